@@ -0,0 +1,140 @@
+// Package cache 进程内 TTL 缓存
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultMaxEntries 默认最大条目数（防止大量未知标识符撑爆负缓存）
+const defaultMaxEntries = 100000
+
+type entry[V any] struct {
+	value     V
+	missing   bool // 负缓存：键对应的数据不存在
+	expiresAt time.Time
+}
+
+// TTL 带过期时间和负缓存的并发安全缓存
+type TTL[V any] struct {
+	mu          sync.RWMutex
+	entries     map[string]entry[V]
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	now         func() time.Time
+}
+
+// NewTTL 创建缓存
+// ttl 为正缓存有效期，negativeTTL 为负缓存有效期（0 表示不缓存不存在的键）
+func NewTTL[V any](ttl, negativeTTL time.Duration) *TTL[V] {
+	return &TTL[V]{
+		entries:     make(map[string]entry[V]),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		maxEntries:  defaultMaxEntries,
+		now:         time.Now,
+	}
+}
+
+// Get 查询缓存
+// ok 表示命中（含负缓存），missing 表示命中的是负缓存
+func (c *TTL[V]) Get(key string) (value V, missing bool, ok bool) {
+	c.mu.RLock()
+	e, found := c.entries[key]
+	c.mu.RUnlock()
+
+	if !found {
+		return value, false, false
+	}
+	if !c.now().Before(e.expiresAt) {
+		c.mu.Lock()
+		if cur, found := c.entries[key]; found && cur.expiresAt == e.expiresAt {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		return value, false, false
+	}
+	return e.value, e.missing, true
+}
+
+// Set 写入正缓存
+func (c *TTL[V]) Set(key string, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.put(key, entry[V]{value: value, expiresAt: c.now().Add(c.ttl)})
+}
+
+// SetMissing 写入负缓存
+func (c *TTL[V]) SetMissing(key string) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+	c.put(key, entry[V]{missing: true, expiresAt: c.now().Add(c.negativeTTL)})
+}
+
+// Delete 删除指定键
+func (c *TTL[V]) Delete(keys ...string) {
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+}
+
+// DeleteFunc 删除满足条件的正缓存条目
+func (c *TTL[V]) DeleteFunc(match func(key string, value V) bool) {
+	c.mu.Lock()
+	for key, e := range c.entries {
+		if !e.missing && match(key, e.value) {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// Purge 清空缓存
+func (c *TTL[V]) Purge() {
+	c.mu.Lock()
+	c.entries = make(map[string]entry[V])
+	c.mu.Unlock()
+}
+
+// Len 当前条目数（含未清理的过期条目）
+func (c *TTL[V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+func (c *TTL[V]) put(key string, e entry[V]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.entries[key]; !found && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = e
+}
+
+// evict 容量满时清理：先删除过期条目，仍然不足时随机淘汰约 1/10
+func (c *TTL[V]) evict() {
+	now := c.now()
+	for key, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+	n := c.maxEntries/10 + 1
+	for key := range c.entries {
+		if n == 0 {
+			break
+		}
+		delete(c.entries, key)
+		n--
+	}
+}
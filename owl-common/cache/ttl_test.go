@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTL_ExpiryAndNegativeCaching(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := NewTTL[string](time.Minute, 10*time.Second)
+	c.now = func() time.Time { return now }
+
+	c.Set("sn-1", "device-1")
+	c.SetMissing("unknown")
+
+	if v, missing, ok := c.Get("sn-1"); !ok || missing || v != "device-1" {
+		t.Fatalf("expected positive hit, got %q missing=%v ok=%v", v, missing, ok)
+	}
+	if _, missing, ok := c.Get("unknown"); !ok || !missing {
+		t.Fatalf("expected negative hit, got missing=%v ok=%v", missing, ok)
+	}
+
+	now = now.Add(11 * time.Second)
+	if _, _, ok := c.Get("unknown"); ok {
+		t.Fatalf("expected negative entry to expire")
+	}
+	if _, _, ok := c.Get("sn-1"); !ok {
+		t.Fatalf("expected positive entry to survive")
+	}
+
+	now = now.Add(time.Minute)
+	if _, _, ok := c.Get("sn-1"); ok {
+		t.Fatalf("expected positive entry to expire")
+	}
+	if c.Len() != 0 {
+		t.Fatalf("expected expired entries to be removed, got %d", c.Len())
+	}
+}
+
+func TestTTL_DeleteFunc(t *testing.T) {
+	c := NewTTL[string](time.Minute, time.Minute)
+	c.Set("sn-1", "device-1")
+	c.Set("uid-1", "device-1")
+	c.Set("sn-2", "device-2")
+	c.SetMissing("unknown")
+
+	c.DeleteFunc(func(_ string, v string) bool { return v == "device-1" })
+
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 entries left, got %d", c.Len())
+	}
+	if _, _, ok := c.Get("sn-2"); !ok {
+		t.Fatalf("expected unrelated entry to remain")
+	}
+}
+
+func TestTTL_EvictsWhenFull(t *testing.T) {
+	c := NewTTL[int](time.Minute, time.Minute)
+	c.maxEntries = 10
+	for i := 0; i < 25; i++ {
+		c.SetMissing(string(rune('a' + i)))
+	}
+	if c.Len() > 10 {
+		t.Fatalf("expected at most 10 entries, got %d", c.Len())
+	}
+}
+
+func TestTTL_DisabledNegativeCache(t *testing.T) {
+	c := NewTTL[int](time.Minute, 0)
+	c.SetMissing("unknown")
+	if _, _, ok := c.Get("unknown"); ok {
+		t.Fatalf("expected negative caching to be disabled")
+	}
+}
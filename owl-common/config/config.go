@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -28,10 +29,31 @@ type RedisConfig struct {
 // MQTTConfig MQTT配置
 type MQTTConfig struct {
 	Broker   string
-	ClientID string
+	ClientID string // 持久会话依赖稳定的 client id；为空时使用 "<hostname>-mqtt"
 	Username string
 	Password string
 	QoS      byte
+
+	// 会话与连接
+	CleanSession   bool          // 默认 false：持久会话，离线期间的 QoS1 消息由 broker 保留
+	KeepAlive      time.Duration // 心跳间隔，默认 30 秒
+	ConnectTimeout time.Duration // 连接超时，默认 30 秒
+
+	// 共享订阅（$share/<group>/<topic>），多副本水平扩展时每条消息只投递给组内一个副本
+	// 设置后 client id 自动追加主机名，保证各副本 client id 唯一且稳定
+	SharedGroup string
+
+	TLS MQTTTLSConfig
+}
+
+// MQTTTLSConfig MQTT TLS/mTLS 配置
+type MQTTTLSConfig struct {
+	Enabled            bool
+	CAFile             string // CA 证书（校验 broker 证书）
+	CertFile           string // 客户端证书（mTLS）
+	KeyFile            string // 客户端私钥（mTLS）
+	ServerName         string // 覆盖 SNI / 证书校验的主机名
+	InsecureSkipVerify bool   // 跳过 broker 证书校验（仅用于测试环境）
 }
 
 // GetDatabaseDSN 获取数据库连接字符串
@@ -89,6 +111,231 @@ func (c *MQTTConfig) LoadFromEnv(prefix string) {
 	if password := os.Getenv(prefix + "_PASSWORD"); password != "" {
 		c.Password = password
 	}
+	if cleanSession := os.Getenv(prefix + "_CLEAN_SESSION"); cleanSession != "" {
+		c.CleanSession = cleanSession == "true"
+	}
+	if keepAlive := os.Getenv(prefix + "_KEEP_ALIVE"); keepAlive != "" {
+		var seconds int64
+		if _, err := fmt.Sscanf(keepAlive, "%d", &seconds); err == nil {
+			c.KeepAlive = time.Duration(seconds) * time.Second
+		}
+	}
+	if sharedGroup := os.Getenv(prefix + "_SHARED_GROUP"); sharedGroup != "" {
+		c.SharedGroup = sharedGroup
+	}
+	if tlsEnabled := os.Getenv(prefix + "_TLS_ENABLED"); tlsEnabled != "" {
+		c.TLS.Enabled = tlsEnabled == "true"
+	}
+	if caFile := os.Getenv(prefix + "_TLS_CA_FILE"); caFile != "" {
+		c.TLS.CAFile = caFile
+	}
+	if certFile := os.Getenv(prefix + "_TLS_CERT_FILE"); certFile != "" {
+		c.TLS.CertFile = certFile
+	}
+	if keyFile := os.Getenv(prefix + "_TLS_KEY_FILE"); keyFile != "" {
+		c.TLS.KeyFile = keyFile
+	}
+	if serverName := os.Getenv(prefix + "_TLS_SERVER_NAME"); serverName != "" {
+		c.TLS.ServerName = serverName
+	}
+	if insecure := os.Getenv(prefix + "_TLS_INSECURE_SKIP_VERIFY"); insecure != "" {
+		c.TLS.InsecureSkipVerify = insecure == "true"
+	}
+}
+
+// StreamConfig Redis Streams 发布配置（保留策略 + 背压策略）
+type StreamConfig struct {
+	// 保留策略（XADD 近似裁剪）
+	MaxLen   int64         // 按长度裁剪：MAXLEN ~ N（0 表示不按长度裁剪）
+	MinIDAge time.Duration // 按时间裁剪：MINID ~ (now - MinIDAge)（0 表示不按时间裁剪）
+
+	// 背压策略（基于 XINFO GROUPS 的消费者组积压）
+	MaxLag           int64         // 积压阈值（0 表示不检查积压）
+	LagPolicy        string        // 积压时的策略：block / drop_oldest / sample
+	SampleRate       int           // sample 策略：低优先级消息每 N 条保留 1 条
+	BlockTimeout     time.Duration // block 策略最长等待时间，超时返回错误
+	LagCheckInterval time.Duration // 积压检查间隔
+
+	ReportInterval time.Duration // 发布统计和积压的指标日志输出间隔（0 表示不输出）
+}
+
+// DefaultStreamConfig 默认流发布配置
+func DefaultStreamConfig() StreamConfig {
+	return StreamConfig{
+		MaxLen:           100000,
+		MaxLag:           10000,
+		LagPolicy:        "sample",
+		SampleRate:       5,
+		BlockTimeout:     5 * time.Second,
+		LagCheckInterval: time.Second,
+		ReportInterval:   60 * time.Second,
+	}
+}
+
+// LoadFromEnv 从环境变量加载流发布配置
+// 如 prefix = "STREAM_RADAR"：STREAM_RADAR_MAXLEN、STREAM_RADAR_MINID_AGE（秒）、STREAM_RADAR_MAX_LAG、
+// STREAM_RADAR_LAG_POLICY、STREAM_RADAR_SAMPLE_RATE、STREAM_RADAR_BLOCK_TIMEOUT（秒）、STREAM_RADAR_REPORT_INTERVAL（秒）
+func (c *StreamConfig) LoadFromEnv(prefix string) {
+	if maxLen := os.Getenv(prefix + "_MAXLEN"); maxLen != "" {
+		fmt.Sscanf(maxLen, "%d", &c.MaxLen)
+	}
+	if minIDAge := os.Getenv(prefix + "_MINID_AGE"); minIDAge != "" {
+		var seconds int64
+		if _, err := fmt.Sscanf(minIDAge, "%d", &seconds); err == nil {
+			c.MinIDAge = time.Duration(seconds) * time.Second
+		}
+	}
+	if maxLag := os.Getenv(prefix + "_MAX_LAG"); maxLag != "" {
+		fmt.Sscanf(maxLag, "%d", &c.MaxLag)
+	}
+	if policy := os.Getenv(prefix + "_LAG_POLICY"); policy != "" {
+		c.LagPolicy = policy
+	}
+	if sampleRate := os.Getenv(prefix + "_SAMPLE_RATE"); sampleRate != "" {
+		fmt.Sscanf(sampleRate, "%d", &c.SampleRate)
+	}
+	if blockTimeout := os.Getenv(prefix + "_BLOCK_TIMEOUT"); blockTimeout != "" {
+		var seconds int64
+		if _, err := fmt.Sscanf(blockTimeout, "%d", &seconds); err == nil {
+			c.BlockTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+	if reportInterval := os.Getenv(prefix + "_REPORT_INTERVAL"); reportInterval != "" {
+		var seconds int64
+		if _, err := fmt.Sscanf(reportInterval, "%d", &seconds); err == nil {
+			c.ReportInterval = time.Duration(seconds) * time.Second
+		}
+	}
+}
+
+// WorkerPoolConfig 按设备分片的有界工作池配置
+type WorkerPoolConfig struct {
+	Workers        int           // 工作协程数（分片数），默认 8
+	QueueSize      int           // 每个分片的队列容量，默认 1000
+	Overflow       string        // 队列满时的策略：block / drop_newest / drop_oldest
+	BlockTimeout   time.Duration // block 策略最长等待时间，超时后丢弃
+	ReportInterval time.Duration // 指标日志输出间隔
+}
+
+// DefaultWorkerPoolConfig 默认工作池配置
+func DefaultWorkerPoolConfig() WorkerPoolConfig {
+	return WorkerPoolConfig{
+		Workers:        8,
+		QueueSize:      1000,
+		Overflow:       "block",
+		BlockTimeout:   5 * time.Second,
+		ReportInterval: 60 * time.Second,
+	}
+}
+
+// LoadFromEnv 从环境变量加载工作池配置
+// 如 prefix = "RADAR_INGEST"：RADAR_INGEST_WORKERS、RADAR_INGEST_QUEUE_SIZE、RADAR_INGEST_OVERFLOW、RADAR_INGEST_BLOCK_TIMEOUT（秒）
+func (c *WorkerPoolConfig) LoadFromEnv(prefix string) {
+	if workers := os.Getenv(prefix + "_WORKERS"); workers != "" {
+		fmt.Sscanf(workers, "%d", &c.Workers)
+	}
+	if queueSize := os.Getenv(prefix + "_QUEUE_SIZE"); queueSize != "" {
+		fmt.Sscanf(queueSize, "%d", &c.QueueSize)
+	}
+	if overflow := os.Getenv(prefix + "_OVERFLOW"); overflow != "" {
+		c.Overflow = overflow
+	}
+	if blockTimeout := os.Getenv(prefix + "_BLOCK_TIMEOUT"); blockTimeout != "" {
+		var seconds int64
+		if _, err := fmt.Sscanf(blockTimeout, "%d", &seconds); err == nil {
+			c.BlockTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+}
+
+// IngestPolicyConfig 采集准入策略配置
+// 设备处于待审批、已拒绝、已禁用、未开启监测或 device_store 撤销授权时，遥测数据被丢弃或隔离
+type IngestPolicyConfig struct {
+	Enabled          bool
+	Quarantine       []string // 需要隔离（而非丢弃）的原因代码，见 owl-common/ingest
+	QuarantineStream string   // 隔离流，如 "ingest:quarantine:stream"
+	QuarantineMaxLen int64    // 隔离流最大长度（近似裁剪）
+}
+
+// DefaultIngestPolicyConfig 默认采集准入策略：待审批和未开启监测的设备隔离，其余丢弃
+func DefaultIngestPolicyConfig() IngestPolicyConfig {
+	return IngestPolicyConfig{
+		Enabled:          true,
+		Quarantine:       []string{"business_access_pending", "monitoring_disabled"},
+		QuarantineStream: "ingest:quarantine:stream",
+		QuarantineMaxLen: 10000,
+	}
+}
+
+// LoadFromEnv 从环境变量加载采集准入策略配置
+// 如 prefix = "INGEST_POLICY"：INGEST_POLICY_ENABLED、INGEST_POLICY_QUARANTINE（逗号分隔的原因代码）、
+// INGEST_POLICY_QUARANTINE_STREAM、INGEST_POLICY_QUARANTINE_MAXLEN
+func (c *IngestPolicyConfig) LoadFromEnv(prefix string) {
+	if enabled := os.Getenv(prefix + "_ENABLED"); enabled != "" {
+		c.Enabled = enabled == "true"
+	}
+	if quarantine, ok := os.LookupEnv(prefix + "_QUARANTINE"); ok {
+		c.Quarantine = nil
+		for _, reason := range strings.Split(quarantine, ",") {
+			if reason = strings.TrimSpace(reason); reason != "" {
+				c.Quarantine = append(c.Quarantine, reason)
+			}
+		}
+	}
+	if stream := os.Getenv(prefix + "_QUARANTINE_STREAM"); stream != "" {
+		c.QuarantineStream = stream
+	}
+	if maxLen := os.Getenv(prefix + "_QUARANTINE_MAXLEN"); maxLen != "" {
+		fmt.Sscanf(maxLen, "%d", &c.QuarantineMaxLen)
+	}
+}
+
+// PresenceConfig 设备在线状态跟踪配置
+// 采集服务记录每台设备最近一次消息时间，静默超过 Timeout 后将 devices.status 置为 offline
+type PresenceConfig struct {
+	Enabled       bool
+	Timeout       time.Duration // 静默窗口，默认 120 秒
+	SweepInterval time.Duration // 离线扫描间隔，默认 15 秒
+	Stream        string        // 在线状态事件流，如 "device:presence:stream"
+	StreamMaxLen  int64         // 事件流最大长度（近似裁剪）
+}
+
+// DefaultPresenceConfig 默认设备在线状态跟踪配置
+func DefaultPresenceConfig() PresenceConfig {
+	return PresenceConfig{
+		Enabled:       true,
+		Timeout:       120 * time.Second,
+		SweepInterval: 15 * time.Second,
+		Stream:        "device:presence:stream",
+		StreamMaxLen:  10000,
+	}
+}
+
+// LoadFromEnv 从环境变量加载设备在线状态跟踪配置
+// 如 prefix = "RADAR_PRESENCE"：RADAR_PRESENCE_ENABLED、RADAR_PRESENCE_TIMEOUT（秒）、
+// RADAR_PRESENCE_SWEEP_INTERVAL（秒）、RADAR_PRESENCE_STREAM、RADAR_PRESENCE_STREAM_MAXLEN
+func (c *PresenceConfig) LoadFromEnv(prefix string) {
+	if enabled := os.Getenv(prefix + "_ENABLED"); enabled != "" {
+		c.Enabled = enabled == "true"
+	}
+	if timeout := os.Getenv(prefix + "_TIMEOUT"); timeout != "" {
+		var seconds int64
+		if _, err := fmt.Sscanf(timeout, "%d", &seconds); err == nil {
+			c.Timeout = time.Duration(seconds) * time.Second
+		}
+	}
+	if interval := os.Getenv(prefix + "_SWEEP_INTERVAL"); interval != "" {
+		var seconds int64
+		if _, err := fmt.Sscanf(interval, "%d", &seconds); err == nil {
+			c.SweepInterval = time.Duration(seconds) * time.Second
+		}
+	}
+	if stream := os.Getenv(prefix + "_STREAM"); stream != "" {
+		c.Stream = stream
+	}
+	if maxLen := os.Getenv(prefix + "_STREAM_MAXLEN"); maxLen != "" {
+		fmt.Sscanf(maxLen, "%d", &c.StreamMaxLen)
+	}
 }
 
 // AlarmConfig 报警服务配置
@@ -0,0 +1,36 @@
+package events
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// CardChangesChannel 卡片变更通知频道（Redis pub/sub）
+// wisefido-card-aggregator 重建单元的卡片后发布，传感器融合服务据此失效卡片拓扑缓存
+// 通知只用于失效缓存：错过的通知由缓存 TTL 兜底
+const CardChangesChannel = "card:changes"
+
+// CardChange 卡片变更通知（单元下的卡片已重建，card_id 和设备归属可能已变化）
+// UnitID 为空时表示全部卡片可能已变更，订阅方应清空全部缓存
+type CardChange struct {
+	TenantID string `json:"tenant_id,omitempty"`
+	UnitID   string `json:"unit_id,omitempty"`
+}
+
+// IsBulk 是否为批量变更（无法定位到具体单元）
+func (c CardChange) IsBulk() bool {
+	return c.UnitID == ""
+}
+
+// PublishCardChange 发布卡片变更通知
+func PublishCardChange(ctx context.Context, client *redis.Client, change CardChange) error {
+	return publishChange(ctx, client, CardChangesChannel, "card", change)
+}
+
+// WatchCardChanges 订阅卡片变更通知，阻塞直到 ctx 取消
+// 订阅建立（含断线重连后重新订阅）时以批量变更调用 handler，因为期间的通知可能已丢失
+func WatchCardChanges(ctx context.Context, client *redis.Client, logger *zap.Logger, handler func(CardChange)) {
+	watchChanges(ctx, client, logger, CardChangesChannel, CardChange{}, handler)
+}
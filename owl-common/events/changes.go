@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// 变更通知（卡片、设备、映射）的发布和订阅
+// 通知只用于失效缓存或触发重新加载，不保证送达：订阅建立（含断线重连后重新订阅）时
+// 以 resync 调用 handler，由订阅方清空全部缓存，期间丢失的通知由此兜底
+
+// publishChange 发布 JSON 编码的变更通知，kind 用于错误信息（如 "card"）
+func publishChange(ctx context.Context, client *redis.Client, channel, kind string, change interface{}) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s change: %w", kind, err)
+	}
+	if err := client.Publish(ctx, channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish %s change: %w", kind, err)
+	}
+	return nil
+}
+
+// watchChanges 订阅变更通知频道，阻塞直到 ctx 取消
+// 订阅建立时以 resync 调用 handler；无法解码的通知记录日志后跳过
+func watchChanges[T any](ctx context.Context, client *redis.Client, logger *zap.Logger, channel string, resync T, handler func(T)) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	pubsub := client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// go-redis 会自动重连并重新订阅
+			logger.Warn("Change notification subscription error",
+				zap.String("channel", channel),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" {
+				logger.Info("Subscribed to change notifications", zap.String("channel", m.Channel))
+				handler(resync)
+			}
+		case *redis.Message:
+			var change T
+			if err := json.Unmarshal([]byte(m.Payload), &change); err != nil {
+				logger.Warn("Invalid change notification",
+					zap.String("channel", channel),
+					zap.String("payload", m.Payload),
+					zap.Error(err),
+				)
+				continue
+			}
+			handler(change)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// 无法解码的通知跳过，不影响后续通知
+func TestWatchChanges_SkipsInvalidPayload(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan MappingChange, 4)
+	go watchChanges(ctx, client, nil, MappingChangesChannel, MappingChange{Table: "resync"}, func(c MappingChange) { received <- c })
+
+	select {
+	case c := <-received:
+		if c.Table != "resync" {
+			t.Fatalf("expected resync change on subscribe, got %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for subscription")
+	}
+
+	if err := client.Publish(ctx, MappingChangesChannel, "not json").Err(); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	want := MappingChange{Table: MappingTableField}
+	if err := PublishMappingChange(ctx, client, want); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Fatalf("expected %+v, got %+v", want, got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for mapping change")
+	}
+}
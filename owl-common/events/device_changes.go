@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// DeviceChangesChannel 设备变更通知频道（Redis pub/sub）
+// wisefido-data 在 devices / device_store 变更后发布，采集服务据此失效设备身份缓存
+// 通知只用于失效缓存：错过的通知由缓存 TTL 兜底
+const DeviceChangesChannel = "device:changes"
+
+// 设备变更类型
+const (
+	DeviceChangeBind          = "bind"           // 设备创建或绑定到房间/床位
+	DeviceChangeUnbind        = "unbind"         // 解除绑定、删除或禁用设备
+	DeviceChangeReassign      = "reassign"       // device_store 分配给其他租户（含退回未分配）
+	DeviceChangeAccessRevoked = "access_revoked" // device_store.allow_access 被撤销
+	DeviceChangeUpdated       = "updated"        // 其他变更（入库、授权恢复等）
+)
+
+// DeviceChange 设备变更通知
+// 标识字段均为空时表示批量变更，订阅方应清空全部缓存
+type DeviceChange struct {
+	Action       string `json:"action"`
+	DeviceID     string `json:"device_id,omitempty"`
+	TenantID     string `json:"tenant_id,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	UID          string `json:"uid,omitempty"`
+}
+
+// IsBulk 是否为批量变更（无法定位到具体设备）
+func (c DeviceChange) IsBulk() bool {
+	return c.DeviceID == "" && c.SerialNumber == "" && c.UID == ""
+}
+
+// PublishDeviceChange 发布设备变更通知
+func PublishDeviceChange(ctx context.Context, client *redis.Client, change DeviceChange) error {
+	return publishChange(ctx, client, DeviceChangesChannel, "device", change)
+}
+
+// WatchDeviceChanges 订阅设备变更通知，阻塞直到 ctx 取消
+// 订阅建立（含断线重连后重新订阅）时以批量变更调用 handler，因为期间的通知可能已丢失
+func WatchDeviceChanges(ctx context.Context, client *redis.Client, logger *zap.Logger, handler func(DeviceChange)) {
+	watchChanges(ctx, client, logger, DeviceChangesChannel, DeviceChange{Action: DeviceChangeUpdated}, handler)
+}
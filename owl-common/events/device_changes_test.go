@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestWatchDeviceChanges(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan DeviceChange, 4)
+	go WatchDeviceChanges(ctx, client, nil, func(c DeviceChange) { received <- c })
+
+	// 订阅建立时先收到一次批量变更
+	select {
+	case c := <-received:
+		if !c.IsBulk() {
+			t.Fatalf("expected bulk change on subscribe, got %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for subscription")
+	}
+
+	want := DeviceChange{Action: DeviceChangeAccessRevoked, TenantID: "t-1", SerialNumber: "SN-1", UID: "UID-1"}
+	if err := PublishDeviceChange(ctx, client, want); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Fatalf("expected %+v, got %+v", want, got)
+		}
+		if got.IsBulk() {
+			t.Fatalf("expected targeted change")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for device change")
+	}
+}
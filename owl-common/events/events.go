@@ -0,0 +1,183 @@
+// Package events 定义服务间 Redis Streams 消息契约（信封 payload）
+//
+// 数据流：
+//
+//	wisefido-radar / wisefido-sleepace --(DeviceData)--> radar:data:stream / sleepace:data:stream
+//	wisefido-data-transformer --(IoTData)--> iot:data:stream --> wisefido-sensor-fusion
+//	wisefido-data --(CardEvent)--> card:events --> wisefido-card-aggregator
+//	wisefido-radar / wisefido-sleepace --(QuarantinedTelemetry)--> ingest:quarantine:stream --> wisefido-data（管理端释放）
+//	wisefido-radar / wisefido-sleepace --(DevicePresence)--> device:presence:stream --> wisefido-alarm（OfflineAlarm）
+//	wisefido-sleepace --(DeviceAlarm)--> device:alarm:stream --> wisefido-alarm（设备上报的报警）
+//	wisefido-sleepace --(SleepReportReady)--> sleepace:report:stream --> wisefido-data（下载睡眠报告）
+//	wisefido-sensor-fusion --(TrackEvent)--> track:event:stream --> wisefido-alarm / 报表（雷达目标轨迹事件）
+//
+// payload 结构发生变化（包括新增字段）时必须递增对应 Schema 的版本号：
+// 消费者严格解码，不认识的字段视为契约不符；消费者兼容所有不高于自身的版本，
+// 因此升级时先部署消费者，再部署生产者。
+package events
+
+import (
+	rediscommon "owl-common/redis"
+	"time"
+)
+
+// 契约定义
+var (
+	DeviceDataSchema  = rediscommon.Schema{Name: "device.data", Version: 2} // v2: model / firmware_version
+	IoTDataSchema     = rediscommon.Schema{Name: "iot.data", Version: 2}    // v2: frame_id / target_count / samples（含 radar_pos_* / hr_quality / rr_quality）
+	CardEventSchema   = rediscommon.Schema{Name: "card.event", Version: 1}
+	QuarantineSchema  = rediscommon.Schema{Name: "ingest.quarantined", Version: 1}
+	PresenceSchema    = rediscommon.Schema{Name: "device.presence", Version: 2} // v2: firmware_version / rssi / uptime_sec
+	DeviceAlarmSchema = rediscommon.Schema{Name: "device.alarm", Version: 1}
+	SleepReportSchema = rediscommon.Schema{Name: "sleepace.report_ready", Version: 2} // v2: user_id
+	TrackEventSchema  = rediscommon.Schema{Name: "track.event", Version: 1}
+)
+
+// DeviceData 设备原始数据（采集服务 -> 数据转换服务）
+// 租户与事件时间由信封携带
+type DeviceData struct {
+	DeviceID     string                 `json:"device_id"`
+	SerialNumber string                 `json:"serial_number"`
+	UID          string                 `json:"uid"`
+	DeviceType   string                 `json:"device_type"` // "Radar" 或 "Sleepace"
+	RawData      map[string]interface{} `json:"raw_data"`
+	Topic        string                 `json:"topic,omitempty"`
+
+	// 设备型号 / 固件版本（device_store 登记值，用于选择转换器和版本相关的编码映射）
+	Model           string `json:"model,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+}
+
+// IoTData 标准化数据已写入 iot_timeseries（数据转换服务 -> 传感器融合服务）
+type IoTData struct {
+	IoTTimeSeriesID int64  `json:"iot_timeseries_id"`
+	DeviceID        string `json:"device_id"`
+	DeviceType      string `json:"device_type"`
+	DataType        string `json:"data_type"` // "observation" or "alarm"
+	Category        string `json:"category"`  // FHIR Category
+
+	// 多目标雷达帧：IoTTimeSeriesID 为第一个目标的行，同帧各行 frame_id 相同
+	FrameID     string `json:"frame_id,omitempty"`
+	TargetCount int    `json:"target_count,omitempty"`
+
+	// 已写入 iot_timeseries 的标准化数据（每行一条，多目标帧按目标顺序）
+	// 传感器融合服务据此直接更新内存状态；旧版本消息没有该字段，融合服务回退到查询数据库
+	Samples []IoTSample `json:"samples,omitempty"`
+}
+
+// IoTSample 一行标准化数据中参与融合的字段（事件时间取信封 EventTime）
+type IoTSample struct {
+	HeartRate       *int    `json:"heart_rate,omitempty"`
+	RespiratoryRate *int    `json:"respiratory_rate,omitempty"`
+	TrackingID      *string `json:"tracking_id,omitempty"`
+	PostureCode     *string `json:"posture_code,omitempty"`
+	PostureDisplay  *string `json:"posture_display,omitempty"`
+	BedStatusCode   *string `json:"bed_status_code,omitempty"`
+	SleepStateCode  *string `json:"sleep_state_code,omitempty"`
+	QualityFlag     string  `json:"quality_flag,omitempty"` // 整行最严重的质量标记
+
+	// 各生命体征的质量标记，下游按此单独决定是否采信（缺省时回退到 QualityFlag）
+	HeartRateQuality       string `json:"hr_quality,omitempty"`
+	RespiratoryRateQuality string `json:"rr_quality,omitempty"`
+
+	// 雷达目标位置（cm，雷达坐标系）
+	RadarPosX *int `json:"radar_pos_x,omitempty"`
+	RadarPosY *int `json:"radar_pos_y,omitempty"`
+	RadarPosZ *int `json:"radar_pos_z,omitempty"`
+}
+
+// CardEvent 卡片相关的绑定/状态变化事件（wisefido-data -> 卡片聚合服务）
+type CardEvent struct {
+	EventType  string                 `json:"event_type"`
+	UnitID     string                 `json:"unit_id,omitempty"`
+	BedID      string                 `json:"bed_id,omitempty"`
+	DeviceID   string                 `json:"device_id,omitempty"`
+	ResidentID string                 `json:"resident_id,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// QuarantinedTelemetry 被采集准入策略隔离的遥测数据（采集服务 -> 隔离流）
+// 管理端释放设备后，Data 按原信封元数据重新发布到 SourceStream
+type QuarantinedTelemetry struct {
+	Reason       string     `json:"reason"`        // 原因代码，见 owl-common/ingest
+	SourceStream string     `json:"source_stream"` // 原目标流，如 "radar:data:stream"
+	Data         DeviceData `json:"data"`
+}
+
+// DevicePresence 设备在线状态变化（采集服务 -> 报警服务）
+// 在 devices.status 实际发生 online/offline 切换时发布；
+// 在线状态未变化时，设备运行信息变化或定期以 reason = "device_info" 发布（status 为 online）
+type DevicePresence struct {
+	DeviceID     string    `json:"device_id"`
+	SerialNumber string    `json:"serial_number,omitempty"`
+	UID          string    `json:"uid,omitempty"`
+	DeviceType   string    `json:"device_type"`
+	Status       string    `json:"status"`    // "online" 或 "offline"
+	Reason       string    `json:"reason"`    // 原因代码，见 owl-common/presence
+	LastSeen     time.Time `json:"last_seen"` // 最近一次收到设备消息的时间
+
+	// 设备自报的运行信息（状态主题 / 遗嘱消息携带时填充）
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+	RSSI            *int   `json:"rssi,omitempty"`
+	UptimeSec       *int64 `json:"uptime_sec,omitempty"`
+}
+
+// 设备报警状态
+const (
+	DeviceAlarmTrigger = "trigger" // 设备触发报警
+	DeviceAlarmRelieve = "relieve" // 设备解除报警
+)
+
+// DeviceAlarm 设备自身上报的报警（采集服务 -> 报警服务）
+// 厂家报警类型由报警服务按配置映射为 alarm_events.event_type；
+// 解除消息携带与触发消息相同的 VendorAlarmID，用于关闭对应的报警事件
+type DeviceAlarm struct {
+	DeviceID      string     `json:"device_id"`
+	SerialNumber  string     `json:"serial_number,omitempty"`
+	UID           string     `json:"uid,omitempty"`
+	DeviceType    string     `json:"device_type"`
+	VendorAlarmID string     `json:"vendor_alarm_id,omitempty"` // 厂家报警 ID
+	VendorType    string     `json:"vendor_type"`               // 厂家报警类型
+	Status        string     `json:"status"`                    // "trigger" 或 "relieve"
+	UserID        string     `json:"user_id,omitempty"`         // 厂家用户 ID（解除操作人）
+	RelieveReason string     `json:"relieve_reason,omitempty"`
+	RelieveTime   *time.Time `json:"relieve_time,omitempty"`
+}
+
+// SleepReportReady 厂家平台已完成睡眠分析，报告可下载（Sleepace analysis 通知）
+type SleepReportReady struct {
+	DeviceID   string `json:"device_id"`         // 报告所属传感器（双侧睡眠垫为该侧的逻辑传感器）
+	DeviceCode string `json:"device_code"`       // 厂家设备编码（下载报告时使用）
+	Side       string `json:"side,omitempty"`    // 双侧睡眠垫的侧（"left" / "right"），下载时只保存该侧的报告
+	UserID     string `json:"user_id,omitempty"` // 厂家用户 ID（analysis 通知的 userId，下载报告时使用）
+	StartTime  int64  `json:"start_time"`        // 报告开始时间（Unix 秒）
+	EndTime    int64  `json:"end_time"`          // 报告结束时间（Unix 秒）
+}
+
+// 雷达目标轨迹事件类型
+const (
+	TrackEntry     = "entry"      // 新目标出现
+	TrackExit      = "exit"       // 目标消失（超过超时时间未再出现）
+	TrackZoneEnter = "zone_enter" // 进入区域
+	TrackZoneExit  = "zone_exit"  // 离开区域（携带停留时长）
+	TrackDwell     = "dwell"      // 在区域内停留超过阈值（每次停留只发布一次）
+	TrackGaitSpeed = "gait_speed" // 行走速度
+)
+
+// TrackEvent 雷达目标轨迹事件（传感器融合服务 -> 报警服务 / 报表）
+// 位置为单元布局坐标（cm），区域来自 units.layout_config
+type TrackEvent struct {
+	DeviceID      string   `json:"device_id"`
+	CardID        string   `json:"card_id,omitempty"`
+	UnitID        string   `json:"unit_id,omitempty"`
+	TrackingID    string   `json:"tracking_id"`
+	EventType     string   `json:"event_type"`
+	ZoneID        string   `json:"zone_id,omitempty"`
+	ZoneType      string   `json:"zone_type,omitempty"` // "bed" / "bathroom_door" / "chair" / "exit" 等
+	ZoneName      string   `json:"zone_name,omitempty"`
+	DwellSeconds  *int     `json:"dwell_seconds,omitempty"`    // dwell / zone_exit：本次停留时长
+	SpeedCmPerSec *float64 `json:"speed_cm_per_sec,omitempty"` // gait_speed：窗口内平均速度
+	X             int      `json:"x"`
+	Y             int      `json:"y"`
+	Z             *int     `json:"z,omitempty"`
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	rediscommon "owl-common/redis"
+)
+
+func encode[T any](t *testing.T, schema rediscommon.Schema, payload T) rediscommon.StreamMessage {
+	t.Helper()
+	values, err := rediscommon.Encode(rediscommon.NewEnvelope(schema, "test", "tenant-1", time.Now(), payload))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	return rediscommon.StreamMessage{ID: "1-0", Values: values}
+}
+
+// v1 生产者的消息仍能被当前消费者解码
+func TestSchemas_DecodeOlderVersion(t *testing.T) {
+	v1 := func(s rediscommon.Schema) rediscommon.Schema { return rediscommon.Schema{Name: s.Name, Version: 1} }
+
+	if _, err := rediscommon.Decode[DeviceData](encode(t, v1(DeviceDataSchema), map[string]any{"device_id": "d-1", "raw_data": map[string]any{}}), DeviceDataSchema); err != nil {
+		t.Fatalf("device.data v1: %v", err)
+	}
+	if _, err := rediscommon.Decode[IoTData](encode(t, v1(IoTDataSchema), map[string]any{"iot_timeseries_id": 1, "device_id": "d-1"}), IoTDataSchema); err != nil {
+		t.Fatalf("iot.data v1: %v", err)
+	}
+	if _, err := rediscommon.Decode[DevicePresence](encode(t, v1(PresenceSchema), map[string]any{"device_id": "d-1"}), PresenceSchema); err != nil {
+		t.Fatalf("device.presence v1: %v", err)
+	}
+	if _, err := rediscommon.Decode[SleepReportReady](encode(t, v1(SleepReportSchema), map[string]any{"device_id": "d-1", "side": "left"}), SleepReportSchema); err != nil {
+		t.Fatalf("sleepace.report_ready v1: %v", err)
+	}
+}
+
+// 新增字段的消息必须携带新版本号：旧消费者按版本拒绝，而不是在 payload 解码时失败
+func TestSchemas_NewFieldsRequireVersionBump(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  rediscommon.Schema
+		payload any
+		decode  func(rediscommon.StreamMessage, rediscommon.Schema) error
+	}{
+		{"device.data", DeviceDataSchema, DeviceData{DeviceID: "d-1", Model: "M1", FirmwareVersion: "2.1"},
+			func(m rediscommon.StreamMessage, s rediscommon.Schema) error {
+				_, err := rediscommon.Decode[DeviceData](m, s)
+				return err
+			}},
+		{"iot.data", IoTDataSchema, IoTData{DeviceID: "d-1", FrameID: "1-0", TargetCount: 2, Samples: []IoTSample{{}}},
+			func(m rediscommon.StreamMessage, s rediscommon.Schema) error {
+				_, err := rediscommon.Decode[IoTData](m, s)
+				return err
+			}},
+		{"device.presence", PresenceSchema, DevicePresence{DeviceID: "d-1", FirmwareVersion: "2.1"},
+			func(m rediscommon.StreamMessage, s rediscommon.Schema) error {
+				_, err := rediscommon.Decode[DevicePresence](m, s)
+				return err
+			}},
+		{"sleepace.report_ready", SleepReportSchema, SleepReportReady{DeviceID: "d-1", UserID: "u-1"},
+			func(m rediscommon.StreamMessage, s rediscommon.Schema) error {
+				_, err := rediscommon.Decode[SleepReportReady](m, s)
+				return err
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.schema.Version < 2 {
+				t.Fatalf("%s must be at least v2", tt.schema)
+			}
+			msg := encode(t, tt.schema, tt.payload)
+			if err := tt.decode(msg, tt.schema); err != nil {
+				t.Fatalf("current consumer: %v", err)
+			}
+			old := rediscommon.Schema{Name: tt.schema.Name, Version: 1}
+			if err := tt.decode(msg, old); !errors.Is(err, rediscommon.ErrSchemaMismatch) {
+				t.Fatalf("v1 consumer should reject by version, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// MappingChangesChannel 编码映射变更通知频道（Redis pub/sub）
+// 映射维护（迁移脚本、管理工具）后发布，如 redis-cli PUBLISH mapping:changes '{"table":"snomed_mapping"}'，数据转换服务据此重新加载映射
+// 通知只用于触发重新加载：错过的通知由定期校验和轮询兜底
+const MappingChangesChannel = "mapping:changes"
+
+// 映射表
+const (
+	MappingTableSNOMED = "snomed_mapping"
+	MappingTableField  = "field_mapping"
+)
+
+// MappingChange 映射变更通知
+// Table 为空时表示全部映射可能已变更（如订阅建立时）
+type MappingChange struct {
+	Table       string `json:"table,omitempty"`
+	MappingType string `json:"mapping_type,omitempty"` // snomed_mapping.mapping_type（posture / event 等）
+}
+
+// Affects 变更是否涉及指定映射表
+func (c MappingChange) Affects(table string) bool {
+	return c.Table == "" || c.Table == table
+}
+
+// PublishMappingChange 发布映射变更通知
+func PublishMappingChange(ctx context.Context, client *redis.Client, change MappingChange) error {
+	return publishChange(ctx, client, MappingChangesChannel, "mapping", change)
+}
+
+// WatchMappingChanges 订阅映射变更通知，阻塞直到 ctx 取消
+// 订阅建立（含断线重连后重新订阅）时以全部变更调用 handler，因为期间的通知可能已丢失
+func WatchMappingChanges(ctx context.Context, client *redis.Client, logger *zap.Logger, handler func(MappingChange)) {
+	watchChanges(ctx, client, logger, MappingChangesChannel, MappingChange{}, handler)
+}
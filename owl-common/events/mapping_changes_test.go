@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestWatchMappingChanges(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan MappingChange, 4)
+	go WatchMappingChanges(ctx, client, nil, func(c MappingChange) { received <- c })
+
+	// 订阅建立时先收到一次全部变更
+	select {
+	case c := <-received:
+		if !c.Affects(MappingTableSNOMED) || !c.Affects(MappingTableField) {
+			t.Fatalf("expected change affecting all tables on subscribe, got %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for subscription")
+	}
+
+	want := MappingChange{Table: MappingTableSNOMED, MappingType: "posture"}
+	if err := PublishMappingChange(ctx, client, want); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Fatalf("expected %+v, got %+v", want, got)
+		}
+		if got.Affects(MappingTableField) {
+			t.Fatalf("expected change limited to %s", MappingTableSNOMED)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for mapping change")
+	}
+}
@@ -0,0 +1,47 @@
+package ingest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDeviceTime 解析设备上报的时间戳
+// 支持 Unix 秒 / 毫秒 / 微秒（按数量级判断，可为数字或数字字符串）和 RFC3339 字符串；
+// 无法解析或为 0 时返回 false（调用方使用接收时间）
+func ParseDeviceTime(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case float64:
+		return unixTime(val)
+	case int64:
+		return unixTime(float64(val))
+	case int:
+		return unixTime(float64(val))
+	case string:
+		s := strings.TrimSpace(val)
+		if s == "" {
+			return time.Time{}, false
+		}
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return unixTime(n)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// unixTime 按数量级换算 Unix 时间戳（秒 < 1e11 <= 毫秒 < 1e14 <= 微秒）
+func unixTime(n float64) (time.Time, bool) {
+	switch {
+	case n <= 0:
+		return time.Time{}, false
+	case n < 1e11:
+		return time.Unix(0, int64(n*float64(time.Second))), true
+	case n < 1e14:
+		return time.UnixMilli(int64(n)), true
+	default:
+		return time.UnixMicro(int64(n)), true
+	}
+}
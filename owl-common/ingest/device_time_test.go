@@ -0,0 +1,35 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeviceTime(t *testing.T) {
+	want := time.Unix(1700000000, 0)
+	cases := []struct {
+		name string
+		in   interface{}
+		ok   bool
+	}{
+		{"seconds", float64(1700000000), true},
+		{"milliseconds", float64(1700000000000), true},
+		{"microseconds", int64(1700000000000000), true},
+		{"numeric string", "1700000000", true},
+		{"rfc3339", "2023-11-14T22:13:20Z", true},
+		{"zero", float64(0), false},
+		{"empty", "", false},
+		{"garbage", "yesterday", false},
+		{"missing", nil, false},
+	}
+	for _, c := range cases {
+		got, ok := ParseDeviceTime(c.in)
+		if ok != c.ok {
+			t.Errorf("%s: expected ok=%v, got %v", c.name, c.ok, ok)
+			continue
+		}
+		if ok && !got.Equal(want) {
+			t.Errorf("%s: expected %v, got %v", c.name, want, got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package ingest 采集准入策略
+//
+// 采集服务（wisefido-radar / wisefido-sleepace）在发布遥测数据前检查设备的业务状态：
+// device_store 撤销授权、设备禁用、审批被拒绝、待审批或未开启监测时，数据不进入处理管道，
+// 按配置丢弃或写入隔离流（附原因代码），由 wisefido-data 管理端查看并释放。
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"owl-common/config"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// 原因代码（按检查顺序）
+const (
+	ReasonAccessRevoked = "access_revoked"           // device_store.allow_access = false
+	ReasonDisabled      = "device_disabled"          // devices.status = 'disabled'
+	ReasonRejected      = "business_access_rejected" // devices.business_access = 'rejected'
+	ReasonPending       = "business_access_pending"  // devices.business_access = 'pending'
+	ReasonNotMonitored  = "monitoring_disabled"      // devices.monitoring_enabled = false
+)
+
+// DeviceState 准入检查所需的设备状态
+type DeviceState struct {
+	Status            string // devices.status
+	BusinessAccess    string // devices.business_access
+	MonitoringEnabled bool   // devices.monitoring_enabled
+	AllowAccess       bool   // device_store.allow_access
+}
+
+// Check 返回设备被拦截的原因代码，空字符串表示放行
+func Check(state DeviceState) string {
+	switch {
+	case !state.AllowAccess:
+		return ReasonAccessRevoked
+	case state.Status == "disabled":
+		return ReasonDisabled
+	case state.BusinessAccess == "rejected":
+		return ReasonRejected
+	case state.BusinessAccess == "pending":
+		return ReasonPending
+	case !state.MonitoringEnabled:
+		return ReasonNotMonitored
+	}
+	return ""
+}
+
+// Gate 采集准入关卡
+type Gate struct {
+	client     *redis.Client
+	cfg        config.IngestPolicyConfig
+	quarantine map[string]bool
+	logger     *zap.Logger
+
+	dropped     int64
+	quarantined int64
+}
+
+// NewGate 创建采集准入关卡
+func NewGate(client *redis.Client, cfg config.IngestPolicyConfig, logger *zap.Logger) *Gate {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	quarantine := make(map[string]bool, len(cfg.Quarantine))
+	for _, reason := range cfg.Quarantine {
+		quarantine[reason] = true
+	}
+	return &Gate{
+		client:     client,
+		cfg:        cfg,
+		quarantine: quarantine,
+		logger:     logger,
+	}
+}
+
+// Admit 执行准入检查
+// 放行返回 true；拦截时按策略丢弃或写入隔离流，返回 false
+// sourceStream 为放行时的目标流，隔离数据释放后重新发布到该流
+func (g *Gate) Admit(ctx context.Context, env rediscommon.Envelope[events.DeviceData], sourceStream string, state DeviceState) (bool, error) {
+	if !g.cfg.Enabled {
+		return true, nil
+	}
+	reason := Check(state)
+	if reason == "" {
+		return true, nil
+	}
+
+	if !g.quarantine[reason] {
+		atomic.AddInt64(&g.dropped, 1)
+		g.logger.Debug("Telemetry dropped by ingest policy",
+			zap.String("device_id", env.Payload.DeviceID),
+			zap.String("reason", reason),
+		)
+		return false, nil
+	}
+
+	quarantined := rediscommon.Envelope[events.QuarantinedTelemetry]{
+		Schema:     events.QuarantineSchema,
+		Producer:   env.Producer,
+		TraceID:    env.TraceID,
+		TenantID:   env.TenantID,
+		EventTime:  env.EventTime,
+		IngestTime: env.IngestTime,
+		Payload: events.QuarantinedTelemetry{
+			Reason:       reason,
+			SourceStream: sourceStream,
+			Data:         env.Payload,
+		},
+	}
+	values, err := rediscommon.Encode(quarantined)
+	if err != nil {
+		return false, err
+	}
+	retention := config.StreamConfig{MaxLen: g.cfg.QuarantineMaxLen}
+	if _, err := rediscommon.PublishToStreamWithRetention(ctx, g.client, g.cfg.QuarantineStream, values, retention); err != nil {
+		return false, fmt.Errorf("failed to quarantine telemetry: %w", err)
+	}
+
+	atomic.AddInt64(&g.quarantined, 1)
+	g.logger.Debug("Telemetry quarantined by ingest policy",
+		zap.String("device_id", env.Payload.DeviceID),
+		zap.String("reason", reason),
+		zap.String("stream", g.cfg.QuarantineStream),
+	)
+	return false, nil
+}
+
+// Stats 返回累计丢弃数和隔离数
+func (g *Gate) Stats() (dropped, quarantined int64) {
+	return atomic.LoadInt64(&g.dropped), atomic.LoadInt64(&g.quarantined)
+}
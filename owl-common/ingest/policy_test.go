@@ -0,0 +1,98 @@
+package ingest
+
+import (
+	"context"
+	"owl-common/config"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestCheck(t *testing.T) {
+	active := DeviceState{Status: "online", BusinessAccess: "approved", MonitoringEnabled: true, AllowAccess: true}
+	cases := []struct {
+		name   string
+		modify func(*DeviceState)
+		want   string
+	}{
+		{"active", func(*DeviceState) {}, ""},
+		{"access revoked", func(s *DeviceState) { s.AllowAccess = false; s.BusinessAccess = "pending" }, ReasonAccessRevoked},
+		{"disabled", func(s *DeviceState) { s.Status = "disabled"; s.BusinessAccess = "rejected" }, ReasonDisabled},
+		{"rejected", func(s *DeviceState) { s.BusinessAccess = "rejected" }, ReasonRejected},
+		{"pending", func(s *DeviceState) { s.BusinessAccess = "pending"; s.MonitoringEnabled = false }, ReasonPending},
+		{"not monitored", func(s *DeviceState) { s.MonitoringEnabled = false }, ReasonNotMonitored},
+	}
+	for _, tc := range cases {
+		state := active
+		tc.modify(&state)
+		if got := Check(state); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestGate_Admit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	cfg := config.DefaultIngestPolicyConfig()
+	gate := NewGate(client, cfg, nil)
+
+	env := rediscommon.NewEnvelope(events.DeviceDataSchema, "wisefido-radar", "tenant-1", time.Now(), events.DeviceData{
+		DeviceID:   "device-1",
+		DeviceType: "Radar",
+		RawData:    map[string]interface{}{"heart_rate": 72.0},
+	})
+
+	admitted, err := gate.Admit(ctx, env, "radar:data:stream", DeviceState{Status: "online", BusinessAccess: "approved", MonitoringEnabled: true, AllowAccess: true})
+	if err != nil || !admitted {
+		t.Fatalf("expected active device to be admitted, got %v, %v", admitted, err)
+	}
+
+	// 待审批：隔离
+	admitted, err = gate.Admit(ctx, env, "radar:data:stream", DeviceState{Status: "online", BusinessAccess: "pending", AllowAccess: true})
+	if err != nil || admitted {
+		t.Fatalf("expected pending device to be blocked, got %v, %v", admitted, err)
+	}
+	// 已拒绝：丢弃
+	admitted, err = gate.Admit(ctx, env, "radar:data:stream", DeviceState{Status: "online", BusinessAccess: "rejected", AllowAccess: true})
+	if err != nil || admitted {
+		t.Fatalf("expected rejected device to be blocked, got %v, %v", admitted, err)
+	}
+
+	dropped, quarantined := gate.Stats()
+	if dropped != 1 || quarantined != 1 {
+		t.Fatalf("expected 1 dropped and 1 quarantined, got %d, %d", dropped, quarantined)
+	}
+
+	msgs, err := client.XRange(ctx, cfg.QuarantineStream, "-", "+").Result()
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected 1 quarantined message, got %d, %v", len(msgs), err)
+	}
+	decoded, err := rediscommon.Decode[events.QuarantinedTelemetry](rediscommon.StreamMessage{ID: msgs[0].ID, Values: msgs[0].Values}, events.QuarantineSchema)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.Payload.Reason != ReasonPending || decoded.Payload.SourceStream != "radar:data:stream" ||
+		decoded.Payload.Data.DeviceID != "device-1" || decoded.TraceID != env.TraceID || decoded.TenantID != "tenant-1" {
+		t.Fatalf("unexpected quarantined envelope: %+v", decoded)
+	}
+}
+
+func TestGate_Disabled(t *testing.T) {
+	cfg := config.DefaultIngestPolicyConfig()
+	cfg.Enabled = false
+	gate := NewGate(nil, cfg, nil)
+
+	env := rediscommon.NewEnvelope(events.DeviceDataSchema, "wisefido-radar", "tenant-1", time.Now(), events.DeviceData{DeviceID: "device-1"})
+	admitted, err := gate.Admit(context.Background(), env, "radar:data:stream", DeviceState{BusinessAccess: "rejected"})
+	if err != nil || !admitted {
+		t.Fatalf("expected disabled policy to admit everything, got %v, %v", admitted, err)
+	}
+}
@@ -1,90 +1,352 @@
 package mqtt
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
 	"owl-common/config"
-	
+	"strings"
+	"sync"
+	"time"
+
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.uber.org/zap"
 )
 
-// MessageHandler 消息处理函数类型
+// maxUnroutedMessages 订阅注册前缓存的消息上限
+// 持久会话下 broker 会在 CONNACK 后立即投递离线期间的 QoS1 消息，此时业务订阅可能尚未注册
+const maxUnroutedMessages = 1000
+
+// MessageHandler 消息处理函数类型（返回后确认消息）
 type MessageHandler func(topic string, payload []byte) error
 
+// AckHandler 异步消息处理函数类型：处理完成后调用 ack 确认消息（可在其他协程中调用，多次调用只确认一次）
+// 未确认的 QoS1 消息在重连后由 broker 重新投递；返回错误时消息不会再被处理，直接确认
+type AckHandler func(topic string, payload []byte, ack func()) error
+
+// Hooks 连接生命周期回调
+type Hooks struct {
+	OnConnect        func()          // 首次连接和每次重连成功后调用（订阅已恢复）
+	OnConnectionLost func(err error) // 连接断开时调用
+	OnReconnecting   func()          // 开始重连时调用
+}
+
+// subscription 已注册的订阅
+type subscription struct {
+	qos     byte
+	handler AckHandler
+}
+
 // Client MQTT客户端封装
+//
+// - 默认持久会话（CleanSession=false）+ 稳定 client id，离线期间的 QoS1 消息由 broker 保留
+// - 关闭自动确认：消息在业务处理完成后才确认，进程退出时未处理的消息由 broker 重新投递
+// - 维护订阅注册表，重连后自动重新订阅
+// - 支持 TLS/mTLS
 type Client struct {
 	client mqtt.Client
 	config *config.MQTTConfig
+	logger *zap.Logger
+	hooks  Hooks
+
+	mu            sync.RWMutex
+	subscriptions map[string]subscription // topic filter -> 订阅
+	unrouted      []mqtt.Message          // 订阅注册前收到的消息
+}
+
+// NewClient 创建MQTT客户端并连接
+func NewClient(cfg *config.MQTTConfig, logger *zap.Logger) (*Client, error) {
+	return NewClientWithHooks(cfg, logger, Hooks{})
 }
 
-// NewClient 创建MQTT客户端
-func NewClient(cfg *config.MQTTConfig, logger interface{}) (*Client, error) {
+// NewClientWithHooks 创建带生命周期回调的 MQTT 客户端并连接
+func NewClientWithHooks(cfg *config.MQTTConfig, logger *zap.Logger, hooks Hooks) (*Client, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	c := &Client{
+		config:        cfg,
+		logger:        logger,
+		hooks:         hooks,
+		subscriptions: make(map[string]subscription),
+	}
+
+	opts, err := c.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	c.client = mqtt.NewClient(opts)
+
+	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
+	}
+
+	return c, nil
+}
+
+// clientOptions 构建 paho 连接选项
+func (c *Client) clientOptions() (*mqtt.ClientOptions, error) {
+	cfg := c.config
+
 	opts := mqtt.NewClientOptions()
 	opts.AddBroker(cfg.Broker)
-	opts.SetClientID(cfg.ClientID)
-	
+	opts.SetClientID(clientID(cfg))
+
 	if cfg.Username != "" {
 		opts.SetUsername(cfg.Username)
 	}
 	if cfg.Password != "" {
 		opts.SetPassword(cfg.Password)
 	}
-	
+
 	opts.SetAutoReconnect(true)
-	opts.SetCleanSession(true)
-	
-	client := mqtt.NewClient(opts)
-	
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
+	opts.SetAutoAckDisabled(true) // 由 dispatch / AckHandler 确认
+	opts.SetCleanSession(cfg.CleanSession)
+	opts.SetKeepAlive(durationOrDefault(cfg.KeepAlive, 30*time.Second))
+	opts.SetConnectTimeout(durationOrDefault(cfg.ConnectTimeout, 30*time.Second))
+	opts.SetMaxReconnectInterval(time.Minute)
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := NewTLSConfig(&cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
 	}
-	
-	return &Client{
-		client: client,
-		config: cfg,
-	}, nil
+
+	opts.SetDefaultPublishHandler(c.handleUnrouted)
+	opts.SetOnConnectHandler(c.onConnect)
+	opts.SetConnectionLostHandler(c.onConnectionLost)
+	opts.SetReconnectingHandler(c.onReconnecting)
+
+	return opts, nil
 }
 
-// Subscribe 订阅主题
-func (c *Client) Subscribe(topic string, qos byte, handler MessageHandler) error {
-	if token := c.client.Subscribe(topic, qos, func(client mqtt.Client, msg mqtt.Message) {
-		if err := handler(msg.Topic(), msg.Payload()); err != nil {
-			// 记录错误，但不中断处理
-			fmt.Printf("Error handling MQTT message: %v\n", err)
+// clientID 返回稳定的 client id（持久会话依赖 client id 不变）
+// 使用共享订阅时追加主机名，避免多个副本使用相同 client id 互相踢下线
+func clientID(cfg *config.MQTTConfig) string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "owl"
+	}
+	if cfg.ClientID == "" {
+		return hostname + "-mqtt"
+	}
+	if cfg.SharedGroup != "" {
+		return cfg.ClientID + "-" + hostname
+	}
+	return cfg.ClientID
+}
+
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// NewTLSConfig 根据配置构建 TLS 配置（CA 校验 + 可选客户端证书）
+func NewTLSConfig(cfg *config.MQTTTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MQTT CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("failed to parse MQTT CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("both MQTT TLS cert file and key file are required for mTLS")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load MQTT client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// onConnect 连接成功（含重连）：重新订阅注册表中的所有主题
+// 持久会话下 broker 可能已保留订阅，但重新订阅是幂等的，统一恢复
+func (c *Client) onConnect(_ mqtt.Client) {
+	c.mu.RLock()
+	subs := make(map[string]subscription, len(c.subscriptions))
+	for topic, sub := range c.subscriptions {
+		subs[topic] = sub
+	}
+	c.mu.RUnlock()
+
+	c.logger.Info("MQTT connected",
+		zap.String("broker", c.config.Broker),
+		zap.String("client_id", clientID(c.config)),
+		zap.Bool("clean_session", c.config.CleanSession),
+		zap.Int("subscriptions", len(subs)),
+	)
+
+	for topic, sub := range subs {
+		if err := c.subscribe(topic, sub); err != nil {
+			c.logger.Error("Failed to restore MQTT subscription",
+				zap.String("topic", topic),
+				zap.Error(err),
+			)
 		}
-	}); token.Wait() && token.Error() != nil {
+	}
+
+	if c.hooks.OnConnect != nil {
+		c.hooks.OnConnect()
+	}
+}
+
+// onConnectionLost 连接断开
+func (c *Client) onConnectionLost(_ mqtt.Client, err error) {
+	c.logger.Warn("MQTT connection lost, will reconnect",
+		zap.String("broker", c.config.Broker),
+		zap.Error(err),
+	)
+	if c.hooks.OnConnectionLost != nil {
+		c.hooks.OnConnectionLost(err)
+	}
+}
+
+// onReconnecting 开始重连
+func (c *Client) onReconnecting(_ mqtt.Client, _ *mqtt.ClientOptions) {
+	c.logger.Info("MQTT reconnecting", zap.String("broker", c.config.Broker))
+	if c.hooks.OnReconnecting != nil {
+		c.hooks.OnReconnecting()
+	}
+}
+
+// handleUnrouted 处理没有匹配路由的消息
+// 已注册订阅匹配时直接分发，否则缓存，等订阅注册后重放
+func (c *Client) handleUnrouted(_ mqtt.Client, msg mqtt.Message) {
+	c.mu.Lock()
+	for filter, sub := range c.subscriptions {
+		if TopicMatches(filter, msg.Topic()) {
+			c.mu.Unlock()
+			c.dispatch(sub.handler, msg)
+			return
+		}
+	}
+	if len(c.unrouted) >= maxUnroutedMessages {
+		c.mu.Unlock()
+		c.logger.Warn("Dropping MQTT message received before subscription",
+			zap.String("topic", msg.Topic()),
+		)
+		msg.Ack()
+		return
+	}
+	c.unrouted = append(c.unrouted, msg)
+	c.mu.Unlock()
+}
+
+// dispatch 调用业务处理函数，错误只记录不中断（出错的消息直接确认）
+func (c *Client) dispatch(handler AckHandler, msg mqtt.Message) {
+	if err := handler(msg.Topic(), msg.Payload(), msg.Ack); err != nil {
+		c.logger.Error("Error handling MQTT message",
+			zap.String("topic", msg.Topic()),
+			zap.Error(err),
+		)
+		msg.Ack()
+	}
+}
+
+// subscribe 向 broker 订阅
+func (c *Client) subscribe(topic string, sub subscription) error {
+	token := c.client.Subscribe(topic, sub.qos, func(_ mqtt.Client, msg mqtt.Message) {
+		c.dispatch(sub.handler, msg)
+	})
+	if token.Wait() && token.Error() != nil {
 		return fmt.Errorf("failed to subscribe to topic %s: %w", topic, token.Error())
 	}
-	
 	return nil
 }
 
+// Subscribe 订阅主题（加入注册表，重连后自动恢复），处理函数返回后确认消息
+// 未连接时只注册，连接成功后订阅
+func (c *Client) Subscribe(topic string, qos byte, handler MessageHandler) error {
+	return c.SubscribeWithAck(topic, qos, func(topic string, payload []byte, ack func()) error {
+		defer ack()
+		return handler(topic, payload)
+	})
+}
+
+// SubscribeWithAck 订阅主题，由处理函数在处理完成后调用 ack 确认消息
+func (c *Client) SubscribeWithAck(topic string, qos byte, handler AckHandler) error {
+	sub := subscription{qos: qos, handler: handler}
+
+	c.mu.Lock()
+	c.subscriptions[topic] = sub
+	var replay []mqtt.Message
+	remaining := c.unrouted[:0]
+	for _, msg := range c.unrouted {
+		if TopicMatches(topic, msg.Topic()) {
+			replay = append(replay, msg)
+		} else {
+			remaining = append(remaining, msg)
+		}
+	}
+	c.unrouted = remaining
+	c.mu.Unlock()
+
+	for _, msg := range replay {
+		c.dispatch(handler, msg)
+	}
+
+	if c.client == nil || !c.client.IsConnectionOpen() {
+		c.logger.Info("MQTT not connected, subscription will be made on connect", zap.String("topic", topic))
+		return nil
+	}
+	return c.subscribe(topic, sub)
+}
+
 // Publish 发布消息
 func (c *Client) Publish(topic string, qos byte, retained bool, payload []byte) error {
 	token := c.client.Publish(topic, qos, retained, payload)
 	token.Wait()
-	
+
 	if token.Error() != nil {
 		return fmt.Errorf("failed to publish to topic %s: %w", topic, token.Error())
 	}
-	
+
 	return nil
 }
 
-// Unsubscribe 取消订阅
+// Unsubscribe 取消订阅（同时从注册表移除）
 func (c *Client) Unsubscribe(topics ...string) error {
+	c.mu.Lock()
+	for _, topic := range topics {
+		delete(c.subscriptions, topic)
+	}
+	c.mu.Unlock()
+
 	token := c.client.Unsubscribe(topics...)
 	token.Wait()
-	
+
 	if token.Error() != nil {
 		return fmt.Errorf("failed to unsubscribe: %w", token.Error())
 	}
-	
+
 	return nil
 }
 
 // Disconnect 断开连接
 func (c *Client) Disconnect() {
 	c.client.Disconnect(250) // 250ms等待时间
+	c.logger.Info("MQTT disconnected", zap.String("broker", c.config.Broker))
 }
 
 // IsConnected 检查连接状态
@@ -92,3 +354,37 @@ func (c *Client) IsConnected() bool {
 	return c.client.IsConnected()
 }
 
+// SharedTopic 构造共享订阅主题：$share/<group>/<topic>
+// group 为空时返回原主题（普通订阅）
+func SharedTopic(group, topic string) string {
+	if group == "" {
+		return topic
+	}
+	return "$share/" + group + "/" + topic
+}
+
+// TopicMatches 判断主题是否匹配订阅过滤器（支持 + 和 # 通配符，以及 $share/<group>/ 共享订阅前缀）
+func TopicMatches(filter, topic string) bool {
+	if strings.HasPrefix(filter, "$share/") {
+		parts := strings.SplitN(filter, "/", 3)
+		if len(parts) < 3 {
+			return false
+		}
+		filter = parts[2]
+	}
+	filterParts := strings.Split(filter, "/")
+	topicParts := strings.Split(topic, "/")
+
+	for i, part := range filterParts {
+		if part == "#" {
+			return true
+		}
+		if i >= len(topicParts) {
+			return false
+		}
+		if part != "+" && part != topicParts[i] {
+			return false
+		}
+	}
+	return len(filterParts) == len(topicParts)
+}
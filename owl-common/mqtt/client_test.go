@@ -0,0 +1,224 @@
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"owl-common/config"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fakeMessage struct {
+	topic   string
+	payload []byte
+	acks    int
+}
+
+func (m *fakeMessage) Duplicate() bool   { return false }
+func (m *fakeMessage) Qos() byte         { return 1 }
+func (m *fakeMessage) Retained() bool    { return false }
+func (m *fakeMessage) Topic() string     { return m.topic }
+func (m *fakeMessage) MessageID() uint16 { return 0 }
+func (m *fakeMessage) Payload() []byte   { return m.payload }
+func (m *fakeMessage) Ack()              { m.acks++ }
+
+func TestTopicMatches(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"radar/+/data", "radar/dev-1/data", true},
+		{"radar/+/data", "radar/dev-1/status", false},
+		{"radar/#", "radar/dev-1/data", true},
+		{"radar/+/data", "radar/dev-1/data/extra", false},
+		{"sleepace-57136", "sleepace-57136", true},
+		{"sleepace-57136", "sleepace-57137", false},
+		{"$share/radar-ingest/radar/+/data", "radar/dev-1/data", true},
+		{"$share/radar-ingest/radar/+/data", "radar/dev-1/status", false},
+	}
+	for _, tc := range cases {
+		if got := TopicMatches(tc.filter, tc.topic); got != tc.want {
+			t.Errorf("TopicMatches(%q, %q) = %v, want %v", tc.filter, tc.topic, got, tc.want)
+		}
+	}
+}
+
+func TestSharedTopic(t *testing.T) {
+	if got := SharedTopic("", "radar/+/data"); got != "radar/+/data" {
+		t.Fatalf("expected plain topic, got %q", got)
+	}
+	if got := SharedTopic("radar-ingest", "radar/+/data"); got != "$share/radar-ingest/radar/+/data" {
+		t.Fatalf("unexpected shared topic %q", got)
+	}
+}
+
+func TestClient_ReplaysMessagesReceivedBeforeSubscribe(t *testing.T) {
+	c := &Client{
+		config:        &config.MQTTConfig{},
+		logger:        zap.NewNop(),
+		subscriptions: make(map[string]subscription),
+	}
+
+	// 持久会话重连后，订阅注册前收到的离线消息
+	c.handleUnrouted(nil, &fakeMessage{topic: "radar/dev-1/data", payload: []byte("a")})
+	c.handleUnrouted(nil, &fakeMessage{topic: "other/topic", payload: []byte("b")})
+
+	var got []string
+	if err := c.Subscribe("radar/+/data", 1, func(topic string, payload []byte) error {
+		got = append(got, string(payload))
+		return nil
+	}); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	if len(got) != 1 || got[0] != "a" {
+		t.Fatalf("expected buffered message to be replayed, got %v", got)
+	}
+	if len(c.unrouted) != 1 {
+		t.Fatalf("expected unmatched message to stay buffered, got %d", len(c.unrouted))
+	}
+
+	// 注册后经默认处理器到达的消息直接分发
+	c.handleUnrouted(nil, &fakeMessage{topic: "radar/dev-2/data", payload: []byte("c")})
+	if len(got) != 2 || got[1] != "c" {
+		t.Fatalf("expected message to be dispatched via registry, got %v", got)
+	}
+}
+
+// 同步处理函数返回后确认；异步处理函数调用 ack 时确认；处理出错时直接确认
+func TestClient_AcksAfterProcessing(t *testing.T) {
+	c := &Client{
+		config:        &config.MQTTConfig{},
+		logger:        zap.NewNop(),
+		subscriptions: make(map[string]subscription),
+	}
+
+	var handled int
+	if err := c.Subscribe("sync/+", 1, func(topic string, payload []byte) error {
+		handled++
+		return nil
+	}); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	msg := &fakeMessage{topic: "sync/a"}
+	c.handleUnrouted(nil, msg)
+	if handled != 1 || msg.acks != 1 {
+		t.Fatalf("sync handler: handled = %d, acks = %d", handled, msg.acks)
+	}
+
+	var pending []func()
+	if err := c.SubscribeWithAck("async/+", 1, func(topic string, payload []byte, ack func()) error {
+		if string(payload) == "bad" {
+			return errors.New("invalid payload")
+		}
+		pending = append(pending, ack)
+		return nil
+	}); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	msg = &fakeMessage{topic: "async/a"}
+	c.handleUnrouted(nil, msg)
+	if len(pending) != 1 || msg.acks != 0 {
+		t.Fatalf("async handler: pending = %d, acks = %d, want ack deferred", len(pending), msg.acks)
+	}
+	pending[0]()
+	if msg.acks != 1 {
+		t.Fatalf("async handler: acks = %d after ack", msg.acks)
+	}
+
+	msg = &fakeMessage{topic: "async/b", payload: []byte("bad")}
+	c.handleUnrouted(nil, msg)
+	if msg.acks != 1 {
+		t.Fatalf("failed message should be acked, acks = %d", msg.acks)
+	}
+}
+
+// 订阅注册前的缓存已满时丢弃并确认，避免占用 broker 的 in-flight 窗口
+func TestClient_AcksDroppedUnroutedMessages(t *testing.T) {
+	c := &Client{
+		config:        &config.MQTTConfig{},
+		logger:        zap.NewNop(),
+		subscriptions: make(map[string]subscription),
+	}
+	for i := 0; i < maxUnroutedMessages; i++ {
+		c.handleUnrouted(nil, &fakeMessage{topic: "radar/dev-1/data"})
+	}
+	msg := &fakeMessage{topic: "radar/dev-1/data"}
+	c.handleUnrouted(nil, msg)
+	if msg.acks != 1 || len(c.unrouted) != maxUnroutedMessages {
+		t.Fatalf("acks = %d, buffered = %d", msg.acks, len(c.unrouted))
+	}
+	if c.unrouted[0].(*fakeMessage).acks != 0 {
+		t.Fatalf("buffered messages must not be acked before dispatch")
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+
+	tlsConfig, err := NewTLSConfig(&config.MQTTTLSConfig{
+		Enabled:    true,
+		CAFile:     certFile,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ServerName: "broker.local",
+	})
+	if err != nil {
+		t.Fatalf("NewTLSConfig failed: %v", err)
+	}
+	if tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 || tlsConfig.ServerName != "broker.local" {
+		t.Fatalf("unexpected tls config: %+v", tlsConfig)
+	}
+
+	if _, err := NewTLSConfig(&config.MQTTTLSConfig{CertFile: certFile}); err == nil {
+		t.Fatalf("expected error when key file is missing")
+	}
+	if _, err := NewTLSConfig(&config.MQTTTLSConfig{CAFile: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Fatalf("expected error when CA file is missing")
+	}
+}
+
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "broker.local"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate failed: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key failed: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert failed: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key failed: %v", err)
+	}
+	return certFile, keyFile
+}
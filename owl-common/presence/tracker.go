@@ -0,0 +1,317 @@
+// Package presence 设备在线状态跟踪
+//
+// 采集服务每收到一条设备消息调用 Tracker.Seen，最近消息时间记录在 Redis 有序集合
+// （device:presence:lastseen:{group}，score 为 Unix 秒）中，多个副本共享。
+// Tracker.Run 定期扫描静默超过 Timeout 的设备，将 devices.status 置为 offline，
+// 设备重新上报时置回 online；状态实际切换时向 device:presence:stream 发布 DevicePresence 事件，
+// 并将当前状态写入 device:presence:status:{device_id} 供卡片聚合服务读取。
+// 设备自报的上线/下线（状态主题、MQTT 遗嘱、厂家平台通知）通过 Tracker.Report 立即生效。
+// 在线状态未变化时，设备运行信息（固件版本、信号强度等）通过 Tracker.PublishInfo 发布（reason = device_info）。
+package presence
+
+import (
+	"context"
+	"fmt"
+	"owl-common/config"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// 设备在线状态（与 devices.status 取值一致）
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+// 原因代码
+const (
+	ReasonHeartbeat = "heartbeat"       // 收到设备消息
+	ReasonTimeout   = "silence_timeout" // 静默超过窗口
+	ReasonReported  = "vendor_reported" // 厂家平台上报连接状态
+	ReasonStatus    = "device_status"   // 设备状态主题上报
+	ReasonLastWill  = "last_will"       // MQTT 遗嘱消息（设备异常断开）
+	ReasonInfo      = "device_info"     // 设备运行信息更新（在线状态未变化）
+)
+
+const (
+	lastSeenKeyPrefix = "device:presence:lastseen:"
+	statusKeyPrefix   = "device:presence:status:"
+)
+
+// StatusKey 设备当前在线状态缓存键（值为 "online" / "offline"）
+func StatusKey(deviceID string) string {
+	return statusKeyPrefix + deviceID
+}
+
+// Device 状态切换涉及的设备信息
+type Device struct {
+	DeviceID     string
+	TenantID     string
+	DeviceType   string
+	SerialNumber string
+	UID          string
+}
+
+// Info 设备自报的运行信息（随在线状态事件发布）
+type Info struct {
+	FirmwareVersion string
+	RSSI            *int
+	UptimeSec       *int64
+}
+
+// StatusStore devices.status 持久化
+type StatusStore interface {
+	// SetStatus 将设备状态从 from 切换为 to（条件更新）
+	// changed = false 表示设备当前不处于 from 状态（已被其他副本切换，或处于 disabled/error 等状态）
+	SetStatus(ctx context.Context, deviceID, from, to string) (device *Device, changed bool, err error)
+	// OnlineDevices 返回当前 status = 'online' 的设备 ID（启动时纳入静默检测）
+	OnlineDevices(ctx context.Context) ([]string, error)
+}
+
+// claimScript 仅当设备最近消息时间不晚于 cutoff 时移除（避免与新消息竞争）
+var claimScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) <= tonumber(ARGV[2]) then
+	return redis.call('ZREM', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// Tracker 设备在线状态跟踪器
+type Tracker struct {
+	client   *redis.Client
+	store    StatusStore
+	cfg      config.PresenceConfig
+	key      string
+	producer string
+	logger   *zap.Logger
+	now      func() time.Time
+}
+
+// NewTracker 创建设备在线状态跟踪器
+// group 区分不同设备类型的静默窗口（如 "radar"、"sleepace"），producer 为事件信封的生产者
+func NewTracker(client *redis.Client, store StatusStore, cfg config.PresenceConfig, group, producer string, logger *zap.Logger) *Tracker {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Tracker{
+		client:   client,
+		store:    store,
+		cfg:      cfg,
+		key:      lastSeenKeyPrefix + group,
+		producer: producer,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Seen 记录设备消息
+// 设备此前不在跟踪集合中（首次上报或已被判定离线）时切换为 online
+func (t *Tracker) Seen(ctx context.Context, deviceID string) error {
+	if !t.cfg.Enabled || deviceID == "" {
+		return nil
+	}
+	now := t.now()
+	added, err := t.client.ZAdd(ctx, t.key, &redis.Z{Score: float64(now.Unix()), Member: deviceID}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to record last seen: %w", err)
+	}
+	if added == 0 {
+		return nil
+	}
+	return t.transition(ctx, deviceID, StatusOffline, StatusOnline, ReasonHeartbeat, now, nil)
+}
+
+// Report 处理设备或厂家平台上报的连接状态（离线无需等待静默窗口）
+// info 为设备自报的运行信息，可为 nil
+func (t *Tracker) Report(ctx context.Context, deviceID string, online bool, reason string, info *Info) error {
+	if !t.cfg.Enabled || deviceID == "" {
+		return nil
+	}
+	now := t.now()
+	if online {
+		added, err := t.client.ZAdd(ctx, t.key, &redis.Z{Score: float64(now.Unix()), Member: deviceID}).Result()
+		if err != nil {
+			return fmt.Errorf("failed to record last seen: %w", err)
+		}
+		if added == 0 {
+			return nil
+		}
+		return t.transition(ctx, deviceID, StatusOffline, StatusOnline, reason, now, info)
+	}
+	lastSeen := t.lastSeen(ctx, deviceID)
+	if err := t.client.ZRem(ctx, t.key, deviceID).Err(); err != nil {
+		return fmt.Errorf("failed to remove last seen: %w", err)
+	}
+	return t.transition(ctx, deviceID, StatusOnline, StatusOffline, reason, lastSeen, info)
+}
+
+// PublishInfo 发布在线设备的运行信息（在线状态未变化，固件版本变化或定期由采集服务调用）
+// 事件 status 为 online、reason 为 ReasonInfo，消费者据此区分状态切换
+func (t *Tracker) PublishInfo(ctx context.Context, device Device, info *Info) error {
+	if !t.cfg.Enabled || device.DeviceID == "" || info == nil {
+		return nil
+	}
+	lastSeen := t.lastSeen(ctx, device.DeviceID)
+	if lastSeen.IsZero() {
+		lastSeen = t.now()
+	}
+	return t.publish(ctx, &device, StatusOnline, ReasonInfo, lastSeen, info)
+}
+
+// Run 定期扫描静默设备（阻塞直到 ctx 结束）
+func (t *Tracker) Run(ctx context.Context) {
+	if !t.cfg.Enabled {
+		return
+	}
+	if err := t.seed(ctx); err != nil {
+		t.logger.Warn("Failed to seed presence tracking", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(t.cfg.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := t.Sweep(ctx); err != nil {
+				t.logger.Warn("Presence sweep failed", zap.Error(err))
+			} else if n > 0 {
+				t.logger.Info("Devices marked offline", zap.Int("count", n))
+			}
+		}
+	}
+}
+
+// Sweep 将静默超过 Timeout 的设备切换为 offline，返回切换的设备数
+// 多副本同时扫描时，只有成功移除跟踪记录的副本执行状态切换
+func (t *Tracker) Sweep(ctx context.Context) (int, error) {
+	cutoff := t.now().Add(-t.cfg.Timeout).Unix()
+	members, err := t.client.ZRangeByScoreWithScores(ctx, t.key, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(cutoff, 10),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan last seen: %w", err)
+	}
+
+	offline := 0
+	for _, m := range members {
+		deviceID, _ := m.Member.(string)
+		claimed, err := claimScript.Run(ctx, t.client, []string{t.key}, deviceID, cutoff).Int()
+		if err != nil {
+			return offline, fmt.Errorf("failed to claim silent device: %w", err)
+		}
+		if claimed == 0 {
+			continue
+		}
+		lastSeen := time.Unix(int64(m.Score), 0)
+		if err := t.transition(ctx, deviceID, StatusOnline, StatusOffline, ReasonTimeout, lastSeen, nil); err != nil {
+			t.logger.Warn("Failed to mark device offline",
+				zap.String("device_id", deviceID),
+				zap.Error(err),
+			)
+			continue
+		}
+		offline++
+
+		// 移除后到达的消息可能先于离线更新执行（online 条件更新未生效），此处补偿
+		if score := t.lastSeen(ctx, deviceID); !score.IsZero() {
+			if err := t.transition(ctx, deviceID, StatusOffline, StatusOnline, ReasonHeartbeat, score, nil); err != nil {
+				t.logger.Warn("Failed to restore device online",
+					zap.String("device_id", deviceID),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+	return offline, nil
+}
+
+// seed 将数据库中 online 的设备纳入静默检测（跟踪集合丢失或服务首次启动时）
+func (t *Tracker) seed(ctx context.Context) error {
+	deviceIDs, err := t.store.OnlineDevices(ctx)
+	if err != nil {
+		return err
+	}
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+	score := float64(t.now().Unix())
+	members := make([]*redis.Z, 0, len(deviceIDs))
+	for _, id := range deviceIDs {
+		members = append(members, &redis.Z{Score: score, Member: id})
+	}
+	return t.client.ZAddNX(ctx, t.key, members...).Err()
+}
+
+// lastSeen 返回设备最近消息时间，不在跟踪集合中时返回零值
+func (t *Tracker) lastSeen(ctx context.Context, deviceID string) time.Time {
+	score, err := t.client.ZScore(ctx, t.key, deviceID).Result()
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(score), 0)
+}
+
+// transition 切换设备状态，状态实际变化时发布事件
+func (t *Tracker) transition(ctx context.Context, deviceID, from, to, reason string, lastSeen time.Time, info *Info) error {
+	device, changed, err := t.store.SetStatus(ctx, deviceID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to update device status: %w", err)
+	}
+	// 状态缓存始终刷新（Redis 数据丢失时由下一次切换补齐）
+	if err := t.client.Set(ctx, StatusKey(deviceID), to, 0).Err(); err != nil {
+		t.logger.Warn("Failed to cache device status",
+			zap.String("device_id", deviceID),
+			zap.Error(err),
+		)
+	}
+	if !changed || device == nil {
+		return nil
+	}
+	if err := t.publish(ctx, device, to, reason, lastSeen, info); err != nil {
+		return err
+	}
+
+	t.logger.Info("Device presence changed",
+		zap.String("device_id", deviceID),
+		zap.String("status", to),
+		zap.String("reason", reason),
+	)
+	return nil
+}
+
+// publish 向 device:presence:stream 发布 DevicePresence 事件
+func (t *Tracker) publish(ctx context.Context, device *Device, status, reason string, lastSeen time.Time, info *Info) error {
+	payload := events.DevicePresence{
+		DeviceID:     device.DeviceID,
+		SerialNumber: device.SerialNumber,
+		UID:          device.UID,
+		DeviceType:   device.DeviceType,
+		Status:       status,
+		Reason:       reason,
+		LastSeen:     lastSeen,
+	}
+	if info != nil {
+		payload.FirmwareVersion = info.FirmwareVersion
+		payload.RSSI = info.RSSI
+		payload.UptimeSec = info.UptimeSec
+	}
+	env := rediscommon.NewEnvelope(events.PresenceSchema, t.producer, device.TenantID, t.now(), payload)
+	values, err := rediscommon.Encode(env)
+	if err != nil {
+		return err
+	}
+	retention := config.StreamConfig{MaxLen: t.cfg.StreamMaxLen}
+	if _, err := rediscommon.PublishToStreamWithRetention(ctx, t.client, t.cfg.Stream, values, retention); err != nil {
+		return fmt.Errorf("failed to publish presence event: %w", err)
+	}
+	return nil
+}
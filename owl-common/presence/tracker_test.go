@@ -0,0 +1,200 @@
+package presence
+
+import (
+	"context"
+	"owl-common/config"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+type fakeStore struct {
+	status map[string]string
+}
+
+func (f *fakeStore) SetStatus(_ context.Context, deviceID, from, to string) (*Device, bool, error) {
+	if f.status[deviceID] != from {
+		return nil, false, nil
+	}
+	f.status[deviceID] = to
+	return &Device{DeviceID: deviceID, TenantID: "tenant-1", DeviceType: "Radar"}, true, nil
+}
+
+func (f *fakeStore) OnlineDevices(_ context.Context) ([]string, error) {
+	var ids []string
+	for id, status := range f.status {
+		if status == StatusOnline {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func newTestTracker(t *testing.T, store StatusStore) (*Tracker, *redis.Client, *time.Time) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	cfg := config.DefaultPresenceConfig()
+	cfg.Timeout = time.Minute
+	tracker := NewTracker(client, store, cfg, "radar", "wisefido-radar", nil)
+	now := time.Unix(1700000000, 0)
+	tracker.now = func() time.Time { return now }
+	return tracker, client, &now
+}
+
+func presenceEvents(t *testing.T, client *redis.Client) []events.DevicePresence {
+	msgs, err := client.XRange(context.Background(), "device:presence:stream", "-", "+").Result()
+	if err != nil {
+		t.Fatalf("xrange failed: %v", err)
+	}
+	out := make([]events.DevicePresence, 0, len(msgs))
+	for _, m := range msgs {
+		env, err := rediscommon.Decode[events.DevicePresence](rediscommon.StreamMessage{ID: m.ID, Values: m.Values}, events.PresenceSchema)
+		if err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		out = append(out, env.Payload)
+	}
+	return out
+}
+
+func TestTracker_OnlineOfflineCycle(t *testing.T) {
+	store := &fakeStore{status: map[string]string{"device-1": StatusOffline}}
+	tracker, client, now := newTestTracker(t, store)
+	ctx := context.Background()
+
+	if err := tracker.Seen(ctx, "device-1"); err != nil {
+		t.Fatalf("seen failed: %v", err)
+	}
+	// 重复上报不产生新事件
+	*now = now.Add(30 * time.Second)
+	if err := tracker.Seen(ctx, "device-1"); err != nil {
+		t.Fatalf("seen failed: %v", err)
+	}
+	if store.status["device-1"] != StatusOnline {
+		t.Fatalf("expected device online, got %s", store.status["device-1"])
+	}
+
+	// 静默未超过窗口
+	*now = now.Add(45 * time.Second)
+	if n, err := tracker.Sweep(ctx); err != nil || n != 0 {
+		t.Fatalf("expected no offline devices, got %d, %v", n, err)
+	}
+
+	*now = now.Add(30 * time.Second)
+	if n, err := tracker.Sweep(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 offline device, got %d, %v", n, err)
+	}
+	if store.status["device-1"] != StatusOffline {
+		t.Fatalf("expected device offline, got %s", store.status["device-1"])
+	}
+	if status, _ := client.Get(ctx, StatusKey("device-1")).Result(); status != StatusOffline {
+		t.Fatalf("expected cached status offline, got %q", status)
+	}
+
+	// 重新上报：置回 online
+	if err := tracker.Seen(ctx, "device-1"); err != nil {
+		t.Fatalf("seen failed: %v", err)
+	}
+
+	got := presenceEvents(t, client)
+	want := []struct{ status, reason string }{
+		{StatusOnline, ReasonHeartbeat},
+		{StatusOffline, ReasonTimeout},
+		{StatusOnline, ReasonHeartbeat},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Status != want[i].status || got[i].Reason != want[i].reason || got[i].DeviceID != "device-1" {
+			t.Errorf("event %d: unexpected %+v", i, got[i])
+		}
+	}
+	if got[1].LastSeen.Unix() != 1700000030 {
+		t.Errorf("expected offline event to carry last seen time, got %v", got[1].LastSeen)
+	}
+}
+
+func TestTracker_SeedAndReport(t *testing.T) {
+	store := &fakeStore{status: map[string]string{"device-1": StatusOnline, "device-2": StatusOnline}}
+	tracker, client, now := newTestTracker(t, store)
+	ctx := context.Background()
+
+	if err := tracker.seed(ctx); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+	if err := tracker.Report(ctx, "device-2", false, ReasonLastWill, nil); err != nil {
+		t.Fatalf("report failed: %v", err)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if n, err := tracker.Sweep(ctx); err != nil || n != 1 {
+		t.Fatalf("expected seeded device to time out, got %d, %v", n, err)
+	}
+
+	got := presenceEvents(t, client)
+	if len(got) != 2 || got[0].DeviceID != "device-2" || got[0].Reason != ReasonLastWill || got[1].DeviceID != "device-1" {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+}
+
+func TestTracker_ReportOnlineWithInfo(t *testing.T) {
+	store := &fakeStore{status: map[string]string{"device-1": StatusOffline}}
+	tracker, client, _ := newTestTracker(t, store)
+	ctx := context.Background()
+
+	rssi := -61
+	info := &Info{FirmwareVersion: "2.1.0", RSSI: &rssi}
+	if err := tracker.Report(ctx, "device-1", true, ReasonStatus, info); err != nil {
+		t.Fatalf("report failed: %v", err)
+	}
+	// 已在线：不重复发布
+	if err := tracker.Report(ctx, "device-1", true, ReasonStatus, info); err != nil {
+		t.Fatalf("report failed: %v", err)
+	}
+
+	got := presenceEvents(t, client)
+	if len(got) != 1 || got[0].Status != StatusOnline || got[0].Reason != ReasonStatus ||
+		got[0].FirmwareVersion != "2.1.0" || got[0].RSSI == nil || *got[0].RSSI != -61 {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+}
+
+// 在线状态未变化时发布运行信息，不修改设备状态
+func TestTracker_PublishInfo(t *testing.T) {
+	store := &fakeStore{status: map[string]string{"device-1": StatusOffline}}
+	tracker, client, _ := newTestTracker(t, store)
+	ctx := context.Background()
+
+	if err := tracker.Seen(ctx, "device-1"); err != nil {
+		t.Fatalf("seen failed: %v", err)
+	}
+	uptime := int64(3600)
+	device := Device{DeviceID: "device-1", TenantID: "tenant-1", DeviceType: "Radar", SerialNumber: "SN1"}
+	if err := tracker.PublishInfo(ctx, device, &Info{FirmwareVersion: "2.2.0", UptimeSec: &uptime}); err != nil {
+		t.Fatalf("publish info failed: %v", err)
+	}
+	// 没有运行信息时不发布
+	if err := tracker.PublishInfo(ctx, device, nil); err != nil {
+		t.Fatalf("publish info failed: %v", err)
+	}
+
+	got := presenceEvents(t, client)
+	if len(got) != 2 || got[0].Reason != ReasonHeartbeat {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+	info := got[1]
+	if info.Status != StatusOnline || info.Reason != ReasonInfo || info.SerialNumber != "SN1" ||
+		info.FirmwareVersion != "2.2.0" || info.UptimeSec == nil || *info.UptimeSec != 3600 || info.LastSeen.Unix() != 1700000000 {
+		t.Fatalf("unexpected info event: %+v", info)
+	}
+	if store.status["device-1"] != StatusOnline {
+		t.Fatalf("status changed: %s", store.status["device-1"])
+	}
+}
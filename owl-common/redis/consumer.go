@@ -0,0 +1,363 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// DeadLetterSuffix 死信流后缀（<stream>:dlq）
+const DeadLetterSuffix = ":dlq"
+
+// StreamHandler 流消息处理函数
+// 返回 nil 表示处理成功（消息会被 XACK）；返回错误时消息保留在 PEL 中，等待超时后重新认领
+type StreamHandler func(ctx context.Context, msg StreamMessage) error
+
+// ErrDeferred 处理函数返回 ErrDeferred 表示消息已被接管（如进入批量写入缓冲），暂不确认；
+// 接管方处理完成后调用 Ack 或 Fail，未调用的消息留在 PEL 中，空闲超时后重新认领
+var ErrDeferred = errors.New("message acknowledgement deferred")
+
+// PermanentError 永久性错误：重试无意义（如消息格式错误），直接进入死信流
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent 将错误标记为永久性错误
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// ConsumerOptions 可靠消费者配置
+type ConsumerOptions struct {
+	Streams       []string      // 消费的流列表
+	Group         string        // 消费者组名称
+	Consumer      string        // 消费者名称
+	BatchSize     int64         // 每次读取的消息数，默认 10
+	Block         time.Duration // XREADGROUP 阻塞时间，默认 5 秒
+	ClaimMinIdle  time.Duration // 待处理消息空闲多久后被重新认领，默认 60 秒
+	ClaimInterval time.Duration // 重新认领检查间隔，默认 30 秒
+	MaxDeliveries int64         // 最大投递次数，超过后移入死信流，默认 5
+}
+
+// withDefaults 填充默认值
+func (o ConsumerOptions) withDefaults() ConsumerOptions {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 10
+	}
+	if o.Block <= 0 {
+		o.Block = 5 * time.Second
+	}
+	if o.ClaimMinIdle <= 0 {
+		o.ClaimMinIdle = 60 * time.Second
+	}
+	if o.ClaimInterval <= 0 {
+		o.ClaimInterval = 30 * time.Second
+	}
+	if o.MaxDeliveries <= 0 {
+		o.MaxDeliveries = 5
+	}
+	return o
+}
+
+// ReliableConsumer 基于消费者组的可靠消费者
+//
+// 处理语义：
+// - 处理成功后 XACK；处理函数返回 ErrDeferred 时由调用方稍后 Ack / Fail（批量写入）
+// - 处理失败的消息留在 PEL 中，空闲超过 ClaimMinIdle 后通过 XCLAIM 重新认领
+// - 投递次数超过 MaxDeliveries（或返回 PermanentError）的消息写入 <stream>:dlq 并 XACK
+type ReliableConsumer struct {
+	client *redis.Client
+	opts   ConsumerOptions
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	lastClaim  time.Time
+	lastErrors map[string]string // message_id -> 最近一次处理错误（用于写入死信流）
+}
+
+// NewReliableConsumer 创建可靠消费者
+func NewReliableConsumer(client *redis.Client, opts ConsumerOptions, logger *zap.Logger) *ReliableConsumer {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &ReliableConsumer{
+		client:     client,
+		opts:       opts.withDefaults(),
+		logger:     logger,
+		lastErrors: make(map[string]string),
+	}
+}
+
+// Setup 为所有流创建消费者组
+func (c *ReliableConsumer) Setup(ctx context.Context) error {
+	for _, stream := range c.opts.Streams {
+		if err := CreateConsumerGroup(ctx, c.client, stream, c.opts.Group); err != nil {
+			return fmt.Errorf("failed to create consumer group for %s: %w", stream, err)
+		}
+	}
+	return nil
+}
+
+// Poll 执行一轮消费：到期时先重新认领超时的待处理消息，再读取新消息
+// 返回的错误仅表示读取失败（用于调用方退避），单条消息的处理错误不会返回
+func (c *ReliableConsumer) Poll(ctx context.Context, handler StreamHandler) error {
+	if c.claimDue() {
+		for _, stream := range c.opts.Streams {
+			if err := c.reclaim(ctx, stream, handler); err != nil {
+				c.logger.Warn("Failed to reclaim pending messages",
+					zap.String("stream", stream),
+					zap.String("consumer_group", c.opts.Group),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+
+	messages, err := c.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		c.dispatch(ctx, msg, 1, handler)
+	}
+	return nil
+}
+
+// claimDue 判断是否到达重新认领时间
+func (c *ReliableConsumer) claimDue() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.lastClaim) < c.opts.ClaimInterval {
+		return false
+	}
+	c.lastClaim = time.Now()
+	return true
+}
+
+// read 从所有流读取新消息（一次 XREADGROUP）
+func (c *ReliableConsumer) read(ctx context.Context) ([]StreamMessage, error) {
+	args := make([]string, 0, len(c.opts.Streams)*2)
+	args = append(args, c.opts.Streams...)
+	for range c.opts.Streams {
+		args = append(args, ">")
+	}
+
+	streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.opts.Group,
+		Consumer: c.opts.Consumer,
+		Streams:  args,
+		Count:    c.opts.BatchSize,
+		Block:    c.opts.Block,
+	}).Result()
+	if err != nil {
+		if err == redis.Nil || errors.Is(err, context.Canceled) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read from streams %v: %w", c.opts.Streams, err)
+	}
+
+	var messages []StreamMessage
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			messages = append(messages, StreamMessage{
+				Stream: stream.Stream,
+				ID:     msg.ID,
+				Values: msg.Values,
+			})
+		}
+	}
+	return messages, nil
+}
+
+// reclaim 认领空闲超时的待处理消息并重新处理
+// 使用 XPENDING IDLE + XCLAIM（go-redis v8 无法解析 Redis 7 的 XAUTOCLAIM 三元素返回值）
+func (c *ReliableConsumer) reclaim(ctx context.Context, stream string, handler StreamHandler) error {
+	for {
+		pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  c.opts.Group,
+			Idle:   c.opts.ClaimMinIdle,
+			Start:  "-",
+			End:    "+",
+			Count:  c.opts.BatchSize,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("xpending failed: %w", err)
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		ids := make([]string, 0, len(pending))
+		deliveries := make(map[string]int64, len(pending))
+		for _, p := range pending {
+			ids = append(ids, p.ID)
+			// XCLAIM 会将投递次数加 1
+			deliveries[p.ID] = p.RetryCount + 1
+		}
+
+		claimed, err := c.client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    c.opts.Group,
+			Consumer: c.opts.Consumer,
+			MinIdle:  c.opts.ClaimMinIdle,
+			Messages: ids,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("xclaim failed: %w", err)
+		}
+		for _, msg := range claimed {
+			c.dispatch(ctx, StreamMessage{Stream: stream, ID: msg.ID, Values: msg.Values}, deliveries[msg.ID], handler)
+		}
+
+		// 已处理的消息要么被确认，要么空闲时间被 XCLAIM 重置，不会在下一轮重复出现
+		if int64(len(pending)) < c.opts.BatchSize || len(claimed) == 0 {
+			return nil
+		}
+	}
+}
+
+// dispatch 处理单条消息：投递次数超限直接进入死信流，否则调用 handler 并根据结果 XACK
+func (c *ReliableConsumer) dispatch(ctx context.Context, msg StreamMessage, deliveries int64, handler StreamHandler) {
+	if deliveries > c.opts.MaxDeliveries {
+		c.deadLetter(ctx, msg, deliveries, c.takeLastError(msg.ID, "max deliveries exceeded"))
+		return
+	}
+
+	msg.Deliveries = deliveries
+	err := handler(ctx, msg)
+	if errors.Is(err, ErrDeferred) {
+		return
+	}
+	if err == nil {
+		c.Ack(ctx, msg)
+		return
+	}
+	c.Fail(ctx, msg, err)
+}
+
+// Ack 确认已处理成功的消息（同一流的消息合并为一次 XACK）
+func (c *ReliableConsumer) Ack(ctx context.Context, msgs ...StreamMessage) {
+	byStream := make(map[string][]string)
+	var streams []string
+	for _, msg := range msgs {
+		c.forgetError(msg.ID)
+		if _, ok := byStream[msg.Stream]; !ok {
+			streams = append(streams, msg.Stream)
+		}
+		byStream[msg.Stream] = append(byStream[msg.Stream], msg.ID)
+	}
+	for _, stream := range streams {
+		ids := byStream[stream]
+		if err := c.client.XAck(ctx, stream, c.opts.Group, ids...).Err(); err != nil {
+			c.logger.Warn("Failed to ack messages",
+				zap.String("stream", stream),
+				zap.Strings("message_ids", ids),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// Fail 记录处理失败：永久性错误直接进入死信流，其他错误保留在 PEL 中，空闲超时后重新认领
+func (c *ReliableConsumer) Fail(ctx context.Context, msg StreamMessage, err error) {
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		c.forgetError(msg.ID)
+		c.deadLetter(ctx, msg, msg.Deliveries, err.Error())
+		return
+	}
+
+	c.rememberError(msg.ID, err.Error())
+	c.logger.Error("Failed to process message, will retry after idle timeout",
+		zap.String("stream", msg.Stream),
+		zap.String("message_id", msg.ID),
+		zap.Int64("deliveries", msg.Deliveries),
+		zap.Duration("claim_min_idle", c.opts.ClaimMinIdle),
+		zap.Error(err),
+	)
+}
+
+// deadLetter 将消息写入 <stream>:dlq 并确认原消息
+func (c *ReliableConsumer) deadLetter(ctx context.Context, msg StreamMessage, deliveries int64, reason string) {
+	dlq := msg.Stream + DeadLetterSuffix
+
+	values := make(map[string]interface{}, len(msg.Values)+6)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["dlq_source_stream"] = msg.Stream
+	values["dlq_source_id"] = msg.ID
+	values["dlq_group"] = c.opts.Group
+	values["dlq_consumer"] = c.opts.Consumer
+	values["dlq_deliveries"] = deliveries
+	values["dlq_error"] = reason
+	values["dlq_failed_at"] = time.Now().Unix()
+
+	if _, err := PublishToStream(ctx, c.client, dlq, values); err != nil {
+		// 写入死信流失败时不确认，消息仍留在 PEL 中，下次认领时重试
+		c.logger.Error("Failed to move message to dead-letter stream",
+			zap.String("stream", msg.Stream),
+			zap.String("dlq", dlq),
+			zap.String("message_id", msg.ID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if err := c.client.XAck(ctx, msg.Stream, c.opts.Group, msg.ID).Err(); err != nil {
+		c.logger.Warn("Failed to ack dead-lettered message",
+			zap.String("stream", msg.Stream),
+			zap.String("message_id", msg.ID),
+			zap.Error(err),
+		)
+	}
+
+	c.logger.Warn("Moved message to dead-letter stream",
+		zap.String("stream", msg.Stream),
+		zap.String("dlq", dlq),
+		zap.String("message_id", msg.ID),
+		zap.Int64("deliveries", deliveries),
+		zap.String("error", reason),
+	)
+}
+
+func (c *ReliableConsumer) rememberError(id, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastErrors[id] = reason
+}
+
+func (c *ReliableConsumer) forgetError(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.lastErrors, id)
+}
+
+func (c *ReliableConsumer) takeLastError(id, fallback string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reason, ok := c.lastErrors[id]
+	delete(c.lastErrors, id)
+	if !ok {
+		return fallback
+	}
+	return reason
+}
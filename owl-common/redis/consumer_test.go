@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func setupTestConsumer(t *testing.T, opts ConsumerOptions) (*miniredis.Miniredis, *redis.Client, *ReliableConsumer) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	opts.Streams = []string{"test:stream"}
+	opts.Group = "test-group"
+	opts.Consumer = "test-consumer"
+	opts.Block = 10 * time.Millisecond
+	consumer := NewReliableConsumer(client, opts, nil)
+	if err := consumer.Setup(context.Background()); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	return mr, client, consumer
+}
+
+func pendingCount(t *testing.T, client *redis.Client) int64 {
+	t.Helper()
+	pending, err := client.XPending(context.Background(), "test:stream", "test-group").Result()
+	if err != nil {
+		t.Fatalf("xpending failed: %v", err)
+	}
+	return pending.Count
+}
+
+func TestReliableConsumer_AcksOnSuccess(t *testing.T) {
+	_, client, consumer := setupTestConsumer(t, ConsumerOptions{})
+	ctx := context.Background()
+
+	if _, err := PublishToStream(ctx, client, "test:stream", map[string]interface{}{"data": "hello"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	var got []string
+	err := consumer.Poll(ctx, func(ctx context.Context, msg StreamMessage) error {
+		got = append(got, msg.Values["data"].(string))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(got) != 1 || got[0] != "hello" {
+		t.Fatalf("unexpected messages: %v", got)
+	}
+	if n := pendingCount(t, client); n != 0 {
+		t.Fatalf("expected empty PEL, got %d", n)
+	}
+}
+
+func TestReliableConsumer_ReclaimsAndDeadLetters(t *testing.T) {
+	mr, client, consumer := setupTestConsumer(t, ConsumerOptions{
+		ClaimMinIdle:  time.Millisecond,
+		ClaimInterval: time.Millisecond,
+		MaxDeliveries: 2,
+	})
+	ctx := context.Background()
+
+	if _, err := PublishToStream(ctx, client, "test:stream", map[string]interface{}{"data": "bad"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	attempts := 0
+	handler := func(ctx context.Context, msg StreamMessage) error {
+		attempts++
+		return errors.New("boom")
+	}
+
+	// 第 1 次：新消息投递失败；第 2 次：重新认领后再次失败；第 3 次：超过最大投递次数，进入死信流
+	for i := 0; i < 3; i++ {
+		time.Sleep(5 * time.Millisecond)
+		mr.FastForward(time.Second)
+		if err := consumer.Poll(ctx, handler); err != nil {
+			t.Fatalf("poll %d failed: %v", i, err)
+		}
+	}
+
+	if attempts != 2 {
+		t.Fatalf("expected 2 handler attempts, got %d", attempts)
+	}
+	if n := pendingCount(t, client); n != 0 {
+		t.Fatalf("expected empty PEL after dead-lettering, got %d", n)
+	}
+
+	dlq, err := client.XRange(ctx, "test:stream"+DeadLetterSuffix, "-", "+").Result()
+	if err != nil {
+		t.Fatalf("xrange dlq failed: %v", err)
+	}
+	if len(dlq) != 1 {
+		t.Fatalf("expected 1 dead-lettered message, got %d", len(dlq))
+	}
+	if dlq[0].Values["dlq_error"] != "boom" {
+		t.Fatalf("expected dlq_error=boom, got %v", dlq[0].Values["dlq_error"])
+	}
+	if dlq[0].Values["data"] != "bad" {
+		t.Fatalf("expected original payload to be preserved, got %v", dlq[0].Values["data"])
+	}
+}
+
+func TestReliableConsumer_PermanentErrorGoesStraightToDLQ(t *testing.T) {
+	_, client, consumer := setupTestConsumer(t, ConsumerOptions{})
+	ctx := context.Background()
+
+	if _, err := PublishToStream(ctx, client, "test:stream", map[string]interface{}{"data": "{"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	err := consumer.Poll(ctx, func(ctx context.Context, msg StreamMessage) error {
+		return Permanent(errors.New("invalid json"))
+	})
+	if err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+
+	if n := pendingCount(t, client); n != 0 {
+		t.Fatalf("expected empty PEL, got %d", n)
+	}
+	n, err := client.XLen(ctx, "test:stream"+DeadLetterSuffix).Result()
+	if err != nil {
+		t.Fatalf("xlen dlq failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 dead-lettered message, got %d", n)
+	}
+}
+
+func TestReliableConsumer_DeferredAck(t *testing.T) {
+	_, client, consumer := setupTestConsumer(t, ConsumerOptions{})
+	ctx := context.Background()
+
+	for _, data := range []string{"a", "b", "c"} {
+		if _, err := PublishToStream(ctx, client, "test:stream", map[string]interface{}{"data": data}); err != nil {
+			t.Fatalf("publish failed: %v", err)
+		}
+	}
+
+	var deferred []StreamMessage
+	err := consumer.Poll(ctx, func(ctx context.Context, msg StreamMessage) error {
+		deferred = append(deferred, msg)
+		return ErrDeferred
+	})
+	if err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(deferred) != 3 || deferred[0].Deliveries != 1 {
+		t.Fatalf("unexpected deferred messages: %+v", deferred)
+	}
+	// 未确认前留在 PEL 中
+	if n := pendingCount(t, client); n != 3 {
+		t.Fatalf("expected 3 pending, got %d", n)
+	}
+
+	consumer.Ack(ctx, deferred[:2]...)
+	consumer.Fail(ctx, deferred[2], errors.New("db down"))
+	if n := pendingCount(t, client); n != 1 {
+		t.Fatalf("expected failed message to stay pending, got %d", n)
+	}
+
+	consumer.Fail(ctx, deferred[2], Permanent(errors.New("bad row")))
+	if n := pendingCount(t, client); n != 0 {
+		t.Fatalf("expected empty PEL, got %d", n)
+	}
+	if n, _ := client.XLen(ctx, "test:stream"+DeadLetterSuffix).Result(); n != 1 {
+		t.Fatalf("expected 1 dead-lettered message, got %d", n)
+	}
+}
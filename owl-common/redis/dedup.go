@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Deduplicator 基于 Redis SET NX 的跨副本消息去重
+// 用于多副本滚动发布期间（新旧副本同时收到同一条 MQTT 消息）避免重复写入 Streams
+type Deduplicator struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewDeduplicator 创建去重器
+// prefix 为键前缀（如 "dedup:radar"），ttl 为去重窗口
+func NewDeduplicator(client *redis.Client, prefix string, ttl time.Duration) *Deduplicator {
+	return &Deduplicator{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// FirstSeen 判断消息是否首次出现（键由 parts 拼接，如 device_id + 设备时间戳）
+// Redis 出错时返回 true 和错误：宁可重复处理，也不丢消息
+func (d *Deduplicator) FirstSeen(ctx context.Context, parts ...string) (bool, error) {
+	key := d.prefix + ":" + strings.Join(parts, ":")
+	ok, err := d.client.SetNX(ctx, key, 1, d.ttl).Result()
+	if err != nil {
+		return true, fmt.Errorf("failed to check dedup key %s: %w", key, err)
+	}
+	return ok, nil
+}
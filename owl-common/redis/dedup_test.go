@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestDeduplicator_FirstSeen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx := context.Background()
+	d := NewDeduplicator(client, "dedup:test", time.Minute)
+
+	first, err := d.FirstSeen(ctx, "dev-1", "1700000000")
+	if err != nil || !first {
+		t.Fatalf("expected first delivery, got %v, %v", first, err)
+	}
+	dup, err := d.FirstSeen(ctx, "dev-1", "1700000000")
+	if err != nil || dup {
+		t.Fatalf("expected duplicate, got %v, %v", dup, err)
+	}
+	other, err := d.FirstSeen(ctx, "dev-1", "1700000001")
+	if err != nil || !other {
+		t.Fatalf("expected different timestamp to be first, got %v, %v", other, err)
+	}
+
+	mr.FastForward(2 * time.Minute)
+	again, err := d.FirstSeen(ctx, "dev-1", "1700000000")
+	if err != nil || !again {
+		t.Fatalf("expected key to expire after ttl, got %v, %v", again, err)
+	}
+}
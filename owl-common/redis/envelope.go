@@ -0,0 +1,183 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 信封字段（Redis Streams 消息中的字段名）
+const (
+	FieldSchema        = "schema"
+	FieldSchemaVersion = "schema_version"
+	FieldProducer      = "producer"
+	FieldTraceID       = "trace_id"
+	FieldTenantID      = "tenant_id"
+	FieldEventTime     = "event_time"
+	FieldIngestTime    = "ingest_time"
+	FieldPayload       = "payload"
+)
+
+var (
+	// ErrNotEnvelope 消息不是信封格式（旧格式消息，只有 data 字段）
+	ErrNotEnvelope = errors.New("stream message is not an envelope")
+	// ErrSchemaMismatch 信封的 schema 名称/版本与消费者期望不一致，或 payload 与 schema 不符
+	ErrSchemaMismatch = errors.New("stream message schema mismatch")
+)
+
+// Schema 消息契约：名称 + 版本
+// 版本号在 payload 结构发生不兼容变化时递增
+type Schema struct {
+	Name    string
+	Version int
+}
+
+func (s Schema) String() string {
+	return fmt.Sprintf("%s/v%d", s.Name, s.Version)
+}
+
+// Envelope 版本化的流消息信封
+type Envelope[T any] struct {
+	Schema     Schema
+	Producer   string    // 生产者服务名，如 "wisefido-radar"
+	TraceID    string    // 链路追踪 ID（跨服务透传）
+	TenantID   string    // 租户 ID
+	EventTime  time.Time // 事件发生时间（设备时间）
+	IngestTime time.Time // 进入系统的时间
+	Payload    T
+}
+
+// NewEnvelope 创建信封（生成新的 trace id，IngestTime 为当前时间）
+func NewEnvelope[T any](schema Schema, producer, tenantID string, eventTime time.Time, payload T) Envelope[T] {
+	return Envelope[T]{
+		Schema:     schema,
+		Producer:   producer,
+		TraceID:    NewTraceID(),
+		TenantID:   tenantID,
+		EventTime:  eventTime,
+		IngestTime: time.Now(),
+		Payload:    payload,
+	}
+}
+
+// NewTraceID 生成 16 字节随机 trace id（hex）
+func NewTraceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Encode 将信封编码为 Redis Streams 字段
+func Encode[T any](env Envelope[T]) (map[string]interface{}, error) {
+	if env.Schema.Name == "" || env.Schema.Version <= 0 {
+		return nil, fmt.Errorf("invalid envelope schema: %q", env.Schema.String())
+	}
+	payload, err := json.Marshal(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %w", env.Schema, err)
+	}
+	return map[string]interface{}{
+		FieldSchema:        env.Schema.Name,
+		FieldSchemaVersion: strconv.Itoa(env.Schema.Version),
+		FieldProducer:      env.Producer,
+		FieldTraceID:       env.TraceID,
+		FieldTenantID:      env.TenantID,
+		FieldEventTime:     env.EventTime.UTC().Format(time.RFC3339Nano),
+		FieldIngestTime:    env.IngestTime.UTC().Format(time.RFC3339Nano),
+		FieldPayload:       string(payload),
+	}, nil
+}
+
+// Publish 通过发布器发布信封（应用保留策略与背压策略）
+func Publish[T any](ctx context.Context, p *StreamPublisher, env Envelope[T], priority Priority) (string, error) {
+	values, err := Encode(env)
+	if err != nil {
+		return "", err
+	}
+	return p.Publish(ctx, values, priority)
+}
+
+// PublishTo 直接发布信封到指定流（无保留策略与背压）
+func PublishTo[T any](ctx context.Context, client *redis.Client, stream string, env Envelope[T]) (string, error) {
+	values, err := Encode(env)
+	if err != nil {
+		return "", err
+	}
+	return PublishToStream(ctx, client, stream, values)
+}
+
+// Decode 解码信封并校验契约
+//
+// - 消息没有 schema 字段时返回 ErrNotEnvelope（旧格式消息）
+// - schema 名称不一致、版本高于消费者支持的版本、payload 含有未知字段时返回 ErrSchemaMismatch
+// - 低于当前版本的消息视为兼容（payload 缺少的字段保持零值）
+func Decode[T any](msg StreamMessage, schema Schema) (*Envelope[T], error) {
+	name, ok := msg.Values[FieldSchema].(string)
+	if !ok || name == "" {
+		return nil, ErrNotEnvelope
+	}
+	if name != schema.Name {
+		return nil, fmt.Errorf("%w: got schema %q, want %q", ErrSchemaMismatch, name, schema.Name)
+	}
+
+	version, err := strconv.Atoi(stringValue(msg.Values[FieldSchemaVersion]))
+	if err != nil || version <= 0 {
+		return nil, fmt.Errorf("%w: invalid schema_version %v", ErrSchemaMismatch, msg.Values[FieldSchemaVersion])
+	}
+	if version > schema.Version {
+		return nil, fmt.Errorf("%w: %s/v%d is newer than supported %s", ErrSchemaMismatch, name, version, schema)
+	}
+
+	env := &Envelope[T]{
+		Schema:   Schema{Name: name, Version: version},
+		Producer: stringValue(msg.Values[FieldProducer]),
+		TraceID:  stringValue(msg.Values[FieldTraceID]),
+		TenantID: stringValue(msg.Values[FieldTenantID]),
+	}
+	if env.EventTime, err = parseEnvelopeTime(msg.Values[FieldEventTime]); err != nil {
+		return nil, fmt.Errorf("%w: invalid event_time: %v", ErrSchemaMismatch, err)
+	}
+	if env.IngestTime, err = parseEnvelopeTime(msg.Values[FieldIngestTime]); err != nil {
+		return nil, fmt.Errorf("%w: invalid ingest_time: %v", ErrSchemaMismatch, err)
+	}
+
+	// 严格解码：payload 中出现消费者不认识的字段说明契约已变化但版本号未递增
+	decoder := json.NewDecoder(bytes.NewReader([]byte(stringValue(msg.Values[FieldPayload]))))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&env.Payload); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode %s payload: %v", ErrSchemaMismatch, schema, err)
+	}
+
+	return env, nil
+}
+
+func parseEnvelopeTime(v interface{}) (time.Time, error) {
+	s := stringValue(v)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
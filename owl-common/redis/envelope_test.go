@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testPayload struct {
+	DeviceID string  `json:"device_id"`
+	Value    float64 `json:"value"`
+}
+
+var testSchema = Schema{Name: "test.payload", Version: 2}
+
+func encodeTestMessage(t *testing.T, env Envelope[testPayload]) StreamMessage {
+	t.Helper()
+	values, err := Encode(env)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	return StreamMessage{Stream: "test:stream", ID: "1-0", Values: values}
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	eventTime := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	env := NewEnvelope(testSchema, "test-producer", "tenant-1", eventTime, testPayload{DeviceID: "dev-1", Value: 36.6})
+
+	got, err := Decode[testPayload](encodeTestMessage(t, env), testSchema)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if got.Schema != testSchema || got.Producer != "test-producer" || got.TenantID != "tenant-1" {
+		t.Fatalf("unexpected header: %+v", got)
+	}
+	if got.TraceID == "" || got.TraceID != env.TraceID {
+		t.Fatalf("trace id not preserved: %q vs %q", got.TraceID, env.TraceID)
+	}
+	if !got.EventTime.Equal(eventTime) || got.IngestTime.IsZero() {
+		t.Fatalf("unexpected times: event=%v ingest=%v", got.EventTime, got.IngestTime)
+	}
+	if got.Payload.DeviceID != "dev-1" || got.Payload.Value != 36.6 {
+		t.Fatalf("unexpected payload: %+v", got.Payload)
+	}
+}
+
+func TestEnvelope_DecodeRejectsContractChanges(t *testing.T) {
+	env := NewEnvelope(testSchema, "p", "t", time.Now(), testPayload{DeviceID: "dev-1"})
+	msg := encodeTestMessage(t, env)
+
+	// 新版本生产者
+	newer := encodeTestMessage(t, env)
+	newer.Values[FieldSchemaVersion] = "3"
+	if _, err := Decode[testPayload](newer, testSchema); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected schema mismatch for newer version, got %v", err)
+	}
+
+	// 不同的 schema
+	if _, err := Decode[testPayload](msg, Schema{Name: "other", Version: 1}); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected schema mismatch for other schema, got %v", err)
+	}
+
+	// payload 出现未知字段
+	unknown := encodeTestMessage(t, env)
+	unknown.Values[FieldPayload] = `{"device_id":"dev-1","renamed_value":1}`
+	if _, err := Decode[testPayload](unknown, testSchema); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected schema mismatch for unknown field, got %v", err)
+	}
+
+	// 旧版本生产者视为兼容
+	older := encodeTestMessage(t, env)
+	older.Values[FieldSchemaVersion] = "1"
+	if _, err := Decode[testPayload](older, testSchema); err != nil {
+		t.Fatalf("expected older version to decode, got %v", err)
+	}
+}
+
+func TestEnvelope_DecodeLegacyMessage(t *testing.T) {
+	msg := StreamMessage{Values: map[string]interface{}{"data": `{"device_id":"dev-1"}`, "timestamp": "1"}}
+	if _, err := Decode[testPayload](msg, testSchema); !errors.Is(err, ErrNotEnvelope) {
+		t.Fatalf("expected ErrNotEnvelope, got %v", err)
+	}
+}
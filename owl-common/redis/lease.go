@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrLeaseLost 租约已被其他实例持有或续约失败超过 ttl
+var ErrLeaseLost = errors.New("lease lost")
+
+// Lease 基于 Redis 的单实例租约（主备部署）
+// 同一时间只有一个实例持有租约；持有者定期续约，异常退出后租约在 ttl 后过期，由备用实例接管。
+// 用于内存状态依赖“看到全部消息”的服务（如传感器融合），避免多个副本各自只看到部分消息。
+type Lease struct {
+	client *redis.Client
+	key    string
+	owner  string
+	ttl    time.Duration
+}
+
+// NewLease 创建租约
+// owner 为实例标识（各副本必须不同，如 hostname:pid）
+func NewLease(client *redis.Client, key, owner string, ttl time.Duration) *Lease {
+	return &Lease{
+		client: client,
+		key:    key,
+		owner:  owner,
+		ttl:    ttl,
+	}
+}
+
+// acquireScript 未被持有时获取；已由本实例持有时续约
+var acquireScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if current == false then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseScript 仅当仍由本实例持有时删除
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// TryAcquire 尝试获取租约（已持有时续约），返回是否由本实例持有
+func (l *Lease) TryAcquire(ctx context.Context) (bool, error) {
+	n, err := acquireScript.Run(ctx, l.client, []string{l.key}, l.owner, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", l.key, err)
+	}
+	return n == 1, nil
+}
+
+// Acquire 阻塞直到获取租约（每 retry 重试一次），ctx 取消时返回 ctx.Err()
+func (l *Lease) Acquire(ctx context.Context, retry time.Duration) error {
+	for {
+		if ok, err := l.TryAcquire(ctx); err == nil && ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+// Keep 持续续约（每 ttl/3 一次），直到 ctx 取消（返回 nil）或租约丢失（返回 ErrLeaseLost）
+// Redis 暂时不可用时继续重试；距上次成功续约超过 ttl 视为丢失（租约可能已被其他实例获取）
+func (l *Lease) Keep(ctx context.Context) error {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		ok, err := l.TryAcquire(ctx)
+		switch {
+		case err == nil && ok:
+			renewed = time.Now()
+		case err == nil:
+			return fmt.Errorf("%w: %s is held by another instance", ErrLeaseLost, l.key)
+		case time.Since(renewed) >= l.ttl:
+			return fmt.Errorf("%w: %s not renewed within %s: %v", ErrLeaseLost, l.key, l.ttl, err)
+		}
+	}
+}
+
+// Release 释放租约（仅当仍由本实例持有），备用实例无需等待过期即可接管
+func (l *Lease) Release(ctx context.Context) error {
+	if err := releaseScript.Run(ctx, l.client, []string{l.key}, l.owner).Err(); err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", l.key, err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestLease_SingleHolder(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx := context.Background()
+	a := NewLease(client, "lease:test", "a", 10*time.Second)
+	b := NewLease(client, "lease:test", "b", 10*time.Second)
+
+	if ok, err := a.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("a should acquire, got %v, %v", ok, err)
+	}
+	if ok, err := b.TryAcquire(ctx); err != nil || ok {
+		t.Fatalf("b should not acquire while a holds, got %v, %v", ok, err)
+	}
+
+	// 续约刷新过期时间
+	mr.FastForward(8 * time.Second)
+	if ok, err := a.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("a should renew, got %v, %v", ok, err)
+	}
+	mr.FastForward(8 * time.Second)
+	if ok, _ := b.TryAcquire(ctx); ok {
+		t.Fatal("renewed lease should not expire")
+	}
+
+	// b 不能释放 a 的租约
+	if err := b.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if !mr.Exists("lease:test") {
+		t.Fatal("lease of another owner must not be released")
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if ok, err := b.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("b should acquire after release, got %v, %v", ok, err)
+	}
+}
+
+func TestLease_ExpiresWithoutRenewal(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx := context.Background()
+	a := NewLease(client, "lease:test", "a", 10*time.Second)
+	b := NewLease(client, "lease:test", "b", 10*time.Second)
+	if ok, _ := a.TryAcquire(ctx); !ok {
+		t.Fatal("a should acquire")
+	}
+	mr.FastForward(11 * time.Second)
+	if ok, err := b.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("b should take over expired lease, got %v, %v", ok, err)
+	}
+}
+
+func TestLease_AcquireWaitsForHolder(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	a := NewLease(client, "lease:test", "a", time.Minute)
+	b := NewLease(client, "lease:test", "b", time.Minute)
+	if ok, _ := a.TryAcquire(context.Background()); !ok {
+		t.Fatal("a should acquire")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := b.Acquire(ctx, 10*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected standby to wait until ctx done, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- b.Acquire(context.Background(), 10*time.Millisecond) }()
+	if err := a.Release(context.Background()); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("acquire failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("standby did not take over after release")
+	}
+}
+
+func TestLease_KeepDetectsTakeover(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	a := NewLease(client, "lease:test", "a", 30*time.Millisecond)
+	if ok, _ := a.TryAcquire(context.Background()); !ok {
+		t.Fatal("a should acquire")
+	}
+	// 租约被其他实例持有（如 a 长时间停顿后过期被接管）
+	mr.Set("lease:test", "b")
+
+	done := make(chan error, 1)
+	go func() { done <- a.Keep(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrLeaseLost) {
+			t.Fatalf("expected ErrLeaseLost, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Keep did not detect takeover")
+	}
+
+	// ctx 取消时正常返回
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewLease(client, "lease:other", "c", time.Minute)
+	cancel()
+	if err := c.Keep(ctx); err != nil {
+		t.Fatalf("expected nil on ctx cancel, got %v", err)
+	}
+}
@@ -0,0 +1,343 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"owl-common/config"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// 积压策略
+const (
+	LagPolicyBlock      = "block"       // 阻塞发布，直到积压恢复或超时
+	LagPolicyDropOldest = "drop_oldest" // 继续发布，裁剪最旧的未消费消息，使积压不超过阈值
+	LagPolicySample     = "sample"      // 低优先级消息按 SampleRate 抽样发布
+)
+
+// Priority 消息优先级
+type Priority int
+
+const (
+	PriorityNormal Priority = iota // 普通优先级（事件、报警等，始终发布）
+	PriorityLow                    // 低优先级（周期性设备遥测，积压时可抽样）
+)
+
+// ErrBackpressure 下游消费者积压超过阈值，且 block 策略等待超时
+var ErrBackpressure = errors.New("stream consumer lag exceeds threshold")
+
+// GroupLag 消费者组积压信息（XINFO GROUPS）
+type GroupLag struct {
+	Group   string
+	Lag     int64 // 尚未投递给该组的消息数（Redis 7+；无法计算时以 Pending 代替）
+	Pending int64 // 已投递但未确认的消息数
+}
+
+// StreamLag 查询流上所有消费者组的积压
+// 流不存在时返回空列表
+func StreamLag(ctx context.Context, client *redis.Client, stream string) ([]GroupLag, error) {
+	// go-redis v8 的 XInfoGroups 不解析 lag 字段，这里直接解析原始返回值
+	reply, err := client.Do(ctx, "XINFO", "GROUPS", stream).Result()
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("xinfo groups failed: %w", err)
+	}
+
+	groups, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected xinfo groups reply: %T", reply)
+	}
+
+	result := make([]GroupLag, 0, len(groups))
+	for _, g := range groups {
+		fields, ok := g.([]interface{})
+		if !ok {
+			continue
+		}
+		info := GroupLag{Lag: -1}
+		for i := 0; i+1 < len(fields); i += 2 {
+			key, _ := fields[i].(string)
+			switch key {
+			case "name":
+				info.Group, _ = fields[i+1].(string)
+			case "pending":
+				info.Pending, _ = fields[i+1].(int64)
+			case "lag":
+				// lag 为 nil 表示 Redis 无法计算（如有消息被删除）
+				if lag, ok := fields[i+1].(int64); ok {
+					info.Lag = lag
+				}
+			}
+		}
+		if info.Lag < 0 {
+			info.Lag = info.Pending
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// PublisherStats 发布器统计
+type PublisherStats struct {
+	Stream    string
+	Lag       int64 // 最近一次检查的最大消费者组积压
+	Lagging   bool  // 是否处于积压状态
+	Published int64 // 已发布消息数
+	Sampled   int64 // 因抽样被丢弃的低优先级消息数
+	Trimmed   int64 // 因 drop_oldest 被裁剪的最旧消息数
+	Blocked   int64 // 因 block 策略等待的次数
+	Rejected  int64 // block 策略等待超时被拒绝的消息数
+}
+
+// StreamPublisher 带保留策略和背压控制的流发布器
+//
+// - 每次 XADD 按 StreamConfig 近似裁剪（MAXLEN ~ / MINID ~）
+// - 按 LagCheckInterval 通过 XINFO GROUPS 检查下游积压，超过 MaxLag 时执行 LagPolicy
+// - Report 按 ReportInterval 输出发布统计和积压（"Stream publisher metrics" 日志）
+type StreamPublisher struct {
+	client *redis.Client
+	stream string
+	cfg    config.StreamConfig
+	logger *zap.Logger
+
+	mu            sync.Mutex
+	lastCheck     time.Time
+	sampleCounter int64
+	stats         PublisherStats
+}
+
+// NewStreamPublisher 创建流发布器
+func NewStreamPublisher(client *redis.Client, stream string, cfg config.StreamConfig, logger *zap.Logger) *StreamPublisher {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 1
+	}
+	if cfg.LagCheckInterval <= 0 {
+		cfg.LagCheckInterval = time.Second
+	}
+	return &StreamPublisher{
+		client: client,
+		stream: stream,
+		cfg:    cfg,
+		logger: logger,
+		stats:  PublisherStats{Stream: stream},
+	}
+}
+
+// Stream 返回发布的流名称
+func (p *StreamPublisher) Stream() string {
+	return p.stream
+}
+
+// Publish 发布消息
+// 被抽样丢弃时返回空 ID 和 nil 错误
+func (p *StreamPublisher) Publish(ctx context.Context, values map[string]interface{}, priority Priority) (string, error) {
+	if p.cfg.MaxLag > 0 {
+		p.refreshLag(ctx, false)
+
+		if p.isLagging() {
+			switch p.cfg.LagPolicy {
+			case LagPolicyBlock:
+				if err := p.waitForLag(ctx); err != nil {
+					return "", err
+				}
+			case LagPolicySample:
+				if priority == PriorityLow && !p.sample() {
+					return "", nil
+				}
+			}
+		}
+	}
+
+	id, err := PublishToStreamWithRetention(ctx, p.client, p.stream, values, p.cfg)
+	if err != nil {
+		return id, err
+	}
+
+	p.mu.Lock()
+	p.stats.Published++
+	lagging := p.stats.Lagging
+	p.mu.Unlock()
+
+	if lagging && p.cfg.LagPolicy == LagPolicyDropOldest {
+		p.dropOldest(ctx)
+	}
+	return id, nil
+}
+
+// PublishJSON 发布 JSON 消息（与 PublishJSONToStream 格式一致）
+func (p *StreamPublisher) PublishJSON(ctx context.Context, data interface{}, priority Priority) (string, error) {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return p.Publish(ctx, map[string]interface{}{
+		"data":      string(jsonBytes),
+		"timestamp": time.Now().Unix(),
+	}, priority)
+}
+
+// Lag 立即检查并返回当前最大消费者组积压
+func (p *StreamPublisher) Lag(ctx context.Context) int64 {
+	p.refreshLag(ctx, true)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats.Lag
+}
+
+// Stats 获取统计快照
+func (p *StreamPublisher) Stats() PublisherStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Report 定期输出发布统计和下游积压（阻塞直到 ctx 结束；ReportInterval 为 0 时直接返回）
+func (p *StreamPublisher) Report(ctx context.Context) {
+	if p.cfg.ReportInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.cfg.ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.refreshLag(ctx, true)
+			stats := p.Stats()
+			p.logger.Info("Stream publisher metrics",
+				zap.String("stream", stats.Stream),
+				zap.Int64("lag", stats.Lag),
+				zap.Bool("lagging", stats.Lagging),
+				zap.Int64("published", stats.Published),
+				zap.Int64("sampled", stats.Sampled),
+				zap.Int64("trimmed", stats.Trimmed),
+				zap.Int64("blocked", stats.Blocked),
+				zap.Int64("rejected", stats.Rejected),
+			)
+		}
+	}
+}
+
+// refreshLag 检查下游积压（未到检查间隔且非强制时跳过）
+func (p *StreamPublisher) refreshLag(ctx context.Context, force bool) {
+	p.mu.Lock()
+	if !force && time.Since(p.lastCheck) < p.cfg.LagCheckInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastCheck = time.Now()
+	p.mu.Unlock()
+
+	groups, err := StreamLag(ctx, p.client, p.stream)
+	if err != nil {
+		p.logger.Warn("Failed to check stream lag",
+			zap.String("stream", p.stream),
+			zap.Error(err),
+		)
+		return
+	}
+
+	var maxLag int64
+	var maxGroup string
+	for _, g := range groups {
+		if g.Lag > maxLag {
+			maxLag = g.Lag
+			maxGroup = g.Group
+		}
+	}
+
+	p.mu.Lock()
+	wasLagging := p.stats.Lagging
+	p.stats.Lag = maxLag
+	p.stats.Lagging = p.cfg.MaxLag > 0 && maxLag > p.cfg.MaxLag
+	lagging := p.stats.Lagging
+	p.mu.Unlock()
+
+	switch {
+	case lagging && !wasLagging:
+		p.logger.Warn("Stream consumers lagging, applying backpressure policy",
+			zap.String("stream", p.stream),
+			zap.String("consumer_group", maxGroup),
+			zap.Int64("lag", maxLag),
+			zap.Int64("max_lag", p.cfg.MaxLag),
+			zap.String("policy", p.cfg.LagPolicy),
+		)
+	case !lagging && wasLagging:
+		p.logger.Info("Stream consumers caught up",
+			zap.String("stream", p.stream),
+			zap.Int64("lag", maxLag),
+		)
+	}
+}
+
+func (p *StreamPublisher) isLagging() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats.Lagging
+}
+
+// waitForLag block 策略：等待积压恢复，超时返回 ErrBackpressure
+func (p *StreamPublisher) waitForLag(ctx context.Context) error {
+	p.mu.Lock()
+	p.stats.Blocked++
+	p.mu.Unlock()
+
+	deadline := time.Now().Add(p.cfg.BlockTimeout)
+	for p.isLagging() {
+		if !time.Now().Before(deadline) {
+			p.mu.Lock()
+			p.stats.Rejected++
+			p.mu.Unlock()
+			return fmt.Errorf("%w: stream %s", ErrBackpressure, p.stream)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.cfg.LagCheckInterval):
+		}
+		p.refreshLag(ctx, true)
+	}
+	return nil
+}
+
+// sample sample 策略：每 SampleRate 条低优先级消息保留 1 条
+func (p *StreamPublisher) sample() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sampleCounter++
+	if p.sampleCounter%int64(p.cfg.SampleRate) == 0 {
+		return true
+	}
+	p.stats.Sampled++
+	return false
+}
+
+// dropOldest drop_oldest 策略：将流裁剪到 MaxLag，丢弃最旧的未消费消息
+func (p *StreamPublisher) dropOldest(ctx context.Context) {
+	trimmed, err := p.client.XTrimMaxLenApprox(ctx, p.stream, p.cfg.MaxLag, 0).Result()
+	if err != nil {
+		p.logger.Warn("Failed to trim lagging stream",
+			zap.String("stream", p.stream),
+			zap.Error(err),
+		)
+		return
+	}
+	if trimmed > 0 {
+		p.mu.Lock()
+		p.stats.Trimmed += trimmed
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,169 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"owl-common/config"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func setupTestPublisher(t *testing.T, cfg config.StreamConfig) (*redis.Client, *StreamPublisher) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	if err := CreateConsumerGroup(context.Background(), client, "test:stream", "test-group"); err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+	return client, NewStreamPublisher(client, "test:stream", cfg, nil)
+}
+
+func publishN(t *testing.T, p *StreamPublisher, n int, priority Priority) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := p.Publish(context.Background(), map[string]interface{}{"i": i}, priority); err != nil {
+			t.Fatalf("publish %d failed: %v", i, err)
+		}
+	}
+}
+
+func TestStreamLag(t *testing.T) {
+	client, p := setupTestPublisher(t, config.StreamConfig{})
+	publishN(t, p, 3, PriorityNormal)
+
+	groups, err := StreamLag(context.Background(), client, "test:stream")
+	if err != nil {
+		t.Fatalf("stream lag failed: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Group != "test-group" || groups[0].Lag != 3 {
+		t.Fatalf("unexpected lag: %+v", groups)
+	}
+
+	groups, err = StreamLag(context.Background(), client, "missing:stream")
+	if err != nil || len(groups) != 0 {
+		t.Fatalf("expected no groups for missing stream, got %+v, %v", groups, err)
+	}
+}
+
+func TestStreamPublisher_Retention(t *testing.T) {
+	client, p := setupTestPublisher(t, config.StreamConfig{MaxLen: 5})
+	publishN(t, p, 20, PriorityNormal)
+
+	n, err := client.XLen(context.Background(), "test:stream").Result()
+	if err != nil {
+		t.Fatalf("xlen failed: %v", err)
+	}
+	if n > 5 {
+		t.Fatalf("expected stream trimmed to 5, got %d", n)
+	}
+}
+
+func TestStreamPublisher_SampleLowPriority(t *testing.T) {
+	_, p := setupTestPublisher(t, config.StreamConfig{
+		MaxLag:     2,
+		LagPolicy:  LagPolicySample,
+		SampleRate: 2,
+	})
+	publishN(t, p, 3, PriorityNormal)
+	p.Lag(context.Background())
+
+	publishN(t, p, 4, PriorityLow)
+	publishN(t, p, 2, PriorityNormal)
+
+	stats := p.Stats()
+	if !stats.Lagging {
+		t.Fatalf("expected publisher to be lagging, stats=%+v", stats)
+	}
+	if stats.Sampled != 2 {
+		t.Fatalf("expected 2 sampled out, got %d", stats.Sampled)
+	}
+	if stats.Published != 7 {
+		t.Fatalf("expected 7 published, got %d", stats.Published)
+	}
+}
+
+func TestStreamPublisher_DropOldest(t *testing.T) {
+	client, p := setupTestPublisher(t, config.StreamConfig{
+		MaxLag:    3,
+		LagPolicy: LagPolicyDropOldest,
+	})
+	publishN(t, p, 5, PriorityNormal)
+	p.Lag(context.Background())
+	publishN(t, p, 1, PriorityNormal)
+
+	n, err := client.XLen(context.Background(), "test:stream").Result()
+	if err != nil {
+		t.Fatalf("xlen failed: %v", err)
+	}
+	if n > 3 {
+		t.Fatalf("expected stream trimmed to max lag 3, got %d", n)
+	}
+	if p.Stats().Trimmed == 0 {
+		t.Fatalf("expected trimmed count to be recorded")
+	}
+}
+
+func TestStreamPublisher_BlockTimesOut(t *testing.T) {
+	_, p := setupTestPublisher(t, config.StreamConfig{
+		MaxLag:           1,
+		LagPolicy:        LagPolicyBlock,
+		BlockTimeout:     20 * time.Millisecond,
+		LagCheckInterval: 5 * time.Millisecond,
+	})
+	publishN(t, p, 2, PriorityNormal)
+	p.Lag(context.Background())
+
+	_, err := p.Publish(context.Background(), map[string]interface{}{"i": "x"}, PriorityNormal)
+	if !errors.Is(err, ErrBackpressure) {
+		t.Fatalf("expected ErrBackpressure, got %v", err)
+	}
+	if p.Stats().Rejected != 1 {
+		t.Fatalf("expected 1 rejected message, got %d", p.Stats().Rejected)
+	}
+}
+
+// 指标日志包含发布统计和最新积压
+func TestStreamPublisher_Report(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	if err := CreateConsumerGroup(context.Background(), client, "test:stream", "test-group"); err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+
+	core, logs := observer.New(zap.InfoLevel)
+	p := NewStreamPublisher(client, "test:stream", config.StreamConfig{ReportInterval: 10 * time.Millisecond}, zap.New(core))
+	publishN(t, p, 3, PriorityNormal)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Report(ctx)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for logs.FilterMessage("Stream publisher metrics").Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	entries := logs.FilterMessage("Stream publisher metrics").All()
+	if len(entries) == 0 {
+		t.Fatalf("expected metrics log")
+	}
+	fields := entries[0].ContextMap()
+	if fields["stream"] != "test:stream" || fields["published"] != int64(3) || fields["lag"] != int64(3) {
+		t.Fatalf("unexpected metrics fields: %v", fields)
+	}
+
+	// 未配置输出间隔时直接返回
+	NewStreamPublisher(client, "test:stream", config.StreamConfig{}, nil).Report(context.Background())
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"owl-common/config"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -14,11 +16,61 @@ type StreamMessage struct {
 	Stream   string
 	ID       string
 	Values   map[string]interface{}
+
+	Deliveries int64 // 投递次数（由 ReliableConsumer 填充）
 }
 
 // PublishToStream 发布消息到 Redis Streams
 func PublishToStream(ctx context.Context, client *redis.Client, stream string, values map[string]interface{}) (string, error) {
-	// 将 values 转换为 Redis Streams 格式
+	return PublishToStreamWithRetention(ctx, client, stream, values, config.StreamConfig{})
+}
+
+// PublishToStreamWithRetention 发布消息到 Redis Streams，并按保留策略近似裁剪（MAXLEN ~ / MINID ~）
+func PublishToStreamWithRetention(ctx context.Context, client *redis.Client, stream string, values map[string]interface{}, retention config.StreamConfig) (string, error) {
+	streamValues, err := toStreamValues(values)
+	if err != nil {
+		return "", err
+	}
+
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: streamValues,
+	}
+	// XADD 只能携带一种裁剪方式：优先 MAXLEN，同时配置 MINID 时再单独 XTRIM
+	minID := retentionMinID(retention)
+	if retention.MaxLen > 0 {
+		args.MaxLen = retention.MaxLen
+		args.Approx = true
+	} else if minID != "" {
+		args.MinID = minID
+		args.Approx = true
+	}
+
+	// 使用 XADD 命令添加消息
+	id, err := client.XAdd(ctx, args).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if retention.MaxLen > 0 && minID != "" {
+		if err := client.XTrimMinIDApprox(ctx, stream, minID, 0).Err(); err != nil {
+			return id, fmt.Errorf("failed to trim stream %s by minid: %w", stream, err)
+		}
+	}
+
+	return id, nil
+}
+
+// retentionMinID 计算 MINID 裁剪边界（<毫秒时间戳>-0）
+func retentionMinID(retention config.StreamConfig) string {
+	if retention.MinIDAge <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d-0", time.Now().Add(-retention.MinIDAge).UnixMilli())
+}
+
+// toStreamValues 将 values 转换为 Redis Streams 格式（所有值转为字符串）
+func toStreamValues(values map[string]interface{}) (map[string]interface{}, error) {
 	streamValues := make(map[string]interface{})
 	for k, v := range values {
 		// 将值转换为字符串
@@ -48,20 +100,13 @@ func PublishToStream(ctx context.Context, client *redis.Client, stream string, v
 			// 尝试 JSON 序列化
 			jsonBytes, err := json.Marshal(v)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 			strValue = string(jsonBytes)
 		}
 		streamValues[k] = strValue
 	}
-
-	// 使用 XADD 命令添加消息
-	id, err := client.XAdd(ctx, &redis.XAddArgs{
-		Stream: stream,
-		Values: streamValues,
-	}).Result()
-	
-	return id, err
+	return streamValues, nil
 }
 
 // PublishJSONToStream 发布 JSON 消息到 Redis Streams
@@ -113,35 +158,21 @@ func ReadFromStream(ctx context.Context, client *redis.Client, stream string, co
 
 // CreateConsumerGroup 创建消费者组
 func CreateConsumerGroup(ctx context.Context, client *redis.Client, stream string, groupName string) error {
-	// 尝试创建消费者组，如果已存在则忽略错误
-	// 注意：redis/v8 的 XGroupCreate 不支持 MkStream 参数
-	// 如果 stream 不存在，先创建 stream（通过发送一条临时消息）
-	err := client.XGroupCreate(ctx, stream, groupName, "0").Err()
-	
+	// 使用 MKSTREAM：stream 不存在时自动创建空流
+	err := client.XGroupCreateMkStream(ctx, stream, groupName, "0").Err()
+
 	// 如果错误是 "BUSYGROUP"，说明组已存在，这是正常的
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		// 如果 stream 不存在，尝试创建（通过发送一条消息）
-		if err.Error() == "NOGROUP" || err.Error() == "no such key" {
-			// Stream 不存在，先创建一个临时消息来创建 stream
-			msgID, createErr := client.XAdd(ctx, &redis.XAddArgs{
-				Stream: stream,
-				Values: map[string]interface{}{"init": "true"},
-			}).Result()
-			if createErr != nil {
-				return fmt.Errorf("failed to create stream: %w", createErr)
-			}
-			// 删除临时消息
-			client.XDel(ctx, stream, msgID)
-			// 再次尝试创建消费者组
-			err = client.XGroupCreate(ctx, stream, groupName, "0").Err()
-			if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-				return err
-			}
-		} else {
-			return err
-		}
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
 	}
-	
+
 	return nil
 }
 
+// AckMessages 确认消息（XACK）
+func AckMessages(ctx context.Context, client *redis.Client, stream string, groupName string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return client.XAck(ctx, stream, groupName, ids...).Err()
+}
@@ -0,0 +1,289 @@
+// Package workerpool 按 key 分片的有界工作池
+//
+// 用于采集服务处理 MQTT 消息：paho 回调只负责投递任务，数据库查询和 XADD 在工作协程中执行。
+// 相同 key（设备标识）总是落在同一个分片，保证单设备消息按序处理；
+// 不同设备分散到多个分片，单个慢查询不会阻塞所有设备。
+//
+// MQTT 消息在任务处理完成后才确认（见 SubmitWithDrop）：队列中的消息在进程退出前未处理时不确认，
+// 由 broker 在重连后重新投递；因队列满被丢弃的消息会确认（避免占用 broker 的 in-flight 窗口），
+// 丢弃数计入 Stats.Dropped 并随 "Worker pool metrics" 日志定期输出。
+package workerpool
+
+import (
+	"context"
+	"hash/fnv"
+	"owl-common/config"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 队列满时的策略
+const (
+	OverflowBlock      = "block"       // 阻塞投递方（背压传导到 MQTT broker），超过 BlockTimeout 后丢弃
+	OverflowDropNewest = "drop_newest" // 丢弃新任务
+	OverflowDropOldest = "drop_oldest" // 丢弃分片中最旧的任务，为新任务腾出位置
+)
+
+// Job 工作任务
+type Job func()
+
+// task 排队的任务，onDrop 在任务因队列满被丢弃时调用
+type task struct {
+	run    Job
+	onDrop func()
+}
+
+// Stats 工作池指标
+type Stats struct {
+	Workers       int
+	QueueCapacity int   // 总队列容量（分片数 × 单分片容量）
+	QueueDepth    int   // 当前排队任务数
+	MaxShardDepth int   // 最繁忙分片的排队任务数
+	Submitted     int64 // 投递成功的任务数
+	Processed     int64 // 已处理的任务数
+	Dropped       int64 // 因队列满被丢弃的任务数
+}
+
+// Pool 按 key 分片的有界工作池
+type Pool struct {
+	name   string
+	cfg    config.WorkerPoolConfig
+	shards []chan task
+	logger *zap.Logger
+
+	done     chan struct{}
+	stopOnce sync.Once
+	mu       sync.RWMutex
+	closed   bool
+	wg       sync.WaitGroup
+
+	submitted int64
+	processed int64
+	dropped   int64
+}
+
+// New 创建工作池
+func New(name string, cfg config.WorkerPoolConfig, logger *zap.Logger) *Pool {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 8
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	switch cfg.Overflow {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+	default:
+		cfg.Overflow = OverflowBlock
+	}
+
+	shards := make([]chan task, cfg.Workers)
+	for i := range shards {
+		shards[i] = make(chan task, cfg.QueueSize)
+	}
+
+	return &Pool{
+		name:   name,
+		cfg:    cfg,
+		shards: shards,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start 启动工作协程（以及指标日志协程）
+func (p *Pool) Start(ctx context.Context) {
+	for i, shard := range p.shards {
+		p.wg.Add(1)
+		go p.worker(i, shard)
+	}
+	if p.cfg.ReportInterval > 0 {
+		go p.report(ctx)
+	}
+
+	p.logger.Info("Worker pool started",
+		zap.String("pool", p.name),
+		zap.Int("workers", p.cfg.Workers),
+		zap.Int("queue_size", p.cfg.QueueSize),
+		zap.String("overflow", p.cfg.Overflow),
+	)
+}
+
+// Stop 停止接收新任务，并等待已排队的任务处理完成
+func (p *Pool) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+
+		p.mu.Lock()
+		p.closed = true
+		for _, shard := range p.shards {
+			close(shard)
+		}
+		p.mu.Unlock()
+
+		p.wg.Wait()
+		p.logger.Info("Worker pool stopped", zap.String("pool", p.name))
+	})
+}
+
+// Submit 按 key 投递任务，返回是否已入队
+func (p *Pool) Submit(key string, job Job) bool {
+	return p.SubmitWithDrop(key, job, nil)
+}
+
+// SubmitWithDrop 按 key 投递任务，任务因队列满被丢弃时调用 onDrop（包括 drop_oldest 挤出的已排队任务）
+// 工作池已停止时返回 false 且不调用 onDrop
+func (p *Pool) SubmitWithDrop(key string, job Job, onDrop func()) bool {
+	t := task{run: job, onDrop: onDrop}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+
+	shard := p.shards[p.shardFor(key)]
+
+	// 队列未满时直接入队
+	select {
+	case shard <- t:
+		atomic.AddInt64(&p.submitted, 1)
+		return true
+	default:
+	}
+
+	switch p.cfg.Overflow {
+	case OverflowDropNewest:
+		p.drop(key, t)
+		return false
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case shard <- t:
+				atomic.AddInt64(&p.submitted, 1)
+				return true
+			default:
+			}
+			select {
+			case old := <-shard:
+				p.drop(key, old)
+			default:
+			}
+		}
+
+	default: // OverflowBlock
+		var timeout <-chan time.Time
+		if p.cfg.BlockTimeout > 0 {
+			timer := time.NewTimer(p.cfg.BlockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case shard <- t:
+			atomic.AddInt64(&p.submitted, 1)
+			return true
+		case <-timeout:
+			p.drop(key, t)
+			return false
+		case <-p.done:
+			return false
+		}
+	}
+}
+
+// Stats 获取指标快照
+func (p *Pool) Stats() Stats {
+	stats := Stats{
+		Workers:       p.cfg.Workers,
+		QueueCapacity: p.cfg.Workers * p.cfg.QueueSize,
+		Submitted:     atomic.LoadInt64(&p.submitted),
+		Processed:     atomic.LoadInt64(&p.processed),
+		Dropped:       atomic.LoadInt64(&p.dropped),
+	}
+	for _, shard := range p.shards {
+		depth := len(shard)
+		stats.QueueDepth += depth
+		if depth > stats.MaxShardDepth {
+			stats.MaxShardDepth = depth
+		}
+	}
+	return stats
+}
+
+// shardFor 根据 key 的 FNV 哈希选择分片
+func (p *Pool) shardFor(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.shards)))
+}
+
+func (p *Pool) drop(key string, t task) {
+	if t.onDrop != nil {
+		t.onDrop()
+	}
+	dropped := atomic.AddInt64(&p.dropped, 1)
+	// 避免高负载时日志刷屏：首次及每 1000 次记录一次
+	if dropped == 1 || dropped%1000 == 0 {
+		p.logger.Warn("Worker pool queue full, dropping task",
+			zap.String("pool", p.name),
+			zap.String("key", key),
+			zap.String("overflow", p.cfg.Overflow),
+			zap.Int64("dropped_total", dropped),
+		)
+	}
+}
+
+// worker 分片工作协程：按顺序处理分片内的任务
+func (p *Pool) worker(index int, shard chan task) {
+	defer p.wg.Done()
+	for t := range shard {
+		p.run(index, t.run)
+		atomic.AddInt64(&p.processed, 1)
+	}
+}
+
+// run 执行任务，panic 不影响工作协程
+func (p *Pool) run(index int, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.logger.Error("Worker pool task panicked",
+				zap.String("pool", p.name),
+				zap.Int("shard", index),
+				zap.Any("panic", r),
+			)
+		}
+	}()
+	job()
+}
+
+// report 定期输出队列深度和丢弃数
+func (p *Pool) report(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.done:
+			return
+		case <-ticker.C:
+			stats := p.Stats()
+			p.logger.Info("Worker pool metrics",
+				zap.String("pool", p.name),
+				zap.Int("queue_depth", stats.QueueDepth),
+				zap.Int("max_shard_depth", stats.MaxShardDepth),
+				zap.Int("queue_capacity", stats.QueueCapacity),
+				zap.Int64("submitted", stats.Submitted),
+				zap.Int64("processed", stats.Processed),
+				zap.Int64("dropped", stats.Dropped),
+			)
+		}
+	}
+}
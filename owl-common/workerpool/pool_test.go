@@ -0,0 +1,159 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"owl-common/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool_PreservesPerKeyOrder(t *testing.T) {
+	p := New("test", config.WorkerPoolConfig{Workers: 4, QueueSize: 100}, nil)
+	p.Start(context.Background())
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	for i := 0; i < 50; i++ {
+		for _, key := range []string{"dev-1", "dev-2", "dev-3"} {
+			key, i := key, i
+			if !p.Submit(key, func() {
+				mu.Lock()
+				got[key] = append(got[key], i)
+				mu.Unlock()
+			}) {
+				t.Fatalf("submit %s/%d rejected", key, i)
+			}
+		}
+	}
+	p.Stop()
+
+	for key, seq := range got {
+		if len(seq) != 50 {
+			t.Fatalf("%s: expected 50 jobs, got %d", key, len(seq))
+		}
+		for i, v := range seq {
+			if v != i {
+				t.Fatalf("%s: out of order at %d: %v", key, i, seq)
+			}
+		}
+	}
+	if stats := p.Stats(); stats.Processed != 150 || stats.Dropped != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// blockedPool 创建单分片工作池，并用一个阻塞任务占住工作协程
+func blockedPool(t *testing.T, overflow string) (*Pool, chan struct{}) {
+	t.Helper()
+	p := New("test", config.WorkerPoolConfig{
+		Workers:      1,
+		QueueSize:    2,
+		Overflow:     overflow,
+		BlockTimeout: 10 * time.Millisecond,
+	}, nil)
+	p.Start(context.Background())
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	p.Submit("dev", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	return p, release
+}
+
+func TestPool_OverflowPolicies(t *testing.T) {
+	cases := []struct {
+		overflow    string
+		wantRan     []string
+		wantDropped int64
+	}{
+		{OverflowDropNewest, []string{"job-0", "job-1"}, 2},
+		{OverflowDropOldest, []string{"job-2", "job-3"}, 2},
+		{OverflowBlock, []string{"job-0", "job-1"}, 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.overflow, func(t *testing.T) {
+			p, release := blockedPool(t, tc.overflow)
+
+			var mu sync.Mutex
+			var ran []string
+			for i := 0; i < 4; i++ {
+				name := fmt.Sprintf("job-%d", i)
+				p.Submit("dev", func() {
+					mu.Lock()
+					ran = append(ran, name)
+					mu.Unlock()
+				})
+			}
+
+			stats := p.Stats()
+			if stats.QueueDepth != 2 || stats.Dropped != tc.wantDropped {
+				t.Fatalf("unexpected stats before release: %+v", stats)
+			}
+
+			close(release)
+			p.Stop()
+
+			if fmt.Sprint(ran) != fmt.Sprint(tc.wantRan) {
+				t.Fatalf("expected %v to run, got %v", tc.wantRan, ran)
+			}
+		})
+	}
+}
+
+// 被丢弃的任务（包括 drop_oldest 挤出的排队任务）调用 onDrop
+func TestPool_SubmitWithDrop(t *testing.T) {
+	cases := []struct {
+		overflow    string
+		wantDropped []string
+	}{
+		{OverflowDropNewest, []string{"job-2", "job-3"}},
+		{OverflowDropOldest, []string{"job-0", "job-1"}},
+		{OverflowBlock, []string{"job-2", "job-3"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.overflow, func(t *testing.T) {
+			p, release := blockedPool(t, tc.overflow)
+
+			var mu sync.Mutex
+			var dropped []string
+			for i := 0; i < 4; i++ {
+				name := fmt.Sprintf("job-%d", i)
+				p.SubmitWithDrop("dev", func() {}, func() {
+					mu.Lock()
+					dropped = append(dropped, name)
+					mu.Unlock()
+				})
+			}
+			close(release)
+			p.Stop()
+
+			if fmt.Sprint(dropped) != fmt.Sprint(tc.wantDropped) {
+				t.Fatalf("expected %v to be dropped, got %v", tc.wantDropped, dropped)
+			}
+		})
+	}
+
+	// 工作池已停止时不调用 onDrop（消息不确认，由 broker 重新投递）
+	p := New("test", config.WorkerPoolConfig{Workers: 1, QueueSize: 1}, nil)
+	p.Start(context.Background())
+	p.Stop()
+	if p.SubmitWithDrop("dev", func() {}, func() { t.Fatalf("onDrop called after stop") }) {
+		t.Fatalf("expected submit after stop to be rejected")
+	}
+}
+
+func TestPool_SubmitAfterStop(t *testing.T) {
+	p := New("test", config.WorkerPoolConfig{Workers: 1, QueueSize: 1}, nil)
+	p.Start(context.Background())
+	p.Stop()
+	if p.Submit("dev", func() {}) {
+		t.Fatalf("expected submit after stop to be rejected")
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"owl-common/config"
+	"strings"
 )
 
 // Config 报警服务配置
@@ -29,6 +30,23 @@ type Config struct {
 		Evaluation struct {
 			BatchSize int // 批量评估卡片数量，默认 10
 		}
+		
+		// 设备在线状态事件（OfflineAlarm）
+		Presence struct {
+			Stream        string // 在线状态事件流，如 "device:presence:stream"
+			ConsumerGroup string // 消费者组前缀（实际组名附加 ":{tenant_id}"）
+			ConsumerName  string // 消费者名称
+		}
+		
+		// 设备上报的报警（如 Sleepace alarmNotify）
+		DeviceAlarm struct {
+			Stream           string            // 设备报警事件流，如 "device:alarm:stream"
+			ConsumerGroup    string            // 消费者组前缀（实际组名附加 ":{tenant_id}"）
+			ConsumerName     string            // 消费者名称
+			TypeMap          map[string]string // 厂家报警类型 -> event_type，键为 "{device_type}/{vendor_type}"
+			DefaultEventType string            // 未配置映射的厂家报警类型使用的 event_type
+			DefaultLevel     string            // 报警策略未配置该事件类型时使用的级别
+		}
 	}
 	
 	Log struct {
@@ -64,12 +82,45 @@ func Load() (*Config, error) {
 	cfg.Alarm.PollInterval = 5 // 5秒轮询一次
 	cfg.Alarm.Evaluation.BatchSize = 10
 	
+	cfg.Alarm.Presence.Stream = getEnv("PRESENCE_STREAM", "device:presence:stream")
+	cfg.Alarm.Presence.ConsumerGroup = getEnv("PRESENCE_CONSUMER_GROUP", "alarm-presence")
+	cfg.Alarm.Presence.ConsumerName = getEnv("PRESENCE_CONSUMER_NAME", "wisefido-alarm")
+	
+	cfg.Alarm.DeviceAlarm.Stream = getEnv("DEVICE_ALARM_STREAM", "device:alarm:stream")
+	cfg.Alarm.DeviceAlarm.ConsumerGroup = getEnv("DEVICE_ALARM_CONSUMER_GROUP", "alarm-device")
+	cfg.Alarm.DeviceAlarm.ConsumerName = getEnv("DEVICE_ALARM_CONSUMER_NAME", "wisefido-alarm")
+	cfg.Alarm.DeviceAlarm.TypeMap = parseTypeMap(getEnv("DEVICE_ALARM_TYPE_MAP", defaultDeviceAlarmTypeMap))
+	cfg.Alarm.DeviceAlarm.DefaultEventType = getEnv("DEVICE_ALARM_DEFAULT_EVENT_TYPE", "DeviceAlarm")
+	cfg.Alarm.DeviceAlarm.DefaultLevel = getEnv("DEVICE_ALARM_DEFAULT_LEVEL", "WARNING")
+	
 	cfg.Log.Level = getEnv("LOG_LEVEL", "info")
 	cfg.Log.Format = getEnv("LOG_FORMAT", "json")
 	
 	return cfg, nil
 }
 
+// defaultDeviceAlarmTypeMap 默认厂家报警类型映射（DEVICE_ALARM_TYPE_MAP 格式）
+const defaultDeviceAlarmTypeMap = "Sleepace/leaveBed=SleepPad_LeftBed," +
+	"Sleepace/apnea=SleepPad_ApneaHypopnea," +
+	"Sleepace/heartRate=SleepPad_AbnormalHeartRate," +
+	"Sleepace/breathRate=SleepPad_AbnormalRespiratoryRate," +
+	"Sleepace/lowBattery=LowBattery," +
+	"Sleepace/deviceFault=DeviceFailure"
+
+// parseTypeMap 解析厂家报警类型映射，格式 "Sleepace/leaveBed=SleepPad_LeftBed,..."（忽略格式错误的条目）
+func parseTypeMap(value string) map[string]string {
+	typeMap := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		key, eventType, ok := strings.Cut(strings.TrimSpace(item), "=")
+		key, eventType = strings.TrimSpace(key), strings.TrimSpace(eventType)
+		if !ok || key == "" || eventType == "" {
+			continue
+		}
+		typeMap[key] = eventType
+	}
+	return typeMap
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -38,6 +38,11 @@ func TestLoad_DefaultValues(t *testing.T) {
 	assert.Equal(t, 5, cfg.Alarm.PollInterval)
 	assert.Equal(t, 10, cfg.Alarm.Evaluation.BatchSize)
 
+	assert.Equal(t, "device:alarm:stream", cfg.Alarm.DeviceAlarm.Stream)
+	assert.Equal(t, "SleepPad_LeftBed", cfg.Alarm.DeviceAlarm.TypeMap["Sleepace/leaveBed"])
+	assert.Equal(t, "DeviceAlarm", cfg.Alarm.DeviceAlarm.DefaultEventType)
+	assert.Equal(t, "WARNING", cfg.Alarm.DeviceAlarm.DefaultLevel)
+
 	assert.Equal(t, "info", cfg.Log.Level)
 	assert.Equal(t, "json", cfg.Log.Format)
 }
@@ -88,3 +93,11 @@ func TestGetEnv(t *testing.T) {
 	// 清理
 	os.Unsetenv("TEST_KEY")
 }
+
+func TestParseTypeMap(t *testing.T) {
+	typeMap := parseTypeMap(" Sleepace/leaveBed = SleepPad_LeftBed ,invalid,Sleepace/apnea=,Sleepace/fall=Fall")
+	assert.Equal(t, map[string]string{
+		"Sleepace/leaveBed": "SleepPad_LeftBed",
+		"Sleepace/fall":     "Fall",
+	}, typeMap)
+}
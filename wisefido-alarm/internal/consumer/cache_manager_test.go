@@ -79,6 +79,39 @@ func TestCacheManager_GetRealtimeData_NotFound(t *testing.T) {
 	assert.Contains(t, err.Error(), "realtime data not found")
 }
 
+func TestCacheManager_GetRealtimeData_PerBed(t *testing.T) {
+	_, _, cacheManager := setupTestRedis(t)
+
+	cardID := "card-123"
+	payload := `{"heart":62,"heart_source":"Sleepace","person_count":0,"timestamp":1700000000,
+		"beds":[{"bed_id":"bed-1","resident_id":"resident-1","heart":62,"heart_source":"Sleepace","person_count":0,"timestamp":1700000000},
+		        {"bed_id":"bed-2","resident_id":"resident-2","heart":80,"heart_source":"Sleepace","person_count":0,"timestamp":1700000000}]}`
+	ctx := context.Background()
+	require.NoError(t, cacheManager.redisClient.Set(ctx, "vital-focus:card:"+cardID+":realtime", payload, time.Minute).Err())
+
+	data, err := cacheManager.GetRealtimeData(cardID)
+	require.NoError(t, err)
+
+	beds := data.BedsFor(stringPtr("bed-1"))
+	require.Len(t, beds, 2)
+	assert.Equal(t, "bed-2", beds[1].BedID)
+	assert.Equal(t, "resident-2", *beds[1].ResidentID)
+	assert.Equal(t, intPtr(80), beds[1].Heart)
+}
+
+func TestRealtimeData_BedsFor_LegacyPayload(t *testing.T) {
+	data := &models.RealtimeData{Heart: intPtr(72), PersonCount: 1}
+
+	beds := data.BedsFor(stringPtr("bed-1"))
+	require.Len(t, beds, 1)
+	assert.Equal(t, "bed-1", beds[0].BedID)
+	assert.Equal(t, intPtr(72), beds[0].Heart)
+	assert.Nil(t, beds[0].ResidentID)
+
+	// Location 卡片没有床位
+	assert.Empty(t, data.BedsFor(nil))
+}
+
 func TestCacheManager_UpdateAlarmCache_Success(t *testing.T) {
 	_, _, cacheManager := setupTestRedis(t)
 
@@ -184,3 +217,7 @@ func intPtr(i int) *int {
 func int64Ptr(i int64) *int64 {
 	return &i
 }
+
+func stringPtr(s string) *string {
+	return &s
+}
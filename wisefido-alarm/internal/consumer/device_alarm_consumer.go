@@ -0,0 +1,106 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wisefido-alarm/internal/config"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+)
+
+// DeviceAlarmConsumer 设备报警事件消费者（消费 device:alarm:stream）
+type DeviceAlarmConsumer struct {
+	config   *config.Config
+	reliable *rediscommon.ReliableConsumer
+	logger   *zap.Logger
+	tenantID string
+}
+
+// NewDeviceAlarmConsumer 创建设备报警事件消费者
+// 与 PresenceConsumer 相同，每个租户使用独立的消费者组，只处理本租户的设备
+func NewDeviceAlarmConsumer(
+	cfg *config.Config,
+	redisClient *redis.Client,
+	logger *zap.Logger,
+	tenantID string,
+) *DeviceAlarmConsumer {
+	return &DeviceAlarmConsumer{
+		config: cfg,
+		reliable: rediscommon.NewReliableConsumer(redisClient, rediscommon.ConsumerOptions{
+			Streams:  []string{cfg.Alarm.DeviceAlarm.Stream},
+			Group:    cfg.Alarm.DeviceAlarm.ConsumerGroup + ":" + tenantID,
+			Consumer: cfg.Alarm.DeviceAlarm.ConsumerName,
+		}, logger),
+		logger:   logger,
+		tenantID: tenantID,
+	}
+}
+
+// Start 启动消费者（阻塞直到 ctx 结束）
+func (c *DeviceAlarmConsumer) Start(ctx context.Context, evaluator DeviceAlarmEvaluator) error {
+	if err := c.reliable.Setup(ctx); err != nil {
+		return err
+	}
+
+	c.logger.Info("Device alarm consumer started",
+		zap.String("tenant_id", c.tenantID),
+		zap.String("stream", c.config.Alarm.DeviceAlarm.Stream),
+	)
+
+	handler := func(ctx context.Context, msg rediscommon.StreamMessage) error {
+		env, err := rediscommon.Decode[events.DeviceAlarm](msg, events.DeviceAlarmSchema)
+		if err != nil {
+			return rediscommon.Permanent(fmt.Errorf("failed to decode device alarm event: %w", err))
+		}
+		if env.TenantID != c.tenantID {
+			return nil
+		}
+		if err := evaluator.EvaluateDeviceAlarm(ctx, c.tenantID, env.Payload, env.EventTime); err != nil {
+			c.logger.Error("Failed to evaluate device alarm event",
+				zap.String("message_id", msg.ID),
+				zap.String("device_id", env.Payload.DeviceID),
+				zap.String("vendor_type", env.Payload.VendorType),
+				zap.Error(err),
+			)
+			return err
+		}
+		return nil
+	}
+
+	backoff := time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("Device alarm consumer stopped")
+			return nil
+		default:
+		}
+
+		if err := c.reliable.Poll(ctx, handler); err != nil {
+			c.logger.Error("Failed to consume device alarm events",
+				zap.Error(err),
+				zap.Duration("backoff", backoff),
+			)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// DeviceAlarmEvaluator 设备报警评估接口
+type DeviceAlarmEvaluator interface {
+	// EvaluateDeviceAlarm 处理设备上报的报警（触发时创建报警事件，解除时自动关闭对应报警事件）
+	EvaluateDeviceAlarm(ctx context.Context, tenantID string, alarm events.DeviceAlarm, eventTime time.Time) error
+}
@@ -0,0 +1,105 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wisefido-alarm/internal/config"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+)
+
+// PresenceConsumer 设备在线状态事件消费者（消费 device:presence:stream）
+type PresenceConsumer struct {
+	config   *config.Config
+	reliable *rediscommon.ReliableConsumer
+	logger   *zap.Logger
+	tenantID string
+}
+
+// NewPresenceConsumer 创建设备在线状态事件消费者
+// 每个租户的报警服务使用独立的消费者组，都能收到全部事件，只处理本租户的设备
+func NewPresenceConsumer(
+	cfg *config.Config,
+	redisClient *redis.Client,
+	logger *zap.Logger,
+	tenantID string,
+) *PresenceConsumer {
+	return &PresenceConsumer{
+		config: cfg,
+		reliable: rediscommon.NewReliableConsumer(redisClient, rediscommon.ConsumerOptions{
+			Streams:  []string{cfg.Alarm.Presence.Stream},
+			Group:    cfg.Alarm.Presence.ConsumerGroup + ":" + tenantID,
+			Consumer: cfg.Alarm.Presence.ConsumerName,
+		}, logger),
+		logger:   logger,
+		tenantID: tenantID,
+	}
+}
+
+// Start 启动消费者（阻塞直到 ctx 结束）
+func (c *PresenceConsumer) Start(ctx context.Context, evaluator PresenceEvaluator) error {
+	if err := c.reliable.Setup(ctx); err != nil {
+		return err
+	}
+
+	c.logger.Info("Presence consumer started",
+		zap.String("tenant_id", c.tenantID),
+		zap.String("stream", c.config.Alarm.Presence.Stream),
+	)
+
+	handler := func(ctx context.Context, msg rediscommon.StreamMessage) error {
+		env, err := rediscommon.Decode[events.DevicePresence](msg, events.PresenceSchema)
+		if err != nil {
+			return rediscommon.Permanent(fmt.Errorf("failed to decode presence event: %w", err))
+		}
+		if env.TenantID != c.tenantID {
+			return nil
+		}
+		if err := evaluator.EvaluatePresence(ctx, c.tenantID, env.Payload); err != nil {
+			c.logger.Error("Failed to evaluate presence event",
+				zap.String("message_id", msg.ID),
+				zap.String("device_id", env.Payload.DeviceID),
+				zap.Error(err),
+			)
+			return err
+		}
+		return nil
+	}
+
+	backoff := time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("Presence consumer stopped")
+			return nil
+		default:
+		}
+
+		if err := c.reliable.Poll(ctx, handler); err != nil {
+			c.logger.Error("Failed to consume presence events",
+				zap.Error(err),
+				zap.Duration("backoff", backoff),
+			)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// PresenceEvaluator 设备在线状态评估接口
+type PresenceEvaluator interface {
+	// EvaluatePresence 处理设备在线状态变化（离线时产生 OfflineAlarm，恢复在线时自动解除）
+	EvaluatePresence(ctx context.Context, tenantID string, presence events.DevicePresence) error
+}
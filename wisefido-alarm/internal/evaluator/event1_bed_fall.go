@@ -38,28 +38,39 @@ func (e *Event1Evaluator) Evaluate(tenantID string, card repository.CardInfo, re
 		return nil, nil
 	}
 
-	// 2. 检查是否有离床事件（通过 bed_status 判断）
+	// 2. 按床位做基础检查（完整逻辑实现前只记录调试日志，不产生报警）
+	beds := realtimeData.BedsFor(card.BedID)
+	for i := range beds {
+		e.evaluateBed(card, &beds[i])
+	}
+
+	return nil, nil
+}
+
+// evaluateBed 单张床位的基础检查（简化实现，只记录调试日志）
+func (e *Event1Evaluator) evaluateBed(card repository.CardInfo, bed *models.BedRealtime) {
+	// 检查是否有离床事件（通过 bed_status 判断）
 	// 注意：bed_status 来自 Sleepace，如果为 "off_bed" 表示离床
-	if realtimeData.BedStatus == nil {
-		return nil, nil
+	if bed.BedStatus == nil {
+		return
 	}
 
-	// 3. 检查是否有 Sleepace 设备（需要 HR/RR 数据）
+	// 检查是否有 Sleepace 设备（需要 HR/RR 数据）
 	// 如果 Sleepace 有 HR/RR，说明人可能回到床上，退出
-	if realtimeData.Heart != nil || realtimeData.Breath != nil {
+	if bed.Heart != nil || bed.Breath != nil {
 		// 有 HR/RR，退出事件1
-		return nil, nil
+		return
 	}
 
-	// TODO: 实现完整的状态管理和定时器逻辑
-	// 当前仅做基础检查，返回空列表
+	// TODO: 实现完整的状态管理和定时器逻辑（状态需按床位保存，报警关联床位住户）
+	// 当前仅做基础检查
 
 	e.evaluator.logger.Debug("Event1 evaluation (simplified)",
 		zap.String("card_id", card.CardID),
-		zap.String("bed_status", *realtimeData.BedStatus),
+		zap.String("bed_id", bed.BedID),
+		zap.Stringp("resident_id", bed.ResidentID),
+		zap.String("bed_status", *bed.BedStatus),
 	)
-
-	return nil, nil
 }
 
 // checkExitConditions 检查退出条件
@@ -32,25 +32,35 @@ func (e *Event2Evaluator) Evaluate(tenantID string, card repository.CardInfo, re
 		return nil, nil
 	}
 
-	// 2. 检查是否有 Sleepace 设备（需要 HR/RR 数据）
-	if realtimeData.Heart == nil && realtimeData.Breath == nil {
+	// 2. 按床位做基础检查（完整逻辑实现前只记录调试日志，不产生报警）
+	beds := realtimeData.BedsFor(card.BedID)
+	for i := range beds {
+		e.evaluateBed(card, &beds[i])
+	}
+
+	return nil, nil
+}
+
+// evaluateBed 单张床位的基础检查（简化实现，只记录调试日志）
+func (e *Event2Evaluator) evaluateBed(card repository.CardInfo, bed *models.BedRealtime) {
+	// 检查是否有 Sleepace 设备（需要 HR/RR 数据）
+	if bed.Heart == nil && bed.Breath == nil {
 		// 没有 HR/RR 数据，无法判断可靠性
-		return nil, nil
+		return
 	}
 
-	// 3. 检查床上是否绑定了 Radar 设备
-	// TODO: 需要查询卡片绑定的设备，检查是否有 Radar 设备
+	// TODO: 按 checkRadarOnBed 检查该床上是否绑定了 Radar 设备，并按分支判断
 	// 当前简化处理，暂时不评估
 
 	e.evaluator.logger.Debug("Event2 evaluation (simplified)",
 		zap.String("card_id", card.CardID),
+		zap.String("bed_id", bed.BedID),
+		zap.Stringp("resident_id", bed.ResidentID),
 	)
-
-	return nil, nil
 }
 
-// checkRadarOnBed 检查床上是否绑定了 Radar 设备
-func (e *Event2Evaluator) checkRadarOnBed(ctx context.Context, card repository.CardInfo) (bool, error) {
+// checkRadarOnBed 检查指定床位上是否绑定了 Radar 设备（供完整的事件2逻辑使用，当前未调用）
+func (e *Event2Evaluator) checkRadarOnBed(ctx context.Context, card repository.CardInfo, bedID string) (bool, error) {
 	// 获取卡片绑定的设备
 	devices, err := e.evaluator.cardRepo.GetCardDevices(card.CardID)
 	if err != nil {
@@ -60,8 +70,8 @@ func (e *Event2Evaluator) checkRadarOnBed(ctx context.Context, card repository.C
 	// 检查是否有 Radar 设备
 	for _, device := range devices {
 		if device.DeviceType == "Radar" {
-			// 检查是否绑定到床（bed_id 有效）
-			if device.BedID != nil && *device.BedID != "" {
+			// 检查是否绑定到该床（多床卡片上其他床的 Radar 不算）
+			if device.BedID != nil && *device.BedID == bedID {
 				return true, nil
 			}
 		}
@@ -0,0 +1,245 @@
+package evaluator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"wisefido-alarm/internal/models"
+	"wisefido-alarm/internal/repository"
+
+	"go.uber.org/zap"
+	"owl-common/events"
+)
+
+// alarmConfigDeviceTypes 设备类型 -> 报警配置中的设备类型键（alarm_cloud.device_alarms 第一层 key）
+var alarmConfigDeviceTypes = map[string]string{
+	"Sleepace": "SleepPad",
+}
+
+// deviceAlarmCategories 设备报警事件类型 -> 报警分类（未列出的为 "device"）
+var deviceAlarmCategories = map[string]string{
+	"Fall":                             "safety",
+	"SleepPad_LeftBed":                 "behavioral",
+	"SleepPad_ApneaHypopnea":           "clinical",
+	"SleepPad_AbnormalHeartRate":       "clinical",
+	"SleepPad_AbnormalRespiratoryRate": "clinical",
+}
+
+// EvaluateDeviceAlarm 处理设备上报的报警（实现 consumer.DeviceAlarmEvaluator 接口）
+// - trigger：厂家报警类型按配置映射为 event_type，按报警策略的级别创建报警事件（metadata.device_triggered = true）
+// - relieve：自动解除同一厂家报警 ID 对应的未处理报警事件
+func (e *Evaluator) EvaluateDeviceAlarm(ctx context.Context, tenantID string, alarm events.DeviceAlarm, eventTime time.Time) error {
+	eventType := e.deviceAlarmEventType(alarm)
+	switch alarm.Status {
+	case events.DeviceAlarmTrigger:
+		return e.raiseDeviceAlarm(ctx, tenantID, alarm, eventType, eventTime)
+	case events.DeviceAlarmRelieve:
+		return e.relieveDeviceAlarms(ctx, tenantID, alarm, eventType)
+	}
+	return nil
+}
+
+// deviceAlarmEventType 厂家报警类型 -> event_type（未配置映射时使用默认事件类型）
+func (e *Evaluator) deviceAlarmEventType(alarm events.DeviceAlarm) string {
+	cfg := e.config.Alarm.DeviceAlarm
+	if eventType, ok := cfg.TypeMap[alarm.DeviceType+"/"+alarm.VendorType]; ok {
+		return eventType
+	}
+	e.logger.Warn("Unmapped device alarm type",
+		zap.String("device_type", alarm.DeviceType),
+		zap.String("vendor_type", alarm.VendorType),
+		zap.String("event_type", cfg.DefaultEventType),
+	)
+	return cfg.DefaultEventType
+}
+
+// raiseDeviceAlarm 创建设备报警事件（同一厂家报警已有未处理事件时不重复创建）
+func (e *Evaluator) raiseDeviceAlarm(ctx context.Context, tenantID string, alarm events.DeviceAlarm, eventType string, eventTime time.Time) error {
+	level, err := e.deviceAlarmLevel(ctx, tenantID, alarm, eventType)
+	if err != nil {
+		return err
+	}
+	if !alarmLevels[level] {
+		e.logger.Debug("Device alarm disabled",
+			zap.String("tenant_id", tenantID),
+			zap.String("device_id", alarm.DeviceID),
+			zap.String("event_type", eventType),
+		)
+		return nil
+	}
+
+	active, err := e.activeDeviceAlarms(ctx, tenantID, alarm, eventType)
+	if err != nil {
+		return err
+	}
+	if len(active) > 0 {
+		return nil
+	}
+
+	category := deviceAlarmCategories[eventType]
+	if category == "" {
+		category = "device"
+	}
+	triggerData := &models.TriggerData{
+		EventType: eventType,
+		Source:    alarm.DeviceType,
+	}
+	metadata := map[string]interface{}{
+		"device_triggered": true,
+		"vendor_type":      alarm.VendorType,
+		"vendor_alarm_id":  alarm.VendorAlarmID,
+		"serial_number":    alarm.SerialNumber,
+	}
+	event, err := NewAlarmEventBuilder(tenantID, alarm.DeviceID).BuildAlarmEvent(
+		eventType,
+		category,
+		level,
+		triggerData,
+		metadata,
+	)
+	if err != nil {
+		return err
+	}
+	if !eventTime.IsZero() {
+		event.TriggeredAt = eventTime
+	}
+	if err := e.alarmEventsRepo.CreateAlarmEvent(ctx, tenantID, event); err != nil {
+		return err
+	}
+
+	e.logger.Info("Device alarm created",
+		zap.String("tenant_id", tenantID),
+		zap.String("device_id", alarm.DeviceID),
+		zap.String("event_type", eventType),
+		zap.String("vendor_alarm_id", alarm.VendorAlarmID),
+		zap.String("alarm_level", event.AlarmLevel),
+		zap.String("event_id", event.EventID),
+	)
+	return nil
+}
+
+// relieveDeviceAlarms 设备解除报警：自动解除对应的未处理报警事件
+func (e *Evaluator) relieveDeviceAlarms(ctx context.Context, tenantID string, alarm events.DeviceAlarm, eventType string) error {
+	active, err := e.activeDeviceAlarms(ctx, tenantID, alarm, eventType)
+	if err != nil {
+		return err
+	}
+	handTime := time.Now()
+	if alarm.RelieveTime != nil {
+		handTime = *alarm.RelieveTime
+	}
+	for _, event := range active {
+		updates := map[string]interface{}{
+			"alarm_status": "acknowledged",
+			"operation":    "auto_relieved",
+			"hand_time":    handTime,
+		}
+		if alarm.RelieveReason != "" {
+			updates["notes"] = alarm.RelieveReason
+		}
+		if err := e.alarmEventsRepo.UpdateAlarmEvent(ctx, tenantID, event.EventID, updates); err != nil {
+			return err
+		}
+		e.logger.Info("Device alarm auto relieved",
+			zap.String("tenant_id", tenantID),
+			zap.String("device_id", alarm.DeviceID),
+			zap.String("event_type", eventType),
+			zap.String("vendor_alarm_id", alarm.VendorAlarmID),
+			zap.String("event_id", event.EventID),
+		)
+	}
+	return nil
+}
+
+// activeDeviceAlarms 查询设备上报的未处理报警事件
+// 携带厂家报警 ID 时只匹配同一 ID 的事件
+func (e *Evaluator) activeDeviceAlarms(ctx context.Context, tenantID string, alarm events.DeviceAlarm, eventType string) ([]*models.AlarmEvent, error) {
+	status := "active"
+	alarms, _, err := e.alarmEventsRepo.ListAlarmEvents(ctx, tenantID, repository.AlarmEventFilters{
+		DeviceID:    &alarm.DeviceID,
+		EventType:   &eventType,
+		AlarmStatus: &status,
+	}, 1, 50)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active device alarms: %w", err)
+	}
+
+	matched := make([]*models.AlarmEvent, 0, len(alarms))
+	for _, event := range alarms {
+		var metadata struct {
+			DeviceTriggered bool   `json:"device_triggered"`
+			VendorAlarmID   string `json:"vendor_alarm_id"`
+		}
+		if len(event.Metadata) > 0 {
+			if err := json.Unmarshal(event.Metadata, &metadata); err != nil {
+				continue
+			}
+		}
+		if !metadata.DeviceTriggered {
+			continue
+		}
+		if alarm.VendorAlarmID != "" && metadata.VendorAlarmID != "" && metadata.VendorAlarmID != alarm.VendorAlarmID {
+			continue
+		}
+		matched = append(matched, event)
+	}
+	return matched, nil
+}
+
+// deviceAlarmLevel 设备报警级别
+// 优先级：1) alarm_device.monitor_config.alarms.{event_type}，2) alarm_cloud 通用报警列（LowBattery / DeviceFailure）
+// 或 alarm_cloud.device_alarms.{设备类型}.{event_type}，3) 默认级别
+// 设备配置 enabled = false 时返回空级别（不产生报警）
+func (e *Evaluator) deviceAlarmLevel(ctx context.Context, tenantID string, alarm events.DeviceAlarm, eventType string) (string, error) {
+	deviceConfig, err := e.alarmDeviceRepo.GetAlarmDeviceConfig(ctx, tenantID, alarm.DeviceID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get alarm device config: %w", err)
+	}
+	if deviceConfig != nil && len(deviceConfig.MonitorConfig) > 0 {
+		var monitorConfig struct {
+			Alarms map[string]struct {
+				Level   string `json:"level"`
+				Enabled *bool  `json:"enabled"`
+			} `json:"alarms"`
+		}
+		if err := json.Unmarshal(deviceConfig.MonitorConfig, &monitorConfig); err == nil {
+			if cfg, ok := monitorConfig.Alarms[eventType]; ok {
+				if cfg.Enabled != nil && !*cfg.Enabled {
+					return "", nil
+				}
+				if cfg.Level != "" {
+					return cfg.Level, nil
+				}
+			}
+		}
+	}
+
+	cloudConfig, err := e.alarmCloudRepo.GetAlarmCloudConfig(ctx, tenantID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get alarm cloud config: %w", err)
+	}
+	switch eventType {
+	case "LowBattery":
+		if cloudConfig.LowBattery != nil {
+			return *cloudConfig.LowBattery, nil
+		}
+	case "DeviceFailure":
+		if cloudConfig.DeviceFailure != nil {
+			return *cloudConfig.DeviceFailure, nil
+		}
+	}
+	if len(cloudConfig.DeviceAlarms) > 0 {
+		var deviceAlarms map[string]map[string]string
+		if err := json.Unmarshal(cloudConfig.DeviceAlarms, &deviceAlarms); err == nil {
+			configType := alarmConfigDeviceTypes[alarm.DeviceType]
+			if configType == "" {
+				configType = alarm.DeviceType
+			}
+			if level, ok := deviceAlarms[configType][eventType]; ok {
+				return level, nil
+			}
+		}
+	}
+	return e.config.Alarm.DeviceAlarm.DefaultLevel, nil
+}
@@ -0,0 +1,256 @@
+package evaluator
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"wisefido-alarm/internal/config"
+	"wisefido-alarm/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"owl-common/events"
+)
+
+var alarmEventColumns = []string{
+	"event_id", "tenant_id", "device_id", "event_type", "category",
+	"alarm_level", "alarm_status", "triggered_at", "hand_time",
+	"iot_timeseries_id", "trigger_data", "handler", "operation",
+	"notes", "notified_users", "metadata", "created_at", "updated_at",
+}
+
+var alarmCloudColumns = []string{
+	"tenant_id", "OfflineAlarm", "LowBattery", "DeviceFailure",
+	"device_alarms", "conditions", "notification_rules", "metadata",
+}
+
+func setupDeviceAlarmEvaluator(t *testing.T) (*Evaluator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{}
+	cfg.Alarm.DeviceAlarm.TypeMap = map[string]string{
+		"Sleepace/leaveBed":   "SleepPad_LeftBed",
+		"Sleepace/lowBattery": "LowBattery",
+	}
+	cfg.Alarm.DeviceAlarm.DefaultEventType = "DeviceAlarm"
+	cfg.Alarm.DeviceAlarm.DefaultLevel = "WARNING"
+
+	logger := zap.NewNop()
+	e := NewEvaluator(cfg, nil, nil, nil, nil,
+		repository.NewAlarmCloudRepository(db, logger),
+		repository.NewAlarmDeviceRepository(db, logger),
+		repository.NewAlarmEventsRepository(db, logger),
+		logger,
+	)
+	return e, mock
+}
+
+// expectDeviceConfig 设备报警配置（monitorConfig 为空表示设备没有配置）
+func expectDeviceConfig(mock sqlmock.Sqlmock, monitorConfig string) {
+	q := mock.ExpectQuery(`FROM alarm_device`).WithArgs("dev-1", "t1")
+	if monitorConfig == "" {
+		q.WillReturnError(sql.ErrNoRows)
+		return
+	}
+	q.WillReturnRows(sqlmock.NewRows([]string{"device_id", "tenant_id", "monitor_config", "vendor_config", "metadata"}).
+		AddRow("dev-1", "t1", []byte(monitorConfig), []byte(`{}`), []byte(`{}`)))
+}
+
+func expectCloudConfig(mock sqlmock.Sqlmock, lowBattery interface{}, deviceAlarms string) {
+	mock.ExpectQuery(`FROM alarm_cloud`).WithArgs("t1").
+		WillReturnRows(sqlmock.NewRows(alarmCloudColumns).
+			AddRow("t1", nil, lowBattery, nil, []byte(deviceAlarms), []byte(`{}`), []byte(`{}`), []byte(`{}`)))
+}
+
+// expectActiveAlarms 设备的未处理报警事件（每个 metadata 对应一个事件，事件 ID 为 e1、e2...）
+func expectActiveAlarms(mock sqlmock.Sqlmock, eventType string, metadata ...string) {
+	mock.ExpectQuery(`SELECT COUNT`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(metadata)))
+	rows := sqlmock.NewRows(alarmEventColumns)
+	now := time.Now()
+	for i, m := range metadata {
+		rows.AddRow("e"+string(rune('1'+i)), "t1", "dev-1", eventType, "behavioral",
+			"CRIT", "active", now, nil, nil, `{}`, nil, nil, nil, `[]`, m, now, now)
+	}
+	mock.ExpectQuery(`SELECT DISTINCT`).WillReturnRows(rows)
+}
+
+func leaveBed(status, vendorAlarmID string) events.DeviceAlarm {
+	return events.DeviceAlarm{
+		DeviceID:      "dev-1",
+		SerialNumber:  "PAD01",
+		DeviceType:    "Sleepace",
+		VendorAlarmID: vendorAlarmID,
+		VendorType:    "leaveBed",
+		Status:        status,
+	}
+}
+
+func TestDeviceAlarmEventType(t *testing.T) {
+	e, _ := setupDeviceAlarmEvaluator(t)
+
+	assert.Equal(t, "SleepPad_LeftBed", e.deviceAlarmEventType(leaveBed(events.DeviceAlarmTrigger, "")))
+
+	unmapped := leaveBed(events.DeviceAlarmTrigger, "")
+	unmapped.VendorType = "unknown"
+	assert.Equal(t, "DeviceAlarm", e.deviceAlarmEventType(unmapped))
+}
+
+// 级别优先级：设备配置 > 通用报警列 / 设备类型报警配置 > 默认级别
+func TestDeviceAlarmLevel(t *testing.T) {
+	tests := []struct {
+		name         string
+		eventType    string
+		deviceConfig string
+		cloud        bool
+		lowBattery   interface{}
+		deviceAlarms string
+		want         string
+	}{
+		{
+			name:         "device config level",
+			eventType:    "SleepPad_LeftBed",
+			deviceConfig: `{"alarms":{"SleepPad_LeftBed":{"level":"ALERT"}}}`,
+			want:         "ALERT",
+		},
+		{
+			name:         "device config disabled",
+			eventType:    "SleepPad_LeftBed",
+			deviceConfig: `{"alarms":{"SleepPad_LeftBed":{"level":"ALERT","enabled":false}}}`,
+			want:         "",
+		},
+		{
+			name:         "device config without level falls back to cloud",
+			eventType:    "SleepPad_LeftBed",
+			deviceConfig: `{"alarms":{"SleepPad_LeftBed":{"enabled":true}}}`,
+			cloud:        true,
+			deviceAlarms: `{"SleepPad":{"SleepPad_LeftBed":"CRIT"}}`,
+			want:         "CRIT",
+		},
+		{
+			name:         "other event in device config",
+			eventType:    "SleepPad_LeftBed",
+			deviceConfig: `{"alarms":{"LowBattery":{"level":"ALERT"}}}`,
+			cloud:        true,
+			deviceAlarms: `{"SleepPad":{"SleepPad_LeftBed":"CRIT"}}`,
+			want:         "CRIT",
+		},
+		{
+			name:         "general alarm column before device alarms",
+			eventType:    "LowBattery",
+			cloud:        true,
+			lowBattery:   "NOTICE",
+			deviceAlarms: `{"SleepPad":{"LowBattery":"ERR"}}`,
+			want:         "NOTICE",
+		},
+		{
+			name:         "general alarm without column uses device alarms",
+			eventType:    "LowBattery",
+			cloud:        true,
+			deviceAlarms: `{"SleepPad":{"LowBattery":"ERR"}}`,
+			want:         "ERR",
+		},
+		{
+			name:         "default level",
+			eventType:    "SleepPad_LeftBed",
+			cloud:        true,
+			deviceAlarms: `{"Radar":{"SleepPad_LeftBed":"CRIT"}}`,
+			want:         "WARNING",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, mock := setupDeviceAlarmEvaluator(t)
+			expectDeviceConfig(mock, tt.deviceConfig)
+			if tt.cloud {
+				expectCloudConfig(mock, tt.lowBattery, tt.deviceAlarms)
+			}
+
+			level, err := e.deviceAlarmLevel(context.Background(), "t1", leaveBed(events.DeviceAlarmTrigger, ""), tt.eventType)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, level)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// 同一厂家报警已有未处理事件时不重复创建
+func TestEvaluateDeviceAlarm_TriggerDedup(t *testing.T) {
+	tests := []struct {
+		name       string
+		active     []string
+		wantCreate bool
+	}{
+		{"no active alarm", nil, true},
+		{"same vendor alarm", []string{`{"device_triggered":true,"vendor_alarm_id":"a1"}`}, false},
+		{"active alarm without vendor id", []string{`{"device_triggered":true}`}, false},
+		{"other vendor alarm", []string{`{"device_triggered":true,"vendor_alarm_id":"a0"}`}, true},
+		{"not device triggered", []string{`{}`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, mock := setupDeviceAlarmEvaluator(t)
+			eventTime := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+			expectDeviceConfig(mock, `{"alarms":{"SleepPad_LeftBed":{"level":"ALERT"}}}`)
+			expectActiveAlarms(mock, "SleepPad_LeftBed", tt.active...)
+			if tt.wantCreate {
+				mock.ExpectExec(`INSERT INTO alarm_events`).
+					WithArgs(sqlmock.AnyArg(), "t1", "dev-1", "SleepPad_LeftBed", "behavioral",
+						"ALERT", "active", eventTime, sqlmock.AnyArg(), sqlmock.AnyArg(),
+						sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+						sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+
+			err := e.EvaluateDeviceAlarm(context.Background(), "t1", leaveBed(events.DeviceAlarmTrigger, "a1"), eventTime)
+			require.NoError(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// 设备配置关闭该报警时不创建事件
+func TestEvaluateDeviceAlarm_TriggerDisabled(t *testing.T) {
+	e, mock := setupDeviceAlarmEvaluator(t)
+	expectDeviceConfig(mock, `{"alarms":{"SleepPad_LeftBed":{"enabled":false}}}`)
+
+	err := e.EvaluateDeviceAlarm(context.Background(), "t1", leaveBed(events.DeviceAlarmTrigger, "a1"), time.Now())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// 解除报警只解除同一厂家报警 ID（或没有记录 ID）的设备报警事件
+func TestEvaluateDeviceAlarm_RelieveByVendorAlarmID(t *testing.T) {
+	e, mock := setupDeviceAlarmEvaluator(t)
+	expectActiveAlarms(mock, "SleepPad_LeftBed",
+		`{"device_triggered":true,"vendor_alarm_id":"a1"}`,
+		`{"device_triggered":true,"vendor_alarm_id":"a2"}`,
+		`{}`,
+		`{"device_triggered":true}`,
+	)
+	// alarm_status、operation、hand_time、notes 的顺序不固定
+	for _, eventID := range []string{"e1", "e4"} {
+		mock.ExpectExec(`UPDATE alarm_events`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), eventID, "t1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	relieveTime := time.Date(2026, 1, 1, 3, 5, 0, 0, time.UTC)
+	alarm := leaveBed(events.DeviceAlarmRelieve, "a1")
+	alarm.RelieveTime = &relieveTime
+	alarm.RelieveReason = "back in bed"
+	require.NoError(t, e.EvaluateDeviceAlarm(context.Background(), "t1", alarm, relieveTime))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// 未知状态不处理
+func TestEvaluateDeviceAlarm_UnknownStatus(t *testing.T) {
+	e, mock := setupDeviceAlarmEvaluator(t)
+	require.NoError(t, e.EvaluateDeviceAlarm(context.Background(), "t1", leaveBed("ack", "a1"), time.Now()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
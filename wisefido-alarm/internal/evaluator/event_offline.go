@@ -0,0 +1,132 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"wisefido-alarm/internal/models"
+	"wisefido-alarm/internal/repository"
+
+	"go.uber.org/zap"
+	"owl-common/events"
+)
+
+// OfflineAlarmEventType 设备离线报警事件类型（与 alarm_cloud."OfflineAlarm" 对应）
+const OfflineAlarmEventType = "OfflineAlarm"
+
+// alarmLevels 产生报警事件的级别（其他取值视为关闭）
+var alarmLevels = map[string]bool{
+	"0": true, "1": true, "2": true, "3": true, "4": true, "5": true,
+	"EMERG": true, "ALERT": true, "CRIT": true, "ERR": true, "WARNING": true, "NOTICE": true,
+}
+
+// EvaluatePresence 处理设备在线状态变化（实现 consumer.PresenceEvaluator 接口）
+// - offline：按 alarm_cloud.OfflineAlarm 配置的级别创建 OfflineAlarm 事件（已有未处理的离线报警时不重复创建）
+// - online：自动解除该设备未处理的 OfflineAlarm 事件
+// - 运行信息更新（reason = device_info）不是状态切换，忽略
+func (e *Evaluator) EvaluatePresence(ctx context.Context, tenantID string, presence events.DevicePresence) error {
+	if presence.Reason == "device_info" {
+		return nil
+	}
+	switch presence.Status {
+	case "offline":
+		return e.raiseOfflineAlarm(ctx, tenantID, presence)
+	case "online":
+		return e.relieveOfflineAlarms(ctx, tenantID, presence)
+	}
+	return nil
+}
+
+// raiseOfflineAlarm 创建设备离线报警
+func (e *Evaluator) raiseOfflineAlarm(ctx context.Context, tenantID string, presence events.DevicePresence) error {
+	cloudConfig, err := e.alarmCloudRepo.GetAlarmCloudConfig(ctx, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to get alarm cloud config: %w", err)
+	}
+	if cloudConfig.OfflineAlarm == nil || !alarmLevels[*cloudConfig.OfflineAlarm] {
+		e.logger.Debug("Offline alarm disabled",
+			zap.String("tenant_id", tenantID),
+			zap.String("device_id", presence.DeviceID),
+		)
+		return nil
+	}
+
+	active, err := e.activeOfflineAlarms(ctx, tenantID, presence.DeviceID)
+	if err != nil {
+		return err
+	}
+	if len(active) > 0 {
+		return nil
+	}
+
+	triggerData := &models.TriggerData{
+		EventType: OfflineAlarmEventType,
+		Source:    presence.DeviceType,
+	}
+	metadata := map[string]interface{}{
+		"reason":        presence.Reason,
+		"serial_number": presence.SerialNumber,
+	}
+	if !presence.LastSeen.IsZero() {
+		metadata["last_seen"] = presence.LastSeen.Unix()
+	}
+	event, err := NewAlarmEventBuilder(tenantID, presence.DeviceID).BuildAlarmEvent(
+		OfflineAlarmEventType,
+		"device",
+		*cloudConfig.OfflineAlarm,
+		triggerData,
+		metadata,
+	)
+	if err != nil {
+		return err
+	}
+	if err := e.alarmEventsRepo.CreateAlarmEvent(ctx, tenantID, event); err != nil {
+		return err
+	}
+
+	e.logger.Info("Offline alarm created",
+		zap.String("tenant_id", tenantID),
+		zap.String("device_id", presence.DeviceID),
+		zap.String("alarm_level", event.AlarmLevel),
+		zap.String("event_id", event.EventID),
+	)
+	return nil
+}
+
+// relieveOfflineAlarms 设备恢复在线：自动解除未处理的离线报警
+func (e *Evaluator) relieveOfflineAlarms(ctx context.Context, tenantID string, presence events.DevicePresence) error {
+	active, err := e.activeOfflineAlarms(ctx, tenantID, presence.DeviceID)
+	if err != nil {
+		return err
+	}
+	for _, alarm := range active {
+		if err := e.alarmEventsRepo.UpdateAlarmEvent(ctx, tenantID, alarm.EventID, map[string]interface{}{
+			"alarm_status": "acknowledged",
+			"operation":    "auto_relieved",
+			"hand_time":    time.Now(),
+		}); err != nil {
+			return err
+		}
+		e.logger.Info("Offline alarm auto relieved",
+			zap.String("tenant_id", tenantID),
+			zap.String("device_id", presence.DeviceID),
+			zap.String("event_id", alarm.EventID),
+		)
+	}
+	return nil
+}
+
+// activeOfflineAlarms 查询设备未处理的离线报警
+func (e *Evaluator) activeOfflineAlarms(ctx context.Context, tenantID, deviceID string) ([]*models.AlarmEvent, error) {
+	eventType := OfflineAlarmEventType
+	status := "active"
+	alarms, _, err := e.alarmEventsRepo.ListAlarmEvents(ctx, tenantID, repository.AlarmEventFilters{
+		DeviceID:    &deviceID,
+		EventType:   &eventType,
+		AlarmStatus: &status,
+	}, 1, 10)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active offline alarms: %w", err)
+	}
+	return alarms, nil
+}
@@ -0,0 +1,24 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"owl-common/events"
+)
+
+// 运行信息更新不是状态切换，不查询也不解除离线报警
+func TestEvaluatePresence_IgnoresDeviceInfo(t *testing.T) {
+	e, mock := setupDeviceAlarmEvaluator(t)
+
+	err := e.EvaluatePresence(context.Background(), "t1", events.DevicePresence{
+		DeviceID:        "dev-1",
+		DeviceType:      "Radar",
+		Status:          "online",
+		Reason:          "device_info",
+		FirmwareVersion: "2.2.0",
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -24,6 +24,29 @@ type RealtimeData struct {
 	
 	// 时间戳
 	Timestamp    int64   `json:"timestamp"`    // Unix 时间戳（融合结果的时间戳）
+	
+	// 按床位融合的结果（ActiveBed 卡片，每张床一项；顶层字段为卡片自身床位的结果）
+	Beds         []BedRealtime `json:"beds,omitempty"`
+}
+
+// BedRealtime 单张床位的融合结果
+type BedRealtime struct {
+	BedID      string  `json:"bed_id"`
+	BedName    string  `json:"bed_name,omitempty"`
+	ResidentID *string `json:"resident_id,omitempty"` // 床位绑定的住户
+	RealtimeData
+}
+
+// BedsFor 按床位返回实时数据
+// 旧版本融合服务没有 beds 字段：ActiveBed 卡片以顶层字段作为卡片自身床位的结果
+func (d *RealtimeData) BedsFor(cardBedID *string) []BedRealtime {
+	if len(d.Beds) > 0 {
+		return d.Beds
+	}
+	if cardBedID == nil {
+		return nil
+	}
+	return []BedRealtime{{BedID: *cardBedID, RealtimeData: *d}}
 }
 
 // Posture 姿态数据
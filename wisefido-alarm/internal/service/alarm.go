@@ -26,6 +26,8 @@ type AlarmService struct {
 	cacheManager    *consumer.CacheManager
 	stateManager    *consumer.StateManager
 	cacheConsumer   *consumer.CacheConsumer
+	presenceConsumer *consumer.PresenceConsumer
+	deviceAlarmConsumer *consumer.DeviceAlarmConsumer
 	cardRepo        *repository.CardRepository
 	deviceRepo      *repository.DeviceRepository
 	roomRepo        *repository.RoomRepository
@@ -95,6 +97,12 @@ func NewAlarmService(cfg *config.Config, logger *zap.Logger, tenantID string) (*
 		tenantID,
 	)
 
+	// 7. 创建 PresenceConsumer（设备离线报警）
+	presenceConsumer := consumer.NewPresenceConsumer(cfg, redisClient, logger, tenantID)
+
+	// 8. 创建 DeviceAlarmConsumer（设备上报的报警）
+	deviceAlarmConsumer := consumer.NewDeviceAlarmConsumer(cfg, redisClient, logger, tenantID)
+
 	return &AlarmService{
 		config:          cfg,
 		db:              db,
@@ -104,6 +112,8 @@ func NewAlarmService(cfg *config.Config, logger *zap.Logger, tenantID string) (*
 		cacheManager:    cacheManager,
 		stateManager:    stateManager,
 		cacheConsumer:   cacheConsumer,
+		presenceConsumer: presenceConsumer,
+		deviceAlarmConsumer: deviceAlarmConsumer,
 		cardRepo:        cardRepo,
 		deviceRepo:      deviceRepo,
 		roomRepo:        roomRepo,
@@ -120,6 +130,20 @@ func (s *AlarmService) Start(ctx context.Context) error {
 		zap.String("tenant_id", s.tenantID),
 	)
 
+	// 启动 PresenceConsumer（设备离线报警，事件驱动）
+	go func() {
+		if err := s.presenceConsumer.Start(ctx, s.evaluator); err != nil {
+			s.logger.Error("Presence consumer failed", zap.Error(err))
+		}
+	}()
+
+	// 启动 DeviceAlarmConsumer（设备上报的报警，事件驱动）
+	go func() {
+		if err := s.deviceAlarmConsumer.Start(ctx, s.evaluator); err != nil {
+			s.logger.Error("Device alarm consumer failed", zap.Error(err))
+		}
+	}()
+
 	// 启动 CacheConsumer（轮询模式）
 	if err := s.cacheConsumer.Start(ctx, s.evaluator); err != nil {
 		return fmt.Errorf("failed to start cache consumer: %w", err)
@@ -6,6 +6,7 @@ import (
 	"wisefido-card-aggregator/internal/repository"
 
 	"go.uber.org/zap"
+	"owl-common/events"
 )
 
 // CardRepositoryInterface defines card repository interface (for test mocking)
@@ -27,10 +28,15 @@ type CardRepositoryInterface interface {
 	GetUnitIDByBedID(tenantID, bedID string) (string, error)
 }
 
+// ChangeNotifier is called after the cards of a unit have been rebuilt
+// (card IDs and device membership may have changed)
+type ChangeNotifier func(change events.CardChange) error
+
 // CardCreator card creator
 type CardCreator struct {
-	repo   CardRepositoryInterface
-	logger *zap.Logger
+	repo     CardRepositoryInterface
+	notifier ChangeNotifier
+	logger   *zap.Logger
 }
 
 // NewCardCreator creates a new card creator
@@ -41,6 +47,12 @@ func NewCardCreator(repo CardRepositoryInterface, logger *zap.Logger) *CardCreat
 	}
 }
 
+// SetChangeNotifier sets the notifier called after the cards of a unit are rebuilt
+// (sensor fusion invalidates its card topology cache on these notifications)
+func (c *CardCreator) SetChangeNotifier(notifier ChangeNotifier) {
+	c.notifier = notifier
+}
+
 // CreateCardsForUnit creates cards for the specified unit
 // According to card creation rules, handles three scenarios:
 // - Scenario A: Unit has only 1 ActiveBed
@@ -63,6 +75,8 @@ func (c *CardCreator) CreateCardsForUnit(tenantID, unitID string) error {
 	if err := c.repo.DeleteCardsByUnit(tenantID, unitID); err != nil {
 		return fmt.Errorf("failed to delete old cards: %w", err)
 	}
+	// Old cards are gone: notify downstream services even if creating the new cards fails
+	defer c.notifyChange(tenantID, unitID)
 
 	// 4. Determine scenario based on ActiveBed count
 	activeBedCount := len(activeBeds)
@@ -79,6 +93,21 @@ func (c *CardCreator) CreateCardsForUnit(tenantID, unitID string) error {
 	}
 }
 
+// notifyChange publishes the card change notification (failures are logged only:
+// subscribers fall back to their cache TTL)
+func (c *CardCreator) notifyChange(tenantID, unitID string) {
+	if c.notifier == nil {
+		return
+	}
+	if err := c.notifier(events.CardChange{TenantID: tenantID, UnitID: unitID}); err != nil {
+		c.logger.Warn("Failed to publish card change",
+			zap.String("tenant_id", tenantID),
+			zap.String("unit_id", unitID),
+			zap.Error(err),
+		)
+	}
+}
+
 // createActiveBedCardWithUnboundDevices Scenario A: Create 1 ActiveBed card, bind all devices
 func (c *CardCreator) createActiveBedCardWithUnboundDevices(
 	tenantID string,
@@ -5,6 +5,8 @@ import (
 	"testing"
 	"wisefido-card-aggregator/internal/repository"
 
+	"owl-common/events"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -394,6 +396,41 @@ func TestCreateCardsForUnit_ScenarioC_NoUnboundDevices(t *testing.T) {
 	mockRepo.AssertNotCalled(t, "CreateCard")
 }
 
+func TestCreateCardsForUnit_NotifiesChange(t *testing.T) {
+	creator, mockRepo := setupCardCreator()
+
+	tenantID := "tenant-123"
+	unitID := "unit-456"
+
+	var changes []events.CardChange
+	creator.SetChangeNotifier(func(change events.CardChange) error {
+		changes = append(changes, change)
+		return errors.New("redis unavailable") // notification failures do not fail the rebuild
+	})
+
+	unitInfo := &repository.UnitInfo{
+		UnitID:    unitID,
+		UnitName:  "E203",
+		GroupList: []byte(`[]`),
+		UserList:  []byte(`[]`),
+	}
+	mockRepo.On("GetUnitInfo", tenantID, unitID).Return(unitInfo, nil)
+	mockRepo.On("GetActiveBedsByUnit", tenantID, unitID).Return([]repository.ActiveBedInfo{}, nil)
+	mockRepo.On("DeleteCardsByUnit", tenantID, unitID).Return(nil)
+	mockRepo.On("GetUnboundDevicesByUnit", tenantID, unitID).Return([]repository.DeviceInfo{}, nil)
+
+	err := creator.CreateCardsForUnit(tenantID, unitID)
+
+	require.NoError(t, err)
+	require.Equal(t, []events.CardChange{{TenantID: tenantID, UnitID: unitID}}, changes)
+
+	// Cards are not deleted when loading the unit fails: no notification
+	changes = nil
+	mockRepo.On("GetUnitInfo", tenantID, "unit-missing").Return(nil, errors.New("database error"))
+	assert.Error(t, creator.CreateCardsForUnit(tenantID, "unit-missing"))
+	assert.Empty(t, changes)
+}
+
 func TestCreateCardsForUnit_Error_GetUnitInfoFailed(t *testing.T) {
 	creator, mockRepo := setupCardCreator()
 
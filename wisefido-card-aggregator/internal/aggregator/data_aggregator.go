@@ -9,6 +9,7 @@ import (
 	"wisefido-card-aggregator/internal/repository"
 
 	"go.uber.org/zap"
+	"owl-common/presence"
 )
 
 // DataAggregator 数据聚合器（聚合卡片数据）
@@ -113,6 +114,9 @@ func (a *DataAggregator) AggregateCard(ctx context.Context, tenantID, cardID str
 		vitalCard.PrimaryResidentID = cardInfo.ResidentID
 	}
 
+	// 设备连接状态（采集服务维护的 device:presence:status:{device_id}）
+	a.mergeConnectionState(ctx, vitalCard, devices)
+
 	// 4. 从 Redis 读取实时数据
 	realtimeData, err := a.getRealtimeData(ctx, cardID)
 	if err != nil {
@@ -182,6 +186,40 @@ func (a *DataAggregator) getAlarmData(ctx context.Context, cardID string) ([]Ala
 	return alarms, nil
 }
 
+// mergeConnectionState 合并设备连接状态
+// 同类设备任一在线即为在线（1）；有状态记录但均不在线为离线（0）；无状态记录时不设置
+func (a *DataAggregator) mergeConnectionState(ctx context.Context, vitalCard *models.VitalFocusCard, devices []repository.DeviceInfo) {
+	for _, d := range devices {
+		status, err := a.kv.Get(ctx, presence.StatusKey(d.DeviceID))
+		if err != nil {
+			if err != ErrCacheMiss {
+				a.logger.Debug("Failed to get device presence",
+					zap.String("device_id", d.DeviceID),
+					zap.Error(err),
+				)
+			}
+			continue
+		}
+		connected := 0
+		if status == presence.StatusOnline {
+			connected = 1
+		}
+
+		var target **int
+		switch *convertSource(d.DeviceType) {
+		case "r":
+			target = &vitalCard.RConnection
+		case "s":
+			target = &vitalCard.SConnection
+		default:
+			continue
+		}
+		if *target == nil || connected > **target {
+			*target = intPtr(connected)
+		}
+	}
+}
+
 // mergeRealtimeData 合并实时数据到 VitalFocusCard
 func (a *DataAggregator) mergeRealtimeData(vitalCard *models.VitalFocusCard, realtimeData *RealtimeData) {
 	// 生命体征
@@ -219,6 +257,55 @@ func (a *DataAggregator) mergeRealtimeData(vitalCard *models.VitalFocusCard, rea
 			vitalCard.Postures = postures
 		}
 	}
+
+	// 按床位的实时数据
+	for _, bed := range realtimeData.Beds {
+		vitalCard.Beds = append(vitalCard.Beds, convertBed(bed, vitalCard.Residents))
+	}
+}
+
+// convertBed 转换单张床位的实时数据，并关联床位的住户
+// 融合结果没有住户时（旧卡片），按卡片住户的 bed_id 匹配
+func convertBed(bed BedRealtime, residents []models.CardResident) models.CardBed {
+	result := models.CardBed{
+		BedID:        bed.BedID,
+		BedName:      bed.BedName,
+		ResidentID:   bed.ResidentID,
+		Heart:        bed.Heart,
+		Breath:       bed.Breath,
+		HeartSource:  convertSource(bed.HeartSource),
+		BreathSource: convertSource(bed.BreathSource),
+		PersonCount:  intPtr(bed.PersonCount),
+	}
+	for i := range residents {
+		r := &residents[i]
+		matched := r.BedID != nil && *r.BedID == bed.BedID
+		if bed.ResidentID != nil {
+			matched = r.ResidentID == *bed.ResidentID
+		}
+		if matched {
+			result.ResidentID = &r.ResidentID
+			result.Nickname = &r.Nickname
+			break
+		}
+	}
+
+	if bed.SleepStage != nil {
+		sleepStage := convertSleepStage(*bed.SleepStage)
+		result.SleepStage = &sleepStage
+		result.SleepStateSNOMEDCode = bed.SleepStage
+		result.SleepStateDisplay = getSleepStateDisplay(*bed.SleepStage)
+	}
+	if bed.BedStatus != nil {
+		bedStatus := convertBedStatus(*bed.BedStatus)
+		result.BedStatus = &bedStatus
+	}
+	for _, posture := range bed.Postures {
+		if code := convertPostureCode(posture.PostureCode); code > 0 {
+			result.Postures = append(result.Postures, code)
+		}
+	}
+	return result
 }
 
 // RealtimeData 实时数据结构（与 wisefido-sensor-fusion 保持一致）
@@ -232,6 +319,17 @@ type RealtimeData struct {
 	PersonCount  int       `json:"person_count"`
 	Postures     []Posture `json:"postures"`
 	Timestamp    int64     `json:"timestamp"`
+
+	// 按床位融合的结果（ActiveBed 卡片）
+	Beds []BedRealtime `json:"beds,omitempty"`
+}
+
+// BedRealtime 单张床位的融合结果（与 wisefido-sensor-fusion 保持一致）
+type BedRealtime struct {
+	BedID      string  `json:"bed_id"`
+	BedName    string  `json:"bed_name,omitempty"`
+	ResidentID *string `json:"resident_id,omitempty"`
+	RealtimeData
 }
 
 // Posture 姿态数据
@@ -13,6 +13,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"owl-common/presence"
 )
 
 func TestDataAggregator_AggregateCard_MergesDBAndCaches(t *testing.T) {
@@ -100,6 +101,7 @@ func TestDataAggregator_AggregateCard_MergesDBAndCaches(t *testing.T) {
 	}
 	alarmsBytes, _ := json.Marshal(alarms)
 	require.NoError(t, kv.Set(context.Background(), "vital-focus:card:card-1:alarms", string(alarmsBytes), 0))
+	require.NoError(t, kv.Set(context.Background(), presence.StatusKey("device-1"), presence.StatusOnline, 0))
 
 	// aggregate
 	out, err := aggregator.AggregateCard(context.Background(), tenantID, cardID)
@@ -125,6 +127,11 @@ func TestDataAggregator_AggregateCard_MergesDBAndCaches(t *testing.T) {
 	require.Equal(t, "alarm-1", out.Alarms[0].EventID)
 	require.Equal(t, "Fall", out.Alarms[0].EventType)
 
+	// connection state
+	require.NotNil(t, out.RConnection)
+	require.Equal(t, 1, *out.RConnection)
+	require.Nil(t, out.SConnection)
+
 	// counts
 	require.Equal(t, 1, out.DeviceCount)
 	require.Equal(t, 1, out.ResidentCount)
@@ -140,3 +147,81 @@ func TestDataAggregator_AggregateCard_MergesDBAndCaches(t *testing.T) {
 }
 
 
+
+func TestDataAggregator_AggregateCard_PerBedVitals(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger := zap.NewNop()
+	cardRepo := repository.NewCardRepository(db, logger)
+	kv := newFakeKVStore()
+	aggregator := agg.NewDataAggregator(&config.Config{}, kv, cardRepo, logger)
+
+	tenantID := "tenant-1"
+	cardID := "card-1"
+
+	mock.ExpectQuery(`SELECT\s+card_id`).
+		WithArgs(cardID, tenantID).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"card_id", "tenant_id", "card_type", "bed_id", "unit_id", "card_name", "card_address", "resident_id",
+			"unhandled_alarm_0", "unhandled_alarm_1", "unhandled_alarm_2", "unhandled_alarm_3", "unhandled_alarm_4",
+			"icon_alarm_level", "pop_alarm_emerge",
+		}).AddRow(
+			cardID, tenantID, "ActiveBed", "bed-1", "unit-1", "BedCard", "Addr", "resident-1",
+			0, 0, 0, 0, 0,
+			3, 0,
+		))
+	devicesBytes, _ := json.Marshal([]map[string]any{
+		{"device_id": "pad-left", "device_type": "Sleepace", "bed_id": "bed-1", "unit_id": "unit-1"},
+		{"device_id": "pad-right", "device_type": "Sleepace", "bed_id": "bed-2", "unit_id": "unit-1"},
+	})
+	mock.ExpectQuery(`SELECT\s+devices`).
+		WithArgs(cardID).
+		WillReturnRows(sqlmock.NewRows([]string{"devices"}).AddRow(devicesBytes))
+	residentsBytes, _ := json.Marshal([]map[string]any{
+		{"resident_id": "resident-1", "nickname": "Ann", "bed_id": "bed-1"},
+		{"resident_id": "resident-2", "nickname": "Bob", "bed_id": "bed-2"},
+	})
+	mock.ExpectQuery(`SELECT\s+residents`).
+		WithArgs(cardID).
+		WillReturnRows(sqlmock.NewRows([]string{"residents"}).AddRow(residentsBytes))
+
+	// bed-2 has no resident_id in the realtime data: associated through the card residents
+	realtime := map[string]any{
+		"heart":        62,
+		"heart_source": "Sleepace",
+		"person_count": 0,
+		"timestamp":    1700000000,
+		"beds": []map[string]any{
+			{"bed_id": "bed-1", "resident_id": "resident-1", "heart": 62, "heart_source": "Sleepace", "timestamp": 1700000000},
+			{"bed_id": "bed-2", "heart": 80, "heart_source": "Sleepace", "bed_status": "out_of_bed", "timestamp": 1700000000},
+		},
+	}
+	rtBytes, _ := json.Marshal(realtime)
+	require.NoError(t, kv.Set(context.Background(), "vital-focus:card:card-1:realtime", string(rtBytes), 0))
+
+	out, err := aggregator.AggregateCard(context.Background(), tenantID, cardID)
+	require.NoError(t, err)
+
+	require.NotNil(t, out.Heart)
+	require.Equal(t, 62, *out.Heart)
+	require.Len(t, out.Beds, 2)
+
+	require.Equal(t, "bed-1", out.Beds[0].BedID)
+	require.Equal(t, "resident-1", *out.Beds[0].ResidentID)
+	require.Equal(t, "Ann", *out.Beds[0].Nickname)
+	require.Equal(t, 62, *out.Beds[0].Heart)
+
+	require.Equal(t, "bed-2", out.Beds[1].BedID)
+	require.NotNil(t, out.Beds[1].ResidentID)
+	require.Equal(t, "resident-2", *out.Beds[1].ResidentID)
+	require.Equal(t, "Bob", *out.Beds[1].Nickname)
+	require.Equal(t, 80, *out.Beds[1].Heart)
+	require.Equal(t, "s", *out.Beds[1].HeartSource)
+
+	require.Len(t, out.Residents, 2)
+	require.Equal(t, "bed-2", *out.Residents[1].BedID)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -34,6 +34,8 @@ type Config struct {
 		ConsumerGroup    string // 消费者组名称，如 "card-aggregator-group"
 		ConsumerName     string // 消费者名称，如 "card-aggregator-1"
 		BatchSize        int    // 批量处理大小，默认 10
+		ClaimMinIdle     int    // 待处理事件空闲多久后重新认领（秒），默认 60 秒
+		MaxDeliveries    int    // 最大投递次数，超过后移入死信流（<stream>:dlq），默认 5
 		
 		// 数据聚合配置
 		Aggregation struct {
@@ -72,6 +74,16 @@ func Load() (*Config, error) {
 	cfg.Aggregator.ConsumerGroup = getEnv("CARD_CONSUMER_GROUP", "card-aggregator-group")
 	cfg.Aggregator.ConsumerName = getEnv("CARD_CONSUMER_NAME", "card-aggregator-1")
 	cfg.Aggregator.BatchSize = 10 // 默认批量处理 10 条消息
+	if v, err := strconv.Atoi(getEnv("CARD_CLAIM_MIN_IDLE", "60")); err == nil && v > 0 {
+		cfg.Aggregator.ClaimMinIdle = v
+	} else {
+		cfg.Aggregator.ClaimMinIdle = 60
+	}
+	if v, err := strconv.Atoi(getEnv("CARD_MAX_DELIVERIES", "5")); err == nil && v > 0 {
+		cfg.Aggregator.MaxDeliveries = v
+	} else {
+		cfg.Aggregator.MaxDeliveries = 5
+	}
 	
 	// 数据聚合配置
 	cfg.Aggregator.Aggregation.Enabled = getEnv("CARD_AGGREGATION_ENABLED", "true") == "true"
@@ -3,6 +3,7 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 	"wisefido-card-aggregator/internal/aggregator"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
+	"owl-common/events"
 	rediscommon "owl-common/redis"
 )
 
@@ -22,7 +24,7 @@ type EventConsumer struct {
 	stream      string
 	groupName   string
 	consumerName string
-	batchSize   int64
+	reliable    *rediscommon.ReliableConsumer
 }
 
 // CardEvent 卡片事件
@@ -47,6 +49,8 @@ func NewEventConsumer(
 	groupName string,
 	consumerName string,
 	batchSize int64,
+	claimMinIdle time.Duration,
+	maxDeliveries int64,
 ) *EventConsumer {
 	return &EventConsumer{
 		redisClient:  redisClient,
@@ -56,15 +60,22 @@ func NewEventConsumer(
 		stream:       stream,
 		groupName:    groupName,
 		consumerName: consumerName,
-		batchSize:    batchSize,
+		reliable: rediscommon.NewReliableConsumer(redisClient, rediscommon.ConsumerOptions{
+			Streams:       []string{stream},
+			Group:         groupName,
+			Consumer:      consumerName,
+			BatchSize:     batchSize,
+			ClaimMinIdle:  claimMinIdle,
+			MaxDeliveries: maxDeliveries,
+		}, logger),
 	}
 }
 
 // Start 启动事件消费者
 func (c *EventConsumer) Start(ctx context.Context) error {
 	// 创建消费者组
-	if err := rediscommon.CreateConsumerGroup(ctx, c.redisClient, c.stream, c.groupName); err != nil {
-		return fmt.Errorf("failed to create consumer group: %w", err)
+	if err := c.reliable.Setup(ctx); err != nil {
+		return err
 	}
 
 	c.logger.Info("Event consumer started",
@@ -82,7 +93,8 @@ func (c *EventConsumer) Start(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		default:
-			if err := c.consumeEvents(ctx); err != nil {
+			// 处理成功的事件会被确认；失败事件超时后重新认领，多次失败进入死信流
+			if err := c.reliable.Poll(ctx, c.handleEvent); err != nil {
 				c.logger.Error("Failed to consume events",
 					zap.Error(err),
 					zap.Duration("backoff", backoffDuration),
@@ -106,40 +118,15 @@ func (c *EventConsumer) Start(ctx context.Context) error {
 	}
 }
 
-// consumeEvents 消费事件
-func (c *EventConsumer) consumeEvents(ctx context.Context) error {
-	// 从 Redis Streams 读取消息
-	messages, err := rediscommon.ReadFromStream(
-		ctx,
-		c.redisClient,
-		c.stream,
-		c.groupName,
-		c.consumerName,
-		c.batchSize,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to read from stream: %w", err)
-	}
-
-	// 处理消息
-	for _, msg := range messages {
-		if err := c.processEvent(ctx, msg); err != nil {
-			c.logger.Error("Failed to process event",
-				zap.String("message_id", msg.ID),
-				zap.Error(err),
-			)
-			// 继续处理下一条消息，不中断
-		} else {
-			// 处理成功后确认消息
-			if err := c.ackMessage(ctx, msg.ID); err != nil {
-				c.logger.Warn("Failed to ack message",
-					zap.String("message_id", msg.ID),
-					zap.Error(err),
-				)
-			}
-		}
+// handleEvent 可靠消费者回调
+func (c *EventConsumer) handleEvent(ctx context.Context, msg rediscommon.StreamMessage) error {
+	if err := c.processEvent(ctx, msg); err != nil {
+		c.logger.Error("Failed to process event",
+			zap.String("message_id", msg.ID),
+			zap.Error(err),
+		)
+		return err
 	}
-
 	return nil
 }
 
@@ -148,7 +135,7 @@ func (c *EventConsumer) processEvent(ctx context.Context, msg rediscommon.Stream
 	// 解析事件
 	event, err := c.parseEvent(msg)
 	if err != nil {
-		return fmt.Errorf("failed to parse event: %w", err)
+		return rediscommon.Permanent(fmt.Errorf("failed to parse event: %w", err))
 	}
 
 	c.logger.Info("Processing card event",
@@ -213,7 +200,25 @@ func (c *EventConsumer) processEvent(ctx context.Context, msg rediscommon.Stream
 
 // parseEvent 解析事件消息
 func (c *EventConsumer) parseEvent(msg rediscommon.StreamMessage) (*CardEvent, error) {
-	// 尝试从 data 字段解析 JSON
+	// 优先按 card.event 信封解码
+	env, err := rediscommon.Decode[events.CardEvent](msg, events.CardEventSchema)
+	if err == nil {
+		return &CardEvent{
+			EventType:  env.Payload.EventType,
+			TenantID:   env.TenantID,
+			UnitID:     env.Payload.UnitID,
+			BedID:      env.Payload.BedID,
+			DeviceID:   env.Payload.DeviceID,
+			ResidentID: env.Payload.ResidentID,
+			Timestamp:  env.EventTime.Unix(),
+			Metadata:   env.Payload.Metadata,
+		}, nil
+	}
+	if !errors.Is(err, rediscommon.ErrNotEnvelope) {
+		return nil, err
+	}
+
+	// 旧格式：尝试从 data 字段解析 JSON
 	if dataStr, ok := msg.Values["data"].(string); ok {
 		var event CardEvent
 		if err := json.Unmarshal([]byte(dataStr), &event); err == nil {
@@ -254,9 +259,3 @@ func (c *EventConsumer) parseEvent(msg rediscommon.StreamMessage) (*CardEvent, e
 func (c *EventConsumer) getUnitIDByBedID(tenantID, bedID string) (string, error) {
 	return c.cardRepo.GetUnitIDByBedID(tenantID, bedID)
 }
-
-// ackMessage 确认消息
-func (c *EventConsumer) ackMessage(ctx context.Context, messageID string) error {
-	return c.redisClient.XAck(ctx, c.stream, c.groupName, messageID).Err()
-}
-
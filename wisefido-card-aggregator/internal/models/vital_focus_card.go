@@ -31,7 +31,7 @@ type VitalFocusCard struct {
 	IconAlarmLevel  *int `json:"icon_alarm_level,omitempty"`  // 图标报警级别阈值（默认 3）
 	PopAlarmEmerge  *int `json:"pop_alarm_emerge,omitempty"`   // 弹出报警级别阈值（默认 0）
 
-	// 设备连接状态（待实现，需要设备状态 API）
+	// 设备连接状态（来自 Redis: device:presence:status:{device_id}，由采集服务维护）
 	RConnection     *int `json:"r_connection,omitempty"` // Radar 连接：0=offline, 1=online
 	SConnection     *int `json:"s_connection,omitempty"` // Sleepace 连接：0=offline, 1=online
 
@@ -58,10 +58,34 @@ type VitalFocusCard struct {
 	BedStatusTimestamp *string `json:"bed_status_timestamp,omitempty"` // 床状态变化时间（格式化）
 	StatusDuration     *string `json:"status_duration,omitempty"`     // 持续时间（格式化）
 
+	// 按床位的实时数据（ActiveBed 卡片，每张床一项；顶层实时数据为卡片自身床位）
+	Beds            []CardBed `json:"beds,omitempty"`
+
 	// 报警列表（来自 Redis: vital-focus:card:{card_id}:alarms）
 	Alarms          []AlarmItem `json:"alarms,omitempty"`
 }
 
+// CardBed 卡片上单张床位的实时数据及其住户
+type CardBed struct {
+	BedID      string  `json:"bed_id"`
+	BedName    string  `json:"bed_name,omitempty"`
+	ResidentID *string `json:"resident_id,omitempty"` // 床位绑定的住户
+	Nickname   *string `json:"nickname,omitempty"`    // 住户昵称
+
+	Heart        *int    `json:"heart,omitempty"`
+	Breath       *int    `json:"breath,omitempty"`
+	HeartSource  *string `json:"heart_source,omitempty"`  // 's'=sleepace, 'r'=radar, '-'=无数据
+	BreathSource *string `json:"breath_source,omitempty"` // 's'=sleepace, 'r'=radar, '-'=无数据
+
+	SleepStage           *int    `json:"sleep_stage,omitempty"` // 1=awake, 2=light sleep, 4=deep sleep
+	SleepStateSNOMEDCode *string `json:"sleep_state_snomed_code,omitempty"`
+	SleepStateDisplay    *string `json:"sleep_state_display,omitempty"`
+	BedStatus            *int    `json:"bed_status,omitempty"` // 0=in bed, 1=out of bed
+
+	PersonCount *int  `json:"person_count,omitempty"`
+	Postures    []int `json:"postures,omitempty"`
+}
+
 // CardResident 卡片关联的住户
 type CardResident struct {
 	ResidentID string `json:"resident_id"`
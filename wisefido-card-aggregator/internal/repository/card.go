@@ -546,8 +546,10 @@ type DeviceJSON struct {
 
 // ResidentJSON resident JSON format (for cards.residents JSONB field)
 type ResidentJSON struct {
-	ResidentID string `json:"resident_id"`
-	Nickname   string `json:"nickname"`
+	ResidentID string  `json:"resident_id"`
+	Nickname   string  `json:"nickname"`
+	UnitID     *string `json:"unit_id,omitempty"`
+	BedID      *string `json:"bed_id,omitempty"` // Bed the resident is bound to (bed -> resident association for per-bed vitals)
 }
 
 // ConvertDevicesToJSON converts device list to JSON
@@ -576,6 +578,8 @@ func ConvertResidentsToJSON(residents []ResidentInfo) ([]byte, error) {
 		residentJSONs = append(residentJSONs, ResidentJSON{
 			ResidentID: resident.ResidentID,
 			Nickname:   resident.Nickname,
+			UnitID:     resident.UnitID,
+			BedID:      resident.BedID,
 		})
 	}
 	return json.Marshal(residentJSONs)
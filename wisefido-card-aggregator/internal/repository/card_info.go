@@ -133,12 +133,27 @@ func (r *CardRepository) GetCardDevices(cardID string) ([]DeviceInfo, error) {
 		return nil, fmt.Errorf("failed to query card devices: %w", err)
 	}
 
-	// 解析 JSONB
-	var devices []DeviceInfo
-	if err := json.Unmarshal(devicesJSON, &devices); err != nil {
+	// 解析 JSONB（格式见 ConvertDevicesToJSON）
+	var deviceJSONs []DeviceJSON
+	if err := json.Unmarshal(devicesJSON, &deviceJSONs); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal devices JSON: %w", err)
 	}
 
+	devices := make([]DeviceInfo, 0, len(deviceJSONs))
+	for _, d := range deviceJSONs {
+		devices = append(devices, DeviceInfo{
+			DeviceID:    d.DeviceID,
+			DeviceName:  d.DeviceName,
+			DeviceType:  d.DeviceType,
+			DeviceModel: d.DeviceModel,
+			BoundBedID:  d.BedID,
+			BedName:     d.BedName,
+			BoundRoomID: d.RoomID,
+			RoomName:    d.RoomName,
+			UnitID:      d.UnitID,
+		})
+	}
+
 	return devices, nil
 }
 
@@ -159,12 +174,22 @@ func (r *CardRepository) GetCardResidents(cardID string) ([]ResidentInfo, error)
 		return nil, fmt.Errorf("failed to query card residents: %w", err)
 	}
 
-	// 解析 JSONB
-	var residents []ResidentInfo
-	if err := json.Unmarshal(residentsJSON, &residents); err != nil {
+	// 解析 JSONB（格式见 ConvertResidentsToJSON）
+	var residentJSONs []ResidentJSON
+	if err := json.Unmarshal(residentsJSON, &residentJSONs); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal residents JSON: %w", err)
 	}
 
+	residents := make([]ResidentInfo, 0, len(residentJSONs))
+	for _, r := range residentJSONs {
+		residents = append(residents, ResidentInfo{
+			ResidentID: r.ResidentID,
+			Nickname:   r.Nickname,
+			UnitID:     r.UnitID,
+			BedID:      r.BedID,
+		})
+	}
+
 	return residents, nil
 }
 
@@ -13,6 +13,7 @@ import (
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 	"owl-common/database"
+	"owl-common/events"
 	rediscommon "owl-common/redis"
 )
 
@@ -48,6 +49,10 @@ func NewAggregatorService(cfg *config.Config, logger *zap.Logger) (*AggregatorSe
 	
 	// 创建 CardCreator
 	cardCreator := aggregator.NewCardCreator(cardRepo, logger)
+	// 卡片重建后发布卡片变更通知（传感器融合服务据此失效卡片拓扑缓存）
+	cardCreator.SetChangeNotifier(func(change events.CardChange) error {
+		return events.PublishCardChange(context.Background(), redisClient, change)
+	})
 	
 	// 创建事件消费者（如果使用事件驱动模式）
 	var eventConsumer *consumer.EventConsumer
@@ -61,6 +66,8 @@ func NewAggregatorService(cfg *config.Config, logger *zap.Logger) (*AggregatorSe
 			cfg.Aggregator.ConsumerGroup,
 			cfg.Aggregator.ConsumerName,
 			int64(cfg.Aggregator.BatchSize),
+			time.Duration(cfg.Aggregator.ClaimMinIdle)*time.Second,
+			int64(cfg.Aggregator.MaxDeliveries),
 		)
 	}
 
@@ -22,7 +22,7 @@ func main() {
 	}
 	
 	// 初始化Logger
-	logger, err := logpkg.NewLogger(cfg.Log.Level, cfg.Log.Format)
+	logger, err := logpkg.NewLogger(cfg.Log.Level, cfg.Log.Format, "wisefido-data-transformer")
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
@@ -30,8 +30,7 @@ func main() {
 	
 	logger.Info("Starting wisefido-data-transformer service",
 		zap.String("version", "1.5.0"),
-		zap.String("radar_stream", cfg.Transformer.Streams.Radar),
-		zap.String("sleepace_stream", cfg.Transformer.Streams.Sleepace),
+		zap.Strings("input_streams", cfg.Transformer.Streams.Inputs),
 		zap.String("output_stream", cfg.Transformer.Streams.Output),
 	)
 	
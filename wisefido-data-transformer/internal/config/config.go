@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"owl-common/config"
 )
 
@@ -14,13 +16,23 @@ type Config struct {
 	Transformer struct {
 		// Redis Streams 配置
 		Streams struct {
-			Radar    string // 雷达数据流，如 "radar:data:stream"
-			Sleepace string // Sleepace 数据流，如 "sleepace:data:stream"
-			Output   string // 输出数据流，如 "iot:data:stream"
+			Inputs []string // 输入数据流（采集服务发布的 device.data 流），如 "radar:data:stream"、"sleepace:data:stream"
+			Output string   // 输出数据流，如 "iot:data:stream"
 		}
+		OutputPolicy  config.StreamConfig // 输出流保留策略与背压策略
 		ConsumerGroup string // 消费者组名称
 		ConsumerName  string // 消费者名称
 		BatchSize     int64  // 批量处理大小
+		WriteBatchSize     int // iot_timeseries 每批最多写入行数，默认 200
+		WriteBatchInterval int // iot_timeseries 缓冲最长等待时间（毫秒），默认 500 毫秒
+		ClaimMinIdle  int    // 待处理消息空闲多久后重新认领（秒），默认 60 秒
+		MaxDeliveries int    // 最大投递次数，超过后移入死信流（<stream>:dlq），默认 5
+		FieldMappingCacheTTL int // 字段映射规范缓存时间（秒），修改 field_mapping 后最迟在此时间后生效，默认 60 秒
+		UnmappedFlushInterval int // 未映射值计数写入 snomed_unmapped_codes 的间隔（秒），默认 30 秒
+		MinSignalQuality      int // 信号质量下限（0-100），低于此值的生命体征丢弃，0 表示不过滤，默认 30
+		ClockSkewTolerance    int // 设备时间与接收时间的容差（秒），超出时按设备时钟偏差校正或拒绝，默认 300 秒
+		MaxEventLateness      int // 迟到 / 补传数据的最大滞后时间（秒），超过且无法校正时拒绝，默认 86400 秒
+		SNOMEDReloadInterval  int // snomed_mapping 校验和轮询间隔（秒），变化时重新加载内存索引，0 表示只依赖变更通知，默认 60 秒
 	}
 	
 	Log struct {
@@ -46,12 +58,63 @@ func Load() (*Config, error) {
 	cfg.Redis.DB = 0
 	
 	// 数据转换服务配置
-	cfg.Transformer.Streams.Radar = getEnv("STREAM_RADAR", "radar:data:stream")
-	cfg.Transformer.Streams.Sleepace = getEnv("STREAM_SLEEPACE", "sleepace:data:stream")
+	// STREAM_INPUTS 逗号分隔；未设置时沿用 STREAM_RADAR / STREAM_SLEEPACE
+	cfg.Transformer.Streams.Inputs = splitList(getEnv("STREAM_INPUTS",
+		getEnv("STREAM_RADAR", "radar:data:stream")+","+getEnv("STREAM_SLEEPACE", "sleepace:data:stream")))
 	cfg.Transformer.Streams.Output = getEnv("STREAM_OUTPUT", "iot:data:stream")
+	cfg.Transformer.OutputPolicy = config.DefaultStreamConfig()
+	cfg.Transformer.OutputPolicy.LoadFromEnv("STREAM_OUTPUT")
 	cfg.Transformer.ConsumerGroup = getEnv("CONSUMER_GROUP", "data-transformer-group")
 	cfg.Transformer.ConsumerName = getEnv("CONSUMER_NAME", "data-transformer-1")
 	cfg.Transformer.BatchSize = 10
+	if v, err := strconv.Atoi(getEnv("IOT_WRITE_BATCH_SIZE", "200")); err == nil && v > 0 {
+		cfg.Transformer.WriteBatchSize = v
+	} else {
+		cfg.Transformer.WriteBatchSize = 200
+	}
+	if v, err := strconv.Atoi(getEnv("IOT_WRITE_BATCH_INTERVAL_MS", "500")); err == nil && v > 0 {
+		cfg.Transformer.WriteBatchInterval = v
+	} else {
+		cfg.Transformer.WriteBatchInterval = 500
+	}
+	if v, err := strconv.Atoi(getEnv("STREAM_CLAIM_MIN_IDLE", "60")); err == nil && v > 0 {
+		cfg.Transformer.ClaimMinIdle = v
+	} else {
+		cfg.Transformer.ClaimMinIdle = 60
+	}
+	if v, err := strconv.Atoi(getEnv("STREAM_MAX_DELIVERIES", "5")); err == nil && v > 0 {
+		cfg.Transformer.MaxDeliveries = v
+	} else {
+		cfg.Transformer.MaxDeliveries = 5
+	}
+	
+	cfg.Transformer.FieldMappingCacheTTL = 60
+	if v, err := strconv.Atoi(getEnv("FIELD_MAPPING_CACHE_TTL", "60")); err == nil && v >= 0 {
+		cfg.Transformer.FieldMappingCacheTTL = v
+	}
+	if v, err := strconv.Atoi(getEnv("SNOMED_UNMAPPED_FLUSH_INTERVAL", "30")); err == nil && v > 0 {
+		cfg.Transformer.UnmappedFlushInterval = v
+	} else {
+		cfg.Transformer.UnmappedFlushInterval = 30
+	}
+	cfg.Transformer.MinSignalQuality = 30
+	if v, err := strconv.Atoi(getEnv("MIN_SIGNAL_QUALITY", "30")); err == nil && v >= 0 && v <= 100 {
+		cfg.Transformer.MinSignalQuality = v
+	}
+	if v, err := strconv.Atoi(getEnv("CLOCK_SKEW_TOLERANCE", "300")); err == nil && v > 0 {
+		cfg.Transformer.ClockSkewTolerance = v
+	} else {
+		cfg.Transformer.ClockSkewTolerance = 300
+	}
+	if v, err := strconv.Atoi(getEnv("MAX_EVENT_LATENESS", "86400")); err == nil && v > 0 {
+		cfg.Transformer.MaxEventLateness = v
+	} else {
+		cfg.Transformer.MaxEventLateness = 86400
+	}
+	cfg.Transformer.SNOMEDReloadInterval = 60
+	if v, err := strconv.Atoi(getEnv("SNOMED_RELOAD_INTERVAL", "60")); err == nil && v >= 0 {
+		cfg.Transformer.SNOMEDReloadInterval = v
+	}
 	
 	cfg.Log.Level = getEnv("LOG_LEVEL", "info")
 	cfg.Log.Format = getEnv("LOG_FORMAT", "json")
@@ -66,3 +129,18 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+
+// splitList 解析逗号分隔的列表（忽略空项和重复项）
+func splitList(value string) []string {
+	var items []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	return items
+}
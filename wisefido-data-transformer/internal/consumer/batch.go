@@ -0,0 +1,156 @@
+package consumer
+
+import (
+	"context"
+	"time"
+	"wisefido-data-transformer/internal/models"
+	"wisefido-data-transformer/internal/repository"
+
+	"go.uber.org/zap"
+	rediscommon "owl-common/redis"
+)
+
+// iotWriter iot_timeseries 批量写入（*repository.IoTTimeSeriesRepository）
+type iotWriter interface {
+	GetDeviceLocations(ctx context.Context, deviceIDs []string) (map[string]repository.Location, error)
+	InsertBatch(ctx context.Context, rows []*models.StandardizedData) ([]int64, error)
+}
+
+// messageAcker 来源消息确认（*rediscommon.ReliableConsumer）
+type messageAcker interface {
+	Ack(ctx context.Context, msgs ...rediscommon.StreamMessage)
+	Fail(ctx context.Context, msg rediscommon.StreamMessage, err error)
+}
+
+// pendingRow 待写入的标准化数据及其来源消息（多目标帧一条消息对应多行）
+type pendingRow struct {
+	msg     rediscommon.StreamMessage
+	rawData *models.RawDeviceData
+	rows    []*models.StandardizedData
+}
+
+// enqueue 加入批量写入缓冲，达到批量大小时立即写入（在消费循环中执行，形成背压）
+func (c *StreamConsumer) enqueue(ctx context.Context, row pendingRow) {
+	c.mu.Lock()
+	c.pending = append(c.pending, row)
+	full := len(c.pending) >= c.config.Transformer.WriteBatchSize
+	c.mu.Unlock()
+
+	if full {
+		c.flush(ctx)
+	}
+}
+
+// runFlusher 按时间间隔写入缓冲（阻塞直到 ctx 结束）
+func (c *StreamConsumer) runFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.flush(ctx)
+		}
+	}
+}
+
+// flush 写入缓冲中的数据
+// 先批量查询设备位置，再在一个事务中 COPY 写入；提交成功后发布输出事件并确认来源消息。
+// 暂时性错误（数据库不可用等）时整批消息留在 PEL 中，空闲超时后重新认领；
+// 数据错误（类型 / 约束）时二分重试，只有出错的消息进入死信流，同批其他消息正常写入
+func (c *StreamConsumer) flush(ctx context.Context) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	c.resolveLocations(ctx, batchRows(batch))
+
+	start := time.Now()
+	written := c.write(ctx, batch)
+	c.logger.Info("Wrote iot_timeseries batch",
+		zap.Int("messages", len(batch)),
+		zap.Int("written", written),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+}
+
+// write 写入一批消息，返回成功写入的消息数
+func (c *StreamConsumer) write(ctx context.Context, batch []pendingRow) int {
+	rows := batchRows(batch)
+	ids, err := c.iotRepo.InsertBatch(ctx, rows)
+	if err == nil {
+		msgs := make([]rediscommon.StreamMessage, len(batch))
+		offset := 0
+		for i, p := range batch {
+			c.publish(ctx, p, ids[offset])
+			offset += len(p.rows)
+			msgs[i] = p.msg
+		}
+		c.reliable.Ack(ctx, msgs...)
+		return len(batch)
+	}
+
+	if !repository.IsDataError(err) {
+		c.logger.Error("Failed to write iot_timeseries batch",
+			zap.Int("messages", len(batch)),
+			zap.Int("rows", len(rows)),
+			zap.Error(err),
+		)
+		for _, p := range batch {
+			c.reliable.Fail(ctx, p.msg, err)
+		}
+		return 0
+	}
+
+	// 数据错误：一条消息的各行（多目标帧）必须一起写入，按消息二分定位
+	if len(batch) == 1 {
+		c.logger.Error("Rejected iot_timeseries rows",
+			zap.String("message_id", batch[0].msg.ID),
+			zap.Int("rows", len(rows)),
+			zap.Error(err),
+		)
+		c.reliable.Fail(ctx, batch[0].msg, rediscommon.Permanent(err))
+		return 0
+	}
+	mid := len(batch) / 2
+	return c.write(ctx, batch[:mid]) + c.write(ctx, batch[mid:])
+}
+
+// batchRows 一批消息的全部行（按消息顺序）
+func batchRows(batch []pendingRow) []*models.StandardizedData {
+	var rows []*models.StandardizedData
+	for _, p := range batch {
+		rows = append(rows, p.rows...)
+	}
+	return rows
+}
+
+// resolveLocations 填充 unit_id / room_id（每批一次查询），查询失败时位置留空
+func (c *StreamConsumer) resolveLocations(ctx context.Context, rows []*models.StandardizedData) {
+	seen := make(map[string]bool, len(rows))
+	deviceIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if !seen[row.DeviceID] {
+			seen[row.DeviceID] = true
+			deviceIDs = append(deviceIDs, row.DeviceID)
+		}
+	}
+
+	locations, err := c.iotRepo.GetDeviceLocations(ctx, deviceIDs)
+	if err != nil {
+		c.logger.Warn("Failed to get device locations", zap.Error(err))
+		return
+	}
+	for _, row := range rows {
+		loc := locations[row.DeviceID]
+		row.UnitID, row.RoomID = loc.UnitID, loc.RoomID
+	}
+}
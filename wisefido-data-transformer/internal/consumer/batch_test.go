@@ -0,0 +1,154 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wisefido-data-transformer/internal/models"
+	"wisefido-data-transformer/internal/repository"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"owl-common/config"
+	rediscommon "owl-common/redis"
+)
+
+// fakeWriter 含 poison 设备的批次写入失败（模拟违反约束），err 非空时所有写入失败
+type fakeWriter struct {
+	poison string
+	err    error
+	calls  int
+	nextID int64
+}
+
+func (f *fakeWriter) GetDeviceLocations(_ context.Context, _ []string) (map[string]repository.Location, error) {
+	return nil, nil
+}
+
+func (f *fakeWriter) InsertBatch(_ context.Context, rows []*models.StandardizedData) ([]int64, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, row := range rows {
+		if row.DeviceID == f.poison {
+			return nil, &pq.Error{Code: "23503", Message: "violates foreign key constraint"}
+		}
+	}
+	ids := make([]int64, len(rows))
+	for i := range rows {
+		f.nextID++
+		ids[i] = f.nextID
+	}
+	return ids, nil
+}
+
+type fakeAcker struct {
+	acked  []string
+	failed map[string]error
+}
+
+func (f *fakeAcker) Ack(_ context.Context, msgs ...rediscommon.StreamMessage) {
+	for _, m := range msgs {
+		f.acked = append(f.acked, m.ID)
+	}
+}
+
+func (f *fakeAcker) Fail(_ context.Context, msg rediscommon.StreamMessage, err error) {
+	f.failed[msg.ID] = err
+}
+
+func newBatchTestConsumer(t *testing.T, writer iotWriter) (*StreamConsumer, *fakeAcker, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	acker := &fakeAcker{failed: make(map[string]error)}
+	return &StreamConsumer{
+		publisher: rediscommon.NewStreamPublisher(client, "iot:data:stream", config.DefaultStreamConfig(), zap.NewNop()),
+		iotRepo:   writer,
+		reliable:  acker,
+		logger:    zap.NewNop(),
+	}, acker, client
+}
+
+func pending(id, deviceID string, rows int) pendingRow {
+	p := pendingRow{
+		msg:     rediscommon.StreamMessage{Stream: "radar:data:stream", ID: id},
+		rawData: &models.RawDeviceData{DeviceID: deviceID, DeviceType: "Radar"},
+	}
+	for i := 0; i < rows; i++ {
+		p.rows = append(p.rows, &models.StandardizedData{TenantID: "t1", DeviceID: deviceID, Timestamp: time.Now(), DataType: "observation"})
+	}
+	return p
+}
+
+func TestFlush_WritesBatchAndAcks(t *testing.T) {
+	writer := &fakeWriter{}
+	c, acker, client := newBatchTestConsumer(t, writer)
+	c.pending = []pendingRow{pending("1-0", "d1", 1), pending("2-0", "d2", 3)}
+
+	c.flush(context.Background())
+
+	if writer.calls != 1 {
+		t.Fatalf("expected one InsertBatch call, got %d", writer.calls)
+	}
+	if len(acker.acked) != 2 || len(acker.failed) != 0 {
+		t.Fatalf("acked %v failed %v", acker.acked, acker.failed)
+	}
+	if n, _ := client.XLen(context.Background(), "iot:data:stream").Result(); n != 2 {
+		t.Fatalf("expected 2 published messages, got %d", n)
+	}
+	if len(c.pending) != 0 {
+		t.Fatal("pending should be drained")
+	}
+}
+
+func TestFlush_PoisonRowOnlyFailsItsMessage(t *testing.T) {
+	writer := &fakeWriter{poison: "bad"}
+	c, acker, _ := newBatchTestConsumer(t, writer)
+	c.pending = []pendingRow{
+		pending("1-0", "d1", 1),
+		pending("2-0", "d2", 2),
+		pending("3-0", "bad", 1),
+		pending("4-0", "d4", 1),
+		pending("5-0", "d5", 1),
+	}
+
+	c.flush(context.Background())
+
+	if len(acker.acked) != 4 {
+		t.Fatalf("expected 4 acked messages, got %v", acker.acked)
+	}
+	if len(acker.failed) != 1 {
+		t.Fatalf("expected only the poison message to fail, got %v", acker.failed)
+	}
+	var permanent *rediscommon.PermanentError
+	if err := acker.failed["3-0"]; !errors.As(err, &permanent) {
+		t.Fatalf("poison message should fail permanently, got %v", err)
+	}
+}
+
+func TestFlush_TransientErrorRetriesWholeBatch(t *testing.T) {
+	writer := &fakeWriter{err: errors.New("connection refused")}
+	c, acker, _ := newBatchTestConsumer(t, writer)
+	c.pending = []pendingRow{pending("1-0", "d1", 1), pending("2-0", "d2", 1), pending("3-0", "d3", 1)}
+
+	c.flush(context.Background())
+
+	if writer.calls != 1 {
+		t.Fatalf("transient errors must not bisect, got %d calls", writer.calls)
+	}
+	if len(acker.acked) != 0 || len(acker.failed) != 3 {
+		t.Fatalf("acked %v failed %v", acker.acked, acker.failed)
+	}
+	var permanent *rediscommon.PermanentError
+	for id, err := range acker.failed {
+		if errors.As(err, &permanent) {
+			t.Fatalf("message %s should be retried, got permanent %v", id, err)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package consumer
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 	"wisefido-data-transformer/internal/config"
 	"wisefido-data-transformer/internal/models"
@@ -11,6 +13,7 @@ import (
 	
 	"go.uber.org/zap"
 	"github.com/go-redis/redis/v8"
+	"owl-common/events"
 	rediscommon "owl-common/redis"
 )
 
@@ -25,11 +28,19 @@ type StreamMessage struct {
 type StreamConsumer struct {
 	config              *config.Config
 	redisClient         *redis.Client
+	publisher           *rediscommon.StreamPublisher
 	snomedRepo          *repository.SNOMEDRepository
-	iotRepo             *repository.IoTTimeSeriesRepository
-	radarTransformer    *transformer.RadarTransformer
-	sleepaceTransformer *transformer.SleepaceTransformer
+	iotRepo             iotWriter
+	registry            *transformer.Registry // 按设备类型 / 型号 / 固件选择转换器
+	validator           *transformer.Validator // 数据质量校验
+	clock               *transformer.ClockGuard // 事件时间检查与时钟偏差校正
+	reliable            messageAcker
 	logger              *zap.Logger
+	
+	// iot_timeseries 批量写入缓冲（见 batch.go）
+	mu      sync.Mutex
+	pending []pendingRow
+	flushMu sync.Mutex
 }
 
 // NewStreamConsumer 创建 Streams 消费者
@@ -38,38 +49,65 @@ func NewStreamConsumer(
 	redisClient *redis.Client,
 	snomedRepo *repository.SNOMEDRepository,
 	iotRepo *repository.IoTTimeSeriesRepository,
-	radarTransformer *transformer.RadarTransformer,
-	sleepaceTransformer *transformer.SleepaceTransformer,
+	registry *transformer.Registry,
+	validator *transformer.Validator,
+	clock *transformer.ClockGuard,
 	logger *zap.Logger,
 ) *StreamConsumer {
 	return &StreamConsumer{
 		config:              cfg,
 		redisClient:         redisClient,
+		publisher:           rediscommon.NewStreamPublisher(redisClient, cfg.Transformer.Streams.Output, cfg.Transformer.OutputPolicy, logger),
 		snomedRepo:          snomedRepo,
 		iotRepo:             iotRepo,
-		radarTransformer:    radarTransformer,
-		sleepaceTransformer: sleepaceTransformer,
+		registry:            registry,
+		validator:           validator,
+		clock:               clock,
 		logger:              logger,
 	}
 }
 
 // Start 启动消费者
 func (c *StreamConsumer) Start(ctx context.Context) error {
-	// 创建消费者组
-	streams := []string{
-		c.config.Transformer.Streams.Radar,
-		c.config.Transformer.Streams.Sleepace,
-	}
+	// 创建可靠消费者（处理成功后 XACK，失败消息超时重新认领，多次失败进入死信流）
+	// 消息先进入批量写入缓冲（ErrDeferred），整批提交后再确认
+	reliable := rediscommon.NewReliableConsumer(c.redisClient, rediscommon.ConsumerOptions{
+		Streams:       c.config.Transformer.Streams.Inputs,
+		Group:         c.config.Transformer.ConsumerGroup,
+		Consumer:      c.config.Transformer.ConsumerName,
+		BatchSize:     c.config.Transformer.BatchSize,
+		ClaimMinIdle:  time.Duration(c.config.Transformer.ClaimMinIdle) * time.Second,
+		MaxDeliveries: int64(c.config.Transformer.MaxDeliveries),
+	}, c.logger)
 	
-	for _, stream := range streams {
-		if err := rediscommon.CreateConsumerGroup(ctx, c.redisClient, stream, c.config.Transformer.ConsumerGroup); err != nil {
-			return fmt.Errorf("failed to create consumer group for %s: %w", stream, err)
-		}
+	if err := reliable.Setup(ctx); err != nil {
+		return err
 	}
+	c.reliable = reliable
+	
+	// 输出流的发布统计和积压
+	go c.publisher.Report(ctx)
+	
+	// 按时间触发批量写入；退出前写入缓冲中的剩余数据
+	flushCtx, cancelFlush := context.WithCancel(context.Background())
+	flushDone := make(chan struct{})
+	go func() {
+		defer close(flushDone)
+		c.runFlusher(flushCtx, time.Duration(c.config.Transformer.WriteBatchInterval)*time.Millisecond)
+	}()
+	defer func() {
+		cancelFlush()
+		<-flushDone
+		c.flush(context.Background())
+	}()
 	
 	c.logger.Info("Stream consumer started",
 		zap.String("consumer_group", c.config.Transformer.ConsumerGroup),
 		zap.String("consumer_name", c.config.Transformer.ConsumerName),
+		zap.Strings("input_streams", c.config.Transformer.Streams.Inputs),
+		zap.Strings("device_types", c.registry.DeviceTypes()),
+		zap.Int("write_batch_size", c.config.Transformer.WriteBatchSize),
+		zap.Int("write_batch_interval_ms", c.config.Transformer.WriteBatchInterval),
 	)
 	
 	// 启动消费循环
@@ -81,14 +119,9 @@ func (c *StreamConsumer) Start(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		default:
-			radarErr := c.consumeStream(ctx, c.config.Transformer.Streams.Radar)
-			sleepaceErr := c.consumeStream(ctx, c.config.Transformer.Streams.Sleepace)
-			
-			// 如果两个流都出错，才进行退避
-			if radarErr != nil && sleepaceErr != nil {
-				c.logger.Error("Failed to consume streams", 
-					zap.Error(radarErr),
-					zap.NamedError("sleepace_error", sleepaceErr),
+			if err := reliable.Poll(ctx, c.handleMessage); err != nil {
+				c.logger.Error("Failed to consume streams",
+					zap.Error(err),
 					zap.Duration("backoff", backoffDuration),
 				)
 				
@@ -104,121 +137,146 @@ func (c *StreamConsumer) Start(ctx context.Context) error {
 					}
 				}
 			} else {
-				// 至少一个流成功时重置退避时间
+				// 成功时重置退避时间
 				backoffDuration = time.Second
-				
-				// 记录单个流的错误（但不中断）
-				if radarErr != nil {
-					c.logger.Error("Failed to consume radar stream", zap.Error(radarErr))
-				}
-				if sleepaceErr != nil {
-					c.logger.Error("Failed to consume sleepace stream", zap.Error(sleepaceErr))
-				}
 			}
 		}
 	}
 }
 
-// consumeStream 消费单个 Stream
-func (c *StreamConsumer) consumeStream(ctx context.Context, streamName string) error {
-	// 从 Stream 读取消息
-	messages, err := rediscommon.ReadFromStream(
-		ctx,
-		c.redisClient,
-		streamName,
-		c.config.Transformer.ConsumerGroup,
-		c.config.Transformer.ConsumerName,
-		c.config.Transformer.BatchSize,
-	)
-	
+// handleMessage 可靠消费者回调
+func (c *StreamConsumer) handleMessage(ctx context.Context, msg rediscommon.StreamMessage) error {
+	rawData, rows, err := c.processMessage(&StreamMessage{
+		ID:     msg.ID,
+		Stream: msg.Stream,
+		Values: msg.Values,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to read from stream %s: %w", streamName, err)
+		return err
 	}
-	
-	// 处理消息
-	for _, msg := range messages {
-		streamMsg := &StreamMessage{
-			ID:     msg.ID,
-			Stream: msg.Stream,
-			Values: msg.Values,
-		}
-		if err := c.processMessage(ctx, streamMsg); err != nil {
-			c.logger.Error("Failed to process message",
-				zap.String("stream", streamName),
-				zap.String("message_id", msg.ID),
-				zap.Error(err),
-			)
-			// 继续处理下一条消息，不中断
-		}
-	}
-	
-	return nil
+	// 进入批量写入缓冲，整批提交后确认
+	c.enqueue(ctx, pendingRow{msg: msg, rawData: rawData, rows: rows})
+	return rediscommon.ErrDeferred
 }
 
-// processMessage 处理单条消息
-func (c *StreamConsumer) processMessage(ctx context.Context, streamMsg *StreamMessage) error {
+// processMessage 解析、转换并校验单条消息（写入由批量缓冲完成）
+// 多目标帧（FrameTransformer）每个目标返回一条标准化数据
+func (c *StreamConsumer) processMessage(streamMsg *StreamMessage) (*models.RawDeviceData, []*models.StandardizedData, error) {
 	// 解析原始设备数据
 	rawData, err := models.ParseRawDeviceData(streamMsg.ID, streamMsg.Stream, streamMsg.Values)
 	if err != nil {
-		return fmt.Errorf("failed to parse raw device data: %w", err)
+		// 消息格式错误，重试无意义
+		return nil, nil, rediscommon.Permanent(fmt.Errorf("failed to parse raw device data: %w", err))
 	}
 	
-	// 根据设备类型选择转换器
-	var stdData *models.StandardizedData
-	switch rawData.DeviceType {
-	case "Radar":
-		stdData, err = c.radarTransformer.Transform(rawData)
-		if err != nil {
-			return fmt.Errorf("failed to transform radar data: %w", err)
-		}
-	case "SleepPad", "Sleepace":
-		stdData, err = c.sleepaceTransformer.Transform(rawData)
-		if err != nil {
-			return fmt.Errorf("failed to transform sleepace data: %w", err)
-		}
-	default:
-		return fmt.Errorf("unknown device type: %s", rawData.DeviceType)
-	}
-	
-	// 写入 PostgreSQL
-	id, err := c.iotRepo.Insert(stdData)
+	// 事件时间检查：校正有稳定偏差的设备时钟，无法校正的未来 / 过旧数据拒绝
+	clockStatus, err := c.clock.Check(rawData)
 	if err != nil {
-		return fmt.Errorf("failed to insert to iot_timeseries: %w", err)
+		return nil, nil, rediscommon.Permanent(fmt.Errorf("rejected event time from device %s: %w", rawData.DeviceID, err))
 	}
 	
-	// 更新位置信息（unit_id, room_id）
-	unitID, roomID, err := c.iotRepo.GetDeviceLocation(stdData.DeviceID)
+	// 根据设备类型 / 型号 / 固件选择转换器
+	t, err := c.registry.Lookup(rawData)
 	if err != nil {
-		c.logger.Warn("Failed to get device location", zap.Error(err))
+		return nil, nil, rediscommon.Permanent(err)
+	}
+	var rows []*models.StandardizedData
+	if ft, ok := t.(transformer.FrameTransformer); ok {
+		rows, err = ft.TransformFrame(rawData)
 	} else {
-		if err := c.iotRepo.UpdateLocation(id, unitID, roomID); err != nil {
-			c.logger.Warn("Failed to update location", zap.Error(err))
+		var stdData *models.StandardizedData
+		if stdData, err = t.Transform(rawData); err == nil {
+			rows = []*models.StandardizedData{stdData}
 		}
 	}
+	if err != nil {
+		return nil, nil, rediscommon.Permanent(fmt.Errorf("failed to transform %s data: %w", rawData.DeviceType, err))
+	}
+	
+	// 数据质量校验（不可信的生命体征丢弃或标记，写入 quality_flag）
+	for _, stdData := range rows {
+		stdData.IngestTime = rawData.IngestTime
+		c.validator.Validate(rawData, stdData)
+	}
+	if clockStatus != transformer.ClockOK {
+		c.logger.Debug("Device event time adjusted",
+			zap.String("device_id", rawData.DeviceID),
+			zap.String("clock_status", clockStatus),
+			zap.Time("event_time", rows[0].Timestamp),
+			zap.Time("ingest_time", rawData.IngestTime),
+		)
+	}
+	return rawData, rows, nil
+}
+
+// sampleOf 标准化数据中参与融合的字段（随 iot:data:stream 消息发布，融合服务无需回查数据库）
+func sampleOf(stdData *models.StandardizedData) events.IoTSample {
+	sample := events.IoTSample{
+		HeartRate:              stdData.HeartRate,
+		RespiratoryRate:        stdData.RespiratoryRate,
+		PostureCode:            stdData.PostureSNOMEDCode,
+		PostureDisplay:         stdData.PostureDisplay,
+		BedStatusCode:          stdData.BedStatusSNOMEDCode,
+		SleepStateCode:         stdData.SleepStateSNOMEDCode,
+		QualityFlag:            stdData.QualityFlag,
+		HeartRateQuality:       stdData.HeartRateQuality,
+		RespiratoryRateQuality: stdData.RespiratoryRateQuality,
+		RadarPosX:              stdData.RadarPosX,
+		RadarPosY:              stdData.RadarPosY,
+		RadarPosZ:              stdData.RadarPosZ,
+	}
+	if stdData.TrackingID != nil {
+		trackingID := strconv.Itoa(*stdData.TrackingID)
+		sample.TrackingID = &trackingID
+	}
+	return sample
+}
+
+// publish 发布到输出 Stream（触发下游服务），沿用上游 trace id
+// 每条来源消息发布一次：多目标帧以第一行的 id 代表整帧，并携带 frame_id 和目标数
+func (c *StreamConsumer) publish(ctx context.Context, row pendingRow, id int64) {
+	rawData, stdData := row.rawData, row.rows[0]
 	
-	// 发布到输出 Stream（触发下游服务）
 	// 注意：device_type 从 rawData.DeviceType 获取，已在 ParseRawDeviceData 中解析
-	outputData := map[string]interface{}{
-		"iot_timeseries_id": id,
-		"device_id":         stdData.DeviceID,
-		"tenant_id":         stdData.TenantID,
-		"device_type":       rawData.DeviceType, // 添加 device_type 字段
-		"timestamp":         stdData.Timestamp.Unix(),
-		"data_type":         stdData.DataType,
-		"category":          stdData.Category,
+	payload := events.IoTData{
+		IoTTimeSeriesID: id,
+		DeviceID:        stdData.DeviceID,
+		DeviceType:      rawData.DeviceType,
+		DataType:        stdData.DataType,
+		Category:        stdData.Category,
+	}
+	if stdData.FrameID != nil {
+		payload.FrameID = *stdData.FrameID
+		payload.TargetCount = len(row.rows)
+	}
+	for _, r := range row.rows {
+		payload.Samples = append(payload.Samples, sampleOf(r))
+	}
+	envelope := rediscommon.NewEnvelope(events.IoTDataSchema, "wisefido-data-transformer", stdData.TenantID, stdData.Timestamp, payload)
+	if rawData.TraceID != "" {
+		envelope.TraceID = rawData.TraceID
+	}
+	if !rawData.IngestTime.IsZero() {
+		envelope.IngestTime = rawData.IngestTime
 	}
 	
-	if _, err := rediscommon.PublishJSONToStream(ctx, c.redisClient, c.config.Transformer.Streams.Output, outputData); err != nil {
+	// 事件数据始终发布；普通观测数据在下游积压时可被抽样（数据已落库，仅影响融合触发频率）
+	priority := rediscommon.PriorityLow
+	for _, r := range row.rows {
+		if r.EventType != nil {
+			priority = rediscommon.PriorityNormal
+		}
+	}
+	if _, err := rediscommon.Publish(ctx, c.publisher, envelope, priority); err != nil {
 		c.logger.Warn("Failed to publish to output stream", zap.Error(err))
 	}
 	
-	c.logger.Info("Processed and transformed data",
+	c.logger.Debug("Processed and transformed data",
 		zap.String("device_id", stdData.DeviceID),
 		zap.Int64("iot_timeseries_id", id),
 		zap.String("data_type", stdData.DataType),
 		zap.String("category", stdData.Category),
+		zap.Int("rows", len(row.rows)),
+		zap.String("trace_id", envelope.TraceID),
 	)
-	
-	return nil
 }
-
@@ -2,7 +2,11 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
+	
+	"owl-common/events"
+	rediscommon "owl-common/redis"
 )
 
 // StreamMessage Redis Streams 消息
@@ -20,15 +24,31 @@ type RawDeviceData struct {
 	UID          string                 `json:"uid"`
 	DeviceType   string                 `json:"device_type"` // "Radar" or "SleepPad"
 	RawData      map[string]interface{} `json:"raw_data"`
-	Timestamp    int64                  `json:"timestamp"`
+	Timestamp    int64                  `json:"timestamp"` // 旧格式消息的事件时间（秒），解析时转换为 EventTime
 	Topic        string                 `json:"topic,omitempty"`
+	
+	// 事件时间（保留设备上报的亚秒精度，时钟偏差已校正），多目标帧的轨迹和行走速度依赖亚秒精度
+	EventTime time.Time `json:"-"`
+	
+	// 设备型号 / 固件版本（信封携带 device_store 登记值，原始数据中有上报时以上报值为准）
+	// 用于选择转换器和版本相关的 SNOMED 映射，未知时为空
+	Model           string `json:"model,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+	
+	// 来源消息 ID（Redis Stream 消息 ID，用作多目标帧的 frame_id）
+	MessageID string `json:"-"`
+	
+	// 信封信息（旧格式消息为空）
+	TraceID    string    `json:"-"`
+	IngestTime time.Time `json:"-"`
 }
 
 // StandardizedData 标准化后的数据（写入 PostgreSQL）
 type StandardizedData struct {
 	TenantID    string
 	DeviceID    string
-	Timestamp   time.Time
+	Timestamp   time.Time // 事件时间（设备时间，时钟偏差已校正）
+	IngestTime  time.Time // 接收时间（采集服务收到数据的时间）
 	DataType    string // "observation" or "alarm"
 	Category    string // FHIR Category
 	
@@ -66,10 +86,53 @@ type StandardizedData struct {
 	
 	// 原始数据（JSONB）
 	RawOriginal json.RawMessage
+	
+	// 多目标帧 ID（同一帧拆分出的各目标行相同，单目标数据为 nil）
+	FrameID *string
+	
+	// 数据质量标记（ok / outlier / out_of_range / low_signal / device_init），由校验阶段设置
+	// QualityFlag 为整行最严重的标记（统计用）；下游按各生命体征自己的标记决定是否采信
+	QualityFlag            string
+	HeartRateQuality       string
+	RespiratoryRateQuality string
+	
+	// 位置（写入前按设备绑定关系填充）
+	UnitID *string
+	RoomID *string
 }
 
 // ParseRawDeviceData 从 Redis Streams 消息解析原始设备数据
+// 优先按 device.data 信封解码；旧格式（data 字段）消息按原方式解析，便于滚动升级
 func ParseRawDeviceData(streamID, streamName string, values map[string]interface{}) (*RawDeviceData, error) {
+	env, err := rediscommon.Decode[events.DeviceData](rediscommon.StreamMessage{
+		Stream: streamName,
+		ID:     streamID,
+		Values: values,
+	}, events.DeviceDataSchema)
+	if err == nil {
+		rawData := &RawDeviceData{
+			DeviceID:     env.Payload.DeviceID,
+			TenantID:     env.TenantID,
+			SerialNumber: env.Payload.SerialNumber,
+			UID:          env.Payload.UID,
+			DeviceType:   env.Payload.DeviceType,
+			RawData:      env.Payload.RawData,
+			Timestamp:    env.EventTime.Unix(),
+			EventTime:    env.EventTime,
+			Topic:        env.Payload.Topic,
+			Model:           env.Payload.Model,
+			FirmwareVersion: env.Payload.FirmwareVersion,
+			MessageID:    streamID,
+			TraceID:      env.TraceID,
+			IngestTime:   env.IngestTime,
+		}
+		rawData.detectModel()
+		return rawData, nil
+	}
+	if !errors.Is(err, rediscommon.ErrNotEnvelope) {
+		return nil, err
+	}
+	
 	// 从 Values 中提取 data 字段（JSON 字符串）
 	dataStr, ok := values["data"].(string)
 	if !ok {
@@ -80,10 +143,29 @@ func ParseRawDeviceData(streamID, streamName string, values map[string]interface
 	if err := json.Unmarshal([]byte(dataStr), &rawData); err != nil {
 		return nil, err
 	}
+	rawData.MessageID = streamID
+	rawData.EventTime = time.Unix(rawData.Timestamp, 0)
+	rawData.detectModel()
 	
 	return &rawData, nil
 }
 
+// detectModel 从原始数据中提取设备上报的型号和固件版本（覆盖登记值）
+func (d *RawDeviceData) detectModel() {
+	for _, field := range []string{"model", "device_model"} {
+		if v, ok := d.RawData[field].(string); ok && v != "" {
+			d.Model = v
+			break
+		}
+	}
+	for _, field := range []string{"firmware_version", "fw_version", "firmware"} {
+		if v, ok := d.RawData[field].(string); ok && v != "" {
+			d.FirmwareVersion = v
+			break
+		}
+	}
+}
+
 // ErrInvalidDataFormat 数据格式错误
 var ErrInvalidDataFormat = &DataFormatError{Message: "invalid data format"}
 
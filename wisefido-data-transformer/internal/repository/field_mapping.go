@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// ErrFieldMappingNotFound 没有匹配的字段映射规范
+var ErrFieldMappingNotFound = errors.New("field mapping not found")
+
+// FieldMappingRepository 字段映射规范仓库（field_mapping 表，与 snomed_mapping 一同维护）
+type FieldMappingRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewFieldMappingRepository 创建字段映射规范仓库
+func NewFieldMappingRepository(db *sql.DB, logger *zap.Logger) *FieldMappingRepository {
+	return &FieldMappingRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetSpec 获取设备适用的字段映射规范（返回 spec JSON 和版本号）
+// 匹配优先级：指定型号优先于通用（model IS NULL），固件版本前缀越长越优先，同一范围内取最高版本
+func (r *FieldMappingRepository) GetSpec(deviceType, model, firmwareVersion string) ([]byte, int, error) {
+	query := `
+		SELECT spec, version
+		FROM field_mapping
+		WHERE device_type = $1
+		  AND is_active = TRUE
+		  AND (model IS NULL OR LOWER(model) = LOWER($2))
+		  AND (firmware_version IS NULL OR LEFT($3, LENGTH(firmware_version)) = firmware_version)
+		ORDER BY model IS NULL, LENGTH(COALESCE(firmware_version, '')) DESC, version DESC
+		LIMIT 1
+	`
+	
+	var spec []byte
+	var version int
+	err := r.db.QueryRow(query, deviceType, model, firmwareVersion).Scan(&spec, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, ErrFieldMappingNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to query field mapping: %w", err)
+	}
+	return spec, version, nil
+}
@@ -1,10 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"wisefido-data-transformer/internal/models"
 	
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -22,131 +25,205 @@ func NewIoTTimeSeriesRepository(db *sql.DB, logger *zap.Logger) *IoTTimeSeriesRe
 	}
 }
 
-// Insert 插入标准化数据到 iot_timeseries 表
-func (r *IoTTimeSeriesRepository) Insert(data *models.StandardizedData) (int64, error) {
-	query := `
-		INSERT INTO iot_timeseries (
-			tenant_id,
-			device_id,
-			timestamp,
-			data_type,
-			category,
-			tracking_id,
-			radar_pos_x,
-			radar_pos_y,
-			radar_pos_z,
-			posture_snomed_code,
-			posture_display,
-			event_type,
-			event_snomed_code,
-			event_display,
-			area_id,
-			heart_rate_code,
-			heart_rate_display,
-			heart_rate,
-			respiratory_rate_code,
-			respiratory_rate_display,
-			respiratory_rate,
-			sleep_state_snomed_code,
-			sleep_state_display,
-			bed_status_snomed_code,
-			bed_status_display,
-			raw_original,
-			unit_id,
-			room_id
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15,
-			$16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28
-		)
-		RETURNING id
-	`
+// iotTimeseriesColumns COPY 写入的列（id 预先从序列分配，便于发布输出事件）
+var iotTimeseriesColumns = []string{
+	"id",
+	"tenant_id",
+	"device_id",
+	"timestamp",
+	"ingest_time",
+	"data_type",
+	"category",
+	"tracking_id",
+	"radar_pos_x",
+	"radar_pos_y",
+	"radar_pos_z",
+	"posture_snomed_code",
+	"posture_display",
+	"event_type",
+	"event_snomed_code",
+	"event_display",
+	"area_id",
+	"heart_rate_code",
+	"heart_rate_display",
+	"heart_rate",
+	"respiratory_rate_code",
+	"respiratory_rate_display",
+	"respiratory_rate",
+	"sleep_state_snomed_code",
+	"sleep_state_display",
+	"bed_status_snomed_code",
+	"bed_status_display",
+	"raw_original", // BYTEA
+	"raw_format",
+	"quality_flag",
+	"hr_quality",
+	"rr_quality",
+	"frame_id",
+	"unit_id",
+	"room_id",
+}
+
+// InsertBatch 在一个事务中用 COPY 批量写入标准化数据（位置信息需预先填充）
+// 返回的 id 与 rows 一一对应；任一行失败时整批回滚
+func (r *IoTTimeSeriesRepository) InsertBatch(ctx context.Context, rows []*models.StandardizedData) ([]int64, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
+	// COPY 不支持 RETURNING，先从序列分配 id
+	ids, err := r.allocateIDs(ctx, tx, len(rows))
+	if err != nil {
+		return nil, err
+	}
 	
-	var id int64
-	err := r.db.QueryRow(
-		query,
-		data.TenantID,
-		data.DeviceID,
-		data.Timestamp,
-		data.DataType,
-		data.Category,
-		data.TrackingID,
-		data.RadarPosX,
-		data.RadarPosY,
-		data.RadarPosZ,
-		data.PostureSNOMEDCode,
-		data.PostureDisplay,
-		data.EventType,
-		data.EventSNOMEDCode,
-		data.EventDisplay,
-		data.AreaID,
-		data.HeartRateCode,
-		data.HeartRateDisplay,
-		data.HeartRate,
-		data.RespiratoryRateCode,
-		data.RespiratoryRateDisplay,
-		data.RespiratoryRate,
-		data.SleepStateSNOMEDCode,
-		data.SleepStateDisplay,
-		data.BedStatusSNOMEDCode,
-		data.BedStatusDisplay,
-		data.RawOriginal,
-		nil, // unit_id - 从 device 表 JOIN 获取
-		nil, // room_id - 从 device 表 JOIN 获取
-	).Scan(&id)
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("iot_timeseries", iotTimeseriesColumns...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	defer stmt.Close()
 	
+	for i, data := range rows {
+		if _, err := stmt.ExecContext(ctx,
+			ids[i],
+			data.TenantID,
+			data.DeviceID,
+			data.Timestamp,
+			data.IngestTime,
+			data.DataType,
+			data.Category,
+			data.TrackingID,
+			data.RadarPosX,
+			data.RadarPosY,
+			data.RadarPosZ,
+			data.PostureSNOMEDCode,
+			data.PostureDisplay,
+			data.EventType,
+			data.EventSNOMEDCode,
+			data.EventDisplay,
+			data.AreaID,
+			data.HeartRateCode,
+			data.HeartRateDisplay,
+			data.HeartRate,
+			data.RespiratoryRateCode,
+			data.RespiratoryRateDisplay,
+			data.RespiratoryRate,
+			data.SleepStateSNOMEDCode,
+			data.SleepStateDisplay,
+			data.BedStatusSNOMEDCode,
+			data.BedStatusDisplay,
+			[]byte(data.RawOriginal),
+			"json",
+			data.QualityFlag,
+			data.HeartRateQuality,
+			data.RespiratoryRateQuality,
+			data.FrameID,
+			data.UnitID,
+			data.RoomID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to copy iot_timeseries row: %w", err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed to copy iot_timeseries: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close copy: %w", err)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit iot_timeseries batch: %w", err)
+	}
+	return ids, nil
+}
+
+// IsDataError 写入失败是否由数据本身引起（数据异常 22xxx / 违反约束 23xxx），重试不会成功
+// 连接中断、超时等其他错误视为暂时性错误
+func IsDataError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	class := pqErr.Code.Class()
+	return class == "22" || class == "23"
+}
+
+// allocateIDs 从 iot_timeseries.id 序列分配 n 个 id
+func (r *IoTTimeSeriesRepository) allocateIDs(ctx context.Context, tx *sql.Tx, n int) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT nextval(pg_get_serial_sequence('iot_timeseries', 'id')) FROM generate_series(1, $1)`, n)
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert iot_timeseries: %w", err)
+		return nil, fmt.Errorf("failed to allocate iot_timeseries ids: %w", err)
 	}
+	defer rows.Close()
 	
-	return id, nil
+	ids := make([]int64, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan iot_timeseries id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to allocate iot_timeseries ids: %w", err)
+	}
+	if len(ids) != n {
+		return nil, fmt.Errorf("allocated %d iot_timeseries ids, expected %d", len(ids), n)
+	}
+	return ids, nil
 }
 
-// GetDeviceLocation 获取设备位置信息（unit_id, room_id）
-func (r *IoTTimeSeriesRepository) GetDeviceLocation(deviceID string) (unitID *string, roomID *string, err error) {
+// Location 设备位置（unit_id, room_id）
+type Location struct {
+	UnitID *string
+	RoomID *string
+}
+
+// GetDeviceLocations 批量查询设备位置（一次查询），不存在的设备不在结果中
+func (r *IoTTimeSeriesRepository) GetDeviceLocations(ctx context.Context, deviceIDs []string) (map[string]Location, error) {
 	query := `
 		SELECT 
+			d.device_id,
 			u.unit_id,
 			r.room_id
 		FROM devices d
 		LEFT JOIN beds b ON d.bound_bed_id = b.bed_id
 		LEFT JOIN rooms r ON COALESCE(b.room_id, d.bound_room_id) = r.room_id
 		LEFT JOIN units u ON r.unit_id = u.unit_id
-		WHERE d.device_id = $1
-		LIMIT 1
+		WHERE d.device_id = ANY($1)
 	`
 	
-	var uID, rID sql.NullString
-	err = r.db.QueryRow(query, deviceID).Scan(&uID, &rID)
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(deviceIDs))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil, fmt.Errorf("device not found: %s", deviceID)
-		}
-		return nil, nil, fmt.Errorf("failed to query device location: %w", err)
+		return nil, fmt.Errorf("failed to query device locations: %w", err)
 	}
+	defer rows.Close()
 	
-	if uID.Valid {
-		unitID = &uID.String
-	}
-	if rID.Valid {
-		roomID = &rID.String
+	locations := make(map[string]Location, len(deviceIDs))
+	for rows.Next() {
+		var deviceID string
+		var uID, rID sql.NullString
+		if err := rows.Scan(&deviceID, &uID, &rID); err != nil {
+			return nil, fmt.Errorf("failed to scan device location: %w", err)
+		}
+		var loc Location
+		if uID.Valid {
+			loc.UnitID = &uID.String
+		}
+		if rID.Valid {
+			loc.RoomID = &rID.String
+		}
+		locations[deviceID] = loc
 	}
-	
-	return unitID, roomID, nil
-}
-
-// UpdateLocation 更新记录的 unit_id 和 room_id
-func (r *IoTTimeSeriesRepository) UpdateLocation(id int64, unitID *string, roomID *string) error {
-	query := `
-		UPDATE iot_timeseries
-		SET unit_id = $1, room_id = $2
-		WHERE id = $3
-	`
-	
-	_, err := r.db.Exec(query, unitID, roomID, id)
-	if err != nil {
-		return fmt.Errorf("failed to update location: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query device locations: %w", err)
 	}
-	
-	return nil
+	return locations, nil
 }
-
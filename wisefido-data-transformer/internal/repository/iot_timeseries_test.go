@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wisefido-data-transformer/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+func newMockIoTRepo(t *testing.T) (*IoTTimeSeriesRepository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewIoTTimeSeriesRepository(db, zap.NewNop()), mock
+}
+
+func testRows(n int) []*models.StandardizedData {
+	rows := make([]*models.StandardizedData, n)
+	for i := range rows {
+		rows[i] = &models.StandardizedData{TenantID: "t1", DeviceID: "d1", Timestamp: time.Now(), DataType: "observation"}
+	}
+	return rows
+}
+
+func TestInsertBatch(t *testing.T) {
+	repo, mock := newMockIoTRepo(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT nextval`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(int64(11)).AddRow(int64(12)))
+	prep := mock.ExpectPrepare(`COPY "iot_timeseries"`)
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	ids, err := repo.InsertBatch(context.Background(), testRows(2))
+	if err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 11 || ids[1] != 12 {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInsertBatch_DataErrorRollsBack(t *testing.T) {
+	repo, mock := newMockIoTRepo(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT nextval`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(int64(1)))
+	prep := mock.ExpectPrepare(`COPY "iot_timeseries"`)
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithArgs().WillReturnError(&pq.Error{Code: "22P02", Message: "invalid input syntax"})
+	mock.ExpectRollback()
+
+	_, err := repo.InsertBatch(context.Background(), testRows(1))
+	if err == nil || !IsDataError(err) {
+		t.Fatalf("expected data error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsDataError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pq.Error{Code: "23505"}, true},
+		{&pq.Error{Code: "22003"}, true},
+		{&pq.Error{Code: "08006"}, false},
+		{&pq.Error{Code: "57P01"}, false},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := IsDataError(tt.err); got != tt.want {
+			t.Errorf("IsDataError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -2,13 +2,29 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync/atomic"
+	
 	"go.uber.org/zap"
 )
 
+// ErrMappingNotFound 没有匹配的 SNOMED 映射
+var ErrMappingNotFound = errors.New("snomed mapping not found")
+
+// 映射类型（snomed_mapping.mapping_type）
+const (
+	MappingTypePosture    = "posture"
+	MappingTypeEvent      = "event"
+	MappingTypeSleepStage = "sleep_stage"
+	MappingTypeBedStatus  = "bed_status"
+)
+
 // SNOMEDRepository SNOMED 映射仓库
+// 调用 Load 后查询走内存索引（Reload / Watch 原子替换），未加载时直接查询数据库
 type SNOMEDRepository struct {
 	db     *sql.DB
+	index  atomic.Pointer[snomedIndex]
 	logger *zap.Logger
 }
 
@@ -20,34 +36,50 @@ func NewSNOMEDRepository(db *sql.DB, logger *zap.Logger) *SNOMEDRepository {
 	}
 }
 
-// PostureMapping 姿态映射结果
-type PostureMapping struct {
+// Mapping SNOMED 映射结果
+type Mapping struct {
 	SNOMEDCode    *string
 	SNOMEDDisplay string
 	Category      string
 }
 
-// GetPostureMapping 获取姿态映射
-// sourceValue: 设备原始姿态值（如 "0", "1", "2", ...）
-// firmwareVersion: 固件版本（可选，用于版本特定的映射）
-func (r *SNOMEDRepository) GetPostureMapping(sourceValue string, firmwareVersion *string) (*PostureMapping, error) {
+// PostureMapping 姿态映射结果
+type PostureMapping = Mapping
+
+// EventMapping 事件映射结果
+type EventMapping = Mapping
+
+// GetMapping 获取映射
+// mappingType: 映射类型（posture / event / sleep_stage / bed_status）
+// sourceValue: 设备原始值或标准事件类型
+// firmwareVersion: 固件版本（可选），固件特定的映射优先（按前缀匹配，如 "2.1" 匹配 "2.1.5"，前缀越长越优先），
+// 其次通用映射（firmware_version IS NULL）
+func (r *SNOMEDRepository) GetMapping(mappingType, sourceValue string, firmwareVersion *string) (*Mapping, error) {
+	if idx := r.index.Load(); idx != nil {
+		if mapping, ok := idx.lookup(mappingType, sourceValue, stringValue(firmwareVersion)); ok {
+			return mapping, nil
+		}
+		return nil, fmt.Errorf("%w: mapping_type=%s, source_value=%s, firmware_version=%v",
+			ErrMappingNotFound, mappingType, sourceValue, stringValue(firmwareVersion))
+	}
+	
 	query := `
 		SELECT 
 			snomed_code,
 			snomed_display,
 			category
 		FROM snomed_mapping
-		WHERE mapping_type = 'posture'
-		  AND source_value = $1
-		  AND (firmware_version IS NULL OR firmware_version = $2)
-		ORDER BY firmware_version DESC NULLS LAST
+		WHERE mapping_type = $1
+		  AND source_value = $2
+		  AND (firmware_version IS NULL OR LEFT($3, LENGTH(firmware_version)) = firmware_version)
+		ORDER BY LENGTH(COALESCE(firmware_version, '')) DESC
 		LIMIT 1
 	`
 	
-	mapping := &PostureMapping{}
+	mapping := &Mapping{}
 	var snomedCode sql.NullString
 	
-	err := r.db.QueryRow(query, sourceValue, firmwareVersion).Scan(
+	err := r.db.QueryRow(query, mappingType, sourceValue, firmwareVersion).Scan(
 		&snomedCode,
 		&mapping.SNOMEDDisplay,
 		&mapping.Category,
@@ -55,9 +87,10 @@ func (r *SNOMEDRepository) GetPostureMapping(sourceValue string, firmwareVersion
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("posture mapping not found: source_value=%s, firmware_version=%v", sourceValue, firmwareVersion)
+			return nil, fmt.Errorf("%w: mapping_type=%s, source_value=%s, firmware_version=%v",
+				ErrMappingNotFound, mappingType, sourceValue, stringValue(firmwareVersion))
 		}
-		return nil, fmt.Errorf("failed to query posture mapping: %w", err)
+		return nil, fmt.Errorf("failed to query %s mapping: %w", mappingType, err)
 	}
 	
 	if snomedCode.Valid {
@@ -67,47 +100,23 @@ func (r *SNOMEDRepository) GetPostureMapping(sourceValue string, firmwareVersion
 	return mapping, nil
 }
 
-// EventMapping 事件映射结果
-type EventMapping struct {
-	SNOMEDCode    *string
-	SNOMEDDisplay string
-	Category      string
+// GetPostureMapping 获取姿态映射
+// sourceValue: 设备原始姿态值（如 "0", "1", "2", ...）
+// firmwareVersion: 固件版本（可选，用于版本特定的映射）
+func (r *SNOMEDRepository) GetPostureMapping(sourceValue string, firmwareVersion *string) (*PostureMapping, error) {
+	return r.GetMapping(MappingTypePosture, sourceValue, firmwareVersion)
 }
 
 // GetEventMapping 获取事件映射
 // sourceValue: 标准事件类型标识符（如 "ENTER_ROOM", "LEFT_BED", "FALL"）
-func (r *SNOMEDRepository) GetEventMapping(sourceValue string) (*EventMapping, error) {
-	query := `
-		SELECT 
-			snomed_code,
-			snomed_display,
-			category
-		FROM snomed_mapping
-		WHERE mapping_type = 'event'
-		  AND source_value = $1
-		LIMIT 1
-	`
-	
-	mapping := &EventMapping{}
-	var snomedCode sql.NullString
-	
-	err := r.db.QueryRow(query, sourceValue).Scan(
-		&snomedCode,
-		&mapping.SNOMEDDisplay,
-		&mapping.Category,
-	)
-	
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("event mapping not found: source_value=%s", sourceValue)
-		}
-		return nil, fmt.Errorf("failed to query event mapping: %w", err)
-	}
-	
-	if snomedCode.Valid {
-		mapping.SNOMEDCode = &snomedCode.String
-	}
-	
-	return mapping, nil
+// firmwareVersion: 固件版本（可选，用于版本特定的映射）
+func (r *SNOMEDRepository) GetEventMapping(sourceValue string, firmwareVersion *string) (*EventMapping, error) {
+	return r.GetMapping(MappingTypeEvent, sourceValue, firmwareVersion)
 }
 
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
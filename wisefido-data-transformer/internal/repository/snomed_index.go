@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"owl-common/events"
+)
+
+// snomedIndex snomed_mapping 内存索引（加载后只读，重新加载时整体替换）
+type snomedIndex struct {
+	checksum string
+	size     int
+	entries  map[string]*snomedEntry // mapping_type + "\x00" + source_value
+}
+
+// snomedEntry 同一原始值的通用映射和固件特定映射
+type snomedEntry struct {
+	generic    *Mapping
+	byFirmware map[string]*Mapping
+}
+
+func snomedKey(mappingType, sourceValue string) string {
+	return mappingType + "\x00" + sourceValue
+}
+
+// lookup 固件特定映射优先（按前缀匹配，前缀越长越优先），其次通用映射（与数据库查询语义一致）
+func (idx *snomedIndex) lookup(mappingType, sourceValue, firmwareVersion string) (*Mapping, bool) {
+	e, ok := idx.entries[snomedKey(mappingType, sourceValue)]
+	if !ok {
+		return nil, false
+	}
+	if firmwareVersion != "" {
+		var best *Mapping
+		bestLen := -1
+		for prefix, m := range e.byFirmware {
+			if len(prefix) > bestLen && strings.HasPrefix(firmwareVersion, prefix) {
+				best, bestLen = m, len(prefix)
+			}
+		}
+		if best != nil {
+			return best, true
+		}
+	}
+	if e.generic != nil {
+		return e.generic, true
+	}
+	return nil, false
+}
+
+// checksumQuery snomed_mapping 内容校验和（任意行增删改都会改变）
+const checksumQuery = `
+	SELECT COALESCE(md5(string_agg(t::text, '|' ORDER BY t::text)), '')
+	FROM snomed_mapping t
+`
+
+// Checksum 计算 snomed_mapping 当前内容的校验和
+func (r *SNOMEDRepository) Checksum(ctx context.Context) (string, error) {
+	var checksum string
+	if err := r.db.QueryRowContext(ctx, checksumQuery).Scan(&checksum); err != nil {
+		return "", fmt.Errorf("failed to checksum snomed_mapping: %w", err)
+	}
+	return checksum, nil
+}
+
+// Load 加载 snomed_mapping 到内存索引并原子替换
+func (r *SNOMEDRepository) Load(ctx context.Context) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin snomed load: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 同一快照内读取校验和和数据
+	var checksum string
+	if err := tx.QueryRowContext(ctx, checksumQuery).Scan(&checksum); err != nil {
+		return fmt.Errorf("failed to checksum snomed_mapping: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			mapping_type,
+			source_value,
+			snomed_code,
+			snomed_display,
+			category,
+			firmware_version
+		FROM snomed_mapping
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load snomed_mapping: %w", err)
+	}
+	defer rows.Close()
+
+	idx := &snomedIndex{checksum: checksum, entries: make(map[string]*snomedEntry)}
+	for rows.Next() {
+		var mappingType, sourceValue string
+		var snomedCode, firmwareVersion sql.NullString
+		m := &Mapping{}
+		if err := rows.Scan(&mappingType, &sourceValue, &snomedCode, &m.SNOMEDDisplay, &m.Category, &firmwareVersion); err != nil {
+			return fmt.Errorf("failed to scan snomed_mapping: %w", err)
+		}
+		if snomedCode.Valid {
+			m.SNOMEDCode = &snomedCode.String
+		}
+
+		key := snomedKey(mappingType, sourceValue)
+		e, ok := idx.entries[key]
+		if !ok {
+			e = &snomedEntry{}
+			idx.entries[key] = e
+		}
+		if firmwareVersion.Valid {
+			if e.byFirmware == nil {
+				e.byFirmware = make(map[string]*Mapping)
+			}
+			e.byFirmware[firmwareVersion.String] = m
+		} else {
+			e.generic = m
+		}
+		idx.size++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load snomed_mapping: %w", err)
+	}
+
+	r.index.Store(idx)
+	r.logger.Info("Loaded SNOMED mappings",
+		zap.Int("mappings", idx.size),
+		zap.String("checksum", checksum),
+	)
+	return nil
+}
+
+// reloadIfChanged 校验和变化时重新加载
+func (r *SNOMEDRepository) reloadIfChanged(ctx context.Context) error {
+	checksum, err := r.Checksum(ctx)
+	if err != nil {
+		return err
+	}
+	if idx := r.index.Load(); idx != nil && idx.checksum == checksum {
+		return nil
+	}
+	return r.Load(ctx)
+}
+
+// Watch 保持内存索引与 snomed_mapping 一致（阻塞直到 ctx 结束）
+// 收到映射变更通知时立即重新加载；pollInterval > 0 时定期比对校验和，兜底错过的通知
+func (r *SNOMEDRepository) Watch(ctx context.Context, client *redis.Client, pollInterval time.Duration) {
+	reload := func(reason string) {
+		if err := r.reloadIfChanged(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Warn("Failed to reload SNOMED mappings",
+				zap.String("reason", reason),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if client != nil {
+		go events.WatchMappingChanges(ctx, client, r.logger, func(change events.MappingChange) {
+			if change.Affects(events.MappingTableSNOMED) {
+				reload("notification")
+			}
+		})
+	}
+
+	if pollInterval <= 0 {
+		<-ctx.Done()
+		return
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reload("poll")
+		}
+	}
+}
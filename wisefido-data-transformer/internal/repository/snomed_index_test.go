@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"owl-common/events"
+)
+
+var snomedColumns = []string{"mapping_type", "source_value", "snomed_code", "snomed_display", "category", "firmware_version"}
+
+func newMockSNOMEDRepo(t *testing.T) (*SNOMEDRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewSNOMEDRepository(db, zap.NewNop()), mock
+}
+
+// expectLoad 期望一次完整加载（同一只读事务内读取校验和和数据）
+func expectLoad(mock sqlmock.Sqlmock, checksum string, rows *sqlmock.Rows) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("md5").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow(checksum))
+	mock.ExpectQuery("FROM snomed_mapping").WillReturnRows(rows)
+	mock.ExpectRollback()
+}
+
+func postureRows() *sqlmock.Rows {
+	return sqlmock.NewRows(snomedColumns).
+		AddRow(MappingTypePosture, "lying", "102538003", "Lying", "posture", nil).
+		AddRow(MappingTypePosture, "lying", "40199007", "Supine", "posture", "2.1.0").
+		AddRow(MappingTypePosture, "lying", "1000002", "Lying (v2)", "posture", "2").
+		AddRow(MappingTypePosture, "crawl", "1000001", "Crawling", "posture", "3.0.0").
+		AddRow(MappingTypeEvent, "fall", nil, "Fall", "event", nil)
+}
+
+func TestSNOMEDIndex_Lookup(t *testing.T) {
+	repo, mock := newMockSNOMEDRepo(t)
+	expectLoad(mock, "c1", postureRows())
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	fw := func(v string) *string { return &v }
+	tests := []struct {
+		name        string
+		mappingType string
+		source      string
+		firmware    *string
+		wantDisplay string
+		wantErr     bool
+	}{
+		{"generic", MappingTypePosture, "lying", nil, "Lying", false},
+		{"firmware specific wins", MappingTypePosture, "lying", fw("2.1.0"), "Supine", false},
+		{"unknown firmware falls back to generic", MappingTypePosture, "lying", fw("9.9.9"), "Lying", false},
+		{"firmware prefix", MappingTypePosture, "lying", fw("2.0.3"), "Lying (v2)", false},
+		{"longest firmware prefix wins", MappingTypePosture, "lying", fw("2.1.0-rc1"), "Supine", false},
+		{"non-matching longer prefix skipped", MappingTypePosture, "lying", fw("2.1.5"), "Lying (v2)", false},
+		{"firmware only mapping", MappingTypePosture, "crawl", fw("3.0.0"), "Crawling", false},
+		{"firmware only mapping by prefix", MappingTypePosture, "crawl", fw("3.0.0.1"), "Crawling", false},
+		{"firmware only mapping without firmware", MappingTypePosture, "crawl", nil, "", true},
+		{"firmware only mapping other firmware", MappingTypePosture, "crawl", fw("2.1.0"), "", true},
+		{"null snomed code", MappingTypeEvent, "fall", nil, "Fall", false},
+		{"mapping type scoped", MappingTypeEvent, "lying", nil, "", true},
+		{"unknown value", MappingTypePosture, "flying", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := repo.GetMapping(tt.mappingType, tt.source, tt.firmware)
+			if tt.wantErr {
+				if !errors.Is(err, ErrMappingNotFound) {
+					t.Fatalf("expected ErrMappingNotFound, got %+v, %v", m, err)
+				}
+				return
+			}
+			if err != nil || m.SNOMEDDisplay != tt.wantDisplay {
+				t.Fatalf("GetMapping = %+v, %v; want %s", m, err, tt.wantDisplay)
+			}
+		})
+	}
+
+	m, _ := repo.GetMapping(MappingTypeEvent, "fall", nil)
+	if m.SNOMEDCode != nil {
+		t.Fatalf("NULL snomed_code should map to nil, got %v", *m.SNOMEDCode)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("lookups must not query the database: %v", err)
+	}
+}
+
+// 校验和不变时不重新加载，变化时重新加载并原子替换
+func TestSNOMEDIndex_ReloadIfChanged(t *testing.T) {
+	repo, mock := newMockSNOMEDRepo(t)
+	expectLoad(mock, "c1", postureRows())
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	mock.ExpectQuery("md5").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("c1"))
+	if err := repo.reloadIfChanged(context.Background()); err != nil {
+		t.Fatalf("reloadIfChanged failed: %v", err)
+	}
+
+	mock.ExpectQuery("md5").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("c2"))
+	expectLoad(mock, "c2", sqlmock.NewRows(snomedColumns).
+		AddRow(MappingTypePosture, "lying", "102538003", "Lying down", "posture", nil))
+	if err := repo.reloadIfChanged(context.Background()); err != nil {
+		t.Fatalf("reloadIfChanged failed: %v", err)
+	}
+	if m, err := repo.GetMapping(MappingTypePosture, "lying", nil); err != nil || m.SNOMEDDisplay != "Lying down" {
+		t.Fatalf("reloaded mapping = %+v, %v", m, err)
+	}
+	if _, err := repo.GetMapping(MappingTypeEvent, "fall", nil); !errors.Is(err, ErrMappingNotFound) {
+		t.Fatalf("removed mapping should be gone, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 加载失败时保留旧索引
+func TestSNOMEDIndex_LoadFailureKeepsIndex(t *testing.T) {
+	repo, mock := newMockSNOMEDRepo(t)
+	expectLoad(mock, "c1", postureRows())
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("md5").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("c2"))
+	mock.ExpectQuery("FROM snomed_mapping").WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+	if err := repo.Load(context.Background()); err == nil {
+		t.Fatal("expected load error")
+	}
+	if m, err := repo.GetMapping(MappingTypePosture, "lying", nil); err != nil || m.SNOMEDDisplay != "Lying" {
+		t.Fatalf("previous index should be kept, got %+v, %v", m, err)
+	}
+}
+
+// 收到 snomed_mapping 变更通知时重新加载
+func TestSNOMEDRepository_WatchNotification(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	repo, mock := newMockSNOMEDRepo(t)
+	mock.MatchExpectationsInOrder(true)
+	expectLoad(mock, "c1", postureRows())
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// 订阅建立时按全部变更检查一次（校验和未变，不重新加载）
+	mock.ExpectQuery("md5").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("c1"))
+	// 变更通知后校验和已变化，重新加载
+	mock.ExpectQuery("md5").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("c2"))
+	expectLoad(mock, "c2", sqlmock.NewRows(snomedColumns).
+		AddRow(MappingTypePosture, "sitting", "33586001", "Sitting", "posture", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go repo.Watch(ctx, client, 0)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(mr.PubSubChannels("")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("watch did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// field_mapping 变更不影响 SNOMED 索引
+	if err := events.PublishMappingChange(ctx, client, events.MappingChange{Table: events.MappingTableField}); err != nil {
+		t.Fatal(err)
+	}
+	if err := events.PublishMappingChange(ctx, client, events.MappingChange{Table: events.MappingTableSNOMED}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if m, err := repo.GetMapping(MappingTypePosture, "sitting", nil); err == nil && m.SNOMEDDisplay == "Sitting" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("index not reloaded after notification")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
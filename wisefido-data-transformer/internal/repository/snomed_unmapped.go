@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// UnmappedCode 没有 SNOMED 映射的设备原始值（snomed_unmapped_codes 的唯一键）
+type UnmappedCode struct {
+	MappingType     string
+	SourceValue     string
+	DeviceType      string
+	Model           string
+	FirmwareVersion string
+}
+
+// IncrementUnmapped 累加未映射值的出现次数（不存在时创建）
+func (r *SNOMEDRepository) IncrementUnmapped(ctx context.Context, code UnmappedCode, count int64, sampleDeviceID string, seenAt time.Time) error {
+	query := `
+		INSERT INTO snomed_unmapped_codes (
+			mapping_type, source_value, device_type, model, firmware_version,
+			occurrences, sample_device_id, first_seen_at, last_seen_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (mapping_type, source_value, device_type, model, firmware_version) DO UPDATE SET
+			occurrences = snomed_unmapped_codes.occurrences + EXCLUDED.occurrences,
+			sample_device_id = EXCLUDED.sample_device_id,
+			last_seen_at = GREATEST(snomed_unmapped_codes.last_seen_at, EXCLUDED.last_seen_at)
+	`
+	
+	_, err := r.db.ExecContext(ctx, query,
+		code.MappingType,
+		code.SourceValue,
+		code.DeviceType,
+		code.Model,
+		code.FirmwareVersion,
+		count,
+		sampleDeviceID,
+		seenAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record unmapped code: %w", err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 	"wisefido-data-transformer/internal/config"
 	"wisefido-data-transformer/internal/consumer"
 	"wisefido-data-transformer/internal/repository"
@@ -12,6 +13,7 @@ import (
 	"go.uber.org/zap"
 	"github.com/go-redis/redis/v8"
 	"owl-common/database"
+	"owl-common/events"
 	rediscommon "owl-common/redis"
 )
 
@@ -23,8 +25,9 @@ type TransformerService struct {
 	redisClient      *redis.Client
 	snomedRepo          *repository.SNOMEDRepository
 	iotRepo             *repository.IoTTimeSeriesRepository
-	radarTransformer    *transformer.RadarTransformer
-	sleepaceTransformer *transformer.SleepaceTransformer
+	registry            *transformer.Registry
+	specs               *transformer.SpecResolver
+	unmapped            *transformer.UnmappedRecorder
 	consumer            *consumer.StreamConsumer
 }
 
@@ -45,10 +48,38 @@ func NewTransformerService(cfg *config.Config, logger *zap.Logger) (*Transformer
 	// 创建Repository
 	snomedRepo := repository.NewSNOMEDRepository(db, logger)
 	iotRepo := repository.NewIoTTimeSeriesRepository(db, logger)
+	fieldMappingRepo := repository.NewFieldMappingRepository(db, logger)
 	
-	// 创建Transformer
-	radarTransformer := transformer.NewRadarTransformer(snomedRepo, logger)
-	sleepaceTransformer := transformer.NewSleepaceTransformer(snomedRepo, logger)
+	// 加载 SNOMED 映射到内存索引（失败时先直接查询数据库，由 Watch 重试加载）
+	if err := snomedRepo.Load(context.Background()); err != nil {
+		logger.Warn("Failed to load SNOMED mappings, falling back to database queries", zap.Error(err))
+	}
+	
+	// 未映射的 SNOMED 原始值（定期写入 snomed_unmapped_codes）
+	unmapped := transformer.NewUnmappedRecorder(snomedRepo, logger)
+	
+	// 创建Transformer（实例化所有已注册的设备转换器）
+	specs := transformer.NewSpecResolver(
+		fieldMappingRepo,
+		time.Duration(cfg.Transformer.FieldMappingCacheTTL)*time.Second,
+		logger,
+	)
+	registry := transformer.NewRegistry(transformer.Dependencies{
+		SNOMEDRepo: snomedRepo,
+		Specs:      specs,
+		Unmapped: unmapped,
+		Logger:   logger,
+	})
+	
+	// 数据质量校验
+	validator := transformer.NewValidator(cfg.Transformer.MinSignalQuality, logger)
+	
+	// 事件时间检查
+	clock := transformer.NewClockGuard(
+		time.Duration(cfg.Transformer.ClockSkewTolerance)*time.Second,
+		time.Duration(cfg.Transformer.MaxEventLateness)*time.Second,
+		logger,
+	)
 	
 	// 创建Consumer
 	streamConsumer := consumer.NewStreamConsumer(
@@ -56,8 +87,9 @@ func NewTransformerService(cfg *config.Config, logger *zap.Logger) (*Transformer
 		redisClient,
 		snomedRepo,
 		iotRepo,
-		radarTransformer,
-		sleepaceTransformer,
+		registry,
+		validator,
+		clock,
 		logger,
 	)
 	
@@ -68,8 +100,9 @@ func NewTransformerService(cfg *config.Config, logger *zap.Logger) (*Transformer
 		redisClient:      redisClient,
 		snomedRepo:          snomedRepo,
 		iotRepo:             iotRepo,
-		radarTransformer:    radarTransformer,
-		sleepaceTransformer: sleepaceTransformer,
+		registry:            registry,
+		specs:               specs,
+		unmapped:            unmapped,
 		consumer:            streamConsumer,
 	}, nil
 }
@@ -78,6 +111,17 @@ func NewTransformerService(cfg *config.Config, logger *zap.Logger) (*Transformer
 func (s *TransformerService) Start(ctx context.Context) error {
 	s.logger.Info("Starting data transformer service components")
 	
+	// 映射变更：SNOMED 索引重新加载（通知 + 定期校验和比对），字段映射规范缓存失效
+	go s.snomedRepo.Watch(ctx, s.redisClient, time.Duration(s.config.Transformer.SNOMEDReloadInterval)*time.Second)
+	go events.WatchMappingChanges(ctx, s.redisClient, s.logger, func(change events.MappingChange) {
+		if change.Affects(events.MappingTableField) {
+			s.specs.Invalidate()
+		}
+	})
+	
+	// 定期记录未映射值
+	go s.unmapped.Run(ctx, time.Duration(s.config.Transformer.UnmappedFlushInterval)*time.Second)
+	
 	// 启动Stream消费者
 	if err := s.consumer.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start stream consumer: %w", err)
@@ -0,0 +1,135 @@
+package transformer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"wisefido-data-transformer/internal/models"
+
+	"go.uber.org/zap"
+	"owl-common/cache"
+)
+
+// 事件时间检查结果
+const (
+	ClockOK        = "ok"        // 设备时间与接收时间相差在容差内
+	ClockLate      = "late"      // 迟到 / 补传数据（保留设备时间）
+	ClockCorrected = "corrected" // 设备时钟有稳定偏差，按估计的偏差校正
+)
+
+// 时钟偏差估计参数
+const (
+	skewWindow     = 20             // 每个设备保留的近期偏差样本数
+	skewMinSamples = 5              // 样本数不足时不做校正
+	skewHistoryTTL = 24 * time.Hour // 超过此时间没有数据时重新估计
+)
+
+// ClockGuard 事件时间检查与设备时钟偏差校正（转换之前）
+//
+// 偏差 = 接收时间 - 设备时间，按设备保留近期消息的偏差样本（包括被拒绝的消息），
+// 多数样本与中位数相差在容差内时，中位数作为该设备稳定的时钟偏差估计：
+// - 稳定偏差超出容差且本条消息与之一致：先按偏差校正，再分类（设备时钟持续快 / 慢，包括最大迟到时间内的偏差）
+// - 偏差在容差内：使用设备时间
+// - 设备时间滞后不超过最大迟到时间：作为迟到 / 补传数据保留设备时间（不会成为融合的"最新"数据）
+// - 未来数据或滞后超过最大迟到时间：拒绝
+// 被拒绝消息的偏差作为候选样本计入：设备时钟持续快或严重滞后时，拒绝 skewMinSamples 条后形成稳定估计，
+// 之后的消息按偏差校正；偶发的错误时间只是中位数之外的个别样本，不影响估计。偏差缓慢漂移时估计随新样本更新
+type ClockGuard struct {
+	tolerance   time.Duration
+	maxLateness time.Duration
+	mu          sync.Mutex
+	offsets     *cache.TTL[[]int] // device_id -> 近期消息的偏差（秒）
+	now         func() time.Time
+	logger      *zap.Logger
+}
+
+// NewClockGuard 创建事件时间检查器
+func NewClockGuard(tolerance, maxLateness time.Duration, logger *zap.Logger) *ClockGuard {
+	return &ClockGuard{
+		tolerance:   tolerance,
+		maxLateness: maxLateness,
+		offsets:     cache.NewTTL[[]int](skewHistoryTTL, 0),
+		now:         time.Now,
+		logger:      logger,
+	}
+}
+
+// Check 检查并校正 rawData.EventTime，返回检查结果；无法校正时返回错误（消息应被拒绝）
+// 旧格式消息没有接收时间时以当前时间作为接收时间
+func (g *ClockGuard) Check(rawData *models.RawDeviceData) (string, error) {
+	if rawData.IngestTime.IsZero() {
+		rawData.IngestTime = g.now()
+	}
+	offset := int64(rawData.IngestTime.Sub(rawData.EventTime) / time.Second)
+	tolerance := int64(g.tolerance / time.Second)
+
+	// 设备时钟有稳定偏差：与偏差一致的消息按偏差校正
+	if skew, stable := g.estimate(rawData.DeviceID); stable && abs64(skew) > tolerance && abs64(offset-skew) <= tolerance {
+		g.logger.Debug("Corrected device clock skew",
+			zap.String("device_id", rawData.DeviceID),
+			zap.Int64("offset_sec", offset),
+			zap.Int64("skew_sec", skew),
+		)
+		rawData.EventTime = rawData.EventTime.Add(time.Duration(skew) * time.Second)
+		rawData.Timestamp = rawData.EventTime.Unix()
+		g.observe(rawData.DeviceID, offset)
+		return ClockCorrected, nil
+	}
+
+	if offset >= -tolerance && offset <= tolerance {
+		g.observe(rawData.DeviceID, offset)
+		return ClockOK, nil
+	}
+	if offset > 0 && offset <= int64(g.maxLateness/time.Second) {
+		g.observe(rawData.DeviceID, offset)
+		return ClockLate, nil
+	}
+	// 拒绝本条消息，但偏差计入候选样本，持续一致时形成稳定估计
+	g.observe(rawData.DeviceID, offset)
+	if offset < 0 {
+		return "", fmt.Errorf("event time %s is %ds ahead of ingest time", rawData.EventTime.UTC().Format(time.RFC3339), -offset)
+	}
+	return "", fmt.Errorf("event time %s is %ds behind ingest time", rawData.EventTime.UTC().Format(time.RFC3339), offset)
+}
+
+// estimate 返回设备的偏差估计（近期样本中位数）及估计是否稳定
+// 样本数不少于 skewMinSamples，且多数样本与中位数相差在容差内时视为稳定（补传数据的偏差分散，不会形成稳定估计）
+func (g *ClockGuard) estimate(deviceID string) (skew int64, stable bool) {
+	g.mu.Lock()
+	samples, _, _ := g.offsets.Get(deviceID)
+	g.mu.Unlock()
+	if len(samples) < skewMinSamples {
+		return 0, false
+	}
+
+	skew = int64(median(samples))
+	tolerance := int64(g.tolerance / time.Second)
+	agree := 0
+	for _, s := range samples {
+		if abs64(int64(s)-skew) <= tolerance {
+			agree++
+		}
+	}
+	return skew, agree >= skewMinSamples && agree*2 > len(samples)
+}
+
+// observe 记录消息的偏差样本（保留最近 skewWindow 个）
+func (g *ClockGuard) observe(deviceID string, offset int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	samples, _, _ := g.offsets.Get(deviceID)
+	next := make([]int, 0, skewWindow)
+	if len(samples) >= skewWindow {
+		samples = samples[len(samples)-skewWindow+1:]
+	}
+	next = append(append(next, samples...), int(offset))
+	g.offsets.Set(deviceID, next)
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
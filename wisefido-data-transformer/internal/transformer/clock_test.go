@@ -0,0 +1,166 @@
+package transformer
+
+import (
+	"testing"
+	"time"
+
+	"wisefido-data-transformer/internal/models"
+
+	"go.uber.org/zap"
+)
+
+var ingestAt = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// clockRaw 构建接收时间为 ingestAt、设备时间相差 offset 秒（正数为滞后）的消息
+func clockRaw(deviceID string, offset int64) *models.RawDeviceData {
+	return &models.RawDeviceData{DeviceID: deviceID, EventTime: ingestAt.Add(-time.Duration(offset) * time.Second), IngestTime: ingestAt}
+}
+
+func newClockGuard() *ClockGuard {
+	return NewClockGuard(5*time.Second, time.Hour, zap.NewNop())
+}
+
+func TestClockGuard_Classify(t *testing.T) {
+	tests := []struct {
+		name    string
+		offset  int64
+		want    string
+		wantErr bool
+	}{
+		{"on time", 0, ClockOK, false},
+		{"within tolerance ahead", -5, ClockOK, false},
+		{"within tolerance behind", 5, ClockOK, false},
+		{"late", 600, ClockLate, false},
+		{"late at max lateness", 3600, ClockLate, false},
+		{"too late", 3601, "", true},
+		{"future", -60, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := clockRaw("dev-1", tt.offset)
+			at := raw.EventTime
+			got, err := newClockGuard().Check(raw)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("Check = %q, %v; want %q, err=%v", got, err, tt.want, tt.wantErr)
+			}
+			if !raw.EventTime.Equal(at) {
+				t.Fatalf("event time changed without correction: %v -> %v", at, raw.EventTime)
+			}
+		})
+	}
+}
+
+// 旧格式消息没有接收时间时以当前时间作为接收时间
+func TestClockGuard_MissingIngestTime(t *testing.T) {
+	g := newClockGuard()
+	g.now = func() time.Time { return ingestAt }
+	raw := &models.RawDeviceData{DeviceID: "dev-1", EventTime: ingestAt}
+	if got, err := g.Check(raw); err != nil || got != ClockOK || !raw.IngestTime.Equal(ingestAt) {
+		t.Fatalf("Check = %q, %v, ingest=%v", got, err, raw.IngestTime)
+	}
+}
+
+// 最大迟到时间内的稳定偏差在估计稳定后被校正，而不是一直按迟到数据处理
+func TestClockGuard_CorrectsSteadySkewWithinLateness(t *testing.T) {
+	g := newClockGuard()
+	for i := 0; i < skewMinSamples; i++ {
+		if got, err := g.Check(clockRaw("dev-1", 60+int64(i%3))); err != nil || got != ClockLate {
+			t.Fatalf("sample %d: Check = %q, %v; want late while estimating", i, got, err)
+		}
+	}
+
+	raw := clockRaw("dev-1", 62)
+	got, err := g.Check(raw)
+	if err != nil || got != ClockCorrected {
+		t.Fatalf("Check = %q, %v; want corrected", got, err)
+	}
+	if offset := ingestAt.Sub(raw.EventTime); offset < -5*time.Second || offset > 5*time.Second {
+		t.Fatalf("corrected offset %v not within tolerance", offset)
+	}
+
+	// 与偏差不一致的补传数据仍按迟到处理
+	if got, err := g.Check(clockRaw("dev-1", 1800)); err != nil || got != ClockLate {
+		t.Fatalf("backfill: Check = %q, %v; want late", got, err)
+	}
+	// 其他设备不受影响
+	if got, err := g.Check(clockRaw("dev-2", 60)); err != nil || got != ClockLate {
+		t.Fatalf("other device: Check = %q, %v; want late", got, err)
+	}
+}
+
+// 偏差缓慢漂移到最大迟到时间之外时，估计随已接受的消息更新并继续校正
+func TestClockGuard_TracksDrift(t *testing.T) {
+	g := NewClockGuard(5*time.Second, 2*time.Minute, zap.NewNop())
+	offset := int64(100)
+	for i := 0; i < skewMinSamples; i++ {
+		if _, err := g.Check(clockRaw("dev-1", offset)); err != nil {
+			t.Fatalf("sample %d: %v", i, err)
+		}
+	}
+	for i := 0; offset <= 200; i++ {
+		offset = 100 + int64(i/5)
+		got, err := g.Check(clockRaw("dev-1", offset))
+		if err != nil || got != ClockCorrected {
+			t.Fatalf("offset %d: Check = %q, %v; want corrected", offset, got, err)
+		}
+	}
+}
+
+// 设备时钟持续快 10 分钟：前 skewMinSamples 条被拒绝，形成稳定估计后按偏差校正
+func TestClockGuard_LearnsRejectedSkew(t *testing.T) {
+	g := newClockGuard()
+	for i := 0; i < skewMinSamples; i++ {
+		if _, err := g.Check(clockRaw("dev-1", -600+int64(i%3))); err == nil {
+			t.Fatalf("sample %d: future data should be rejected while estimating", i)
+		}
+	}
+
+	raw := clockRaw("dev-1", -601)
+	got, err := g.Check(raw)
+	if err != nil || got != ClockCorrected {
+		t.Fatalf("Check = %q, %v; want corrected", got, err)
+	}
+	if offset := ingestAt.Sub(raw.EventTime); offset < -5*time.Second || offset > 5*time.Second {
+		t.Fatalf("corrected offset %v not within tolerance", offset)
+	}
+
+	// 偶发的错误时间不影响估计，仍被拒绝
+	if _, err := g.Check(clockRaw("dev-1", -86400)); err == nil {
+		t.Fatal("outlier should be rejected")
+	}
+	if got, err := g.Check(clockRaw("dev-1", -600)); err != nil || got != ClockCorrected {
+		t.Fatalf("after outlier: Check = %q, %v; want corrected", got, err)
+	}
+}
+
+// 偏差分散的补传数据不会形成稳定估计
+func TestClockGuard_BackfillNotStable(t *testing.T) {
+	g := newClockGuard()
+	for offset := int64(1200); offset > 0; offset -= 60 {
+		if got, err := g.Check(clockRaw("dev-1", offset)); err != nil || got == ClockCorrected {
+			t.Fatalf("offset %d: Check = %q, %v; backfill must not be corrected", offset, got, err)
+		}
+	}
+	if _, stable := g.estimate("dev-1"); stable {
+		t.Fatal("spread backfill offsets must not form a stable estimate")
+	}
+}
+
+// 校正保留亚秒精度
+func TestClockGuard_CorrectionKeepsSubSecond(t *testing.T) {
+	g := newClockGuard()
+	for i := 0; i < skewMinSamples; i++ {
+		g.Check(clockRaw("dev-1", 60))
+	}
+	raw := clockRaw("dev-1", 60)
+	raw.EventTime = raw.EventTime.Add(250 * time.Millisecond)
+	if got, err := g.Check(raw); err != nil || got != ClockCorrected {
+		t.Fatalf("Check = %q, %v; want corrected", got, err)
+	}
+	if want := ingestAt.Add(250 * time.Millisecond); !raw.EventTime.Equal(want) {
+		t.Fatalf("corrected event time = %v, want %v", raw.EventTime, want)
+	}
+	if raw.Timestamp != ingestAt.Unix() {
+		t.Fatalf("timestamp = %d, want %d", raw.Timestamp, ingestAt.Unix())
+	}
+}
@@ -0,0 +1,217 @@
+package transformer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"wisefido-data-transformer/internal/models"
+)
+
+// MappingSpec 字段映射规范（存储在 field_mapping.spec，按设备类型 / 型号 / 固件版本区分）
+//
+// 示例：
+//
+//	{"fields": [
+//	  {"target": "radar_pos_x", "source": "position_x", "unit": "dm"},
+//	  {"target": "heart_rate", "source": "vitals.hr", "min": 20, "max": 250, "invalid": [0, 255],
+//	   "code": "364075005", "display": "Heart rate"},
+//	  {"target": "posture", "source": "posture"}
+//	]}
+//
+// 多目标帧（如 {"targets": [{"tracking_id": 1, ...}, {"tracking_id": 2, ...}], "heart_rate": 72}）
+// 按 targets 指定的数组拆分，字段路径相对于每个目标对象（目标中没有时回退到帧级字段）。
+type MappingSpec struct {
+	Version int         `json:"version,omitempty"` // field_mapping.version（加载时填充）
+	Targets string      `json:"targets,omitempty"` // 多目标数组路径，默认 "targets"
+	Fields  []FieldSpec `json:"fields"`
+}
+
+// defaultTargetsPath 默认多目标数组路径
+const defaultTargetsPath = "targets"
+
+// targetsPath 多目标数组路径
+func (s *MappingSpec) targetsPath() string {
+	if s.Targets == "" {
+		return defaultTargetsPath
+	}
+	return s.Targets
+}
+
+// FieldSpec 单个字段映射
+type FieldSpec struct {
+	Target  string    `json:"target"`            // 目标 StandardizedData 字段（见 targets）
+	Source  string    `json:"source"`            // 源 JSON 路径，点分隔，数组下标用数字（如 "vitals.hr"、"targets.0.x"）
+	Unit    string    `json:"unit,omitempty"`    // 源单位，按目标字段的标准单位换算（如 "dm" -> cm）
+	Scale   *float64  `json:"scale,omitempty"`   // 附加乘数（单位换算之后）
+	Offset  float64   `json:"offset,omitempty"`  // 附加偏移（乘数之后）
+	Min     *float64  `json:"min,omitempty"`     // 有效范围下限（换算后），超出范围的值丢弃
+	Max     *float64  `json:"max,omitempty"`     // 有效范围上限（换算后）
+	Invalid []float64 `json:"invalid,omitempty"` // 设备无效值（按原始值比较，如 0、255）
+	Code    string    `json:"code,omitempty"`    // 测量项目 SNOMED CT 编码（生命体征）
+	Display string    `json:"display,omitempty"` // 测量项目显示名称
+}
+
+// 目标字段类型
+const (
+	targetNumeric = iota // 数值字段
+	targetPosture        // 查询 SNOMED 姿态映射
+	targetEvent          // 查询 SNOMED 事件映射
+)
+
+// targetDef 目标字段定义
+type targetDef struct {
+	kind int
+	unit string // 标准单位（数值字段）
+	set  func(stdData *models.StandardizedData, value int, field FieldSpec)
+}
+
+// targets 支持的目标字段
+var targets = map[string]targetDef{
+	"tracking_id": {kind: targetNumeric, set: func(d *models.StandardizedData, v int, _ FieldSpec) { d.TrackingID = &v }},
+	"radar_pos_x": {kind: targetNumeric, unit: "cm", set: func(d *models.StandardizedData, v int, _ FieldSpec) { d.RadarPosX = &v }},
+	"radar_pos_y": {kind: targetNumeric, unit: "cm", set: func(d *models.StandardizedData, v int, _ FieldSpec) { d.RadarPosY = &v }},
+	"radar_pos_z": {kind: targetNumeric, unit: "cm", set: func(d *models.StandardizedData, v int, _ FieldSpec) { d.RadarPosZ = &v }},
+	"heart_rate": {kind: targetNumeric, unit: "/min", set: func(d *models.StandardizedData, v int, f FieldSpec) {
+		d.HeartRate = &v
+		d.HeartRateCode, d.HeartRateDisplay = optional(f.Code), optional(f.Display)
+	}},
+	"respiratory_rate": {kind: targetNumeric, unit: "/min", set: func(d *models.StandardizedData, v int, f FieldSpec) {
+		d.RespiratoryRate = &v
+		d.RespiratoryRateCode, d.RespiratoryRateDisplay = optional(f.Code), optional(f.Display)
+	}},
+	"area_id":    {kind: targetNumeric, set: func(d *models.StandardizedData, v int, _ FieldSpec) { d.AreaID = &v }},
+	"posture":    {kind: targetPosture},
+	"event_type": {kind: targetEvent},
+}
+
+// unitFactors 源单位 -> 标准单位换算系数
+var unitFactors = map[string]map[string]float64{
+	"cm":   {"mm": 0.1, "cm": 1, "dm": 10, "m": 100},
+	"/min": {"/min": 1, "bpm": 1, "rpm": 1, "/s": 60, "hz": 60},
+}
+
+// Validate 校验映射规范（目标字段、源路径、单位）
+func (s *MappingSpec) Validate() error {
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("mapping spec has no fields")
+	}
+	for i, f := range s.Fields {
+		def, ok := targets[f.Target]
+		if !ok {
+			return fmt.Errorf("field %d: unknown target %q", i, f.Target)
+		}
+		if f.Source == "" {
+			return fmt.Errorf("field %d (%s): source is required", i, f.Target)
+		}
+		if f.Unit != "" {
+			if def.unit == "" {
+				return fmt.Errorf("field %d (%s): target has no unit", i, f.Target)
+			}
+			if _, ok := unitFactors[def.unit][strings.ToLower(f.Unit)]; !ok {
+				return fmt.Errorf("field %d (%s): cannot convert %q to %s", i, f.Target, f.Unit, def.unit)
+			}
+		}
+		if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+			return fmt.Errorf("field %d (%s): min greater than max", i, f.Target)
+		}
+	}
+	return nil
+}
+
+// ParseMappingSpec 解析并校验映射规范
+func ParseMappingSpec(data []byte) (*MappingSpec, error) {
+	spec := &MappingSpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping spec: %w", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// fieldValue 按映射规范取出数值字段（已换算单位，超出范围或无效值时 ok = false）
+func (f FieldSpec) fieldValue(rawData map[string]interface{}) (value int, ok bool, err error) {
+	raw, found := lookupPath(rawData, f.Source)
+	if !found || raw == nil {
+		return 0, false, nil
+	}
+	v, err := toFloat(raw)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", f.Source, err)
+	}
+	for _, invalid := range f.Invalid {
+		if v == invalid {
+			return 0, false, nil
+		}
+	}
+
+	if f.Unit != "" {
+		v *= unitFactors[targets[f.Target].unit][strings.ToLower(f.Unit)]
+	}
+	if f.Scale != nil {
+		v *= *f.Scale
+	}
+	v += f.Offset
+
+	if (f.Min != nil && v < *f.Min) || (f.Max != nil && v > *f.Max) {
+		return 0, false, nil
+	}
+	return int(math.Round(v)), true, nil
+}
+
+// lookupPath 按点分隔路径取值（支持嵌套对象和数组下标）
+func lookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	// 完整键优先（兼容键名本身含 "." 的情况）
+	if v, ok := data[path]; ok {
+		return v, true
+	}
+	var cur interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			v, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			cur = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// toFloat 数值转换（兼容字符串形式的数字）
+func toFloat(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case int:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case json.Number:
+		return val.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(val), 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to number", v)
+	}
+}
+
+// optional 空字符串返回 nil
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,79 @@
+package transformer
+
+import (
+	"errors"
+	"time"
+	"wisefido-data-transformer/internal/repository"
+
+	"go.uber.org/zap"
+	"owl-common/cache"
+)
+
+// SpecResolver 字段映射规范解析器（带缓存，修改 field_mapping 后在缓存过期时生效，无需重新部署）
+type SpecResolver struct {
+	repo   *repository.FieldMappingRepository
+	cache  *cache.TTL[*MappingSpec]
+	logger *zap.Logger
+}
+
+// NewSpecResolver 创建字段映射规范解析器
+// repo 为 nil 时始终使用内置默认规范
+func NewSpecResolver(repo *repository.FieldMappingRepository, ttl time.Duration, logger *zap.Logger) *SpecResolver {
+	return &SpecResolver{
+		repo:   repo,
+		cache:  cache.NewTTL[*MappingSpec](ttl, ttl),
+		logger: logger,
+	}
+}
+
+// Resolve 返回设备适用的映射规范，没有配置或配置无效时返回 fallback
+func (r *SpecResolver) Resolve(deviceType, model, firmwareVersion string, fallback *MappingSpec) *MappingSpec {
+	if r == nil || r.repo == nil {
+		return fallback
+	}
+	key := deviceType + "|" + model + "|" + firmwareVersion
+	if spec, missing, ok := r.cache.Get(key); ok {
+		if missing {
+			return fallback
+		}
+		return spec
+	}
+
+	data, version, err := r.repo.GetSpec(deviceType, model, firmwareVersion)
+	if err != nil {
+		if errors.Is(err, repository.ErrFieldMappingNotFound) {
+			r.cache.SetMissing(key)
+		} else {
+			// 数据库暂时不可用：不缓存，使用默认规范
+			r.logger.Warn("Failed to load field mapping, using built-in spec",
+				zap.String("device_type", deviceType),
+				zap.Error(err),
+			)
+		}
+		return fallback
+	}
+
+	spec, err := ParseMappingSpec(data)
+	if err != nil {
+		r.logger.Error("Invalid field mapping spec, using built-in spec",
+			zap.String("device_type", deviceType),
+			zap.String("model", model),
+			zap.String("firmware_version", firmwareVersion),
+			zap.Int("version", version),
+			zap.Error(err),
+		)
+		r.cache.SetMissing(key)
+		return fallback
+	}
+	spec.Version = version
+	r.cache.Set(key, spec)
+	return spec
+}
+
+// Invalidate 清空缓存（field_mapping 变更通知到达时调用）
+func (r *SpecResolver) Invalidate() {
+	if r == nil {
+		return
+	}
+	r.cache.Purge()
+}
@@ -0,0 +1,214 @@
+package transformer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"wisefido-data-transformer/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestParseMappingSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", `{"fields":[{"target":"radar_pos_x","source":"x","unit":"dm"},{"target":"posture","source":"posture"}]}`, false},
+		{"unit case insensitive", `{"fields":[{"target":"heart_rate","source":"hr","unit":"BPM"}]}`, false},
+		{"no fields", `{"fields":[]}`, true},
+		{"unknown target", `{"fields":[{"target":"weight","source":"w"}]}`, true},
+		{"missing source", `{"fields":[{"target":"heart_rate"}]}`, true},
+		{"unit on unitless target", `{"fields":[{"target":"area_id","source":"a","unit":"cm"}]}`, true},
+		{"incompatible unit", `{"fields":[{"target":"radar_pos_x","source":"x","unit":"bpm"}]}`, true},
+		{"min greater than max", `{"fields":[{"target":"heart_rate","source":"hr","min":200,"max":20}]}`, true},
+		{"invalid json", `{"fields":`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMappingSpec([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMappingSpec error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFieldSpec_FieldValue(t *testing.T) {
+	f64 := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name    string
+		field   FieldSpec
+		raw     string
+		want    int
+		wantOK  bool
+		wantErr bool
+	}{
+		{"plain", FieldSpec{Target: "heart_rate", Source: "hr"}, `{"hr":72}`, 72, true, false},
+		{"decimeter to centimeter", FieldSpec{Target: "radar_pos_x", Source: "x", Unit: "dm"}, `{"x":12}`, 120, true, false},
+		{"millimeter rounded", FieldSpec{Target: "radar_pos_x", Source: "x", Unit: "mm"}, `{"x":125}`, 13, true, false},
+		{"hertz to per minute", FieldSpec{Target: "respiratory_rate", Source: "rr", Unit: "Hz"}, `{"rr":0.25}`, 15, true, false},
+		{"scale and offset after unit", FieldSpec{Target: "radar_pos_y", Source: "y", Unit: "m", Scale: f64(-1), Offset: 500}, `{"y":1.5}`, 350, true, false},
+		{"string number", FieldSpec{Target: "heart_rate", Source: "hr"}, `{"hr":" 64 "}`, 64, true, false},
+		{"invalid raw value", FieldSpec{Target: "heart_rate", Source: "hr", Invalid: []float64{0, 255}}, `{"hr":255}`, 0, false, false},
+		{"invalid compared before conversion", FieldSpec{Target: "radar_pos_x", Source: "x", Unit: "dm", Invalid: []float64{10}}, `{"x":1}`, 10, true, false},
+		{"below min after conversion", FieldSpec{Target: "heart_rate", Source: "hr", Unit: "/s", Min: f64(20)}, `{"hr":0.3}`, 0, false, false},
+		{"above max", FieldSpec{Target: "heart_rate", Source: "hr", Max: f64(250)}, `{"hr":251}`, 0, false, false},
+		{"missing", FieldSpec{Target: "heart_rate", Source: "hr"}, `{}`, 0, false, false},
+		{"null", FieldSpec{Target: "heart_rate", Source: "hr"}, `{"hr":null}`, 0, false, false},
+		{"not a number", FieldSpec{Target: "heart_rate", Source: "hr"}, `{"hr":"fast"}`, 0, false, true},
+		{"object", FieldSpec{Target: "heart_rate", Source: "hr"}, `{"hr":{"v":1}}`, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.raw), &raw); err != nil {
+				t.Fatal(err)
+			}
+			got, ok, err := tt.field.fieldValue(raw)
+			if (err != nil) != tt.wantErr || ok != tt.wantOK || got != tt.want {
+				t.Fatalf("fieldValue = %d, %v, %v; want %d, %v, err=%v", got, ok, err, tt.want, tt.wantOK, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"vitals": {"hr": 72},
+		"targets": [{"x": 1}, {"x": 2}],
+		"a.b": "dotted",
+		"a": {"b": "nested"},
+		"n": null
+	}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path      string
+		want      interface{}
+		wantFound bool
+	}{
+		{"vitals.hr", float64(72), true},
+		{"targets.1.x", float64(2), true},
+		{"a.b", "dotted", true}, // 完整键优先
+		{"n", nil, true},
+		{"targets.2.x", nil, false},
+		{"targets.-1.x", nil, false},
+		{"targets.first", nil, false},
+		{"vitals.hr.value", nil, false},
+		{"vitals.rr", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, found := lookupPath(data, tt.path)
+			if found != tt.wantFound || got != tt.want {
+				t.Fatalf("lookupPath(%q) = %v, %v; want %v, %v", tt.path, got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+var fallbackSpec = &MappingSpec{Fields: []FieldSpec{{Target: "heart_rate", Source: "hr"}}}
+
+func newTestSpecResolver(t *testing.T) (*SpecResolver, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	repo := repository.NewFieldMappingRepository(db, zap.NewNop())
+	return NewSpecResolver(repo, time.Minute, zap.NewNop()), mock
+}
+
+func expectFieldMapping(mock sqlmock.Sqlmock, spec string, version int) {
+	mock.ExpectQuery("FROM field_mapping").WithArgs("Radar", "R60", "2.1.0").
+		WillReturnRows(sqlmock.NewRows([]string{"spec", "version"}).AddRow([]byte(spec), version))
+}
+
+func TestSpecResolver_Resolve(t *testing.T) {
+	r, mock := newTestSpecResolver(t)
+	expectFieldMapping(mock, `{"fields":[{"target":"radar_pos_x","source":"x","unit":"dm"}]}`, 4)
+
+	for i := 0; i < 2; i++ {
+		spec := r.Resolve("Radar", "R60", "2.1.0", fallbackSpec)
+		if spec == fallbackSpec || spec.Version != 4 || spec.Fields[0].Target != "radar_pos_x" {
+			t.Fatalf("Resolve #%d = %+v", i, spec)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected a single query: %v", err)
+	}
+
+	// 变更通知后重新加载
+	r.Invalidate()
+	expectFieldMapping(mock, `{"fields":[{"target":"radar_pos_y","source":"y"}]}`, 5)
+	if spec := r.Resolve("Radar", "R60", "2.1.0", fallbackSpec); spec.Version != 5 {
+		t.Fatalf("Resolve after Invalidate = %+v", spec)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 没有配置和配置无效时使用内置规范，并缓存该结果
+func TestSpecResolver_FallbackCached(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(sqlmock.Sqlmock)
+	}{
+		{"not found", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("FROM field_mapping").WillReturnError(sql.ErrNoRows)
+		}},
+		{"invalid spec", func(mock sqlmock.Sqlmock) {
+			expectFieldMapping(mock, `{"fields":[{"target":"weight","source":"w"}]}`, 1)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := newTestSpecResolver(t)
+			tt.expect(mock)
+			for i := 0; i < 2; i++ {
+				if spec := r.Resolve("Radar", "R60", "2.1.0", fallbackSpec); spec != fallbackSpec {
+					t.Fatalf("Resolve #%d = %+v, want fallback", i, spec)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("expected a single query: %v", err)
+			}
+		})
+	}
+}
+
+// 数据库错误时使用内置规范，不缓存
+func TestSpecResolver_ErrorNotCached(t *testing.T) {
+	r, mock := newTestSpecResolver(t)
+	mock.ExpectQuery("FROM field_mapping").WillReturnError(errors.New("connection reset"))
+	expectFieldMapping(mock, `{"fields":[{"target":"radar_pos_x","source":"x"}]}`, 2)
+
+	if spec := r.Resolve("Radar", "R60", "2.1.0", fallbackSpec); spec != fallbackSpec {
+		t.Fatalf("Resolve = %+v, want fallback", spec)
+	}
+	if spec := r.Resolve("Radar", "R60", "2.1.0", fallbackSpec); spec.Version != 2 {
+		t.Fatalf("Resolve after error = %+v", spec)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 未配置仓库（或解析器为 nil）时始终使用内置规范
+func TestSpecResolver_NoRepository(t *testing.T) {
+	var nilResolver *SpecResolver
+	for _, r := range []*SpecResolver{nilResolver, NewSpecResolver(nil, time.Minute, zap.NewNop())} {
+		if spec := r.Resolve("Radar", "R60", "2.1.0", fallbackSpec); spec != fallbackSpec {
+			t.Fatalf("Resolve = %+v, want fallback", spec)
+		}
+		r.Invalidate()
+	}
+}
@@ -3,186 +3,196 @@ package transformer
 import (
 	"encoding/json"
 	"fmt"
-	"strconv"
-	"time"
 	"wisefido-data-transformer/internal/models"
 	"wisefido-data-transformer/internal/repository"
 	
 	"go.uber.org/zap"
 )
 
+func init() {
+	Register(Registration{
+		DeviceType: "Radar",
+		Factory: func(deps Dependencies) Transformer {
+			return NewRadarTransformer(deps.SNOMEDRepo, deps.Specs, deps.Unmapped, deps.Logger)
+		},
+	})
+}
+
+// defaultRadarSpec 内置雷达字段映射（field_mapping 中没有匹配的规范时使用）
+var defaultRadarSpec = &MappingSpec{
+	Fields: []FieldSpec{
+		{Target: "tracking_id", Source: "tracking_id"},
+		{Target: "radar_pos_x", Source: "position_x", Unit: "dm"},
+		{Target: "radar_pos_y", Source: "position_y", Unit: "dm"},
+		{Target: "radar_pos_z", Source: "position_z", Unit: "dm"},
+		{Target: "posture", Source: "posture"},
+		{Target: "heart_rate", Source: "heart_rate", Code: "364075005", Display: "Heart rate"},
+		{Target: "respiratory_rate", Source: "breath_rate", Code: "86290005", Display: "Respiratory rate"},
+		{Target: "event_type", Source: "event_type"},
+		{Target: "area_id", Source: "area_id"},
+	},
+}
+
 // RadarTransformer 雷达数据转换器
+// 字段名、单位换算、有效范围和测量项目编码由映射规范描述（按型号 / 固件版本从 field_mapping 加载）
 type RadarTransformer struct {
 	snomedRepo *repository.SNOMEDRepository
+	specs      *SpecResolver
+	unmapped   *UnmappedRecorder
 	logger     *zap.Logger
 }
 
 // NewRadarTransformer 创建雷达数据转换器
-func NewRadarTransformer(snomedRepo *repository.SNOMEDRepository, logger *zap.Logger) *RadarTransformer {
+func NewRadarTransformer(snomedRepo *repository.SNOMEDRepository, specs *SpecResolver, unmapped *UnmappedRecorder, logger *zap.Logger) *RadarTransformer {
 	return &RadarTransformer{
 		snomedRepo: snomedRepo,
+		specs:      specs,
+		unmapped:   unmapped,
 		logger:     logger,
 	}
 }
 
-// Transform 转换雷达原始数据为标准格式
+// Transform 转换雷达原始数据为标准格式（多目标帧只返回第一个目标，完整结果见 TransformFrame）
 func (t *RadarTransformer) Transform(rawData *models.RawDeviceData) (*models.StandardizedData, error) {
-	stdData := &models.StandardizedData{
-		TenantID:  rawData.TenantID,
-		DeviceID:  rawData.DeviceID,
-		Timestamp: time.Unix(rawData.Timestamp, 0),
-		DataType:  "observation", // 默认为 observation，告警事件由 alarm 服务判断
+	rows, err := t.TransformFrame(rawData)
+	if err != nil {
+		return nil, err
 	}
+	return rows[0], nil
+}
+
+// TransformFrame 转换雷达原始数据
+// 携带目标数组的多目标帧拆分为每个目标一行，以 frame_id（来源消息 ID）关联；
+// 目标中没有的字段（帧级字段，如生命体征、事件）只写入第一个目标的行，避免重复计数
+func (t *RadarTransformer) TransformFrame(rawData *models.RawDeviceData) ([]*models.StandardizedData, error) {
+	spec := t.specs.Resolve(rawData.DeviceType, rawData.Model, rawData.FirmwareVersion, defaultRadarSpec)
 	
-	// 序列化原始数据
+	// 序列化原始数据（多目标帧：第一行保存完整帧，其余行保存各自的目标对象）
 	rawOriginal, err := json.Marshal(rawData.RawData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal raw data: %w", err)
 	}
-	stdData.RawOriginal = rawOriginal
-	
-	// 转换轨迹数据
-	if err := t.transformTrackingData(rawData.RawData, stdData); err != nil {
-		t.logger.Warn("Failed to transform tracking data", zap.Error(err))
-	}
-	
-	// 转换姿态数据
-	if err := t.transformPosture(rawData.RawData, stdData); err != nil {
-		t.logger.Warn("Failed to transform posture", zap.Error(err))
-	}
-	
-	// 转换生命体征数据
-	if err := t.transformVitalSigns(rawData.RawData, stdData); err != nil {
-		t.logger.Warn("Failed to transform vital signs", zap.Error(err))
-	}
-	
-	// 转换事件数据
-	if err := t.transformEvent(rawData.RawData, stdData); err != nil {
-		t.logger.Warn("Failed to transform event", zap.Error(err))
-	}
-	
-	// 确定 category（根据转换后的数据）
-	t.determineCategory(stdData)
-	
-	return stdData, nil
-}
-
-// transformTrackingData 转换轨迹数据
-func (t *RadarTransformer) transformTrackingData(rawData map[string]interface{}, stdData *models.StandardizedData) error {
-	// 提取 tracking_id
-	if trackingID, ok := rawData["tracking_id"]; ok {
-		if id, err := parseInt(trackingID); err == nil {
-			stdData.TrackingID = &id
-		}
-	}
-	
-	// 提取位置坐标（单位转换：dm -> cm）
-	if posX, ok := rawData["position_x"]; ok {
-		if x, err := parseInt(posX); err == nil {
-			// 假设原始数据是 dm，转换为 cm
-			xCm := x * 10
-			stdData.RadarPosX = &xCm
+	sources := []map[string]interface{}{rawData.RawData}
+	originals := [][]byte{rawOriginal}
+	var frameID *string
+	if items := frameTargets(rawData.RawData, spec.targetsPath()); len(items) > 0 {
+		sources, originals = sources[:0], originals[:0]
+		for i, item := range items {
+			source := item
+			if i == 0 {
+				source = withFrameFields(rawData.RawData, item)
+			}
+			original := rawOriginal
+			if i > 0 {
+				if original, err = json.Marshal(item); err != nil {
+					return nil, fmt.Errorf("failed to marshal target %d: %w", i, err)
+				}
+			}
+			sources = append(sources, source)
+			originals = append(originals, original)
 		}
-	}
-	
-	if posY, ok := rawData["position_y"]; ok {
-		if y, err := parseInt(posY); err == nil {
-			yCm := y * 10
-			stdData.RadarPosY = &yCm
+		id := rawData.MessageID
+		frameID = &id
+	}
+	
+	rows := make([]*models.StandardizedData, 0, len(sources))
+	for i, source := range sources {
+		stdData := &models.StandardizedData{
+			TenantID:    rawData.TenantID,
+			DeviceID:    rawData.DeviceID,
+			Timestamp:   rawData.EventTime,
+			DataType:    "observation", // 默认为 observation，告警事件由 alarm 服务判断
+			RawOriginal: originals[i],
+			FrameID:     frameID,
 		}
-	}
-	
-	if posZ, ok := rawData["position_z"]; ok {
-		if z, err := parseInt(posZ); err == nil {
-			zCm := z * 10
-			stdData.RadarPosZ = &zCm
+		
+		// 按映射规范转换轨迹、姿态、生命体征和事件数据
+		for _, field := range spec.Fields {
+			if err := t.applyField(field, source, rawData, stdData); err != nil {
+				t.logger.Warn("Failed to transform field",
+					zap.String("target", field.Target),
+					zap.String("source", field.Source),
+					zap.Int("spec_version", spec.Version),
+					zap.Error(err),
+				)
+			}
 		}
+		
+		// 确定 category（根据转换后的数据）
+		t.determineCategory(stdData)
+		rows = append(rows, stdData)
 	}
-	
-	return nil
+	return rows, nil
 }
 
-// transformPosture 转换姿态数据
-func (t *RadarTransformer) transformPosture(rawData map[string]interface{}, stdData *models.StandardizedData) error {
-	// 提取姿态值
-	postureValue, ok := rawData["posture"]
+// frameTargets 取出多目标帧的目标数组（非对象元素忽略），不是多目标帧时返回 nil
+func frameTargets(data map[string]interface{}, path string) []map[string]interface{} {
+	value, ok := lookupPath(data, path)
 	if !ok {
-		return nil // 没有姿态数据
+		return nil
 	}
-	
-	// 转换为字符串
-	postureStr := fmt.Sprintf("%v", postureValue)
-	
-	// 查询 SNOMED 映射
-	mapping, err := t.snomedRepo.GetPostureMapping(postureStr, nil)
-	if err != nil {
-		return fmt.Errorf("failed to get posture mapping: %w", err)
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil
 	}
-	
-	stdData.PostureSNOMEDCode = mapping.SNOMEDCode
-	stdData.PostureDisplay = &mapping.SNOMEDDisplay
-	
-	return nil
+	items := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		if item, ok := v.(map[string]interface{}); ok {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
-// transformVitalSigns 转换生命体征数据
-func (t *RadarTransformer) transformVitalSigns(rawData map[string]interface{}, stdData *models.StandardizedData) error {
-	// 心率
-	if hr, ok := rawData["heart_rate"]; ok {
-		if heartRate, err := parseInt(hr); err == nil {
-			stdData.HeartRate = &heartRate
-			// 固定值：心率测量项目的 SNOMED CT 编码
-			hrCode := "364075005"
-			hrDisplay := "Heart rate"
-			stdData.HeartRateCode = &hrCode
-			stdData.HeartRateDisplay = &hrDisplay
-		}
+// withFrameFields 帧级字段与目标字段合并（目标字段优先）
+func withFrameFields(frame, target map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(frame)+len(target))
+	for k, v := range frame {
+		merged[k] = v
 	}
-	
-	// 呼吸率
-	if br, ok := rawData["breath_rate"]; ok {
-		if breathRate, err := parseInt(br); err == nil {
-			stdData.RespiratoryRate = &breathRate
-			// 固定值：呼吸频率测量项目的 SNOMED CT 编码
-			rrCode := "86290005"
-			rrDisplay := "Respiratory rate"
-			stdData.RespiratoryRateCode = &rrCode
-			stdData.RespiratoryRateDisplay = &rrDisplay
-		}
+	for k, v := range target {
+		merged[k] = v
 	}
-	
-	return nil
+	return merged
 }
 
-// transformEvent 转换事件数据
-func (t *RadarTransformer) transformEvent(rawData map[string]interface{}, stdData *models.StandardizedData) error {
-	// 提取事件类型（如果有）
-	// 注意：事件类型可能是原始值，需要映射到标准事件类型
-	// 这里假设原始数据中已经有标准事件类型，或者需要根据其他字段判断
-	
-	// 示例：如果有 event_type 字段
-	if eventType, ok := rawData["event_type"]; ok {
-		eventTypeStr := fmt.Sprintf("%v", eventType)
-		
-		// 查询事件映射
-		mapping, err := t.snomedRepo.GetEventMapping(eventTypeStr)
-		if err != nil {
-			// 如果映射不存在，可能是标准事件类型，直接使用
-			stdData.EventType = &eventTypeStr
-		} else {
-			stdData.EventType = &eventTypeStr
-			stdData.EventSNOMEDCode = mapping.SNOMEDCode
-			stdData.EventDisplay = &mapping.SNOMEDDisplay
+// applyField 按单个字段映射写入标准化数据（source 为取值的 JSON 对象，rawData 用于 SNOMED 查询）
+func (t *RadarTransformer) applyField(field FieldSpec, source map[string]interface{}, rawData *models.RawDeviceData, stdData *models.StandardizedData) error {
+	def := targets[field.Target]
+	switch def.kind {
+	case targetPosture:
+		value, ok := lookupPath(source, field.Source)
+		if !ok {
+			return nil // 没有姿态数据
 		}
-	}
-	
-	// 提取区域 ID
-	if areaID, ok := rawData["area_id"]; ok {
-		if id, err := parseInt(areaID); err == nil {
-			stdData.AreaID = &id
+		mapping, err := lookupSNOMED(t.snomedRepo, t.unmapped, repository.MappingTypePosture, fmt.Sprintf("%v", value), rawData, nil)
+		if err != nil || mapping == nil {
+			return err
+		}
+		stdData.PostureSNOMEDCode = mapping.SNOMEDCode
+		stdData.PostureDisplay = &mapping.SNOMEDDisplay
+	case targetEvent:
+		value, ok := lookupPath(source, field.Source)
+		if !ok {
+			return nil
+		}
+		eventType := fmt.Sprintf("%v", value)
+		stdData.EventType = &eventType
+		// 映射不存在时可能已是标准事件类型，直接使用
+		mapping, err := lookupSNOMED(t.snomedRepo, t.unmapped, repository.MappingTypeEvent, eventType, rawData, nil)
+		if err != nil || mapping == nil {
+			return err
+		}
+		stdData.EventSNOMEDCode = mapping.SNOMEDCode
+		stdData.EventDisplay = &mapping.SNOMEDDisplay
+		stdData.Category = mapping.Category
+	default:
+		value, ok, err := field.fieldValue(source)
+		if err != nil || !ok {
+			return err
 		}
+		def.set(stdData, value, field)
 	}
-	
 	return nil
 }
 
@@ -202,13 +212,9 @@ func (t *RadarTransformer) determineCategory(stdData *models.StandardizedData) {
 		return
 	}
 	
-	// 如果有事件数据，根据事件类型确定 category
+	// 如果有事件数据，使用事件映射的 category（applyField 中已按固件版本查询）
 	if stdData.EventType != nil {
-		// 查询事件映射获取 category
-		mapping, err := t.snomedRepo.GetEventMapping(*stdData.EventType)
-		if err == nil {
-			stdData.Category = mapping.Category
-		} else {
+		if stdData.Category == "" {
 			stdData.Category = "activity" // 默认
 		}
 		return
@@ -217,20 +223,3 @@ func (t *RadarTransformer) determineCategory(stdData *models.StandardizedData) {
 	// 默认 category
 	stdData.Category = "activity"
 }
-
-// parseInt 解析整数
-func parseInt(v interface{}) (int, error) {
-	switch val := v.(type) {
-	case int:
-		return val, nil
-	case int64:
-		return int(val), nil
-	case float64:
-		return int(val), nil
-	case string:
-		return strconv.Atoi(val)
-	default:
-		return 0, fmt.Errorf("cannot convert %T to int", v)
-	}
-}
-
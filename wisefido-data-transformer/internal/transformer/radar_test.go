@@ -0,0 +1,194 @@
+package transformer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"wisefido-data-transformer/internal/models"
+	"wisefido-data-transformer/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+// newTestRadarTransformer 使用内置映射规范和已加载 SNOMED 索引的雷达转换器
+func newTestRadarTransformer(t *testing.T, specs *SpecResolver) *RadarTransformer {
+	t.Helper()
+	repo, mock := newTestSNOMEDRepo(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("md5").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("c1"))
+	mock.ExpectQuery("FROM snomed_mapping").WillReturnRows(
+		sqlmock.NewRows([]string{"mapping_type", "source_value", "snomed_code", "snomed_display", "category", "firmware_version"}).
+			AddRow(repository.MappingTypePosture, "1", "102538003", "Lying", "activity", nil).
+			AddRow(repository.MappingTypeEvent, "fall", "217082002", "Fall", "safety", nil))
+	mock.ExpectRollback()
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if specs == nil {
+		specs = NewSpecResolver(nil, time.Minute, zap.NewNop())
+	}
+	return NewRadarTransformer(repo, specs, NewUnmappedRecorder(repo, zap.NewNop()), zap.NewNop())
+}
+
+var frameTime = time.Date(2026, 1, 1, 12, 0, 0, 250e6, time.UTC)
+
+func radarRaw(t *testing.T, data string) *models.RawDeviceData {
+	t.Helper()
+	raw := &models.RawDeviceData{TenantID: "t1", DeviceID: "radar-1", DeviceType: "Radar", EventTime: frameTime, MessageID: "1700000000000-0"}
+	if err := json.Unmarshal([]byte(data), &raw.RawData); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func intValue(p *int) int {
+	if p == nil {
+		return -1
+	}
+	return *p
+}
+
+// 单目标数据转换为一行，不设置 frame_id
+func TestRadarTransformer_TransformFrameSingle(t *testing.T) {
+	tr := newTestRadarTransformer(t, nil)
+	raw := radarRaw(t, `{"tracking_id":3,"position_x":12,"position_y":-5,"posture":1,"heart_rate":70}`)
+
+	rows, err := tr.TransformFrame(raw)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("TransformFrame = %d rows, %v", len(rows), err)
+	}
+	row := rows[0]
+	if row.FrameID != nil || !row.Timestamp.Equal(frameTime) {
+		t.Fatalf("frame id = %v, timestamp = %v", row.FrameID, row.Timestamp)
+	}
+	if intValue(row.TrackingID) != 3 || intValue(row.RadarPosX) != 120 || intValue(row.RadarPosY) != -50 || row.RadarPosZ != nil {
+		t.Fatalf("position = %+v", row)
+	}
+	if row.PostureDisplay == nil || *row.PostureDisplay != "Lying" {
+		t.Fatalf("posture = %v", row.PostureDisplay)
+	}
+	if intValue(row.HeartRate) != 70 || row.HeartRateCode == nil || *row.HeartRateCode != "364075005" || row.Category != "vital-signs" {
+		t.Fatalf("heart rate = %v code %v category %s", row.HeartRate, row.HeartRateCode, row.Category)
+	}
+	var original map[string]interface{}
+	if err := json.Unmarshal(row.RawOriginal, &original); err != nil || original["position_x"] != float64(12) {
+		t.Fatalf("raw original = %s", row.RawOriginal)
+	}
+}
+
+// 多目标帧拆分为每个目标一行，帧级字段只写入第一行
+func TestRadarTransformer_TransformFrameMultiTarget(t *testing.T) {
+	tr := newTestRadarTransformer(t, nil)
+	raw := radarRaw(t, `{
+		"position_x": 99,
+		"heart_rate": 72,
+		"event_type": "fall",
+		"targets": [
+			{"tracking_id": 1, "position_x": 10, "posture": 1},
+			"invalid",
+			{"tracking_id": 2, "position_x": 20}
+		]
+	}`)
+
+	rows, err := tr.TransformFrame(raw)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("TransformFrame = %d rows, %v", len(rows), err)
+	}
+	for i, row := range rows {
+		if row.FrameID == nil || *row.FrameID != raw.MessageID || !row.Timestamp.Equal(frameTime) {
+			t.Fatalf("row %d: frame id = %v, timestamp = %v", i, row.FrameID, row.Timestamp)
+		}
+	}
+
+	first, second := rows[0], rows[1]
+	// 目标字段优先于帧级字段
+	if intValue(first.TrackingID) != 1 || intValue(first.RadarPosX) != 100 || first.PostureDisplay == nil {
+		t.Fatalf("first target = %+v", first)
+	}
+	if intValue(first.HeartRate) != 72 || first.EventType == nil || *first.EventType != "fall" || first.EventDisplay == nil {
+		t.Fatalf("frame fields should be on the first row, got %+v", first)
+	}
+	if intValue(second.TrackingID) != 2 || intValue(second.RadarPosX) != 200 {
+		t.Fatalf("second target = %+v", second)
+	}
+	if second.HeartRate != nil || second.EventType != nil || second.PostureDisplay != nil {
+		t.Fatalf("frame fields must not be repeated, got %+v", second)
+	}
+
+	// 第一行保存完整帧，其余行保存各自的目标
+	var full, target map[string]interface{}
+	if err := json.Unmarshal(first.RawOriginal, &full); err != nil || full["targets"] == nil {
+		t.Fatalf("first raw original = %s", first.RawOriginal)
+	}
+	if err := json.Unmarshal(second.RawOriginal, &target); err != nil || target["tracking_id"] != float64(2) || target["heart_rate"] != nil {
+		t.Fatalf("second raw original = %s", second.RawOriginal)
+	}
+
+	compat, err := tr.Transform(raw)
+	if err != nil || intValue(compat.TrackingID) != 1 {
+		t.Fatalf("Transform should return the first target, got %+v, %v", compat, err)
+	}
+}
+
+// 映射规范可指定目标数组路径
+func TestRadarTransformer_TransformFrameTargetsPath(t *testing.T) {
+	specs, mock := newTestSpecResolver(t)
+	mock.ExpectQuery("FROM field_mapping").WithArgs("Radar", "R60", "").
+		WillReturnRows(sqlmock.NewRows([]string{"spec", "version"}).AddRow(
+			[]byte(`{"targets":"frame.people","fields":[{"target":"tracking_id","source":"id"},{"target":"radar_pos_x","source":"x","unit":"cm"}]}`), 1))
+	tr := newTestRadarTransformer(t, specs)
+
+	raw := radarRaw(t, `{"frame":{"people":[{"id":7,"x":15},{"id":8,"x":25}]},"targets":[{"id":9}]}`)
+	raw.Model = "R60"
+	rows, err := tr.TransformFrame(raw)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("TransformFrame = %d rows, %v", len(rows), err)
+	}
+	if intValue(rows[0].TrackingID) != 7 || intValue(rows[1].RadarPosX) != 25 {
+		t.Fatalf("rows = %+v, %+v", rows[0], rows[1])
+	}
+}
+
+func TestFrameTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		path string
+		want int
+	}{
+		{"targets", `{"targets":[{"a":1},{"a":2}]}`, "targets", 2},
+		{"non-object elements ignored", `{"targets":[{"a":1},2,"x",null]}`, "targets", 1},
+		{"nested path", `{"frame":{"people":[{"a":1}]}}`, "frame.people", 1},
+		{"empty array", `{"targets":[]}`, "targets", 0},
+		{"not an array", `{"targets":{"a":1}}`, "targets", 0},
+		{"missing", `{"heart_rate":70}`, "targets", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.data), &data); err != nil {
+				t.Fatal(err)
+			}
+			if got := frameTargets(data, tt.path); len(got) != tt.want {
+				t.Fatalf("frameTargets = %v, want %d items", got, tt.want)
+			}
+		})
+	}
+}
+
+// 目标字段优先，不修改输入
+func TestWithFrameFields(t *testing.T) {
+	frame := map[string]interface{}{"heart_rate": 72, "position_x": 99}
+	target := map[string]interface{}{"position_x": 10, "tracking_id": 1}
+
+	merged := withFrameFields(frame, target)
+	if merged["heart_rate"] != 72 || merged["position_x"] != 10 || merged["tracking_id"] != 1 {
+		t.Fatalf("merged = %v", merged)
+	}
+	if frame["position_x"] != 99 || len(frame) != 2 || len(target) != 2 {
+		t.Fatalf("inputs modified: frame = %v, target = %v", frame, target)
+	}
+}
@@ -0,0 +1,137 @@
+package transformer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"wisefido-data-transformer/internal/models"
+	"wisefido-data-transformer/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// Transformer 设备数据转换器
+// 每个设备厂家 / 型号实现一个转换器，通过 Register 注册。
+// 新厂家可放在独立的包中，在 init 中注册，并在 service 包中以空导入（_ "...")引入。
+type Transformer interface {
+	// Transform 转换原始设备数据为标准格式
+	Transform(rawData *models.RawDeviceData) (*models.StandardizedData, error)
+}
+
+// FrameTransformer 一条原始数据可包含多个目标（如多人雷达帧）的转换器
+// 消费者优先调用 TransformFrame，每个目标写入一行
+type FrameTransformer interface {
+	Transformer
+	// TransformFrame 转换原始设备数据，返回至少一条标准化数据
+	TransformFrame(rawData *models.RawDeviceData) ([]*models.StandardizedData, error)
+}
+
+// Dependencies 创建转换器时可用的依赖
+type Dependencies struct {
+	SNOMEDRepo *repository.SNOMEDRepository
+	Specs      *SpecResolver     // 字段映射规范（field_mapping）
+	Unmapped   *UnmappedRecorder // 未映射值记录（snomed_unmapped_codes）
+	Logger     *zap.Logger
+}
+
+// Factory 转换器工厂
+type Factory func(deps Dependencies) Transformer
+
+// Registration 转换器注册信息
+//
+// DeviceType 必填（与 device_store.device_type 一致，如 "Radar"、"SleepPad"）；
+// Model / Firmware 为空表示适用于该设备类型的所有型号 / 固件，Firmware 按前缀匹配（如 "2.1" 匹配 "2.1.5"）。
+type Registration struct {
+	DeviceType string
+	Model      string
+	Firmware   string
+	Factory    Factory
+}
+
+var (
+	registryMu    sync.Mutex
+	registrations = make(map[string]Registration)
+)
+
+// Register 注册转换器（通常在厂家包的 init 中调用）
+// 同一设备类型 / 型号 / 固件重复注册时 panic
+func Register(reg Registration) {
+	if reg.DeviceType == "" || reg.Factory == nil {
+		panic("transformer: Register requires DeviceType and Factory")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	key := registrationKey(reg.DeviceType, reg.Model, reg.Firmware)
+	if _, dup := registrations[key]; dup {
+		panic(fmt.Sprintf("transformer: Register called twice for %s", key))
+	}
+	registrations[key] = reg
+}
+
+func registrationKey(deviceType, model, firmware string) string {
+	return deviceType + "/" + model + "/" + firmware
+}
+
+// entry 已实例化的转换器
+type entry struct {
+	model       string
+	firmware    string
+	transformer Transformer
+}
+
+// Registry 转换器注册表（按设备类型、型号、固件版本选择转换器）
+type Registry struct {
+	byType map[string][]entry // 设备类型 -> 转换器（按匹配优先级排序）
+}
+
+// NewRegistry 实例化所有已注册的转换器
+func NewRegistry(deps Dependencies) *Registry {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	r := &Registry{byType: make(map[string][]entry)}
+	for _, reg := range registrations {
+		r.byType[reg.DeviceType] = append(r.byType[reg.DeviceType], entry{
+			model:       reg.Model,
+			firmware:    reg.Firmware,
+			transformer: reg.Factory(deps),
+		})
+	}
+	// 型号优先于通用，固件前缀越长越优先
+	for _, entries := range r.byType {
+		sort.Slice(entries, func(i, j int) bool {
+			if (entries[i].model != "") != (entries[j].model != "") {
+				return entries[i].model != ""
+			}
+			return len(entries[i].firmware) > len(entries[j].firmware)
+		})
+	}
+	return r
+}
+
+// Lookup 选择匹配的转换器
+func (r *Registry) Lookup(rawData *models.RawDeviceData) (Transformer, error) {
+	for _, e := range r.byType[rawData.DeviceType] {
+		if e.model != "" && !strings.EqualFold(e.model, rawData.Model) {
+			continue
+		}
+		if e.firmware != "" && !strings.HasPrefix(rawData.FirmwareVersion, e.firmware) {
+			continue
+		}
+		return e.transformer, nil
+	}
+	return nil, fmt.Errorf("no transformer registered for device type %q (model %q, firmware %q)",
+		rawData.DeviceType, rawData.Model, rawData.FirmwareVersion)
+}
+
+// DeviceTypes 已注册的设备类型（用于启动日志）
+func (r *Registry) DeviceTypes() []string {
+	types := make([]string, 0, len(r.byType))
+	for t := range r.byType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,117 @@
+package transformer
+
+import (
+	"testing"
+
+	"wisefido-data-transformer/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// namedTransformer 按名称区分的测试转换器
+type namedTransformer string
+
+func (namedTransformer) Transform(*models.RawDeviceData) (*models.StandardizedData, error) {
+	return nil, nil
+}
+
+// registerForTest 注册测试转换器，测试结束后移除（避免重复运行时重复注册）
+func registerForTest(t *testing.T, deviceType, model, firmware string) {
+	t.Helper()
+	Register(Registration{
+		DeviceType: deviceType,
+		Model:      model,
+		Firmware:   firmware,
+		Factory:    func(Dependencies) Transformer { return namedTransformer(model + "@" + firmware) },
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registrations, registrationKey(deviceType, model, firmware))
+		registryMu.Unlock()
+	})
+}
+
+func mustPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("%s: expected panic", name)
+		}
+	}()
+	f()
+}
+
+// 型号优先于通用，固件前缀越长越优先，型号不区分大小写
+func TestRegistry_LookupPrecedence(t *testing.T) {
+	registerForTest(t, "TestDevice", "", "")
+	registerForTest(t, "TestDevice", "", "2")
+	registerForTest(t, "TestDevice", "", "2.1")
+	registerForTest(t, "TestDevice", "M1", "")
+	registerForTest(t, "TestDevice", "M1", "2.1")
+	r := NewRegistry(Dependencies{Logger: zap.NewNop()})
+
+	tests := []struct {
+		name     string
+		model    string
+		firmware string
+		want     namedTransformer
+	}{
+		{"model and firmware", "M1", "2.1.5", "M1@2.1"},
+		{"model case insensitive", "m1", "3.0", "M1@"},
+		{"model without firmware", "M1", "", "M1@"},
+		{"longest firmware prefix", "M2", "2.1.5", "@2.1"},
+		{"shorter firmware prefix", "M2", "2.5", "@2"},
+		{"generic", "M2", "3.0", "@"},
+		{"no model or firmware", "", "", "@"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Lookup(&models.RawDeviceData{DeviceType: "TestDevice", Model: tt.model, FirmwareVersion: tt.firmware})
+			if err != nil || got != tt.want {
+				t.Fatalf("Lookup = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// 只有特定型号 / 固件的转换器时，其他设备没有匹配
+func TestRegistry_LookupNoMatch(t *testing.T) {
+	registerForTest(t, "TestDevice", "M1", "2.")
+	r := NewRegistry(Dependencies{Logger: zap.NewNop()})
+
+	for _, raw := range []*models.RawDeviceData{
+		{DeviceType: "TestDevice", Model: "M1", FirmwareVersion: "3.0"},
+		{DeviceType: "TestDevice", Model: "M2", FirmwareVersion: "2.1"},
+		{DeviceType: "UnknownDevice"},
+	} {
+		if got, err := r.Lookup(raw); err == nil {
+			t.Fatalf("Lookup(%s/%s/%s) = %v, want error", raw.DeviceType, raw.Model, raw.FirmwareVersion, got)
+		}
+	}
+	found := false
+	for _, deviceType := range r.DeviceTypes() {
+		found = found || deviceType == "TestDevice"
+	}
+	if !found {
+		t.Fatalf("DeviceTypes = %v, want TestDevice", r.DeviceTypes())
+	}
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	factory := func(Dependencies) Transformer { return namedTransformer("x") }
+	mustPanic(t, "missing device type", func() { Register(Registration{Factory: factory}) })
+	mustPanic(t, "missing factory", func() { Register(Registration{DeviceType: "TestDevice"}) })
+
+	registerForTest(t, "TestDevice", "M1", "")
+	mustPanic(t, "duplicate", func() { Register(Registration{DeviceType: "TestDevice", Model: "M1", Factory: factory}) })
+}
+
+// 内置转换器在 init 中注册
+func TestRegistry_BuiltinTransformers(t *testing.T) {
+	r := NewRegistry(Dependencies{Logger: zap.NewNop()})
+	for _, deviceType := range []string{"Radar", "Sleepace"} {
+		if _, err := r.Lookup(&models.RawDeviceData{DeviceType: deviceType}); err != nil {
+			t.Fatalf("Lookup(%s): %v", deviceType, err)
+		}
+	}
+}
@@ -11,13 +11,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
-	"time"
 	"wisefido-data-transformer/internal/models"
 	"wisefido-data-transformer/internal/repository"
 	
 	"go.uber.org/zap"
 )
 
+func init() {
+	// Sleepace 睡眠垫在 device_store 中登记为 "SleepPad" 或 "Sleepace"
+	for _, deviceType := range []string{"SleepPad", "Sleepace"} {
+		Register(Registration{
+			DeviceType: deviceType,
+			Factory: func(deps Dependencies) Transformer {
+				return NewSleepaceTransformer(deps.SNOMEDRepo, deps.Unmapped, deps.Logger)
+			},
+		})
+	}
+}
+
 // SleepaceTransformer Sleepace 数据转换器
 // 
 // 负责将 Sleepace 设备的原始数据转换为标准化格式。
@@ -30,13 +41,15 @@ import (
 // - FHIR Category：根据数据内容自动分类（vital-signs 或 activity）
 type SleepaceTransformer struct {
 	snomedRepo *repository.SNOMEDRepository // SNOMED CT 映射仓库
+	unmapped   *UnmappedRecorder             // 未映射值记录
 	logger     *zap.Logger                   // 日志记录器
 }
 
 // NewSleepaceTransformer 创建 Sleepace 数据转换器
-func NewSleepaceTransformer(snomedRepo *repository.SNOMEDRepository, logger *zap.Logger) *SleepaceTransformer {
+func NewSleepaceTransformer(snomedRepo *repository.SNOMEDRepository, unmapped *UnmappedRecorder, logger *zap.Logger) *SleepaceTransformer {
 	return &SleepaceTransformer{
 		snomedRepo: snomedRepo,
+		unmapped:   unmapped,
 		logger:     logger,
 	}
 }
@@ -70,7 +83,7 @@ func (t *SleepaceTransformer) Transform(rawData *models.RawDeviceData) (*models.
 	stdData := &models.StandardizedData{
 		TenantID:  rawData.TenantID,
 		DeviceID:  rawData.DeviceID,
-		Timestamp: time.Unix(rawData.Timestamp, 0),
+		Timestamp: rawData.EventTime,
 		DataType:  "observation", // 默认为 observation，告警事件由 alarm 服务判断
 	}
 	
@@ -87,17 +100,17 @@ func (t *SleepaceTransformer) Transform(rawData *models.RawDeviceData) (*models.
 	}
 	
 	// 转换床状态数据
-	if err := t.transformBedStatus(rawData.RawData, stdData); err != nil {
+	if err := t.transformBedStatus(rawData, stdData); err != nil {
 		t.logger.Warn("Failed to transform bed status", zap.Error(err))
 	}
 	
 	// 转换睡眠阶段数据
-	if err := t.transformSleepStage(rawData.RawData, stdData); err != nil {
+	if err := t.transformSleepStage(rawData, stdData); err != nil {
 		t.logger.Warn("Failed to transform sleep stage", zap.Error(err))
 	}
 	
 	// 转换行为事件数据
-	if err := t.transformBehaviorEvents(rawData.RawData, stdData); err != nil {
+	if err := t.transformBehaviorEvents(rawData, stdData); err != nil {
 		t.logger.Warn("Failed to transform behavior events", zap.Error(err))
 	}
 	
@@ -140,93 +153,87 @@ func (t *SleepaceTransformer) transformVitalSigns(rawData map[string]interface{}
 	return nil
 }
 
-// transformBedStatus 转换床状态数据
-func (t *SleepaceTransformer) transformBedStatus(rawData map[string]interface{}, stdData *models.StandardizedData) error {
+// 内置 Sleepace 编码（snomed_mapping 中没有对应固件 / 通用映射时使用）
+var (
 	// bedStatus: 0=在床, 1=离床
-	if bedStatus, ok := rawData["bedStatus"]; ok {
-		status, err := parseIntSleepace(bedStatus)
-		if err != nil {
-			return err
-		}
-		
-		// 映射到 SNOMED CT
-		// 0 = 在床 (On bed) -> 370998004 / 248569007
-		// 1 = 离床 (Left bed) -> 424287000
-		var bedStatusCode, bedStatusDisplay string
-		if status == 0 {
-			bedStatusCode = "370998004" // On bed
-			bedStatusDisplay = "On bed"
-		} else if status == 1 {
-			bedStatusCode = "424287000" // Left bed
-			bedStatusDisplay = "Left bed"
-		}
-		
-		if bedStatusCode != "" {
-			stdData.BedStatusSNOMEDCode = &bedStatusCode
-			stdData.BedStatusDisplay = &bedStatusDisplay
-		}
+	sleepaceBedStatus = map[string]repository.Mapping{
+		"0": {SNOMEDCode: strPtr("370998004"), SNOMEDDisplay: "On bed", Category: "activity"},
+		"1": {SNOMEDCode: strPtr("424287000"), SNOMEDDisplay: "Left bed", Category: "activity"},
+	}
+	// sleepStage: 0=清醒, 1=浅睡眠, 2=深睡眠, 3=REM睡眠
+	sleepaceSleepStages = map[string]repository.Mapping{
+		"0": {SNOMEDCode: strPtr("248220002"), SNOMEDDisplay: "Awake", Category: "activity"},
+		"1": {SNOMEDCode: strPtr("248232005"), SNOMEDDisplay: "Light sleep", Category: "activity"},
+		"2": {SNOMEDCode: strPtr("248233000"), SNOMEDDisplay: "Deep sleep", Category: "activity"},
+		"3": {SNOMEDCode: strPtr("248234006"), SNOMEDDisplay: "REM sleep", Category: "activity"},
+	}
+	// 行为事件
+	sleepaceEvents = map[string]repository.Mapping{
+		"BED_SIT_UP": {SNOMEDCode: strPtr("422256002"), SNOMEDDisplay: "Sitting up in bed", Category: "activity"},
+	}
+)
+
+// transformBedStatus 转换床状态数据（按固件版本查询 bed_status 映射）
+func (t *SleepaceTransformer) transformBedStatus(rawData *models.RawDeviceData, stdData *models.StandardizedData) error {
+	bedStatus, ok := rawData.RawData["bedStatus"]
+	if !ok {
+		return nil
+	}
+	status, err := parseIntSleepace(bedStatus)
+	if err != nil {
+		return err
 	}
 	
+	mapping, err := lookupSNOMED(t.snomedRepo, t.unmapped, repository.MappingTypeBedStatus, strconv.Itoa(status), rawData, sleepaceBedStatus)
+	if err != nil || mapping == nil {
+		return err
+	}
+	stdData.BedStatusSNOMEDCode = mapping.SNOMEDCode
+	stdData.BedStatusDisplay = &mapping.SNOMEDDisplay
 	return nil
 }
 
-// transformSleepStage 转换睡眠阶段数据
-func (t *SleepaceTransformer) transformSleepStage(rawData map[string]interface{}, stdData *models.StandardizedData) error {
-	// sleepStage: 0=清醒, 1=浅睡眠, 2=深睡眠, 3=REM睡眠
+// transformSleepStage 转换睡眠阶段数据（按固件版本查询 sleep_stage 映射）
+func (t *SleepaceTransformer) transformSleepStage(rawData *models.RawDeviceData, stdData *models.StandardizedData) error {
 	// 注意：Sleepace 的 sleepStage 可能在不同字段中，需要根据实际数据格式调整
-	if sleepStage, ok := rawData["sleepStage"]; ok {
-		stage, err := parseIntSleepace(sleepStage)
-		if err != nil {
-			return err
-		}
-		
-		// 映射到 SNOMED CT
-		// 0 = 清醒 (Awake) -> 248220002
-		// 1 = 浅睡眠 (Light sleep) -> 248232005
-		// 2 = 深睡眠 (Deep sleep) -> 248233000
-		// 3 = REM睡眠 (REM sleep) -> 248234006
-		var sleepStateCode, sleepStateDisplay string
-		switch stage {
-		case 0:
-			sleepStateCode = "248220002"
-			sleepStateDisplay = "Awake"
-		case 1:
-			sleepStateCode = "248232005"
-			sleepStateDisplay = "Light sleep"
-		case 2:
-			sleepStateCode = "248233000"
-			sleepStateDisplay = "Deep sleep"
-		case 3:
-			sleepStateCode = "248234006"
-			sleepStateDisplay = "REM sleep"
-		}
-		
-		if sleepStateCode != "" {
-			stdData.SleepStateSNOMEDCode = &sleepStateCode
-			stdData.SleepStateDisplay = &sleepStateDisplay
-		}
+	sleepStage, ok := rawData.RawData["sleepStage"]
+	if !ok {
+		return nil
+	}
+	stage, err := parseIntSleepace(sleepStage)
+	if err != nil {
+		return err
 	}
 	
+	mapping, err := lookupSNOMED(t.snomedRepo, t.unmapped, repository.MappingTypeSleepStage, strconv.Itoa(stage), rawData, sleepaceSleepStages)
+	if err != nil || mapping == nil {
+		return err
+	}
+	stdData.SleepStateSNOMEDCode = mapping.SNOMEDCode
+	stdData.SleepStateDisplay = &mapping.SNOMEDDisplay
 	return nil
 }
 
-// transformBehaviorEvents 转换行为事件数据
-func (t *SleepaceTransformer) transformBehaviorEvents(rawData map[string]interface{}, stdData *models.StandardizedData) error {
-	// 转换行为事件
-	// sitUp: 床上坐起 -> 422256002 / 40199007
-	if sitUp, ok := rawData["sitUp"]; ok {
+// transformBehaviorEvents 转换行为事件数据（按固件版本查询 event 映射）
+func (t *SleepaceTransformer) transformBehaviorEvents(rawData *models.RawDeviceData, stdData *models.StandardizedData) error {
+	// sitUp: 床上坐起
+	if sitUp, ok := rawData.RawData["sitUp"]; ok {
 		if val, err := parseIntSleepace(sitUp); err == nil && val > 0 {
 			eventType := "BED_SIT_UP"
-			eventCode := "422256002"
-			eventDisplay := "Sitting up in bed"
 			stdData.EventType = &eventType
-			stdData.EventSNOMEDCode = &eventCode
-			stdData.EventDisplay = &eventDisplay
+			mapping, err := lookupSNOMED(t.snomedRepo, t.unmapped, repository.MappingTypeEvent, eventType, rawData, sleepaceEvents)
+			if err != nil {
+				return err
+			}
+			if mapping != nil {
+				stdData.EventSNOMEDCode = mapping.SNOMEDCode
+				stdData.EventDisplay = &mapping.SNOMEDDisplay
+			}
 		}
 	}
 	
 	// turnOver: 翻身
-	if turnOver, ok := rawData["turnOver"]; ok {
+	if turnOver, ok := rawData.RawData["turnOver"]; ok {
 		if val, err := parseIntSleepace(turnOver); err == nil && val > 0 {
 			// 翻身事件通常不单独记录，而是作为姿态变化的一部分
 			// 如果需要记录，可以使用相应的事件类型
@@ -234,7 +241,7 @@ func (t *SleepaceTransformer) transformBehaviorEvents(rawData map[string]interfa
 	}
 	
 	// bodyMove: 体动
-	if bodyMove, ok := rawData["bodyMove"]; ok {
+	if bodyMove, ok := rawData.RawData["bodyMove"]; ok {
 		if val, err := parseIntSleepace(bodyMove); err == nil && val > 0 {
 			// 体动事件通常不单独记录，而是作为姿态变化的一部分
 		}
@@ -289,3 +296,7 @@ func parseIntSleepace(v interface{}) (int, error) {
 	}
 }
 
+// strPtr 返回字符串指针
+func strPtr(s string) *string {
+	return &s
+}
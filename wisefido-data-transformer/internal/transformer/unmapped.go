@@ -0,0 +1,140 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"wisefido-data-transformer/internal/models"
+	"wisefido-data-transformer/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// pendingUnmapped 待写入的未映射值计数
+type pendingUnmapped struct {
+	count    int64
+	deviceID string
+	lastSeen time.Time
+}
+
+// UnmappedRecorder 未映射值记录器
+// 在内存中累加出现次数，定期批量写入 snomed_unmapped_codes，供临床人员补充映射
+type UnmappedRecorder struct {
+	repo    *repository.SNOMEDRepository
+	mu      sync.Mutex
+	pending map[repository.UnmappedCode]*pendingUnmapped
+	logger  *zap.Logger
+}
+
+// NewUnmappedRecorder 创建未映射值记录器
+func NewUnmappedRecorder(repo *repository.SNOMEDRepository, logger *zap.Logger) *UnmappedRecorder {
+	return &UnmappedRecorder{
+		repo:    repo,
+		pending: make(map[repository.UnmappedCode]*pendingUnmapped),
+		logger:  logger,
+	}
+}
+
+// Record 记录一次未映射值
+func (r *UnmappedRecorder) Record(mappingType, sourceValue string, rawData *models.RawDeviceData) {
+	if r == nil {
+		return
+	}
+	code := repository.UnmappedCode{
+		MappingType:     mappingType,
+		SourceValue:     sourceValue,
+		DeviceType:      rawData.DeviceType,
+		Model:           rawData.Model,
+		FirmwareVersion: rawData.FirmwareVersion,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[code]
+	if !ok {
+		p = &pendingUnmapped{}
+		r.pending[code] = p
+	}
+	p.count++
+	p.deviceID = rawData.DeviceID
+	p.lastSeen = time.Now()
+}
+
+// Run 定期写入累加的计数（阻塞直到 ctx 结束，结束前写入剩余计数）
+func (r *UnmappedRecorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.Flush(context.Background())
+			return
+		case <-ticker.C:
+			r.Flush(ctx)
+		}
+	}
+}
+
+// Flush 写入累加的计数，失败的计数保留到下次写入
+func (r *UnmappedRecorder) Flush(ctx context.Context) {
+	r.mu.Lock()
+	batch := r.pending
+	r.pending = make(map[repository.UnmappedCode]*pendingUnmapped)
+	r.mu.Unlock()
+
+	for code, p := range batch {
+		if err := r.repo.IncrementUnmapped(ctx, code, p.count, p.deviceID, p.lastSeen); err != nil {
+			r.logger.Warn("Failed to record unmapped code",
+				zap.String("mapping_type", code.MappingType),
+				zap.String("source_value", code.SourceValue),
+				zap.Error(err),
+			)
+			r.restore(code, p)
+			continue
+		}
+		r.logger.Info("Recorded unmapped code",
+			zap.String("mapping_type", code.MappingType),
+			zap.String("source_value", code.SourceValue),
+			zap.String("device_type", code.DeviceType),
+			zap.String("firmware_version", code.FirmwareVersion),
+			zap.Int64("count", p.count),
+		)
+	}
+}
+
+// restore 写入失败时合并回待写入计数
+func (r *UnmappedRecorder) restore(code repository.UnmappedCode, p *pendingUnmapped) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cur, ok := r.pending[code]; ok {
+		cur.count += p.count
+		return
+	}
+	r.pending[code] = p
+}
+
+// lookupSNOMED 按设备固件版本查询 SNOMED 映射（固件版本按前缀匹配）
+// snomed_mapping 中没有映射时使用 builtin（内置的厂家标准编码，可为 nil），
+// 仍没有时记录未映射值并返回 nil, nil；数据库错误时返回 error
+func lookupSNOMED(
+	repo *repository.SNOMEDRepository,
+	unmapped *UnmappedRecorder,
+	mappingType, sourceValue string,
+	rawData *models.RawDeviceData,
+	builtin map[string]repository.Mapping,
+) (*repository.Mapping, error) {
+	var firmwareVersion *string
+	if rawData.FirmwareVersion != "" {
+		firmwareVersion = &rawData.FirmwareVersion
+	}
+	mapping, err := repo.GetMapping(mappingType, sourceValue, firmwareVersion)
+	if errors.Is(err, repository.ErrMappingNotFound) {
+		if m, ok := builtin[sourceValue]; ok {
+			return &m, nil
+		}
+		unmapped.Record(mappingType, sourceValue, rawData)
+		return nil, nil
+	}
+	return mapping, err
+}
@@ -0,0 +1,197 @@
+package transformer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"wisefido-data-transformer/internal/models"
+	"wisefido-data-transformer/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+// newTestSNOMEDRepo 未加载内存索引的仓库（查询直接访问数据库）
+func newTestSNOMEDRepo(t *testing.T) (*repository.SNOMEDRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repository.NewSNOMEDRepository(db, zap.NewNop()), mock
+}
+
+var unmappedRaw = &models.RawDeviceData{DeviceID: "dev-1", DeviceType: "Radar", Model: "R60", FirmwareVersion: "2.1.5"}
+
+func postureCode(value string) repository.UnmappedCode {
+	return repository.UnmappedCode{
+		MappingType:     repository.MappingTypePosture,
+		SourceValue:     value,
+		DeviceType:      "Radar",
+		Model:           "R60",
+		FirmwareVersion: "2.1.5",
+	}
+}
+
+func pendingCount(r *UnmappedRecorder, code repository.UnmappedCode) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.pending[code]; ok {
+		return p.count
+	}
+	return 0
+}
+
+// 同一未映射值累加后批量写入一次
+func TestUnmappedRecorder_Flush(t *testing.T) {
+	repo, mock := newTestSNOMEDRepo(t)
+	mock.MatchExpectationsInOrder(false)
+	r := NewUnmappedRecorder(repo, zap.NewNop())
+
+	r.Record(repository.MappingTypePosture, "9", unmappedRaw)
+	r.Record(repository.MappingTypePosture, "9", unmappedRaw)
+	r.Record(repository.MappingTypePosture, "10", unmappedRaw)
+
+	mock.ExpectExec("INSERT INTO snomed_unmapped_codes").
+		WithArgs(repository.MappingTypePosture, "9", "Radar", "R60", "2.1.5", int64(2), "dev-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO snomed_unmapped_codes").
+		WithArgs(repository.MappingTypePosture, "10", "Radar", "R60", "2.1.5", int64(1), "dev-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r.Flush(context.Background())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.pending); n != 0 {
+		t.Fatalf("pending after flush = %d, want 0", n)
+	}
+	// 没有待写入的计数时不访问数据库
+	r.Flush(context.Background())
+}
+
+// 写入失败的计数保留，与之后的计数合并写入
+func TestUnmappedRecorder_RestoreOnError(t *testing.T) {
+	repo, mock := newTestSNOMEDRepo(t)
+	r := NewUnmappedRecorder(repo, zap.NewNop())
+	code := postureCode("9")
+
+	r.Record(repository.MappingTypePosture, "9", unmappedRaw)
+	mock.ExpectExec("INSERT INTO snomed_unmapped_codes").WillReturnError(errors.New("connection reset"))
+	r.Flush(context.Background())
+	if got := pendingCount(r, code); got != 1 {
+		t.Fatalf("pending after failed flush = %d, want 1", got)
+	}
+
+	// 写入期间新增的计数与失败的计数合并
+	r.Record(repository.MappingTypePosture, "9", unmappedRaw)
+	r.restore(code, &pendingUnmapped{count: 3, deviceID: "dev-0"})
+	if got := pendingCount(r, code); got != 5 {
+		t.Fatalf("pending after restore = %d, want 5", got)
+	}
+
+	mock.ExpectExec("INSERT INTO snomed_unmapped_codes").
+		WithArgs(repository.MappingTypePosture, "9", "Radar", "R60", "2.1.5", int64(5), "dev-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r.Flush(context.Background())
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 未配置记录器时忽略
+func TestUnmappedRecorder_Nil(t *testing.T) {
+	var r *UnmappedRecorder
+	r.Record(repository.MappingTypePosture, "9", unmappedRaw)
+}
+
+func TestLookupSNOMED(t *testing.T) {
+	code := "102538003"
+	builtin := map[string]repository.Mapping{
+		"1": {SNOMEDCode: &code, SNOMEDDisplay: "Lying (builtin)", Category: "posture"},
+	}
+	tests := []struct {
+		name         string
+		value        string
+		firmware     string
+		expect       func(mock sqlmock.Sqlmock, firmware interface{})
+		wantDisplay  string
+		wantErr      bool
+		wantUnmapped bool
+	}{
+		{
+			name:     "database mapping",
+			value:    "1",
+			firmware: "2.1.5",
+			expect: func(mock sqlmock.Sqlmock, firmware interface{}) {
+				mock.ExpectQuery("FROM snomed_mapping").WithArgs(repository.MappingTypePosture, "1", firmware).
+					WillReturnRows(sqlmock.NewRows([]string{"snomed_code", "snomed_display", "category"}).
+						AddRow("40199007", "Supine", "posture"))
+			},
+			wantDisplay: "Supine",
+		},
+		{
+			name:     "builtin fallback",
+			value:    "1",
+			firmware: "2.1.5",
+			expect: func(mock sqlmock.Sqlmock, firmware interface{}) {
+				mock.ExpectQuery("FROM snomed_mapping").WithArgs(repository.MappingTypePosture, "1", firmware).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantDisplay: "Lying (builtin)",
+		},
+		{
+			// 没有固件版本时按通用映射查询
+			name:  "unmapped without firmware",
+			value: "9",
+			expect: func(mock sqlmock.Sqlmock, firmware interface{}) {
+				mock.ExpectQuery("FROM snomed_mapping").WithArgs(repository.MappingTypePosture, "9", firmware).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantUnmapped: true,
+		},
+		{
+			name:     "database error",
+			value:    "9",
+			firmware: "2.1.5",
+			expect: func(mock sqlmock.Sqlmock, firmware interface{}) {
+				mock.ExpectQuery("FROM snomed_mapping").WillReturnError(errors.New("connection reset"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock := newTestSNOMEDRepo(t)
+			var firmware interface{}
+			if tt.firmware != "" {
+				firmware = tt.firmware
+			}
+			tt.expect(mock, firmware)
+			unmapped := NewUnmappedRecorder(repo, zap.NewNop())
+			raw := &models.RawDeviceData{DeviceID: "dev-1", DeviceType: "Radar", Model: "R60", FirmwareVersion: tt.firmware}
+
+			m, err := lookupSNOMED(repo, unmapped, repository.MappingTypePosture, tt.value, raw, builtin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupSNOMED error = %v, wantErr %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.wantDisplay != "":
+				if m == nil || m.SNOMEDDisplay != tt.wantDisplay {
+					t.Fatalf("lookupSNOMED = %+v, want %s", m, tt.wantDisplay)
+				}
+			case m != nil:
+				t.Fatalf("lookupSNOMED = %+v, want nil", m)
+			}
+			if recorded := len(unmapped.pending) > 0; recorded != tt.wantUnmapped {
+				t.Fatalf("unmapped recorded = %v, want %v", recorded, tt.wantUnmapped)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package transformer
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"wisefido-data-transformer/internal/models"
+
+	"go.uber.org/zap"
+	"owl-common/cache"
+)
+
+// 数据质量标记（iot_timeseries.quality_flag / hr_quality / rr_quality），下游只采信 ok 的生命体征
+const (
+	QualityOK         = "ok"
+	QualityOutlier    = "outlier"      // 与设备近期数据偏差过大（保留数值）
+	QualityOutOfRange = "out_of_range" // 超出生理范围（数值已丢弃）
+	QualityLowSignal  = "low_signal"   // 信号质量低（生命体征已丢弃）
+	QualityDeviceInit = "device_init"  // 设备初始化中（生命体征已丢弃）
+)
+
+// qualitySeverity 同一条数据有多个问题时记录最严重的
+var qualitySeverity = map[string]int{
+	QualityOK:         0,
+	QualityOutlier:    1,
+	QualityOutOfRange: 2,
+	QualityLowSignal:  3,
+	QualityDeviceInit: 4,
+}
+
+// 离群检测参数
+const (
+	outlierWindow     = 10               // 每个设备每项生命体征保留的近期样本数
+	outlierMinSamples = 5                // 样本数不足时不做离群检测
+	outlierHistoryTTL = 10 * time.Minute // 超过此时间没有数据时重新积累样本
+)
+
+// vitalRule 生命体征校验规则
+type vitalRule struct {
+	name         string
+	min, max     int // 生理范围（含边界）
+	maxDeviation int // 与近期中位数的最大偏差
+	value        func(d *models.StandardizedData) *int
+	clear        func(d *models.StandardizedData)
+	quality      func(d *models.StandardizedData) *string // 该生命体征的质量标记
+}
+
+// vitalRules 生命体征校验规则（成人，单位：次/分）
+var vitalRules = []vitalRule{
+	{
+		name: "heart_rate", min: 25, max: 220, maxDeviation: 35,
+		value: func(d *models.StandardizedData) *int { return d.HeartRate },
+		clear:   func(d *models.StandardizedData) { d.HeartRate, d.HeartRateCode, d.HeartRateDisplay = nil, nil, nil },
+		quality: func(d *models.StandardizedData) *string { return &d.HeartRateQuality },
+	},
+	{
+		name: "respiratory_rate", min: 4, max: 60, maxDeviation: 12,
+		value: func(d *models.StandardizedData) *int { return d.RespiratoryRate },
+		clear: func(d *models.StandardizedData) {
+			d.RespiratoryRate, d.RespiratoryRateCode, d.RespiratoryRateDisplay = nil, nil, nil
+		},
+		quality: func(d *models.StandardizedData) *string { return &d.RespiratoryRateQuality },
+	},
+}
+
+// Validator 数据质量校验（转换之后、写入之前）
+//
+// - 设备初始化中或信号质量低于阈值时丢弃生命体征
+// - 超出生理范围的生命体征丢弃
+// - 与设备近期中位数偏差过大的值标记为 outlier（数值保留，下游不采信）
+//
+// 近期样本保存在进程内存中（多实例部署时每个实例只看到自己处理的消息）
+type Validator struct {
+	minSignalQuality int
+	mu               sync.Mutex
+	history          *cache.TTL[[]int] // device_id/vital -> 近期样本
+	logger           *zap.Logger
+}
+
+// NewValidator 创建数据质量校验器
+// minSignalQuality 为信号质量下限（0-100），0 表示不按信号质量过滤
+func NewValidator(minSignalQuality int, logger *zap.Logger) *Validator {
+	return &Validator{
+		minSignalQuality: minSignalQuality,
+		history:          cache.NewTTL[[]int](outlierHistoryTTL, 0),
+		logger:           logger,
+	}
+}
+
+// Validate 校验标准化数据并设置质量标记（不合格的生命体征会被清空）
+// 每项生命体征单独标记，一项离群 / 超范围不影响另一项；设备状态（初始化中 / 信号低）影响全部生命体征
+func (v *Validator) Validate(rawData *models.RawDeviceData, stdData *models.StandardizedData) {
+	stdData.QualityFlag = QualityOK
+	for _, rule := range vitalRules {
+		*rule.quality(stdData) = QualityOK
+	}
+	if stdData.HeartRate == nil && stdData.RespiratoryRate == nil {
+		return
+	}
+
+	if flag := v.deviceState(rawData.RawData); flag != QualityOK {
+		for _, rule := range vitalRules {
+			rule.clear(stdData)
+			v.flag(stdData, rule, flag, rawData)
+		}
+		return
+	}
+
+	for _, rule := range vitalRules {
+		value := rule.value(stdData)
+		if value == nil {
+			continue
+		}
+		if *value < rule.min || *value > rule.max {
+			rule.clear(stdData)
+			v.flag(stdData, rule, QualityOutOfRange, rawData)
+			continue
+		}
+		if v.isOutlier(historyKey(stdData, rule), rule, *value) {
+			v.flag(stdData, rule, QualityOutlier, rawData)
+		}
+	}
+}
+
+// flag 记录生命体征的质量问题，整行标记保留最严重的
+func (v *Validator) flag(stdData *models.StandardizedData, rule vitalRule, flag string, rawData *models.RawDeviceData) {
+	*rule.quality(stdData) = flag
+	if qualitySeverity[flag] > qualitySeverity[stdData.QualityFlag] {
+		stdData.QualityFlag = flag
+	}
+	v.logger.Debug("Data quality check failed",
+		zap.String("device_id", stdData.DeviceID),
+		zap.String("device_type", rawData.DeviceType),
+		zap.String("quality_flag", flag),
+		zap.String("vital", rule.name),
+	)
+}
+
+// deviceState 按设备上报的初始化状态和信号质量判断生命体征是否可信
+// Sleepace：initStatus 1=初始化完成、0=初始化中；signalQuality 0-100
+func (v *Validator) deviceState(raw map[string]interface{}) string {
+	for _, field := range []string{"initStatus", "init_status"} {
+		if val, ok := raw[field]; ok {
+			if status, err := toFloat(val); err == nil && status == 0 {
+				return QualityDeviceInit
+			}
+		}
+	}
+	if initializing, ok := raw["initializing"].(bool); ok && initializing {
+		return QualityDeviceInit
+	}
+
+	if v.minSignalQuality <= 0 {
+		return QualityOK
+	}
+	for _, field := range []string{"signalQuality", "signal_quality"} {
+		if val, ok := raw[field]; ok {
+			if quality, err := toFloat(val); err == nil && quality < float64(v.minSignalQuality) {
+				return QualityLowSignal
+			}
+		}
+	}
+	return QualityOK
+}
+
+// historyKey 近期样本键：设备 + 生命体征，多目标雷达再按 tracking_id 区分
+func historyKey(stdData *models.StandardizedData, rule vitalRule) string {
+	key := stdData.DeviceID + "/" + rule.name
+	if stdData.TrackingID != nil {
+		key += "/" + strconv.Itoa(*stdData.TrackingID)
+	}
+	return key
+}
+
+// isOutlier 与近期样本的中位数比较，并记录本次样本
+// 离群值同样计入样本，真实的持续变化会在几个样本后被接受
+func (v *Validator) isOutlier(key string, rule vitalRule, value int) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	samples, _, _ := v.history.Get(key)
+	outlier := false
+	if len(samples) >= outlierMinSamples {
+		deviation := value - median(samples)
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		outlier = deviation > rule.maxDeviation
+	}
+
+	next := make([]int, 0, outlierWindow)
+	if len(samples) >= outlierWindow {
+		samples = samples[len(samples)-outlierWindow+1:]
+	}
+	next = append(append(next, samples...), value)
+	v.history.Set(key, next)
+	return outlier
+}
+
+// median 中位数
+func median(values []int) int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
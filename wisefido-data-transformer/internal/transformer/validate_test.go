@@ -0,0 +1,165 @@
+package transformer
+
+import (
+	"testing"
+
+	"wisefido-data-transformer/internal/models"
+
+	"go.uber.org/zap"
+)
+
+func intPtr(v int) *int { return &v }
+
+func vitals(hr, rr *int) *models.StandardizedData {
+	return &models.StandardizedData{DeviceID: "dev-1", HeartRate: hr, RespiratoryRate: rr}
+}
+
+func rawWith(fields map[string]interface{}) *models.RawDeviceData {
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	return &models.RawDeviceData{DeviceID: "dev-1", DeviceType: "Sleepace", RawData: fields}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name             string
+		minSignal        int
+		raw              map[string]interface{}
+		data             *models.StandardizedData
+		wantFlag, wantHR string
+		wantRR           string
+		keepsHR, keepsRR bool
+	}{
+		{"ok", 50, map[string]interface{}{"signalQuality": 80}, vitals(intPtr(70), intPtr(16)),
+			QualityOK, QualityOK, QualityOK, true, true},
+		{"no vitals", 50, map[string]interface{}{"initStatus": 0}, vitals(nil, nil),
+			QualityOK, QualityOK, QualityOK, false, false},
+		{"heart rate out of range keeps respiratory rate", 0, nil, vitals(intPtr(300), intPtr(16)),
+			QualityOutOfRange, QualityOutOfRange, QualityOK, false, true},
+		{"respiratory rate out of range keeps heart rate", 0, nil, vitals(intPtr(70), intPtr(2)),
+			QualityOutOfRange, QualityOK, QualityOutOfRange, true, false},
+		{"range bounds are inclusive", 0, nil, vitals(intPtr(25), intPtr(60)),
+			QualityOK, QualityOK, QualityOK, true, true},
+		{"device init (initStatus)", 0, map[string]interface{}{"initStatus": 0}, vitals(intPtr(70), intPtr(16)),
+			QualityDeviceInit, QualityDeviceInit, QualityDeviceInit, false, false},
+		{"device init (init_status string)", 0, map[string]interface{}{"init_status": "0"}, vitals(intPtr(70), nil),
+			QualityDeviceInit, QualityDeviceInit, QualityDeviceInit, false, false},
+		{"device init (initializing)", 0, map[string]interface{}{"initializing": true}, vitals(intPtr(70), intPtr(16)),
+			QualityDeviceInit, QualityDeviceInit, QualityDeviceInit, false, false},
+		{"initialized", 0, map[string]interface{}{"initStatus": 1}, vitals(intPtr(70), intPtr(16)),
+			QualityOK, QualityOK, QualityOK, true, true},
+		{"low signal", 50, map[string]interface{}{"signal_quality": 30}, vitals(intPtr(70), intPtr(16)),
+			QualityLowSignal, QualityLowSignal, QualityLowSignal, false, false},
+		{"signal filter disabled", 0, map[string]interface{}{"signalQuality": 10}, vitals(intPtr(70), intPtr(16)),
+			QualityOK, QualityOK, QualityOK, true, true},
+		{"device init wins over low signal", 50, map[string]interface{}{"initStatus": 0, "signalQuality": 10}, vitals(intPtr(70), intPtr(16)),
+			QualityDeviceInit, QualityDeviceInit, QualityDeviceInit, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewValidator(tt.minSignal, zap.NewNop())
+			v.Validate(rawWith(tt.raw), tt.data)
+			if tt.data.QualityFlag != tt.wantFlag || tt.data.HeartRateQuality != tt.wantHR || tt.data.RespiratoryRateQuality != tt.wantRR {
+				t.Fatalf("flags = %s/%s/%s, want %s/%s/%s", tt.data.QualityFlag, tt.data.HeartRateQuality, tt.data.RespiratoryRateQuality,
+					tt.wantFlag, tt.wantHR, tt.wantRR)
+			}
+			if (tt.data.HeartRate != nil) != tt.keepsHR || (tt.data.RespiratoryRate != nil) != tt.keepsRR {
+				t.Fatalf("heart_rate kept = %v, respiratory_rate kept = %v", tt.data.HeartRate != nil, tt.data.RespiratoryRate != nil)
+			}
+		})
+	}
+}
+
+func TestValidator_Outlier(t *testing.T) {
+	v := NewValidator(0, zap.NewNop())
+	raw := rawWith(nil)
+
+	// 样本不足时不判断离群
+	for i := 0; i < outlierMinSamples; i++ {
+		d := vitals(intPtr(70+i%3), intPtr(16))
+		v.Validate(raw, d)
+		if d.QualityFlag != QualityOK {
+			t.Fatalf("sample %d flagged %s", i, d.QualityFlag)
+		}
+	}
+
+	// 心率离群只标记心率，数值保留，呼吸率仍可信
+	d := vitals(intPtr(140), intPtr(17))
+	v.Validate(raw, d)
+	if d.QualityFlag != QualityOutlier || d.HeartRateQuality != QualityOutlier || d.RespiratoryRateQuality != QualityOK {
+		t.Fatalf("flags = %s/%s/%s", d.QualityFlag, d.HeartRateQuality, d.RespiratoryRateQuality)
+	}
+	if d.HeartRate == nil || *d.HeartRate != 140 || d.RespiratoryRate == nil {
+		t.Fatal("outlier values must be kept")
+	}
+
+	// 偏差在容差内
+	d = vitals(intPtr(70+35), intPtr(16))
+	v.Validate(raw, d)
+	if d.HeartRateQuality != QualityOK {
+		t.Fatalf("deviation within tolerance flagged %s", d.HeartRateQuality)
+	}
+
+	// 其他设备、其他目标的样本互不影响
+	other := vitals(intPtr(140), intPtr(16))
+	other.DeviceID = "dev-2"
+	v.Validate(raw, other)
+	if other.QualityFlag != QualityOK {
+		t.Fatalf("other device flagged %s", other.QualityFlag)
+	}
+	target := vitals(intPtr(140), intPtr(16))
+	target.TrackingID = intPtr(1)
+	v.Validate(raw, target)
+	if target.QualityFlag != QualityOK {
+		t.Fatalf("other target flagged %s", target.QualityFlag)
+	}
+}
+
+// 持续的真实变化在几个样本后被接受
+func TestValidator_OutlierAcceptsSustainedChange(t *testing.T) {
+	v := NewValidator(0, zap.NewNop())
+	raw := rawWith(nil)
+	for i := 0; i < outlierWindow; i++ {
+		v.Validate(raw, vitals(intPtr(60), nil))
+	}
+	var last string
+	for i := 0; i < outlierWindow; i++ {
+		d := vitals(intPtr(120), nil)
+		v.Validate(raw, d)
+		last = d.HeartRateQuality
+	}
+	if last != QualityOK {
+		t.Fatalf("sustained change still flagged %s", last)
+	}
+}
+
+// 超范围的值不计入近期样本
+func TestValidator_OutOfRangeNotRecorded(t *testing.T) {
+	v := NewValidator(0, zap.NewNop())
+	raw := rawWith(nil)
+	for i := 0; i < outlierWindow; i++ {
+		v.Validate(raw, vitals(intPtr(300), nil))
+	}
+	d := vitals(intPtr(70), nil)
+	v.Validate(raw, d)
+	if d.HeartRateQuality != QualityOK {
+		t.Fatalf("flagged %s after out-of-range samples", d.HeartRateQuality)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		values []int
+		want   int
+	}{
+		{[]int{5}, 5},
+		{[]int{3, 1, 2}, 2},
+		{[]int{4, 1, 3, 2}, 2},
+	}
+	for _, tt := range tests {
+		if got := median(tt.values); got != tt.want {
+			t.Errorf("median(%v) = %d, want %d", tt.values, got, tt.want)
+		}
+	}
+}
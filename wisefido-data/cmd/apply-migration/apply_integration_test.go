@@ -0,0 +1,78 @@
+// +build integration
+
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"owl-common/config"
+	"owl-common/database"
+)
+
+func getEnv(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+func getTestDB(t *testing.T) *sql.DB {
+	port, _ := strconv.Atoi(getEnv("TEST_DB_PORT", "5432"))
+	cfg := &config.DatabaseConfig{
+		Host:     getEnv("TEST_DB_HOST", "localhost"),
+		Port:     port,
+		User:     getEnv("TEST_DB_USER", "postgres"),
+		Password: getEnv("TEST_DB_PASSWORD", "postgres"),
+		Database: getEnv("TEST_DB_NAME", "owlrd"),
+		SSLMode:  getEnv("TEST_DB_SSLMODE", "disable"),
+	}
+	db, err := database.NewPostgresDB(cfg)
+	if err != nil {
+		t.Skipf("Skipping integration test: cannot connect to database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Skipf("Skipping integration test: cannot ping database: %v", err)
+	}
+	return db
+}
+
+// 在事务中依次执行每个迁移脚本（执行两次验证幂等），结束后回滚
+func TestApplyMigrationScripts(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	scripts := []string{
+		"field_mapping.sql",
+		"snomed_unmapped_codes.sql",
+		"iot_timeseries_quality_flag.sql",
+		"iot_timeseries_vital_quality.sql",
+		"iot_timeseries_frame_id.sql",
+		"iot_timeseries_ingest_time.sql",
+		"device_sides.sql",
+		"fusion_policy.sql",
+	}
+	for _, name := range scripts {
+		t.Run(name, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("..", "..", "scripts", name))
+			if err != nil {
+				t.Fatalf("read script: %v", err)
+			}
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			defer tx.Rollback()
+			for run := 0; run < 2; run++ {
+				for i, stmt := range splitStatements(string(content)) {
+					if _, err := tx.Exec(stmt); err != nil {
+						t.Fatalf("run %d statement %d failed: %v\n%s", run+1, i+1, err, stmt)
+					}
+				}
+			}
+		})
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"strings"
 
 	_ "github.com/lib/pq"
 	"wisefido-data/internal/config"
@@ -51,19 +50,9 @@ func main() {
 
 	fmt.Printf("Connected to database: %s\n\n", connectedDB)
 
-	// Split SQL by semicolon and execute each statement
-	statements := strings.Split(string(sqlContent), ";")
+	// Split SQL into statements (comments stripped) and execute each statement
+	statements := splitStatements(string(sqlContent))
 	for i, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" || strings.HasPrefix(stmt, "--") {
-			continue
-		}
-		
-		// Skip verification queries (commented out)
-		if strings.Contains(stmt, "Verification query") {
-			continue
-		}
-
 		fmt.Printf("Executing statement %d/%d...\n", i+1, len(statements))
 		_, err := db.Exec(stmt)
 		if err != nil {
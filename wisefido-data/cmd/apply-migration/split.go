@@ -0,0 +1,41 @@
+package main
+
+import "strings"
+
+// splitStatements 按分号拆分 SQL 脚本，去掉 "--" 注释（单引号字符串内的内容原样保留）
+// 迁移脚本以注释说明开头，注释中也可能出现分号，必须先去掉注释再拆分
+func splitStatements(content string) []string {
+	var statements []string
+	var current strings.Builder
+	inString := false
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\'':
+			inString = !inString // '' 转义相当于连续两次切换，结果不变
+			current.WriteByte(c)
+		case inString:
+			current.WriteByte(c)
+		case c == '-' && i+1 < len(content) && content[i+1] == '-':
+			// 注释到行尾
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	content := `-- header; with a semicolon
+-- 应用：go run ./cmd/apply-migration x.sql
+
+CREATE TABLE t (
+    id INT, -- trailing comment; here
+    note TEXT DEFAULT 'a;b -- not a comment'
+);
+
+INSERT INTO t VALUES (1, 'it''s; fine');
+-- SELECT * FROM t;
+`
+	got := splitStatements(content)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %q", len(got), got)
+	}
+	if !strings.HasPrefix(got[0], "CREATE TABLE t") || !strings.Contains(got[0], "'a;b -- not a comment'") {
+		t.Errorf("unexpected first statement %q", got[0])
+	}
+	if strings.Contains(got[0], "trailing comment") {
+		t.Errorf("inline comment not stripped: %q", got[0])
+	}
+	if got[1] != "INSERT INTO t VALUES (1, 'it''s; fine')" {
+		t.Errorf("unexpected second statement %q", got[1])
+	}
+}
+
+// 每个迁移脚本拆分后的语句（注释说明 / 示例查询不执行）
+func TestSplitStatements_Scripts(t *testing.T) {
+	want := map[string][]string{
+		"device_sides.sql":                 {"CREATE TABLE IF NOT EXISTS device_sides"},
+		"field_mapping.sql":                {"CREATE TABLE IF NOT EXISTS field_mapping", "CREATE UNIQUE INDEX IF NOT EXISTS idx_field_mapping_scope_version", "INSERT INTO field_mapping"},
+		"fusion_policy.sql":                {"CREATE TABLE IF NOT EXISTS fusion_policy", "CREATE UNIQUE INDEX IF NOT EXISTS idx_fusion_policy_scope_version"},
+		"iot_timeseries_frame_id.sql":      {"ALTER TABLE iot_timeseries\n    ADD COLUMN IF NOT EXISTS frame_id", "CREATE INDEX IF NOT EXISTS idx_iot_timeseries_device_frame"},
+		"iot_timeseries_ingest_time.sql":   {"ALTER TABLE iot_timeseries\n    ADD COLUMN IF NOT EXISTS ingest_time"},
+		"iot_timeseries_quality_flag.sql":  {"ALTER TABLE iot_timeseries\n    ADD COLUMN IF NOT EXISTS quality_flag"},
+		"iot_timeseries_vital_quality.sql": {"ALTER TABLE iot_timeseries\n    ADD COLUMN IF NOT EXISTS hr_quality"},
+		"snomed_unmapped_codes.sql":        {"CREATE TABLE IF NOT EXISTS snomed_unmapped_codes", "CREATE INDEX IF NOT EXISTS idx_snomed_unmapped_codes_occurrences"},
+	}
+	for name, prefixes := range want {
+		t.Run(name, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("..", "..", "scripts", name))
+			if err != nil {
+				t.Fatalf("read script: %v", err)
+			}
+			got := splitStatements(string(content))
+			if len(got) != len(prefixes) {
+				t.Fatalf("expected %d statements, got %d: %q", len(prefixes), len(got), got)
+			}
+			for i, prefix := range prefixes {
+				if !strings.HasPrefix(got[i], prefix) {
+					t.Errorf("statement %d = %q, want prefix %q", i+1, got[i], prefix)
+				}
+			}
+		})
+	}
+}
@@ -57,6 +57,7 @@ func main() {
 
 	// Optional DB-backed admin APIs (units/rooms/beds/devices)
 	var db *sql.DB
+	var reportTrigger *service.SleepaceReportTrigger // Sleepace 报告下载触发（需要 DB）
 	// Stub depends on tenantsRepo + authStore (used by /auth/api/v1/institutions/search + /auth/api/v1/login)
 	stub := httpapi.NewStubHandler(nil, authStore, nil)
 	// Always register admin routes; if DB is not available, AdminAPI will fall back to stub (no 404).
@@ -111,9 +112,12 @@ func main() {
 		}
 
 		unitsRepo := repository.NewPostgresUnitsRepository(db)
-		devicesRepo := repository.NewPostgresDevicesRepository(db)
-		devicesRepo.SetLogger(logger) // Set logger for device connection logging
-		deviceStoreRepo := repository.NewPostgresDeviceStoreRepository(db)
+		pgDevicesRepo := repository.NewPostgresDevicesRepository(db)
+		pgDevicesRepo.SetLogger(logger) // Set logger for device connection logging
+		// 设备写操作后通过 Redis pub/sub 通知采集服务失效设备身份缓存
+		deviceChanges := repository.NewRedisDeviceChangeNotifier(redisClient, logger)
+		devicesRepo := repository.NewNotifyingDevicesRepository(pgDevicesRepo, deviceChanges)
+		deviceStoreRepo := repository.NewNotifyingDeviceStoreRepository(repository.NewPostgresDeviceStoreRepository(db), deviceChanges)
 		tenantResolver := repository.NewPostgresTenantResolver(db)
 		tenantsRepo = repository.NewPostgresTenantsRepository(db)
 		// Note: StubHandler still uses TenantsRepo (old interface), but we need TenantsRepository for AuthService
@@ -152,11 +156,15 @@ func main() {
 		router.RegisterAuthRoutes(authHandler)
 
 		// 创建 Device Service 和 Handler
-		devicesRepo.SetLogger(logger) // 确保 logger 已设置（用于设备连接日志）
 		deviceService := service.NewDeviceService(devicesRepo, logger)
 		deviceHandler := httpapi.NewDeviceHandler(deviceService, logger)
 		router.RegisterDeviceRoutes(deviceHandler)
 
+		// 创建采集隔离 Service 和 Handler（查看 / 释放被准入策略隔离的设备）
+		quarantineService := service.NewQuarantineService(redisClient, cfg.Ingest.QuarantineStream, devicesRepo, logger)
+		quarantineHandler := httpapi.NewQuarantineHandler(quarantineService, logger)
+		router.RegisterQuarantineRoutes(quarantineHandler)
+
 		// 创建 DeviceStore Handler（直接使用 Repository，不需要 Service 层）
 		deviceStoreHandler := httpapi.NewDeviceStoreHandler(deviceStoreRepo, logger)
 		router.RegisterDeviceStoreRoutes(deviceStoreHandler)
@@ -166,6 +174,12 @@ func main() {
 		unitHandler := httpapi.NewUnitHandler(unitService, logger)
 		router.RegisterUnitRoutes(unitHandler)
 
+		// 创建 Layout Service 和 Handler（单元布局 / 雷达安装姿态，版本保存在 config_versions）
+		configVersionsRepo := repository.NewPostgresConfigVersionsRepository(db)
+		layoutService := service.NewLayoutService(unitsRepo, devicesRepo, deviceStoreRepo, configVersionsRepo, logger)
+		layoutHandler := httpapi.NewLayoutHandler(layoutService, logger)
+		router.RegisterLayoutRoutes(layoutHandler)
+
 		// 创建 User Service 和 Handler
 		// usersRepo 已在上面创建 RoleService 时声明，这里直接使用
 		userService := service.NewUserService(usersRepo, logger)
@@ -234,6 +248,9 @@ func main() {
 			)
 		}
 		
+		// 厂家睡眠分析完成后（wisefido-sleepace 发布报告就绪事件）自动下载报告
+		reportTrigger = service.NewSleepaceReportTrigger(redisClient, cfg.Sleepace.ReportStream, sleepaceReportService, logger)
+
 		sleepaceReportHandler := httpapi.NewSleepaceReportHandler(sleepaceReportService, db, logger)
 		router.RegisterSleepaceReportRoutes(sleepaceReportHandler)
 
@@ -330,6 +347,10 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if reportTrigger != nil {
+		go reportTrigger.Run(ctx)
+	}
+
 	errCh := make(chan error, 1)
 	go func() {
 		errCh <- srv.Start()
@@ -25,6 +25,9 @@ type Config struct {
 	}
 	Sleepace SleepaceConfig `yaml:"sleepace"`
 	MQTT     MQTTConfig     `yaml:"mqtt"`
+	Ingest   struct {
+		QuarantineStream string // 采集隔离流（与采集服务 INGEST_POLICY_QUARANTINE_STREAM 一致）
+	}
 }
 
 // SleepaceConfig Sleepace 厂家服务配置
@@ -34,6 +37,8 @@ type SleepaceConfig struct {
 	ChannelID   string `yaml:"channel_id"`  // Channel ID
 	SecretKey   string `yaml:"secret_key"`  // Secret Key
 	Timezone    int    `yaml:"timezone"`    // 时区偏移（秒）
+
+	ReportStream string `yaml:"report_stream"` // 睡眠报告就绪事件流（与 wisefido-sleepace SLEEPACE_REPORT_STREAM 一致）
 }
 
 // MQTTConfig MQTT 配置（用于触发报告下载）
@@ -72,6 +77,7 @@ func Load() *Config {
 	cfg.Sleepace.ChannelID = getEnv("SLEEPACE_CHANNEL_ID", "")
 	cfg.Sleepace.SecretKey = getEnv("SLEEPACE_SECRET_KEY", "")
 	cfg.Sleepace.Timezone = parseInt(getEnv("SLEEPACE_TIMEZONE", "28800"), 28800) // 默认 UTC+8
+	cfg.Sleepace.ReportStream = getEnv("SLEEPACE_REPORT_STREAM", "sleepace:report:stream")
 
 	// MQTT 配置（用于触发报告下载，默认禁用）
 	cfg.MQTT.Enabled = getEnv("MQTT_ENABLED", "false") == "true"
@@ -81,6 +87,9 @@ func Load() *Config {
 	cfg.MQTT.Password = getEnv("MQTT_PASSWORD", "")
 	cfg.MQTT.Topic = getEnv("MQTT_TOPIC", "sleepace-57136") // Sleepace 厂家提供的主题
 
+	// 采集隔离配置
+	cfg.Ingest.QuarantineStream = getEnv("INGEST_POLICY_QUARANTINE_STREAM", "ingest:quarantine:stream")
+
 	return cfg
 }
 
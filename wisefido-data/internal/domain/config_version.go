@@ -5,6 +5,12 @@ import (
 	"time"
 )
 
+// 配置类型（config_versions.config_type）
+const (
+	ConfigTypeRoomLayout         = "room_layout"         // 单元布局，entity_id = unit_id
+	ConfigTypeDeviceInstallation = "device_installation" // 雷达安装姿态，entity_id = device_id
+)
+
 // ConfigVersion 配置版本领域模型（对应 config_versions 表）
 // 统一配置历史表，按时间保存所有配置类型的快照
 type ConfigVersion struct {
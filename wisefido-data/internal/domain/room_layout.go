@@ -0,0 +1,205 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+)
+
+// 布局坐标范围上限（cm），用于拦截明显错误的输入
+const maxLayoutCoordinate = 100000
+
+// 区域类型（wisefido-sensor-fusion 按区域产生进出 / 停留事件）
+var ZoneTypes = map[string]bool{
+	"bed":           true,
+	"bathroom":      true,
+	"bathroom_door": true,
+	"chair":         true,
+	"exit":          true,
+	"custom":        true,
+}
+
+// 家具类型
+var FurnitureTypes = map[string]bool{
+	"bed":      true,
+	"chair":    true,
+	"sofa":     true,
+	"table":    true,
+	"wardrobe": true,
+	"toilet":   true,
+	"other":    true,
+}
+
+// 雷达安装方式
+var MountTypes = map[string]bool{
+	"wall":    true,
+	"ceiling": true,
+}
+
+// LayoutPoint 布局坐标（cm，单元布局坐标系：俯视，X 向右，Y 向上）
+type LayoutPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// LayoutRoom 布局中的房间轮廓
+type LayoutRoom struct {
+	RoomID  string        `json:"room_id"` // rooms.room_id
+	Name    string        `json:"name,omitempty"`
+	Outline []LayoutPoint `json:"outline"`
+}
+
+// LayoutZone 区域（多边形，顶点按顺序排列）
+type LayoutZone struct {
+	ZoneID  string        `json:"zone_id"`
+	Type    string        `json:"type"` // 见 ZoneTypes
+	Name    string        `json:"name,omitempty"`
+	RoomID  string        `json:"room_id,omitempty"`
+	BedID   string        `json:"bed_id,omitempty"` // bed 区域对应的 beds.bed_id
+	Polygon []LayoutPoint `json:"polygon"`
+}
+
+// LayoutFurniture 家具（仅用于编辑器显示）
+type LayoutFurniture struct {
+	FurnitureID string        `json:"furniture_id"`
+	Type        string        `json:"type"` // 见 FurnitureTypes
+	Name        string        `json:"name,omitempty"`
+	RoomID      string        `json:"room_id,omitempty"`
+	HeightCm    float64       `json:"height_cm,omitempty"`
+	Polygon     []LayoutPoint `json:"polygon"`
+}
+
+// RoomLayout 单元布局（units.layout_config，历史版本保存在 config_versions：config_type = 'room_layout'，entity_id = unit_id）
+type RoomLayout struct {
+	Rooms     []LayoutRoom      `json:"rooms"`
+	Zones     []LayoutZone      `json:"zones"`
+	Furniture []LayoutFurniture `json:"furniture,omitempty"`
+}
+
+// Validate 校验单元布局
+func (l *RoomLayout) Validate() error {
+	rooms := make(map[string]bool, len(l.Rooms))
+	for _, room := range l.Rooms {
+		if room.RoomID == "" {
+			return fmt.Errorf("room_id is required")
+		}
+		if rooms[room.RoomID] {
+			return fmt.Errorf("room %q: duplicate room_id", room.RoomID)
+		}
+		rooms[room.RoomID] = true
+		if err := validatePolygon(room.Outline); err != nil {
+			return fmt.Errorf("room %q: outline %w", room.RoomID, err)
+		}
+	}
+
+	zones := make(map[string]bool, len(l.Zones))
+	for _, zone := range l.Zones {
+		if zone.ZoneID == "" {
+			return fmt.Errorf("zone_id is required")
+		}
+		if zones[zone.ZoneID] {
+			return fmt.Errorf("zone %q: duplicate zone_id", zone.ZoneID)
+		}
+		zones[zone.ZoneID] = true
+		if !ZoneTypes[zone.Type] {
+			return fmt.Errorf("zone %q: invalid type %q", zone.ZoneID, zone.Type)
+		}
+		if zone.RoomID != "" && !rooms[zone.RoomID] {
+			return fmt.Errorf("zone %q: unknown room_id %q", zone.ZoneID, zone.RoomID)
+		}
+		if zone.BedID != "" && zone.Type != "bed" {
+			return fmt.Errorf("zone %q: bed_id is only allowed on bed zones", zone.ZoneID)
+		}
+		if err := validatePolygon(zone.Polygon); err != nil {
+			return fmt.Errorf("zone %q: polygon %w", zone.ZoneID, err)
+		}
+	}
+
+	furniture := make(map[string]bool, len(l.Furniture))
+	for _, item := range l.Furniture {
+		if item.FurnitureID == "" {
+			return fmt.Errorf("furniture_id is required")
+		}
+		if furniture[item.FurnitureID] {
+			return fmt.Errorf("furniture %q: duplicate furniture_id", item.FurnitureID)
+		}
+		furniture[item.FurnitureID] = true
+		if !FurnitureTypes[item.Type] {
+			return fmt.Errorf("furniture %q: invalid type %q", item.FurnitureID, item.Type)
+		}
+		if item.RoomID != "" && !rooms[item.RoomID] {
+			return fmt.Errorf("furniture %q: unknown room_id %q", item.FurnitureID, item.RoomID)
+		}
+		if item.HeightCm < 0 || item.HeightCm > maxLayoutCoordinate {
+			return fmt.Errorf("furniture %q: height_cm out of range", item.FurnitureID)
+		}
+		if err := validatePolygon(item.Polygon); err != nil {
+			return fmt.Errorf("furniture %q: polygon %w", item.FurnitureID, err)
+		}
+	}
+	return nil
+}
+
+// DeviceInstallation 雷达安装姿态（config_versions：config_type = 'device_installation'，entity_id = device_id）
+// wisefido-sensor-fusion 据此将雷达坐标转换为单元布局坐标：
+// 雷达坐标系 X 向右、Y 为正前方、Z 向上（面向雷达正前方观察），先按俯仰角转为水平，再按朝向旋转并平移到安装位置
+type DeviceInstallation struct {
+	UnitID      string      `json:"unit_id"`           // 安装位置所在单元（布局坐标所属单元）
+	RoomID      string      `json:"room_id,omitempty"` // 安装位置所在房间
+	Mount       string      `json:"mount"`             // 见 MountTypes
+	Position    LayoutPoint `json:"position"`          // 雷达在单元布局中的位置（cm）
+	HeightCm    float64     `json:"height_cm"`         // 安装高度（cm，离地）
+	TiltDeg     float64     `json:"tilt_deg"`          // 俯仰角：正前方相对水平向下倾斜的角度（0 水平 ~ 90 垂直向下）
+	RotationDeg float64     `json:"rotation_deg"`      // 水平朝向：正前方相对布局 +Y 轴逆时针旋转的角度
+}
+
+// Validate 校验雷达安装姿态
+func (d *DeviceInstallation) Validate() error {
+	if d.UnitID == "" {
+		return fmt.Errorf("unit_id is required")
+	}
+	if !MountTypes[d.Mount] {
+		return fmt.Errorf("invalid mount %q", d.Mount)
+	}
+	if err := validatePoint(d.Position); err != nil {
+		return fmt.Errorf("position %w", err)
+	}
+	if d.HeightCm <= 0 || d.HeightCm > 1000 {
+		return fmt.Errorf("height_cm must be between 0 and 1000")
+	}
+	if d.TiltDeg < 0 || d.TiltDeg > 90 {
+		return fmt.Errorf("tilt_deg must be between 0 and 90")
+	}
+	if d.RotationDeg <= -360 || d.RotationDeg >= 360 || math.IsNaN(d.RotationDeg) {
+		return fmt.Errorf("rotation_deg must be between -360 and 360")
+	}
+	return nil
+}
+
+// validatePolygon 多边形至少 3 个顶点且面积不为 0
+func validatePolygon(points []LayoutPoint) error {
+	if len(points) < 3 {
+		return fmt.Errorf("needs at least 3 points")
+	}
+	area := 0.0
+	for i, p := range points {
+		if err := validatePoint(p); err != nil {
+			return err
+		}
+		q := points[(i+1)%len(points)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	if area == 0 {
+		return fmt.Errorf("has zero area")
+	}
+	return nil
+}
+
+// validatePoint 坐标有限且在合理范围内
+func validatePoint(p LayoutPoint) error {
+	for _, v := range []float64{p.X, p.Y} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) > maxLayoutCoordinate {
+			return fmt.Errorf("coordinate out of range")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"wisefido-data/internal/domain"
+	"wisefido-data/internal/service"
+
+	"go.uber.org/zap"
+)
+
+const (
+	roomLayoutsPath         = "/admin/api/v1/room-layouts/"
+	deviceInstallationsPath = "/admin/api/v1/device-installations/"
+)
+
+// LayoutHandler 单元布局 / 雷达安装姿态管理 Handler
+type LayoutHandler struct {
+	layoutService service.LayoutService
+	logger        *zap.Logger
+}
+
+// NewLayoutHandler 创建单元布局管理 Handler
+func NewLayoutHandler(layoutService service.LayoutService, logger *zap.Logger) *LayoutHandler {
+	return &LayoutHandler{
+		layoutService: layoutService,
+		logger:        logger,
+	}
+}
+
+// ServeHTTP 实现 http.Handler 接口
+// GET    /admin/api/v1/room-layouts/:unit_id                      查询单元布局
+// PUT    /admin/api/v1/room-layouts/:unit_id                      保存单元布局（新增版本）
+// DELETE /admin/api/v1/room-layouts/:unit_id                      清除单元布局
+// GET    /admin/api/v1/room-layouts/:unit_id/versions             单元布局版本历史
+// GET    /admin/api/v1/device-installations/:device_id            查询雷达安装姿态
+// PUT    /admin/api/v1/device-installations/:device_id            保存雷达安装姿态（新增版本）
+// DELETE /admin/api/v1/device-installations/:device_id            清除雷达安装姿态
+// GET    /admin/api/v1/device-installations/:device_id/versions   雷达安装姿态版本历史
+func (h *LayoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var prefix, configType string
+	switch {
+	case strings.HasPrefix(r.URL.Path, roomLayoutsPath):
+		prefix, configType = roomLayoutsPath, domain.ConfigTypeRoomLayout
+	case strings.HasPrefix(r.URL.Path, deviceInstallationsPath):
+		prefix, configType = deviceInstallationsPath, domain.ConfigTypeDeviceInstallation
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	entityID, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if entityID == "" || (sub != "" && sub != "versions") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case sub == "versions" && r.Method == http.MethodGet:
+		h.ListVersions(w, r, configType, entityID)
+	case sub != "":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	case configType == domain.ConfigTypeRoomLayout && r.Method == http.MethodGet:
+		h.GetUnitLayout(w, r, entityID)
+	case configType == domain.ConfigTypeRoomLayout && r.Method == http.MethodPut:
+		h.SaveUnitLayout(w, r, entityID)
+	case configType == domain.ConfigTypeRoomLayout && r.Method == http.MethodDelete:
+		h.DeleteUnitLayout(w, r, entityID)
+	case r.Method == http.MethodGet:
+		h.GetDeviceInstallation(w, r, entityID)
+	case r.Method == http.MethodPut:
+		h.SaveDeviceInstallation(w, r, entityID)
+	case r.Method == http.MethodDelete:
+		h.DeleteDeviceInstallation(w, r, entityID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// GetUnitLayout 查询单元布局
+func (h *LayoutHandler) GetUnitLayout(w http.ResponseWriter, r *http.Request, unitID string) {
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.layoutService.GetUnitLayout(r.Context(), service.GetUnitLayoutRequest{TenantID: tenantID, UnitID: unitID})
+	if err != nil {
+		h.logger.Error("GetUnitLayout failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{
+		"unit_id": unitID,
+		"layout":  resp.Layout,
+		"version": configVersionToJSON(resp.Version),
+	}))
+}
+
+// SaveUnitLayout 保存单元布局
+func (h *LayoutHandler) SaveUnitLayout(w http.ResponseWriter, r *http.Request, unitID string) {
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	var layout domain.RoomLayout
+	if err := readBodyJSON(r, 1<<20, &layout); err != nil {
+		writeJSON(w, http.StatusOK, Fail("invalid body"))
+		return
+	}
+
+	resp, err := h.layoutService.SaveUnitLayout(r.Context(), service.SaveUnitLayoutRequest{
+		TenantID: tenantID,
+		UnitID:   unitID,
+		Layout:   &layout,
+	})
+	if err != nil {
+		h.logger.Error("SaveUnitLayout failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{
+		"success":    true,
+		"version_id": resp.VersionID,
+	}))
+}
+
+// DeleteUnitLayout 清除单元布局
+func (h *LayoutHandler) DeleteUnitLayout(w http.ResponseWriter, r *http.Request, unitID string) {
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.layoutService.DeleteUnitLayout(r.Context(), service.DeleteUnitLayoutRequest{TenantID: tenantID, UnitID: unitID}); err != nil {
+		h.logger.Error("DeleteUnitLayout failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{"success": true}))
+}
+
+// GetDeviceInstallation 查询雷达安装姿态
+func (h *LayoutHandler) GetDeviceInstallation(w http.ResponseWriter, r *http.Request, deviceID string) {
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.layoutService.GetDeviceInstallation(r.Context(), service.GetDeviceInstallationRequest{TenantID: tenantID, DeviceID: deviceID})
+	if err != nil {
+		h.logger.Error("GetDeviceInstallation failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{
+		"device_id":    deviceID,
+		"installation": resp.Installation,
+		"version":      configVersionToJSON(resp.Version),
+	}))
+}
+
+// SaveDeviceInstallation 保存雷达安装姿态
+func (h *LayoutHandler) SaveDeviceInstallation(w http.ResponseWriter, r *http.Request, deviceID string) {
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	var installation domain.DeviceInstallation
+	if err := readBodyJSON(r, 1<<20, &installation); err != nil {
+		writeJSON(w, http.StatusOK, Fail("invalid body"))
+		return
+	}
+
+	resp, err := h.layoutService.SaveDeviceInstallation(r.Context(), service.SaveDeviceInstallationRequest{
+		TenantID:     tenantID,
+		DeviceID:     deviceID,
+		Installation: &installation,
+	})
+	if err != nil {
+		h.logger.Error("SaveDeviceInstallation failed", zap.Error(err))
+		status := http.StatusOK
+		if errors.Is(err, service.ErrNotRadarDevice) {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, Fail(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{
+		"success":    true,
+		"version_id": resp.VersionID,
+	}))
+}
+
+// DeleteDeviceInstallation 清除雷达安装姿态
+func (h *LayoutHandler) DeleteDeviceInstallation(w http.ResponseWriter, r *http.Request, deviceID string) {
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.layoutService.DeleteDeviceInstallation(r.Context(), service.DeleteDeviceInstallationRequest{TenantID: tenantID, DeviceID: deviceID}); err != nil {
+		h.logger.Error("DeleteDeviceInstallation failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{"success": true}))
+}
+
+// ListVersions 配置版本历史
+func (h *LayoutHandler) ListVersions(w http.ResponseWriter, r *http.Request, configType, entityID string) {
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.layoutService.ListConfigVersions(r.Context(), service.ListConfigVersionsRequest{
+		TenantID:   tenantID,
+		ConfigType: configType,
+		EntityID:   entityID,
+		Page:       parseInt(r.URL.Query().Get("page"), 1),
+		Size:       parseInt(r.URL.Query().Get("size"), 20),
+	})
+	if err != nil {
+		h.logger.Error("ListConfigVersions failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	items := make([]any, 0, len(resp.Items))
+	for _, v := range resp.Items {
+		items = append(items, configVersionToJSON(v))
+	}
+	writeJSON(w, http.StatusOK, Ok(map[string]any{
+		"items": items,
+		"total": resp.Total,
+	}))
+}
+
+// configVersionToJSON 配置版本转换为 JSON（nil 返回 nil）
+func configVersionToJSON(v *domain.ConfigVersion) map[string]any {
+	if v == nil {
+		return nil
+	}
+	m := map[string]any{
+		"version_id":  v.VersionID,
+		"config_type": v.ConfigType,
+		"entity_id":   v.EntityID,
+		"config_data": json.RawMessage(v.ConfigData),
+		"valid_from":  v.ValidFrom.Format(time.RFC3339),
+		"valid_to":    nil,
+	}
+	if v.ValidTo != nil {
+		m["valid_to"] = v.ValidTo.Format(time.RFC3339)
+	}
+	return m
+}
+
+// tenantIDFromReq 从请求中获取 tenant_id（复用 AdminAPI 的逻辑）
+func (h *LayoutHandler) tenantIDFromReq(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if tid := r.URL.Query().Get("tenant_id"); tid != "" {
+		return tid, true
+	}
+	if tid := r.Header.Get("X-Tenant-Id"); tid != "" && tid != "null" {
+		return tid, true
+	}
+	if strings.EqualFold(r.Header.Get("X-User-Role"), "SystemAdmin") {
+		return SystemTenantID(), true
+	}
+	writeJSON(w, http.StatusOK, Fail("tenant_id is required"))
+	return "", false
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wisefido-data/internal/service"
+
+	"go.uber.org/zap"
+)
+
+type fakeLayoutService struct {
+	service.LayoutService
+	err error
+}
+
+func (f *fakeLayoutService) SaveDeviceInstallation(_ context.Context, _ service.SaveDeviceInstallationRequest) (*service.SaveConfigVersionResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &service.SaveConfigVersionResponse{VersionID: "v1"}, nil
+}
+
+// 非雷达设备保存安装姿态返回 400，其他错误沿用 200 + Fail
+func TestLayoutHandler_SaveDeviceInstallationStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"saved", nil, http.StatusOK},
+		{"not a radar", fmt.Errorf("%w: device_type=Sleepace", service.ErrNotRadarDevice), http.StatusBadRequest},
+		{"other error", errors.New("unit not found"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewLayoutHandler(&fakeLayoutService{err: tt.err}, zap.NewNop())
+			req := httptest.NewRequest(http.MethodPut, deviceInstallationsPath+"device-1?tenant_id=t1", strings.NewReader(`{"unit_id":"unit-1"}`))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"wisefido-data/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// QuarantineHandler 采集隔离管理 Handler
+type QuarantineHandler struct {
+	quarantineService service.QuarantineService
+	logger            *zap.Logger
+}
+
+// NewQuarantineHandler 创建采集隔离管理 Handler
+func NewQuarantineHandler(quarantineService service.QuarantineService, logger *zap.Logger) *QuarantineHandler {
+	return &QuarantineHandler{
+		quarantineService: quarantineService,
+		logger:            logger,
+	}
+}
+
+// ServeHTTP 实现 http.Handler 接口
+// GET    /admin/api/v1/quarantine/devices              查询被隔离的设备
+// POST   /admin/api/v1/quarantine/devices/:id/release  审批设备并重放隔离数据
+// DELETE /admin/api/v1/quarantine/devices/:id          丢弃设备的隔离数据
+func (h *QuarantineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/admin/api/v1/quarantine/devices" && r.Method == http.MethodGet:
+		h.ListQuarantinedDevices(w, r)
+	case strings.HasPrefix(r.URL.Path, "/admin/api/v1/quarantine/devices/") && strings.HasSuffix(r.URL.Path, "/release") && r.Method == http.MethodPost:
+		h.ReleaseDevice(w, r)
+	case strings.HasPrefix(r.URL.Path, "/admin/api/v1/quarantine/devices/") && r.Method == http.MethodDelete:
+		h.DiscardDevice(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// ListQuarantinedDevices 查询被隔离的设备
+func (h *QuarantineHandler) ListQuarantinedDevices(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.quarantineService.ListQuarantinedDevices(ctx, service.ListQuarantinedDevicesRequest{TenantID: tenantID})
+	if err != nil {
+		h.logger.Error("ListQuarantinedDevices failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	items := make([]any, 0, len(resp.Items))
+	for _, d := range resp.Items {
+		items = append(items, map[string]any{
+			"device_id":     d.DeviceID,
+			"serial_number": d.SerialNumber,
+			"uid":           d.UID,
+			"device_type":   d.DeviceType,
+			"reasons":       d.Reasons,
+			"count":         d.Count,
+			"first_seen":    d.FirstSeen.Format(time.RFC3339),
+			"last_seen":     d.LastSeen.Format(time.RFC3339),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{
+		"items": items,
+		"total": len(items),
+	}))
+}
+
+// ReleaseDevice 审批设备并将隔离数据重新发布到处理管道
+func (h *QuarantineHandler) ReleaseDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// 路径格式：/admin/api/v1/quarantine/devices/:id/release
+	deviceID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/api/v1/quarantine/devices/"), "/release")
+	if deviceID == "" || strings.Contains(deviceID, "/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	// 请求体可选：{"enable_monitoring": true} 同时开启监测
+	var payload struct {
+		EnableMonitoring bool `json:"enable_monitoring"`
+	}
+	if err := readBodyJSON(r, 1<<20, &payload); err != nil {
+		writeJSON(w, http.StatusOK, Fail("invalid body"))
+		return
+	}
+
+	resp, err := h.quarantineService.ReleaseDevice(ctx, service.ReleaseQuarantinedDeviceRequest{
+		TenantID:         tenantID,
+		DeviceID:         deviceID,
+		EnableMonitoring: payload.EnableMonitoring,
+	})
+	if err != nil {
+		h.logger.Error("ReleaseDevice failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{
+		"success":  true,
+		"replayed": resp.Replayed,
+	}))
+}
+
+// DiscardDevice 丢弃设备的隔离数据
+func (h *QuarantineHandler) DiscardDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	deviceID := strings.TrimPrefix(r.URL.Path, "/admin/api/v1/quarantine/devices/")
+	if deviceID == "" || strings.Contains(deviceID, "/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	tenantID, ok := h.tenantIDFromReq(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.quarantineService.DiscardDevice(ctx, service.DiscardQuarantinedDeviceRequest{
+		TenantID: tenantID,
+		DeviceID: deviceID,
+	})
+	if err != nil {
+		h.logger.Error("DiscardDevice failed", zap.Error(err))
+		writeJSON(w, http.StatusOK, Fail(err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Ok(map[string]any{
+		"success":   true,
+		"discarded": resp.Discarded,
+	}))
+}
+
+// tenantIDFromReq 从请求中获取 tenant_id（复用 AdminAPI 的逻辑）
+func (h *QuarantineHandler) tenantIDFromReq(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if tid := r.URL.Query().Get("tenant_id"); tid != "" {
+		return tid, true
+	}
+	if tid := r.Header.Get("X-Tenant-Id"); tid != "" && tid != "null" {
+		return tid, true
+	}
+	if strings.EqualFold(r.Header.Get("X-User-Role"), "SystemAdmin") {
+		return SystemTenantID(), true
+	}
+	writeJSON(w, http.StatusOK, Fail("tenant_id is required"))
+	return "", false
+}
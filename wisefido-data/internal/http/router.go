@@ -220,6 +220,18 @@ func (r *Router) RegisterDeviceRoutes(h *DeviceHandler) {
 	r.Handle("/device/api/v1/device/", h.GetDeviceRelations)
 }
 
+// RegisterQuarantineRoutes 注册采集隔离管理路由
+func (r *Router) RegisterQuarantineRoutes(h *QuarantineHandler) {
+	r.Handle("/admin/api/v1/quarantine/devices", h.ServeHTTP)
+	r.Handle("/admin/api/v1/quarantine/devices/", h.ServeHTTP)
+}
+
+// RegisterLayoutRoutes 注册单元布局 / 雷达安装姿态管理路由
+func (r *Router) RegisterLayoutRoutes(h *LayoutHandler) {
+	r.Handle("/admin/api/v1/room-layouts/", h.ServeHTTP)
+	r.Handle("/admin/api/v1/device-installations/", h.ServeHTTP)
+}
+
 // RegisterDeviceStoreRoutes 注册设备库存管理路由
 func (r *Router) RegisterDeviceStoreRoutes(h *DeviceStoreHandler) {
 	r.Handle("/admin/api/v1/device-store", h.ServeHTTP)
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"owl-common/events"
+	"wisefido-data/internal/domain"
+)
+
+// DeviceChangeNotifier 设备变更通知（采集服务据此失效设备身份缓存）
+type DeviceChangeNotifier func(ctx context.Context, change events.DeviceChange)
+
+// NewRedisDeviceChangeNotifier 通过 Redis pub/sub 发布设备变更通知
+// 发布失败只记录日志，不影响已提交的写操作（采集服务的缓存 TTL 兜底）
+func NewRedisDeviceChangeNotifier(client *redis.Client, logger *zap.Logger) DeviceChangeNotifier {
+	return func(ctx context.Context, change events.DeviceChange) {
+		if err := events.PublishDeviceChange(ctx, client, change); err != nil && logger != nil {
+			logger.Warn("Failed to publish device change",
+				zap.String("action", change.Action),
+				zap.String("device_id", change.DeviceID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// notifyingDevicesRepository 写操作成功后发布设备变更通知
+type notifyingDevicesRepository struct {
+	DevicesRepository
+	notify DeviceChangeNotifier
+}
+
+// NewNotifyingDevicesRepository 包装 DevicesRepository，写操作成功后发布设备变更通知
+func NewNotifyingDevicesRepository(inner DevicesRepository, notify DeviceChangeNotifier) DevicesRepository {
+	return &notifyingDevicesRepository{DevicesRepository: inner, notify: notify}
+}
+
+func (r *notifyingDevicesRepository) CreateDevice(ctx context.Context, tenantID string, device *domain.Device) (string, error) {
+	deviceID, err := r.DevicesRepository.CreateDevice(ctx, tenantID, device)
+	if err != nil {
+		return "", err
+	}
+	r.notify(ctx, deviceChange(events.DeviceChangeBind, device, tenantID, deviceID))
+	return deviceID, nil
+}
+
+func (r *notifyingDevicesRepository) UpdateDevice(ctx context.Context, tenantID, deviceID string, device *domain.Device) error {
+	before := r.lookup(ctx, tenantID, deviceID)
+	if err := r.DevicesRepository.UpdateDevice(ctx, tenantID, deviceID, device); err != nil {
+		return err
+	}
+
+	action := events.DeviceChangeUpdated
+	if device.BoundBedID.Valid || device.BoundRoomID.Valid {
+		action = events.DeviceChangeUnbind
+		if device.BoundBedID.String != "" || device.BoundRoomID.String != "" {
+			action = events.DeviceChangeBind
+		}
+	}
+	r.notify(ctx, deviceChange(action, before, tenantID, deviceID))
+	return nil
+}
+
+func (r *notifyingDevicesRepository) DeleteDevice(ctx context.Context, tenantID, deviceID string) error {
+	before := r.lookup(ctx, tenantID, deviceID)
+	if err := r.DevicesRepository.DeleteDevice(ctx, tenantID, deviceID); err != nil {
+		return err
+	}
+	r.notify(ctx, deviceChange(events.DeviceChangeUnbind, before, tenantID, deviceID))
+	return nil
+}
+
+func (r *notifyingDevicesRepository) DisableDevice(ctx context.Context, tenantID, deviceID string) error {
+	before := r.lookup(ctx, tenantID, deviceID)
+	if err := r.DevicesRepository.DisableDevice(ctx, tenantID, deviceID); err != nil {
+		return err
+	}
+	r.notify(ctx, deviceChange(events.DeviceChangeUnbind, before, tenantID, deviceID))
+	return nil
+}
+
+// lookup 写操作前读取设备（用于获取 serial_number / uid），失败时返回 nil
+func (r *notifyingDevicesRepository) lookup(ctx context.Context, tenantID, deviceID string) *domain.Device {
+	device, err := r.DevicesRepository.GetDevice(ctx, tenantID, deviceID)
+	if err != nil {
+		return nil
+	}
+	return device
+}
+
+// deviceChange 构建设备变更通知（device 为 nil 时只携带 device_id）
+func deviceChange(action string, device *domain.Device, tenantID, deviceID string) events.DeviceChange {
+	change := events.DeviceChange{Action: action, TenantID: tenantID, DeviceID: deviceID}
+	if device != nil {
+		change.SerialNumber = device.SerialNumber.String
+		change.UID = device.UID.String
+	}
+	return change
+}
+
+// notifyingDeviceStoreRepository 写操作成功后发布设备变更通知
+type notifyingDeviceStoreRepository struct {
+	DeviceStoreRepository
+	notify DeviceChangeNotifier
+}
+
+// NewNotifyingDeviceStoreRepository 包装 DeviceStoreRepository，写操作成功后发布设备变更通知
+func NewNotifyingDeviceStoreRepository(inner DeviceStoreRepository, notify DeviceChangeNotifier) DeviceStoreRepository {
+	return &notifyingDeviceStoreRepository{DeviceStoreRepository: inner, notify: notify}
+}
+
+func (r *notifyingDeviceStoreRepository) CreateDeviceStore(ctx context.Context, deviceStore *domain.DeviceStore) (string, error) {
+	id, err := r.DeviceStoreRepository.CreateDeviceStore(ctx, deviceStore)
+	if err != nil {
+		return "", err
+	}
+	// 新入库的设备可能已被采集服务负缓存为“未注册”
+	r.notify(ctx, deviceStoreChange(events.DeviceChangeUpdated, deviceStore))
+	return id, nil
+}
+
+func (r *notifyingDeviceStoreRepository) BatchUpdateDeviceStores(ctx context.Context, updates []*domain.DeviceStore) error {
+	before := make(map[string]*domain.DeviceStore, len(updates))
+	for _, u := range updates {
+		if u == nil || u.DeviceStoreID == "" {
+			continue
+		}
+		if ds, err := r.DeviceStoreRepository.GetDeviceStore(ctx, u.DeviceStoreID); err == nil {
+			before[u.DeviceStoreID] = ds
+		}
+	}
+
+	if err := r.DeviceStoreRepository.BatchUpdateDeviceStores(ctx, updates); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		if u == nil || u.DeviceStoreID == "" {
+			continue
+		}
+		old, ok := before[u.DeviceStoreID]
+		if !ok {
+			// 无法定位设备标识：通知采集服务清空缓存
+			r.notify(ctx, events.DeviceChange{Action: events.DeviceChangeUpdated})
+			continue
+		}
+
+		action := events.DeviceChangeUpdated
+		switch {
+		case old.AllowAccess && !u.AllowAccess:
+			action = events.DeviceChangeAccessRevoked
+		case u.TenantID != "" && u.TenantID != old.TenantID:
+			action = events.DeviceChangeReassign
+		}
+		change := deviceStoreChange(action, old)
+		if action == events.DeviceChangeReassign {
+			change.TenantID = u.TenantID
+		}
+		r.notify(ctx, change)
+	}
+	return nil
+}
+
+func (r *notifyingDeviceStoreRepository) DeleteDeviceStore(ctx context.Context, deviceStoreID string) error {
+	old, lookupErr := r.DeviceStoreRepository.GetDeviceStore(ctx, deviceStoreID)
+	if err := r.DeviceStoreRepository.DeleteDeviceStore(ctx, deviceStoreID); err != nil {
+		return err
+	}
+	change := events.DeviceChange{Action: events.DeviceChangeUpdated}
+	if lookupErr == nil {
+		change = deviceStoreChange(events.DeviceChangeUpdated, old)
+	}
+	r.notify(ctx, change)
+	return nil
+}
+
+func (r *notifyingDeviceStoreRepository) ImportDeviceStores(ctx context.Context, items []*domain.DeviceStore) (int, []*domain.DeviceStore, []*domain.DeviceStore, error) {
+	count, failed, skipped, err := r.DeviceStoreRepository.ImportDeviceStores(ctx, items)
+	if err == nil && count > 0 {
+		// 批量入库：通知采集服务清空缓存（包括“未注册”负缓存）
+		r.notify(ctx, events.DeviceChange{Action: events.DeviceChangeUpdated})
+	}
+	return count, failed, skipped, err
+}
+
+// deviceStoreChange 根据库存记录构建变更通知
+func deviceStoreChange(action string, ds *domain.DeviceStore) events.DeviceChange {
+	return events.DeviceChange{
+		Action:       action,
+		TenantID:     ds.TenantID,
+		SerialNumber: ds.SerialNumber.String,
+		UID:          ds.UID.String,
+	}
+}
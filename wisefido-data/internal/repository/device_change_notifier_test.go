@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"owl-common/events"
+	"wisefido-data/internal/domain"
+)
+
+type fakeDeviceStoreRepo struct {
+	DeviceStoreRepository
+	stores    map[string]*domain.DeviceStore
+	updateErr error
+}
+
+func (f *fakeDeviceStoreRepo) GetDeviceStore(_ context.Context, id string) (*domain.DeviceStore, error) {
+	ds, ok := f.stores[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return ds, nil
+}
+
+func (f *fakeDeviceStoreRepo) BatchUpdateDeviceStores(_ context.Context, _ []*domain.DeviceStore) error {
+	return f.updateErr
+}
+
+type fakeDevicesRepo struct {
+	DevicesRepository
+	device *domain.Device
+}
+
+func (f *fakeDevicesRepo) GetDevice(_ context.Context, _, _ string) (*domain.Device, error) {
+	return f.device, nil
+}
+
+func (f *fakeDevicesRepo) UpdateDevice(_ context.Context, _, _ string, _ *domain.Device) error {
+	return nil
+}
+
+func collectChanges(changes *[]events.DeviceChange) DeviceChangeNotifier {
+	return func(_ context.Context, c events.DeviceChange) { *changes = append(*changes, c) }
+}
+
+func TestNotifyingDeviceStoreRepository_BatchUpdate(t *testing.T) {
+	inner := &fakeDeviceStoreRepo{stores: map[string]*domain.DeviceStore{
+		"ds-1": {DeviceStoreID: "ds-1", TenantID: "t-1", AllowAccess: true, SerialNumber: sql.NullString{String: "SN-1", Valid: true}},
+		"ds-2": {DeviceStoreID: "ds-2", TenantID: "t-1", AllowAccess: true, UID: sql.NullString{String: "UID-2", Valid: true}},
+		"ds-3": {DeviceStoreID: "ds-3", TenantID: "t-1", AllowAccess: true},
+	}}
+	var changes []events.DeviceChange
+	repo := NewNotifyingDeviceStoreRepository(inner, collectChanges(&changes))
+
+	err := repo.BatchUpdateDeviceStores(context.Background(), []*domain.DeviceStore{
+		{DeviceStoreID: "ds-1", TenantID: "t-1", AllowAccess: false},
+		{DeviceStoreID: "ds-2", TenantID: "t-2", AllowAccess: true},
+		{DeviceStoreID: "ds-3", AllowAccess: true},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []events.DeviceChange{
+		{Action: events.DeviceChangeAccessRevoked, TenantID: "t-1", SerialNumber: "SN-1"},
+		{Action: events.DeviceChangeReassign, TenantID: "t-2", UID: "UID-2"},
+		{Action: events.DeviceChangeUpdated, TenantID: "t-1"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: expected %+v, got %+v", i, want[i], changes[i])
+		}
+	}
+}
+
+func TestNotifyingDeviceStoreRepository_NoNotifyOnError(t *testing.T) {
+	inner := &fakeDeviceStoreRepo{updateErr: errors.New("db down")}
+	var changes []events.DeviceChange
+	repo := NewNotifyingDeviceStoreRepository(inner, collectChanges(&changes))
+
+	if err := repo.BatchUpdateDeviceStores(context.Background(), []*domain.DeviceStore{{DeviceStoreID: "ds-1"}}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no notifications, got %+v", changes)
+	}
+}
+
+func TestNotifyingDevicesRepository_UpdateBinding(t *testing.T) {
+	inner := &fakeDevicesRepo{device: &domain.Device{
+		DeviceID:     "d-1",
+		TenantID:     "t-1",
+		SerialNumber: sql.NullString{String: "SN-1", Valid: true},
+	}}
+	var changes []events.DeviceChange
+	repo := NewNotifyingDevicesRepository(inner, collectChanges(&changes))
+	ctx := context.Background()
+
+	_ = repo.UpdateDevice(ctx, "t-1", "d-1", &domain.Device{BoundBedID: sql.NullString{String: "bed-1", Valid: true}})
+	_ = repo.UpdateDevice(ctx, "t-1", "d-1", &domain.Device{BoundBedID: sql.NullString{Valid: true}})
+	_ = repo.UpdateDevice(ctx, "t-1", "d-1", &domain.Device{DeviceName: "renamed"})
+
+	actions := []string{events.DeviceChangeBind, events.DeviceChangeUnbind, events.DeviceChangeUpdated}
+	if len(changes) != len(actions) {
+		t.Fatalf("expected %d changes, got %+v", len(actions), changes)
+	}
+	for i, action := range actions {
+		if changes[i].Action != action || changes[i].DeviceID != "d-1" || changes[i].SerialNumber != "SN-1" {
+			t.Errorf("change %d: unexpected %+v", i, changes[i])
+		}
+	}
+}
@@ -855,6 +855,26 @@ func (r *PostgresUnitsRepository) UpdateUnit(ctx context.Context, tenantID, unit
 	return nil
 }
 
+// UpdateUnitLayout: 只更新 layout_config（NULL 表示清除布局）
+// UpdateUnit 会同时写入 is_public_space 等字段，布局编辑器单独保存布局时使用本方法
+func (r *PostgresUnitsRepository) UpdateUnitLayout(ctx context.Context, tenantID, unitID string, layoutConfig sql.NullString) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE units SET layout_config = $3::jsonb WHERE tenant_id = $1 AND unit_id = $2`,
+		tenantID, unitID, layoutConfig,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update unit layout: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("unit not found")
+	}
+	return nil
+}
+
 // DeleteUnit: 删除 unit
 // 替代触发器：无（仅删除）
 func (r *PostgresUnitsRepository) DeleteUnit(ctx context.Context, tenantID, unitID string) error {
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"wisefido-data/internal/domain"
 )
 
@@ -21,6 +22,7 @@ type UnitsRepository interface {
 	CreateUnit(ctx context.Context, tenantID string, unit *domain.Unit) (string, error)
 	UpdateUnit(ctx context.Context, tenantID, unitID string, unit *domain.Unit) error
 	DeleteUnit(ctx context.Context, tenantID, unitID string) error
+	UpdateUnitLayout(ctx context.Context, tenantID, unitID string, layoutConfig sql.NullString) error
 
 	// Room 操作
 	ListRooms(ctx context.Context, tenantID, unitID string) ([]*domain.Room, error)
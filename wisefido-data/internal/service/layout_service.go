@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"wisefido-data/internal/domain"
+	"wisefido-data/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// LayoutService 单元布局 / 雷达安装姿态管理服务接口
+// 单元布局写入 units.layout_config，雷达安装姿态只保存在 config_versions；
+// 每次保存都在 config_versions 中新增一个版本（旧版本 valid_to 自动关闭），用于回放历史数据时还原当时的配置。
+// wisefido-sensor-fusion 读取当前布局的区域和当前安装姿态（缓存过期后生效）
+type LayoutService interface {
+	// 单元布局
+	GetUnitLayout(ctx context.Context, req GetUnitLayoutRequest) (*GetUnitLayoutResponse, error)
+	SaveUnitLayout(ctx context.Context, req SaveUnitLayoutRequest) (*SaveConfigVersionResponse, error)
+	DeleteUnitLayout(ctx context.Context, req DeleteUnitLayoutRequest) error
+
+	// 雷达安装姿态
+	GetDeviceInstallation(ctx context.Context, req GetDeviceInstallationRequest) (*GetDeviceInstallationResponse, error)
+	SaveDeviceInstallation(ctx context.Context, req SaveDeviceInstallationRequest) (*SaveConfigVersionResponse, error)
+	DeleteDeviceInstallation(ctx context.Context, req DeleteDeviceInstallationRequest) error
+
+	// 版本历史
+	ListConfigVersions(ctx context.Context, req ListConfigVersionsRequest) (*ListConfigVersionsResponse, error)
+}
+
+// ErrNotRadarDevice 安装姿态只适用于雷达设备
+var ErrNotRadarDevice = errors.New("device is not a radar")
+
+// layoutService 实现
+type layoutService struct {
+	unitsRepo       repository.UnitsRepository
+	devicesRepo     repository.DevicesRepository
+	deviceStoreRepo repository.DeviceStoreRepository
+	versionsRepo    repository.ConfigVersionsRepository
+	logger          *zap.Logger
+}
+
+// NewLayoutService 创建 LayoutService 实例
+func NewLayoutService(
+	unitsRepo repository.UnitsRepository,
+	devicesRepo repository.DevicesRepository,
+	deviceStoreRepo repository.DeviceStoreRepository,
+	versionsRepo repository.ConfigVersionsRepository,
+	logger *zap.Logger,
+) LayoutService {
+	return &layoutService{
+		unitsRepo:       unitsRepo,
+		devicesRepo:     devicesRepo,
+		deviceStoreRepo: deviceStoreRepo,
+		versionsRepo:    versionsRepo,
+		logger:          logger,
+	}
+}
+
+// GetUnitLayoutRequest 查询单元布局请求
+type GetUnitLayoutRequest struct {
+	TenantID string // 必填
+	UnitID   string // 必填
+}
+
+// GetUnitLayoutResponse 查询单元布局响应
+type GetUnitLayoutResponse struct {
+	Layout  *domain.RoomLayout    // 未配置布局时为 nil
+	Version *domain.ConfigVersion // 当前版本（布局在版本管理之前写入时为 nil）
+}
+
+// SaveUnitLayoutRequest 保存单元布局请求
+type SaveUnitLayoutRequest struct {
+	TenantID string             // 必填
+	UnitID   string             // 必填
+	Layout   *domain.RoomLayout // 必填
+}
+
+// DeleteUnitLayoutRequest 清除单元布局请求
+type DeleteUnitLayoutRequest struct {
+	TenantID string // 必填
+	UnitID   string // 必填
+}
+
+// GetDeviceInstallationRequest 查询雷达安装姿态请求
+type GetDeviceInstallationRequest struct {
+	TenantID string // 必填
+	DeviceID string // 必填
+}
+
+// GetDeviceInstallationResponse 查询雷达安装姿态响应
+type GetDeviceInstallationResponse struct {
+	Installation *domain.DeviceInstallation // 未配置时为 nil
+	Version      *domain.ConfigVersion
+}
+
+// SaveDeviceInstallationRequest 保存雷达安装姿态请求
+type SaveDeviceInstallationRequest struct {
+	TenantID     string                     // 必填
+	DeviceID     string                     // 必填
+	Installation *domain.DeviceInstallation // 必填
+}
+
+// DeleteDeviceInstallationRequest 清除雷达安装姿态请求
+type DeleteDeviceInstallationRequest struct {
+	TenantID string // 必填
+	DeviceID string // 必填
+}
+
+// SaveConfigVersionResponse 保存配置响应
+type SaveConfigVersionResponse struct {
+	VersionID string // 新版本 ID
+}
+
+// ListConfigVersionsRequest 查询配置版本历史请求
+type ListConfigVersionsRequest struct {
+	TenantID   string // 必填
+	ConfigType string // 必填：domain.ConfigTypeRoomLayout 或 domain.ConfigTypeDeviceInstallation
+	EntityID   string // 必填：unit_id 或 device_id
+	Page       int    // 可选，默认 1
+	Size       int    // 可选，默认 20
+}
+
+// ListConfigVersionsResponse 查询配置版本历史响应
+type ListConfigVersionsResponse struct {
+	Items []*domain.ConfigVersion // 按生效时间倒序
+	Total int
+}
+
+// GetUnitLayout 查询单元布局
+func (s *layoutService) GetUnitLayout(ctx context.Context, req GetUnitLayoutRequest) (*GetUnitLayoutResponse, error) {
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+	if req.UnitID == "" {
+		return nil, fmt.Errorf("unit_id is required")
+	}
+
+	unit, err := s.unitsRepo.GetUnit(ctx, req.TenantID, req.UnitID)
+	if err != nil {
+		return nil, fmt.Errorf("unit not found")
+	}
+
+	resp := &GetUnitLayoutResponse{}
+	if unit.LayoutConfig.Valid && unit.LayoutConfig.String != "" {
+		layout := &domain.RoomLayout{}
+		if err := json.Unmarshal([]byte(unit.LayoutConfig.String), layout); err != nil {
+			return nil, fmt.Errorf("stored layout_config is not a valid layout: %w", err)
+		}
+		resp.Layout = layout
+	}
+
+	version, err := s.currentVersion(ctx, req.TenantID, domain.ConfigTypeRoomLayout, req.UnitID)
+	if err != nil {
+		return nil, err
+	}
+	resp.Version = version
+	return resp, nil
+}
+
+// SaveUnitLayout 校验并保存单元布局，同时记录新版本
+func (s *layoutService) SaveUnitLayout(ctx context.Context, req SaveUnitLayoutRequest) (*SaveConfigVersionResponse, error) {
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+	if req.UnitID == "" {
+		return nil, fmt.Errorf("unit_id is required")
+	}
+	if req.Layout == nil {
+		return nil, fmt.Errorf("layout is required")
+	}
+	if err := req.Layout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid layout: %w", err)
+	}
+	if err := s.checkLayoutRefs(ctx, req.TenantID, req.UnitID, req.Layout); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(req.Layout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal layout: %w", err)
+	}
+	if err := s.unitsRepo.UpdateUnitLayout(ctx, req.TenantID, req.UnitID, sql.NullString{String: string(data), Valid: true}); err != nil {
+		return nil, err
+	}
+
+	versionID, err := s.versionsRepo.CreateConfigVersion(ctx, req.TenantID, &domain.ConfigVersion{
+		ConfigType:      domain.ConfigTypeRoomLayout,
+		EntityID:        req.UnitID,
+		CurrentEntityID: req.UnitID,
+		ConfigData:      data,
+	})
+	if err != nil {
+		// 布局已保存，只是缺少历史版本
+		s.logger.Error("SaveUnitLayout: failed to record config version",
+			zap.String("tenant_id", req.TenantID),
+			zap.String("unit_id", req.UnitID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("layout saved but failed to record version: %w", err)
+	}
+
+	s.logger.Info("Unit layout saved",
+		zap.String("tenant_id", req.TenantID),
+		zap.String("unit_id", req.UnitID),
+		zap.String("version_id", versionID),
+		zap.Int("zones", len(req.Layout.Zones)),
+	)
+	return &SaveConfigVersionResponse{VersionID: versionID}, nil
+}
+
+// DeleteUnitLayout 清除单元布局，并关闭当前版本（历史版本保留）
+func (s *layoutService) DeleteUnitLayout(ctx context.Context, req DeleteUnitLayoutRequest) error {
+	if req.TenantID == "" {
+		return fmt.Errorf("tenant_id is required")
+	}
+	if req.UnitID == "" {
+		return fmt.Errorf("unit_id is required")
+	}
+	if err := s.unitsRepo.UpdateUnitLayout(ctx, req.TenantID, req.UnitID, sql.NullString{}); err != nil {
+		return err
+	}
+	return s.closeCurrentVersion(ctx, req.TenantID, domain.ConfigTypeRoomLayout, req.UnitID)
+}
+
+// GetDeviceInstallation 查询雷达当前的安装姿态
+func (s *layoutService) GetDeviceInstallation(ctx context.Context, req GetDeviceInstallationRequest) (*GetDeviceInstallationResponse, error) {
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+	if req.DeviceID == "" {
+		return nil, fmt.Errorf("device_id is required")
+	}
+
+	version, err := s.currentVersion(ctx, req.TenantID, domain.ConfigTypeDeviceInstallation, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &GetDeviceInstallationResponse{Version: version}
+	if version != nil {
+		installation := &domain.DeviceInstallation{}
+		if err := json.Unmarshal(version.ConfigData, installation); err != nil {
+			return nil, fmt.Errorf("stored installation is not valid: %w", err)
+		}
+		resp.Installation = installation
+	}
+	return resp, nil
+}
+
+// SaveDeviceInstallation 校验并保存雷达安装姿态（新增版本）
+// 设备须属于该租户且为 Radar，其他设备类型返回 ErrNotRadarDevice
+func (s *layoutService) SaveDeviceInstallation(ctx context.Context, req SaveDeviceInstallationRequest) (*SaveConfigVersionResponse, error) {
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+	if req.DeviceID == "" {
+		return nil, fmt.Errorf("device_id is required")
+	}
+	if req.Installation == nil {
+		return nil, fmt.Errorf("installation is required")
+	}
+	if err := req.Installation.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid installation: %w", err)
+	}
+	device, err := s.devicesRepo.GetDevice(ctx, req.TenantID, req.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("device not found")
+	}
+	deviceType, err := s.getDeviceType(ctx, device)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device type: %w", err)
+	}
+	if deviceType != "Radar" {
+		return nil, fmt.Errorf("%w: device_type=%s", ErrNotRadarDevice, deviceType)
+	}
+	if _, err := s.unitsRepo.GetUnit(ctx, req.TenantID, req.Installation.UnitID); err != nil {
+		return nil, fmt.Errorf("unit not found")
+	}
+	if req.Installation.RoomID != "" {
+		room, err := s.unitsRepo.GetRoom(ctx, req.TenantID, req.Installation.RoomID)
+		if err != nil || room.UnitID != req.Installation.UnitID {
+			return nil, fmt.Errorf("room %q does not belong to unit", req.Installation.RoomID)
+		}
+	}
+
+	data, err := json.Marshal(req.Installation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal installation: %w", err)
+	}
+	versionID, err := s.versionsRepo.CreateConfigVersion(ctx, req.TenantID, &domain.ConfigVersion{
+		ConfigType:      domain.ConfigTypeDeviceInstallation,
+		EntityID:        req.DeviceID,
+		CurrentEntityID: req.DeviceID,
+		ConfigData:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Device installation saved",
+		zap.String("tenant_id", req.TenantID),
+		zap.String("device_id", req.DeviceID),
+		zap.String("version_id", versionID),
+	)
+	return &SaveConfigVersionResponse{VersionID: versionID}, nil
+}
+
+// DeleteDeviceInstallation 清除雷达安装姿态（关闭当前版本，融合服务回退到按雷达坐标直接使用）
+func (s *layoutService) DeleteDeviceInstallation(ctx context.Context, req DeleteDeviceInstallationRequest) error {
+	if req.TenantID == "" {
+		return fmt.Errorf("tenant_id is required")
+	}
+	if req.DeviceID == "" {
+		return fmt.Errorf("device_id is required")
+	}
+	return s.closeCurrentVersion(ctx, req.TenantID, domain.ConfigTypeDeviceInstallation, req.DeviceID)
+}
+
+// ListConfigVersions 查询配置版本历史
+func (s *layoutService) ListConfigVersions(ctx context.Context, req ListConfigVersionsRequest) (*ListConfigVersionsResponse, error) {
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+	if req.ConfigType != domain.ConfigTypeRoomLayout && req.ConfigType != domain.ConfigTypeDeviceInstallation {
+		return nil, fmt.Errorf("invalid config_type %q", req.ConfigType)
+	}
+	if req.EntityID == "" {
+		return nil, fmt.Errorf("entity_id is required")
+	}
+
+	items, total, err := s.versionsRepo.ListConfigVersions(ctx, req.TenantID, req.ConfigType, req.EntityID, nil, req.Page, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &ListConfigVersionsResponse{Items: items, Total: total}, nil
+}
+
+// checkLayoutRefs 布局引用的房间 / 床位必须属于该单元
+func (s *layoutService) checkLayoutRefs(ctx context.Context, tenantID, unitID string, layout *domain.RoomLayout) error {
+	rooms, err := s.unitsRepo.ListRoomsWithBeds(ctx, tenantID, unitID)
+	if err != nil {
+		return fmt.Errorf("failed to list rooms: %w", err)
+	}
+	roomIDs := make(map[string]bool, len(rooms))
+	bedIDs := make(map[string]bool)
+	for _, room := range rooms {
+		roomIDs[room.Room.RoomID] = true
+		for _, bed := range room.Beds {
+			bedIDs[bed.BedID] = true
+		}
+	}
+	for _, room := range layout.Rooms {
+		if !roomIDs[room.RoomID] {
+			return fmt.Errorf("room %q does not belong to unit", room.RoomID)
+		}
+	}
+	for _, zone := range layout.Zones {
+		if zone.BedID != "" && !bedIDs[zone.BedID] {
+			return fmt.Errorf("zone %q: bed %q does not belong to unit", zone.ZoneID, zone.BedID)
+		}
+	}
+	return nil
+}
+
+// currentVersion 当前生效的版本（没有时返回 nil）
+func (s *layoutService) currentVersion(ctx context.Context, tenantID, configType, entityID string) (*domain.ConfigVersion, error) {
+	version, err := s.versionsRepo.GetConfigVersionAtTime(ctx, tenantID, configType, entityID, time.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return version, nil
+}
+
+// closeCurrentVersion 关闭当前版本（valid_to = 现在）
+func (s *layoutService) closeCurrentVersion(ctx context.Context, tenantID, configType, entityID string) error {
+	version, err := s.currentVersion(ctx, tenantID, configType, entityID)
+	if err != nil || version == nil {
+		return err
+	}
+	now := time.Now()
+	version.ValidTo = &now
+	if err := s.versionsRepo.UpdateConfigVersion(ctx, tenantID, version.VersionID, version); err != nil {
+		return err
+	}
+
+	s.logger.Info("Config version closed",
+		zap.String("tenant_id", tenantID),
+		zap.String("config_type", configType),
+		zap.String("entity_id", entityID),
+		zap.String("version_id", version.VersionID),
+	)
+	return nil
+}
+
+// getDeviceType 获取设备类型（通过 device_store_id）
+func (s *layoutService) getDeviceType(ctx context.Context, device *domain.Device) (string, error) {
+	if !device.DeviceStoreID.Valid {
+		return "", fmt.Errorf("device has no device_store_id")
+	}
+	deviceStore, err := s.deviceStoreRepo.GetDeviceStore(ctx, device.DeviceStoreID.String)
+	if err != nil {
+		return "", fmt.Errorf("failed to get device store: %w", err)
+	}
+	return deviceStore.DeviceType, nil
+}
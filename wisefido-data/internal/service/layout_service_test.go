@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"wisefido-data/internal/domain"
+	"wisefido-data/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+type fakeLayoutUnitsRepo struct {
+	repository.UnitsRepository
+	rooms   []*repository.RoomWithBeds
+	layouts map[string]sql.NullString
+}
+
+func (f *fakeLayoutUnitsRepo) GetUnit(_ context.Context, _, unitID string) (*domain.Unit, error) {
+	if unitID != "unit-1" {
+		return nil, sql.ErrNoRows
+	}
+	return &domain.Unit{UnitID: unitID, LayoutConfig: f.layouts[unitID]}, nil
+}
+
+func (f *fakeLayoutUnitsRepo) GetRoom(_ context.Context, _, roomID string) (*domain.Room, error) {
+	for _, r := range f.rooms {
+		if r.Room.RoomID == roomID {
+			return r.Room, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (f *fakeLayoutUnitsRepo) ListRoomsWithBeds(_ context.Context, _, _ string) ([]*repository.RoomWithBeds, error) {
+	return f.rooms, nil
+}
+
+func (f *fakeLayoutUnitsRepo) UpdateUnitLayout(_ context.Context, _, unitID string, layoutConfig sql.NullString) error {
+	f.layouts[unitID] = layoutConfig
+	return nil
+}
+
+type fakeLayoutDevicesRepo struct {
+	repository.DevicesRepository
+}
+
+func (f *fakeLayoutDevicesRepo) GetDevice(_ context.Context, _, deviceID string) (*domain.Device, error) {
+	if deviceID != "radar-1" && deviceID != "sleepace-1" {
+		return nil, sql.ErrNoRows
+	}
+	return &domain.Device{DeviceID: deviceID, DeviceStoreID: sql.NullString{String: "ds-" + deviceID, Valid: true}}, nil
+}
+
+type fakeLayoutDeviceStoreRepo struct {
+	repository.DeviceStoreRepository
+}
+
+func (f *fakeLayoutDeviceStoreRepo) GetDeviceStore(_ context.Context, deviceStoreID string) (*domain.DeviceStore, error) {
+	switch deviceStoreID {
+	case "ds-radar-1":
+		return &domain.DeviceStore{DeviceStoreID: deviceStoreID, DeviceType: "Radar"}, nil
+	case "ds-sleepace-1":
+		return &domain.DeviceStore{DeviceStoreID: deviceStoreID, DeviceType: "Sleepace"}, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+type fakeConfigVersionsRepo struct {
+	repository.ConfigVersionsRepository
+	versions []*domain.ConfigVersion
+}
+
+func (f *fakeConfigVersionsRepo) CreateConfigVersion(_ context.Context, _ string, v *domain.ConfigVersion) (string, error) {
+	v.VersionID = "v" + string(rune('1'+len(f.versions)))
+	v.ValidFrom = time.Now().Add(-time.Second)
+	f.versions = append(f.versions, v)
+	return v.VersionID, nil
+}
+
+func (f *fakeConfigVersionsRepo) GetConfigVersionAtTime(_ context.Context, _, configType, entityID string, at time.Time) (*domain.ConfigVersion, error) {
+	for i := len(f.versions) - 1; i >= 0; i-- {
+		v := f.versions[i]
+		if v.ConfigType == configType && v.EntityID == entityID && (v.ValidTo == nil || v.ValidTo.After(at)) {
+			return v, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (f *fakeConfigVersionsRepo) UpdateConfigVersion(_ context.Context, _, versionID string, v *domain.ConfigVersion) error {
+	for i, existing := range f.versions {
+		if existing.VersionID == versionID {
+			f.versions[i] = v
+			return nil
+		}
+	}
+	return errors.New("version not found")
+}
+
+func newTestLayoutService() (LayoutService, *fakeLayoutUnitsRepo, *fakeConfigVersionsRepo) {
+	units := &fakeLayoutUnitsRepo{
+		rooms: []*repository.RoomWithBeds{{
+			Room: &domain.Room{RoomID: "room-1", UnitID: "unit-1"},
+			Beds: []*domain.Bed{{BedID: "bed-1"}},
+		}},
+		layouts: make(map[string]sql.NullString),
+	}
+	versions := &fakeConfigVersionsRepo{}
+	return NewLayoutService(units, &fakeLayoutDevicesRepo{}, &fakeLayoutDeviceStoreRepo{}, versions, zap.NewNop()), units, versions
+}
+
+func square(x, y, size float64) []domain.LayoutPoint {
+	return []domain.LayoutPoint{{X: x, Y: y}, {X: x + size, Y: y}, {X: x + size, Y: y + size}, {X: x, Y: y + size}}
+}
+
+func testLayout() *domain.RoomLayout {
+	return &domain.RoomLayout{
+		Rooms: []domain.LayoutRoom{{RoomID: "room-1", Outline: square(0, 0, 400)}},
+		Zones: []domain.LayoutZone{{ZoneID: "z-bed", Type: "bed", RoomID: "room-1", BedID: "bed-1", Polygon: square(0, 0, 200)}},
+	}
+}
+
+func TestRoomLayout_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(l *domain.RoomLayout)
+		want   string
+	}{
+		{"valid", func(l *domain.RoomLayout) {}, ""},
+		{"duplicate zone", func(l *domain.RoomLayout) { l.Zones = append(l.Zones, l.Zones[0]) }, "duplicate zone_id"},
+		{"unknown zone type", func(l *domain.RoomLayout) { l.Zones[0].Type = "garden" }, "invalid type"},
+		{"unknown room", func(l *domain.RoomLayout) { l.Zones[0].RoomID = "room-9" }, "unknown room_id"},
+		{"bed_id on chair", func(l *domain.RoomLayout) { l.Zones[0].Type = "chair" }, "bed_id is only allowed"},
+		{"too few points", func(l *domain.RoomLayout) { l.Zones[0].Polygon = l.Zones[0].Polygon[:2] }, "at least 3 points"},
+		{"collinear points", func(l *domain.RoomLayout) {
+			l.Zones[0].Polygon = []domain.LayoutPoint{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 2}}
+		}, "zero area"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := testLayout()
+			tt.mutate(l)
+			err := l.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutService_SaveUnitLayout(t *testing.T) {
+	svc, units, versions := newTestLayoutService()
+	ctx := context.Background()
+
+	resp, err := svc.SaveUnitLayout(ctx, SaveUnitLayoutRequest{TenantID: "t1", UnitID: "unit-1", Layout: testLayout()})
+	if err != nil {
+		t.Fatalf("SaveUnitLayout failed: %v", err)
+	}
+	if resp.VersionID == "" || len(versions.versions) != 1 {
+		t.Fatalf("expected one version, got %+v", versions.versions)
+	}
+	if v := versions.versions[0]; v.ConfigType != domain.ConfigTypeRoomLayout || v.EntityID != "unit-1" {
+		t.Fatalf("unexpected version %+v", v)
+	}
+	stored := units.layouts["unit-1"]
+	if !stored.Valid || stored.String != string(versions.versions[0].ConfigData) {
+		t.Fatalf("layout_config not written: %+v", stored)
+	}
+
+	got, err := svc.GetUnitLayout(ctx, GetUnitLayoutRequest{TenantID: "t1", UnitID: "unit-1"})
+	if err != nil {
+		t.Fatalf("GetUnitLayout failed: %v", err)
+	}
+	if got.Layout == nil || len(got.Layout.Zones) != 1 || got.Version == nil || got.Version.VersionID != resp.VersionID {
+		t.Fatalf("unexpected layout response %+v", got)
+	}
+}
+
+func TestLayoutService_SaveUnitLayout_RejectsForeignBed(t *testing.T) {
+	svc, units, versions := newTestLayoutService()
+	layout := testLayout()
+	layout.Zones[0].BedID = "bed-other-unit"
+
+	_, err := svc.SaveUnitLayout(context.Background(), SaveUnitLayoutRequest{TenantID: "t1", UnitID: "unit-1", Layout: layout})
+	if err == nil || !strings.Contains(err.Error(), "does not belong to unit") {
+		t.Fatalf("expected foreign bed rejected, got %v", err)
+	}
+	if _, ok := units.layouts["unit-1"]; ok || len(versions.versions) != 0 {
+		t.Fatal("rejected layout must not be written")
+	}
+}
+
+func TestLayoutService_DeleteUnitLayout(t *testing.T) {
+	svc, units, versions := newTestLayoutService()
+	ctx := context.Background()
+	if _, err := svc.SaveUnitLayout(ctx, SaveUnitLayoutRequest{TenantID: "t1", UnitID: "unit-1", Layout: testLayout()}); err != nil {
+		t.Fatalf("SaveUnitLayout failed: %v", err)
+	}
+
+	if err := svc.DeleteUnitLayout(ctx, DeleteUnitLayoutRequest{TenantID: "t1", UnitID: "unit-1"}); err != nil {
+		t.Fatalf("DeleteUnitLayout failed: %v", err)
+	}
+	if units.layouts["unit-1"].Valid {
+		t.Fatal("layout_config should be cleared")
+	}
+	if versions.versions[0].ValidTo == nil {
+		t.Fatal("current version should be closed")
+	}
+	// 没有当前版本时重复删除不报错
+	if err := svc.DeleteUnitLayout(ctx, DeleteUnitLayoutRequest{TenantID: "t1", UnitID: "unit-1"}); err != nil {
+		t.Fatalf("second DeleteUnitLayout failed: %v", err)
+	}
+}
+
+func TestLayoutService_SaveDeviceInstallation(t *testing.T) {
+	svc, _, versions := newTestLayoutService()
+	ctx := context.Background()
+	installation := domain.DeviceInstallation{
+		UnitID:      "unit-1",
+		RoomID:      "room-1",
+		Mount:       "wall",
+		Position:    domain.LayoutPoint{X: 0, Y: 200},
+		HeightCm:    150,
+		TiltDeg:     30,
+		RotationDeg: -90,
+	}
+
+	tests := []struct {
+		name   string
+		device string
+		mutate func(d *domain.DeviceInstallation)
+		want   string
+	}{
+		{"bad tilt", "radar-1", func(d *domain.DeviceInstallation) { d.TiltDeg = 120 }, "tilt_deg"},
+		{"bad mount", "radar-1", func(d *domain.DeviceInstallation) { d.Mount = "floor" }, "invalid mount"},
+		{"unknown device", "radar-9", func(d *domain.DeviceInstallation) {}, "device not found"},
+		{"not a radar", "sleepace-1", func(d *domain.DeviceInstallation) {}, ErrNotRadarDevice.Error()},
+		{"unknown unit", "radar-1", func(d *domain.DeviceInstallation) { d.UnitID = "unit-9" }, "unit not found"},
+		{"unknown room", "radar-1", func(d *domain.DeviceInstallation) { d.RoomID = "room-9" }, "does not belong to unit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := installation
+			tt.mutate(&d)
+			_, err := svc.SaveDeviceInstallation(ctx, SaveDeviceInstallationRequest{TenantID: "t1", DeviceID: tt.device, Installation: &d})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if len(versions.versions) != 0 {
+		t.Fatal("rejected installations must not create versions")
+	}
+
+	if _, err := svc.SaveDeviceInstallation(ctx, SaveDeviceInstallationRequest{TenantID: "t1", DeviceID: "radar-1", Installation: &installation}); err != nil {
+		t.Fatalf("SaveDeviceInstallation failed: %v", err)
+	}
+	got, err := svc.GetDeviceInstallation(ctx, GetDeviceInstallationRequest{TenantID: "t1", DeviceID: "radar-1"})
+	if err != nil {
+		t.Fatalf("GetDeviceInstallation failed: %v", err)
+	}
+	if got.Installation == nil || *got.Installation != installation {
+		t.Fatalf("unexpected installation %+v", got.Installation)
+	}
+	var stored map[string]any
+	if err := json.Unmarshal(versions.versions[0].ConfigData, &stored); err != nil || stored["tilt_deg"] != 30.0 {
+		t.Fatalf("unexpected config_data %s", versions.versions[0].ConfigData)
+	}
+
+	if err := svc.DeleteDeviceInstallation(ctx, DeleteDeviceInstallationRequest{TenantID: "t1", DeviceID: "radar-1"}); err != nil {
+		t.Fatalf("DeleteDeviceInstallation failed: %v", err)
+	}
+	got, err = svc.GetDeviceInstallation(ctx, GetDeviceInstallationRequest{TenantID: "t1", DeviceID: "radar-1"})
+	if err != nil || got.Installation != nil {
+		t.Fatalf("installation should be cleared, got %+v, %v", got, err)
+	}
+}
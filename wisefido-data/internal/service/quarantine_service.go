@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"wisefido-data/internal/domain"
+	"wisefido-data/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+)
+
+// QuarantineService 采集隔离管理服务接口
+// 采集服务按准入策略将待审批、未开启监测等设备的遥测数据写入隔离流，
+// 管理端查看被隔离的设备，审批通过后将隔离数据重新发布到原目标流
+type QuarantineService interface {
+	ListQuarantinedDevices(ctx context.Context, req ListQuarantinedDevicesRequest) (*ListQuarantinedDevicesResponse, error)
+	ReleaseDevice(ctx context.Context, req ReleaseQuarantinedDeviceRequest) (*ReleaseQuarantinedDeviceResponse, error)
+	DiscardDevice(ctx context.Context, req DiscardQuarantinedDeviceRequest) (*DiscardQuarantinedDeviceResponse, error)
+}
+
+// quarantineService 实现
+type quarantineService struct {
+	redisClient *redis.Client
+	stream      string
+	devicesRepo repository.DevicesRepository
+	logger      *zap.Logger
+}
+
+// NewQuarantineService 创建 QuarantineService 实例
+func NewQuarantineService(redisClient *redis.Client, stream string, devicesRepo repository.DevicesRepository, logger *zap.Logger) QuarantineService {
+	return &quarantineService{
+		redisClient: redisClient,
+		stream:      stream,
+		devicesRepo: devicesRepo,
+		logger:      logger,
+	}
+}
+
+// QuarantinedDevice 被隔离的设备（按设备聚合隔离流中的消息）
+type QuarantinedDevice struct {
+	DeviceID     string
+	SerialNumber string
+	UID          string
+	DeviceType   string
+	Reasons      map[string]int // 原因代码 -> 消息数
+	Count        int            // 隔离消息总数
+	FirstSeen    time.Time      // 最早一条隔离消息的事件时间
+	LastSeen     time.Time      // 最近一条隔离消息的事件时间
+}
+
+// ListQuarantinedDevicesRequest 查询被隔离设备请求
+type ListQuarantinedDevicesRequest struct {
+	TenantID string // 必填
+}
+
+// ListQuarantinedDevicesResponse 查询被隔离设备响应
+type ListQuarantinedDevicesResponse struct {
+	Items []*QuarantinedDevice // 按最近隔离时间倒序
+}
+
+// ReleaseQuarantinedDeviceRequest 释放被隔离设备请求
+type ReleaseQuarantinedDeviceRequest struct {
+	TenantID         string // 必填
+	DeviceID         string // 必填
+	EnableMonitoring bool   // 同时开启监测；为 false 时保留设备当前的 monitoring_enabled
+}
+
+// ReleaseQuarantinedDeviceResponse 释放被隔离设备响应
+type ReleaseQuarantinedDeviceResponse struct {
+	Replayed int // 重新发布到原目标流的消息数
+}
+
+// DiscardQuarantinedDeviceRequest 丢弃被隔离数据请求
+type DiscardQuarantinedDeviceRequest struct {
+	TenantID string // 必填
+	DeviceID string // 必填
+}
+
+// DiscardQuarantinedDeviceResponse 丢弃被隔离数据响应
+type DiscardQuarantinedDeviceResponse struct {
+	Discarded int // 删除的隔离消息数
+}
+
+// quarantinedMessage 隔离流中的一条消息
+type quarantinedMessage struct {
+	id       string
+	envelope *rediscommon.Envelope[events.QuarantinedTelemetry]
+}
+
+// ListQuarantinedDevices 查询被隔离的设备
+func (s *quarantineService) ListQuarantinedDevices(ctx context.Context, req ListQuarantinedDevicesRequest) (*ListQuarantinedDevicesResponse, error) {
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+
+	msgs, err := s.readTenant(ctx, req.TenantID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	byDevice := make(map[string]*QuarantinedDevice)
+	for _, m := range msgs {
+		data := m.envelope.Payload.Data
+		item, ok := byDevice[data.DeviceID]
+		if !ok {
+			item = &QuarantinedDevice{
+				DeviceID:     data.DeviceID,
+				SerialNumber: data.SerialNumber,
+				UID:          data.UID,
+				DeviceType:   data.DeviceType,
+				Reasons:      make(map[string]int),
+				FirstSeen:    m.envelope.EventTime,
+			}
+			byDevice[data.DeviceID] = item
+		}
+		item.Count++
+		item.Reasons[m.envelope.Payload.Reason]++
+		if m.envelope.EventTime.Before(item.FirstSeen) {
+			item.FirstSeen = m.envelope.EventTime
+		}
+		if m.envelope.EventTime.After(item.LastSeen) {
+			item.LastSeen = m.envelope.EventTime
+		}
+	}
+
+	items := make([]*QuarantinedDevice, 0, len(byDevice))
+	for _, item := range byDevice {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].LastSeen.After(items[j].LastSeen) })
+
+	return &ListQuarantinedDevicesResponse{Items: items}, nil
+}
+
+// ReleaseDevice 释放被隔离设备
+// 1. 审批设备（business_access='approved'，仅在 EnableMonitoring 时开启监测），采集服务收到设备变更通知后放行后续数据
+// 2. 隔离数据按原信封元数据重新发布到原目标流，并从隔离流删除
+func (s *quarantineService) ReleaseDevice(ctx context.Context, req ReleaseQuarantinedDeviceRequest) (*ReleaseQuarantinedDeviceResponse, error) {
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+	if req.DeviceID == "" {
+		return nil, fmt.Errorf("device_id is required")
+	}
+
+	device, err := s.devicesRepo.GetDevice(ctx, req.TenantID, req.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+
+	// UpdateDevice 总是写入 monitoring_enabled，需带上当前值
+	if err := s.devicesRepo.UpdateDevice(ctx, req.TenantID, req.DeviceID, &domain.Device{
+		BusinessAccess:    "approved",
+		MonitoringEnabled: device.MonitoringEnabled || req.EnableMonitoring,
+	}); err != nil {
+		s.logger.Error("ReleaseDevice: failed to approve device",
+			zap.String("tenant_id", req.TenantID),
+			zap.String("device_id", req.DeviceID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to approve device")
+	}
+
+	msgs, err := s.readTenant(ctx, req.TenantID, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed := 0
+	for _, m := range msgs {
+		q := m.envelope
+		env := rediscommon.Envelope[events.DeviceData]{
+			Schema:     events.DeviceDataSchema,
+			Producer:   q.Producer,
+			TraceID:    q.TraceID,
+			TenantID:   q.TenantID,
+			EventTime:  q.EventTime,
+			IngestTime: q.IngestTime,
+			Payload:    q.Payload.Data,
+		}
+		if _, err := rediscommon.PublishTo(ctx, s.redisClient, q.Payload.SourceStream, env); err != nil {
+			return nil, fmt.Errorf("failed to replay quarantined telemetry: %w", err)
+		}
+		if err := s.redisClient.XDel(ctx, s.stream, m.id).Err(); err != nil {
+			return nil, fmt.Errorf("failed to remove quarantined telemetry: %w", err)
+		}
+		replayed++
+	}
+
+	s.logger.Info("Quarantined device released",
+		zap.String("tenant_id", req.TenantID),
+		zap.String("device_id", req.DeviceID),
+		zap.Int("replayed", replayed),
+	)
+	return &ReleaseQuarantinedDeviceResponse{Replayed: replayed}, nil
+}
+
+// DiscardDevice 丢弃设备的隔离数据（不改变设备状态）
+func (s *quarantineService) DiscardDevice(ctx context.Context, req DiscardQuarantinedDeviceRequest) (*DiscardQuarantinedDeviceResponse, error) {
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+	if req.DeviceID == "" {
+		return nil, fmt.Errorf("device_id is required")
+	}
+
+	msgs, err := s.readTenant(ctx, req.TenantID, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return &DiscardQuarantinedDeviceResponse{}, nil
+	}
+
+	ids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.id)
+	}
+	discarded, err := s.redisClient.XDel(ctx, s.stream, ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove quarantined telemetry: %w", err)
+	}
+	return &DiscardQuarantinedDeviceResponse{Discarded: int(discarded)}, nil
+}
+
+// readTenant 读取租户（可选：指定设备）的隔离消息，按流顺序返回
+// 隔离流由采集服务按 MAXLEN 裁剪，整体读取的数据量有上限
+func (s *quarantineService) readTenant(ctx context.Context, tenantID, deviceID string) ([]quarantinedMessage, error) {
+	entries, err := s.redisClient.XRange(ctx, s.stream, "-", "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine stream: %w", err)
+	}
+
+	var out []quarantinedMessage
+	for _, entry := range entries {
+		env, err := rediscommon.Decode[events.QuarantinedTelemetry](rediscommon.StreamMessage{
+			Stream: s.stream,
+			ID:     entry.ID,
+			Values: entry.Values,
+		}, events.QuarantineSchema)
+		if err != nil {
+			s.logger.Warn("Skipping invalid quarantine message",
+				zap.String("stream_id", entry.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		if env.TenantID != tenantID {
+			continue
+		}
+		if deviceID != "" && env.Payload.Data.DeviceID != deviceID {
+			continue
+		}
+		out = append(out, quarantinedMessage{id: entry.ID, envelope: env})
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+// +build integration
+
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"wisefido-data/internal/domain"
+	"wisefido-data/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+)
+
+// approvingDevicesRepo 记录 UpdateDevice 调用的设备仓库
+type approvingDevicesRepo struct {
+	repository.DevicesRepository
+	monitoring bool // GetDevice 返回的当前 monitoring_enabled
+	updated    []*domain.Device
+}
+
+func (r *approvingDevicesRepo) GetDevice(_ context.Context, tenantID, deviceID string) (*domain.Device, error) {
+	return &domain.Device{TenantID: tenantID, DeviceID: deviceID, MonitoringEnabled: r.monitoring}, nil
+}
+
+func (r *approvingDevicesRepo) UpdateDevice(_ context.Context, _, _ string, device *domain.Device) error {
+	r.updated = append(r.updated, device)
+	return nil
+}
+
+// getTestRedisForQuarantine 获取测试 Redis（使用独立 DB，测试前清空）
+func getTestRedisForQuarantine(t *testing.T) *redis.Client {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	require.NoError(t, client.FlushDB(context.Background()).Err())
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func quarantineTelemetry(t *testing.T, client *redis.Client, stream, tenantID, deviceID, reason string) {
+	env := rediscommon.NewEnvelope(events.QuarantineSchema, "wisefido-radar", tenantID, time.Now(), events.QuarantinedTelemetry{
+		Reason:       reason,
+		SourceStream: "radar:data:stream",
+		Data:         events.DeviceData{DeviceID: deviceID, DeviceType: "Radar", SerialNumber: "SN-" + deviceID},
+	})
+	_, err := rediscommon.PublishTo(context.Background(), client, stream, env)
+	require.NoError(t, err)
+}
+
+func TestQuarantineService_ListAndRelease(t *testing.T) {
+	client := getTestRedisForQuarantine(t)
+	ctx := context.Background()
+	stream := "ingest:quarantine:stream"
+
+	quarantineTelemetry(t, client, stream, "tenant-1", "device-1", "business_access_pending")
+	quarantineTelemetry(t, client, stream, "tenant-1", "device-1", "monitoring_disabled")
+	quarantineTelemetry(t, client, stream, "tenant-1", "device-2", "business_access_pending")
+	quarantineTelemetry(t, client, stream, "tenant-2", "device-3", "business_access_pending")
+
+	repo := &approvingDevicesRepo{}
+	svc := NewQuarantineService(client, stream, repo, getTestLogger())
+
+	list, err := svc.ListQuarantinedDevices(ctx, ListQuarantinedDevicesRequest{TenantID: "tenant-1"})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	for _, item := range list.Items {
+		if item.DeviceID == "device-1" {
+			require.Equal(t, 2, item.Count)
+			require.Equal(t, 1, item.Reasons["monitoring_disabled"])
+		}
+	}
+
+	released, err := svc.ReleaseDevice(ctx, ReleaseQuarantinedDeviceRequest{TenantID: "tenant-1", DeviceID: "device-1"})
+	require.NoError(t, err)
+	require.Equal(t, 2, released.Replayed)
+	require.Len(t, repo.updated, 1)
+	require.Equal(t, "approved", repo.updated[0].BusinessAccess)
+	// 未请求开启监测时保留操作员关闭的监测
+	require.False(t, repo.updated[0].MonitoringEnabled)
+
+	replayed, err := client.XRange(ctx, "radar:data:stream", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, replayed, 2)
+	env, err := rediscommon.Decode[events.DeviceData](rediscommon.StreamMessage{ID: replayed[0].ID, Values: replayed[0].Values}, events.DeviceDataSchema)
+	require.NoError(t, err)
+	require.Equal(t, "device-1", env.Payload.DeviceID)
+	require.Equal(t, "tenant-1", env.TenantID)
+
+	discarded, err := svc.DiscardDevice(ctx, DiscardQuarantinedDeviceRequest{TenantID: "tenant-1", DeviceID: "device-2"})
+	require.NoError(t, err)
+	require.Equal(t, 1, discarded.Discarded)
+
+	// 其他租户的隔离数据不受影响
+	remaining, err := client.XLen(ctx, stream).Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), remaining)
+}
+
+// 显式 EnableMonitoring 时开启监测，否则保留设备当前值
+func TestQuarantineService_ReleaseMonitoring(t *testing.T) {
+	client := getTestRedisForQuarantine(t)
+	ctx := context.Background()
+	stream := "test:quarantine:stream"
+
+	tests := []struct {
+		name    string
+		current bool
+		enable  bool
+		want    bool
+	}{
+		{"keep disabled", false, false, false},
+		{"keep enabled", true, false, true},
+		{"enable", false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &approvingDevicesRepo{monitoring: tt.current}
+			svc := NewQuarantineService(client, stream, repo, getTestLogger())
+			_, err := svc.ReleaseDevice(ctx, ReleaseQuarantinedDeviceRequest{TenantID: "tenant-1", DeviceID: "device-1", EnableMonitoring: tt.enable})
+			require.NoError(t, err)
+			require.Len(t, repo.updated, 1)
+			require.Equal(t, tt.want, repo.updated[0].MonitoringEnabled)
+		})
+	}
+}
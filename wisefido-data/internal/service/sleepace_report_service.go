@@ -137,6 +137,8 @@ type DownloadReportRequest struct {
 	TenantID   string // 必填
 	DeviceID   string // 必填（设备 ID）
 	DeviceCode string // 必填（设备编码，对应 devices.serial_number 或 devices.uid）
+	UserID     string // 厂家用户 ID（analysis 通知的 userId），为空时使用 DeviceID
+	Side       string // 双侧睡眠垫的侧（"left" / "right"），只保存该侧的报告；为空表示单侧设备
 	StartTime  int64  // 开始时间（Unix 时间戳，秒）
 	EndTime    int64  // 结束时间（Unix 时间戳，秒）
 }
@@ -311,8 +313,12 @@ func (s *sleepaceReportService) DownloadReport(ctx context.Context, req Download
 		return err
 	}
 
-	// 调用 Sleepace 厂家 API 获取报告
-	reports, err := client.Get24HourDailyWithMaxReport(req.DeviceID, req.DeviceCode, req.StartTime, req.EndTime)
+	// 调用 Sleepace 厂家 API 获取报告（双侧睡眠垫的逻辑传感器不是厂家用户，按通知中的 userId 查询）
+	userID := req.DeviceID
+	if req.UserID != "" {
+		userID = req.UserID
+	}
+	reports, err := client.Get24HourDailyWithMaxReport(userID, req.DeviceCode, req.StartTime, req.EndTime)
 	if err != nil {
 		s.logger.Error("Failed to get reports from Sleepace API",
 			zap.String("tenant_id", req.TenantID),
@@ -335,6 +341,7 @@ func (s *sleepaceReportService) DownloadReport(ctx context.Context, req Download
 				StopMode    int   `json:"stopMode"`
 				TimeStep    int   `json:"timeStep"`
 				Timezone    int   `json:"timezone"`
+				LeftRight   *int  `json:"leftRight"`
 			} `json:"summary"`
 			Analysis struct {
 				SleepStateStr json.RawMessage `json:"sleepStateStr"`
@@ -349,6 +356,19 @@ func (s *sleepaceReportService) DownloadReport(ctx context.Context, req Download
 			continue // 跳过无效的报告
 		}
 
+		// 双侧睡眠垫：只保存该侧的报告，无法判断侧的报告不保存（避免同一份报告记到两侧）
+		if req.Side != "" {
+			if side, ok := reportSide(report.Summary.LeftRight); !ok || side != req.Side {
+				s.logger.Debug("Skipping report of another side",
+					zap.String("device_id", req.DeviceID),
+					zap.String("side", req.Side),
+					zap.String("report_side", side),
+					zap.Int64("start_time", report.Summary.StartTime),
+				)
+				continue
+			}
+		}
+
 		// 转换为领域模型
 		domainReport := &domain.SleepaceReport{
 			DeviceID:    req.DeviceID,
@@ -411,6 +431,20 @@ func (s *sleepaceReportService) validateDevice(ctx context.Context, tenantID, de
 	return nil
 }
 
+// reportSide 报告所属的侧（summary.leftRight：0=左侧，1=右侧），没有该字段时返回 false
+func reportSide(leftRight *int) (string, bool) {
+	if leftRight == nil {
+		return "", false
+	}
+	switch *leftRight {
+	case 0:
+		return "left", true
+	case 1:
+		return "right", true
+	}
+	return "", false
+}
+
 // dateToInt 将 time.Time 转换为 YYYYMMDD 格式的整数
 func dateToInt(t time.Time) int {
 	return t.Year()*10000 + int(t.Month())*100 + t.Day()
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// SleepaceReportTrigger 睡眠报告下载触发器
+// 消费 wisefido-sleepace 发布的报告就绪事件（厂家 analysis 通知），从厂家平台下载报告并保存
+type SleepaceReportTrigger struct {
+	reports  SleepaceReportService
+	reliable *rediscommon.ReliableConsumer
+	stream   string
+	logger   *zap.Logger
+}
+
+// NewSleepaceReportTrigger 创建睡眠报告下载触发器
+func NewSleepaceReportTrigger(redisClient *redis.Client, stream string, reports SleepaceReportService, logger *zap.Logger) *SleepaceReportTrigger {
+	return &SleepaceReportTrigger{
+		reports: reports,
+		reliable: rediscommon.NewReliableConsumer(redisClient, rediscommon.ConsumerOptions{
+			Streams:  []string{stream},
+			Group:    "data-sleepace-report",
+			Consumer: "wisefido-data",
+		}, logger),
+		stream: stream,
+		logger: logger,
+	}
+}
+
+// Run 消费报告就绪事件（阻塞直到 ctx 结束）
+func (t *SleepaceReportTrigger) Run(ctx context.Context) {
+	backoff := time.Second
+	ready := false // Redis 未就绪时消费者组创建失败，按退避重试
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		var err error
+		if !ready {
+			err = t.reliable.Setup(ctx)
+			ready = err == nil
+		}
+		if err == nil {
+			err = t.reliable.Poll(ctx, t.handle)
+		}
+		if err != nil {
+			t.logger.Error("Failed to consume sleep report events",
+				zap.String("stream", t.stream),
+				zap.Error(err),
+				zap.Duration("backoff", backoff),
+			)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// handle 处理单条报告就绪事件
+func (t *SleepaceReportTrigger) handle(ctx context.Context, msg rediscommon.StreamMessage) error {
+	env, err := rediscommon.Decode[events.SleepReportReady](msg, events.SleepReportSchema)
+	if err != nil {
+		return rediscommon.Permanent(fmt.Errorf("failed to decode sleep report event: %w", err))
+	}
+	ready := env.Payload
+
+	// 与 v1.0 一致：开始时间加 1 秒，避免重复下载上一份报告
+	if err := t.reports.DownloadReport(ctx, DownloadReportRequest{
+		TenantID:   env.TenantID,
+		DeviceID:   ready.DeviceID,
+		DeviceCode: ready.DeviceCode,
+		UserID:     ready.UserID,
+		Side:       ready.Side,
+		StartTime:  ready.StartTime + 1,
+		EndTime:    ready.EndTime,
+	}); err != nil {
+		return fmt.Errorf("failed to download sleep report for device %s: %w", ready.DeviceID, err)
+	}
+
+	t.logger.Info("Sleep report downloaded",
+		zap.String("tenant_id", env.TenantID),
+		zap.String("device_id", ready.DeviceID),
+		zap.String("side", ready.Side),
+		zap.Int64("start_time", ready.StartTime),
+		zap.Int64("end_time", ready.EndTime),
+	)
+	return nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"wisefido-data/internal/domain"
+	"wisefido-data/internal/repository"
+
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+type fakeSleepaceReportService struct {
+	SleepaceReportService
+	requests []DownloadReportRequest
+	err      error
+}
+
+func (f *fakeSleepaceReportService) DownloadReport(_ context.Context, req DownloadReportRequest) error {
+	f.requests = append(f.requests, req)
+	return f.err
+}
+
+func isPermanent(err error) bool {
+	var permanent *rediscommon.PermanentError
+	return errors.As(err, &permanent)
+}
+
+func reportReadyMessage(t *testing.T, ready events.SleepReportReady) rediscommon.StreamMessage {
+	env := rediscommon.NewEnvelope(events.SleepReportSchema, "wisefido-sleepace", "tenant-1", time.Unix(ready.EndTime, 0), ready)
+	values, err := rediscommon.Encode(env)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	return rediscommon.StreamMessage{ID: "1-0", Values: values}
+}
+
+func TestSleepaceReportTrigger_Handle(t *testing.T) {
+	reports := &fakeSleepaceReportService{}
+	trigger := &SleepaceReportTrigger{reports: reports, logger: zap.NewNop()}
+
+	msg := reportReadyMessage(t, events.SleepReportReady{
+		DeviceID:   "side-device-1",
+		DeviceCode: "SN001",
+		Side:       "right",
+		UserID:     "vendor-user-1",
+		StartTime:  1700000000,
+		EndTime:    1700030000,
+	})
+	if err := trigger.handle(context.Background(), msg); err != nil {
+		t.Fatalf("handle failed: %v", err)
+	}
+	want := DownloadReportRequest{
+		TenantID:   "tenant-1",
+		DeviceID:   "side-device-1",
+		DeviceCode: "SN001",
+		UserID:     "vendor-user-1",
+		Side:       "right",
+		StartTime:  1700000001,
+		EndTime:    1700030000,
+	}
+	if len(reports.requests) != 1 || reports.requests[0] != want {
+		t.Fatalf("unexpected download requests: %+v", reports.requests)
+	}
+
+	// 下载失败返回错误（消息留在待处理列表中重试）
+	reports.err = errors.New("vendor unavailable")
+	if err := trigger.handle(context.Background(), msg); err == nil || isPermanent(err) {
+		t.Fatalf("expected retryable error, got %v", err)
+	}
+
+	// 无法解码的消息直接进入死信
+	bad := rediscommon.StreamMessage{ID: "2-0", Values: map[string]interface{}{"schema": "device.data", "schema_version": "1"}}
+	if err := trigger.handle(context.Background(), bad); !isPermanent(err) {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+}
+
+type recordingSleepaceClient struct {
+	userIDs []string
+	reports []json.RawMessage
+}
+
+func (c *recordingSleepaceClient) Get24HourDailyWithMaxReport(userID, _ string, _, _ int64) ([]json.RawMessage, error) {
+	c.userIDs = append(c.userIDs, userID)
+	return c.reports, nil
+}
+
+type savedReportsRepo struct {
+	repository.SleepaceReportsRepository
+	saved []*domain.SleepaceReport
+}
+
+func (r *savedReportsRepo) SaveReport(_ context.Context, _ string, report *domain.SleepaceReport) error {
+	r.saved = append(r.saved, report)
+	return nil
+}
+
+func vendorReport(startTime int64, leftRight string) json.RawMessage {
+	side := ""
+	if leftRight != "" {
+		side = `,"leftRight":` + leftRight
+	}
+	return json.RawMessage(fmt.Sprintf(`{"summary":{"recordCount":10,"startTime":%d,"timeStep":60%s},"analysis":{"sleepStateStr":[1,2]}}`, startTime, side))
+}
+
+// 双侧睡眠垫的报告按厂家 userId 下载，只保存该侧的报告
+func TestDownloadReport_Side(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		side       string
+		wantUserID string
+		wantStarts []int64
+	}{
+		{"single-sided pad saves all reports", "", "", "device-1", []int64{1700000300, 1700000200, 1700000100}},
+		{"left side", "vendor-user-1", "left", "vendor-user-1", []int64{1700000100}},
+		{"right side", "vendor-user-1", "right", "vendor-user-1", []int64{1700000200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New failed: %v", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT EXISTS").WithArgs("device-1", "tenant-1").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+			client := &recordingSleepaceClient{reports: []json.RawMessage{
+				vendorReport(1700000100, "0"),
+				vendorReport(1700000200, "1"),
+				vendorReport(1700000300, ""), // 无法判断侧
+			}}
+			repo := &savedReportsRepo{}
+			svc := NewSleepaceReportService(repo, db, zap.NewNop()).(*sleepaceReportService)
+			svc.SetSleepaceClientForTest(client)
+
+			err = svc.DownloadReport(context.Background(), DownloadReportRequest{
+				TenantID:   "tenant-1",
+				DeviceID:   "device-1",
+				DeviceCode: "SN001",
+				UserID:     tt.userID,
+				Side:       tt.side,
+				StartTime:  1700000000,
+				EndTime:    1700100000,
+			})
+			if err != nil {
+				t.Fatalf("DownloadReport failed: %v", err)
+			}
+			if len(client.userIDs) != 1 || client.userIDs[0] != tt.wantUserID {
+				t.Fatalf("vendor queried with %v, want %s", client.userIDs, tt.wantUserID)
+			}
+			if len(repo.saved) != len(tt.wantStarts) {
+				t.Fatalf("saved %d reports, want %d", len(repo.saved), len(tt.wantStarts))
+			}
+			for i, report := range repo.saved {
+				if report.StartTime != tt.wantStarts[i] || report.DeviceID != "device-1" {
+					t.Errorf("report %d = %+v", i, report)
+				}
+			}
+		})
+	}
+}
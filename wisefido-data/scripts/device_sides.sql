@@ -0,0 +1,22 @@
+-- device_sides：双侧睡眠垫的左右两侧（wisefido-sleepace 首次收到该侧数据时自动创建）
+-- 每一侧是一个独立的逻辑传感器（devices 行，business_access = 'pending'），
+-- 审批后与普通设备一样通过 bound_bed_id 绑定到各自的床位 / 住户；
+-- 该侧的实时数据、睡眠阶段、睡眠报告均以逻辑传感器的 device_id 写入下游。
+--
+-- 应用：go run ./cmd/apply-migration scripts/device_sides.sql
+
+CREATE TABLE IF NOT EXISTS device_sides (
+    tenant_id      UUID        NOT NULL,
+    device_id      UUID        NOT NULL REFERENCES devices(device_id) ON DELETE CASCADE, -- 物理睡眠垫
+    side           VARCHAR(10) NOT NULL CHECK (side IN ('left', 'right')),
+    side_device_id UUID        NOT NULL REFERENCES devices(device_id) ON DELETE CASCADE, -- 该侧的逻辑传感器
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_id, side),
+    UNIQUE (side_device_id)
+);
+
+-- 睡眠垫各侧的绑定情况
+-- SELECT s.side, d.device_id, d.business_access, d.bound_bed_id
+-- FROM device_sides s
+-- JOIN devices d ON d.device_id = s.side_device_id
+-- WHERE s.device_id = $1;
@@ -0,0 +1,44 @@
+-- field_mapping：设备原始数据字段映射规范（与 snomed_mapping 一同维护）
+-- wisefido-data-transformer 按 device_type / model / firmware_version 选择规范，
+-- 固件改名字段、调整单位或有效范围时只需新增一个版本，无需重新部署。
+--
+-- 匹配规则：
+--   model IS NULL 表示适用于所有型号；firmware_version 按前缀匹配（'2.1' 匹配 '2.1.5'），NULL 表示所有固件
+--   指定型号优先，固件前缀越长越优先，同一范围内取 version 最大的启用规范
+--
+-- 应用：go run ./cmd/apply-migration scripts/field_mapping.sql
+
+CREATE TABLE IF NOT EXISTS field_mapping (
+    mapping_id       UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_type      VARCHAR(50)  NOT NULL,             -- 与 device_store.device_type 一致，如 'Radar'
+    model            VARCHAR(100),                      -- 设备型号，NULL 表示所有型号
+    firmware_version VARCHAR(50),                       -- 固件版本前缀，NULL 表示所有固件
+    version          INTEGER      NOT NULL DEFAULT 1,   -- 规范版本
+    spec             JSONB        NOT NULL,             -- {"fields": [{"target", "source", "unit", "scale", "offset", "min", "max", "invalid", "code", "display"}]}
+    is_active        BOOLEAN      NOT NULL DEFAULT TRUE,
+    description      TEXT,
+    created_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_field_mapping_scope_version
+    ON field_mapping (device_type, COALESCE(model, ''), COALESCE(firmware_version, ''), version);
+
+-- 雷达通用规范（与内置默认规范一致：坐标 dm -> cm，生命体征测量项目编码）
+INSERT INTO field_mapping (device_type, model, firmware_version, version, spec, description)
+VALUES (
+    'Radar', NULL, NULL, 1,
+    '{"fields": [
+        {"target": "tracking_id", "source": "tracking_id"},
+        {"target": "radar_pos_x", "source": "position_x", "unit": "dm"},
+        {"target": "radar_pos_y", "source": "position_y", "unit": "dm"},
+        {"target": "radar_pos_z", "source": "position_z", "unit": "dm"},
+        {"target": "posture", "source": "posture"},
+        {"target": "heart_rate", "source": "heart_rate", "code": "364075005", "display": "Heart rate"},
+        {"target": "respiratory_rate", "source": "breath_rate", "code": "86290005", "display": "Respiratory rate"},
+        {"target": "event_type", "source": "event_type"},
+        {"target": "area_id", "source": "area_id"}
+    ]}'::jsonb,
+    'Radar default mapping'
+)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,36 @@
+-- fusion_policy：传感器融合策略（wisefido-sensor-fusion 按卡片的床位 / 单元选择策略）
+-- 策略覆盖服务默认值，未配置的字段使用默认值（FUSION_WINDOW_SECONDS、FUSION_FRESHNESS_SECONDS 等）。
+--
+-- 匹配规则：
+--   bed_id 与卡片床位相同 > unit_id 与卡片单元相同（bed_id IS NULL）> 租户默认（unit_id、bed_id 均为 NULL）
+--   同一范围内取 version 最大的启用策略
+--
+-- spec 示例：
+--   {"window_seconds": 60,               -- 滑动窗口长度
+--    "freshness_seconds": 30,            -- 设备最新样本超过该时长不参与融合
+--    "min_confidence": 0.3,              -- 置信度低于该值时不输出融合值
+--    "sources": {"Sleepace": 1.0, "Radar": 0.6},  -- 数据源基础权重，0 表示不采用该数据源
+--    "heart_rate_tolerance": 8,          -- 数据源之间心率一致性容差（次/分）
+--    "respiratory_rate_tolerance": 4}    -- 数据源之间呼吸率一致性容差（次/分）
+--
+-- 应用：go run ./cmd/apply-migration scripts/fusion_policy.sql
+
+CREATE TABLE IF NOT EXISTS fusion_policy (
+    policy_id   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id   UUID        NOT NULL REFERENCES tenants(tenant_id) ON DELETE CASCADE,
+    unit_id     UUID        REFERENCES units(unit_id) ON DELETE CASCADE,   -- NULL 表示租户默认
+    bed_id      UUID        REFERENCES beds(bed_id) ON DELETE CASCADE,     -- NULL 表示整个单元
+    version     INTEGER     NOT NULL DEFAULT 1,
+    spec        JSONB       NOT NULL,
+    is_active   BOOLEAN     NOT NULL DEFAULT TRUE,
+    description TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fusion_policy_scope_version
+    ON fusion_policy (tenant_id, COALESCE(unit_id::text, ''), COALESCE(bed_id::text, ''), version);
+
+-- 示例：某张床只采用 Sleepace 生命体征
+-- INSERT INTO fusion_policy (tenant_id, unit_id, bed_id, spec, description)
+-- VALUES ('<tenant_id>', '<unit_id>', '<bed_id>', '{"sources": {"Radar": 0}}', 'Sleepace only');
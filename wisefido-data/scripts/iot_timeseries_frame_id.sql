@@ -0,0 +1,19 @@
+-- iot_timeseries.frame_id：多目标雷达帧 ID（wisefido-data-transformer 写入）
+-- 一帧携带多个跟踪目标时，每个目标写入一行，各行 frame_id 相同（来源 Redis Stream 消息 ID），
+-- 以 (device_id, frame_id) 关联同一帧；帧级字段（生命体征、事件）只写入第一个目标的行。
+-- 单目标数据 frame_id 为 NULL。
+--
+-- 应用：go run ./cmd/apply-migration scripts/iot_timeseries_frame_id.sql
+
+ALTER TABLE iot_timeseries
+    ADD COLUMN IF NOT EXISTS frame_id VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_iot_timeseries_device_frame
+    ON iot_timeseries (device_id, frame_id)
+    WHERE frame_id IS NOT NULL;
+
+-- 某设备最近一帧的所有目标
+-- SELECT tracking_id, radar_pos_x, radar_pos_y, posture_display
+-- FROM iot_timeseries
+-- WHERE device_id = $1
+--   AND frame_id = (SELECT frame_id FROM iot_timeseries WHERE device_id = $1 ORDER BY timestamp DESC LIMIT 1);
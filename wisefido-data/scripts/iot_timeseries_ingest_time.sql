@@ -0,0 +1,18 @@
+-- iot_timeseries.ingest_time：采集服务收到数据的时间（wisefido-data-transformer 写入）
+-- timestamp 为设备事件时间：设备时钟有稳定偏差时按估计的偏差校正，无法校正的未来 / 过旧数据被拒绝（进入死信流）；
+-- 迟到 / 补传数据保留设备时间，按 timestamp 排序的"最新"查询不受影响。
+-- 历史数据为 NULL。
+--
+-- 应用：go run ./cmd/apply-migration scripts/iot_timeseries_ingest_time.sql
+
+ALTER TABLE iot_timeseries
+    ADD COLUMN IF NOT EXISTS ingest_time TIMESTAMPTZ;
+
+-- 各设备的时钟偏差和迟到情况（最近一天）
+-- SELECT device_id,
+--        PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM ingest_time - timestamp)) AS median_delay_sec,
+--        MAX(EXTRACT(EPOCH FROM ingest_time - timestamp)) AS max_delay_sec
+-- FROM iot_timeseries
+-- WHERE ingest_time > NOW() - INTERVAL '1 day'
+-- GROUP BY device_id
+-- ORDER BY max_delay_sec DESC;
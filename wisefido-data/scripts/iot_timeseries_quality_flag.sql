@@ -0,0 +1,19 @@
+-- iot_timeseries.quality_flag：数据质量标记（wisefido-data-transformer 校验阶段写入）
+--   ok           正常
+--   outlier      与设备近期数据偏差过大（数值保留，融合 / 告警不采信）
+--   out_of_range 超出生理范围（数值已丢弃）
+--   low_signal   信号质量低（生命体征已丢弃）
+--   device_init  设备初始化中（生命体征已丢弃）
+-- 历史数据为 NULL，读取时按 ok 处理。
+--
+-- 应用：go run ./cmd/apply-migration scripts/iot_timeseries_quality_flag.sql
+
+ALTER TABLE iot_timeseries
+    ADD COLUMN IF NOT EXISTS quality_flag VARCHAR(20);
+
+-- 统计各设备的不可信样本比例
+-- SELECT device_id, quality_flag, COUNT(*)
+-- FROM iot_timeseries
+-- WHERE timestamp > NOW() - INTERVAL '1 day'
+-- GROUP BY device_id, quality_flag
+-- ORDER BY device_id, quality_flag;
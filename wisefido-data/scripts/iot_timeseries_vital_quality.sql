@@ -0,0 +1,9 @@
+-- iot_timeseries.hr_quality / rr_quality：各生命体征的质量标记（取值同 quality_flag）
+-- 一项生命体征离群 / 超范围时只标记该项，另一项仍可被融合 / 告警采信；
+-- quality_flag 保留为整行最严重的标记。历史数据为 NULL，读取时回退到 quality_flag。
+--
+-- 应用：go run ./cmd/apply-migration scripts/iot_timeseries_vital_quality.sql
+
+ALTER TABLE iot_timeseries
+    ADD COLUMN IF NOT EXISTS hr_quality VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS rr_quality VARCHAR(20);
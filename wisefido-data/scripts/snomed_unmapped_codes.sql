@@ -0,0 +1,32 @@
+-- snomed_unmapped_codes：没有 SNOMED 映射的设备原始值（wisefido-data-transformer 定期累加计数）
+-- 临床人员按出现次数补充 snomed_mapping（mapping_type: posture / event / sleep_stage / bed_status），
+-- 补充后新数据即按新映射转换。
+--
+-- 应用：go run ./cmd/apply-migration scripts/snomed_unmapped_codes.sql
+
+CREATE TABLE IF NOT EXISTS snomed_unmapped_codes (
+    mapping_type     VARCHAR(20)  NOT NULL,             -- 与 snomed_mapping.mapping_type 一致
+    source_value     VARCHAR(50)  NOT NULL,             -- 设备原始值
+    device_type      VARCHAR(50)  NOT NULL,
+    model            VARCHAR(100) NOT NULL DEFAULT '',  -- 未知时为空字符串
+    firmware_version VARCHAR(50)  NOT NULL DEFAULT '',  -- 未知时为空字符串
+    occurrences      BIGINT       NOT NULL DEFAULT 0,
+    sample_device_id UUID,                              -- 最近一次出现的设备
+    first_seen_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    last_seen_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (mapping_type, source_value, device_type, model, firmware_version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_snomed_unmapped_codes_occurrences
+    ON snomed_unmapped_codes (occurrences DESC);
+
+-- 待补充的映射（已补充通用或对应固件（前缀）映射的值不再列出）
+-- SELECT u.*
+-- FROM snomed_unmapped_codes u
+-- WHERE NOT EXISTS (
+--     SELECT 1 FROM snomed_mapping m
+--     WHERE m.mapping_type = u.mapping_type
+--       AND m.source_value = u.source_value
+--       AND (m.firmware_version IS NULL OR LEFT(u.firmware_version, LENGTH(m.firmware_version)) = m.firmware_version)
+-- )
+-- ORDER BY u.occurrences DESC;
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"owl-common/config"
 )
 
@@ -17,7 +18,23 @@ type Config struct {
 			Data    string // 数据主题，如 "radar/+/data"
 			Command string // 命令主题，如 "radar/+/command"
 			OTA     string // OTA主题，如 "radar/+/ota"
+
+			// 设备状态主题（留空表示不订阅）
+			Status    string // 状态主题，如 "radar/+/status"（上线/下线、固件版本、RSSI、运行时长）
+			LWT       string // 遗嘱主题，如 "radar/+/lwt"（设备异常断开时由 Broker 发布）
+			Heartbeat string // 心跳主题，如 "radar/+/heartbeat"
+		}
+		Stream       string              // Redis Streams 输出流，如 "radar:data:stream"
+		DedupTTL     int                 // 去重窗口（秒），0 表示关闭；多副本滚动发布时避免重复发布
+		DeviceCache struct {
+			TTL         int // 设备身份缓存时间（秒），0 表示关闭
+			NegativeTTL int // 未授权标识符缓存时间（秒），0 表示不缓存
 		}
+		StreamPolicy config.StreamConfig // 输出流保留策略与背压策略
+		IngestPolicy config.IngestPolicyConfig // 采集准入策略（按设备业务状态丢弃或隔离）
+		Ingest       config.WorkerPoolConfig // MQTT 消息处理工作池（按设备分片）
+		Presence     config.PresenceConfig   // 设备在线状态跟踪（静默超时置为 offline）
+		MetadataInterval int                 // 心跳写入 devices.metadata 的最小间隔（秒），固件版本变化时立即写入
 		OTA struct {
 			Enabled        bool
 			FirmwarePath   string // 固件文件路径
@@ -51,11 +68,41 @@ func Load() (*Config, error) {
 	cfg.MQTT.ClientID = getEnv("MQTT_CLIENT_ID", "wisefido-radar")
 	cfg.MQTT.Username = getEnv("MQTT_USERNAME", "")
 	cfg.MQTT.Password = getEnv("MQTT_PASSWORD", "")
+	cfg.MQTT.LoadFromEnv("MQTT") // 持久会话、TLS/mTLS 等配置（MQTT_CLEAN_SESSION、MQTT_TLS_*）
 	
 	// 雷达服务配置
 	cfg.Radar.Topics.Data = getEnv("RADAR_TOPIC_DATA", "radar/+/data")
 	cfg.Radar.Topics.Command = getEnv("RADAR_TOPIC_COMMAND", "radar/+/command")
 	cfg.Radar.Topics.OTA = getEnv("RADAR_TOPIC_OTA", "radar/+/ota")
+	// 状态主题显式设置为空字符串时不订阅
+	cfg.Radar.Topics.Status = getEnvAllowEmpty("RADAR_TOPIC_STATUS", "radar/+/status")
+	cfg.Radar.Topics.LWT = getEnvAllowEmpty("RADAR_TOPIC_LWT", "radar/+/lwt")
+	cfg.Radar.Topics.Heartbeat = getEnvAllowEmpty("RADAR_TOPIC_HEARTBEAT", "radar/+/heartbeat")
+	cfg.Radar.Stream = getEnv("RADAR_STREAM", "radar:data:stream")
+	cfg.Radar.DedupTTL = 300
+	if v, err := strconv.Atoi(getEnv("RADAR_DEDUP_TTL", "300")); err == nil && v >= 0 {
+		cfg.Radar.DedupTTL = v
+	}
+	cfg.Radar.DeviceCache.TTL = 300
+	if v, err := strconv.Atoi(getEnv("RADAR_DEVICE_CACHE_TTL", "300")); err == nil && v >= 0 {
+		cfg.Radar.DeviceCache.TTL = v
+	}
+	cfg.Radar.DeviceCache.NegativeTTL = 60
+	if v, err := strconv.Atoi(getEnv("RADAR_DEVICE_CACHE_NEGATIVE_TTL", "60")); err == nil && v >= 0 {
+		cfg.Radar.DeviceCache.NegativeTTL = v
+	}
+	cfg.Radar.StreamPolicy = config.DefaultStreamConfig()
+	cfg.Radar.StreamPolicy.LoadFromEnv("STREAM_RADAR")
+	cfg.Radar.IngestPolicy = config.DefaultIngestPolicyConfig()
+	cfg.Radar.IngestPolicy.LoadFromEnv("INGEST_POLICY") // INGEST_POLICY_ENABLED、INGEST_POLICY_QUARANTINE 等
+	cfg.Radar.Ingest = config.DefaultWorkerPoolConfig()
+	cfg.Radar.Ingest.LoadFromEnv("RADAR_INGEST") // RADAR_INGEST_WORKERS、RADAR_INGEST_QUEUE_SIZE、RADAR_INGEST_OVERFLOW
+	cfg.Radar.Presence = config.DefaultPresenceConfig()
+	cfg.Radar.Presence.LoadFromEnv("RADAR_PRESENCE") // RADAR_PRESENCE_TIMEOUT、RADAR_PRESENCE_SWEEP_INTERVAL 等
+	cfg.Radar.MetadataInterval = 300
+	if v, err := strconv.Atoi(getEnv("RADAR_METADATA_INTERVAL", "300")); err == nil && v >= 0 {
+		cfg.Radar.MetadataInterval = v
+	}
 	
 	cfg.Log.Level = getEnv("LOG_LEVEL", "info")
 	cfg.Log.Format = getEnv("LOG_FORMAT", "json")
@@ -70,3 +117,11 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+
+// getEnvAllowEmpty 与 getEnv 相同，但显式设置的空值生效（用于关闭可选功能）
+func getEnvAllowEmpty(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
@@ -2,8 +2,11 @@ package consumer
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 	"wisefido-radar/internal/config"
@@ -11,8 +14,13 @@ import (
 	
 	"go.uber.org/zap"
 	"github.com/go-redis/redis/v8"
+	"owl-common/cache"
+	"owl-common/events"
+	"owl-common/ingest"
+	"owl-common/presence"
 	rediscommon "owl-common/redis"
 	mqttcommon "owl-common/mqtt"
+	"owl-common/workerpool"
 )
 
 // MQTTConsumer MQTT消息消费者
@@ -20,7 +28,13 @@ type MQTTConsumer struct {
 	config     *config.Config
 	mqttClient *mqttcommon.Client
 	redisClient *redis.Client
+	publisher  *rediscommon.StreamPublisher
+	dedup      *rediscommon.Deduplicator // nil 表示不去重
+	gate       *ingest.Gate              // 采集准入策略
+	presence   *presence.Tracker         // 设备在线状态跟踪
+	metadataSeen *cache.TTL[string]      // 最近写入 metadata 的固件版本（设备 ID -> 版本），控制心跳写入频率
 	deviceRepo *repository.DeviceRepository
+	pool       *workerpool.Pool // paho 回调只投递，查询和发布在工作池中执行
 	logger     *zap.Logger
 }
 
@@ -30,26 +44,58 @@ func NewMQTTConsumer(
 	mqttClient *mqttcommon.Client,
 	redisClient *redis.Client,
 	deviceRepo *repository.DeviceRepository,
+	tracker *presence.Tracker,
 	logger *zap.Logger,
 ) *MQTTConsumer {
+	var dedup *rediscommon.Deduplicator
+	if cfg.Radar.DedupTTL > 0 {
+		dedup = rediscommon.NewDeduplicator(redisClient, "dedup:radar", time.Duration(cfg.Radar.DedupTTL)*time.Second)
+	}
 	return &MQTTConsumer{
 		config:     cfg,
 		mqttClient: mqttClient,
 		redisClient: redisClient,
+		publisher:  rediscommon.NewStreamPublisher(redisClient, cfg.Radar.Stream, cfg.Radar.StreamPolicy, logger),
+		dedup:      dedup,
+		gate:       ingest.NewGate(redisClient, cfg.Radar.IngestPolicy, logger),
+		presence:   tracker,
+		metadataSeen: cache.NewTTL[string](time.Duration(cfg.Radar.MetadataInterval)*time.Second, 0),
 		deviceRepo: deviceRepo,
+		pool:       workerpool.New("radar-ingest", cfg.Radar.Ingest, logger),
 		logger:     logger,
 	}
 }
 
+// dataTopic 数据订阅主题（配置 MQTT_SHARED_GROUP 时使用共享订阅，多副本分摊消息）
+func (c *MQTTConsumer) dataTopic() string {
+	return mqttcommon.SharedTopic(c.config.MQTT.SharedGroup, c.config.Radar.Topics.Data)
+}
+
 // Start 启动消费者
 func (c *MQTTConsumer) Start(ctx context.Context) error {
+	c.pool.Start(ctx)
+	go c.publisher.Report(ctx)
+	
 	// 订阅雷达数据主题
-	if err := c.mqttClient.Subscribe(c.config.Radar.Topics.Data, 1, c.handleMessage); err != nil {
+	topic := c.dataTopic()
+	if err := c.mqttClient.SubscribeWithAck(topic, 1, c.handleMessage); err != nil {
 		return fmt.Errorf("failed to subscribe to data topic: %w", err)
 	}
 	
+	// 订阅设备状态、遗嘱和心跳主题（在线状态以设备自报为准）
+	for statusTopic, kind := range c.statusTopics() {
+		if err := c.mqttClient.SubscribeWithAck(statusTopic, 1, c.statusHandler(kind)); err != nil {
+			return fmt.Errorf("failed to subscribe to %s topic: %w", kind, err)
+		}
+		c.logger.Info("Subscribed to radar status topic",
+			zap.String("topic", statusTopic),
+			zap.String("kind", kind),
+		)
+	}
+	
 	c.logger.Info("MQTT consumer started",
-		zap.String("topic", c.config.Radar.Topics.Data),
+		zap.String("topic", topic),
+		zap.String("shared_group", c.config.MQTT.SharedGroup),
 	)
 	
 	// 等待上下文取消
@@ -60,16 +106,24 @@ func (c *MQTTConsumer) Start(ctx context.Context) error {
 // Stop 停止消费者
 func (c *MQTTConsumer) Stop(ctx context.Context) error {
 	// 取消订阅
-	if err := c.mqttClient.Unsubscribe(c.config.Radar.Topics.Data); err != nil {
+	topics := []string{c.dataTopic()}
+	for statusTopic := range c.statusTopics() {
+		topics = append(topics, statusTopic)
+	}
+	if err := c.mqttClient.Unsubscribe(topics...); err != nil {
 		c.logger.Error("Failed to unsubscribe", zap.Error(err))
 	}
 	
+	// 处理完已排队的消息
+	c.pool.Stop()
+	
 	c.logger.Info("MQTT consumer stopped")
 	return nil
 }
 
-// handleMessage 处理MQTT消息
-func (c *MQTTConsumer) handleMessage(topic string, payload []byte) error {
+// handleMessage MQTT 回调：按设备标识投递到工作池，同一设备的消息按序处理
+// 消息在处理完成（或因队列满被丢弃）后确认；工作池停止后未入队的消息不确认，由 broker 重新投递
+func (c *MQTTConsumer) handleMessage(topic string, payload []byte, ack func()) error {
 	c.logger.Debug("Received MQTT message",
 		zap.String("topic", topic),
 		zap.Int("payload_size", len(payload)),
@@ -83,6 +137,21 @@ func (c *MQTTConsumer) handleMessage(topic string, payload []byte) error {
 	}
 	deviceIdentifier := parts[1] // 可能是 serial_number 或 uid
 	
+	// 队列满时按溢出策略丢弃，丢弃计数由工作池统计并定期输出
+	c.pool.SubmitWithDrop(deviceIdentifier, func() {
+		defer ack()
+		if err := c.processMessage(topic, deviceIdentifier, payload); err != nil {
+			c.logger.Error("Error processing radar message",
+				zap.String("topic", topic),
+				zap.Error(err),
+			)
+		}
+	}, ack)
+	return nil
+}
+
+// processMessage 处理单条雷达消息（在工作池中执行）
+func (c *MQTTConsumer) processMessage(topic, deviceIdentifier string, payload []byte) error {
 	// 2. 解析消息
 	var mqttData map[string]interface{}
 	if err := json.Unmarshal(payload, &mqttData); err != nil {
@@ -93,46 +162,72 @@ func (c *MQTTConsumer) handleMessage(topic string, payload []byte) error {
 		return fmt.Errorf("failed to unmarshal message: %w", err)
 	}
 	
-	// 3. 查询设备信息（如果不存在，尝试从 device_store 自动创建）
-	device, err := c.deviceRepo.GetDeviceBySerialNumber(deviceIdentifier)
-	if err != nil {
-		// 尝试使用 UID 查询
-		device, err = c.deviceRepo.GetDeviceByUID(deviceIdentifier)
+	// 去重：滚动发布期间新旧副本可能同时收到同一条消息
+	// 没有设备时间戳时无法区分重复投递和内容相同的新帧（如稳定的生命体征），不去重
+	if key, ok := dedupKey(mqttData, payload); c.dedup != nil && ok {
+		first, err := c.dedup.FirstSeen(context.Background(), deviceIdentifier, key)
 		if err != nil {
-			// 设备不存在，尝试从 device_store 自动创建
-			device, err = c.deviceRepo.GetOrCreateDeviceFromStore(context.Background(), deviceIdentifier, topic)
-			if err != nil {
-				c.logger.Warn("Device not found and cannot be created from device_store",
-					zap.String("identifier", deviceIdentifier),
-					zap.String("mqtt_topic", topic),
-					zap.Error(err),
-				)
-				return fmt.Errorf("device not found: %s", deviceIdentifier)
-			}
-			// 设备已从 device_store 自动创建
-			c.logger.Info("Device auto-created from device_store on MQTT connection",
-				zap.String("device_id", device.DeviceID),
+			c.logger.Warn("Failed to check duplicate message", zap.Error(err))
+		}
+		if !first {
+			c.logger.Debug("Skipping duplicate radar message",
 				zap.String("identifier", deviceIdentifier),
-				zap.String("mqtt_topic", topic),
+				zap.String("topic", topic),
 			)
+			return nil
 		}
 	}
 	
-	// 4. 构建标准化数据
-	standardizedData := map[string]interface{}{
-		"device_id":      device.DeviceID,
-		"tenant_id":      device.TenantID,
-		"serial_number":  device.SerialNumber,
-		"uid":            device.UID,
-		"device_type":    "Radar",
-		"raw_data":       mqttData,
-		"timestamp":      time.Now().Unix(),
-		"topic":          topic,
+	// 3. 解析设备（带身份缓存；不存在时尝试从 device_store 自动创建）
+	device, err := c.deviceRepo.ResolveDevice(context.Background(), deviceIdentifier, topic)
+	if err != nil {
+		c.logger.Warn("Device not found and cannot be created from device_store",
+			zap.String("identifier", deviceIdentifier),
+			zap.String("mqtt_topic", topic),
+			zap.Error(err),
+		)
+		return fmt.Errorf("device not found: %s", deviceIdentifier)
 	}
 	
-	// 5. 发布到 Redis Streams
-	streamName := "radar:data:stream"
-	streamID, err := rediscommon.PublishJSONToStream(context.Background(), c.redisClient, streamName, standardizedData)
+	// 记录最近消息时间（离线设备重新上报时置为 online），不受准入策略影响
+	if err := c.presence.Seen(context.Background(), device.DeviceID); err != nil {
+		c.logger.Warn("Failed to record device presence",
+			zap.String("device_id", device.DeviceID),
+			zap.Error(err),
+		)
+	}
+	
+	// 4. 构建标准化数据（device.data 信封）
+	// 事件时间使用设备上报的时间戳（没有时使用接收时间），接收时间记录在信封 ingest_time 中；
+	// 设备时钟偏差由数据转换服务按设备估计和校正
+	envelope := rediscommon.NewEnvelope(events.DeviceDataSchema, "wisefido-radar", device.TenantID, deviceEventTime(mqttData), events.DeviceData{
+		DeviceID:     device.DeviceID,
+		SerialNumber: device.SerialNumber,
+		UID:          device.UID,
+		DeviceType:   "Radar",
+		RawData:      mqttData,
+		Topic:        topic,
+		Model:           device.Model,
+		FirmwareVersion: device.FirmwareVersion,
+	})
+	
+	// 5. 准入检查：待审批、已拒绝、已禁用、未开启监测的设备数据被丢弃或隔离
+	streamName := c.publisher.Stream()
+	admitted, err := c.gate.Admit(context.Background(), envelope, streamName, deviceState(device))
+	if err != nil {
+		return fmt.Errorf("failed to apply ingest policy: %w", err)
+	}
+	if !admitted {
+		return nil
+	}
+	
+	// 6. 发布到 Redis Streams
+	// 携带事件的消息始终发布；周期性遥测在下游积压时可被抽样
+	priority := rediscommon.PriorityLow
+	if _, ok := mqttData["event_type"]; ok {
+		priority = rediscommon.PriorityNormal
+	}
+	streamID, err := rediscommon.Publish(context.Background(), c.publisher, envelope, priority)
 	if err != nil {
 		c.logger.Error("Failed to publish to Redis Streams",
 			zap.String("stream", streamName),
@@ -141,12 +236,63 @@ func (c *MQTTConsumer) handleMessage(topic string, payload []byte) error {
 		return fmt.Errorf("failed to publish to stream: %w", err)
 	}
 	
+	if streamID == "" {
+		c.logger.Debug("Radar telemetry sampled out due to stream lag",
+			zap.String("device_id", device.DeviceID),
+			zap.String("stream", streamName),
+		)
+		return nil
+	}
+	
 	c.logger.Info("Published radar data to Redis Streams",
 		zap.String("device_id", device.DeviceID),
 		zap.String("stream", streamName),
 		zap.String("stream_id", streamID),
+		zap.String("trace_id", envelope.TraceID),
 	)
 	
 	return nil
 }
 
+// deviceState 准入检查所需的设备状态
+func deviceState(device *repository.Device) ingest.DeviceState {
+	return ingest.DeviceState{
+		Status:            device.Status,
+		BusinessAccess:    device.BusinessAccess,
+		MonitoringEnabled: device.MonitoringEnabled,
+		AllowAccess:       device.AllowAccess,
+	}
+}
+
+// deviceEventTime 设备上报的事件时间（timestamp / ts 字段），没有时使用接收时间
+func deviceEventTime(mqttData map[string]interface{}) time.Time {
+	for _, field := range []string{"timestamp", "ts"} {
+		if t, ok := ingest.ParseDeviceTime(mqttData[field]); ok {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// dedupKey 去重键（设备维度由 Deduplicator 的 key 区分）：设备上报的时间戳 + 消息内容摘要
+// 同一时间戳可能有多条内容不同的消息（如同一秒内的多帧），只有内容也相同时才视为重复；
+// 没有时间戳时返回 false，不去重
+func dedupKey(mqttData map[string]interface{}, payload []byte) (string, bool) {
+	var ts string
+	for _, field := range []string{"timestamp", "ts"} {
+		switch v := mqttData[field].(type) {
+		case float64:
+			ts = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			ts = v
+		}
+		if ts != "" {
+			break
+		}
+	}
+	if ts == "" {
+		return "", false
+	}
+	sum := sha1.Sum(payload)
+	return ts + ":" + hex.EncodeToString(sum[:8]), true
+}
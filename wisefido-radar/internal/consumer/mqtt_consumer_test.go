@@ -0,0 +1,57 @@
+package consumer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDedupKey(t *testing.T) {
+	key := func(payload string) (string, bool) {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(payload), &data); err != nil {
+			t.Fatal(err)
+		}
+		return dedupKey(data, []byte(payload))
+	}
+
+	tests := []struct {
+		name      string
+		a, b      string
+		wantEqual bool
+	}{
+		{"same message", `{"timestamp":1700000000,"x":1}`, `{"timestamp":1700000000,"x":1}`, true},
+		// 同一秒内的多帧内容不同，不能视为重复
+		{"same timestamp different frame", `{"timestamp":1700000000,"x":1}`, `{"timestamp":1700000000,"x":2}`, false},
+		{"different timestamp same content", `{"ts":"1700000000","x":1}`, `{"ts":"1700000001","x":1}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, okA := key(tt.a)
+			b, okB := key(tt.b)
+			if !okA || !okB {
+				t.Fatalf("messages with a device timestamp must be deduplicated")
+			}
+			if got := a == b; got != tt.wantEqual {
+				t.Fatalf("dedupKey(%s) = %s, dedupKey(%s) = %s, equal = %v", tt.a, a, tt.b, b, got)
+			}
+		})
+	}
+
+	if k, _ := key(`{"timestamp":1700000000.5}`); !strings.HasPrefix(k, "1700000000.5:") {
+		t.Fatalf("key should start with the device timestamp, got %s", k)
+	}
+}
+
+// 没有设备时间戳时不去重：内容相同的帧（如稳定的生命体征）不能被当作重复丢弃
+func TestDedupKey_NoTimestamp(t *testing.T) {
+	for _, payload := range []string{`{"heart_rate":70}`, `{"timestamp":"","heart_rate":70}`, `{"ts":null,"heart_rate":70}`} {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(payload), &data); err != nil {
+			t.Fatal(err)
+		}
+		if k, ok := dedupKey(data, []byte(payload)); ok {
+			t.Fatalf("dedupKey(%s) = %s, want no key", payload, k)
+		}
+	}
+}
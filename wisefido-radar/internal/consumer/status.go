@@ -0,0 +1,230 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	mqttcommon "owl-common/mqtt"
+	"owl-common/presence"
+)
+
+// 设备状态主题类型
+const (
+	statusKindStatus    = "status"
+	statusKindLWT       = "lwt"
+	statusKindHeartbeat = "heartbeat"
+)
+
+// statusReport 设备状态消息解析结果
+type statusReport struct {
+	Online bool
+	Info   presence.Info
+}
+
+// hasInfo 是否携带设备运行信息
+func (r *statusReport) hasInfo() bool {
+	return r.Info.FirmwareVersion != "" || r.Info.RSSI != nil || r.Info.UptimeSec != nil
+}
+
+// statusTopics 已配置的状态主题（主题 -> 类型），留空的主题不订阅
+func (c *MQTTConsumer) statusTopics() map[string]string {
+	topics := make(map[string]string, 3)
+	for kind, topic := range map[string]string{
+		statusKindStatus:    c.config.Radar.Topics.Status,
+		statusKindLWT:       c.config.Radar.Topics.LWT,
+		statusKindHeartbeat: c.config.Radar.Topics.Heartbeat,
+	} {
+		if topic != "" {
+			topics[mqttcommon.SharedTopic(c.config.MQTT.SharedGroup, topic)] = kind
+		}
+	}
+	return topics
+}
+
+// statusHandler 状态主题 MQTT 回调：与数据消息共用工作池分片，同一设备的消息按序处理
+// 确认方式与数据消息相同（见 handleMessage）
+func (c *MQTTConsumer) statusHandler(kind string) mqttcommon.AckHandler {
+	return func(topic string, payload []byte, ack func()) error {
+		// 主题格式: radar/{device_id}/status
+		parts := strings.Split(topic, "/")
+		if len(parts) < 3 {
+			return fmt.Errorf("invalid topic format: %s", topic)
+		}
+		deviceIdentifier := parts[1]
+
+		c.pool.SubmitWithDrop(deviceIdentifier, func() {
+			defer ack()
+			if err := c.processStatus(kind, topic, deviceIdentifier, payload); err != nil {
+				c.logger.Error("Error processing radar status message",
+					zap.String("topic", topic),
+					zap.Error(err),
+				)
+			}
+		}, ack)
+		return nil
+	}
+}
+
+// processStatus 处理设备状态 / 遗嘱 / 心跳消息（在工作池中执行）
+// 在线状态以设备自报为准，运行信息写入 devices.metadata 并发布到 device:presence:stream
+func (c *MQTTConsumer) processStatus(kind, topic, deviceIdentifier string, payload []byte) error {
+	ctx := context.Background()
+	report := parseStatus(kind, payload)
+
+	device, err := c.deviceRepo.ResolveDevice(ctx, deviceIdentifier, topic)
+	if err != nil {
+		c.logger.Warn("Device not found for status message",
+			zap.String("identifier", deviceIdentifier),
+			zap.String("mqtt_topic", topic),
+			zap.Error(err),
+		)
+		return fmt.Errorf("device not found: %s", deviceIdentifier)
+	}
+
+	reason := presence.ReasonStatus
+	switch kind {
+	case statusKindLWT:
+		reason = presence.ReasonLastWill
+	case statusKindHeartbeat:
+		reason = presence.ReasonHeartbeat
+	}
+	if err := c.presence.Report(ctx, device.DeviceID, report.Online, reason, &report.Info); err != nil {
+		c.logger.Warn("Failed to report device presence",
+			zap.String("device_id", device.DeviceID),
+			zap.String("kind", kind),
+			zap.Error(err),
+		)
+	}
+
+	if !report.hasInfo() || !c.shouldWriteMetadata(kind, device.DeviceID, report.Info.FirmwareVersion) {
+		return nil
+	}
+	fields := map[string]interface{}{
+		"last_status_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	if report.Info.FirmwareVersion != "" {
+		fields["firmware_version"] = report.Info.FirmwareVersion
+	}
+	if report.Info.RSSI != nil {
+		fields["rssi"] = *report.Info.RSSI
+	}
+	if report.Info.UptimeSec != nil {
+		fields["uptime_sec"] = *report.Info.UptimeSec
+	}
+	if err := c.deviceRepo.UpdateMetadata(ctx, device.DeviceID, fields); err != nil {
+		return err
+	}
+	c.metadataSeen.Set(device.DeviceID, report.Info.FirmwareVersion)
+
+	// 在线状态未变化时 presence 事件不会携带运行信息，随 metadata 写入同步发布（固件版本变化或超过写入间隔）
+	if report.Online {
+		if err := c.presence.PublishInfo(ctx, presence.Device{
+			DeviceID:     device.DeviceID,
+			TenantID:     device.TenantID,
+			DeviceType:   "Radar",
+			SerialNumber: device.SerialNumber,
+			UID:          device.UID,
+		}, &report.Info); err != nil {
+			c.logger.Warn("Failed to publish device info",
+				zap.String("device_id", device.DeviceID),
+				zap.Error(err),
+			)
+		}
+	}
+
+	c.logger.Debug("Updated radar device metadata",
+		zap.String("device_id", device.DeviceID),
+		zap.String("kind", kind),
+		zap.String("firmware_version", report.Info.FirmwareVersion),
+	)
+	return nil
+}
+
+// shouldWriteMetadata 状态 / 遗嘱消息每次写入；心跳仅在固件版本变化或超过写入间隔时写入
+func (c *MQTTConsumer) shouldWriteMetadata(kind, deviceID, firmwareVersion string) bool {
+	if kind != statusKindHeartbeat {
+		return true
+	}
+	last, _, ok := c.metadataSeen.Get(deviceID)
+	return !ok || (firmwareVersion != "" && firmwareVersion != last)
+}
+
+// parseStatus 解析设备状态消息
+// 支持 JSON（status/state、firmware_version/fw_version、rssi、uptime 等字段）和纯文本（"online" / "offline"）。
+// 未携带状态字段时：遗嘱消息视为离线，状态和心跳消息视为在线。
+func parseStatus(kind string, payload []byte) *statusReport {
+	report := &statusReport{Online: kind != statusKindLWT}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		if online, ok := parseOnline(strings.Trim(strings.TrimSpace(string(payload)), `"`)); ok {
+			report.Online = online
+		}
+		return report
+	}
+
+	for _, field := range []string{"status", "state", "connection_status", "online"} {
+		if online, ok := parseOnline(data[field]); ok {
+			report.Online = online
+			break
+		}
+	}
+	for _, field := range []string{"firmware_version", "fw_version", "firmware", "fw"} {
+		if v, ok := data[field].(string); ok && v != "" {
+			report.Info.FirmwareVersion = v
+			break
+		}
+	}
+	for _, field := range []string{"rssi", "signal"} {
+		if v, ok := toInt64(data[field]); ok {
+			rssi := int(v)
+			report.Info.RSSI = &rssi
+			break
+		}
+	}
+	for _, field := range []string{"uptime", "uptime_sec", "uptime_s"} {
+		if v, ok := toInt64(data[field]); ok && v >= 0 {
+			report.Info.UptimeSec = &v
+			break
+		}
+	}
+	return report
+}
+
+// parseOnline 解析在线状态取值（online/offline、connected/disconnected、true/false、1/0）
+func parseOnline(v interface{}) (online bool, ok bool) {
+	switch val := v.(type) {
+	case bool:
+		return val, true
+	case float64:
+		return val != 0, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "online", "connected", "up", "true", "1":
+			return true, true
+		case "offline", "disconnected", "down", "false", "0":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// toInt64 数值字段转换（兼容字符串形式的数字）
+func toInt64(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return int64(val), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return 0, false
+		}
+		return int64(n), true
+	}
+	return 0, false
+}
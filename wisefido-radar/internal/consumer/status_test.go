@@ -0,0 +1,142 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"owl-common/cache"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		kind       string
+		payload    string
+		wantOnline bool
+		wantFW     string
+		wantRSSI   *int
+		wantUptime *int64
+	}{
+		{name: "plain text online", kind: statusKindStatus, payload: "online", wantOnline: true},
+		{name: "plain text quoted offline", kind: statusKindStatus, payload: ` "offline" `, wantOnline: false},
+		{name: "plain text unknown keeps default", kind: statusKindStatus, payload: "hello", wantOnline: true},
+		// 遗嘱消息未携带状态字段时视为离线
+		{name: "lwt without status", kind: statusKindLWT, payload: "{}", wantOnline: false},
+		{name: "lwt empty payload", kind: statusKindLWT, payload: "", wantOnline: false},
+		{name: "heartbeat without status", kind: statusKindHeartbeat, payload: `{"rssi":-70}`, wantOnline: true, wantRSSI: intPtr(-70)},
+		{
+			name:       "full status",
+			kind:       statusKindStatus,
+			payload:    `{"status":"connected","firmware_version":"2.1.0","rssi":"-61","uptime":3600.9}`,
+			wantOnline: true,
+			wantFW:     "2.1.0",
+			wantRSSI:   intPtr(-61),
+			wantUptime: int64Ptr(3600),
+		},
+		{
+			name:       "alternative field names",
+			kind:       statusKindStatus,
+			payload:    `{"state":0,"fw":"1.9","signal":-80,"uptime_sec":"12"}`,
+			wantOnline: false,
+			wantFW:     "1.9",
+			wantRSSI:   intPtr(-80),
+			wantUptime: int64Ptr(12),
+		},
+		// 第一个可识别的状态字段生效
+		{name: "first recognised status field", kind: statusKindStatus, payload: `{"status":"busy","online":false}`, wantOnline: false},
+		{name: "negative uptime ignored", kind: statusKindStatus, payload: `{"uptime":-1,"firmware":""}`, wantOnline: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := parseStatus(tt.kind, []byte(tt.payload))
+			if report.Online != tt.wantOnline || report.Info.FirmwareVersion != tt.wantFW {
+				t.Fatalf("parseStatus = %+v", report)
+			}
+			if !equalPtr(report.Info.RSSI, tt.wantRSSI) || !equalPtr(report.Info.UptimeSec, tt.wantUptime) {
+				t.Fatalf("rssi = %v, uptime = %v", report.Info.RSSI, report.Info.UptimeSec)
+			}
+			if report.hasInfo() != (tt.wantFW != "" || tt.wantRSSI != nil || tt.wantUptime != nil) {
+				t.Fatalf("hasInfo = %v", report.hasInfo())
+			}
+		})
+	}
+}
+
+func TestParseOnline(t *testing.T) {
+	tests := []struct {
+		value      interface{}
+		wantOnline bool
+		wantOK     bool
+	}{
+		{true, true, true},
+		{false, false, true},
+		{float64(1), true, true},
+		{float64(0), false, true},
+		{" Online ", true, true},
+		{"UP", true, true},
+		{"disconnected", false, true},
+		{"0", false, true},
+		{"busy", false, false},
+		{nil, false, false},
+		{[]interface{}{"online"}, false, false},
+	}
+	for _, tt := range tests {
+		online, ok := parseOnline(tt.value)
+		if online != tt.wantOnline || ok != tt.wantOK {
+			t.Fatalf("parseOnline(%#v) = %v, %v; want %v, %v", tt.value, online, ok, tt.wantOnline, tt.wantOK)
+		}
+	}
+}
+
+func TestToInt64(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		want   int64
+		wantOK bool
+	}{
+		{float64(42), 42, true},
+		{float64(-61.7), -61, true},
+		{" 3600 ", 3600, true},
+		{"12.9", 12, true},
+		{"abc", 0, false},
+		{"", 0, false},
+		{true, 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := toInt64(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Fatalf("toInt64(%#v) = %d, %v; want %d, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// 心跳仅在固件版本变化或超过写入间隔时写入，状态 / 遗嘱消息每次写入
+func TestShouldWriteMetadata(t *testing.T) {
+	c := &MQTTConsumer{metadataSeen: cache.NewTTL[string](time.Minute, 0)}
+
+	if !c.shouldWriteMetadata(statusKindHeartbeat, "dev-1", "2.1.0") {
+		t.Fatal("first heartbeat should write")
+	}
+	c.metadataSeen.Set("dev-1", "2.1.0")
+	if c.shouldWriteMetadata(statusKindHeartbeat, "dev-1", "2.1.0") || c.shouldWriteMetadata(statusKindHeartbeat, "dev-1", "") {
+		t.Fatal("heartbeat within interval should not write")
+	}
+	if !c.shouldWriteMetadata(statusKindHeartbeat, "dev-1", "2.2.0") {
+		t.Fatal("firmware change should write")
+	}
+	if !c.shouldWriteMetadata(statusKindStatus, "dev-1", "2.1.0") || !c.shouldWriteMetadata(statusKindLWT, "dev-1", "") {
+		t.Fatal("status and lwt messages should always write")
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
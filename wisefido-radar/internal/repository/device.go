@@ -3,13 +3,19 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"owl-common/cache"
 )
 
+// ErrUnauthorizedDevice 设备未在 device_store 注册或未分配给租户
+var ErrUnauthorizedDevice = errors.New("unauthorized device")
+
 // DeviceRepository 设备仓库
 type DeviceRepository struct {
 	db     *sql.DB
+	cache  *cache.TTL[*Device] // 设备身份缓存（标识符 -> 设备），nil 表示不缓存
 	logger *zap.Logger
 }
 
@@ -33,8 +39,13 @@ func (r *DeviceRepository) GetDeviceBySerialNumber(serialNumber string) (*Device
 			d.status,
 			d.business_access,
 			d.bound_bed_id,
-			d.bound_room_id
+			d.bound_room_id,
+			d.monitoring_enabled,
+			COALESCE(ds.allow_access, TRUE),
+			COALESCE(ds.device_model, ''),
+			COALESCE(ds.firmware_version, '')
 		FROM devices d
+		LEFT JOIN device_store ds ON ds.device_store_id = d.device_store_id
 		WHERE d.serial_number = $1
 		LIMIT 1
 	`
@@ -50,6 +61,10 @@ func (r *DeviceRepository) GetDeviceBySerialNumber(serialNumber string) (*Device
 		&device.BusinessAccess,
 		&device.BoundBedID,
 		&device.BoundRoomID,
+		&device.MonitoringEnabled,
+		&device.AllowAccess,
+		&device.Model,
+		&device.FirmwareVersion,
 	)
 	
 	if err != nil {
@@ -74,8 +89,13 @@ func (r *DeviceRepository) GetDeviceByUID(uid string) (*Device, error) {
 			d.status,
 			d.business_access,
 			d.bound_bed_id,
-			d.bound_room_id
+			d.bound_room_id,
+			d.monitoring_enabled,
+			COALESCE(ds.allow_access, TRUE),
+			COALESCE(ds.device_model, ''),
+			COALESCE(ds.firmware_version, '')
 		FROM devices d
+		LEFT JOIN device_store ds ON ds.device_store_id = d.device_store_id
 		WHERE d.uid = $1
 		LIMIT 1
 	`
@@ -91,6 +111,10 @@ func (r *DeviceRepository) GetDeviceByUID(uid string) (*Device, error) {
 		&device.BusinessAccess,
 		&device.BoundBedID,
 		&device.BoundRoomID,
+		&device.MonitoringEnabled,
+		&device.AllowAccess,
+		&device.Model,
+		&device.FirmwareVersion,
 	)
 	
 	if err != nil {
@@ -131,8 +155,13 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 			d.status,
 			d.business_access,
 			d.bound_bed_id,
-			d.bound_room_id
+			d.bound_room_id,
+			d.monitoring_enabled,
+			COALESCE(ds.allow_access, TRUE),
+			COALESCE(ds.device_model, ''),
+			COALESCE(ds.firmware_version, '')
 		FROM devices d
+		LEFT JOIN device_store ds ON ds.device_store_id = d.device_store_id
 		WHERE (d.serial_number = $1 OR d.uid = $1)
 		LIMIT 1
 	`
@@ -148,6 +177,10 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 		&device.BusinessAccess,
 		&device.BoundBedID,
 		&device.BoundRoomID,
+		&device.MonitoringEnabled,
+		&device.AllowAccess,
+		&device.Model,
+		&device.FirmwareVersion,
 	)
 	
 	if err == nil {
@@ -196,14 +229,6 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 
 	if err == sql.ErrNoRows {
 		// Case 3: Device not registered in device_store
-		serialNum := ""
-		if dsSerialNumber.Valid {
-			serialNum = dsSerialNumber.String
-		}
-		uid := ""
-		if dsUID.Valid {
-			uid = dsUID.String
-		}
 		logWarn("Unauthorized device connection attempt",
 			zap.String("identifier", identifier),
 			zap.String("mqtt_topic", mqttTopic),
@@ -211,7 +236,7 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 			zap.String("action", "connection_rejected"),
 			zap.String("security_level", "warning"),
 		)
-		return nil, fmt.Errorf("unauthorized device: not registered in device_store")
+		return nil, fmt.Errorf("%w: not registered in device_store", ErrUnauthorizedDevice)
 	}
 
 	if err != nil {
@@ -243,7 +268,7 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 			zap.String("reason", "device_not_allocated"),
 			zap.String("action", "connection_rejected"),
 		)
-		return nil, fmt.Errorf("device not allocated to tenant")
+		return nil, fmt.Errorf("%w: device not allocated to tenant", ErrUnauthorizedDevice)
 	}
 
 	// Case 1: Device is registered and allocated, create devices record
@@ -362,12 +387,17 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 			d.status,
 			d.business_access,
 			d.bound_bed_id,
-			d.bound_room_id
+			d.bound_room_id,
+			d.monitoring_enabled,
+			COALESCE(ds.allow_access, TRUE),
+			COALESCE(ds.device_model, ''),
+			COALESCE(ds.firmware_version, '')
 		FROM devices d
+		LEFT JOIN device_store ds ON ds.device_store_id = d.device_store_id
 		WHERE d.device_id = $1
 		LIMIT 1
 	`
-	device := &Device{}
+	device = &Device{}
 	err = r.db.QueryRowContext(ctx, query, newDeviceID).Scan(
 		&device.DeviceID,
 		&device.TenantID,
@@ -378,6 +408,10 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 		&device.BusinessAccess,
 		&device.BoundBedID,
 		&device.BoundRoomID,
+		&device.MonitoringEnabled,
+		&device.AllowAccess,
+		&device.Model,
+		&device.FirmwareVersion,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query newly created device: %w", err)
@@ -396,5 +430,11 @@ type Device struct {
 	BusinessAccess string
 	BoundBedID     *string
 	BoundRoomID    *string
+
+	MonitoringEnabled bool // devices.monitoring_enabled
+	AllowAccess       bool // device_store.allow_access（无 device_store 关联时为 true）
+
+	Model           string // device_store.device_model（未登记时为空）
+	FirmwareVersion string // device_store.firmware_version（未登记时为空）
 }
 
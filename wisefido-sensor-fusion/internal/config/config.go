@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"owl-common/config"
 )
 
@@ -19,6 +20,37 @@ type Config struct {
 		ConsumerGroup string // 消费者组名称
 		ConsumerName  string // 消费者名称
 		BatchSize     int64  // 批量处理大小
+		ClaimMinIdle  int    // 待处理消息空闲多久后重新认领（秒），默认 60 秒
+		MaxDeliveries int    // 最大投递次数，超过后移入死信流（<stream>:dlq），默认 5
+		
+		// 单实例租约：设备窗口保存在内存中，只有持有租约的实例消费输入流，其他副本待命（主备）
+		LeaseKey string // 租约键，默认 "sensor-fusion:lease"
+		LeaseTTL int    // 租约有效期（秒），持有者每 1/3 有效期续约，异常退出后备用实例最迟在此时间后接管，默认 15
+		
+		// 融合窗口默认值（可按床位 / 单元在 fusion_policy 表中覆盖）
+		WindowSeconds      int     // 滑动窗口长度（秒），默认 60
+		FreshnessSeconds   int     // 新鲜度上限（秒）：设备最新样本超过该时长不参与融合，默认 30
+		WindowFrames       int     // 每个设备窗口内最多读取的帧数，默认 60
+		MinConfidence      float64 // 融合值最低置信度，低于该值不输出，默认 0.2
+		PolicyCacheTTL     int     // 融合策略缓存时间（秒），修改 fusion_policy 后最迟在此时间后生效，默认 60
+		ClockSkewTolerance int     // 事件时间超前当前时间的容差（秒），超出的历史数据不参与融合（与数据转换服务共用 CLOCK_SKEW_TOLERANCE），默认 300
+		
+		// 内存状态（设备滑动窗口由 iot:data:stream 消息直接更新，冷启动时从数据库加载）
+		StateRetention      int // 设备状态保留时长（秒），长时间无数据的设备被清理，默认 300
+		TopologyCacheTTL    int // 卡片拓扑缓存时间（秒），收到 card:changes 通知时立即失效，默认 600
+		TopologyNegativeTTL int // 未关联卡片的设备的缓存时间（秒），默认 30
+		
+		// 雷达目标轨迹跟踪（区域来自 units.layout_config，事件发布到 track:event:stream）
+		Tracking struct {
+			Output          string              // 轨迹事件输出流，默认 "track:event:stream"
+			OutputPolicy    config.StreamConfig // 输出流保留策略与背压策略（STREAM_TRACK_*）
+			LostTimeout     int                 // 目标超过该时长（秒）未出现视为离开，默认 10
+			DwellThreshold  int                 // 在区域内停留超过该时长（秒）发布 dwell 事件，默认 300
+			GaitWindow      int                 // 行走速度计算窗口（秒），默认 5
+			GaitMinDistance int                 // 窗口内最小位移（cm），低于该值不视为行走，默认 50
+			GaitInterval    int                 // 同一目标两次行走速度事件的最短间隔（秒），默认 30
+			LayoutCacheTTL  int                 // 单元布局缓存时间（秒），默认 300
+		}
 		
 		// Redis 缓存配置
 		Cache struct {
@@ -54,6 +86,88 @@ func Load() (*Config, error) {
 	cfg.Fusion.ConsumerGroup = getEnv("CONSUMER_GROUP", "sensor-fusion-group")
 	cfg.Fusion.ConsumerName = getEnv("CONSUMER_NAME", "sensor-fusion-1")
 	cfg.Fusion.BatchSize = 10
+	if v, err := strconv.Atoi(getEnv("STREAM_CLAIM_MIN_IDLE", "60")); err == nil && v > 0 {
+		cfg.Fusion.ClaimMinIdle = v
+	} else {
+		cfg.Fusion.ClaimMinIdle = 60
+	}
+	if v, err := strconv.Atoi(getEnv("STREAM_MAX_DELIVERIES", "5")); err == nil && v > 0 {
+		cfg.Fusion.MaxDeliveries = v
+	} else {
+		cfg.Fusion.MaxDeliveries = 5
+	}
+	
+	cfg.Fusion.LeaseKey = getEnv("FUSION_LEASE_KEY", "sensor-fusion:lease")
+	cfg.Fusion.LeaseTTL = 15
+	if v, err := strconv.Atoi(getEnv("FUSION_LEASE_TTL", "15")); err == nil && v > 0 {
+		cfg.Fusion.LeaseTTL = v
+	}
+	
+	cfg.Fusion.WindowSeconds = 60
+	if v, err := strconv.Atoi(getEnv("FUSION_WINDOW_SECONDS", "60")); err == nil && v > 0 {
+		cfg.Fusion.WindowSeconds = v
+	}
+	cfg.Fusion.FreshnessSeconds = 30
+	if v, err := strconv.Atoi(getEnv("FUSION_FRESHNESS_SECONDS", "30")); err == nil && v > 0 {
+		cfg.Fusion.FreshnessSeconds = v
+	}
+	cfg.Fusion.WindowFrames = 60
+	if v, err := strconv.Atoi(getEnv("FUSION_WINDOW_FRAMES", "60")); err == nil && v > 0 {
+		cfg.Fusion.WindowFrames = v
+	}
+	cfg.Fusion.MinConfidence = 0.2
+	if v, err := strconv.ParseFloat(getEnv("FUSION_MIN_CONFIDENCE", "0.2"), 64); err == nil && v >= 0 && v <= 1 {
+		cfg.Fusion.MinConfidence = v
+	}
+	cfg.Fusion.PolicyCacheTTL = 60
+	if v, err := strconv.Atoi(getEnv("FUSION_POLICY_CACHE_TTL", "60")); err == nil && v >= 0 {
+		cfg.Fusion.PolicyCacheTTL = v
+	}
+	cfg.Fusion.ClockSkewTolerance = 300
+	if v, err := strconv.Atoi(getEnv("CLOCK_SKEW_TOLERANCE", "300")); err == nil && v > 0 {
+		cfg.Fusion.ClockSkewTolerance = v
+	}
+	
+	cfg.Fusion.StateRetention = 300
+	if v, err := strconv.Atoi(getEnv("FUSION_STATE_RETENTION", "300")); err == nil && v > 0 {
+		cfg.Fusion.StateRetention = v
+	}
+	cfg.Fusion.TopologyCacheTTL = 600
+	if v, err := strconv.Atoi(getEnv("FUSION_TOPOLOGY_CACHE_TTL", "600")); err == nil && v >= 0 {
+		cfg.Fusion.TopologyCacheTTL = v
+	}
+	cfg.Fusion.TopologyNegativeTTL = 30
+	if v, err := strconv.Atoi(getEnv("FUSION_TOPOLOGY_NEGATIVE_TTL", "30")); err == nil && v >= 0 {
+		cfg.Fusion.TopologyNegativeTTL = v
+	}
+	
+	cfg.Fusion.Tracking.Output = getEnv("TRACK_EVENT_STREAM", "track:event:stream")
+	cfg.Fusion.Tracking.OutputPolicy = config.DefaultStreamConfig()
+	cfg.Fusion.Tracking.OutputPolicy.LoadFromEnv("STREAM_TRACK")
+	cfg.Fusion.Tracking.LostTimeout = 10
+	if v, err := strconv.Atoi(getEnv("TRACK_LOST_TIMEOUT", "10")); err == nil && v > 0 {
+		cfg.Fusion.Tracking.LostTimeout = v
+	}
+	cfg.Fusion.Tracking.DwellThreshold = 300
+	if v, err := strconv.Atoi(getEnv("TRACK_DWELL_THRESHOLD", "300")); err == nil && v > 0 {
+		cfg.Fusion.Tracking.DwellThreshold = v
+	}
+	cfg.Fusion.Tracking.GaitWindow = 5
+	if v, err := strconv.Atoi(getEnv("TRACK_GAIT_WINDOW", "5")); err == nil && v > 0 {
+		cfg.Fusion.Tracking.GaitWindow = v
+	}
+	cfg.Fusion.Tracking.GaitMinDistance = 50
+	if v, err := strconv.Atoi(getEnv("TRACK_GAIT_MIN_DISTANCE", "50")); err == nil && v >= 0 {
+		cfg.Fusion.Tracking.GaitMinDistance = v
+	}
+	cfg.Fusion.Tracking.GaitInterval = 30
+	if v, err := strconv.Atoi(getEnv("TRACK_GAIT_INTERVAL", "30")); err == nil && v >= 0 {
+		cfg.Fusion.Tracking.GaitInterval = v
+	}
+	cfg.Fusion.Tracking.LayoutCacheTTL = 300
+	if v, err := strconv.Atoi(getEnv("TRACK_LAYOUT_CACHE_TTL", "300")); err == nil && v >= 0 {
+		cfg.Fusion.Tracking.LayoutCacheTTL = v
+	}
 	
 	cfg.Fusion.Cache.RealtimeKeyPrefix = getEnv("CACHE_REALTIME_PREFIX", "vital-focus:card:")
 	cfg.Fusion.Cache.RealtimeTTL = 300 // 5分钟
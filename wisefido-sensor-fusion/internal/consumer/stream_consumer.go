@@ -3,16 +3,20 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 	"wisefido-sensor-fusion/internal/config"
 	"wisefido-sensor-fusion/internal/fusion"
 	"wisefido-sensor-fusion/internal/models"
 	"wisefido-sensor-fusion/internal/repository"
+	"wisefido-sensor-fusion/internal/tracking"
 	
 	"go.uber.org/zap"
 	"github.com/go-redis/redis/v8"
+	"owl-common/events"
 	rediscommon "owl-common/redis"
 )
 
@@ -107,6 +111,8 @@ type StreamConsumer struct {
 	iotRepo      *repository.IoTTimeSeriesRepository
 	fusion       *fusion.SensorFusion
 	cache        *CacheManager
+	tracks       *tracking.Manager
+	trackEvents  *tracking.Publisher
 	logger       *zap.Logger
 	metrics      *Metrics
 }
@@ -119,6 +125,8 @@ func NewStreamConsumer(
 	iotRepo *repository.IoTTimeSeriesRepository,
 	fusion *fusion.SensorFusion,
 	cache *CacheManager,
+	tracks *tracking.Manager,
+	trackEvents *tracking.Publisher,
 	logger *zap.Logger,
 ) *StreamConsumer {
 	return &StreamConsumer{
@@ -128,6 +136,8 @@ func NewStreamConsumer(
 		iotRepo:     iotRepo,
 		fusion:      fusion,
 		cache:       cache,
+		tracks:      tracks,
+		trackEvents: trackEvents,
 		logger:      logger,
 		metrics: &Metrics{
 			StartTime: time.Now(),
@@ -137,10 +147,18 @@ func NewStreamConsumer(
 
 // Start 启动消费者
 func (c *StreamConsumer) Start(ctx context.Context) error {
-	// 创建消费者组
+	// 创建可靠消费者（处理成功后 XACK，失败消息超时重新认领，多次失败进入死信流）
 	stream := c.config.Fusion.Stream.Input
-	if err := rediscommon.CreateConsumerGroup(ctx, c.redisClient, stream, c.config.Fusion.ConsumerGroup); err != nil {
-		return fmt.Errorf("failed to create consumer group for %s: %w", stream, err)
+	reliable := rediscommon.NewReliableConsumer(c.redisClient, rediscommon.ConsumerOptions{
+		Streams:       []string{stream},
+		Group:         c.config.Fusion.ConsumerGroup,
+		Consumer:      c.config.Fusion.ConsumerName,
+		BatchSize:     c.config.Fusion.BatchSize,
+		ClaimMinIdle:  time.Duration(c.config.Fusion.ClaimMinIdle) * time.Second,
+		MaxDeliveries: int64(c.config.Fusion.MaxDeliveries),
+	}, c.logger)
+	if err := reliable.Setup(ctx); err != nil {
+		return err
 	}
 	
 	c.logger.Info("Stream consumer started",
@@ -163,7 +181,7 @@ func (c *StreamConsumer) Start(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		default:
-			if err := c.consumeStream(ctx, stream); err != nil {
+			if err := reliable.Poll(ctx, c.handleMessage); err != nil {
 				c.logger.Error("Failed to consume stream", 
 					zap.Error(err),
 					zap.Duration("backoff", backoffDuration),
@@ -188,33 +206,16 @@ func (c *StreamConsumer) Start(ctx context.Context) error {
 	}
 }
 
-// consumeStream 消费单个 Stream
-func (c *StreamConsumer) consumeStream(ctx context.Context, stream string) error {
-	// 从 Stream 读取消息
-	messages, err := rediscommon.ReadFromStream(
-		ctx,
-		c.redisClient,
-		stream,
-		c.config.Fusion.ConsumerGroup,
-		c.config.Fusion.ConsumerName,
-		c.config.Fusion.BatchSize,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to read from stream: %w", err)
-	}
-	
-	// 处理消息
-	for _, msg := range messages {
-		c.metrics.IncrementProcessed()
-		if err := c.processMessage(ctx, msg); err != nil {
-			c.logger.Error("Failed to process message",
-				zap.String("stream_id", msg.ID),
-				zap.Error(err),
-			)
-			// 继续处理下一条消息，不中断
-		}
+// handleMessage 可靠消费者回调
+func (c *StreamConsumer) handleMessage(ctx context.Context, msg rediscommon.StreamMessage) error {
+	c.metrics.IncrementProcessed()
+	if err := c.processMessage(ctx, msg); err != nil {
+		c.logger.Error("Failed to process message",
+			zap.String("stream_id", msg.ID),
+			zap.Error(err),
+		)
+		return err
 	}
-	
 	return nil
 }
 
@@ -234,39 +235,33 @@ func (c *StreamConsumer) processMessage(ctx context.Context, msg rediscommon.Str
 	startTime := time.Now()
 	
 	// 解析消息数据
-	var dataStr string
-	if val, ok := msg.Values["data"]; ok {
-		if str, ok := val.(string); ok {
-			dataStr = str
-		} else {
-			c.metrics.IncrementFailed("parse")
-			return fmt.Errorf("invalid data format in message")
-		}
-	} else {
-		c.metrics.IncrementFailed("parse")
-		return fmt.Errorf("missing data field in message")
-	}
-	
-	// 解析 JSON
-	var iotData models.IoTDataMessage
-	if err := json.Unmarshal([]byte(dataStr), &iotData); err != nil {
+	iotData, err := parseIoTDataMessage(msg)
+	if err != nil {
 		c.metrics.IncrementFailed("parse")
 		c.logger.Error("Failed to parse message data",
 			zap.String("stream_id", msg.ID),
-			zap.String("device_id", iotData.DeviceID),
 			zap.Error(err),
 		)
-		return fmt.Errorf("failed to unmarshal message data: %w", err)
+		return rediscommon.Permanent(err)
 	}
 	
 	c.logger.Debug("Processing IoT data",
 		zap.String("device_id", iotData.DeviceID),
 		zap.String("device_type", iotData.DeviceType),
 		zap.String("tenant_id", iotData.TenantID),
+		zap.String("trace_id", iotData.TraceID),
 	)
 	
-	// 1. 根据 device_id 和 tenant_id 查询关联的卡片
-	cardInfo, err := c.cardRepo.GetCardByDeviceID(iotData.TenantID, iotData.DeviceID)
+	// 更新设备内存状态（消息未携带样本时丢弃状态，下次融合从数据库加载）
+	frame := frameRows(iotData)
+	if len(frame) > 0 {
+		c.fusion.Observe(iotData.DeviceID, frame)
+	} else {
+		c.fusion.Forget(iotData.DeviceID)
+	}
+	
+	// 1. 根据 device_id 和 tenant_id 查询关联的卡片（卡片拓扑缓存）
+	cardInfo, err := c.cardRepo.ResolveCard(iotData.TenantID, iotData.DeviceID)
 	if err != nil {
 		c.metrics.IncrementSkipped()
 		c.logger.Warn("Card not found for device",
@@ -277,8 +272,11 @@ func (c *StreamConsumer) processMessage(ctx context.Context, msg rediscommon.Str
 		return nil // 设备可能未绑定到卡片，忽略
 	}
 	
+	// 雷达目标轨迹跟踪（区域按卡片所在单元的布局）
+	c.trackTargets(ctx, iotData, cardInfo, frame)
+	
 	// 2. 融合卡片的所有设备数据（传递卡片类型）
-	realtimeData, err := c.fusion.FuseCardData(cardInfo.TenantID, cardInfo.CardID, cardInfo.CardType)
+	realtimeData, err := c.fusion.FuseCardData(cardInfo)
 	if err != nil {
 		c.metrics.IncrementFailed("fusion_failed")
 		c.logger.Error("Failed to fuse card data",
@@ -314,6 +312,122 @@ func (c *StreamConsumer) processMessage(ctx context.Context, msg rediscommon.Str
 	return nil
 }
 
+// parseIoTDataMessage 解析 iot:data:stream 消息
+// 优先按 iot.data 信封解码；旧格式（data 字段）消息按原方式解析，便于滚动升级
+func parseIoTDataMessage(msg rediscommon.StreamMessage) (*models.IoTDataMessage, error) {
+	env, err := rediscommon.Decode[events.IoTData](msg, events.IoTDataSchema)
+	if err == nil {
+		return &models.IoTDataMessage{
+			IoTTimeSeriesID: env.Payload.IoTTimeSeriesID,
+			DeviceID:        env.Payload.DeviceID,
+			TenantID:        env.TenantID,
+			DeviceType:      env.Payload.DeviceType,
+			Timestamp:       env.EventTime.Unix(),
+			DataType:        env.Payload.DataType,
+			Category:        env.Payload.Category,
+			TraceID:         env.TraceID,
+			EventTime:       env.EventTime,
+			FrameID:         env.Payload.FrameID,
+			Samples:         env.Payload.Samples,
+		}, nil
+	}
+	if !errors.Is(err, rediscommon.ErrNotEnvelope) {
+		return nil, err
+	}
+	
+	dataStr, ok := msg.Values["data"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing data field in message")
+	}
+	var iotData models.IoTDataMessage
+	if err := json.Unmarshal([]byte(dataStr), &iotData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal message data: %w", err)
+	}
+	return &iotData, nil
+}
+
+// frameRows 将消息携带的样本转换为 iot_timeseries 行（与数据库读取的行一致）
+func frameRows(iotData *models.IoTDataMessage) []*models.IoTTimeSeries {
+	if len(iotData.Samples) == 0 {
+		return nil
+	}
+	var frameID *string
+	if iotData.FrameID != "" {
+		frameID = &iotData.FrameID
+	}
+	rows := make([]*models.IoTTimeSeries, 0, len(iotData.Samples))
+	for i, sample := range iotData.Samples {
+		// 多目标帧只知道第一行的 ID，其余行按帧内序号区分（按 frame_id 计帧，ID 仅用于去重）
+		id := strconv.FormatInt(iotData.IoTTimeSeriesID, 10)
+		if i > 0 {
+			id += ":" + strconv.Itoa(i)
+		}
+		qualityFlag := sample.QualityFlag
+		if qualityFlag == "" {
+			qualityFlag = models.QualityOK
+		}
+		row := &models.IoTTimeSeries{
+			ID:                     id,
+			TenantID:               iotData.TenantID,
+			DeviceID:               iotData.DeviceID,
+			DeviceType:             iotData.DeviceType,
+			Timestamp:              iotData.EventTime,
+			HeartRate:              sample.HeartRate,
+			RespiratoryRate:        sample.RespiratoryRate,
+			PostureSNOMEDCode:      sample.PostureCode,
+			PostureDisplay:         sample.PostureDisplay,
+			TrackingID:             sample.TrackingID,
+			RadarPosX:              sample.RadarPosX,
+			RadarPosY:              sample.RadarPosY,
+			RadarPosZ:              sample.RadarPosZ,
+			BedStatusSNOMEDCode:    sample.BedStatusCode,
+			SleepStateSNOMEDCode:   sample.SleepStateCode,
+			FrameID:                frameID,
+			QualityFlag:            qualityFlag,
+			HeartRateQuality:       sample.HeartRateQuality,
+			RespiratoryRateQuality: sample.RespiratoryRateQuality,
+		}
+		row.DropUntrustedVitals()
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// trackTargets 将雷达帧的目标位置交给轨迹管理器，并发布产生的轨迹事件
+// 不携带样本的旧格式消息没有位置，跳过
+func (c *StreamConsumer) trackTargets(ctx context.Context, iotData *models.IoTDataMessage, cardInfo *repository.CardInfo, frame []*models.IoTTimeSeries) {
+	if c.tracks == nil || iotData.DeviceType != fusion.SourceRadar || len(frame) == 0 {
+		return
+	}
+	targets := make([]tracking.Target, 0, len(frame))
+	for _, row := range frame {
+		if row.TrackingID == nil || row.RadarPosX == nil || row.RadarPosY == nil {
+			continue
+		}
+		targets = append(targets, tracking.Target{
+			TrackingID: *row.TrackingID,
+			Position:   tracking.Point{X: float64(*row.RadarPosX), Y: float64(*row.RadarPosY)},
+			Z:          row.RadarPosZ,
+		})
+	}
+	
+	var unitID string
+	if cardInfo.UnitID != nil {
+		unitID = *cardInfo.UnitID
+	}
+	evs := c.tracks.Observe(tracking.Frame{
+		TenantID: iotData.TenantID,
+		DeviceID: iotData.DeviceID,
+		CardID:   cardInfo.CardID,
+		UnitID:   unitID,
+		At:       iotData.EventTime,
+		Targets:  targets,
+	})
+	if len(evs) > 0 && c.trackEvents != nil {
+		c.trackEvents.Publish(ctx, evs)
+	}
+}
+
 // reportMetrics 定期报告指标（每60秒）
 func (c *StreamConsumer) reportMetrics(ctx context.Context) {
 	ticker := time.NewTicker(60 * time.Second)
@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"wisefido-sensor-fusion/internal/models"
+
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+)
+
+func intPtr(v int) *int { return &v }
+
+func strPtr(v string) *string { return &v }
+
+func TestFrameRows(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if rows := frameRows(&models.IoTDataMessage{IoTTimeSeriesID: 1, DeviceID: "dev-1"}); rows != nil {
+		t.Fatalf("message without samples should yield no rows, got %d", len(rows))
+	}
+
+	msg := &models.IoTDataMessage{
+		IoTTimeSeriesID: 100,
+		DeviceID:        "radar-1",
+		TenantID:        "t1",
+		DeviceType:      "Radar",
+		EventTime:       at,
+		FrameID:         "100",
+		Samples: []events.IoTSample{
+			{HeartRate: intPtr(70), RespiratoryRate: intPtr(16), TrackingID: strPtr("1"), RadarPosX: intPtr(10)},
+			{HeartRate: intPtr(140), RespiratoryRate: intPtr(18), TrackingID: strPtr("2"),
+				QualityFlag: "outlier", HeartRateQuality: "outlier", RespiratoryRateQuality: "ok"},
+			{HeartRate: intPtr(200), RespiratoryRate: intPtr(20), QualityFlag: "low_signal"},
+		},
+	}
+	rows := frameRows(msg)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+
+	wantIDs := []string{"100", "100:1", "100:2"}
+	for i, r := range rows {
+		if r.ID != wantIDs[i] {
+			t.Errorf("row %d id = %s, want %s", i, r.ID, wantIDs[i])
+		}
+		if r.DeviceID != "radar-1" || r.TenantID != "t1" || r.DeviceType != "Radar" || !r.Timestamp.Equal(at) {
+			t.Errorf("row %d envelope fields not copied: %+v", i, r)
+		}
+		if r.FrameID == nil || *r.FrameID != "100" {
+			t.Errorf("row %d frame_id = %v", i, r.FrameID)
+		}
+	}
+
+	// 无质量标记按 ok 处理
+	if rows[0].QualityFlag != models.QualityOK || rows[0].HeartRate == nil || rows[0].RespiratoryRate == nil {
+		t.Errorf("row 0 should be trusted: %+v", rows[0])
+	}
+	if rows[0].TrackingID == nil || *rows[0].TrackingID != "1" || rows[0].RadarPosX == nil || *rows[0].RadarPosX != 10 {
+		t.Errorf("row 0 target fields not copied: %+v", rows[0])
+	}
+	// 心率离群只丢弃心率
+	if rows[1].HeartRate != nil || rows[1].RespiratoryRate == nil {
+		t.Errorf("row 1 should keep only respiratory rate: hr=%v rr=%v", rows[1].HeartRate, rows[1].RespiratoryRate)
+	}
+	// 旧版本样本只有整行标记
+	if rows[2].HeartRate != nil || rows[2].RespiratoryRate != nil {
+		t.Errorf("row 2 vitals should be dropped: hr=%v rr=%v", rows[2].HeartRate, rows[2].RespiratoryRate)
+	}
+
+	// 单目标消息没有 frame_id
+	single := frameRows(&models.IoTDataMessage{IoTTimeSeriesID: 7, Samples: []events.IoTSample{{}}})
+	if len(single) != 1 || single[0].ID != "7" || single[0].FrameID != nil {
+		t.Fatalf("unexpected single row %+v", single[0])
+	}
+}
+
+func TestParseIoTDataMessage_Envelope(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	env := rediscommon.NewEnvelope(events.IoTDataSchema, "test", "t1", at, events.IoTData{
+		IoTTimeSeriesID: 100,
+		DeviceID:        "radar-1",
+		DeviceType:      "Radar",
+		FrameID:         "100",
+		TargetCount:     1,
+		Samples:         []events.IoTSample{{HeartRate: intPtr(70)}},
+	})
+	values, err := rediscommon.Encode(env)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	msg, err := parseIoTDataMessage(rediscommon.StreamMessage{ID: "1-0", Values: values})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if msg.DeviceID != "radar-1" || msg.TenantID != "t1" || msg.FrameID != "100" || len(msg.Samples) != 1 || !msg.EventTime.Equal(at) {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
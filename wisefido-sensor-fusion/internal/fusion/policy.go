@@ -0,0 +1,226 @@
+package fusion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"wisefido-sensor-fusion/internal/repository"
+
+	"go.uber.org/zap"
+	"owl-common/cache"
+)
+
+// 数据源名称（与 RealtimeData.HeartSource 等字段一致）
+const (
+	SourceSleepace = "Sleepace"
+	SourceRadar    = "Radar"
+)
+
+// Policy 融合策略（服务默认值，可按床位 / 单元在 fusion_policy.spec 中覆盖）
+type Policy struct {
+	Version                  int                `json:"version,omitempty"`                    // fusion_policy.version（加载时填充，0 表示默认策略）
+	WindowSeconds            int                `json:"window_seconds,omitempty"`             // 滑动窗口长度
+	FreshnessSeconds         int                `json:"freshness_seconds,omitempty"`          // 新鲜度上限：设备最新样本超过该时长不参与融合
+	MinConfidence            *float64           `json:"min_confidence,omitempty"`             // 置信度低于该值时不输出融合值
+	Sources                  map[string]float64 `json:"sources,omitempty"`                    // 数据源基础权重，0 表示不采用该数据源
+	HeartRateTolerance       float64            `json:"heart_rate_tolerance,omitempty"`       // 数据源之间心率一致性容差（次/分）
+	RespiratoryRateTolerance float64            `json:"respiratory_rate_tolerance,omitempty"` // 数据源之间呼吸率一致性容差（次/分）
+}
+
+// 内置默认值（服务配置未指定时使用）
+const (
+	defaultHeartRateTolerance       = 8
+	defaultRespiratoryRateTolerance = 4
+)
+
+// defaultSourceWeights 数据源默认权重：睡眠垫接触式测量，生命体征优先于雷达
+var defaultSourceWeights = map[string]float64{
+	SourceSleepace: 1.0,
+	SourceRadar:    0.6,
+}
+
+// DefaultPolicy 服务默认融合策略
+func DefaultPolicy(windowSeconds, freshnessSeconds int, minConfidence float64) *Policy {
+	sources := make(map[string]float64, len(defaultSourceWeights))
+	for source, weight := range defaultSourceWeights {
+		sources[source] = weight
+	}
+	return &Policy{
+		WindowSeconds:            windowSeconds,
+		FreshnessSeconds:         freshnessSeconds,
+		MinConfidence:            &minConfidence,
+		Sources:                  sources,
+		HeartRateTolerance:       defaultHeartRateTolerance,
+		RespiratoryRateTolerance: defaultRespiratoryRateTolerance,
+	}
+}
+
+// Validate 校验融合策略
+func (p *Policy) Validate() error {
+	if p.WindowSeconds < 0 || p.FreshnessSeconds < 0 {
+		return fmt.Errorf("window_seconds and freshness_seconds must not be negative")
+	}
+	if p.MinConfidence != nil && (*p.MinConfidence < 0 || *p.MinConfidence > 1) {
+		return fmt.Errorf("min_confidence must be between 0 and 1")
+	}
+	for source, weight := range p.Sources {
+		if weight < 0 {
+			return fmt.Errorf("source %q: weight must not be negative", source)
+		}
+	}
+	if p.HeartRateTolerance < 0 || p.RespiratoryRateTolerance < 0 {
+		return fmt.Errorf("tolerances must not be negative")
+	}
+	return nil
+}
+
+// ParsePolicy 解析并校验融合策略
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse fusion policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// merge 以 p 覆盖默认策略中已配置的字段
+func (p *Policy) merge(defaults *Policy) *Policy {
+	merged := *defaults
+	merged.Version = p.Version
+	if p.WindowSeconds > 0 {
+		merged.WindowSeconds = p.WindowSeconds
+	}
+	if p.FreshnessSeconds > 0 {
+		merged.FreshnessSeconds = p.FreshnessSeconds
+	}
+	if p.MinConfidence != nil {
+		merged.MinConfidence = p.MinConfidence
+	}
+	if p.HeartRateTolerance > 0 {
+		merged.HeartRateTolerance = p.HeartRateTolerance
+	}
+	if p.RespiratoryRateTolerance > 0 {
+		merged.RespiratoryRateTolerance = p.RespiratoryRateTolerance
+	}
+	merged.Sources = make(map[string]float64, len(defaults.Sources)+len(p.Sources))
+	for source, weight := range defaults.Sources {
+		merged.Sources[source] = weight
+	}
+	for source, weight := range p.Sources {
+		merged.Sources[source] = weight
+	}
+	return &merged
+}
+
+// window 滑动窗口长度（不短于新鲜度上限）
+func (p *Policy) window() time.Duration {
+	if p.WindowSeconds < p.FreshnessSeconds {
+		return time.Duration(p.FreshnessSeconds) * time.Second
+	}
+	return time.Duration(p.WindowSeconds) * time.Second
+}
+
+// freshness 新鲜度上限
+func (p *Policy) freshness() time.Duration {
+	return time.Duration(p.FreshnessSeconds) * time.Second
+}
+
+// minConfidence 最低置信度
+func (p *Policy) minConfidence() float64 {
+	if p.MinConfidence == nil {
+		return 0
+	}
+	return *p.MinConfidence
+}
+
+// sourceWeight 数据源基础权重（未配置的数据源权重为 0）
+func (p *Policy) sourceWeight(source string) float64 {
+	return p.Sources[source]
+}
+
+// policyErrorTTL 加载融合策略失败后使用默认策略的时间（避免数据库不可用时每条消息都查询）
+const policyErrorTTL = 5 * time.Second
+
+// PolicyResolver 融合策略解析器（带缓存，修改 fusion_policy 后在缓存过期时生效，无需重新部署）
+type PolicyResolver struct {
+	repo     *repository.FusionPolicyRepository
+	defaults *Policy
+	cache    *cache.TTL[*Policy]
+	failures *cache.TTL[*Policy] // 加载失败的键（只使用负缓存）
+	logger   *zap.Logger
+}
+
+// NewPolicyResolver 创建融合策略解析器
+// repo 为 nil 时始终使用默认策略
+func NewPolicyResolver(repo *repository.FusionPolicyRepository, defaults *Policy, ttl time.Duration, logger *zap.Logger) *PolicyResolver {
+	errorTTL := policyErrorTTL
+	if ttl < errorTTL {
+		errorTTL = ttl
+	}
+	return &PolicyResolver{
+		repo:     repo,
+		defaults: defaults,
+		cache:    cache.NewTTL[*Policy](ttl, ttl),
+		failures: cache.NewTTL[*Policy](0, errorTTL),
+		logger:   logger,
+	}
+}
+
+// Resolve 返回卡片适用的融合策略，没有配置或配置无效时返回默认策略
+func (r *PolicyResolver) Resolve(card *repository.CardInfo) *Policy {
+	if r.repo == nil {
+		return r.defaults
+	}
+	key := card.TenantID + "|" + derefString(card.UnitID) + "|" + derefString(card.BedID)
+	if policy, missing, ok := r.cache.Get(key); ok {
+		if missing {
+			return r.defaults
+		}
+		return policy
+	}
+	if _, _, ok := r.failures.Get(key); ok {
+		return r.defaults
+	}
+
+	data, version, err := r.repo.GetSpec(card.TenantID, card.UnitID, card.BedID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFusionPolicyNotFound) {
+			r.cache.SetMissing(key)
+		} else {
+			// 数据库暂时不可用：短时间内使用默认策略，之后重新加载
+			r.failures.SetMissing(key)
+			r.logger.Warn("Failed to load fusion policy, using default policy",
+				zap.String("card_id", card.CardID),
+				zap.Error(err),
+			)
+		}
+		return r.defaults
+	}
+
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		r.logger.Error("Invalid fusion policy, using default policy",
+			zap.String("card_id", card.CardID),
+			zap.Int("version", version),
+			zap.Error(err),
+		)
+		r.cache.SetMissing(key)
+		return r.defaults
+	}
+	policy.Version = version
+	policy = policy.merge(r.defaults)
+	r.cache.Set(key, policy)
+	return policy
+}
+
+// derefString 空指针返回空字符串
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
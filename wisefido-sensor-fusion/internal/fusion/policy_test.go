@@ -0,0 +1,184 @@
+package fusion
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"wisefido-sensor-fusion/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", `{"window_seconds":120,"min_confidence":0.7,"sources":{"Radar":0}}`, false},
+		{"empty", `{}`, false},
+		{"negative window", `{"window_seconds":-1}`, true},
+		{"negative freshness", `{"freshness_seconds":-1}`, true},
+		{"min confidence above one", `{"min_confidence":1.5}`, true},
+		{"negative min confidence", `{"min_confidence":-0.1}`, true},
+		{"negative weight", `{"sources":{"Radar":-1}}`, true},
+		{"negative tolerance", `{"heart_rate_tolerance":-2}`, true},
+		{"invalid json", `{`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePolicy([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicy error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 覆盖策略只替换已配置的字段，显式的 0 置信度和 0 权重生效，默认策略不被修改
+func TestPolicy_Merge(t *testing.T) {
+	defaults := DefaultPolicy(60, 30, 0.5)
+	override, err := ParsePolicy([]byte(`{"freshness_seconds":10,"min_confidence":0,"sources":{"Radar":0,"Other":0.3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	override.Version = 3
+
+	merged := override.merge(defaults)
+	if merged.Version != 3 || merged.WindowSeconds != 60 || merged.FreshnessSeconds != 10 || merged.minConfidence() != 0 {
+		t.Fatalf("merged = %+v", merged)
+	}
+	if merged.HeartRateTolerance != defaultHeartRateTolerance || merged.RespiratoryRateTolerance != defaultRespiratoryRateTolerance {
+		t.Fatalf("tolerances should keep defaults, got %+v", merged)
+	}
+	if merged.sourceWeight(SourceSleepace) != 1 || merged.sourceWeight(SourceRadar) != 0 || merged.sourceWeight("Other") != 0.3 {
+		t.Fatalf("merged sources = %v", merged.Sources)
+	}
+	if defaults.Sources[SourceRadar] != 0.6 || defaults.minConfidence() != 0.5 || defaults.FreshnessSeconds != 30 {
+		t.Fatalf("defaults modified: %+v", defaults)
+	}
+}
+
+// 窗口不短于新鲜度上限
+func TestPolicy_Window(t *testing.T) {
+	if w := DefaultPolicy(60, 30, 0).window(); w != time.Minute {
+		t.Fatalf("window = %v", w)
+	}
+	if w := DefaultPolicy(10, 30, 0).window(); w != 30*time.Second {
+		t.Fatalf("window shorter than freshness = %v", w)
+	}
+}
+
+func newTestResolver(t *testing.T) (*PolicyResolver, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	repo := repository.NewFusionPolicyRepository(db, zap.NewNop())
+	return NewPolicyResolver(repo, DefaultPolicy(60, 30, 0.5), time.Minute, zap.NewNop()), mock
+}
+
+func expectPolicy(mock sqlmock.Sqlmock, spec string, version int) {
+	mock.ExpectQuery("FROM fusion_policy").
+		WillReturnRows(sqlmock.NewRows([]string{"spec", "version"}).AddRow([]byte(spec), version))
+}
+
+func strPtr(s string) *string { return &s }
+
+var policyCard = &repository.CardInfo{CardID: "card-1", TenantID: "t1", UnitID: strPtr("unit-1"), BedID: strPtr("bed-1")}
+
+func TestPolicyResolver_Resolve(t *testing.T) {
+	r, mock := newTestResolver(t)
+	expectPolicy(mock, `{"min_confidence":0.8}`, 2)
+
+	for i := 0; i < 2; i++ {
+		policy := r.Resolve(policyCard)
+		if policy.Version != 2 || policy.minConfidence() != 0.8 || policy.WindowSeconds != 60 {
+			t.Fatalf("Resolve #%d = %+v", i, policy)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected a single query: %v", err)
+	}
+}
+
+// 没有配置和配置无效时使用默认策略，并缓存该结果
+func TestPolicyResolver_DefaultsCached(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(sqlmock.Sqlmock)
+	}{
+		{"not found", func(mock sqlmock.Sqlmock) {
+			mock.ExpectQuery("FROM fusion_policy").WillReturnError(sql.ErrNoRows)
+		}},
+		{"invalid spec", func(mock sqlmock.Sqlmock) {
+			expectPolicy(mock, `{"min_confidence":2}`, 1)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := newTestResolver(t)
+			tt.expect(mock)
+			for i := 0; i < 2; i++ {
+				if policy := r.Resolve(policyCard); policy != r.defaults {
+					t.Fatalf("Resolve #%d = %+v, want defaults", i, policy)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("expected a single query: %v", err)
+			}
+		})
+	}
+}
+
+// 数据库错误时短时间内使用默认策略，不逐条消息查询；之后重新加载
+func TestPolicyResolver_ErrorCachedBriefly(t *testing.T) {
+	r, mock := newTestResolver(t)
+	mock.ExpectQuery("FROM fusion_policy").WillReturnError(errors.New("connection reset"))
+	for i := 0; i < 3; i++ {
+		if policy := r.Resolve(policyCard); policy != r.defaults {
+			t.Fatalf("Resolve #%d = %+v, want defaults", i, policy)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected a single query while failing: %v", err)
+	}
+
+	// 错误缓存过期后重新加载
+	r.failures.Purge()
+	expectPolicy(mock, `{"min_confidence":0.8}`, 2)
+	if policy := r.Resolve(policyCard); policy.Version != 2 {
+		t.Fatalf("Resolve after error expiry = %+v", policy)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 错误缓存时间不超过策略缓存时间，未启用缓存时每次重新查询
+func TestPolicyResolver_ErrorTTLBoundedByCacheTTL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	defer db.Close()
+	r := NewPolicyResolver(repository.NewFusionPolicyRepository(db, zap.NewNop()), DefaultPolicy(60, 30, 0.5), 0, zap.NewNop())
+	mock.ExpectQuery("FROM fusion_policy").WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery("FROM fusion_policy").WillReturnError(errors.New("connection reset"))
+	r.Resolve(policyCard)
+	r.Resolve(policyCard)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyResolver_NoRepository(t *testing.T) {
+	defaults := DefaultPolicy(60, 30, 0.5)
+	if policy := NewPolicyResolver(nil, defaults, time.Minute, zap.NewNop()).Resolve(policyCard); policy != defaults {
+		t.Fatalf("Resolve = %+v, want defaults", policy)
+	}
+}
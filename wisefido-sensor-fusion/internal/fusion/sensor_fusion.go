@@ -3,14 +3,17 @@
 // 主要功能：
 // - 多传感器数据融合（处理所有卡片上的设备数据）
 // - 融合条件：
-//   - ActiveBed 卡片：同一床上同时有 Radar 和 Sleepace 设备（只融合 bed_id 有效且相同的设备）
+//   - ActiveBed 卡片：按床位分组融合（bed_id 有效且相同的设备为一组），每张床输出一组结果及床位绑定的住户
 //   - Location 卡片：同一卡片上同时有 Radar 和 Sleepace 设备（融合所有设备）
-// - 融合内容：HR/RR、床状态/睡眠状态（优先 Sleepace）
+// - 融合内容：HR/RR、床状态/睡眠状态（滑动窗口内按信号质量、新鲜度和数据源一致性加权，输出置信度）
+// - 融合策略：按床位 / 单元在 fusion_policy 表中配置（窗口、新鲜度、数据源权重、一致性容差）
 // - 姿态数据：直接使用 Radar 数据（Sleepace 不提供姿态数据）
 package fusion
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"time"
 	"wisefido-sensor-fusion/internal/models"
 	"wisefido-sensor-fusion/internal/repository"
@@ -23,34 +26,48 @@ import (
 // 负责将多个设备的数据融合为统一的实时数据格式
 // 
 // 融合条件：
-// - ActiveBed 卡片：同一床上同时有 Radar 和 Sleepace 设备（只融合 bed_id 有效且相同的设备）
+// - ActiveBed 卡片：按床位分组融合（bed_id 有效且相同的设备为一组）
 //   - 场景 A（门牌下只有 1 个 ActiveBed）：ActiveBed 卡片包含床上的设备（bed_id 有效）和未绑床的设备（bed_id 为 NULL）
 //     - 只融合床上的设备（bed_id 有效且相同），未绑床的设备（bed_id 为 NULL）不参与融合
 //   - 场景 B（门牌下有多个 ActiveBed）：ActiveBed 卡片只包含床上的设备（bed_id 有效）
 //     - 融合床上的设备（bed_id 有效且相同）
+//   - 卡片设备分布在多张床上时，每张床分别融合，结果写入 RealtimeData.Beds，避免第二位住户被忽略
 // - Location 卡片：同一卡片上同时有 Radar 和 Sleepace 设备（融合所有设备，bed_id 为 NULL）
 // - 所有卡片（ActiveBed 和 Location）都处理其设备数据
 // 
-// 融合规则：
-// - HR/RR：优先 Sleepace，无数据则 Radar
-// - 床状态/睡眠状态：优先 Sleepace，无数据则 Radar
-// - 姿态数据：直接使用 Radar 数据（不是融合，Sleepace 不提供姿态数据）
+// 融合规则（见 window.go）：
+// - 每个设备取滑动窗口内的样本，最新样本超过新鲜度上限的设备不参与融合
+// - HR/RR：按数据源权重 × 信号质量 × 新鲜度加权，与多数不一致的数据源不参与，输出置信度
+// - 床状态/睡眠状态：按数据源权重 × 新鲜度投票，输出置信度
+// - 默认数据源权重 Sleepace 高于 Radar（与原“优先 Sleepace”规则一致）
+// - 姿态数据：直接使用 Radar 最新帧（不是融合，Sleepace 不提供姿态数据）
 type SensorFusion struct {
-	cardRepo *repository.CardRepository       // 卡片仓库，用于查询设备关联
-	iotRepo  *repository.IoTTimeSeriesRepository // IoT 时序数据仓库，用于查询设备数据
-	logger   *zap.Logger                     // 日志记录器
+	cardRepo     *repository.CardRepository       // 卡片仓库，用于查询设备关联
+	iotRepo      *repository.IoTTimeSeriesRepository // IoT 时序数据仓库，用于查询设备数据
+	policies     *PolicyResolver                  // 融合策略
+	state        *StateStore                      // 设备滑动窗口状态（内存）
+	windowFrames int                              // 每个设备窗口内最多读取的帧数
+	now          func() time.Time
+	logger       *zap.Logger                     // 日志记录器
 }
 
 // NewSensorFusion 创建传感器融合器
 func NewSensorFusion(
 	cardRepo *repository.CardRepository,
 	iotRepo *repository.IoTTimeSeriesRepository,
+	policies *PolicyResolver,
+	state *StateStore,
+	windowFrames int,
 	logger *zap.Logger,
 ) *SensorFusion {
 	return &SensorFusion{
-		cardRepo: cardRepo,
-		iotRepo:  iotRepo,
-		logger:   logger,
+		cardRepo:     cardRepo,
+		iotRepo:      iotRepo,
+		policies:     policies,
+		state:        state,
+		windowFrames: windowFrames,
+		now:          time.Now,
+		logger:       logger,
 	}
 }
 
@@ -58,154 +75,249 @@ func NewSensorFusion(
 // 
 // ⚠️ 重要依赖：
 // - 本函数依赖 PostgreSQL cards 表，需要 wisefido-card-aggregator 服务先创建卡片
-// - 通过 GetCardDevices 查询卡片绑定的设备列表（从 cards.devices JSONB 字段）
+// - 通过 ResolveCardTopology 查询卡片绑定的设备和住户（从 cards.devices / cards.residents JSONB 字段）
 // - 如果 cards 表为空或卡片不存在，会返回错误
-// - 当前 wisefido-card-aggregator 的卡片创建功能还未实现，需要优先实现
 //
-// 该方法从卡片关联的设备中收集最新数据，并按照优先级规则进行融合。
-// 所有卡片（ActiveBed 和 Location）都处理其设备数据。
-// 
-// 融合条件：
-// - 同一张卡片上同时有 Radar 和 Sleepace 设备（两者都提供 HR/RR，需要选择更好的数据源）
-// 
-// 融合规则（仅当同时有 Radar 和 Sleepace 时）：
-// 1. HR/RR（心率/呼吸率）：优先使用 Sleepace 数据，如果 Sleepace 无数据则使用 Radar 数据
-// 2. 床状态/睡眠状态：优先使用 Sleepace 数据，如果 Sleepace 无数据则使用 Radar 数据
-// 
-// 非融合情况：
-// - 如果只有 Radar 或只有 Sleepace：直接使用该设备数据
-// - 姿态数据：直接使用 Radar 数据（不是融合，Sleepace 不提供姿态数据）
+// 该方法读取卡片关联设备在滑动窗口内的数据，按卡片适用的融合策略加权融合。
+// 所有卡片（ActiveBed 和 Location）都处理其设备数据；只有一个数据源时，融合结果即该数据源的估计值。
+// ActiveBed 卡片按床位分别融合（见 fuseBeds）。
 // 
 // 参数:
-//   - tenantID: 租户 ID（UUID 格式）
-//   - cardID: 卡片 ID（UUID 格式）
-//   - cardType: 卡片类型（"ActiveBed" 或 "Location"）
+//   - card: 卡片信息（卡片 ID、类型，以及用于选择融合策略的 unit_id / bed_id）
 // 
 // 返回:
-//   - *models.RealtimeData: 融合后的实时数据，包含心率、呼吸率、姿态等信息
+//   - *models.RealtimeData: 融合后的实时数据，包含心率、呼吸率、姿态及各项置信度（ActiveBed 卡片另含每张床的结果）
 //   - error: 如果融合过程中发生错误（如设备查询失败、数据获取失败等）
-func (f *SensorFusion) FuseCardData(tenantID, cardID, cardType string) (*models.RealtimeData, error) {
-	// 1. 获取卡片关联的所有设备
-	devices, err := f.cardRepo.GetCardDevices(cardID)
+func (f *SensorFusion) FuseCardData(card *repository.CardInfo) (*models.RealtimeData, error) {
+	cardID, cardType := card.CardID, card.CardType
+	
+	// 1. 获取卡片关联的所有设备和住户（卡片拓扑缓存，收到卡片变更通知时失效）
+	topology, err := f.cardRepo.ResolveCardTopology(cardID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get card devices: %w", err)
 	}
 	
-	if len(devices) == 0 {
+	if len(topology.Devices) == 0 {
 		return nil, fmt.Errorf("no devices found for card: %s", cardID)
 	}
 	
-	// 2. 过滤设备类型和绑定关系：只查询 Radar 和 Sleepace 设备（其他设备不参与融合）
-	// 融合规则：
-	// - ActiveBed 卡片：只融合绑定到同一床上的设备（bed_id 有效且相同）
-	//   - 如果 bed_id 有效，则所有 bed_id 相同的设备都是绑在同一床上的，应该融合
-	//   - 如果 bed_id 为 NULL，则不参与融合（未绑床的设备）
-	// - Location 卡片：融合所有设备（因为它们都是未绑床的设备，bed_id 为 NULL）
-	var fusionDeviceIDs []string
-	var bedIDForFusion *string // 用于 ActiveBed 卡片，记录第一个有效 bed_id
+	// 2. 过滤设备类型并按床位分组：只融合 Radar 和 Sleepace 设备（其他设备不参与融合）
+	// - ActiveBed 卡片：同一张床上的设备为一组（bed_id 有效且相同），bed_id 为 NULL 的设备不参与融合
+	// - Location 卡片：所有设备为一组（因为它们都是未绑床的设备，bed_id 为 NULL）
+	groups := make(map[string]*bedGroup)
+	var bedIDs []string
+	sources := make(map[string]string) // 设备 ID -> 数据源
 	
-	for _, device := range devices {
-		deviceType := device.DeviceType
-		if deviceType == "Radar" || deviceType == "Sleepace" || deviceType == "SleepPad" {
-			if cardType == "ActiveBed" {
-				// ActiveBed 卡片：只融合绑定到同一床上的设备
-				if device.BedID != nil && *device.BedID != "" {
-					// 如果这是第一个有效 bed_id，记录它
-					if bedIDForFusion == nil {
-						bedIDForFusion = device.BedID
-					}
-					// 只融合 bed_id 相同的设备（绑定到同一床上的设备）
-					if bedIDForFusion != nil && *device.BedID == *bedIDForFusion {
-						fusionDeviceIDs = append(fusionDeviceIDs, device.DeviceID)
-					}
-				}
-				// bed_id 为 NULL 的设备不参与融合（未绑床的设备）
-			} else {
-				// Location 卡片：融合所有设备（因为它们都是未绑床的设备，bed_id 为 NULL）
-				fusionDeviceIDs = append(fusionDeviceIDs, device.DeviceID)
+	for _, device := range topology.Devices {
+		source := sourceOf(device.DeviceType)
+		if source == "" {
+			continue
+		}
+		bedID := ""
+		if cardType == "ActiveBed" {
+			if device.BedID == nil || *device.BedID == "" {
+				continue
 			}
+			bedID = *device.BedID
+		}
+		group, ok := groups[bedID]
+		if !ok {
+			group = &bedGroup{bedID: bedID}
+			groups[bedID] = group
+			bedIDs = append(bedIDs, bedID)
+		}
+		if group.bedName == "" && device.BedName != nil {
+			group.bedName = *device.BedName
 		}
+		group.deviceIDs = append(group.deviceIDs, device.DeviceID)
+		sources[device.DeviceID] = source
 	}
 	
-	if len(fusionDeviceIDs) == 0 {
+	if len(groups) == 0 {
 		return nil, fmt.Errorf("no Radar or Sleepace devices found for card: %s", cardID)
 	}
 	
-	// 3. 批量获取 Radar 和 Sleepace 设备的最新数据（优化 N+1 查询）
-	// 使用批量查询（每个设备获取最新1条数据）
-	deviceDataMap, err := f.iotRepo.GetLatestByDeviceIDs(tenantID, fusionDeviceIDs, 1)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get latest data for devices: %w", err)
+	// 3. 融合每组设备在滑动窗口内的数据
+	now := f.now()
+	if cardType != "ActiveBed" {
+		return f.fuseGroup(card, groups[""].deviceIDs, sources, now)
+	}
+	return f.fuseBeds(card, topology, groups, bedIDs, sources, now)
+}
+
+// bedGroup 同一张床上参与融合的设备
+type bedGroup struct {
+	bedID     string
+	bedName   string
+	deviceIDs []string
+}
+
+// fuseBeds 按床位分别融合 ActiveBed 卡片的设备
+//
+// 每张床使用该床位适用的融合策略，结果写入 Beds（附带床位绑定的住户）；
+// 顶层生命体征 / 睡眠状态 / 床状态为卡片自身床位（cards.bed_id）的结果，便于只读取顶层字段的旧版本消费者；
+// 顶层姿态为所有床位 Radar 姿态的合并。
+func (f *SensorFusion) fuseBeds(
+	card *repository.CardInfo,
+	topology *repository.CardTopology,
+	groups map[string]*bedGroup,
+	bedIDs []string,
+	sources map[string]string,
+	now time.Time,
+) (*models.RealtimeData, error) {
+	sort.Strings(bedIDs)
+	primary := bedIDs[0]
+	if card.BedID != nil && groups[*card.BedID] != nil {
+		primary = *card.BedID
 	}
 	
-	// 4. 收集 Radar 和 Sleepace 设备的最新数据，并找到最大时间戳
-	var sleepaceData []*models.IoTTimeSeries
-	var radarData []*models.IoTTimeSeries
-	var maxTimestamp time.Time
+	var result models.RealtimeData
+	beds := make([]models.BedRealtime, 0, len(bedIDs))
+	var postures []models.Posture
+	var timestamp int64
+	for _, bedID := range bedIDs {
+		group := groups[bedID]
+		bedCard := *card
+		bedCard.BedID = &group.bedID
+		data, err := f.fuseGroup(&bedCard, group.deviceIDs, sources, now)
+		if err != nil {
+			return nil, err
+		}
+		beds = append(beds, models.BedRealtime{
+			BedID:        bedID,
+			BedName:      group.bedName,
+			ResidentID:   topology.ResidentForBed(bedID, card.BedID),
+			RealtimeData: *data,
+		})
+		if bedID == primary {
+			result = *data
+		}
+		postures = append(postures, data.Postures...)
+		if data.Timestamp > timestamp {
+			timestamp = data.Timestamp
+		}
+	}
 	
-	// 创建设备ID到设备信息的映射（用于获取设备类型）
-	deviceMap := make(map[string]*repository.DeviceInfo)
-	for _, device := range devices {
-		deviceType := device.DeviceType
-		if deviceType == "Radar" || deviceType == "Sleepace" || deviceType == "SleepPad" {
-			deviceMap[device.DeviceID] = &device
+	result.Postures = append([]models.Posture{}, postures...)
+	result.PersonCount = len(result.Postures)
+	result.Timestamp = timestamp
+	result.Beds = beds
+	return &result, nil
+}
+
+// fuseGroup 融合一组设备（同一张床或整张 Location 卡片）
+func (f *SensorFusion) fuseGroup(
+	card *repository.CardInfo,
+	deviceIDs []string,
+	sources map[string]string,
+	now time.Time,
+) (*models.RealtimeData, error) {
+	// 获取设备在滑动窗口内的数据（内存状态，不完整的设备从数据库批量加载一次）
+	policy := f.policies.Resolve(card)
+	deviceDataMap, err := f.windowData(card.TenantID, deviceIDs, now.Add(-policy.window()))
+	if err != nil {
+		return nil, err
+	}
+	
+	return f.fuse(deviceIDs, sources, deviceDataMap, policy, now), nil
+}
+
+// Observe 将 iot:data:stream 消息携带的一帧数据写入内存状态
+func (f *SensorFusion) Observe(deviceID string, frame []*models.IoTTimeSeries) {
+	f.state.Observe(deviceID, frame)
+}
+
+// Forget 丢弃设备的内存状态（消息未携带数据时调用，下次融合从数据库加载）
+func (f *SensorFusion) Forget(deviceID string) {
+	f.state.Forget(deviceID)
+}
+
+// windowData 获取设备在 since 之后的数据
+func (f *SensorFusion) windowData(tenantID string, deviceIDs []string, since time.Time) (map[string][]*models.IoTTimeSeries, error) {
+	deviceDataMap := make(map[string][]*models.IoTTimeSeries, len(deviceIDs))
+	var cold []string
+	for _, deviceID := range deviceIDs {
+		rows, complete := f.state.Window(deviceID, since)
+		if !complete {
+			cold = append(cold, deviceID)
+			continue
 		}
+		deviceDataMap[deviceID] = rows
+	}
+	if len(cold) == 0 {
+		return deviceDataMap, nil
+	}
+	
+	loaded, err := f.iotRepo.GetWindowByDeviceIDs(tenantID, cold, since, f.windowFrames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get window data for devices: %w", err)
+	}
+	for _, deviceID := range cold {
+		f.state.Load(deviceID, since, loaded[deviceID])
+		deviceDataMap[deviceID], _ = f.state.Window(deviceID, since)
 	}
+	f.logger.Debug("Loaded fusion window from database",
+		zap.Strings("device_ids", cold),
+	)
+	return deviceDataMap, nil
+}
+
+// fuse 按融合策略融合各设备窗口内的数据
+func (f *SensorFusion) fuse(
+	deviceIDs []string,
+	sources map[string]string,
+	deviceDataMap map[string][]*models.IoTTimeSeries,
+	policy *Policy,
+	now time.Time,
+) *models.RealtimeData {
+	var heart, breath, bedStatus, sleepStage []estimate
+	var radarFrames []*models.IoTTimeSeries
+	var maxTimestamp time.Time
+	minConfidence := policy.minConfidence()
 	
-	for _, deviceID := range fusionDeviceIDs {
-		latestData, ok := deviceDataMap[deviceID]
-		if !ok || len(latestData) == 0 {
-			f.logger.Warn("No data found for device",
+	for _, deviceID := range deviceIDs {
+		rows := deviceDataMap[deviceID]
+		if len(rows) == 0 {
+			f.logger.Debug("No data in fusion window for device",
 				zap.String("device_id", deviceID),
 			)
 			continue
 		}
 		
-		data := latestData[0]
-		
-		// 更新最大时间戳（使用数据的时间戳，而不是当前时间）
-		if data.Timestamp.After(maxTimestamp) {
-			maxTimestamp = data.Timestamp
+		// 优先使用数据中的设备类型，否则使用卡片中的设备类型
+		source := sources[deviceID]
+		if s := sourceOf(rows[0].DeviceType); s != "" {
+			source = s
 		}
 		
-		// 确定设备类型（优先使用数据中的类型，否则使用设备信息中的类型）
-		deviceType := data.DeviceType
-		if deviceType == "" {
-			if deviceInfo, ok := deviceMap[deviceID]; ok {
-				deviceType = deviceInfo.DeviceType
-			} else {
-				// 降级方案：从设备表查询
-				deviceTypeFromDB, err := f.iotRepo.GetDeviceType(tenantID, deviceID)
-				if err != nil {
-					f.logger.Warn("Failed to get device type",
-						zap.String("device_id", deviceID),
-						zap.Error(err),
-					)
-					continue
-				}
-				deviceType = deviceTypeFromDB
-			}
+		if e, ok := estimateNumeric(deviceID, source, rows, func(r *models.IoTTimeSeries) *int { return r.HeartRate }, (*models.IoTTimeSeries).HeartRateTrusted, policy.HeartRateTolerance, policy, now); ok {
+			heart = append(heart, e)
+		}
+		if e, ok := estimateNumeric(deviceID, source, rows, func(r *models.IoTTimeSeries) *int { return r.RespiratoryRate }, (*models.IoTTimeSeries).RespiratoryRateTrusted, policy.RespiratoryRateTolerance, policy, now); ok {
+			breath = append(breath, e)
+		}
+		if e, ok := estimateCode(deviceID, source, rows, func(r *models.IoTTimeSeries) *string { return r.BedStatusSNOMEDCode }, policy, now); ok {
+			bedStatus = append(bedStatus, e)
+		}
+		if e, ok := estimateCode(deviceID, source, rows, func(r *models.IoTTimeSeries) *string { return r.SleepStateSNOMEDCode }, policy, now); ok {
+			sleepStage = append(sleepStage, e)
 		}
 		
-		// 分类数据
-		if deviceType == "Sleepace" || deviceType == "SleepPad" {
-			sleepaceData = append(sleepaceData, data)
-		} else if deviceType == "Radar" {
-			radarData = append(radarData, data)
+		// 姿态：Radar 最新一帧（多目标帧的所有目标行），超过新鲜度上限的不使用
+		latest := latestFrame(rows)
+		if _, fresh := freshnessFactor(latest[0].Timestamp, now, policy.freshness()); !fresh {
+			continue
+		}
+		if latest[0].Timestamp.After(maxTimestamp) {
+			maxTimestamp = latest[0].Timestamp
+		}
+		if source == SourceRadar {
+			radarFrames = append(radarFrames, latest...)
 		}
 	}
 	
-	// 5. 判断是否需要融合 HR/RR 和床状态
-	// 融合条件：
-	// - ActiveBed 卡片：同一床上同时有 Radar 和 Sleepace 设备（只融合 bed_id 有效且相同的设备）
-	// - Location 卡片：同一卡片上同时有 Radar 和 Sleepace 设备（融合所有设备，bed_id 为 NULL）
-	// 注意：Location 卡片上的设备可能监测不同的人，理论上不应该融合 HR/RR
-	// 但为了统一处理逻辑，如果 Location 卡片上同时有 Radar 和 Sleepace，也进行融合
-	// （实际业务中，Location 卡片通常不会同时绑定 Radar 和 Sleepace）
-	needFusion := len(sleepaceData) > 0 && len(radarData) > 0
-	
-	// 使用数据的时间戳（如果没有任何数据，使用当前时间作为降级）
-	resultTimestamp := time.Now().Unix()
+	// 使用数据的时间戳（如果没有任何新鲜数据，使用当前时间作为降级）
+	resultTimestamp := now.Unix()
 	if !maxTimestamp.IsZero() {
 		resultTimestamp = maxTimestamp.Unix()
 	}
@@ -215,194 +327,53 @@ func (f *SensorFusion) FuseCardData(tenantID, cardID, cardType string) (*models.
 		Postures:  []models.Posture{},
 	}
 	
-	// 6. 处理数据（所有卡片都处理其设备数据）
-	if needFusion {
-		// 需要融合：同一张卡片上同时有 Radar 和 Sleepace 设备
-		// 选择更好的 HR/RR 和床状态数据源（优先 Sleepace）
-		f.fuseVitalSigns(sleepaceData, radarData, result)
-		f.fuseBedAndSleepStatus(sleepaceData, radarData, result)
-	} else {
-		// 不需要融合：直接使用设备数据
-		// 如果只有 Sleepace，使用 Sleepace 数据
-		if len(sleepaceData) > 0 {
-			f.useSingleDeviceData(sleepaceData[0], result)
-		}
-		// 如果只有 Radar，使用 Radar 数据
-		// 注意：对于 Location 卡片，如果有多个 Radar，只使用第一个 Radar 的 HR/RR/床状态
-		if len(radarData) > 0 {
-			f.useRadarDeviceData(radarData[0], result)
-		}
+	if v, ok := fuseNumeric(heart, policy.HeartRateTolerance, minConfidence); ok {
+		hr := int(math.Round(v.value))
+		timestamp := v.at.Unix()
+		result.Heart, result.HeartSource, result.HeartTimestamp = &hr, v.source, &timestamp
+		result.HeartConfidence = &v.confidence
 	}
-	
-	// 7. 处理姿态数据（直接使用 Radar 数据，不是融合）
-	// 注意：Sleepace 不提供姿态数据，所以直接使用 Radar 数据
-	f.useRadarPostures(radarData, result)
-	
-	return result, nil
-}
-
-// useSingleDeviceData 使用单个 Sleepace 设备的数据（不需要融合的情况）
-func (f *SensorFusion) useSingleDeviceData(data *models.IoTTimeSeries, result *models.RealtimeData) {
-	if data.HeartRate != nil {
-		result.Heart = data.HeartRate
-		result.HeartSource = "Sleepace"
-		timestamp := data.Timestamp.Unix()
-		result.HeartTimestamp = &timestamp
-	}
-	if data.RespiratoryRate != nil {
-		result.Breath = data.RespiratoryRate
-		result.BreathSource = "Sleepace"
-		timestamp := data.Timestamp.Unix()
-		result.BreathTimestamp = &timestamp
-	}
-	if data.BedStatusSNOMEDCode != nil {
-		result.BedStatus = data.BedStatusSNOMEDCode
-		result.BedStatusSource = "Sleepace"
-		timestamp := data.Timestamp.Unix()
-		result.BedStatusTimestamp = &timestamp
-	}
-	if data.SleepStateSNOMEDCode != nil {
-		result.SleepStage = data.SleepStateSNOMEDCode
-		result.SleepStageSource = "Sleepace"
-		timestamp := data.Timestamp.Unix()
-		result.SleepStageTimestamp = &timestamp
-	}
-}
-
-// useRadarDeviceData 使用单个 Radar 设备的数据（不需要融合的情况）
-func (f *SensorFusion) useRadarDeviceData(data *models.IoTTimeSeries, result *models.RealtimeData) {
-	if data.HeartRate != nil {
-		result.Heart = data.HeartRate
-		result.HeartSource = "Radar"
-		timestamp := data.Timestamp.Unix()
-		result.HeartTimestamp = &timestamp
-	}
-	if data.RespiratoryRate != nil {
-		result.Breath = data.RespiratoryRate
-		result.BreathSource = "Radar"
-		timestamp := data.Timestamp.Unix()
-		result.BreathTimestamp = &timestamp
+	if v, ok := fuseNumeric(breath, policy.RespiratoryRateTolerance, minConfidence); ok {
+		rr := int(math.Round(v.value))
+		timestamp := v.at.Unix()
+		result.Breath, result.BreathSource, result.BreathTimestamp = &rr, v.source, &timestamp
+		result.BreathConfidence = &v.confidence
 	}
-	if data.BedStatusSNOMEDCode != nil {
-		result.BedStatus = data.BedStatusSNOMEDCode
-		result.BedStatusSource = "Radar"
-		timestamp := data.Timestamp.Unix()
-		result.BedStatusTimestamp = &timestamp
+	if v, ok := fuseCode(bedStatus, minConfidence); ok {
+		code := v.code
+		timestamp := v.at.Unix()
+		result.BedStatus, result.BedStatusSource, result.BedStatusTimestamp = &code, v.source, &timestamp
+		result.BedStatusConfidence = &v.confidence
 	}
-	if data.SleepStateSNOMEDCode != nil {
-		result.SleepStage = data.SleepStateSNOMEDCode
-		result.SleepStageSource = "Radar"
-		timestamp := data.Timestamp.Unix()
-		result.SleepStageTimestamp = &timestamp
-	}
-}
-
-// fuseVitalSigns 融合生命体征（HR/RR）
-// 规则：优先 Sleepace，无数据则 Radar
-func (f *SensorFusion) fuseVitalSigns(
-	sleepaceData []*models.IoTTimeSeries,
-	radarData []*models.IoTTimeSeries,
-	result *models.RealtimeData,
-) {
-	// 优先使用 Sleepace 数据
-	if len(sleepaceData) > 0 {
-		for _, data := range sleepaceData {
-			if data.HeartRate != nil {
-				result.Heart = data.HeartRate
-				result.HeartSource = "Sleepace"
-				timestamp := data.Timestamp.Unix()
-				result.HeartTimestamp = &timestamp
-				break
-			}
-		}
-		for _, data := range sleepaceData {
-			if data.RespiratoryRate != nil {
-				result.Breath = data.RespiratoryRate
-				result.BreathSource = "Sleepace"
-				timestamp := data.Timestamp.Unix()
-				result.BreathTimestamp = &timestamp
-				break
-			}
-		}
+	if v, ok := fuseCode(sleepStage, minConfidence); ok {
+		code := v.code
+		timestamp := v.at.Unix()
+		result.SleepStage, result.SleepStageSource, result.SleepStageTimestamp = &code, v.source, &timestamp
+		result.SleepStageConfidence = &v.confidence
 	}
 	
-	// 如果 Sleepace 没有数据，使用 Radar 数据
-	if result.Heart == nil && len(radarData) > 0 {
-		for _, data := range radarData {
-			if data.HeartRate != nil {
-				result.Heart = data.HeartRate
-				result.HeartSource = "Radar"
-				timestamp := data.Timestamp.Unix()
-				result.HeartTimestamp = &timestamp
-				break
-			}
-		}
-	}
-	if result.Breath == nil && len(radarData) > 0 {
-		for _, data := range radarData {
-			if data.RespiratoryRate != nil {
-				result.Breath = data.RespiratoryRate
-				result.BreathSource = "Radar"
-				timestamp := data.Timestamp.Unix()
-				result.BreathTimestamp = &timestamp
-				break
-			}
-		}
-	}
+	// 处理姿态数据（直接使用 Radar 数据，不是融合）
+	f.useRadarPostures(radarFrames, result)
+	
+	return result
 }
 
-// fuseBedAndSleepStatus 融合床状态和睡眠状态
-// 规则：优先 Sleepace
-func (f *SensorFusion) fuseBedAndSleepStatus(
-	sleepaceData []*models.IoTTimeSeries,
-	radarData []*models.IoTTimeSeries,
-	result *models.RealtimeData,
-) {
-	// 优先使用 Sleepace 数据
-	if len(sleepaceData) > 0 {
-		for _, data := range sleepaceData {
-			if data.BedStatusSNOMEDCode != nil {
-				result.BedStatus = data.BedStatusSNOMEDCode
-				result.BedStatusSource = "Sleepace"
-				timestamp := data.Timestamp.Unix()
-				result.BedStatusTimestamp = &timestamp
-				break
-			}
-		}
-		for _, data := range sleepaceData {
-			if data.SleepStateSNOMEDCode != nil {
-				result.SleepStage = data.SleepStateSNOMEDCode
-				result.SleepStageSource = "Sleepace"
-				timestamp := data.Timestamp.Unix()
-				result.SleepStageTimestamp = &timestamp
-				break
-			}
-		}
-	}
-	
-	// 如果 Sleepace 没有数据，使用 Radar 数据（如果有）
-	if result.BedStatus == nil && len(radarData) > 0 {
-		for _, data := range radarData {
-			if data.BedStatusSNOMEDCode != nil {
-				result.BedStatus = data.BedStatusSNOMEDCode
-				result.BedStatusSource = "Radar"
-				timestamp := data.Timestamp.Unix()
-				result.BedStatusTimestamp = &timestamp
-				break
-			}
+// latestFrame 设备最新一帧的所有行（多目标雷达帧拆分出的各行）
+func latestFrame(rows []*models.IoTTimeSeries) []*models.IoTTimeSeries {
+	latest := rows[0]
+	for _, row := range rows[1:] {
+		if row.Timestamp.After(latest.Timestamp) {
+			latest = row
 		}
 	}
-	if result.SleepStage == nil && len(radarData) > 0 {
-		for _, data := range radarData {
-			if data.SleepStateSNOMEDCode != nil {
-				result.SleepStage = data.SleepStateSNOMEDCode
-				result.SleepStageSource = "Radar"
-				timestamp := data.Timestamp.Unix()
-				result.SleepStageTimestamp = &timestamp
-				break
-			}
+	key := frameKey(latest)
+	var frame []*models.IoTTimeSeries
+	for _, row := range rows {
+		if frameKey(row) == key {
+			frame = append(frame, row)
 		}
 	}
+	return frame
 }
 
 // useRadarPostures 使用 Radar 设备的姿态数据
@@ -0,0 +1,172 @@
+package fusion
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"wisefido-sensor-fusion/internal/models"
+)
+
+// StateStore 设备滑动窗口状态（内存）
+//
+// 由 iot:data:stream 消息直接更新，融合时只读内存；设备的窗口数据不完整时
+// （服务刚启动、收到不带样本的旧格式消息、长时间无数据被清理）从 iot_timeseries 加载一次。
+// 窗口完整的前提是本实例收到设备的全部消息：服务通过单实例租约保证只有一个实例消费输入流。
+type StateStore struct {
+	mu        sync.Mutex
+	devices   map[string]*deviceState
+	retention time.Duration // 保留时长（以设备最新样本时间为准）
+	maxFrames int           // 每个设备最多保留的帧数（与数据库窗口查询的帧数上限一致）
+}
+
+// deviceState 单个设备的窗口状态
+type deviceState struct {
+	rows        []*models.IoTTimeSeries // 按时间倒序
+	frames      int                     // rows 中的帧数
+	coveredFrom time.Time               // 该时间之后的数据在内存中完整
+}
+
+// NewStateStore 创建设备状态存储
+func NewStateStore(retention time.Duration, maxFrames int) *StateStore {
+	return &StateStore{
+		devices:   make(map[string]*deviceState),
+		retention: retention,
+		maxFrames: maxFrames,
+	}
+}
+
+// Observe 追加设备的一帧数据（多目标帧的所有行）
+// 新设备从该帧开始完整，更早的数据在首次融合时从数据库加载
+func (s *StateStore) Observe(deviceID string, frame []*models.IoTTimeSeries) {
+	if len(frame) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.devices[deviceID]
+	if !ok {
+		st = &deviceState{coveredFrom: frame[0].Timestamp}
+		s.devices[deviceID] = st
+	}
+	st.rows = append(st.rows, frame...)
+	st.sortAndTrim(s.retention, s.maxFrames)
+}
+
+// Forget 丢弃设备状态（下次融合时从数据库重新加载）
+func (s *StateStore) Forget(deviceID string) {
+	s.mu.Lock()
+	delete(s.devices, deviceID)
+	s.mu.Unlock()
+}
+
+// Window 返回设备在 since 之后的数据（按时间倒序）
+// complete 为 false 时内存中的数据不完整，调用方应从数据库加载后调用 Load
+func (s *StateStore) Window(deviceID string, since time.Time) (rows []*models.IoTTimeSeries, complete bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.devices[deviceID]
+	if !ok {
+		return nil, false
+	}
+	for _, row := range st.rows {
+		if row.Timestamp.Before(since) {
+			break
+		}
+		rows = append(rows, row)
+	}
+	// 已保留 maxFrames 帧时与数据库窗口查询结果相同
+	return rows, !st.coveredFrom.After(since) || (s.maxFrames > 0 && st.frames >= s.maxFrames)
+}
+
+// Load 合并从数据库加载的窗口数据（since 之后的数据在内存中完整）
+// 内存中比数据库最新行更新的数据（加载期间到达的消息）保留
+func (s *StateStore) Load(deviceID string, since time.Time, rows []*models.IoTTimeSeries) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.devices[deviceID]
+	if !ok {
+		st = &deviceState{}
+		s.devices[deviceID] = st
+	}
+	var newest time.Time
+	for _, row := range rows {
+		if row.Timestamp.After(newest) {
+			newest = row.Timestamp
+		}
+	}
+	merged := append([]*models.IoTTimeSeries(nil), rows...)
+	for _, row := range st.rows {
+		if row.Timestamp.After(newest) {
+			merged = append(merged, row)
+		}
+	}
+	st.rows = merged
+	st.frames = countFrames(merged)
+	st.coveredFrom = since
+	st.sortAndTrim(s.retention, s.maxFrames)
+}
+
+// Sweep 清理最新样本早于保留时长的设备（返回清理的设备数）
+func (s *StateStore) Sweep(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	horizon := now.Add(-s.retention)
+	removed := 0
+	for deviceID, st := range s.devices {
+		if len(st.rows) == 0 || st.rows[0].Timestamp.Before(horizon) {
+			delete(s.devices, deviceID)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len 当前保存状态的设备数
+func (s *StateStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.devices)
+}
+
+// sortAndTrim 按时间倒序排序，丢弃超过保留时长或帧数上限的旧数据
+func (st *deviceState) sortAndTrim(retention time.Duration, maxFrames int) {
+	sort.SliceStable(st.rows, func(i, j int) bool {
+		return st.rows[i].Timestamp.After(st.rows[j].Timestamp)
+	})
+	if len(st.rows) == 0 {
+		return
+	}
+
+	horizon := st.rows[0].Timestamp.Add(-retention)
+	frames := 0
+	var lastKey string
+	for i, row := range st.rows {
+		if key := frameKey(row); i == 0 || key != lastKey {
+			frames++
+			lastKey = key
+		}
+		if row.Timestamp.Before(horizon) || (maxFrames > 0 && frames > maxFrames) {
+			st.rows = st.rows[:i]
+			st.frames = frames - 1
+			// 丢弃的数据不再完整
+			if oldest := st.rows[i-1].Timestamp; oldest.After(st.coveredFrom) {
+				st.coveredFrom = oldest
+			}
+			return
+		}
+	}
+	st.frames = frames
+}
+
+// countFrames 统计帧数（多目标帧的各行算一帧）
+func countFrames(rows []*models.IoTTimeSeries) int {
+	keys := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		keys[frameKey(row)] = true
+	}
+	return len(keys)
+}
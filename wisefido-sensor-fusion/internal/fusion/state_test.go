@@ -0,0 +1,154 @@
+package fusion
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"wisefido-sensor-fusion/internal/models"
+)
+
+var t0 = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func row(id int, at time.Time) *models.IoTTimeSeries {
+	return &models.IoTTimeSeries{ID: strconv.Itoa(id), DeviceID: "dev-1", Timestamp: at}
+}
+
+func frameRow(id int, frameID string, at time.Time) *models.IoTTimeSeries {
+	r := row(id, at)
+	r.FrameID = &frameID
+	return r
+}
+
+func ids(rows []*models.IoTTimeSeries) []string {
+	out := make([]string, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func equalIDs(got []*models.IoTTimeSeries, want ...string) bool {
+	g := ids(got)
+	if len(g) != len(want) {
+		return false
+	}
+	for i := range g {
+		if g[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStateStore_UnknownDeviceIncomplete(t *testing.T) {
+	s := NewStateStore(5*time.Minute, 60)
+	if rows, complete := s.Window("dev-1", t0); rows != nil || complete {
+		t.Fatalf("unknown device: rows=%v complete=%v", rows, complete)
+	}
+}
+
+// 新设备只从第一条消息开始完整，更早的窗口需要从数据库加载
+func TestStateStore_ObserveCoversFromFirstFrame(t *testing.T) {
+	s := NewStateStore(5*time.Minute, 60)
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(1, t0)})
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(2, t0.Add(2*time.Second))})
+
+	if _, complete := s.Window("dev-1", t0.Add(-time.Minute)); complete {
+		t.Fatal("window before first observed frame must be incomplete")
+	}
+	rows, complete := s.Window("dev-1", t0)
+	if !complete || !equalIDs(rows, "2", "1") {
+		t.Fatalf("window = %v complete=%v", ids(rows), complete)
+	}
+	rows, _ = s.Window("dev-1", t0.Add(time.Second))
+	if !equalIDs(rows, "2") {
+		t.Fatalf("since filter: %v", ids(rows))
+	}
+}
+
+// 乱序到达的帧按时间倒序保存
+func TestStateStore_ObserveOutOfOrder(t *testing.T) {
+	s := NewStateStore(5*time.Minute, 60)
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(1, t0)})
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(3, t0.Add(3*time.Second))})
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(2, t0.Add(time.Second))})
+	rows, _ := s.Window("dev-1", t0)
+	if !equalIDs(rows, "3", "2", "1") {
+		t.Fatalf("window = %v", ids(rows))
+	}
+}
+
+func TestStateStore_LoadMergesNewerObserved(t *testing.T) {
+	s := NewStateStore(5*time.Minute, 60)
+	// 加载期间到达的消息
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(3, t0.Add(3*time.Second))})
+
+	since := t0.Add(-time.Minute)
+	s.Load("dev-1", since, []*models.IoTTimeSeries{row(2, t0.Add(time.Second)), row(1, t0)})
+	rows, complete := s.Window("dev-1", since)
+	if !complete || !equalIDs(rows, "3", "2", "1") {
+		t.Fatalf("window = %v complete=%v", ids(rows), complete)
+	}
+
+	// 数据库中已有的行不重复
+	s.Load("dev-1", since, []*models.IoTTimeSeries{row(3, t0.Add(3*time.Second)), row(2, t0.Add(time.Second))})
+	rows, _ = s.Window("dev-1", since)
+	if !equalIDs(rows, "3", "2") {
+		t.Fatalf("reload window = %v", ids(rows))
+	}
+}
+
+// 多目标帧的各行按一帧计数，帧数达到上限时窗口视为完整
+func TestStateStore_MaxFrames(t *testing.T) {
+	s := NewStateStore(5*time.Minute, 2)
+	s.Observe("dev-1", []*models.IoTTimeSeries{frameRow(1, "f1", t0), frameRow(2, "f1", t0)})
+	s.Observe("dev-1", []*models.IoTTimeSeries{frameRow(3, "f2", t0.Add(time.Second)), frameRow(4, "f2", t0.Add(time.Second))})
+	s.Observe("dev-1", []*models.IoTTimeSeries{frameRow(5, "f3", t0.Add(2*time.Second))})
+
+	rows, complete := s.Window("dev-1", t0.Add(-time.Hour))
+	if !equalIDs(rows, "5", "3", "4") {
+		t.Fatalf("window = %v", ids(rows))
+	}
+	if !complete {
+		t.Fatal("window holding maxFrames frames should be complete")
+	}
+}
+
+// 超过保留时长的数据被丢弃，丢弃后更早的窗口不再完整
+func TestStateStore_Retention(t *testing.T) {
+	s := NewStateStore(time.Minute, 0)
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(1, t0)})
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(2, t0.Add(30*time.Second))})
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(3, t0.Add(90*time.Second))})
+
+	rows, complete := s.Window("dev-1", t0)
+	if !equalIDs(rows, "3", "2") {
+		t.Fatalf("window = %v", ids(rows))
+	}
+	if complete {
+		t.Fatal("window reaching into trimmed data must be incomplete")
+	}
+	if _, complete := s.Window("dev-1", t0.Add(30*time.Second)); !complete {
+		t.Fatal("window inside retained data should be complete")
+	}
+}
+
+func TestStateStore_ForgetAndSweep(t *testing.T) {
+	s := NewStateStore(time.Minute, 60)
+	s.Observe("dev-1", []*models.IoTTimeSeries{row(1, t0)})
+	s.Observe("dev-2", []*models.IoTTimeSeries{row(2, t0.Add(time.Minute))})
+
+	s.Forget("dev-2")
+	if _, complete := s.Window("dev-2", t0); complete || s.Len() != 1 {
+		t.Fatalf("forgotten device should be reloaded, len=%d", s.Len())
+	}
+
+	s.Observe("dev-2", []*models.IoTTimeSeries{row(2, t0.Add(time.Minute))})
+	if removed := s.Sweep(t0.Add(90 * time.Second)); removed != 1 || s.Len() != 1 {
+		t.Fatalf("sweep removed %d, len=%d", removed, s.Len())
+	}
+	if _, complete := s.Window("dev-1", t0); complete {
+		t.Fatal("swept device should be reloaded")
+	}
+}
@@ -0,0 +1,303 @@
+package fusion
+
+import (
+	"math"
+	"sort"
+	"time"
+	"wisefido-sensor-fusion/internal/models"
+)
+
+// sourceOf 设备类型对应的数据源（不参与融合的设备类型返回空字符串）
+func sourceOf(deviceType string) string {
+	switch deviceType {
+	case "Sleepace", "SleepPad":
+		return SourceSleepace
+	case "Radar":
+		return SourceRadar
+	}
+	return ""
+}
+
+// estimate 单个设备在窗口内的估计值
+type estimate struct {
+	deviceID    string
+	source      string
+	value       float64   // 数值字段（心率 / 呼吸率）：窗口内可信样本的中位数
+	code        string    // 编码字段（床状态 / 睡眠状态）：最新样本的 SNOMED 编码
+	at          time.Time // 最新样本时间
+	reliability float64   // 信号质量 × 新鲜度（0~1）
+	weight      float64   // 数据源权重 × reliability
+}
+
+// fused 融合结果
+type fused struct {
+	value      float64
+	code       string
+	source     string    // 权重最大的一致数据源
+	at         time.Time // 一致数据源中最新样本时间
+	confidence float64   // 0~1
+}
+
+// frameKey 多目标雷达帧的各行属于同一帧，只计一次
+func frameKey(row *models.IoTTimeSeries) string {
+	if row.FrameID != nil {
+		return *row.FrameID
+	}
+	return row.ID
+}
+
+// freshnessFactor 新鲜度因子：样本越新越接近 1，超过新鲜度上限返回 false
+func freshnessFactor(at, now time.Time, freshness time.Duration) (float64, bool) {
+	age := now.Sub(at)
+	if age <= 0 {
+		return 1, true
+	}
+	if age > freshness {
+		return 0, false
+	}
+	return 1 - age.Seconds()/freshness.Seconds(), true
+}
+
+// estimateNumeric 估计设备在窗口内的数值字段
+//
+// 信号质量 = 可信样本比例（该字段质量标记不是 ok 的样本计为不可信）× 稳定度（窗口内波动相对容差越小越接近 1），
+// 最新可信样本超过新鲜度上限时该设备不参与融合。
+func estimateNumeric(
+	deviceID, source string,
+	rows []*models.IoTTimeSeries,
+	value func(*models.IoTTimeSeries) *int,
+	trusted func(*models.IoTTimeSeries) bool,
+	tolerance float64,
+	policy *Policy,
+	now time.Time,
+) (estimate, bool) {
+	sourceWeight := policy.sourceWeight(source)
+	if sourceWeight <= 0 {
+		return estimate{}, false
+	}
+
+	var values []float64
+	var latest time.Time
+	untrusted := 0
+	seen := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		key := frameKey(row)
+		if seen[key] {
+			continue
+		}
+		v := value(row)
+		if v == nil {
+			if !trusted(row) {
+				seen[key] = true
+				untrusted++
+			}
+			continue
+		}
+		seen[key] = true
+		values = append(values, float64(*v))
+		if row.Timestamp.After(latest) {
+			latest = row.Timestamp
+		}
+	}
+	if len(values) == 0 {
+		return estimate{}, false
+	}
+	fresh, ok := freshnessFactor(latest, now, policy.freshness())
+	if !ok {
+		return estimate{}, false
+	}
+
+	quality := float64(len(values)) / float64(len(values)+untrusted)
+	if tolerance > 0 {
+		quality /= 1 + stddev(values)/tolerance
+	}
+	reliability := quality * fresh
+	return estimate{
+		deviceID:    deviceID,
+		source:      source,
+		value:       median(values),
+		at:          latest,
+		reliability: reliability,
+		weight:      sourceWeight * reliability,
+	}, true
+}
+
+// estimateCode 估计设备在窗口内的编码字段（取最新样本）
+func estimateCode(
+	deviceID, source string,
+	rows []*models.IoTTimeSeries,
+	code func(*models.IoTTimeSeries) *string,
+	policy *Policy,
+	now time.Time,
+) (estimate, bool) {
+	sourceWeight := policy.sourceWeight(source)
+	if sourceWeight <= 0 {
+		return estimate{}, false
+	}
+
+	var latest *models.IoTTimeSeries
+	for _, row := range rows {
+		if code(row) != nil && (latest == nil || row.Timestamp.After(latest.Timestamp)) {
+			latest = row
+		}
+	}
+	if latest == nil {
+		return estimate{}, false
+	}
+	fresh, ok := freshnessFactor(latest.Timestamp, now, policy.freshness())
+	if !ok {
+		return estimate{}, false
+	}
+	return estimate{
+		deviceID:    deviceID,
+		source:      source,
+		code:        *code(latest),
+		at:          latest.Timestamp,
+		reliability: fresh,
+		weight:      sourceWeight * fresh,
+	}, true
+}
+
+// fuseNumeric 融合数值字段
+//
+// 以权重最大的一致组（与组中心差值不超过容差）加权平均，组外的数据源视为不一致。
+// 置信度 = 一致组权重占比 × 一致组中至少一个数据源可靠的概率（多个数据源一致时置信度更高）。
+func fuseNumeric(estimates []estimate, tolerance float64, minConfidence float64) (fused, bool) {
+	if len(estimates) == 0 {
+		return fused{}, false
+	}
+	sortEstimates(estimates)
+
+	// 选择支持权重最大的中心（权重相同时取排序靠前的数据源）
+	center, bestSupport := 0, -1.0
+	for i, anchor := range estimates {
+		support := 0.0
+		for _, e := range estimates {
+			if math.Abs(e.value-anchor.value) <= tolerance {
+				support += e.weight
+			}
+		}
+		if support > bestSupport {
+			center, bestSupport = i, support
+		}
+	}
+
+	var total, agreeing, weighted float64
+	unreliable := 1.0
+	var result fused
+	var lead *estimate
+	for i := range estimates {
+		e := &estimates[i]
+		total += e.weight
+		if math.Abs(e.value-estimates[center].value) > tolerance {
+			continue
+		}
+		agreeing += e.weight
+		weighted += e.weight * e.value
+		unreliable *= 1 - e.reliability
+		if lead == nil {
+			lead = e
+		}
+		if e.at.After(result.at) {
+			result.at = e.at
+		}
+	}
+	if total <= 0 || agreeing <= 0 {
+		return fused{}, false
+	}
+	result.value = weighted / agreeing
+	result.source = lead.source
+	result.confidence = roundConfidence(agreeing / total * (1 - unreliable))
+	if result.confidence < minConfidence {
+		return fused{}, false
+	}
+	return result, true
+}
+
+// fuseCode 融合编码字段（按数据源权重投票）
+func fuseCode(estimates []estimate, minConfidence float64) (fused, bool) {
+	if len(estimates) == 0 {
+		return fused{}, false
+	}
+	sortEstimates(estimates)
+
+	support := make(map[string]float64)
+	total := 0.0
+	for _, e := range estimates {
+		support[e.code] += e.weight
+		total += e.weight
+	}
+	// 得票相同时取排序靠前（权重更大）的数据源的编码
+	winner := estimates[0].code
+	for _, e := range estimates {
+		if support[e.code] > support[winner] {
+			winner = e.code
+		}
+	}
+	if total <= 0 {
+		return fused{}, false
+	}
+
+	result := fused{code: winner}
+	unreliable := 1.0
+	for _, e := range estimates {
+		if e.code != winner {
+			continue
+		}
+		if result.source == "" {
+			result.source = e.source
+		}
+		if e.at.After(result.at) {
+			result.at = e.at
+		}
+		unreliable *= 1 - e.reliability
+	}
+	result.confidence = roundConfidence(support[winner] / total * (1 - unreliable))
+	if result.confidence < minConfidence {
+		return fused{}, false
+	}
+	return result, true
+}
+
+// sortEstimates 按权重降序排序（权重相同时按设备 ID，保证结果稳定）
+func sortEstimates(estimates []estimate) {
+	sort.SliceStable(estimates, func(i, j int) bool {
+		if estimates[i].weight != estimates[j].weight {
+			return estimates[i].weight > estimates[j].weight
+		}
+		return estimates[i].deviceID < estimates[j].deviceID
+	})
+}
+
+// median 中位数（对单个异常值不敏感）
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// stddev 标准差
+func stddev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+// roundConfidence 置信度保留两位小数
+func roundConfidence(c float64) float64 {
+	return math.Round(c*100) / 100
+}
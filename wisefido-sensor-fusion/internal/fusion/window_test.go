@@ -0,0 +1,295 @@
+package fusion
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"wisefido-sensor-fusion/internal/models"
+)
+
+func hrRow(id int, at time.Time, hr int) *models.IoTTimeSeries {
+	r := row(id, at)
+	r.HeartRate = &hr
+	return r
+}
+
+func heartRate(r *models.IoTTimeSeries) *int { return r.HeartRate }
+
+func bedStatus(r *models.IoTTimeSeries) *string { return r.BedStatusSNOMEDCode }
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestFreshnessFactor(t *testing.T) {
+	tests := []struct {
+		name   string
+		age    time.Duration
+		want   float64
+		wantOK bool
+	}{
+		{"future sample", -time.Second, 1, true},
+		{"now", 0, 1, true},
+		{"half of freshness", 15 * time.Second, 0.5, true},
+		{"at freshness limit", 30 * time.Second, 0, true},
+		{"stale", 31 * time.Second, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := freshnessFactor(t0.Add(-tt.age), t0, 30*time.Second)
+			if ok != tt.wantOK || !approx(got, tt.want) {
+				t.Fatalf("freshnessFactor = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEstimateNumeric(t *testing.T) {
+	policy := DefaultPolicy(60, 30, 0.5)
+	untrusted := row(9, t0)
+	untrusted.HeartRateQuality = "low"
+
+	tests := []struct {
+		name     string
+		source   string
+		rows     []*models.IoTTimeSeries
+		policy   *Policy
+		wantOK   bool
+		want     float64
+		wantRel  float64
+		wantWgt  float64
+		wantTime time.Time
+	}{
+		{
+			name:   "median of trusted samples",
+			source: SourceSleepace,
+			rows:   []*models.IoTTimeSeries{hrRow(1, t0, 60), hrRow(2, t0.Add(-time.Second), 60), hrRow(3, t0.Add(-2*time.Second), 60)},
+			policy: policy, wantOK: true, want: 60, wantRel: 1, wantWgt: 1, wantTime: t0,
+		},
+		{
+			// 可信比例 2/3，新鲜度 0.5，雷达权重 0.6
+			name:   "untrusted and aged samples lower reliability",
+			source: SourceRadar,
+			rows:   []*models.IoTTimeSeries{hrRow(1, t0.Add(-15*time.Second), 70), hrRow(2, t0.Add(-20*time.Second), 70), untrusted},
+			policy: policy, wantOK: true, want: 70, wantRel: 1.0 / 3, wantWgt: 0.2, wantTime: t0.Add(-15 * time.Second),
+		},
+		{
+			// 同一帧的多行只计一次：样本为 70、80
+			name:   "frame rows counted once",
+			source: SourceSleepace,
+			rows: []*models.IoTTimeSeries{
+				func() *models.IoTTimeSeries { r := frameRow(1, "f1", t0); hr := 70; r.HeartRate = &hr; return r }(),
+				func() *models.IoTTimeSeries { r := frameRow(2, "f1", t0); hr := 70; r.HeartRate = &hr; return r }(),
+				hrRow(3, t0, 80),
+			},
+			policy: policy, wantOK: true, want: 75, wantRel: 1 / (1 + 5.0/8), wantWgt: 1 / (1 + 5.0/8), wantTime: t0,
+		},
+		{
+			name:   "stale device excluded",
+			source: SourceSleepace,
+			rows:   []*models.IoTTimeSeries{hrRow(1, t0.Add(-31*time.Second), 60)},
+			policy: policy,
+		},
+		{
+			name:   "zero weight source excluded",
+			source: SourceRadar,
+			rows:   []*models.IoTTimeSeries{hrRow(1, t0, 60)},
+			policy: (&Policy{Sources: map[string]float64{SourceRadar: 0}}).merge(policy),
+		},
+		{
+			name:   "no values",
+			source: SourceSleepace,
+			rows:   []*models.IoTTimeSeries{row(1, t0), untrusted},
+			policy: policy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := estimateNumeric("dev-1", tt.source, tt.rows, heartRate, (*models.IoTTimeSeries).HeartRateTrusted,
+				tt.policy.HeartRateTolerance, tt.policy, t0)
+			if ok != tt.wantOK {
+				t.Fatalf("estimateNumeric ok = %v, want %v (%+v)", ok, tt.wantOK, e)
+			}
+			if !ok {
+				return
+			}
+			if !approx(e.value, tt.want) || !approx(e.reliability, tt.wantRel) || !approx(e.weight, tt.wantWgt) || !e.at.Equal(tt.wantTime) {
+				t.Fatalf("estimate = %+v; want value %v reliability %v weight %v at %v", e, tt.want, tt.wantRel, tt.wantWgt, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestEstimateCode(t *testing.T) {
+	policy := DefaultPolicy(60, 30, 0.5)
+	code := func(id int, at time.Time, c string) *models.IoTTimeSeries {
+		r := row(id, at)
+		r.BedStatusSNOMEDCode = &c
+		return r
+	}
+
+	rows := []*models.IoTTimeSeries{code(1, t0.Add(-15*time.Second), "in-bed"), row(2, t0), code(3, t0.Add(-20*time.Second), "out-of-bed")}
+	e, ok := estimateCode("dev-1", SourceRadar, rows, bedStatus, policy, t0)
+	if !ok || e.code != "in-bed" || !approx(e.reliability, 0.5) || !approx(e.weight, 0.3) {
+		t.Fatalf("estimateCode = %+v, %v", e, ok)
+	}
+
+	stale := []*models.IoTTimeSeries{code(1, t0.Add(-time.Minute), "in-bed")}
+	if e, ok := estimateCode("dev-1", SourceRadar, stale, bedStatus, policy, t0); ok {
+		t.Fatalf("stale code should be excluded, got %+v", e)
+	}
+	noRadar := (&Policy{Sources: map[string]float64{SourceRadar: 0}}).merge(policy)
+	if e, ok := estimateCode("dev-1", SourceRadar, rows, bedStatus, noRadar, t0); ok {
+		t.Fatalf("zero weight source should be excluded, got %+v", e)
+	}
+}
+
+func est(deviceID, source string, value, reliability, weight float64) estimate {
+	return estimate{deviceID: deviceID, source: source, value: value, at: t0, reliability: reliability, weight: weight}
+}
+
+func TestFuseNumeric(t *testing.T) {
+	tests := []struct {
+		name          string
+		estimates     []estimate
+		minConfidence float64
+		wantOK        bool
+		want          float64
+		wantSource    string
+		wantConf      float64
+	}{
+		{
+			name:      "single source",
+			estimates: []estimate{est("pad", SourceSleepace, 60, 0.9, 0.9)},
+			wantOK:    true, want: 60, wantSource: SourceSleepace, wantConf: 0.9,
+		},
+		{
+			// 一致的数据源加权平均，置信度高于任一数据源
+			name:      "agreeing sources",
+			estimates: []estimate{est("radar", SourceRadar, 64, 0.5, 0.3), est("pad", SourceSleepace, 60, 0.8, 0.8)},
+			wantOK:    true, want: (0.8*60 + 0.3*64) / 1.1, wantSource: SourceSleepace, wantConf: 0.9,
+		},
+		{
+			// 不一致的数据源不参与平均，并降低置信度：0.8/1.1 × 0.8
+			name:      "disagreeing sources",
+			estimates: []estimate{est("pad", SourceSleepace, 60, 0.8, 0.8), est("radar", SourceRadar, 90, 0.5, 0.3)},
+			wantOK:    true, want: 60, wantSource: SourceSleepace, wantConf: 0.58,
+		},
+		{
+			// 两个一致的低权重数据源支持权重更大：0.6/1.1 × 0.75
+			name: "agreeing group outweighs single source",
+			estimates: []estimate{
+				est("pad", SourceSleepace, 60, 0.5, 0.5),
+				est("radar-1", SourceRadar, 90, 0.5, 0.3),
+				est("radar-2", SourceRadar, 92, 0.5, 0.3),
+			},
+			wantOK: true, want: 91, wantSource: SourceRadar, wantConf: 0.41,
+		},
+		{
+			name:          "below min confidence",
+			estimates:     []estimate{est("pad", SourceSleepace, 60, 0.8, 0.8), est("radar", SourceRadar, 90, 0.5, 0.3)},
+			minConfidence: 0.6,
+		},
+		{
+			name:      "zero weight sources",
+			estimates: []estimate{est("pad", SourceSleepace, 60, 0, 0)},
+		},
+		{
+			name: "no sources",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := fuseNumeric(tt.estimates, 8, tt.minConfidence)
+			if ok != tt.wantOK {
+				t.Fatalf("fuseNumeric ok = %v, want %v (%+v)", ok, tt.wantOK, got)
+			}
+			if !ok {
+				return
+			}
+			if !approx(got.value, tt.want) || got.source != tt.wantSource || got.confidence != tt.wantConf {
+				t.Fatalf("fuseNumeric = %+v; want value %v source %s confidence %v", got, tt.want, tt.wantSource, tt.wantConf)
+			}
+		})
+	}
+}
+
+// 融合时间为一致数据源中最新的样本时间
+func TestFuseNumeric_LatestAgreeingTime(t *testing.T) {
+	a := est("pad", SourceSleepace, 60, 0.8, 0.8)
+	b := est("radar-1", SourceRadar, 62, 0.5, 0.3)
+	b.at = t0.Add(time.Second)
+	c := est("radar-2", SourceRadar, 90, 0.5, 0.3)
+	c.at = t0.Add(2 * time.Second)
+	got, ok := fuseNumeric([]estimate{a, b, c}, 8, 0)
+	if !ok || !got.at.Equal(t0.Add(time.Second)) {
+		t.Fatalf("fused at = %v, %v; want %v", got.at, ok, t0.Add(time.Second))
+	}
+}
+
+func TestFuseCode(t *testing.T) {
+	code := func(deviceID, source, c string, reliability, weight float64) estimate {
+		e := est(deviceID, source, 0, reliability, weight)
+		e.code = c
+		return e
+	}
+	tests := []struct {
+		name          string
+		estimates     []estimate
+		minConfidence float64
+		wantOK        bool
+		want          string
+		wantSource    string
+		wantConf      float64
+	}{
+		{
+			name:      "weighted vote",
+			estimates: []estimate{code("radar", SourceRadar, "out", 0.5, 0.3), code("pad", SourceSleepace, "in", 0.8, 0.8)},
+			wantOK:    true, want: "in", wantSource: SourceSleepace, wantConf: 0.58,
+		},
+		{
+			name: "agreeing sources outvote heavier source",
+			estimates: []estimate{
+				code("pad", SourceSleepace, "in", 0.8, 0.8),
+				code("radar-1", SourceRadar, "out", 0.5, 0.5),
+				code("radar-2", SourceRadar, "out", 0.5, 0.5),
+			},
+			wantOK: true, want: "out", wantSource: SourceRadar, wantConf: 0.42,
+		},
+		{
+			// 得票相同时取排序靠前的数据源
+			name:      "tie broken by order",
+			estimates: []estimate{code("b", SourceRadar, "out", 0.5, 0.5), code("a", SourceRadar, "in", 0.5, 0.5)},
+			wantOK:    true, want: "in", wantSource: SourceRadar, wantConf: 0.25,
+		},
+		{
+			name: "below min confidence",
+			estimates: []estimate{
+				code("pad", SourceSleepace, "in", 0.8, 0.8),
+				code("radar-1", SourceRadar, "out", 0.5, 0.5),
+				code("radar-2", SourceRadar, "out", 0.5, 0.5),
+			},
+			minConfidence: 0.5,
+		},
+		{
+			name:      "zero weight sources",
+			estimates: []estimate{code("pad", SourceSleepace, "in", 0, 0)},
+		},
+		{
+			name: "no sources",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := fuseCode(tt.estimates, tt.minConfidence)
+			if ok != tt.wantOK {
+				t.Fatalf("fuseCode ok = %v, want %v (%+v)", ok, tt.wantOK, got)
+			}
+			if !ok {
+				return
+			}
+			if got.code != tt.want || got.source != tt.wantSource || got.confidence != tt.wantConf {
+				t.Fatalf("fuseCode = %+v; want code %s source %s confidence %v", got, tt.want, tt.wantSource, tt.wantConf)
+			}
+		})
+	}
+}
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	"owl-common/events"
+)
+
 // IoTDataMessage iot:data:stream 消息格式
 // 这是从 wisefido-data-transformer 发布到 iot:data:stream 的消息格式
 type IoTDataMessage struct {
@@ -10,5 +16,11 @@ type IoTDataMessage struct {
 	Timestamp       int64  `json:"timestamp"`
 	DataType        string `json:"data_type"`   // "observation" or "alarm"
 	Category        string `json:"category"`    // FHIR Category
+	TraceID         string `json:"-"`           // 信封 trace id（旧格式消息为空）
+	
+	// 信封携带的标准化数据（旧格式消息为空）
+	EventTime time.Time          `json:"-"` // 信封事件时间
+	FrameID   string             `json:"-"` // 多目标帧 ID
+	Samples   []events.IoTSample `json:"-"` // 每行一条，多目标帧按目标顺序
 }
 
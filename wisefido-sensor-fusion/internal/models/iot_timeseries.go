@@ -4,6 +4,9 @@ import (
 	"time"
 )
 
+// QualityOK 数据质量正常（iot_timeseries.quality_flag）
+const QualityOK = "ok"
+
 // IoTTimeSeries IoT 时序数据（从 PostgreSQL 读取）
 type IoTTimeSeries struct {
 	ID                string    `json:"id"`
@@ -24,6 +27,11 @@ type IoTTimeSeries struct {
 	PostureDisplay     *string `json:"posture_display"`
 	TrackingID         *string `json:"tracking_id"` // Radar 设备的 tracking_id
 	
+	// 雷达目标位置（cm，仅来自 iot:data:stream 消息的样本，用于轨迹跟踪，不从数据库读取）
+	RadarPosX          *int    `json:"radar_pos_x,omitempty"`
+	RadarPosY          *int    `json:"radar_pos_y,omitempty"`
+	RadarPosZ          *int    `json:"radar_pos_z,omitempty"`
+	
 	// 床状态
 	BedStatusSNOMEDCode *string `json:"bed_status_snomed_code"`
 	BedStatusDisplay    *string `json:"bed_status_display"`
@@ -32,10 +40,50 @@ type IoTTimeSeries struct {
 	SleepStateSNOMEDCode *string `json:"sleep_state_snomed_code"`
 	SleepStateDisplay    *string `json:"sleep_state_display"`
 	
+	// 多目标帧 ID（雷达一帧多个目标时各行相同）
+	FrameID             *string `json:"frame_id,omitempty"`
+	
+	// 数据质量标记（数据转换服务写入）：QualityFlag 为整行最严重的标记，
+	// HeartRateQuality / RespiratoryRateQuality 为各生命体征自己的标记（旧数据为空，回退到 QualityFlag）
+	QualityFlag            string `json:"quality_flag"`
+	HeartRateQuality       string `json:"hr_quality,omitempty"`
+	RespiratoryRateQuality string `json:"rr_quality,omitempty"`
+	
 	// 设备类型（从 devices 表查询）
 	DeviceType          string  `json:"device_type"` // "Radar" 或 "Sleepace"
 }
 
+// HeartRateTrusted 心率是否可信
+func (d *IoTTimeSeries) HeartRateTrusted() bool {
+	return vitalQuality(d.HeartRateQuality, d.QualityFlag) == QualityOK
+}
+
+// RespiratoryRateTrusted 呼吸率是否可信
+func (d *IoTTimeSeries) RespiratoryRateTrusted() bool {
+	return vitalQuality(d.RespiratoryRateQuality, d.QualityFlag) == QualityOK
+}
+
+// DropUntrustedVitals 清空质量标记不是 ok 的生命体征（各项单独判断，姿态、床状态等仍可使用）
+func (d *IoTTimeSeries) DropUntrustedVitals() {
+	if !d.HeartRateTrusted() {
+		d.HeartRate, d.HeartRateCode, d.HeartRateDisplay = nil, nil, nil
+	}
+	if !d.RespiratoryRateTrusted() {
+		d.RespiratoryRate, d.RespiratoryRateCode, d.RespiratoryRateDisplay = nil, nil, nil
+	}
+}
+
+// vitalQuality 生命体征的质量标记，没有单独标记的旧数据使用整行标记
+func vitalQuality(vital, row string) string {
+	if vital != "" {
+		return vital
+	}
+	if row != "" {
+		return row
+	}
+	return QualityOK
+}
+
 // RealtimeData 融合后的实时数据（写入 Redis）
 type RealtimeData struct {
 	// 生命体征
@@ -45,6 +93,8 @@ type RealtimeData struct {
 	BreathSource string  `json:"breath_source"` // 数据来源："Sleepace" 或 "Radar"
 	HeartTimestamp *int64 `json:"heart_timestamp,omitempty"` // 心率数据的时间戳
 	BreathTimestamp *int64 `json:"breath_timestamp,omitempty"` // 呼吸率数据的时间戳
+	HeartConfidence *float64 `json:"heart_confidence,omitempty"` // 心率融合置信度（0~1）
+	BreathConfidence *float64 `json:"breath_confidence,omitempty"` // 呼吸率融合置信度（0~1）
 	
 	// 睡眠状态
 	SleepStage   *string `json:"sleep_stage"`   // SNOMED 编码
@@ -53,6 +103,8 @@ type RealtimeData struct {
 	BedStatusSource string `json:"bed_status_source,omitempty"` // 床状态数据来源
 	SleepStageTimestamp *int64 `json:"sleep_stage_timestamp,omitempty"` // 睡眠状态数据的时间戳
 	BedStatusTimestamp *int64 `json:"bed_status_timestamp,omitempty"` // 床状态数据的时间戳
+	SleepStageConfidence *float64 `json:"sleep_stage_confidence,omitempty"` // 睡眠状态融合置信度（0~1）
+	BedStatusConfidence *float64 `json:"bed_status_confidence,omitempty"` // 床状态融合置信度（0~1）
 	
 	// 姿态数据（来自 Radar）
 	PersonCount  int     `json:"person_count"`  // 人数（tracking_id 数量）
@@ -60,6 +112,17 @@ type RealtimeData struct {
 	
 	// 时间戳
 	Timestamp    int64   `json:"timestamp"`    // Unix 时间戳（融合结果的时间戳，使用数据中的最大时间戳）
+	
+	// 按床位融合的结果（ActiveBed 卡片，每张床一项；顶层字段为卡片自身床位的结果，姿态为所有床位的合并）
+	Beds         []BedRealtime `json:"beds,omitempty"`
+}
+
+// BedRealtime 单张床位的融合结果
+type BedRealtime struct {
+	BedID      string  `json:"bed_id"`
+	BedName    string  `json:"bed_name,omitempty"`
+	ResidentID *string `json:"resident_id,omitempty"` // 床位绑定的住户（未绑定住户时为空）
+	RealtimeData
 }
 
 // Posture 姿态数据
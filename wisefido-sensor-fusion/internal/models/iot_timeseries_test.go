@@ -0,0 +1,29 @@
+package models
+
+import "testing"
+
+func TestIoTTimeSeries_DropUntrustedVitals(t *testing.T) {
+	tests := []struct {
+		name             string
+		row, hr, rr      string
+		keepsHR, keepsRR bool
+	}{
+		{"all ok", QualityOK, QualityOK, QualityOK, true, true},
+		{"heart rate outlier keeps respiratory rate", "outlier", "outlier", QualityOK, false, true},
+		{"respiratory rate out of range keeps heart rate", "out_of_range", QualityOK, "out_of_range", true, false},
+		{"device state drops both", "device_init", "device_init", "device_init", false, false},
+		{"legacy row without vital flags", "outlier", "", "", false, false},
+		{"legacy ok row", QualityOK, "", "", true, true},
+		{"no flags at all", "", "", "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr, rr := 70, 16
+			row := &IoTTimeSeries{HeartRate: &hr, RespiratoryRate: &rr, QualityFlag: tt.row, HeartRateQuality: tt.hr, RespiratoryRateQuality: tt.rr}
+			row.DropUntrustedVitals()
+			if (row.HeartRate != nil) != tt.keepsHR || (row.RespiratoryRate != nil) != tt.keepsRR {
+				t.Fatalf("heart_rate kept = %v, respiratory_rate kept = %v", row.HeartRate != nil, row.RespiratoryRate != nil)
+			}
+		})
+	}
+}
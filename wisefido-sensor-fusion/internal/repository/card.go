@@ -3,14 +3,21 @@ package repository
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"owl-common/cache"
 )
 
+// ErrCardNotFound 设备未关联到卡片（未绑定或卡片尚未创建）
+var ErrCardNotFound = errors.New("card not found")
+
 // CardRepository 卡片仓库
 type CardRepository struct {
-	db     *sql.DB
-	logger *zap.Logger
+	db            *sql.DB
+	cardCache     *cache.TTL[*CardInfo]     // tenant_id|device_id -> 卡片（见 card_cache.go）
+	topologyCache *cache.TTL[*CardTopology] // card_id -> 卡片拓扑
+	logger        *zap.Logger
 }
 
 // NewCardRepository 创建卡片仓库
@@ -128,7 +135,7 @@ func (r *CardRepository) GetCardByDeviceID(tenantID, deviceID string) (*CardInfo
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("card not found for device: %s", deviceID)
+			return nil, fmt.Errorf("%w for device: %s", ErrCardNotFound, deviceID)
 		}
 		return nil, fmt.Errorf("failed to query card: %w", err)
 	}
@@ -217,6 +224,41 @@ func (r *CardRepository) GetCardDevices(cardID string) ([]DeviceInfo, error) {
 	return devices, nil
 }
 
+// GetCardTopology 获取卡片关联的设备、住户和主住户（从 cards.devices / cards.residents JSONB 字段）
+// 融合按床位分组时用于确定床位与住户的对应关系
+func (r *CardRepository) GetCardTopology(cardID string) (*CardTopology, error) {
+	query := `
+		SELECT devices, residents, resident_id
+		FROM cards
+		WHERE card_id = $1
+	`
+	
+	var devicesJSON, residentsJSON []byte
+	var residentID sql.NullString
+	err := r.db.QueryRow(query, cardID).Scan(&devicesJSON, &residentsJSON, &residentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("card not found: %s", cardID)
+		}
+		return nil, fmt.Errorf("failed to query card topology: %w", err)
+	}
+	
+	topology := &CardTopology{}
+	if err := json.Unmarshal(devicesJSON, &topology.Devices); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal devices JSON: %w", err)
+	}
+	if len(residentsJSON) > 0 {
+		if err := json.Unmarshal(residentsJSON, &topology.Residents); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal residents JSON: %w", err)
+		}
+	}
+	if residentID.Valid {
+		topology.PrimaryResidentID = &residentID.String
+	}
+	
+	return topology, nil
+}
+
 // CardInfo 卡片信息
 type CardInfo struct {
 	CardID   string
@@ -239,3 +281,36 @@ type DeviceInfo struct {
 	UnitID      string  `json:"unit_id"`                // 设备绑定的单元ID
 }
 
+
+// ResidentInfo 住户信息（从 cards.residents JSONB 解析）
+type ResidentInfo struct {
+	ResidentID string  `json:"resident_id"`
+	Nickname   string  `json:"nickname"`
+	BedID      *string `json:"bed_id,omitempty"` // 住户绑定的床ID（旧卡片没有该字段）
+}
+
+// CardTopology 卡片拓扑（设备、住户）
+type CardTopology struct {
+	Devices           []DeviceInfo
+	Residents         []ResidentInfo
+	PrimaryResidentID *string // cards.resident_id（ActiveBed 卡片床位的住户）
+}
+
+// ResidentForBed 床位绑定的住户
+// 优先按住户的 bed_id 匹配；卡片自身的床位（primaryBedID）没有匹配时使用卡片主住户（已绑定到其他床位的除外）
+func (t *CardTopology) ResidentForBed(bedID string, primaryBedID *string) *string {
+	for i := range t.Residents {
+		if t.Residents[i].BedID != nil && *t.Residents[i].BedID == bedID {
+			return &t.Residents[i].ResidentID
+		}
+	}
+	if primaryBedID == nil || *primaryBedID != bedID || t.PrimaryResidentID == nil {
+		return nil
+	}
+	for _, r := range t.Residents {
+		if r.ResidentID == *t.PrimaryResidentID && r.BedID != nil && *r.BedID != bedID {
+			return nil
+		}
+	}
+	return t.PrimaryResidentID
+}
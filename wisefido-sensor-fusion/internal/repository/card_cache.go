@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"owl-common/cache"
+	"owl-common/events"
+)
+
+// EnableCache 启用卡片拓扑缓存（设备 -> 卡片、卡片 -> 设备 / 住户）
+// ttl 为已知卡片的缓存时间，negativeTTL 为未关联卡片的设备的缓存时间（0 表示不缓存）
+func (r *CardRepository) EnableCache(ttl, negativeTTL time.Duration) {
+	if ttl <= 0 && negativeTTL <= 0 {
+		return
+	}
+	r.cardCache = cache.NewTTL[*CardInfo](ttl, negativeTTL)
+	r.topologyCache = cache.NewTTL[*CardTopology](ttl, 0)
+}
+
+// ResolveCard 查询设备关联的卡片（带缓存，直到过期或收到卡片变更通知）
+func (r *CardRepository) ResolveCard(tenantID, deviceID string) (*CardInfo, error) {
+	if r.cardCache == nil {
+		return r.GetCardByDeviceID(tenantID, deviceID)
+	}
+	key := tenantID + "|" + deviceID
+	if card, missing, ok := r.cardCache.Get(key); ok {
+		if missing {
+			return nil, ErrCardNotFound
+		}
+		return card, nil
+	}
+
+	card, err := r.GetCardByDeviceID(tenantID, deviceID)
+	if err != nil {
+		// 只缓存“未关联卡片”，数据库错误不缓存
+		if errors.Is(err, ErrCardNotFound) {
+			r.cardCache.SetMissing(key)
+		}
+		return nil, err
+	}
+	r.cardCache.Set(key, card)
+	return card, nil
+}
+
+// ResolveCardTopology 查询卡片关联的设备和住户（带缓存，直到过期或收到卡片变更通知）
+func (r *CardRepository) ResolveCardTopology(cardID string) (*CardTopology, error) {
+	if r.topologyCache == nil {
+		return r.GetCardTopology(cardID)
+	}
+	if topology, _, ok := r.topologyCache.Get(cardID); ok {
+		return topology, nil
+	}
+
+	topology, err := r.GetCardTopology(cardID)
+	if err != nil {
+		return nil, err
+	}
+	r.topologyCache.Set(cardID, topology)
+	return topology, nil
+}
+
+// Invalidate 根据卡片变更通知失效缓存
+// 单元的卡片重建后 card_id 会变化，失效该单元的所有卡片及其拓扑
+func (r *CardRepository) Invalidate(change events.CardChange) {
+	if r.cardCache == nil {
+		return
+	}
+	if change.IsBulk() {
+		r.cardCache.Purge()
+		r.topologyCache.Purge()
+		return
+	}
+
+	inUnit := func(unitID *string) bool {
+		return unitID != nil && *unitID == change.UnitID
+	}
+	var cardIDs []string
+	r.cardCache.DeleteFunc(func(_ string, card *CardInfo) bool {
+		if card.TenantID == change.TenantID && inUnit(card.UnitID) {
+			cardIDs = append(cardIDs, card.CardID)
+			return true
+		}
+		return false
+	})
+	r.topologyCache.Delete(cardIDs...)
+	r.topologyCache.DeleteFunc(func(_ string, topology *CardTopology) bool {
+		for _, d := range topology.Devices {
+			if d.UnitID == change.UnitID {
+				return true
+			}
+		}
+		return false
+	})
+
+	if r.logger != nil {
+		r.logger.Debug("Card cache invalidated",
+			zap.String("tenant_id", change.TenantID),
+			zap.String("unit_id", change.UnitID),
+			zap.Int("cards", len(cardIDs)),
+		)
+	}
+}
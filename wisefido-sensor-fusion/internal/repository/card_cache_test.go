@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+	"owl-common/events"
+)
+
+func newCachedCardRepo(t *testing.T) (*CardRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	repo := NewCardRepository(db, zap.NewNop())
+	repo.EnableCache(time.Minute, time.Minute)
+	return repo, mock
+}
+
+func expectCard(mock sqlmock.Sqlmock, deviceID, cardID, unitID string) {
+	mock.ExpectQuery("FROM cards").WithArgs(deviceID, "t1").
+		WillReturnRows(sqlmock.NewRows([]string{"card_id", "tenant_id", "card_type", "bed_id", "unit_id"}).
+			AddRow(cardID, "t1", "ActiveBed", "bed-1", unitID))
+}
+
+func TestCardRepository_ResolveCardCached(t *testing.T) {
+	repo, mock := newCachedCardRepo(t)
+	expectCard(mock, "dev-1", "card-1", "unit-1")
+
+	for i := 0; i < 3; i++ {
+		card, err := repo.ResolveCard("t1", "dev-1")
+		if err != nil || card.CardID != "card-1" {
+			t.Fatalf("ResolveCard #%d = %+v, %v", i, card, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected a single query: %v", err)
+	}
+}
+
+// 未关联卡片的设备负缓存，数据库错误不缓存
+func TestCardRepository_ResolveCardNegative(t *testing.T) {
+	repo, mock := newCachedCardRepo(t)
+	mock.ExpectQuery("FROM cards").WithArgs("dev-x", "t1").
+		WillReturnRows(sqlmock.NewRows([]string{"card_id", "tenant_id", "card_type", "bed_id", "unit_id"}))
+	for i := 0; i < 2; i++ {
+		if _, err := repo.ResolveCard("t1", "dev-x"); !errors.Is(err, ErrCardNotFound) {
+			t.Fatalf("ResolveCard #%d error = %v, want ErrCardNotFound", i, err)
+		}
+	}
+
+	mock.ExpectQuery("FROM cards").WithArgs("dev-2", "t1").WillReturnError(errors.New("connection reset"))
+	expectCard(mock, "dev-2", "card-2", "unit-1")
+	if _, err := repo.ResolveCard("t1", "dev-2"); err == nil || errors.Is(err, ErrCardNotFound) {
+		t.Fatalf("expected database error, got %v", err)
+	}
+	if card, err := repo.ResolveCard("t1", "dev-2"); err != nil || card.CardID != "card-2" {
+		t.Fatalf("database error must not be cached, got %+v, %v", card, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCardRepository_ResolveCardTopologyCached(t *testing.T) {
+	repo, mock := newCachedCardRepo(t)
+	mock.ExpectQuery("SELECT devices, residents, resident_id").WithArgs("card-1").
+		WillReturnRows(sqlmock.NewRows([]string{"devices", "residents", "resident_id"}).
+			AddRow([]byte(`[{"device_id":"dev-1","device_type":"Radar","unit_id":"unit-1"}]`), []byte(`[]`), "res-1"))
+
+	for i := 0; i < 2; i++ {
+		topology, err := repo.ResolveCardTopology("card-1")
+		if err != nil || len(topology.Devices) != 1 || topology.PrimaryResidentID == nil {
+			t.Fatalf("ResolveCardTopology #%d = %+v, %v", i, topology, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected a single query: %v", err)
+	}
+}
+
+// 单元卡片变更只失效该单元的卡片和拓扑，批量变更清空缓存
+func TestCardRepository_Invalidate(t *testing.T) {
+	repo, mock := newCachedCardRepo(t)
+	expectCard(mock, "dev-1", "card-1", "unit-1")
+	expectCard(mock, "dev-2", "card-2", "unit-2")
+	for _, dev := range []string{"dev-1", "dev-2"} {
+		if _, err := repo.ResolveCard("t1", dev); err != nil {
+			t.Fatalf("ResolveCard %s: %v", dev, err)
+		}
+	}
+
+	repo.Invalidate(events.CardChange{TenantID: "t1", UnitID: "unit-1"})
+	if _, _, ok := repo.cardCache.Get("t1|dev-1"); ok {
+		t.Fatal("card in changed unit should be invalidated")
+	}
+	if _, _, ok := repo.cardCache.Get("t1|dev-2"); !ok {
+		t.Fatal("card in other unit should stay cached")
+	}
+
+	// 其他租户的同名单元不受影响
+	repo.Invalidate(events.CardChange{TenantID: "t2", UnitID: "unit-2"})
+	if _, _, ok := repo.cardCache.Get("t1|dev-2"); !ok {
+		t.Fatal("change of another tenant should not invalidate")
+	}
+
+	repo.Invalidate(events.CardChange{TenantID: "t1"})
+	if repo.cardCache.Len() != 0 {
+		t.Fatalf("bulk change should purge cache, %d left", repo.cardCache.Len())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// ErrFusionPolicyNotFound 没有匹配的融合策略
+var ErrFusionPolicyNotFound = errors.New("fusion policy not found")
+
+// FusionPolicyRepository 融合策略仓库（fusion_policy 表）
+type FusionPolicyRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewFusionPolicyRepository 创建融合策略仓库
+func NewFusionPolicyRepository(db *sql.DB, logger *zap.Logger) *FusionPolicyRepository {
+	return &FusionPolicyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetSpec 获取卡片适用的融合策略（返回 spec JSON 和版本号）
+// 匹配优先级：床位策略 > 单元策略 > 租户默认策略，同一范围内取最高版本
+func (r *FusionPolicyRepository) GetSpec(tenantID string, unitID, bedID *string) ([]byte, int, error) {
+	query := `
+		SELECT spec, version
+		FROM fusion_policy
+		WHERE tenant_id = $1
+		  AND is_active = TRUE
+		  AND (unit_id IS NULL OR unit_id::text = $2)
+		  AND (bed_id IS NULL OR bed_id::text = $3)
+		ORDER BY bed_id IS NULL, unit_id IS NULL, version DESC
+		LIMIT 1
+	`
+	
+	var spec []byte
+	var version int
+	err := r.db.QueryRow(query, tenantID, stringOrEmpty(unitID), stringOrEmpty(bedID)).Scan(&spec, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, ErrFusionPolicyNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to query fusion policy: %w", err)
+	}
+	return spec, version, nil
+}
+
+// stringOrEmpty 空指针返回空字符串
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -3,22 +3,27 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"time"
 	"wisefido-sensor-fusion/internal/models"
 	
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 // IoTTimeSeriesRepository IoT 时序数据仓库
 type IoTTimeSeriesRepository struct {
-	db     *sql.DB
-	logger *zap.Logger
+	db            *sql.DB
+	skewTolerance time.Duration // 事件时间超前当前时间的容差（与数据转换服务的 CLOCK_SKEW_TOLERANCE 一致）
+	logger        *zap.Logger
 }
 
 // NewIoTTimeSeriesRepository 创建 IoT 时序数据仓库
-func NewIoTTimeSeriesRepository(db *sql.DB, logger *zap.Logger) *IoTTimeSeriesRepository {
+// 事件时间超过 NOW() + skewTolerance 的历史数据不参与融合
+func NewIoTTimeSeriesRepository(db *sql.DB, skewTolerance time.Duration, logger *zap.Logger) *IoTTimeSeriesRepository {
 	return &IoTTimeSeriesRepository{
-		db:     db,
-		logger: logger,
+		db:            db,
+		skewTolerance: skewTolerance,
+		logger:        logger,
 	}
 }
 
@@ -48,16 +53,21 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceID(tenantID, deviceID string,
 			its.bed_status_display,
 			its.sleep_state_snomed_code,
 			its.sleep_state_display,
+			COALESCE(its.quality_flag, 'ok') as quality_flag,
+			COALESCE(its.hr_quality, '') as hr_quality,
+			COALESCE(its.rr_quality, '') as rr_quality,
 			COALESCE(ds.device_type, '') as device_type
 		FROM iot_timeseries its
 		LEFT JOIN devices d ON its.device_id = d.device_id
 		LEFT JOIN device_store ds ON d.device_store_id = ds.device_store_id
 		WHERE its.device_id = $1 AND its.tenant_id = $2
+			-- 忽略事件时间在未来的历史数据（数据转换服务已拒绝 / 校正新的未来数据）
+			AND its.timestamp <= NOW() + $4 * INTERVAL '1 second'
 		ORDER BY its.timestamp DESC
 		LIMIT $3
 	`
 	
-	rows, err := r.db.Query(query, deviceID, tenantID, limit)
+	rows, err := r.db.Query(query, deviceID, tenantID, limit, r.skewSeconds())
 	if err != nil {
 		return nil, fmt.Errorf("failed to query iot_timeseries: %w", err)
 	}
@@ -93,6 +103,9 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceID(tenantID, deviceID string,
 			&bedStatusDisplay,
 			&sleepStateCode,
 			&sleepStateDisplay,
+			&item.QualityFlag,
+			&item.HeartRateQuality,
+			&item.RespiratoryRateQuality,
 			&deviceType,
 		)
 		if err != nil {
@@ -145,6 +158,8 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceID(tenantID, deviceID string,
 			item.SleepStateDisplay = &sleepStateDisplay.String
 		}
 		
+		item.DropUntrustedVitals()
+		
 		// 设置设备类型（从 JOIN 查询获取，避免额外查询）
 		if deviceType.Valid {
 			item.DeviceType = deviceType.String
@@ -161,8 +176,24 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceID(tenantID, deviceID string,
 // 参数:
 //   - tenantID: 租户 ID（用于数据隔离）
 //   - deviceIDs: 设备 ID 列表
-//   - limit: 每个设备返回的记录数限制
+//   - limit: 每个设备返回的帧数限制（多目标雷达帧拆分出的各行算一帧，全部返回）
 func (r *IoTTimeSeriesRepository) GetLatestByDeviceIDs(tenantID string, deviceIDs []string, limit int) (map[string][]*models.IoTTimeSeries, error) {
+	return r.getFrames(tenantID, deviceIDs, time.Time{}, limit)
+}
+
+// GetWindowByDeviceIDs 批量获取多个设备在滑动窗口内的时序数据（按时间倒序）
+// 
+// 参数:
+//   - tenantID: 租户 ID（用于数据隔离）
+//   - deviceIDs: 设备 ID 列表
+//   - since: 窗口起始时间（只返回该时间之后的数据）
+//   - limit: 每个设备返回的帧数上限
+func (r *IoTTimeSeriesRepository) GetWindowByDeviceIDs(tenantID string, deviceIDs []string, since time.Time, limit int) (map[string][]*models.IoTTimeSeries, error) {
+	return r.getFrames(tenantID, deviceIDs, since, limit)
+}
+
+// getFrames 批量查询多个设备的最新 limit 帧（since 非零时只查询窗口内的数据）
+func (r *IoTTimeSeriesRepository) getFrames(tenantID string, deviceIDs []string, since time.Time, limit int) (map[string][]*models.IoTTimeSeries, error) {
 	if len(deviceIDs) == 0 {
 		return make(map[string][]*models.IoTTimeSeries), nil
 	}
@@ -187,15 +218,27 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceIDs(tenantID string, deviceID
 			its.bed_status_display,
 			its.sleep_state_snomed_code,
 			its.sleep_state_display,
+			COALESCE(its.quality_flag, 'ok') as quality_flag,
+			COALESCE(its.hr_quality, '') as hr_quality,
+			COALESCE(its.rr_quality, '') as rr_quality,
+			its.frame_id,
 			COALESCE(ds.device_type, '') as device_type,
-			ROW_NUMBER() OVER (PARTITION BY its.device_id ORDER BY its.timestamp DESC) as rn
+			-- 同一多目标帧的各行排名相同（按帧计数）
+			DENSE_RANK() OVER (PARTITION BY its.device_id ORDER BY its.timestamp DESC, COALESCE(its.frame_id, its.id::text) DESC) as rn
 		FROM iot_timeseries its
 		LEFT JOIN devices d ON its.device_id = d.device_id
 		LEFT JOIN device_store ds ON d.device_store_id = ds.device_store_id
 		WHERE its.device_id = ANY($1) AND its.tenant_id = $2
+			AND its.timestamp <= NOW() + $4 * INTERVAL '1 second'
+			AND ($3::timestamptz IS NULL OR its.timestamp >= $3)
+		ORDER BY its.device_id, its.timestamp DESC
 	`
 	
-	rows, err := r.db.Query(query, deviceIDs, tenantID)
+	var sinceParam sql.NullTime
+	if !since.IsZero() {
+		sinceParam = sql.NullTime{Time: since, Valid: true}
+	}
+	rows, err := r.db.Query(query, pq.Array(deviceIDs), tenantID, sinceParam, r.skewSeconds())
 	if err != nil {
 		return nil, fmt.Errorf("failed to query iot_timeseries: %w", err)
 	}
@@ -212,6 +255,7 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceIDs(tenantID string, deviceID
 		var bedStatusCode, bedStatusDisplay sql.NullString
 		var sleepStateCode, sleepStateDisplay sql.NullString
 		var deviceType sql.NullString
+		var frameID sql.NullString
 		var rowNum int64
 		
 		err := rows.Scan(
@@ -232,6 +276,10 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceIDs(tenantID string, deviceID
 			&bedStatusDisplay,
 			&sleepStateCode,
 			&sleepStateDisplay,
+			&item.QualityFlag,
+			&item.HeartRateQuality,
+			&item.RespiratoryRateQuality,
+			&frameID,
 			&deviceType,
 			&rowNum,
 		)
@@ -239,7 +287,7 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceIDs(tenantID string, deviceID
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 		
-		// 只取每个设备的前 limit 条记录
+		// 只取每个设备的前 limit 帧（多目标帧的所有目标行）
 		if rowNum > int64(limit) {
 			continue
 		}
@@ -290,6 +338,11 @@ func (r *IoTTimeSeriesRepository) GetLatestByDeviceIDs(tenantID string, deviceID
 			item.SleepStateDisplay = &sleepStateDisplay.String
 		}
 		
+		item.DropUntrustedVitals()
+		
+		if frameID.Valid {
+			item.FrameID = &frameID.String
+		}
 		if deviceType.Valid {
 			item.DeviceType = deviceType.String
 		}
@@ -325,3 +378,7 @@ func (r *IoTTimeSeriesRepository) GetDeviceType(tenantID, deviceID string) (stri
 	return deviceType, nil
 }
 
+// skewSeconds 事件时间超前容差（秒）
+func (r *IoTTimeSeriesRepository) skewSeconds() int64 {
+	return int64(r.skewTolerance / time.Second)
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var frameColumns = []string{
+	"id", "tenant_id", "device_id", "timestamp",
+	"heart_rate", "heart_rate_code", "heart_rate_display",
+	"respiratory_rate", "respiratory_rate_code", "respiratory_rate_display",
+	"posture_snomed_code", "posture_display", "tracking_id",
+	"bed_status_snomed_code", "bed_status_display", "sleep_state_snomed_code", "sleep_state_display",
+	"quality_flag", "hr_quality", "rr_quality", "frame_id", "device_type", "rn",
+}
+
+func newTimeSeriesRepo(t *testing.T) (*IoTTimeSeriesRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewIoTTimeSeriesRepository(db, 5*time.Minute, zap.NewNop()), mock
+}
+
+// addFrameRow 追加一行时序数据（frameID 为 nil 表示单目标数据）
+func addFrameRow(rows *sqlmock.Rows, id, deviceID string, at time.Time, heartRate, trackingID, hrQuality, frameID interface{}, rn int64) {
+	rows.AddRow(id, "t1", deviceID, at,
+		heartRate, nil, nil,
+		nil, nil, nil,
+		nil, nil, trackingID,
+		nil, nil, nil, nil,
+		"ok", hrQuality, "", frameID, "Radar", rn)
+}
+
+// 多目标帧的各行按一帧计数，超过 limit 的帧丢弃，质量标记不是 ok 的生命体征清空
+func TestIoTTimeSeriesRepository_GetLatestByDeviceIDs(t *testing.T) {
+	repo, mock := newTimeSeriesRepo(t)
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	rows := sqlmock.NewRows(frameColumns)
+	addFrameRow(rows, "1", "radar-1", t0, 72, "1", "", "f2", 1)
+	addFrameRow(rows, "2", "radar-1", t0, nil, "2", "", "f2", 1)
+	addFrameRow(rows, "3", "radar-1", t0.Add(-time.Second), 70, "1", "", "f1", 2)
+	addFrameRow(rows, "4", "radar-1", t0.Add(-2*time.Second), 68, nil, "", nil, 3)
+	addFrameRow(rows, "5", "pad-1", t0, 90, nil, "low", nil, 1)
+	mock.ExpectQuery("FROM iot_timeseries").
+		WithArgs(pq.Array([]string{"radar-1", "pad-1"}), "t1", sql.NullTime{}, int64(300)).
+		WillReturnRows(rows)
+
+	result, err := repo.GetLatestByDeviceIDs("t1", []string{"radar-1", "pad-1"}, 2)
+	if err != nil {
+		t.Fatalf("GetLatestByDeviceIDs failed: %v", err)
+	}
+	radar := result["radar-1"]
+	if len(radar) != 3 {
+		t.Fatalf("radar rows = %d, want 3 (two frames)", len(radar))
+	}
+	if radar[0].FrameID == nil || *radar[0].FrameID != "f2" || *radar[1].FrameID != "f2" || radar[1].TrackingID == nil || *radar[1].TrackingID != "2" {
+		t.Fatalf("first frame rows = %+v, %+v", radar[0], radar[1])
+	}
+	if radar[0].HeartRate == nil || *radar[0].HeartRate != 72 || radar[0].DeviceType != "Radar" {
+		t.Fatalf("first row = %+v", radar[0])
+	}
+	pad := result["pad-1"]
+	if len(pad) != 1 || pad[0].HeartRate != nil || pad[0].FrameID != nil {
+		t.Fatalf("pad rows = %+v", pad)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 窗口查询传入起始时间
+func TestIoTTimeSeriesRepository_GetWindowByDeviceIDs(t *testing.T) {
+	repo, mock := newTimeSeriesRepo(t)
+	since := time.Date(2026, 1, 1, 11, 59, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM iot_timeseries").
+		WithArgs(pq.Array([]string{"radar-1"}), "t1", sql.NullTime{Time: since, Valid: true}, int64(300)).
+		WillReturnRows(sqlmock.NewRows(frameColumns))
+
+	result, err := repo.GetWindowByDeviceIDs("t1", []string{"radar-1"}, since, 10)
+	if err != nil || len(result) != 0 {
+		t.Fatalf("GetWindowByDeviceIDs = %v, %v", result, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有设备时不查询
+	if result, err := repo.GetWindowByDeviceIDs("t1", nil, since, 10); err != nil || len(result) != 0 {
+		t.Fatalf("GetWindowByDeviceIDs(nil) = %v, %v", result, err)
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// ErrLayoutNotFound 单元不存在或未配置布局
+var ErrLayoutNotFound = errors.New("layout not found")
+
+// LayoutRepository 单元布局仓库（units.layout_config）
+type LayoutRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewLayoutRepository 创建单元布局仓库
+func NewLayoutRepository(db *sql.DB, logger *zap.Logger) *LayoutRepository {
+	return &LayoutRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetUnitLayout 获取单元的布局配置（layout_config JSON）
+func (r *LayoutRepository) GetUnitLayout(tenantID, unitID string) ([]byte, error) {
+	query := `
+		SELECT layout_config::text
+		FROM units
+		WHERE tenant_id = $1 AND unit_id::text = $2
+	`
+	
+	var layout sql.NullString
+	err := r.db.QueryRow(query, tenantID, unitID).Scan(&layout)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrLayoutNotFound
+		}
+		return nil, fmt.Errorf("failed to query unit layout: %w", err)
+	}
+	if !layout.Valid || layout.String == "" {
+		return nil, ErrLayoutNotFound
+	}
+	return []byte(layout.String), nil
+}
+
+// ErrInstallationNotFound 雷达未配置安装姿态
+var ErrInstallationNotFound = errors.New("installation not found")
+
+// GetDeviceInstallation 获取雷达当前生效的安装姿态（config_versions.config_data，config_type = 'device_installation'）
+func (r *LayoutRepository) GetDeviceInstallation(tenantID, deviceID string) ([]byte, error) {
+	query := `
+		SELECT config_data::text
+		FROM config_versions
+		WHERE tenant_id = $1
+		  AND config_type = 'device_installation'
+		  AND entity_id::text = $2
+		  AND valid_from <= NOW()
+		  AND (valid_to IS NULL OR valid_to > NOW())
+		ORDER BY valid_from DESC
+		LIMIT 1
+	`
+
+	var data string
+	err := r.db.QueryRow(query, tenantID, deviceID).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInstallationNotFound
+		}
+		return nil, fmt.Errorf("failed to query device installation: %w", err)
+	}
+	return []byte(data), nil
+}
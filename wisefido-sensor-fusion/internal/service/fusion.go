@@ -4,14 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
+	"time"
 	"wisefido-sensor-fusion/internal/config"
 	"wisefido-sensor-fusion/internal/consumer"
 	"wisefido-sensor-fusion/internal/fusion"
 	"wisefido-sensor-fusion/internal/repository"
+	"wisefido-sensor-fusion/internal/tracking"
 	
 	"go.uber.org/zap"
 	"github.com/go-redis/redis/v8"
 	"owl-common/database"
+	"owl-common/events"
 	rediscommon "owl-common/redis"
 )
 
@@ -21,7 +25,12 @@ type FusionService struct {
 	logger      *zap.Logger
 	db          *sql.DB
 	redisClient *redis.Client
+	cardRepo    *repository.CardRepository
+	state       *fusion.StateStore
+	tracks      *tracking.Manager
+	trackEvents *tracking.Publisher
 	consumer    *consumer.StreamConsumer
+	lease       *rediscommon.Lease
 }
 
 // NewFusionService 创建传感器融合服务
@@ -40,14 +49,35 @@ func NewFusionService(cfg *config.Config, logger *zap.Logger) (*FusionService, e
 	
 	// 创建Repository
 	cardRepo := repository.NewCardRepository(db, logger)
-	iotRepo := repository.NewIoTTimeSeriesRepository(db, logger)
+	cardRepo.EnableCache(
+		time.Duration(cfg.Fusion.TopologyCacheTTL)*time.Second,
+		time.Duration(cfg.Fusion.TopologyNegativeTTL)*time.Second,
+	)
+	iotRepo := repository.NewIoTTimeSeriesRepository(db, time.Duration(cfg.Fusion.ClockSkewTolerance)*time.Second, logger)
+	
+	policyRepo := repository.NewFusionPolicyRepository(db, logger)
 	
-	// 创建Fusion
-	sensorFusion := fusion.NewSensorFusion(cardRepo, iotRepo, logger)
+	// 创建Fusion（融合策略：服务默认值 + fusion_policy 表按床位 / 单元覆盖）
+	defaultPolicy := fusion.DefaultPolicy(cfg.Fusion.WindowSeconds, cfg.Fusion.FreshnessSeconds, cfg.Fusion.MinConfidence)
+	policies := fusion.NewPolicyResolver(policyRepo, defaultPolicy, time.Duration(cfg.Fusion.PolicyCacheTTL)*time.Second, logger)
+	state := fusion.NewStateStore(time.Duration(cfg.Fusion.StateRetention)*time.Second, cfg.Fusion.WindowFrames)
+	sensorFusion := fusion.NewSensorFusion(cardRepo, iotRepo, policies, state, cfg.Fusion.WindowFrames, logger)
 	
 	// 创建CacheManager
 	cacheManager := consumer.NewCacheManager(cfg, redisClient, logger)
 	
+	// 创建轨迹管理器（区域来自 units.layout_config）
+	trackCfg := cfg.Fusion.Tracking
+	layouts := tracking.NewLayoutResolver(repository.NewLayoutRepository(db, logger), time.Duration(trackCfg.LayoutCacheTTL)*time.Second, logger)
+	tracks := tracking.NewManager(tracking.Config{
+		LostTimeout:     time.Duration(trackCfg.LostTimeout) * time.Second,
+		DwellThreshold:  time.Duration(trackCfg.DwellThreshold) * time.Second,
+		GaitWindow:      time.Duration(trackCfg.GaitWindow) * time.Second,
+		GaitMinDistance: float64(trackCfg.GaitMinDistance),
+		GaitInterval:    time.Duration(trackCfg.GaitInterval) * time.Second,
+	}, layouts)
+	trackEvents := tracking.NewPublisher(redisClient, trackCfg.Output, trackCfg.OutputPolicy, logger)
+	
 	// 创建Consumer
 	streamConsumer := consumer.NewStreamConsumer(
 		cfg,
@@ -56,35 +86,111 @@ func NewFusionService(cfg *config.Config, logger *zap.Logger) (*FusionService, e
 		iotRepo,
 		sensorFusion,
 		cacheManager,
+		tracks,
+		trackEvents,
 		logger,
 	)
 	
+	// 单实例租约：内存窗口只有在本实例看到设备全部消息时才完整，多个副本同时消费会各自只有部分数据
+	hostname, _ := os.Hostname()
+	lease := rediscommon.NewLease(redisClient, cfg.Fusion.LeaseKey,
+		fmt.Sprintf("%s:%d", hostname, os.Getpid()), time.Duration(cfg.Fusion.LeaseTTL)*time.Second)
+	
 	return &FusionService{
 		config:      cfg,
 		logger:      logger,
 		db:          db,
 		redisClient: redisClient,
+		cardRepo:    cardRepo,
+		state:       state,
+		tracks:      tracks,
+		trackEvents: trackEvents,
 		consumer:    streamConsumer,
+		lease:       lease,
 	}, nil
 }
 
 // Start 启动服务
+// 先获取单实例租约（其他实例持有时待命），租约丢失时返回错误，由进程重启后重新待命
 func (s *FusionService) Start(ctx context.Context) error {
+	s.logger.Info("Waiting for sensor fusion lease", zap.String("lease_key", s.config.Fusion.LeaseKey))
+	ttl := time.Duration(s.config.Fusion.LeaseTTL) * time.Second
+	if err := s.lease.Acquire(ctx, ttl/3); err != nil {
+		return nil // 待命期间收到退出信号
+	}
+	s.logger.Info("Acquired sensor fusion lease, starting as active instance")
+	
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	leaseErr := make(chan error, 1)
+	go func() {
+		err := s.lease.Keep(ctx)
+		if err != nil {
+			// 其他实例可能已接管，内存状态不再完整，停止消费
+			cancel()
+		}
+		leaseErr <- err
+	}()
+	
 	s.logger.Info("Starting sensor fusion service components")
 	
+	// 卡片重建后失效卡片拓扑缓存（card_id 会变化）
+	go events.WatchCardChanges(ctx, s.redisClient, s.logger, s.cardRepo.Invalidate)
+	
+	// 定期清理长时间无数据的设备状态
+	go s.sweepState(ctx)
+	
+	// 轨迹事件流的发布统计和积压
+	go s.trackEvents.Report(ctx)
+	
 	// 启动Stream消费者
+	s.logger.Info("Sensor fusion service started successfully")
 	if err := s.consumer.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start stream consumer: %w", err)
 	}
 	
-	s.logger.Info("Sensor fusion service started successfully")
+	cancel()
+	if err := <-leaseErr; err != nil {
+		return fmt.Errorf("stopped consuming: %w", err)
+	}
 	return nil
 }
 
+// sweepState 定期清理设备内存状态和已离开的目标轨迹（每分钟）
+func (s *FusionService) sweepState(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if removed := s.state.Sweep(now); removed > 0 {
+				s.logger.Debug("Swept idle device state",
+					zap.Int("removed", removed),
+					zap.Int("devices", s.state.Len()),
+				)
+			}
+			// 设备停止上报时结束其目标轨迹
+			if evs := s.tracks.Sweep(now); len(evs) > 0 {
+				s.trackEvents.Publish(ctx, evs)
+			}
+		}
+	}
+}
+
 // Stop 停止服务
 func (s *FusionService) Stop(ctx context.Context) error {
 	s.logger.Info("Stopping sensor fusion service")
 	
+	// 释放租约，备用实例无需等待过期即可接管（ctx 此时可能已取消）
+	releaseCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.lease.Release(releaseCtx); err != nil {
+		s.logger.Error("Error releasing sensor fusion lease", zap.Error(err))
+	}
+	
 	// 关闭Redis
 	if s.redisClient != nil {
 		if err := s.redisClient.Close(); err != nil {
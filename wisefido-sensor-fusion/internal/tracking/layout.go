@@ -0,0 +1,190 @@
+package tracking
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"wisefido-sensor-fusion/internal/repository"
+
+	"go.uber.org/zap"
+	"owl-common/cache"
+)
+
+// 区域类型（layout_config.zones[].type）
+const (
+	ZoneBed          = "bed"
+	ZoneBathroomDoor = "bathroom_door"
+	ZoneChair        = "chair"
+	ZoneExit         = "exit"
+)
+
+// Point 布局坐标（cm）
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Zone 布局中的区域（多边形，顶点按顺序排列）
+type Zone struct {
+	ZoneID  string  `json:"zone_id"`
+	Type    string  `json:"type"`
+	Name    string  `json:"name,omitempty"`
+	Polygon []Point `json:"polygon"`
+}
+
+// Layout 单元布局（units.layout_config 中与轨迹跟踪相关的部分，其他字段忽略）
+//
+// 示例：
+//
+//	{"zones": [
+//	  {"zone_id": "bed-a", "type": "bed", "name": "A 床",
+//	   "polygon": [{"x": 0, "y": 0}, {"x": 90, "y": 0}, {"x": 90, "y": 200}, {"x": 0, "y": 200}]},
+//	  {"zone_id": "door", "type": "exit", "polygon": [...]}
+//	]}
+type Layout struct {
+	Zones []Zone `json:"zones"`
+}
+
+// ParseLayout 解析并校验单元布局
+func ParseLayout(data []byte) (*Layout, error) {
+	layout := &Layout{}
+	if err := json.Unmarshal(data, layout); err != nil {
+		return nil, fmt.Errorf("failed to parse layout config: %w", err)
+	}
+	seen := make(map[string]bool, len(layout.Zones))
+	for _, zone := range layout.Zones {
+		if zone.ZoneID == "" || zone.Type == "" {
+			return nil, fmt.Errorf("zone_id and type are required")
+		}
+		if seen[zone.ZoneID] {
+			return nil, fmt.Errorf("zone %q: duplicate zone_id", zone.ZoneID)
+		}
+		seen[zone.ZoneID] = true
+		if len(zone.Polygon) < 3 {
+			return nil, fmt.Errorf("zone %q: polygon needs at least 3 points", zone.ZoneID)
+		}
+	}
+	return layout, nil
+}
+
+// Contains 点是否在区域内（射线法，边界上的点视情况可能不计入）
+func (z *Zone) Contains(p Point) bool {
+	inside := false
+	n := len(z.Polygon)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a, b := z.Polygon[i], z.Polygon[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// zonesAt 包含该点的区域（区域可重叠，如床与床边椅）
+func (l *Layout) zonesAt(p Point) map[string]*Zone {
+	zones := make(map[string]*Zone)
+	if l == nil {
+		return zones
+	}
+	for i := range l.Zones {
+		if l.Zones[i].Contains(p) {
+			zones[l.Zones[i].ZoneID] = &l.Zones[i]
+		}
+	}
+	return zones
+}
+
+// LayoutResolver 单元布局 / 雷达安装姿态解析器（带缓存，修改配置后在缓存过期时生效）
+type LayoutResolver struct {
+	repo   *repository.LayoutRepository
+	cache  *cache.TTL[*Layout]
+	poses  *cache.TTL[*MountPose]
+	logger *zap.Logger
+}
+
+// NewLayoutResolver 创建单元布局解析器
+func NewLayoutResolver(repo *repository.LayoutRepository, ttl time.Duration, logger *zap.Logger) *LayoutResolver {
+	return &LayoutResolver{
+		repo:   repo,
+		cache:  cache.NewTTL[*Layout](ttl, ttl),
+		poses:  cache.NewTTL[*MountPose](ttl, ttl),
+		logger: logger,
+	}
+}
+
+// Resolve 返回单元布局，未配置或配置无效时返回 nil（只跟踪轨迹，不产生区域事件）
+func (r *LayoutResolver) Resolve(tenantID, unitID string) *Layout {
+	if unitID == "" {
+		return nil
+	}
+	key := tenantID + "|" + unitID
+	if layout, missing, ok := r.cache.Get(key); ok {
+		if missing {
+			return nil
+		}
+		return layout
+	}
+
+	data, err := r.repo.GetUnitLayout(tenantID, unitID)
+	if err != nil {
+		if errors.Is(err, repository.ErrLayoutNotFound) {
+			r.cache.SetMissing(key)
+		} else {
+			// 数据库暂时不可用：不缓存，本次不产生区域事件
+			r.logger.Warn("Failed to load unit layout",
+				zap.String("unit_id", unitID),
+				zap.Error(err),
+			)
+		}
+		return nil
+	}
+
+	layout, err := ParseLayout(data)
+	if err != nil {
+		r.logger.Error("Invalid unit layout, zone events disabled",
+			zap.String("unit_id", unitID),
+			zap.Error(err),
+		)
+		r.cache.SetMissing(key)
+		return nil
+	}
+	r.cache.Set(key, layout)
+	return layout
+}
+
+// Pose 返回雷达安装姿态，未配置或配置无效时返回 nil（雷达坐标直接作为布局坐标）
+func (r *LayoutResolver) Pose(tenantID, deviceID string) *MountPose {
+	key := tenantID + "|" + deviceID
+	if pose, missing, ok := r.poses.Get(key); ok {
+		if missing {
+			return nil
+		}
+		return pose
+	}
+
+	data, err := r.repo.GetDeviceInstallation(tenantID, deviceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrInstallationNotFound) {
+			r.poses.SetMissing(key)
+		} else {
+			r.logger.Warn("Failed to load device installation",
+				zap.String("device_id", deviceID),
+				zap.Error(err),
+			)
+		}
+		return nil
+	}
+
+	pose, err := ParseMountPose(data)
+	if err != nil {
+		r.logger.Error("Invalid device installation, using radar coordinates",
+			zap.String("device_id", deviceID),
+			zap.Error(err),
+		)
+		r.poses.SetMissing(key)
+		return nil
+	}
+	r.poses.Set(key, pose)
+	return pose
+}
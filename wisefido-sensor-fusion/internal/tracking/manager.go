@@ -0,0 +1,304 @@
+package tracking
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"owl-common/events"
+)
+
+// Config 轨迹跟踪参数
+type Config struct {
+	LostTimeout     time.Duration // 目标超过该时长未出现视为离开
+	DwellThreshold  time.Duration // 在区域内停留超过该时长发布 dwell 事件
+	GaitWindow      time.Duration // 行走速度计算窗口
+	GaitMinDistance float64       // 窗口内位移不小于该距离（cm）才视为行走，过滤原地晃动
+	GaitInterval    time.Duration // 同一目标两次 gait_speed 事件的最短间隔
+}
+
+// Target 雷达帧中的一个目标
+type Target struct {
+	TrackingID string
+	Position   Point
+	Z          *int
+}
+
+// Frame 雷达一帧的目标（同一设备、同一时刻）
+type Frame struct {
+	TenantID string
+	DeviceID string
+	CardID   string
+	UnitID   string
+	At       time.Time
+	Targets  []Target
+}
+
+// Event 待发布的轨迹事件
+type Event struct {
+	TenantID  string
+	EventTime time.Time
+	Payload   events.TrackEvent
+}
+
+// Manager 雷达目标轨迹管理器（内存，按 设备 + tracking_id 保存轨迹）
+//
+// 由 iot:data:stream 消息直接更新，与融合内存状态一样只反映本实例收到的帧；
+// 帧被抽样丢弃时轨迹变稀疏，超过 LostTimeout 仍未出现的目标视为离开。
+type Manager struct {
+	mu      sync.Mutex
+	cfg     Config
+	layouts *LayoutResolver
+	tracks  map[string]*track // device_id|tracking_id -> 轨迹
+}
+
+// track 单个目标的轨迹
+type track struct {
+	tenantID   string
+	deviceID   string
+	cardID     string
+	unitID     string
+	trackingID string
+	points     []trackPoint         // 行走速度窗口内的位置（按时间正序）
+	stays      map[string]*zoneStay // 当前所在区域
+	lastSeen   time.Time
+	lastGait   time.Time
+}
+
+// trackPoint 轨迹点
+type trackPoint struct {
+	at time.Time
+	p  Point
+	z  *int
+}
+
+// zoneStay 目标在某区域内的一次停留
+type zoneStay struct {
+	zone         Zone
+	since        time.Time
+	dwellEmitted bool
+}
+
+// NewManager 创建轨迹管理器
+func NewManager(cfg Config, layouts *LayoutResolver) *Manager {
+	return &Manager{
+		cfg:     cfg,
+		layouts: layouts,
+		tracks:  make(map[string]*track),
+	}
+}
+
+// Observe 处理雷达一帧的目标，返回产生的轨迹事件
+// 同一设备未出现在本帧且超过 LostTimeout 的目标一并结束
+func (m *Manager) Observe(frame Frame) []Event {
+	// 布局 / 安装姿态查询可能访问数据库，不持有锁
+	var layout *Layout
+	if m.layouts != nil {
+		layout = m.layouts.Resolve(frame.TenantID, frame.UnitID)
+		frame.Targets = transformTargets(m.layouts.Pose(frame.TenantID, frame.DeviceID), frame.UnitID, frame.Targets)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []Event
+	seen := make(map[string]bool, len(frame.Targets))
+	for _, target := range frame.Targets {
+		if target.TrackingID == "" {
+			continue
+		}
+		key := frame.DeviceID + "|" + target.TrackingID
+		seen[key] = true
+
+		t, ok := m.tracks[key]
+		if !ok {
+			t = &track{
+				tenantID:   frame.TenantID,
+				deviceID:   frame.DeviceID,
+				trackingID: target.TrackingID,
+				stays:      make(map[string]*zoneStay),
+			}
+			m.tracks[key] = t
+		} else if frame.At.Before(t.lastSeen) {
+			// 乱序的帧（同一时刻的帧保留：设备只上报秒级时间时同一秒内有多帧）
+			continue
+		}
+		// 设备可能重新绑定到其他卡片 / 单元
+		t.cardID, t.unitID = frame.CardID, frame.UnitID
+		t.lastSeen = frame.At
+		point := trackPoint{at: frame.At, p: target.Position, z: target.Z}
+		t.points = append(t.points, point)
+		t.trimPoints(m.cfg.GaitWindow)
+
+		if !ok {
+			out = append(out, t.event(events.TrackEntry, point, nil))
+		}
+		out = append(out, t.updateZones(layout, point, m.cfg.DwellThreshold)...)
+		if e, ok := t.gait(point, m.cfg); ok {
+			out = append(out, e)
+		}
+	}
+
+	for key, t := range m.tracks {
+		if t.deviceID == frame.DeviceID && !seen[key] && frame.At.Sub(t.lastSeen) > m.cfg.LostTimeout {
+			out = append(out, t.finish()...)
+			delete(m.tracks, key)
+		}
+	}
+	return out
+}
+
+// Sweep 结束长时间未出现的目标（设备停止上报时由定时任务调用），返回产生的轨迹事件
+func (m *Manager) Sweep(now time.Time) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []Event
+	for key, t := range m.tracks {
+		if now.Sub(t.lastSeen) > m.cfg.LostTimeout {
+			out = append(out, t.finish()...)
+			delete(m.tracks, key)
+		}
+	}
+	return out
+}
+
+// Len 当前跟踪的目标数
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.tracks)
+}
+
+// transformTargets 按安装姿态将目标的雷达坐标转换为布局坐标（返回副本）
+// 未配置姿态，或姿态所属单元与卡片单元不一致（设备已移动、姿态未更新）时保持雷达坐标
+func transformTargets(pose *MountPose, unitID string, targets []Target) []Target {
+	if pose == nil || pose.UnitID != unitID {
+		return targets
+	}
+	out := make([]Target, len(targets))
+	for i, target := range targets {
+		pose.toRoom(&target)
+		out[i] = target
+	}
+	return out
+}
+
+// updateZones 根据当前位置更新区域停留，返回 zone_enter / zone_exit / dwell 事件
+func (t *track) updateZones(layout *Layout, point trackPoint, dwellThreshold time.Duration) []Event {
+	var out []Event
+	current := layout.zonesAt(point.p)
+
+	// 离开的区域（布局修改后已删除的区域也视为离开）
+	for _, zoneID := range sortedKeys(t.stays) {
+		if _, ok := current[zoneID]; ok {
+			continue
+		}
+		stay := t.stays[zoneID]
+		out = append(out, t.zoneEvent(events.TrackZoneExit, stay, point, point.at.Sub(stay.since)))
+		delete(t.stays, zoneID)
+	}
+
+	for _, zoneID := range sortedKeys(current) {
+		stay, ok := t.stays[zoneID]
+		if !ok {
+			stay = &zoneStay{zone: *current[zoneID], since: point.at}
+			t.stays[zoneID] = stay
+			out = append(out, t.zoneEvent(events.TrackZoneEnter, stay, point, 0))
+			continue
+		}
+		if dwell := point.at.Sub(stay.since); !stay.dwellEmitted && dwell >= dwellThreshold {
+			stay.dwellEmitted = true
+			out = append(out, t.zoneEvent(events.TrackDwell, stay, point, dwell))
+		}
+	}
+	return out
+}
+
+// gait 计算窗口内的行走速度（位移 / 时长），满足最小位移和发布间隔时返回 gait_speed 事件
+func (t *track) gait(point trackPoint, cfg Config) (Event, bool) {
+	if len(t.points) < 2 || point.at.Sub(t.lastGait) < cfg.GaitInterval {
+		return Event{}, false
+	}
+	first := t.points[0]
+	elapsed := point.at.Sub(first.at).Seconds()
+	// 窗口内数据不足一半时不计算（新目标或帧被抽样丢弃）
+	if elapsed < cfg.GaitWindow.Seconds()/2 {
+		return Event{}, false
+	}
+	distance := math.Hypot(point.p.X-first.p.X, point.p.Y-first.p.Y)
+	if distance < cfg.GaitMinDistance {
+		return Event{}, false
+	}
+	t.lastGait = point.at
+	speed := math.Round(distance/elapsed*10) / 10
+	e := t.event(events.TrackGaitSpeed, point, nil)
+	e.Payload.SpeedCmPerSec = &speed
+	return e, true
+}
+
+// finish 目标离开：结束所有区域停留并发布 exit 事件（事件时间为最后一次出现的时间）
+func (t *track) finish() []Event {
+	if len(t.points) == 0 {
+		return nil
+	}
+	last := t.points[len(t.points)-1]
+	var out []Event
+	for _, zoneID := range sortedKeys(t.stays) {
+		stay := t.stays[zoneID]
+		out = append(out, t.zoneEvent(events.TrackZoneExit, stay, last, last.at.Sub(stay.since)))
+	}
+	t.stays = make(map[string]*zoneStay)
+	return append(out, t.event(events.TrackExit, last, nil))
+}
+
+// trimPoints 丢弃行走速度窗口之外的轨迹点（至少保留最新一个）
+func (t *track) trimPoints(window time.Duration) {
+	latest := t.points[len(t.points)-1].at
+	i := 0
+	for i < len(t.points)-1 && latest.Sub(t.points[i].at) > window {
+		i++
+	}
+	t.points = t.points[i:]
+}
+
+// zoneEvent 区域事件（dwell 为 0 时不携带停留时长）
+func (t *track) zoneEvent(eventType string, stay *zoneStay, point trackPoint, dwell time.Duration) Event {
+	e := t.event(eventType, point, &stay.zone)
+	if dwell > 0 {
+		seconds := int(dwell.Seconds())
+		e.Payload.DwellSeconds = &seconds
+	}
+	return e
+}
+
+// event 构建轨迹事件
+func (t *track) event(eventType string, point trackPoint, zone *Zone) Event {
+	payload := events.TrackEvent{
+		DeviceID:   t.deviceID,
+		CardID:     t.cardID,
+		UnitID:     t.unitID,
+		TrackingID: t.trackingID,
+		EventType:  eventType,
+		X:          int(math.Round(point.p.X)),
+		Y:          int(math.Round(point.p.Y)),
+		Z:          point.z,
+	}
+	if zone != nil {
+		payload.ZoneID = zone.ZoneID
+		payload.ZoneType = zone.Type
+		payload.ZoneName = zone.Name
+	}
+	return Event{TenantID: t.tenantID, EventTime: point.at, Payload: payload}
+}
+
+// sortedKeys 按区域 ID 排序（事件顺序稳定）
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
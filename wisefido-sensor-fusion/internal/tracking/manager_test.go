@@ -0,0 +1,314 @@
+package tracking
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"owl-common/events"
+)
+
+var t0 = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+var testConfig = Config{
+	LostTimeout:     10 * time.Second,
+	DwellThreshold:  30 * time.Second,
+	GaitWindow:      5 * time.Second,
+	GaitMinDistance: 50,
+	GaitInterval:    30 * time.Second,
+}
+
+// 床（0,0）-（100,200），门（300,0）-（400,50）
+var testLayout = &Layout{Zones: []Zone{
+	{ZoneID: "bed-a", Type: ZoneBed, Polygon: []Point{{0, 0}, {100, 0}, {100, 200}, {0, 200}}},
+	{ZoneID: "door", Type: ZoneExit, Polygon: []Point{{300, 0}, {400, 0}, {400, 50}, {300, 50}}},
+}}
+
+// newTestManager 创建使用固定布局（无安装姿态）的管理器
+func newTestManager(layout *Layout) *Manager {
+	layouts := NewLayoutResolver(nil, time.Hour, zap.NewNop())
+	if layout != nil {
+		layouts.cache.Set("t1|unit-1", layout)
+	} else {
+		layouts.cache.SetMissing("t1|unit-1")
+	}
+	layouts.poses.SetMissing("t1|radar-1")
+	return NewManager(testConfig, layouts)
+}
+
+func frame(at time.Time, targets ...Target) Frame {
+	return Frame{TenantID: "t1", DeviceID: "radar-1", CardID: "card-1", UnitID: "unit-1", At: at, Targets: targets}
+}
+
+func target(id string, x, y float64) Target {
+	return Target{TrackingID: id, Position: Point{X: x, Y: y}}
+}
+
+func eventTypes(evts []Event) []string {
+	out := make([]string, 0, len(evts))
+	for _, e := range evts {
+		out = append(out, e.Payload.EventType)
+	}
+	return out
+}
+
+func equalTypes(got []Event, want ...string) bool {
+	g := eventTypes(got)
+	if len(g) != len(want) {
+		return false
+	}
+	for i := range g {
+		if g[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestZone_Contains(t *testing.T) {
+	// L 形（凹多边形）
+	l := Zone{ZoneID: "l", Polygon: []Point{{0, 0}, {100, 0}, {100, 50}, {50, 50}, {50, 100}, {0, 100}}}
+	tests := []struct {
+		name string
+		zone Zone
+		p    Point
+		want bool
+	}{
+		{"inside rectangle", testLayout.Zones[0], Point{50, 100}, true},
+		{"outside rectangle", testLayout.Zones[0], Point{150, 100}, false},
+		{"below rectangle", testLayout.Zones[0], Point{50, -1}, false},
+		{"inside concave arm", l, Point{25, 75}, true},
+		{"inside concave base", l, Point{75, 25}, true},
+		{"concave notch", l, Point{75, 75}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.zone.Contains(tt.p); got != tt.want {
+				t.Fatalf("Contains(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", `{"zones":[{"zone_id":"bed-a","type":"bed","polygon":[{"x":0,"y":0},{"x":90,"y":0},{"x":90,"y":200}]}]}`, false},
+		{"no zones", `{"other":1}`, false},
+		{"missing type", `{"zones":[{"zone_id":"bed-a","polygon":[{"x":0,"y":0},{"x":1,"y":0},{"x":1,"y":1}]}]}`, true},
+		{"duplicate zone", `{"zones":[` +
+			`{"zone_id":"a","type":"bed","polygon":[{"x":0,"y":0},{"x":1,"y":0},{"x":1,"y":1}]},` +
+			`{"zone_id":"a","type":"chair","polygon":[{"x":0,"y":0},{"x":1,"y":0},{"x":1,"y":1}]}]}`, true},
+		{"too few points", `{"zones":[{"zone_id":"a","type":"bed","polygon":[{"x":0,"y":0},{"x":1,"y":0}]}]}`, true},
+		{"invalid json", `{`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseLayout([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLayout error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManager_Observe(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []Frame
+		want   [][]string // 每帧产生的事件类型
+	}{
+		{
+			name:   "entry outside zones",
+			frames: []Frame{frame(t0, target("1", 200, 300))},
+			want:   [][]string{{events.TrackEntry}},
+		},
+		{
+			name:   "entry into bed",
+			frames: []Frame{frame(t0, target("1", 50, 100))},
+			want:   [][]string{{events.TrackEntry, events.TrackZoneEnter}},
+		},
+		{
+			name: "move from bed to door",
+			frames: []Frame{
+				frame(t0, target("1", 50, 100)),
+				frame(t0.Add(time.Second), target("1", 350, 25)),
+			},
+			want: [][]string{
+				{events.TrackEntry, events.TrackZoneEnter},
+				{events.TrackZoneExit, events.TrackZoneEnter},
+			},
+		},
+		{
+			name: "dwell emitted once",
+			frames: []Frame{
+				frame(t0, target("1", 50, 100)),
+				frame(t0.Add(29*time.Second), target("1", 50, 100)),
+				frame(t0.Add(30*time.Second), target("1", 50, 100)),
+				frame(t0.Add(40*time.Second), target("1", 50, 100)),
+			},
+			want: [][]string{
+				{events.TrackEntry, events.TrackZoneEnter},
+				nil,
+				{events.TrackDwell},
+				nil,
+			},
+		},
+		{
+			name: "targets without tracking id ignored",
+			frames: []Frame{
+				frame(t0, target("", 50, 100)),
+			},
+			want: [][]string{nil},
+		},
+		{
+			name: "lost target finishes when device reports again",
+			frames: []Frame{
+				frame(t0, target("1", 50, 100)),
+				frame(t0.Add(11*time.Second), target("2", 200, 300)),
+			},
+			want: [][]string{
+				{events.TrackEntry, events.TrackZoneEnter},
+				{events.TrackEntry, events.TrackZoneExit, events.TrackExit},
+			},
+		},
+		{
+			name: "earlier frame dropped",
+			frames: []Frame{
+				frame(t0.Add(time.Second), target("1", 50, 100)),
+				frame(t0, target("1", 350, 25)),
+			},
+			want: [][]string{
+				{events.TrackEntry, events.TrackZoneEnter},
+				nil,
+			},
+		},
+		{
+			// 设备只上报秒级时间时，同一秒内的多帧都保留
+			name: "same timestamp frame kept",
+			frames: []Frame{
+				frame(t0, target("1", 50, 100)),
+				frame(t0, target("1", 350, 25)),
+			},
+			want: [][]string{
+				{events.TrackEntry, events.TrackZoneEnter},
+				{events.TrackZoneExit, events.TrackZoneEnter},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(testLayout)
+			for i, f := range tt.frames {
+				if got := m.Observe(f); !equalTypes(got, tt.want[i]...) {
+					t.Fatalf("frame %d events = %v, want %v", i, eventTypes(got), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 离开区域和目标消失时携带停留时长，事件时间为最后一次出现的时间
+func TestManager_ExitCarriesDwell(t *testing.T) {
+	m := newTestManager(testLayout)
+	m.Observe(frame(t0, target("1", 50, 100)))
+	m.Observe(frame(t0.Add(45*time.Second), target("1", 60, 100)))
+
+	if evts := m.Sweep(t0.Add(50 * time.Second)); len(evts) != 0 {
+		t.Fatalf("target within lost timeout should stay, got %v", eventTypes(evts))
+	}
+	evts := m.Sweep(t0.Add(56 * time.Second))
+	if !equalTypes(evts, events.TrackZoneExit, events.TrackExit) {
+		t.Fatalf("sweep events = %v", eventTypes(evts))
+	}
+	exit := evts[0]
+	if exit.Payload.ZoneID != "bed-a" || exit.Payload.DwellSeconds == nil || *exit.Payload.DwellSeconds != 45 {
+		t.Fatalf("zone exit = %+v", exit.Payload)
+	}
+	if !exit.EventTime.Equal(t0.Add(45*time.Second)) || exit.Payload.X != 60 {
+		t.Fatalf("exit should use last seen point, got %v x=%d", exit.EventTime, exit.Payload.X)
+	}
+	if m.Len() != 0 {
+		t.Fatalf("finished target still tracked")
+	}
+}
+
+// 布局修改后已删除的区域视为离开；未配置布局时只产生 entry / exit
+func TestManager_NoLayout(t *testing.T) {
+	m := newTestManager(nil)
+	if got := m.Observe(frame(t0, target("1", 50, 100))); !equalTypes(got, events.TrackEntry) {
+		t.Fatalf("events = %v", eventTypes(got))
+	}
+	if got := m.Sweep(t0.Add(time.Minute)); !equalTypes(got, events.TrackExit) {
+		t.Fatalf("events = %v", eventTypes(got))
+	}
+}
+
+// walk 以 speed（cm/s）沿 X 轴行走，每 step 一帧，返回产生的 gait_speed 事件
+func walk(m *Manager, start time.Time, speed float64, step, duration time.Duration) []Event {
+	var out []Event
+	for d := time.Duration(0); d <= duration; d += step {
+		x := 500 + speed*d.Seconds()
+		for _, e := range m.Observe(frame(start.Add(d), target("1", x, 500))) {
+			if e.Payload.EventType == events.TrackGaitSpeed {
+				out = append(out, e)
+			}
+		}
+	}
+	return out
+}
+
+func TestManager_Gait(t *testing.T) {
+	tests := []struct {
+		name      string
+		speed     float64
+		step      time.Duration
+		duration  time.Duration
+		wantCount int
+		wantSpeed float64
+	}{
+		// 亚秒帧间隔：速度按精确时间计算
+		{"sub-second frames", 80, 100 * time.Millisecond, 4 * time.Second, 1, 80},
+		{"irregular speed rounding", 33.3, 250 * time.Millisecond, 4 * time.Second, 1, 33.3},
+		// 窗口内数据不足一半时不计算
+		{"window not half full", 80, 100 * time.Millisecond, 2 * time.Second, 0, 0},
+		// 原地晃动：位移小于最小距离
+		{"below min distance", 10, 100 * time.Millisecond, 4 * time.Second, 0, 0},
+		// 同一目标两次事件至少间隔 GaitInterval
+		{"interval between events", 80, 500 * time.Millisecond, 40 * time.Second, 2, 80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(nil)
+			gaits := walk(m, t0, tt.speed, tt.step, tt.duration)
+			if len(gaits) != tt.wantCount {
+				t.Fatalf("gait events = %d, want %d", len(gaits), tt.wantCount)
+			}
+			for _, e := range gaits {
+				if e.Payload.SpeedCmPerSec == nil || *e.Payload.SpeedCmPerSec != tt.wantSpeed {
+					t.Fatalf("speed = %v, want %v", e.Payload.SpeedCmPerSec, tt.wantSpeed)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformTargets(t *testing.T) {
+	z := 0
+	targets := []Target{{TrackingID: "1", Position: Point{X: 0, Y: 100}, Z: &z}}
+	pose := &MountPose{UnitID: "unit-1", Position: Point{X: 200, Y: 0}, HeightCm: 250}
+
+	out := transformTargets(pose, "unit-1", targets)
+	if out[0].Position != (Point{X: 200, Y: 100}) || out[0].Z == nil || *out[0].Z != 250 {
+		t.Fatalf("transformed target = %+v z=%v", out[0].Position, *out[0].Z)
+	}
+	if targets[0].Position != (Point{X: 0, Y: 100}) {
+		t.Fatal("input targets must not be modified")
+	}
+	// 姿态所属单元与卡片单元不一致时保持雷达坐标
+	if out := transformTargets(pose, "unit-2", targets); out[0].Position != targets[0].Position {
+		t.Fatalf("mismatched unit should keep radar coordinates, got %+v", out[0].Position)
+	}
+}
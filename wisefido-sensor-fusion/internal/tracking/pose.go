@@ -0,0 +1,64 @@
+package tracking
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// MountPose 雷达安装姿态（config_versions.device_installation，由 wisefido-data 校验后写入）
+//
+// 雷达坐标系：X 向右、Y 为正前方、Z 向上（以雷达自身为准）；
+// 布局坐标系：俯视，X 向右、Y 向上，单位 cm。
+type MountPose struct {
+	UnitID      string  `json:"unit_id"`      // 布局坐标所属单元
+	Position    Point   `json:"position"`     // 雷达在布局中的位置
+	HeightCm    float64 `json:"height_cm"`    // 安装高度（离地）
+	TiltDeg     float64 `json:"tilt_deg"`     // 正前方相对水平向下倾斜的角度（0 ~ 90）
+	RotationDeg float64 `json:"rotation_deg"` // 正前方相对布局 +Y 轴逆时针旋转的角度
+}
+
+// ParseMountPose 解析安装姿态
+func ParseMountPose(data []byte) (*MountPose, error) {
+	var pose MountPose
+	if err := json.Unmarshal(data, &pose); err != nil {
+		return nil, fmt.Errorf("failed to parse installation: %w", err)
+	}
+	if pose.UnitID == "" {
+		return nil, fmt.Errorf("installation: unit_id is required")
+	}
+	if pose.TiltDeg < 0 || pose.TiltDeg > 90 {
+		return nil, fmt.Errorf("installation: tilt_deg out of range")
+	}
+	return &pose, nil
+}
+
+// ToRoom 雷达坐标转换为布局坐标：先按俯仰角转为水平坐标系，再按朝向旋转并平移到安装位置
+// 返回布局平面位置和离地高度（z 缺失时按 0 处理）
+func (p *MountPose) ToRoom(x, y, z float64) (Point, float64) {
+	tilt := p.TiltDeg * math.Pi / 180
+	// 俯仰：正前方向下倾斜 tilt，水平前向距离和离地高度
+	forward := y*math.Cos(tilt) + z*math.Sin(tilt)
+	height := p.HeightCm - y*math.Sin(tilt) + z*math.Cos(tilt)
+
+	// 朝向：正前方 (−sinψ, cosψ)，右侧 (cosψ, sinψ)
+	rot := p.RotationDeg * math.Pi / 180
+	return Point{
+		X: p.Position.X + x*math.Cos(rot) - forward*math.Sin(rot),
+		Y: p.Position.Y + x*math.Sin(rot) + forward*math.Cos(rot),
+	}, height
+}
+
+// toRoom 转换目标坐标（原地修改），z 转换为离地高度
+func (p *MountPose) toRoom(target *Target) {
+	var z float64
+	if target.Z != nil {
+		z = float64(*target.Z)
+	}
+	pos, height := p.ToRoom(target.Position.X, target.Position.Y, z)
+	target.Position = pos
+	if target.Z != nil {
+		h := int(math.Round(height))
+		target.Z = &h
+	}
+}
@@ -0,0 +1,52 @@
+package tracking
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"owl-common/config"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+)
+
+// Publisher 轨迹事件发布器（track:event:stream）
+type Publisher struct {
+	publisher *rediscommon.StreamPublisher
+	logger    *zap.Logger
+}
+
+// NewPublisher 创建轨迹事件发布器
+func NewPublisher(client *redis.Client, stream string, cfg config.StreamConfig, logger *zap.Logger) *Publisher {
+	return &Publisher{
+		publisher: rediscommon.NewStreamPublisher(client, stream, cfg, logger),
+		logger:    logger,
+	}
+}
+
+// Report 定期输出轨迹事件流的发布统计和积压（阻塞直到 ctx 结束）
+func (p *Publisher) Report(ctx context.Context) {
+	p.publisher.Report(ctx)
+}
+
+// Publish 发布轨迹事件（事件不抽样；发布失败只记录日志，轨迹状态不回退）
+func (p *Publisher) Publish(ctx context.Context, evs []Event) {
+	for _, e := range evs {
+		env := rediscommon.NewEnvelope(events.TrackEventSchema, "wisefido-sensor-fusion", e.TenantID, e.EventTime, e.Payload)
+		if _, err := rediscommon.Publish(ctx, p.publisher, env, rediscommon.PriorityNormal); err != nil {
+			p.logger.Warn("Failed to publish track event",
+				zap.String("device_id", e.Payload.DeviceID),
+				zap.String("tracking_id", e.Payload.TrackingID),
+				zap.String("event_type", e.Payload.EventType),
+				zap.Error(err),
+			)
+			continue
+		}
+		p.logger.Debug("Published track event",
+			zap.String("device_id", e.Payload.DeviceID),
+			zap.String("tracking_id", e.Payload.TrackingID),
+			zap.String("event_type", e.Payload.EventType),
+			zap.String("zone_id", e.Payload.ZoneID),
+		)
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"owl-common/config"
 )
 
@@ -24,6 +26,19 @@ type Config struct {
 		ReportUploadTime int    // 报告上传时间
 		Topic            string // MQTT 主题（Sleepace 厂家提供的主题，如 "sleepace-57136"）
 		Stream           string // Redis Streams 输出流，如 "sleepace:data:stream"
+		AlarmStream      string // 设备报警事件流（alarmNotify -> wisefido-alarm），如 "device:alarm:stream"
+		ReportStream     string // 睡眠报告就绪事件流（analysis -> wisefido-data 下载报告），如 "sleepace:report:stream"
+		DualSidedModels  []string // 双侧睡眠垫型号（device_store.device_model），左右两侧分别作为逻辑传感器
+		LowBatteryThreshold int // 低电量报警阈值（%），0 表示关闭
+		StreamPolicy     config.StreamConfig // 输出流保留策略与背压策略
+		IngestPolicy     config.IngestPolicyConfig // 采集准入策略（按设备业务状态丢弃或隔离）
+		DedupTTL         int    // 去重窗口（秒），0 表示关闭；多副本滚动发布时避免重复发布
+		DeviceCache      struct {
+			TTL         int // 设备身份缓存时间（秒），0 表示关闭
+			NegativeTTL int // 未授权标识符缓存时间（秒），0 表示不缓存
+		}
+		Ingest           config.WorkerPoolConfig // MQTT 消息处理工作池（按设备分片）
+		Presence         config.PresenceConfig   // 设备在线状态跟踪（静默超时或厂家上报离线时置为 offline）
 	}
 	
 	Log struct {
@@ -52,6 +67,7 @@ func Load() (*Config, error) {
 	cfg.MQTT.ClientID = getEnv("MQTT_CLIENT_ID", "wisefido-sleepace")
 	cfg.MQTT.Username = getEnv("MQTT_USERNAME", "wisefido")
 	cfg.MQTT.Password = getEnv("MQTT_PASSWORD", "")
+	cfg.MQTT.LoadFromEnv("MQTT") // 持久会话、TLS/mTLS 等配置（MQTT_CLEAN_SESSION、MQTT_TLS_*）
 	
 	// Sleepace 服务配置
 	cfg.Sleepace.HttpAddress = getEnv("SLEEPACE_HTTP_ADDRESS", "http://47.90.180.176:8080")
@@ -65,6 +81,37 @@ func Load() (*Config, error) {
 	cfg.Sleepace.ReportUploadTime = 0
 	cfg.Sleepace.Topic = getEnv("SLEEPACE_MQTT_TOPIC", "sleepace-57136")
 	cfg.Sleepace.Stream = getEnv("SLEEPACE_STREAM", "sleepace:data:stream")
+	cfg.Sleepace.AlarmStream = getEnv("SLEEPACE_ALARM_STREAM", "device:alarm:stream")
+	cfg.Sleepace.ReportStream = getEnv("SLEEPACE_REPORT_STREAM", "sleepace:report:stream")
+	for _, model := range strings.Split(getEnv("SLEEPACE_DUAL_SIDED_MODELS", ""), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			cfg.Sleepace.DualSidedModels = append(cfg.Sleepace.DualSidedModels, model)
+		}
+	}
+	cfg.Sleepace.LowBatteryThreshold = 20
+	if v, err := strconv.Atoi(getEnv("SLEEPACE_LOW_BATTERY_THRESHOLD", "20")); err == nil && v >= 0 {
+		cfg.Sleepace.LowBatteryThreshold = v
+	}
+	cfg.Sleepace.DedupTTL = 300
+	if v, err := strconv.Atoi(getEnv("SLEEPACE_DEDUP_TTL", "300")); err == nil && v >= 0 {
+		cfg.Sleepace.DedupTTL = v
+	}
+	cfg.Sleepace.DeviceCache.TTL = 300
+	if v, err := strconv.Atoi(getEnv("SLEEPACE_DEVICE_CACHE_TTL", "300")); err == nil && v >= 0 {
+		cfg.Sleepace.DeviceCache.TTL = v
+	}
+	cfg.Sleepace.DeviceCache.NegativeTTL = 60
+	if v, err := strconv.Atoi(getEnv("SLEEPACE_DEVICE_CACHE_NEGATIVE_TTL", "60")); err == nil && v >= 0 {
+		cfg.Sleepace.DeviceCache.NegativeTTL = v
+	}
+	cfg.Sleepace.StreamPolicy = config.DefaultStreamConfig()
+	cfg.Sleepace.StreamPolicy.LoadFromEnv("STREAM_SLEEPACE")
+	cfg.Sleepace.IngestPolicy = config.DefaultIngestPolicyConfig()
+	cfg.Sleepace.IngestPolicy.LoadFromEnv("INGEST_POLICY") // INGEST_POLICY_ENABLED、INGEST_POLICY_QUARANTINE 等
+	cfg.Sleepace.Ingest = config.DefaultWorkerPoolConfig()
+	cfg.Sleepace.Ingest.LoadFromEnv("SLEEPACE_INGEST") // SLEEPACE_INGEST_WORKERS、SLEEPACE_INGEST_QUEUE_SIZE、SLEEPACE_INGEST_OVERFLOW
+	cfg.Sleepace.Presence = config.DefaultPresenceConfig()
+	cfg.Sleepace.Presence.LoadFromEnv("SLEEPACE_PRESENCE") // SLEEPACE_PRESENCE_TIMEOUT、SLEEPACE_PRESENCE_SWEEP_INTERVAL 等
 	
 	cfg.Log.Level = getEnv("LOG_LEVEL", "info")
 	cfg.Log.Format = getEnv("LOG_FORMAT", "json")
@@ -0,0 +1,86 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"wisefido-sleepace/internal/models"
+	"wisefido-sleepace/internal/repository"
+
+	"go.uber.org/zap"
+	"owl-common/config"
+	"owl-common/events"
+	"owl-common/ingest"
+	rediscommon "owl-common/redis"
+)
+
+// deviceAlarmStreamMaxLen 设备报警事件流最大长度（近似裁剪）
+const deviceAlarmStreamMaxLen = 10000
+
+// Sleepace alarmNotify 报警状态
+const (
+	sleepaceAlarmTrigger = 0
+	sleepaceAlarmRelieve = 1
+)
+
+// deviceAlarmFromNotify alarmNotify -> 设备报警事件
+func deviceAlarmFromNotify(device *repository.Device, alarmData *models.AlarmNotifyData) (events.DeviceAlarm, error) {
+	payload := newDeviceAlarm(device, alarmData.Type)
+	payload.UserID = alarmData.UserId
+	if alarmData.Id != 0 {
+		payload.VendorAlarmID = strconv.FormatInt(alarmData.Id, 10)
+	}
+	switch alarmData.Status {
+	case sleepaceAlarmTrigger:
+		payload.Status = events.DeviceAlarmTrigger
+	case sleepaceAlarmRelieve:
+		payload.Status = events.DeviceAlarmRelieve
+		payload.RelieveReason = alarmData.RelieveReason
+		if t, ok := ingest.ParseDeviceTime(alarmData.RelieveTime); ok {
+			payload.RelieveTime = &t
+		}
+	default:
+		return payload, fmt.Errorf("unknown sleepace alarm status: %d", alarmData.Status)
+	}
+	return payload, nil
+}
+
+// newDeviceAlarm 构建设备报警事件（状态由调用方填充）
+func newDeviceAlarm(device *repository.Device, vendorType string) events.DeviceAlarm {
+	return events.DeviceAlarm{
+		DeviceID:     device.DeviceID,
+		SerialNumber: device.SerialNumber,
+		UID:          device.UID,
+		DeviceType:   "Sleepace",
+		VendorType:   vendorType,
+	}
+}
+
+// publishDeviceAlarm 发布设备报警事件到 device:alarm:stream（由 wisefido-alarm 写入 alarm_events）
+// 仅在数据已通过准入检查后调用
+func (c *MQTTConsumer) publishDeviceAlarm(msg *models.ReceivedMessage, device *repository.Device, payload events.DeviceAlarm) (string, error) {
+	eventTime, ok := ingest.ParseDeviceTime(msg.TimeStamp)
+	if !ok {
+		eventTime = time.Now()
+	}
+	env := rediscommon.NewEnvelope(events.DeviceAlarmSchema, "wisefido-sleepace", device.TenantID, eventTime, payload)
+	values, err := rediscommon.Encode(env)
+	if err != nil {
+		return "", err
+	}
+	retention := config.StreamConfig{MaxLen: deviceAlarmStreamMaxLen}
+	streamID, err := rediscommon.PublishToStreamWithRetention(context.Background(), c.redisClient, c.config.Sleepace.AlarmStream, values, retention)
+	if err != nil {
+		return "", fmt.Errorf("failed to publish device alarm: %w", err)
+	}
+
+	c.logger.Info("Published sleepace device alarm",
+		zap.String("device_id", device.DeviceID),
+		zap.String("vendor_type", payload.VendorType),
+		zap.String("vendor_alarm_id", payload.VendorAlarmID),
+		zap.String("status", payload.Status),
+		zap.String("stream_id", streamID),
+	)
+	return streamID, nil
+}
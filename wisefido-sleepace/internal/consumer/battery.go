@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"wisefido-sleepace/internal/models"
+	"wisefido-sleepace/internal/repository"
+
+	"go.uber.org/zap"
+	"owl-common/events"
+	rediscommon "owl-common/redis"
+)
+
+// lowBatteryVendorType 低电量报警的厂家报警类型（wisefido-alarm 映射为 LowBattery）
+const lowBatteryVendorType = "lowBattery"
+
+// handleBattery 处理电量数据
+// 电量写入 devices.metadata 并发布到数据流；电量低于阈值（且未充电）时触发低电量报警，恢复后解除
+func (c *MQTTConsumer) handleBattery(msg *models.ReceivedMessage, device *repository.Device) error {
+	var batteryData models.BatteryData
+	if err := json.Unmarshal(msg.Data, &batteryData); err != nil {
+		return fmt.Errorf("failed to unmarshal battery data: %w", err)
+	}
+
+	ctx := context.Background()
+	if err := c.deviceRepo.UpdateMetadata(ctx, device.DeviceID, map[string]interface{}{
+		"battery_level":   batteryData.Battery,
+		"charging":        batteryData.Charging == 1,
+		"last_battery_at": time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		c.logger.Warn("Failed to update sleepace battery metadata",
+			zap.String("device_id", device.DeviceID),
+			zap.Error(err),
+		)
+	}
+
+	rawData := map[string]interface{}{
+		"battery":  batteryData.Battery,
+		"charging": batteryData.Charging,
+	}
+	envelope := c.newEnvelope(msg, device, rawData, "sleepace/battery")
+	streamID, err := c.publish(envelope, device, rediscommon.PriorityLow)
+	if err != nil {
+		return fmt.Errorf("failed to publish to stream: %w", err)
+	}
+	if streamID == "" {
+		// 被准入策略拦截，或下游积压时被抽样丢弃
+		return nil
+	}
+
+	c.logger.Debug("Published sleepace battery data to Redis Streams",
+		zap.String("device_id", device.DeviceID),
+		zap.Int("battery", batteryData.Battery),
+		zap.String("stream_id", streamID),
+	)
+
+	return c.checkLowBattery(msg, device, &batteryData)
+}
+
+// checkLowBattery 低电量状态变化时发布设备报警（触发 / 解除）
+// 重启后首次上报正常电量不发布解除，首次上报低电量会重复触发（报警服务按未处理事件去重）
+func (c *MQTTConsumer) checkLowBattery(msg *models.ReceivedMessage, device *repository.Device, batteryData *models.BatteryData) error {
+	if c.config.Sleepace.LowBatteryThreshold <= 0 {
+		return nil
+	}
+	low := batteryData.Battery < c.config.Sleepace.LowBatteryThreshold && batteryData.Charging != 1
+	prev, _, known := c.lowBattery.Get(device.DeviceID)
+	if known && prev == low {
+		return nil
+	}
+	if !known && !low {
+		c.lowBattery.Set(device.DeviceID, false)
+		return nil
+	}
+
+	alarm := newDeviceAlarm(device, lowBatteryVendorType)
+	alarm.Status = events.DeviceAlarmTrigger
+	if !low {
+		alarm.Status = events.DeviceAlarmRelieve
+		alarm.RelieveReason = fmt.Sprintf("battery %d%%", batteryData.Battery)
+	}
+	if _, err := c.publishDeviceAlarm(msg, device, alarm); err != nil {
+		// 状态未更新，下次上报重试
+		return err
+	}
+	c.lowBattery.Set(device.DeviceID, low)
+	return nil
+}
@@ -0,0 +1,64 @@
+package consumer
+
+import (
+	"testing"
+	"wisefido-sleepace/internal/models"
+
+	"owl-common/events"
+)
+
+// 低电量只在状态变化时发布：首次正常不发布，触发后不重复，恢复后解除，充电中不视为低电量
+func TestCheckLowBattery(t *testing.T) {
+	c, client, _ := newTestConsumer(t)
+	device := approvedDevice("BM8701")
+	msg := newMessage(t, "battery", nil)
+
+	steps := []models.BatteryData{
+		{Battery: 80},
+		{Battery: 15},
+		{Battery: 10},
+		{Battery: 10, Charging: 1},
+		{Battery: 12},
+	}
+	for i := range steps {
+		if err := c.checkLowBattery(msg, device, &steps[i]); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	alarms := decodeAlarms(t, client)
+	want := []string{events.DeviceAlarmTrigger, events.DeviceAlarmRelieve, events.DeviceAlarmTrigger}
+	if len(alarms) != len(want) {
+		t.Fatalf("expected %d alarms, got %+v", len(want), alarms)
+	}
+	for i, a := range alarms {
+		if a.Status != want[i] || a.VendorType != lowBatteryVendorType || a.DeviceID != "pad-1" {
+			t.Errorf("alarm %d = %+v, want status %s", i, a, want[i])
+		}
+	}
+	if alarms[1].RelieveReason != "battery 10%" {
+		t.Errorf("relieve reason = %q", alarms[1].RelieveReason)
+	}
+}
+
+// 重启后首次上报低电量即触发
+func TestCheckLowBattery_FirstLow(t *testing.T) {
+	c, client, _ := newTestConsumer(t)
+	if err := c.checkLowBattery(newMessage(t, "battery", nil), approvedDevice("BM8701"), &models.BatteryData{Battery: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if alarms := decodeAlarms(t, client); len(alarms) != 1 || alarms[0].Status != events.DeviceAlarmTrigger {
+		t.Fatalf("expected one trigger, got %+v", alarms)
+	}
+}
+
+func TestCheckLowBattery_Disabled(t *testing.T) {
+	c, client, _ := newTestConsumer(t)
+	c.config.Sleepace.LowBatteryThreshold = 0
+	if err := c.checkLowBattery(newMessage(t, "battery", nil), approvedDevice("BM8701"), &models.BatteryData{Battery: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if alarms := decodeAlarms(t, client); len(alarms) != 0 {
+		t.Fatalf("threshold 0 should disable alarms, got %+v", alarms)
+	}
+}
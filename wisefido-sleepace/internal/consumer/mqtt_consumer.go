@@ -2,16 +2,26 @@ package consumer
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
 	"wisefido-sleepace/internal/config"
 	"wisefido-sleepace/internal/models"
 	"wisefido-sleepace/internal/repository"
 	
 	"go.uber.org/zap"
 	"github.com/go-redis/redis/v8"
+	"owl-common/cache"
+	"owl-common/events"
+	"owl-common/ingest"
+	"owl-common/presence"
 	rediscommon "owl-common/redis"
 	mqttcommon "owl-common/mqtt"
+	"owl-common/workerpool"
 )
 
 // MQTTConsumer MQTT消息消费者
@@ -19,7 +29,13 @@ type MQTTConsumer struct {
 	config     *config.Config
 	mqttClient *mqttcommon.Client
 	redisClient *redis.Client
+	publisher  *rediscommon.StreamPublisher
+	dedup      *rediscommon.Deduplicator // nil 表示不去重
+	gate       *ingest.Gate              // 采集准入策略
+	presence   *presence.Tracker         // 设备在线状态跟踪
 	deviceRepo *repository.DeviceRepository
+	pool       *workerpool.Pool // paho 回调只投递，查询和发布在工作池中执行
+	lowBattery *cache.TTL[bool] // 设备最近一次上报的低电量状态（状态变化时发布报警 / 解除）
 	logger     *zap.Logger
 }
 
@@ -29,13 +45,24 @@ func NewMQTTConsumer(
 	mqttClient *mqttcommon.Client,
 	redisClient *redis.Client,
 	deviceRepo *repository.DeviceRepository,
+	tracker *presence.Tracker,
 	logger *zap.Logger,
 ) *MQTTConsumer {
+	var dedup *rediscommon.Deduplicator
+	if cfg.Sleepace.DedupTTL > 0 {
+		dedup = rediscommon.NewDeduplicator(redisClient, "dedup:sleepace", time.Duration(cfg.Sleepace.DedupTTL)*time.Second)
+	}
 	return &MQTTConsumer{
 		config:     cfg,
 		mqttClient: mqttClient,
 		redisClient: redisClient,
+		publisher:  rediscommon.NewStreamPublisher(redisClient, cfg.Sleepace.Stream, cfg.Sleepace.StreamPolicy, logger),
+		dedup:      dedup,
+		gate:       ingest.NewGate(redisClient, cfg.Sleepace.IngestPolicy, logger),
+		presence:   tracker,
 		deviceRepo: deviceRepo,
+		pool:       workerpool.New("sleepace-ingest", cfg.Sleepace.Ingest, logger),
+		lowBattery: cache.NewTTL[bool](24*time.Hour, 0),
 		logger:     logger,
 	}
 }
@@ -48,13 +75,19 @@ func (c *MQTTConsumer) Start(ctx context.Context) error {
 	if topic == "" {
 		return fmt.Errorf("sleepace MQTT topic not configured")
 	}
+	// 配置 MQTT_SHARED_GROUP 时使用共享订阅，多副本分摊消息
+	topic = mqttcommon.SharedTopic(c.config.MQTT.SharedGroup, topic)
+	
+	c.pool.Start(ctx)
+	go c.publisher.Report(ctx)
 	
-	if err := c.mqttClient.Subscribe(topic, 1, c.handleMessage); err != nil {
+	if err := c.mqttClient.SubscribeWithAck(topic, 1, c.handleMessage); err != nil {
 		return fmt.Errorf("failed to subscribe to sleepace topic: %w", err)
 	}
 	
 	c.logger.Info("MQTT consumer started",
 		zap.String("topic", topic),
+		zap.String("shared_group", c.config.MQTT.SharedGroup),
 		zap.String("stream", c.config.Sleepace.Stream),
 	)
 	
@@ -68,17 +101,22 @@ func (c *MQTTConsumer) Stop(ctx context.Context) error {
 	// 取消订阅
 	topic := c.config.Sleepace.Topic
 	if topic != "" {
-		if err := c.mqttClient.Unsubscribe(topic); err != nil {
+		if err := c.mqttClient.Unsubscribe(mqttcommon.SharedTopic(c.config.MQTT.SharedGroup, topic)); err != nil {
 			c.logger.Error("Failed to unsubscribe", zap.Error(err))
 		}
 	}
 	
+	// 处理完已排队的消息
+	c.pool.Stop()
+	
 	c.logger.Info("MQTT consumer stopped")
 	return nil
 }
 
 // handleMessage 处理MQTT消息
-func (c *MQTTConsumer) handleMessage(topic string, payload []byte) error {
+// 一条 MQTT 消息包含多个设备的数据，每条数据处理完成、因队列满被丢弃或因工作池停止未投递后计数，
+// 全部计数后确认
+func (c *MQTTConsumer) handleMessage(topic string, payload []byte, ack func()) error {
 	c.logger.Debug("Received MQTT message",
 		zap.String("topic", topic),
 		zap.Int("payload_size", len(payload)),
@@ -95,42 +133,85 @@ func (c *MQTTConsumer) handleMessage(topic string, payload []byte) error {
 		return fmt.Errorf("failed to unmarshal message: %w", err)
 	}
 	
-	// 2. 处理每个消息
-	for _, msg := range messages {
-		if err := c.processMessage(&msg); err != nil {
-			c.logger.Error("Failed to process message",
-				zap.String("device_id", msg.DeviceId),
-				zap.String("data_key", msg.DataKey),
-				zap.Error(err),
-			)
-			// 继续处理下一条消息，不中断
+	// 2. 按设备投递到工作池：同一设备的消息按序处理，不同设备并行
+	// 队列满时按溢出策略丢弃，丢弃计数由工作池统计并定期输出
+	if len(messages) == 0 {
+		ack()
+		return nil
+	}
+	remaining := int32(len(messages))
+	done := func() {
+		if atomic.AddInt32(&remaining, -1) == 0 {
+			ack()
 		}
 	}
+	for i := range messages {
+		msg := &messages[i]
+		dropped := false
+		if c.pool.SubmitWithDrop(msg.DeviceId, func() {
+			defer done()
+			if err := c.processMessage(msg); err != nil {
+				c.logger.Error("Failed to process message",
+					zap.String("device_id", msg.DeviceId),
+					zap.String("data_key", msg.DataKey),
+					zap.Error(err),
+				)
+			}
+		}, func() {
+			dropped = true
+			done()
+		}) || dropped {
+			continue
+		}
+		// 工作池已停止（不调用 onDrop）：当前及剩余的数据不再投递，逐条计数，保证整条消息被确认
+		for range messages[i:] {
+			done()
+		}
+		break
+	}
 	
 	return nil
 }
 
 // processMessage 处理单条 Sleepace 消息
 func (c *MQTTConsumer) processMessage(msg *models.ReceivedMessage) error {
-	// 1. 查询设备信息（如果不存在，尝试从 device_store 自动创建）
-	device, err := c.deviceRepo.GetDeviceByCode(msg.DeviceId)
-	if err != nil {
-		// 设备不存在，尝试从 device_store 自动创建
-		device, err = c.deviceRepo.GetOrCreateDeviceFromStore(context.Background(), msg.DeviceId, "sleepace/realtime")
+	// 去重：滚动发布期间新旧副本可能同时收到同一条消息
+	// 同一设备同一时间戳可能有多种数据（dataKey）及左右两侧数据，键中附加内容摘要
+	if c.dedup != nil {
+		sum := sha1.Sum(msg.Data)
+		first, err := c.dedup.FirstSeen(context.Background(), msg.DeviceId, strconv.FormatInt(msg.TimeStamp, 10), msg.DataKey, hex.EncodeToString(sum[:8]))
 		if err != nil {
-			c.logger.Warn("Device not found and cannot be created from device_store",
+			c.logger.Warn("Failed to check duplicate message", zap.Error(err))
+		}
+		if !first {
+			c.logger.Debug("Skipping duplicate sleepace message",
 				zap.String("device_code", msg.DeviceId),
 				zap.String("data_key", msg.DataKey),
-				zap.Error(err),
+				zap.Int64("timestamp", msg.TimeStamp),
 			)
-			return fmt.Errorf("device not found: %s", msg.DeviceId)
+			return nil
 		}
-		// 设备已从 device_store 自动创建
-		c.logger.Info("Device auto-created from device_store on MQTT connection",
-			zap.String("device_id", device.DeviceID),
+	}
+	
+	// 1. 解析设备（带身份缓存；不存在时尝试从 device_store 自动创建）
+	device, err := c.deviceRepo.ResolveDevice(context.Background(), msg.DeviceId, "sleepace/realtime")
+	if err != nil {
+		c.logger.Warn("Device not found and cannot be created from device_store",
 			zap.String("device_code", msg.DeviceId),
 			zap.String("data_key", msg.DataKey),
+			zap.Error(err),
 		)
+		return fmt.Errorf("device not found: %s", msg.DeviceId)
+	}
+	
+	// 记录最近消息时间（connectionStatus 由厂家平台上报，单独处理）
+	if msg.DataKey != "connectionStatus" {
+		if err := c.presence.Seen(context.Background(), device.DeviceID); err != nil {
+			c.logger.Warn("Failed to record device presence",
+				zap.String("device_id", device.DeviceID),
+				zap.Error(err),
+			)
+		}
 	}
 	
 	// 2. 根据 DataKey 处理不同类型的数据
@@ -148,6 +229,13 @@ func (c *MQTTConsumer) processMessage(msg *models.ReceivedMessage) error {
 	case "alarmNotify":
 		// 报警通知可以发布到 Streams 或单独处理
 		return c.handleAlarmNotify(msg, device)
+	case "battery":
+		return c.handleBattery(msg, device)
+	case "summary":
+		return c.handleSleepSummary(msg, device)
+	case "analysis":
+		// 厂家平台睡眠分析完成：通知 wisefido-data 下载报告
+		return c.handleAnalysis(msg, device)
 	default:
 		// 其他类型的数据可以忽略或单独处理
 		c.logger.Debug("Unhandled data key",
@@ -158,6 +246,43 @@ func (c *MQTTConsumer) processMessage(msg *models.ReceivedMessage) error {
 	}
 }
 
+// newEnvelope 构建 device.data 信封（事件时间为设备上报时间，没有时使用接收时间）
+func (c *MQTTConsumer) newEnvelope(msg *models.ReceivedMessage, device *repository.Device, rawData map[string]interface{}, topic string) rediscommon.Envelope[events.DeviceData] {
+	eventTime, ok := ingest.ParseDeviceTime(msg.TimeStamp)
+	if !ok {
+		eventTime = time.Now()
+	}
+	return rediscommon.NewEnvelope(events.DeviceDataSchema, "wisefido-sleepace", device.TenantID, eventTime, events.DeviceData{
+		DeviceID:     device.DeviceID,
+		SerialNumber: device.SerialNumber,
+		UID:          device.UID,
+		DeviceType:   "Sleepace",
+		RawData:      rawData,
+		Topic:        topic,
+		Model:           device.Model,
+		FirmwareVersion: device.FirmwareVersion,
+	})
+}
+
+// publish 执行准入检查后发布到 Redis Streams
+// 待审批、已拒绝、已禁用、未开启监测的设备数据被丢弃或隔离，此时返回空的 stream id
+func (c *MQTTConsumer) publish(envelope rediscommon.Envelope[events.DeviceData], device *repository.Device, priority rediscommon.Priority) (string, error) {
+	ctx := context.Background()
+	admitted, err := c.gate.Admit(ctx, envelope, c.publisher.Stream(), ingest.DeviceState{
+		Status:            device.Status,
+		BusinessAccess:    device.BusinessAccess,
+		MonitoringEnabled: device.MonitoringEnabled,
+		AllowAccess:       device.AllowAccess,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to apply ingest policy: %w", err)
+	}
+	if !admitted {
+		return "", nil
+	}
+	return rediscommon.Publish(ctx, c.publisher, envelope, priority)
+}
+
 // handleRealtimeData 处理实时数据
 func (c *MQTTConsumer) handleRealtimeData(msg *models.ReceivedMessage, device *repository.Device) error {
 	// 解析实时数据
@@ -166,36 +291,41 @@ func (c *MQTTConsumer) handleRealtimeData(msg *models.ReceivedMessage, device *r
 		return fmt.Errorf("failed to unmarshal realtime data: %w", err)
 	}
 	
-	// 构建标准化数据（与 wisefido-radar 格式一致）
-	standardizedData := map[string]interface{}{
-		"device_id":      device.DeviceID,
-		"tenant_id":      device.TenantID,
-		"serial_number":  device.SerialNumber,
-		"uid":            device.UID,
-		"device_type":    "Sleepace", // 或 "SleepPad"
-		"raw_data": map[string]interface{}{
-			"breath":        realtimeData.Breath,
-			"heart":         realtimeData.Heart,
-			"turnOver":      realtimeData.TurnOver,
-			"bodyMove":      realtimeData.BodyMove,
-			"sitUp":         realtimeData.SitUp,
-			"initStatus":    realtimeData.InitStatus,
-			"bedStatus":     realtimeData.BedStatus,
-			"signalQuality": realtimeData.SignalQuality,
-			"leftRight":     realtimeData.LeftRight,
-		},
-		"timestamp": msg.TimeStamp,
-		"topic":     "sleepace/realtime",
+	// 双侧睡眠垫：数据归属该侧的逻辑传感器
+	sensor, side, err := c.sensorFor(device, realtimeData.LeftRight)
+	if err != nil {
+		return err
+	}
+	
+	// 构建标准化数据（device.data 信封）
+	rawData := map[string]interface{}{
+		"breath":        realtimeData.Breath,
+		"heart":         realtimeData.Heart,
+		"turnOver":      realtimeData.TurnOver,
+		"bodyMove":      realtimeData.BodyMove,
+		"sitUp":         realtimeData.SitUp,
+		"initStatus":    realtimeData.InitStatus,
+		"bedStatus":     realtimeData.BedStatus,
+		"signalQuality": realtimeData.SignalQuality,
+		"leftRight":     realtimeData.LeftRight,
 	}
+	if side != "" {
+		rawData["side"] = side
+	}
+	envelope := c.newEnvelope(msg, sensor, rawData, "sleepace/realtime")
 	
 	// 发布到 Redis Streams
-	streamID, err := rediscommon.PublishJSONToStream(context.Background(), c.redisClient, c.config.Sleepace.Stream, standardizedData)
+	streamID, err := c.publish(envelope, sensor, rediscommon.PriorityLow)
 	if err != nil {
 		return fmt.Errorf("failed to publish to stream: %w", err)
 	}
+	if streamID == "" {
+		// 被准入策略拦截，或下游积压时实时数据被抽样丢弃
+		return nil
+	}
 	
 	c.logger.Info("Published sleepace realtime data to Redis Streams",
-		zap.String("device_id", device.DeviceID),
+		zap.String("device_id", sensor.DeviceID),
 		zap.String("stream", c.config.Sleepace.Stream),
 		zap.String("stream_id", streamID),
 	)
@@ -211,29 +341,33 @@ func (c *MQTTConsumer) handleSleepStageData(msg *models.ReceivedMessage, device
 		return fmt.Errorf("failed to unmarshal sleep stage data: %w", err)
 	}
 	
-	// 构建标准化数据
-	standardizedData := map[string]interface{}{
-		"device_id":      device.DeviceID,
-		"tenant_id":      device.TenantID,
-		"serial_number":  device.SerialNumber,
-		"uid":            device.UID,
-		"device_type":    "Sleepace",
-		"raw_data": map[string]interface{}{
-			"sleepStage": sleepStageData.SleepStage,
-			"leftRight":  sleepStageData.LeftRight,
-		},
-		"timestamp": msg.TimeStamp,
-		"topic":     "sleepace/sleepStage",
+	sensor, side, err := c.sensorFor(device, sleepStageData.LeftRight)
+	if err != nil {
+		return err
+	}
+	
+	// 构建标准化数据（device.data 信封）
+	rawData := map[string]interface{}{
+		"sleepStage": sleepStageData.SleepStage,
+		"leftRight":  sleepStageData.LeftRight,
+	}
+	if side != "" {
+		rawData["side"] = side
 	}
+	envelope := c.newEnvelope(msg, sensor, rawData, "sleepace/sleepStage")
 	
 	// 发布到 Redis Streams
-	streamID, err := rediscommon.PublishJSONToStream(context.Background(), c.redisClient, c.config.Sleepace.Stream, standardizedData)
+	streamID, err := c.publish(envelope, sensor, rediscommon.PriorityNormal)
 	if err != nil {
 		return fmt.Errorf("failed to publish to stream: %w", err)
 	}
+	if streamID == "" {
+		// 被准入策略拦截
+		return nil
+	}
 	
 	c.logger.Info("Published sleepace sleep stage data to Redis Streams",
-		zap.String("device_id", device.DeviceID),
+		zap.String("device_id", sensor.DeviceID),
 		zap.String("stream", c.config.Sleepace.Stream),
 		zap.String("stream_id", streamID),
 	)
@@ -250,23 +384,29 @@ func (c *MQTTConsumer) handleConnectionStatus(msg *models.ReceivedMessage, devic
 		return fmt.Errorf("failed to unmarshal connection status data: %w", err)
 	}
 	
-	standardizedData := map[string]interface{}{
-		"device_id":      device.DeviceID,
-		"tenant_id":      device.TenantID,
-		"serial_number":  device.SerialNumber,
-		"uid":            device.UID,
-		"device_type":    "Sleepace",
-		"raw_data": map[string]interface{}{
-			"connectionStatus": connData.ConnectionStatus,
-		},
-		"timestamp": msg.TimeStamp,
-		"topic":     "sleepace/connectionStatus",
+	// 厂家平台上报的连接状态直接切换在线状态（离线无需等待静默窗口）
+	if err := c.presence.Report(context.Background(), device.DeviceID, connData.ConnectionStatus == 1, presence.ReasonReported, nil); err != nil {
+		c.logger.Warn("Failed to apply reported connection status",
+			zap.String("device_id", device.DeviceID),
+			zap.Int("connection_status", connData.ConnectionStatus),
+			zap.Error(err),
+		)
+	}
+	
+	// 构建标准化数据（device.data 信封）
+	rawData := map[string]interface{}{
+		"connectionStatus": connData.ConnectionStatus,
 	}
+	envelope := c.newEnvelope(msg, device, rawData, "sleepace/connectionStatus")
 	
-	streamID, err := rediscommon.PublishJSONToStream(context.Background(), c.redisClient, c.config.Sleepace.Stream, standardizedData)
+	streamID, err := c.publish(envelope, device, rediscommon.PriorityNormal)
 	if err != nil {
 		return fmt.Errorf("failed to publish to stream: %w", err)
 	}
+	if streamID == "" {
+		// 被准入策略拦截
+		return nil
+	}
 	
 	c.logger.Debug("Published sleepace connection status to Redis Streams",
 		zap.String("device_id", device.DeviceID),
@@ -278,35 +418,31 @@ func (c *MQTTConsumer) handleConnectionStatus(msg *models.ReceivedMessage, devic
 
 // handleAlarmNotify 处理报警通知数据
 func (c *MQTTConsumer) handleAlarmNotify(msg *models.ReceivedMessage, device *repository.Device) error {
-	// 报警通知数据可以发布到 Streams 或单独处理
-	// 这里选择发布到 Streams，保持数据流统一
+	// 报警通知数据发布到数据流（保持数据流统一），并转发到设备报警事件流
 	var alarmData models.AlarmNotifyData
 	if err := json.Unmarshal(msg.Data, &alarmData); err != nil {
 		return fmt.Errorf("failed to unmarshal alarm notify data: %w", err)
 	}
 	
-	standardizedData := map[string]interface{}{
-		"device_id":      device.DeviceID,
-		"tenant_id":      device.TenantID,
-		"serial_number":  device.SerialNumber,
-		"uid":            device.UID,
-		"device_type":    "Sleepace",
-		"raw_data": map[string]interface{}{
-			"alarmId":       alarmData.Id,
-			"alarmType":     alarmData.Type,
-			"alarmStatus":   alarmData.Status,
-			"userId":        alarmData.UserId,
-			"relieveReason": alarmData.RelieveReason,
-			"relieveTime":   alarmData.RelieveTime,
-		},
-		"timestamp": msg.TimeStamp,
-		"topic":     "sleepace/alarmNotify",
-	}
-	
-	streamID, err := rediscommon.PublishJSONToStream(context.Background(), c.redisClient, c.config.Sleepace.Stream, standardizedData)
+	// 构建标准化数据（device.data 信封）
+	rawData := map[string]interface{}{
+		"alarmId":       alarmData.Id,
+		"alarmType":     alarmData.Type,
+		"alarmStatus":   alarmData.Status,
+		"userId":        alarmData.UserId,
+		"relieveReason": alarmData.RelieveReason,
+		"relieveTime":   alarmData.RelieveTime,
+	}
+	envelope := c.newEnvelope(msg, device, rawData, "sleepace/alarmNotify")
+	
+	streamID, err := c.publish(envelope, device, rediscommon.PriorityNormal)
 	if err != nil {
 		return fmt.Errorf("failed to publish to stream: %w", err)
 	}
+	if streamID == "" {
+		// 被准入策略拦截
+		return nil
+	}
 	
 	c.logger.Info("Published sleepace alarm notify to Redis Streams",
 		zap.String("device_id", device.DeviceID),
@@ -314,6 +450,15 @@ func (c *MQTTConsumer) handleAlarmNotify(msg *models.ReceivedMessage, device *re
 		zap.String("stream_id", streamID),
 	)
 	
+	// 同时发布设备报警事件，由报警服务写入 alarm_events（解除时自动关闭对应报警）
+	alarm, err := deviceAlarmFromNotify(device, &alarmData)
+	if err != nil {
+		return err
+	}
+	if _, err := c.publishDeviceAlarm(msg, device, alarm); err != nil {
+		return err
+	}
+	
 	return nil
 }
 
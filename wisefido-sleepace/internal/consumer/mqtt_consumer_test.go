@@ -0,0 +1,165 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+	"wisefido-sleepace/internal/config"
+	"wisefido-sleepace/internal/models"
+	"wisefido-sleepace/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"owl-common/cache"
+	commonconfig "owl-common/config"
+	"owl-common/events"
+	"owl-common/ingest"
+	"owl-common/presence"
+	rediscommon "owl-common/redis"
+	"owl-common/workerpool"
+)
+
+const (
+	testDataStream   = "sleepace:data:stream"
+	testAlarmStream  = "device:alarm:stream"
+	testReportStream = "sleepace:report:stream"
+)
+
+// newTestConsumer 构建使用 miniredis 的消费者（准入策略与在线状态跟踪关闭）
+func newTestConsumer(t *testing.T) (*MQTTConsumer, *redis.Client, sqlmock.Sqlmock) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{}
+	cfg.Sleepace.Stream = testDataStream
+	cfg.Sleepace.AlarmStream = testAlarmStream
+	cfg.Sleepace.ReportStream = testReportStream
+	cfg.Sleepace.DualSidedModels = []string{"BM8701-2"}
+	cfg.Sleepace.LowBatteryThreshold = 20
+
+	logger := zap.NewNop()
+	c := &MQTTConsumer{
+		config:      cfg,
+		redisClient: client,
+		publisher:   rediscommon.NewStreamPublisher(client, testDataStream, commonconfig.StreamConfig{}, logger),
+		gate:        ingest.NewGate(client, commonconfig.IngestPolicyConfig{}, logger),
+		presence:    presence.NewTracker(client, nil, commonconfig.PresenceConfig{}, "sleepace", "wisefido-sleepace", logger),
+		deviceRepo:  repository.NewDeviceRepository(db, logger),
+		lowBattery:  cache.NewTTL[bool](time.Hour, 0),
+		logger:      logger,
+	}
+	return c, client, mock
+}
+
+func readStream(t *testing.T, client *redis.Client, stream string) []rediscommon.StreamMessage {
+	t.Helper()
+	msgs, err := client.XRange(context.Background(), stream, "-", "+").Result()
+	if err != nil {
+		t.Fatalf("XRANGE %s failed: %v", stream, err)
+	}
+	out := make([]rediscommon.StreamMessage, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, rediscommon.StreamMessage{ID: m.ID, Values: m.Values})
+	}
+	return out
+}
+
+func decodeAlarms(t *testing.T, client *redis.Client) []events.DeviceAlarm {
+	t.Helper()
+	var out []events.DeviceAlarm
+	for _, msg := range readStream(t, client, testAlarmStream) {
+		env, err := rediscommon.Decode[events.DeviceAlarm](msg, events.DeviceAlarmSchema)
+		if err != nil {
+			t.Fatalf("decode device alarm failed: %v", err)
+		}
+		out = append(out, env.Payload)
+	}
+	return out
+}
+
+func newMessage(t *testing.T, dataKey string, data interface{}) *models.ReceivedMessage {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	return &models.ReceivedMessage{DeviceId: "PAD01", DataKey: dataKey, TimeStamp: 1767268800, Data: raw}
+}
+
+func approvedDevice(model string) *repository.Device {
+	return &repository.Device{
+		DeviceID:          "pad-1",
+		TenantID:          "t1",
+		SerialNumber:      "PAD01",
+		Status:            "online",
+		BusinessAccess:    "approved",
+		MonitoringEnabled: true,
+		AllowAccess:       true,
+		Model:             model,
+	}
+}
+
+func sideRows(deviceID string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"device_id", "tenant_id", "serial_number", "uid", "device_name", "status", "business_access",
+		"bound_bed_id", "bound_room_id", "monitoring_enabled", "allow_access", "device_model", "firmware_version",
+	}).AddRow(deviceID, "t1", "PAD01:side", "", "Pad", "online", "approved", "bed-1", nil, true, true, "BM8701-2", "1.0")
+}
+
+// 工作池队列已满（drop_newest）时丢弃的数据也计数，整条 MQTT 消息仍被确认
+func TestHandleMessage_AcksWhenDropped(t *testing.T) {
+	pool := workerpool.New("test", commonconfig.WorkerPoolConfig{Workers: 1, QueueSize: 1, Overflow: workerpool.OverflowDropNewest}, nil)
+	pool.Start(context.Background())
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit("blocker", func() {
+		close(started)
+		<-release
+	})
+	<-started
+	pool.Submit("filler", func() {})
+	defer func() {
+		close(release)
+		pool.Stop()
+	}()
+
+	c := &MQTTConsumer{pool: pool, logger: zap.NewNop()}
+	acks := 0
+	payload := `[{"deviceId":"dev-1","dataKey":"realtime"},{"deviceId":"dev-2","dataKey":"realtime"}]`
+	if err := c.handleMessage("sleepace-57136", []byte(payload), func() { acks++ }); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+	if acks != 1 {
+		t.Fatalf("acks = %d, want 1", acks)
+	}
+	if dropped := pool.Stats().Dropped; dropped != 2 {
+		t.Fatalf("dropped = %d, want 2", dropped)
+	}
+}
+
+// 工作池已停止时未投递的数据同样计数
+func TestHandleMessage_AcksWhenPoolStopped(t *testing.T) {
+	pool := workerpool.New("test", commonconfig.WorkerPoolConfig{Workers: 1, QueueSize: 1}, nil)
+	pool.Start(context.Background())
+	pool.Stop()
+
+	c := &MQTTConsumer{pool: pool, logger: zap.NewNop()}
+	acks := 0
+	payload := `[{"deviceId":"dev-1","dataKey":"realtime"},{"deviceId":"dev-2","dataKey":"realtime"}]`
+	if err := c.handleMessage("sleepace-57136", []byte(payload), func() { acks++ }); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+	if acks != 1 {
+		t.Fatalf("acks = %d, want 1", acks)
+	}
+}
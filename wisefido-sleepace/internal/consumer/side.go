@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"context"
+	"strings"
+	"wisefido-sleepace/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// Sleepace leftRight 取值（双侧睡眠垫）
+const (
+	sleepaceSideLeft  = 0
+	sleepaceSideRight = 1
+)
+
+// isDualSided 设备型号是否为双侧睡眠垫（SLEEPACE_DUAL_SIDED_MODELS）
+func (c *MQTTConsumer) isDualSided(device *repository.Device) bool {
+	for _, model := range c.config.Sleepace.DualSidedModels {
+		if strings.EqualFold(model, device.Model) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensorFor 选择数据所属的传感器
+// 双侧睡眠垫按 leftRight 返回该侧的逻辑传感器（各自绑定床位 / 住户）及 side，单侧睡眠垫返回设备本身，side 为空
+func (c *MQTTConsumer) sensorFor(device *repository.Device, leftRight int) (*repository.Device, string, error) {
+	if !c.isDualSided(device) {
+		return device, "", nil
+	}
+	side := repository.SideLeft
+	if leftRight == sleepaceSideRight {
+		side = repository.SideRight
+	}
+	ctx := context.Background()
+	sensor, err := c.deviceRepo.ResolveSide(ctx, device, side)
+	if err != nil {
+		return nil, "", err
+	}
+	// 逻辑传感器与普通设备一样跟踪在线状态（该侧持续无数据时置为 offline）
+	if err := c.presence.Seen(ctx, sensor.DeviceID); err != nil {
+		c.logger.Warn("Failed to record device presence",
+			zap.String("device_id", sensor.DeviceID),
+			zap.Error(err),
+		)
+	}
+	return sensor, side, nil
+}
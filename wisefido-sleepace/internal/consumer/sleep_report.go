@@ -0,0 +1,139 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"wisefido-sleepace/internal/models"
+	"wisefido-sleepace/internal/repository"
+
+	"go.uber.org/zap"
+	"owl-common/config"
+	"owl-common/events"
+	"owl-common/ingest"
+	rediscommon "owl-common/redis"
+)
+
+// sleepReportStreamMaxLen 睡眠报告就绪事件流最大长度（近似裁剪）
+const sleepReportStreamMaxLen = 10000
+
+// handleSleepSummary 处理睡眠小结（一次睡眠结束后上报，按侧归属逻辑传感器）
+func (c *MQTTConsumer) handleSleepSummary(msg *models.ReceivedMessage, device *repository.Device) error {
+	var summary models.SleepSummaryData
+	if err := json.Unmarshal(msg.Data, &summary); err != nil {
+		return fmt.Errorf("failed to unmarshal sleep summary data: %w", err)
+	}
+
+	sensor, side, err := c.sensorFor(device, summary.LeftRight)
+	if err != nil {
+		return err
+	}
+
+	rawData := map[string]interface{}{
+		"startTime":     summary.StartTime,
+		"endTime":       summary.EndTime,
+		"sleepDuration": summary.SleepDuration,
+		"sleepScore":    summary.SleepScore,
+		"avgHeart":      summary.AvgHeart,
+		"avgBreath":     summary.AvgBreath,
+		"outOfBedTimes": summary.OutOfBedTimes,
+		"turnOverTimes": summary.TurnOverTimes,
+		"apneaTimes":    summary.ApneaTimes,
+		"leftRight":     summary.LeftRight,
+	}
+	if side != "" {
+		rawData["side"] = side
+	}
+	envelope := c.newEnvelope(msg, sensor, rawData, "sleepace/summary")
+	streamID, err := c.publish(envelope, sensor, rediscommon.PriorityNormal)
+	if err != nil {
+		return fmt.Errorf("failed to publish to stream: %w", err)
+	}
+	if streamID == "" {
+		// 被准入策略拦截
+		return nil
+	}
+
+	c.logger.Info("Published sleepace sleep summary to Redis Streams",
+		zap.String("device_id", sensor.DeviceID),
+		zap.String("side", side),
+		zap.Int("sleep_duration", summary.SleepDuration),
+		zap.String("stream_id", streamID),
+	)
+	return nil
+}
+
+// handleAnalysis 处理睡眠分析完成通知
+// 发布到数据流（保持数据流统一），并发布报告就绪事件，由 wisefido-data 从厂家平台下载报告
+func (c *MQTTConsumer) handleAnalysis(msg *models.ReceivedMessage, device *repository.Device) error {
+	var analysis models.AnalysisData
+	if err := json.Unmarshal(msg.Data, &analysis); err != nil {
+		return fmt.Errorf("failed to unmarshal analysis data: %w", err)
+	}
+
+	startTime, ok := ingest.ParseDeviceTime(analysis.StartTime)
+	if !ok {
+		return fmt.Errorf("invalid analysis start time: %d", analysis.StartTime)
+	}
+	endTime, ok := ingest.ParseDeviceTime(analysis.TimeStamp)
+	if !ok {
+		if endTime, ok = ingest.ParseDeviceTime(msg.TimeStamp); !ok {
+			return fmt.Errorf("invalid analysis end time: %d", analysis.TimeStamp)
+		}
+	}
+
+	sensor, side, err := c.sensorFor(device, analysis.LeftRight)
+	if err != nil {
+		return err
+	}
+
+	rawData := map[string]interface{}{
+		"analysisStartTime": startTime.Unix(),
+		"analysisEndTime":   endTime.Unix(),
+		"userId":            analysis.UserId,
+		"leftRight":         analysis.LeftRight,
+	}
+	if side != "" {
+		rawData["side"] = side
+	}
+	envelope := c.newEnvelope(msg, sensor, rawData, "sleepace/analysis")
+	streamID, err := c.publish(envelope, sensor, rediscommon.PriorityNormal)
+	if err != nil {
+		return fmt.Errorf("failed to publish to stream: %w", err)
+	}
+	if streamID == "" {
+		// 被准入策略拦截
+		return nil
+	}
+
+	deviceCode := device.SerialNumber
+	if deviceCode == "" {
+		deviceCode = device.UID
+	}
+	env := rediscommon.NewEnvelope(events.SleepReportSchema, "wisefido-sleepace", sensor.TenantID, endTime, events.SleepReportReady{
+		DeviceID:   sensor.DeviceID,
+		DeviceCode: deviceCode,
+		Side:       side,
+		UserID:     analysis.UserId,
+		StartTime:  startTime.Unix(),
+		EndTime:    endTime.Unix(),
+	})
+	values, err := rediscommon.Encode(env)
+	if err != nil {
+		return err
+	}
+	retention := config.StreamConfig{MaxLen: sleepReportStreamMaxLen}
+	reportID, err := rediscommon.PublishToStreamWithRetention(context.Background(), c.redisClient, c.config.Sleepace.ReportStream, values, retention)
+	if err != nil {
+		return fmt.Errorf("failed to publish sleep report event: %w", err)
+	}
+
+	c.logger.Info("Published sleepace sleep report ready event",
+		zap.String("device_id", sensor.DeviceID),
+		zap.String("side", side),
+		zap.Int64("start_time", startTime.Unix()),
+		zap.Int64("end_time", endTime.Unix()),
+		zap.String("stream_id", reportID),
+	)
+	return nil
+}
@@ -0,0 +1,129 @@
+package consumer
+
+import (
+	"testing"
+	"wisefido-sleepace/internal/models"
+	"wisefido-sleepace/internal/repository"
+
+	"go.uber.org/zap"
+	commonconfig "owl-common/config"
+	"owl-common/events"
+	"owl-common/ingest"
+	rediscommon "owl-common/redis"
+)
+
+func TestHandleSleepSummary_SingleSided(t *testing.T) {
+	c, client, _ := newTestConsumer(t)
+	msg := newMessage(t, "summary", models.SleepSummaryData{SleepDuration: 420, SleepScore: 85})
+	if err := c.handleSleepSummary(msg, approvedDevice("BM8701")); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := readStream(t, client, testDataStream)
+	if len(msgs) != 1 {
+		t.Fatalf("expected one data message, got %d", len(msgs))
+	}
+	env, err := rediscommon.Decode[events.DeviceData](msgs[0], events.DeviceDataSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Payload.DeviceID != "pad-1" || env.Payload.Topic != "sleepace/summary" {
+		t.Fatalf("unexpected payload %+v", env.Payload)
+	}
+	if _, ok := env.Payload.RawData["side"]; ok {
+		t.Fatal("single-sided pad should not carry side")
+	}
+	if env.Payload.RawData["sleepDuration"] != float64(420) {
+		t.Fatalf("sleepDuration = %v", env.Payload.RawData["sleepDuration"])
+	}
+}
+
+// 双侧睡眠垫的小结归属该侧的逻辑传感器
+func TestHandleSleepSummary_DualSided(t *testing.T) {
+	c, client, mock := newTestConsumer(t)
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", repository.SideRight).
+		WillReturnRows(sideRows("side-r"))
+
+	msg := newMessage(t, "summary", models.SleepSummaryData{LeftRight: sleepaceSideRight, SleepDuration: 300})
+	if err := c.handleSleepSummary(msg, approvedDevice("BM8701-2")); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := readStream(t, client, testDataStream)
+	if len(msgs) != 1 {
+		t.Fatalf("expected one data message, got %d", len(msgs))
+	}
+	env, err := rediscommon.Decode[events.DeviceData](msgs[0], events.DeviceDataSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Payload.DeviceID != "side-r" || env.Payload.RawData["side"] != repository.SideRight {
+		t.Fatalf("summary should belong to right side sensor, got %+v", env.Payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 报告就绪事件携带该侧逻辑传感器、物理设备编码、side 和厂家用户 ID
+func TestHandleAnalysis_PublishesReportReady(t *testing.T) {
+	c, client, mock := newTestConsumer(t)
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", repository.SideLeft).
+		WillReturnRows(sideRows("side-l"))
+
+	analysis := models.AnalysisData{LeftRight: sleepaceSideLeft, UserId: "vendor-user-7", StartTime: 1767240000}
+	analysis.TimeStamp = 1767268800
+	if err := c.handleAnalysis(newMessage(t, "analysis", analysis), approvedDevice("BM8701-2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if msgs := readStream(t, client, testDataStream); len(msgs) != 1 {
+		t.Fatalf("expected one data message, got %d", len(msgs))
+	}
+	reports := readStream(t, client, testReportStream)
+	if len(reports) != 1 {
+		t.Fatalf("expected one report event, got %d", len(reports))
+	}
+	env, err := rediscommon.Decode[events.SleepReportReady](reports[0], events.SleepReportSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := events.SleepReportReady{
+		DeviceID:   "side-l",
+		DeviceCode: "PAD01",
+		Side:       repository.SideLeft,
+		UserID:     "vendor-user-7",
+		StartTime:  1767240000,
+		EndTime:    1767268800,
+	}
+	if env.Payload != want {
+		t.Fatalf("report event = %+v, want %+v", env.Payload, want)
+	}
+	if env.TenantID != "t1" {
+		t.Fatalf("tenant = %s", env.TenantID)
+	}
+}
+
+// 被准入策略拦截的分析通知不发布报告就绪事件
+func TestHandleAnalysis_BlockedByIngestPolicy(t *testing.T) {
+	c, client, _ := newTestConsumer(t)
+	c.gate = ingest.NewGate(client, commonconfig.IngestPolicyConfig{Enabled: true}, zap.NewNop())
+	device := approvedDevice("BM8701")
+	device.BusinessAccess = "pending"
+
+	analysis := models.AnalysisData{StartTime: 1767240000}
+	analysis.TimeStamp = 1767268800
+	if err := c.handleAnalysis(newMessage(t, "analysis", analysis), device); err != nil {
+		t.Fatal(err)
+	}
+	if reports := readStream(t, client, testReportStream); len(reports) != 0 {
+		t.Fatalf("blocked device should not publish report events, got %d", len(reports))
+	}
+}
+
+func TestHandleAnalysis_InvalidStartTime(t *testing.T) {
+	c, _, _ := newTestConsumer(t)
+	if err := c.handleAnalysis(newMessage(t, "analysis", models.AnalysisData{}), approvedDevice("BM8701")); err == nil {
+		t.Fatal("expected error for missing start time")
+	}
+}
@@ -5,7 +5,7 @@ import "encoding/json"
 // ReceivedMessage Sleepace MQTT 消息结构（v1.0 格式）
 type ReceivedMessage struct {
 	DeviceId  string          `json:"deviceId"`  // 设备代码（device_code）
-	DataKey   string          `json:"dataKey"`   // 数据类型：realtime, connectionStatus, sleepStage, alarmNotify 等
+	DataKey   string          `json:"dataKey"`   // 数据类型：realtime, connectionStatus, sleepStage, alarmNotify, battery, summary, analysis 等
 	TimeStamp int64           `json:"timestamp"` // 时间戳
 	Data      json.RawMessage `json:"data"`      // 数据内容（JSON）
 }
@@ -48,6 +48,36 @@ type AlarmNotifyData struct {
 	RelieveTime   int64  `json:"relieveTime"`  // 解除时间
 }
 
+// BatteryData 电量数据（dataKey = "battery"）
+type BatteryData struct {
+	CommonData
+	Battery  int `json:"battery"`  // 剩余电量（%）
+	Charging int `json:"charging"` // 1=充电中
+}
+
+// SleepSummaryData 睡眠小结（dataKey = "summary"，一次睡眠结束后上报，双侧睡眠垫按侧上报）
+type SleepSummaryData struct {
+	CommonData
+	LeftRight      int   `json:"leftRight"`
+	StartTime      int64 `json:"startTime"`      // 睡眠开始时间
+	EndTime        int64 `json:"endTime"`        // 睡眠结束时间
+	SleepDuration  int   `json:"sleepDuration"`  // 睡眠时长（分钟）
+	SleepScore     int   `json:"sleepScore"`     // 睡眠评分
+	AvgHeart       int   `json:"avgHeart"`       // 平均心率
+	AvgBreath      int   `json:"avgBreath"`      // 平均呼吸率
+	OutOfBedTimes  int   `json:"outOfBedTimes"`  // 离床次数
+	TurnOverTimes  int   `json:"turnOverTimes"`  // 翻身次数
+	ApneaTimes     int   `json:"apneaTimes"`     // 呼吸暂停次数
+}
+
+// AnalysisData 睡眠分析完成通知（dataKey = "analysis"，厂家平台已生成报告，可按时间范围下载）
+type AnalysisData struct {
+	CommonData
+	LeftRight int    `json:"leftRight"`
+	UserId    string `json:"userId"`    // 厂家用户 ID
+	StartTime int64  `json:"startTime"` // 报告开始时间
+}
+
 // CommonData 通用数据字段
 type CommonData struct {
 	DeviceId  string `json:"deviceId"`
@@ -3,14 +3,21 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"owl-common/cache"
 )
 
+// ErrUnauthorizedDevice 设备未在 device_store 注册或未分配给租户
+var ErrUnauthorizedDevice = errors.New("unauthorized device")
+
 // DeviceRepository 设备仓库
 type DeviceRepository struct {
-	db     *sql.DB
-	logger *zap.Logger
+	db        *sql.DB
+	cache     *cache.TTL[*Device] // 设备身份缓存（device_code -> 设备），nil 表示不缓存
+	sideCache *cache.TTL[*Device] // 双侧睡眠垫逻辑传感器缓存（"{device_id}/{side}" -> 设备），nil 表示不缓存
+	logger    *zap.Logger
 }
 
 // NewDeviceRepository 创建设备仓库
@@ -33,8 +40,13 @@ func (r *DeviceRepository) GetDeviceByCode(deviceCode string) (*Device, error) {
 			d.status,
 			d.business_access,
 			d.bound_bed_id,
-			d.bound_room_id
+			d.bound_room_id,
+			d.monitoring_enabled,
+			COALESCE(ds.allow_access, TRUE),
+			COALESCE(ds.device_model, ''),
+			COALESCE(ds.firmware_version, '')
 		FROM devices d
+		LEFT JOIN device_store ds ON ds.device_store_id = d.device_store_id
 		WHERE d.serial_number = $1 OR d.uid = $1
 		LIMIT 1
 	`
@@ -50,6 +62,10 @@ func (r *DeviceRepository) GetDeviceByCode(deviceCode string) (*Device, error) {
 		&device.BusinessAccess,
 		&device.BoundBedID,
 		&device.BoundRoomID,
+		&device.MonitoringEnabled,
+		&device.AllowAccess,
+		&device.Model,
+		&device.FirmwareVersion,
 	)
 	
 	if err != nil {
@@ -90,8 +106,13 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 			d.status,
 			d.business_access,
 			d.bound_bed_id,
-			d.bound_room_id
+			d.bound_room_id,
+			d.monitoring_enabled,
+			COALESCE(ds.allow_access, TRUE),
+			COALESCE(ds.device_model, ''),
+			COALESCE(ds.firmware_version, '')
 		FROM devices d
+		LEFT JOIN device_store ds ON ds.device_store_id = d.device_store_id
 		WHERE (d.serial_number = $1 OR d.uid = $1)
 		LIMIT 1
 	`
@@ -107,6 +128,10 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 		&device.BusinessAccess,
 		&device.BoundBedID,
 		&device.BoundRoomID,
+		&device.MonitoringEnabled,
+		&device.AllowAccess,
+		&device.Model,
+		&device.FirmwareVersion,
 	)
 	
 	if err == nil {
@@ -164,7 +189,7 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 			zap.String("action", "connection_rejected"),
 			zap.String("security_level", "warning"),
 		)
-		return nil, fmt.Errorf("unauthorized device: not registered in device_store")
+		return nil, fmt.Errorf("%w: not registered in device_store", ErrUnauthorizedDevice)
 	}
 
 	if err != nil {
@@ -196,7 +221,7 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 			zap.String("reason", "device_not_allocated"),
 			zap.String("action", "connection_rejected"),
 		)
-		return nil, fmt.Errorf("device not allocated to tenant")
+		return nil, fmt.Errorf("%w: device not allocated to tenant", ErrUnauthorizedDevice)
 	}
 
 	// Case 1: Device is registered and allocated, create devices record
@@ -315,8 +340,13 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 			d.status,
 			d.business_access,
 			d.bound_bed_id,
-			d.bound_room_id
+			d.bound_room_id,
+			d.monitoring_enabled,
+			COALESCE(ds.allow_access, TRUE),
+			COALESCE(ds.device_model, ''),
+			COALESCE(ds.firmware_version, '')
 		FROM devices d
+		LEFT JOIN device_store ds ON ds.device_store_id = d.device_store_id
 		WHERE d.device_id = $1
 		LIMIT 1
 	`
@@ -331,6 +361,10 @@ func (r *DeviceRepository) GetOrCreateDeviceFromStore(ctx context.Context, ident
 		&device.BusinessAccess,
 		&device.BoundBedID,
 		&device.BoundRoomID,
+		&device.MonitoringEnabled,
+		&device.AllowAccess,
+		&device.Model,
+		&device.FirmwareVersion,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query newly created device: %w", err)
@@ -349,5 +383,11 @@ type Device struct {
 	BusinessAccess string
 	BoundBedID     *string
 	BoundRoomID    *string
+
+	MonitoringEnabled bool // devices.monitoring_enabled
+	AllowAccess       bool // device_store.allow_access（无 device_store 关联时为 true）
+
+	Model           string // device_store.device_model（未登记时为空）
+	FirmwareVersion string // device_store.firmware_version（未登记时为空）
 }
 
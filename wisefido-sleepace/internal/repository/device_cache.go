@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"owl-common/cache"
+	"owl-common/events"
+)
+
+// EnableCache 启用设备身份缓存
+// ttl 为已知设备的缓存时间，negativeTTL 为未授权标识符的缓存时间（0 表示不缓存）
+func (r *DeviceRepository) EnableCache(ttl, negativeTTL time.Duration) {
+	if ttl <= 0 && negativeTTL <= 0 {
+		return
+	}
+	r.cache = cache.NewTTL[*Device](ttl, negativeTTL)
+	r.sideCache = cache.NewTTL[*Device](ttl, 0)
+}
+
+// ResolveDevice 根据 Sleepace 消息中的 device_code 解析设备
+// 先查询 devices 表，不存在时尝试从 device_store 自动创建
+// 已知设备和未授权标识符都会被缓存，直到过期或收到设备变更通知
+func (r *DeviceRepository) ResolveDevice(ctx context.Context, identifier, mqttTopic string) (*Device, error) {
+	if r.cache != nil {
+		if device, missing, ok := r.cache.Get(identifier); ok {
+			if missing {
+				return nil, ErrUnauthorizedDevice
+			}
+			return device, nil
+		}
+	}
+
+	device, err := r.GetDeviceByCode(identifier)
+	if err != nil {
+		device, err = r.GetOrCreateDeviceFromStore(ctx, identifier, mqttTopic)
+	}
+	if err != nil {
+		// 只缓存“未授权”，数据库错误不缓存
+		if r.cache != nil && errors.Is(err, ErrUnauthorizedDevice) {
+			r.cache.SetMissing(identifier)
+		}
+		return nil, err
+	}
+
+	if r.cache != nil {
+		r.cache.Set(identifier, device)
+	}
+	return device, nil
+}
+
+// Invalidate 根据设备变更通知失效缓存
+func (r *DeviceRepository) Invalidate(change events.DeviceChange) {
+	if r.cache == nil {
+		return
+	}
+	if change.IsBulk() {
+		r.cache.Purge()
+		r.sideCache.Purge()
+		return
+	}
+	r.invalidateSides(change.DeviceID)
+
+	r.cache.Delete(change.SerialNumber, change.UID)
+	r.cache.DeleteFunc(func(_ string, d *Device) bool {
+		return (change.DeviceID != "" && d.DeviceID == change.DeviceID) ||
+			(change.SerialNumber != "" && d.SerialNumber == change.SerialNumber) ||
+			(change.UID != "" && d.UID == change.UID)
+	})
+
+	if r.logger != nil {
+		r.logger.Debug("Device cache invalidated",
+			zap.String("action", change.Action),
+			zap.String("device_id", change.DeviceID),
+			zap.String("serial_number", change.SerialNumber),
+			zap.String("uid", change.UID),
+		)
+	}
+}
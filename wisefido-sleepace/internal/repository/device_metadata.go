@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// UpdateMetadata 合并写入 devices.metadata（仅覆盖 fields 中的键）
+func (r *DeviceRepository) UpdateMetadata(ctx context.Context, deviceID string, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to marshal device metadata: %w", err)
+	}
+
+	query := `
+		UPDATE devices
+		SET metadata = COALESCE(metadata, '{}'::jsonb) || $2::jsonb
+		WHERE device_id = $1
+	`
+	if _, err := r.db.ExecContext(ctx, query, deviceID, string(data)); err != nil {
+		return fmt.Errorf("failed to update device metadata: %w", err)
+	}
+	return nil
+}
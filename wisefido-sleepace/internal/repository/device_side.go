@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// 双侧睡眠垫的两侧（device_sides.side）
+const (
+	SideLeft  = "left"
+	SideRight = "right"
+)
+
+// ResolveSide 解析双侧睡眠垫某一侧的逻辑传感器
+// 该侧尚未登记时自动创建逻辑传感器（business_access = 'pending'，审批并绑定床位后数据才放行），
+// 多副本同时创建时以先写入 device_sides 的为准
+func (r *DeviceRepository) ResolveSide(ctx context.Context, pad *Device, side string) (*Device, error) {
+	key := pad.DeviceID + "/" + side
+	if r.sideCache != nil {
+		if device, _, ok := r.sideCache.Get(key); ok {
+			return device, nil
+		}
+	}
+
+	device, err := r.getSideDevice(ctx, pad.DeviceID, side)
+	if err == sql.ErrNoRows {
+		if err = r.createSideDevice(ctx, pad, side); err == nil {
+			device, err = r.getSideDevice(ctx, pad.DeviceID, side)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s side of device %s: %w", side, pad.DeviceID, err)
+	}
+
+	if r.sideCache != nil {
+		r.sideCache.Set(key, device)
+	}
+	return device, nil
+}
+
+// getSideDevice 查询某一侧的逻辑传感器（未登记时返回 sql.ErrNoRows）
+// 型号和固件版本取自物理睡眠垫登记的 device_store
+func (r *DeviceRepository) getSideDevice(ctx context.Context, padDeviceID, side string) (*Device, error) {
+	query := `
+		SELECT 
+			d.device_id,
+			d.tenant_id,
+			COALESCE(d.serial_number, ''),
+			COALESCE(d.uid, ''),
+			d.device_name,
+			d.status,
+			d.business_access,
+			d.bound_bed_id,
+			d.bound_room_id,
+			d.monitoring_enabled,
+			COALESCE(ds.allow_access, TRUE),
+			COALESCE(ds.device_model, ''),
+			COALESCE(ds.firmware_version, '')
+		FROM device_sides s
+		JOIN devices d ON d.device_id = s.side_device_id
+		JOIN devices pad ON pad.device_id = s.device_id
+		LEFT JOIN device_store ds ON ds.device_store_id = pad.device_store_id
+		WHERE s.device_id = $1 AND s.side = $2
+	`
+
+	device := &Device{}
+	err := r.db.QueryRowContext(ctx, query, padDeviceID, side).Scan(
+		&device.DeviceID,
+		&device.TenantID,
+		&device.SerialNumber,
+		&device.UID,
+		&device.DeviceName,
+		&device.Status,
+		&device.BusinessAccess,
+		&device.BoundBedID,
+		&device.BoundRoomID,
+		&device.MonitoringEnabled,
+		&device.AllowAccess,
+		&device.Model,
+		&device.FirmwareVersion,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// createSideDevice 创建某一侧的逻辑传感器并登记到 device_sides
+// 序列号为 "{物理序列号}:{side}"，不会与 device_store 中的标识符冲突
+func (r *DeviceRepository) createSideDevice(ctx context.Context, pad *Device, side string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	code := pad.SerialNumber
+	if code == "" {
+		code = pad.UID
+	}
+	insertDevice := `
+		INSERT INTO devices (
+			tenant_id,
+			device_store_id,
+			device_name,
+			serial_number,
+			status,
+			business_access,
+			monitoring_enabled
+		)
+		SELECT tenant_id, device_store_id, device_name || ' (' || $2 || ')', $3, 'online', 'pending', FALSE
+		FROM devices
+		WHERE device_id = $1
+		RETURNING device_id
+	`
+	var sideDeviceID string
+	if err := tx.QueryRowContext(ctx, insertDevice, pad.DeviceID, side, code+":"+side).Scan(&sideDeviceID); err != nil {
+		return fmt.Errorf("failed to create side device: %w", err)
+	}
+
+	insertSide := `
+		INSERT INTO device_sides (tenant_id, device_id, side, side_device_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (device_id, side) DO NOTHING
+	`
+	result, err := tx.ExecContext(ctx, insertSide, pad.TenantID, pad.DeviceID, side, sideDeviceID)
+	if err != nil {
+		return fmt.Errorf("failed to register device side: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		// 其他副本已创建：回滚本次插入的设备
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if r.logger != nil {
+		r.logger.Info("Sleep pad side device auto-created",
+			zap.String("device_id", pad.DeviceID),
+			zap.String("side", side),
+			zap.String("side_device_id", sideDeviceID),
+			zap.String("tenant_id", pad.TenantID),
+		)
+	}
+	return nil
+}
+
+// invalidateSides 失效与设备相关的侧缓存（设备为物理睡眠垫或某一侧的逻辑传感器）
+func (r *DeviceRepository) invalidateSides(deviceID string) {
+	if r.sideCache == nil || deviceID == "" {
+		return
+	}
+	r.sideCache.DeleteFunc(func(key string, d *Device) bool {
+		return strings.HasPrefix(key, deviceID+"/") || d.DeviceID == deviceID
+	})
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+var sideColumns = []string{
+	"device_id", "tenant_id", "serial_number", "uid", "device_name", "status", "business_access",
+	"bound_bed_id", "bound_room_id", "monitoring_enabled", "allow_access", "device_model", "firmware_version",
+}
+
+func newSideRepo(t *testing.T) (*DeviceRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewDeviceRepository(db, zap.NewNop()), mock
+}
+
+func sideRow(deviceID, side string) *sqlmock.Rows {
+	return sqlmock.NewRows(sideColumns).
+		AddRow(deviceID, "t1", "PAD01:"+side, "", "Pad ("+side+")", "online", "approved", "bed-1", nil, true, true, "BM8701-2", "1.0")
+}
+
+var pad = &Device{DeviceID: "pad-1", TenantID: "t1", SerialNumber: "PAD01"}
+
+func TestResolveSide_Existing(t *testing.T) {
+	repo, mock := newSideRepo(t)
+	repo.EnableCache(time.Minute, time.Minute)
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", SideLeft).WillReturnRows(sideRow("side-l", SideLeft))
+
+	for i := 0; i < 2; i++ {
+		device, err := repo.ResolveSide(context.Background(), pad, SideLeft)
+		if err != nil || device.DeviceID != "side-l" || device.Model != "BM8701-2" {
+			t.Fatalf("ResolveSide #%d = %+v, %v", i, device, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected a single query: %v", err)
+	}
+}
+
+// 未登记的一侧自动创建逻辑传感器（序列号为 "{物理序列号}:{side}"）
+func TestResolveSide_CreatesMissingSide(t *testing.T) {
+	repo, mock := newSideRepo(t)
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", SideRight).WillReturnError(sql.ErrNoRows)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO devices").WithArgs("pad-1", SideRight, "PAD01:right").
+		WillReturnRows(sqlmock.NewRows([]string{"device_id"}).AddRow("side-r"))
+	mock.ExpectExec("INSERT INTO device_sides").WithArgs("t1", "pad-1", SideRight, "side-r").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", SideRight).WillReturnRows(sideRow("side-r", SideRight))
+
+	device, err := repo.ResolveSide(context.Background(), pad, SideRight)
+	if err != nil || device.DeviceID != "side-r" {
+		t.Fatalf("ResolveSide = %+v, %v", device, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 其他副本已登记该侧时回滚本次插入，使用已登记的逻辑传感器
+func TestResolveSide_ConcurrentCreate(t *testing.T) {
+	repo, mock := newSideRepo(t)
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", SideLeft).WillReturnError(sql.ErrNoRows)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO devices").WithArgs("pad-1", SideLeft, "PAD01:left").
+		WillReturnRows(sqlmock.NewRows([]string{"device_id"}).AddRow("side-dup"))
+	mock.ExpectExec("INSERT INTO device_sides").WithArgs("t1", "pad-1", SideLeft, "side-dup").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", SideLeft).WillReturnRows(sideRow("side-l", SideLeft))
+
+	device, err := repo.ResolveSide(context.Background(), pad, SideLeft)
+	if err != nil || device.DeviceID != "side-l" {
+		t.Fatalf("ResolveSide = %+v, %v", device, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidateSides(t *testing.T) {
+	repo, mock := newSideRepo(t)
+	repo.EnableCache(time.Minute, time.Minute)
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", SideLeft).WillReturnRows(sideRow("side-l", SideLeft))
+	mock.ExpectQuery("FROM device_sides").WithArgs("pad-1", SideRight).WillReturnRows(sideRow("side-r", SideRight))
+	for _, side := range []string{SideLeft, SideRight} {
+		if _, err := repo.ResolveSide(context.Background(), pad, side); err != nil {
+			t.Fatalf("ResolveSide %s: %v", side, err)
+		}
+	}
+
+	// 逻辑传感器变更只失效该侧
+	repo.invalidateSides("side-l")
+	if _, _, ok := repo.sideCache.Get("pad-1/left"); ok {
+		t.Fatal("changed side should be invalidated")
+	}
+	if _, _, ok := repo.sideCache.Get("pad-1/right"); !ok {
+		t.Fatal("other side should stay cached")
+	}
+
+	// 物理睡眠垫变更失效两侧
+	repo.invalidateSides("pad-1")
+	if repo.sideCache.Len() != 0 {
+		t.Fatalf("pad change should invalidate both sides, %d left", repo.sideCache.Len())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
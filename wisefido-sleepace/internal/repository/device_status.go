@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"owl-common/presence"
+)
+
+// SetStatus 条件更新 devices.status（实现 presence.StatusStore）
+// 仅当设备当前状态为 from 时更新，disabled / error 等状态不受在线跟踪影响
+func (r *DeviceRepository) SetStatus(ctx context.Context, deviceID, from, to string) (*presence.Device, bool, error) {
+	query := `
+		UPDATE devices d
+		SET status = $3
+		WHERE d.device_id = $1 AND d.status = $2
+		RETURNING
+			d.tenant_id::text,
+			COALESCE(d.serial_number, ''),
+			COALESCE(d.uid, ''),
+			COALESCE((SELECT ds.device_type FROM device_store ds WHERE ds.device_store_id = d.device_store_id), 'Sleepace')
+	`
+
+	device := &presence.Device{DeviceID: deviceID}
+	err := r.db.QueryRowContext(ctx, query, deviceID, from, to).Scan(
+		&device.TenantID,
+		&device.SerialNumber,
+		&device.UID,
+		&device.DeviceType,
+	)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update device status: %w", err)
+	}
+	return device, true, nil
+}
+
+// OnlineDevices 返回当前在线的睡眠监测设备 ID（实现 presence.StatusStore）
+func (r *DeviceRepository) OnlineDevices(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT d.device_id::text
+		FROM devices d
+		JOIN device_store ds ON ds.device_store_id = d.device_store_id
+		WHERE d.status = 'online' AND ds.device_type IN ('Sleepace', 'SleepPad')
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query online devices: %w", err)
+	}
+	defer rows.Close()
+
+	var deviceIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan device id: %w", err)
+		}
+		deviceIDs = append(deviceIDs, id)
+	}
+	return deviceIDs, rows.Err()
+}
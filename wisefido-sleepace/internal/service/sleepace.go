@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 	"wisefido-sleepace/internal/config"
 	"wisefido-sleepace/internal/consumer"
 	"wisefido-sleepace/internal/repository"
@@ -11,6 +12,8 @@ import (
 	"go.uber.org/zap"
 	"github.com/go-redis/redis/v8"
 	"owl-common/database"
+	"owl-common/events"
+	"owl-common/presence"
 	rediscommon "owl-common/redis"
 	mqttcommon "owl-common/mqtt"
 )
@@ -22,6 +25,8 @@ type SleepaceService struct {
 	db         *sql.DB
 	redis      *redis.Client
 	mqttClient *mqttcommon.Client
+	deviceRepo *repository.DeviceRepository
+	presence   *presence.Tracker
 	consumer   *consumer.MQTTConsumer
 }
 
@@ -47,9 +52,16 @@ func NewSleepaceService(cfg *config.Config, logger *zap.Logger) (*SleepaceServic
 	
 	// 创建Repository
 	deviceRepo := repository.NewDeviceRepository(db, logger)
+	deviceRepo.EnableCache(
+		time.Duration(cfg.Sleepace.DeviceCache.TTL)*time.Second,
+		time.Duration(cfg.Sleepace.DeviceCache.NegativeTTL)*time.Second,
+	)
+	
+	// 创建设备在线状态跟踪器
+	tracker := presence.NewTracker(redisClient, deviceRepo, cfg.Sleepace.Presence, "sleepace", "wisefido-sleepace", logger)
 	
 	// 创建Consumer
-	mqttConsumer := consumer.NewMQTTConsumer(cfg, mqttClient, redisClient, deviceRepo, logger)
+	mqttConsumer := consumer.NewMQTTConsumer(cfg, mqttClient, redisClient, deviceRepo, tracker, logger)
 	
 	return &SleepaceService{
 		config:     cfg,
@@ -57,6 +69,8 @@ func NewSleepaceService(cfg *config.Config, logger *zap.Logger) (*SleepaceServic
 		db:         db,
 		redis:      redisClient,
 		mqttClient: mqttClient,
+		deviceRepo: deviceRepo,
+		presence:   tracker,
 		consumer:   mqttConsumer,
 	}, nil
 }
@@ -65,6 +79,12 @@ func NewSleepaceService(cfg *config.Config, logger *zap.Logger) (*SleepaceServic
 func (s *SleepaceService) Start(ctx context.Context) error {
 	s.logger.Info("Starting sleepace service components")
 	
+	// 订阅设备变更通知（wisefido-data 发布），失效设备身份缓存
+	go events.WatchDeviceChanges(ctx, s.redis, s.logger, s.deviceRepo.Invalidate)
+	
+	// 扫描静默设备，置为 offline 并发布在线状态事件
+	go s.presence.Run(ctx)
+	
 	// 启动MQTT消费者
 	if err := s.consumer.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start MQTT consumer: %w", err)